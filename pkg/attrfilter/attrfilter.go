@@ -0,0 +1,156 @@
+// Package attrfilter drops span attributes before export according to an
+// allowlist and a denylist, and masks credentials left in the remaining
+// values, so sensitive or noisy attributes never leave the process regardless
+// of which code set them.
+package attrfilter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fhsmendes/open-telemetry/pkg/redact"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultDeny drops the attributes that carry full request URLs, whose paths
+// hold CEPs and whose query strings may hold credentials: the ones the
+// services' provider calls set, and the standard ones.
+const DefaultDeny = "viacep.url,zippopotam.url,cep_dataset.url,service.b.url,http.url,url.full"
+
+// Filter decides which attribute keys are kept. Patterns are exact keys or
+// prefixes ending in "*" (e.g. "viacep.*").
+type Filter struct {
+	allow []string
+	deny  []string
+}
+
+// Parse builds a Filter from comma-separated allow and deny patterns. An empty
+// allowlist keeps every key not denied; the denylist wins over the allowlist.
+func Parse(allow, deny string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.allow, err = parsePatterns(allow); err != nil {
+		return nil, fmt.Errorf("invalid attribute allowlist: %w", err)
+	}
+	if f.deny, err = parsePatterns(deny); err != nil {
+		return nil, fmt.Errorf("invalid attribute denylist: %w", err)
+	}
+	return f, nil
+}
+
+// Load reads SPAN_ATTRIBUTE_ALLOWLIST and SPAN_ATTRIBUTE_DENYLIST. An unset
+// denylist falls back to DefaultDeny; set it to "-" to drop nothing.
+func Load(getenv func(string) string) (*Filter, error) {
+	deny := getenv("SPAN_ATTRIBUTE_DENYLIST")
+	switch deny {
+	case "":
+		deny = DefaultDeny
+	case "-":
+		deny = ""
+	}
+	return Parse(getenv("SPAN_ATTRIBUTE_ALLOWLIST"), deny)
+}
+
+func parsePatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if strings.Contains(strings.TrimSuffix(p, "*"), "*") {
+			return nil, fmt.Errorf("pattern %q: only a trailing * is supported", p)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func matches(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) Keep(key string) bool {
+	if matches(f.deny, key) {
+		return false
+	}
+	return len(f.allow) == 0 || matches(f.allow, key)
+}
+
+func (f *Filter) apply(attrs []attribute.KeyValue) []attribute.KeyValue {
+	kept := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if !f.Keep(string(kv.Key)) {
+			continue
+		}
+		if kv.Value.Type() == attribute.STRING {
+			kv.Value = attribute.StringValue(redact.String(kv.Value.AsString()))
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
+
+// Processor wraps the span processor that exports spans, handing it every
+// ended span with the filtered span and event attributes and a masked status
+// description.
+type Processor struct {
+	next   sdktrace.SpanProcessor
+	filter *Filter
+}
+
+func NewProcessor(next sdktrace.SpanProcessor, filter *Filter) *Processor {
+	return &Processor{next: next, filter: filter}
+}
+
+func (p *Processor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *Processor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(filteredSpan{ReadOnlySpan: s, filter: p.filter})
+}
+
+func (p *Processor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *Processor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+type filteredSpan struct {
+	sdktrace.ReadOnlySpan
+	filter *Filter
+}
+
+func (s filteredSpan) Attributes() []attribute.KeyValue {
+	return s.filter.apply(s.ReadOnlySpan.Attributes())
+}
+
+func (s filteredSpan) Status() sdktrace.Status {
+	status := s.ReadOnlySpan.Status()
+	status.Description = redact.String(status.Description)
+	return status
+}
+
+func (s filteredSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	filtered := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Attributes = s.filter.apply(e.Attributes)
+		filtered[i] = e
+	}
+	return filtered
+}
@@ -0,0 +1,106 @@
+package attrfilter
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestFilterKeep(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow string
+		deny  string
+		key   string
+		keep  bool
+	}{
+		{"no lists", "", "", "cep", true},
+		{"denied exact", "", "http.url", "http.url", false},
+		{"denied prefix", "", "viacep.*", "viacep.url", false},
+		{"prefix does not match", "", "viacep.*", "cep", true},
+		{"allowed", "http.route,http.status_code", "", "http.route", true},
+		{"not in allowlist", "http.route,http.status_code", "", "cep", false},
+		{"deny wins", "http.*", "http.url", "http.url", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Keep(tt.key); got != tt.keep {
+				t.Errorf("Keep(%q) = %v, want %v", tt.key, got, tt.keep)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInnerWildcards(t *testing.T) {
+	if _, err := Parse("", "http.*.url"); err == nil {
+		t.Error("Parse() should reject a * that is not trailing")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	f, err := Load(getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"http.url", "viacep.url", "zippopotam.url", "cep_dataset.url", "service.b.url"} {
+		if f.Keep(key) {
+			t.Errorf("default denylist should drop %s", key)
+		}
+	}
+
+	env["SPAN_ATTRIBUTE_DENYLIST"] = "-"
+	f, _ = Load(getenv)
+	if !f.Keep("http.url") {
+		t.Error(`denylist "-" should keep http.url`)
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	filter, _ := Parse("", "http.url,exception.*")
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewProcessor(sdktrace.NewSimpleSpanProcessor(exporter), filter)),
+	)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "call", trace.WithAttributes(
+		attribute.String("http.url", "https://api.weatherapi.com/v1/current.json?key=secret"),
+		attribute.Int("http.status_code", 200),
+	))
+	span.AddEvent("exception", trace.WithAttributes(
+		attribute.String("exception.message", "Get https://api.weatherapi.com/v1/current.json?key=secret: timeout"),
+		attribute.String("provider", "weatherapi"),
+	))
+	span.AddEvent("retry", trace.WithAttributes(
+		attribute.String("error", `Get "https://api.weatherapi.com/v1/current.json?key=secret": timeout`),
+	))
+	span.SetStatus(codes.Error, `Get "https://api.weatherapi.com/v1/current.json?key=secret": timeout`)
+	span.End()
+
+	got := exporter.GetSpans()[0]
+	if len(got.Attributes) != 1 || got.Attributes[0].Key != "http.status_code" {
+		t.Errorf("span attributes = %v, want only http.status_code", got.Attributes)
+	}
+	if attrs := got.Events[0].Attributes; len(attrs) != 1 || attrs[0].Key != "provider" {
+		t.Errorf("event attributes = %v, want only provider", attrs)
+	}
+	want := `Get "https://api.weatherapi.com/v1/current.json?key=REDACTED": timeout`
+	if got := got.Events[1].Attributes[0].Value.AsString(); got != want {
+		t.Errorf("event attribute = %q, want %q", got, want)
+	}
+	if got.Status.Description != want {
+		t.Errorf("status description = %q, want %q", got.Status.Description, want)
+	}
+}
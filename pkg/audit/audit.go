@@ -0,0 +1,170 @@
+// Package audit records who changed what through the admin endpoints of both
+// services, with the state before and after each change, and serves the
+// recorded entries back.
+package audit
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxEntries = 1000
+
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	Before  any       `json:"before,omitempty"`
+	After   any       `json:"after,omitempty"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// Logger records admin actions to an append-only JSON lines file, or to the
+// audit_entries table, and keeps the most recent entries in memory for the
+// query endpoint.
+type Logger struct {
+	mu      sync.Mutex
+	file    *os.File
+	db      *sql.DB
+	entries []Entry
+}
+
+// NewLogger opens (or creates) the audit file at path. With an empty path the
+// log is kept in memory only.
+func NewLogger(path string) (*Logger, error) {
+	l := &Logger{}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := l.loadExisting(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+func (l *Logger) loadExisting(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		l.append(e)
+	}
+	return scanner.Err()
+}
+
+func (l *Logger) append(e Entry) {
+	l.entries = append(l.entries, e)
+	if len(l.entries) > maxEntries {
+		l.entries = l.entries[len(l.entries)-maxEntries:]
+	}
+}
+
+func (l *Logger) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.append(e)
+	if l.db != nil {
+		return l.insert(e)
+	}
+	if l.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *Logger) Entries(actor, action string) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := []Entry{}
+	for _, e := range l.entries {
+		if (actor == "" || e.Actor == actor) && (action == "" || e.Action == action) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Middleware records every call to the wrapped admin handler, capturing the
+// state returned by snapshot before and after it runs. The actor is the
+// identity middleware.RequireAPIKey authenticated the caller as; requests it
+// did not guard are recorded as "unknown".
+func (l *Logger) Middleware(action string, snapshot func() any) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			before := snapshot()
+			next.ServeHTTP(w, r)
+
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			entry := Entry{
+				Time:   time.Now().UTC(),
+				Actor:  middleware.Identity(r.Context()),
+				Action: action,
+				Before: before,
+				After:  snapshot(),
+			}
+			if entry.Actor == "" {
+				entry.Actor = "unknown"
+			}
+			if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+				entry.TraceID = sc.TraceID().String()
+			}
+
+			if err := l.Record(entry); err != nil {
+				slog.ErrorContext(ctx, "Failed to write audit log", "action", action, "error", err)
+			}
+		})
+	}
+}
+
+// Handler lists audit entries, optionally filtered by ?actor= and ?action=.
+func (l *Logger) Handler(w http.ResponseWriter, r *http.Request) {
+	entries := l.Entries(r.URL.Query().Get("actor"), r.URL.Query().Get("action"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestLoggerMiddleware(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := NewLogger(path)
+	if err != nil {
+		t.Fatalf("NewLogger() unexpected error: %v", err)
+	}
+
+	state := "ready"
+	h := l.Middleware("drain", func() any { return state })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state = "draining"
+	}))
+	h = middleware.RequireAPIKey(middleware.APIKeys{"k1": "ops@example.com"})(h)
+
+	req := httptest.NewRequest(http.MethodPost, "/drain", nil)
+	req.Header.Set(middleware.HeaderAPIKey, "k1")
+	// Claimed identities are ignored; the actor is the one of the key
+	req.Header.Set("X-Admin-Actor", "someone-else")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	l.Close()
+
+	// Entries must survive a restart
+	reopened, err := NewLogger(path)
+	if err != nil {
+		t.Fatalf("NewLogger() reopen unexpected error: %v", err)
+	}
+	defer reopened.Close()
+
+	entries := reopened.Entries("", "drain")
+	if len(entries) != 1 {
+		t.Fatalf("Entries() = %+v, want 1 entry", entries)
+	}
+	e := entries[0]
+	if e.Actor != "ops@example.com" || e.Before != "ready" || e.After != "draining" {
+		t.Errorf("entry = %+v, want actor ops@example.com and ready -> draining", e)
+	}
+	if e.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("entry trace ID = %q, want the trace ID from traceparent", e.TraceID)
+	}
+
+	if got := reopened.Entries("someone-else", ""); len(got) != 0 {
+		t.Errorf("Entries(actor filter) = %+v, want none", got)
+	}
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NewSQLLogger records to the audit_entries table of db, loading the most
+// recent entries already in it. The table is created by the schema migrations
+// of the service that opens db. Closing the logger leaves db open.
+func NewSQLLogger(db *sql.DB) (*Logger, error) {
+	rows, err := db.Query(`SELECT time, actor, action, before, after, trace_id FROM (
+	SELECT id, time, actor, action, before, after, trace_id FROM audit_entries ORDER BY id DESC LIMIT ?
+) ORDER BY id`, maxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	l := &Logger{db: db}
+	for rows.Next() {
+		var e Entry
+		var nanos int64
+		var before, after sql.NullString
+		if err := rows.Scan(&nanos, &e.Actor, &e.Action, &before, &after, &e.TraceID); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		e.Time = time.Unix(0, nanos).UTC()
+		// Like lines of the file log, a state that cannot be decoded is dropped
+		// rather than failing startup
+		e.Before = unmarshalState(before)
+		e.After = unmarshalState(after)
+		l.append(e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return l, nil
+}
+
+func (l *Logger) insert(e Entry) error {
+	before, err := marshalState(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalState(e.After)
+	if err != nil {
+		return err
+	}
+	_, err = l.db.Exec(`INSERT INTO audit_entries (time, actor, action, before, after, trace_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.Time.UnixNano(), e.Actor, e.Action, before, after, e.TraceID)
+	return err
+}
+
+func marshalState(v any) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func unmarshalState(s sql.NullString) any {
+	if !s.Valid {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s.String), &v); err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+// Package buildinfo reports the version, commit and environment a service was
+// built and deployed with, for /version, the telemetry resource and the
+// User-Agent of outgoing requests.
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime/debug"
+)
+
+// Version and Commit can be set at build time with
+// -ldflags "-X github.com/fhsmendes/open-telemetry/pkg/buildinfo.Version=v1.2.3 -X github.com/fhsmendes/open-telemetry/pkg/buildinfo.Commit=abc123".
+var (
+	Version = ""
+	Commit  = ""
+)
+
+type Info struct {
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+	Environment string `json:"environment"`
+	GoVersion   string `json:"go_version"`
+}
+
+// Get returns the ldflags values, falling back to the module version and VCS
+// revision the binary was built with, then to "dev" and "unknown".
+func Get() Info {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		bi = nil
+	}
+	return resolve(Version, Commit, os.Getenv("DEPLOYMENT_ENVIRONMENT"), bi)
+}
+
+func resolve(version, commit, environment string, bi *debug.BuildInfo) Info {
+	info := Info{
+		Version:     version,
+		Commit:      commit,
+		Environment: environment,
+	}
+
+	if bi != nil {
+		info.GoVersion = bi.GoVersion
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = s.Value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Environment == "" {
+		info.Environment = "development"
+	}
+
+	return info
+}
+
+// Handler answers /version with Get.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Get())
+}
@@ -0,0 +1,70 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	built := &debug.BuildInfo{
+		GoVersion: "go1.24.5",
+		Main:      debug.Module{Version: "v1.4.0"},
+		Settings:  []debug.BuildSetting{{Key: "vcs.revision", Value: "0123abc"}},
+	}
+	devel := &debug.BuildInfo{
+		GoVersion: "go1.24.5",
+		Main:      debug.Module{Version: "(devel)"},
+	}
+
+	tests := []struct {
+		name            string
+		version, commit string
+		environment     string
+		bi              *debug.BuildInfo
+		want            Info
+	}{
+		{
+			name:    "ldflags win over the build info",
+			version: "v2.0.0", commit: "feedbee", environment: "production",
+			bi:   built,
+			want: Info{Version: "v2.0.0", Commit: "feedbee", Environment: "production", GoVersion: "go1.24.5"},
+		},
+		{
+			name: "build info fills what ldflags left empty",
+			bi:   built,
+			want: Info{Version: "v1.4.0", Commit: "0123abc", Environment: "development", GoVersion: "go1.24.5"},
+		},
+		{
+			name: "devel builds fall back to dev",
+			bi:   devel,
+			want: Info{Version: "dev", Commit: "unknown", Environment: "development", GoVersion: "go1.24.5"},
+		},
+		{
+			name: "no build info",
+			want: Info{Version: "dev", Commit: "unknown", Environment: "development"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolve(tt.version, tt.commit, tt.environment, tt.bi); got != tt.want {
+				t.Errorf("resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	prevVersion, prevCommit := Version, Commit
+	t.Cleanup(func() { Version, Commit = prevVersion, prevCommit })
+	Version, Commit = "v1.2.3", "abc123"
+	t.Setenv("DEPLOYMENT_ENVIRONMENT", "staging")
+
+	got := Get()
+	if got.Version != "v1.2.3" || got.Commit != "abc123" || got.Environment != "staging" {
+		t.Errorf("Get() = %+v, want the ldflags values in staging", got)
+	}
+	if got.GoVersion == "" {
+		t.Error("Get() has no Go version")
+	}
+}
@@ -0,0 +1,59 @@
+// Package carrier moves trace context across the async boundaries of the
+// services: headers of outgoing messages and webhooks, and metadata stored
+// with work that runs later. Work picked up from a carrier continues the
+// trace of whatever enqueued it, with that span as its remote parent.
+package carrier
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Carrier is where trace context is written to and read from.
+type Carrier = propagation.TextMapCarrier
+
+// Headers carries trace context in the headers of an HTTP request, such as a
+// webhook call.
+func Headers(h http.Header) Carrier {
+	return propagation.HeaderCarrier(h)
+}
+
+// Metadata carries trace context with queued work, such as message headers or
+// job metadata. It marshals to a JSON object, so it can be stored with the
+// work it describes.
+type Metadata map[string]string
+
+func (m Metadata) Get(key string) string {
+	return propagation.MapCarrier(m).Get(key)
+}
+
+func (m Metadata) Set(key, value string) {
+	propagation.MapCarrier(m).Set(key, value)
+}
+
+func (m Metadata) Keys() []string {
+	return propagation.MapCarrier(m).Keys()
+}
+
+// Inject writes the trace context and baggage of ctx to c with the global
+// propagator.
+func Inject(ctx context.Context, c Carrier) {
+	otel.GetTextMapPropagator().Inject(ctx, c)
+}
+
+// Extract returns ctx with the trace context and baggage read from c, so spans
+// started from it are children of the remote span that injected c.
+func Extract(ctx context.Context, c Carrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, c)
+}
+
+// Capture returns the trace context of ctx as Metadata, to store with work
+// handed to another goroutine or process.
+func Capture(ctx context.Context) Metadata {
+	m := Metadata{}
+	Inject(ctx, m)
+	return m
+}
@@ -0,0 +1,64 @@
+package carrier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCarriersLinkRemoteParent(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	defer otel.SetTextMapPropagator(previous)
+
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "enqueue")
+	defer parent.End()
+
+	tests := []struct {
+		name string
+		// send writes the trace context of ctx and returns the carrier the
+		// receiving side reads.
+		send func(ctx context.Context) Carrier
+	}{
+		{"webhook headers", func(ctx context.Context) Carrier {
+			h := http.Header{}
+			Inject(ctx, Headers(h))
+			return Headers(h)
+		}},
+		{"job metadata", func(ctx context.Context) Carrier {
+			// Stored work is serialized and read back later
+			b, err := json.Marshal(Capture(ctx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m Metadata
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := Extract(context.Background(), tt.send(ctx))
+			remote := trace.SpanContextFromContext(received)
+			if !remote.IsRemote() || remote.SpanID() != parent.SpanContext().SpanID() {
+				t.Fatalf("extracted span context = %+v, want the remote enqueue span", remote)
+			}
+
+			_, child := tracer.Start(received, "process")
+			defer child.End()
+			if got := child.(sdktrace.ReadOnlySpan).Parent(); got.SpanID() != parent.SpanContext().SpanID() || got.TraceID() != parent.SpanContext().TraceID() {
+				t.Errorf("child parent = %s/%s, want %s/%s", got.TraceID(), got.SpanID(), parent.SpanContext().TraceID(), parent.SpanContext().SpanID())
+			}
+		})
+	}
+}
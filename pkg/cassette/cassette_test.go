@@ -0,0 +1,82 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":2007,"message":"quota exceeded"}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	url := srv.URL + "/v1/current.json?key=secret-api-key&q=Recife"
+
+	recorder := &http.Client{Transport: Wrap(nil, ModeRecord, dir)}
+	resp, err := recorder.Get(url)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	recorded, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("recorded %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if strings.Contains(string(data), "secret-api-key") {
+		t.Error("cassette contains the API key")
+	}
+
+	srv.Close()
+	player := &http.Client{Transport: Wrap(nil, ModeReplay, dir)}
+	// A different key must still match the recording
+	resp, err = player.Get(strings.Replace(url, "secret-api-key", "other-key", 1))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	replayed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("replayed status = %d, want 429", resp.StatusCode)
+	}
+	if string(replayed) != string(recorded) {
+		t.Errorf("replayed body = %s, want %s", replayed, recorded)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("replayed Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	if calls != 1 {
+		t.Errorf("upstream called %d times, want 1", calls)
+	}
+}
+
+func TestReplayMiss(t *testing.T) {
+	player := &http.Client{Transport: Wrap(nil, ModeReplay, t.TempDir())}
+	if _, err := player.Get("http://viacep.invalid/ws/01001000/json/"); err == nil {
+		t.Error("replay without a recording should fail")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for value, want := range map[string]Mode{"": ModeOff, "off": ModeOff, "record": ModeRecord, " Replay ": ModeReplay} {
+		if got, err := ParseMode(value); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseMode("rewind"); err == nil {
+		t.Error("ParseMode(rewind) expected error")
+	}
+}
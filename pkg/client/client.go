@@ -0,0 +1,166 @@
+// Package client is a Go SDK for the public temperature API served by
+// service-input.
+//
+// Failed calls return an *APIError that wraps one of the sentinel errors
+// below, so callers can branch with errors.Is and read the details with
+// errors.As:
+//
+//	temp, err := c.Temperature(ctx, "01001000")
+//	var apiErr *client.APIError
+//	switch {
+//	case errors.Is(err, client.ErrNotFound):
+//		// unknown CEP
+//	case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+//		// back off for apiErr.RetryAfter
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCEP is returned for 422 responses: the CEP is malformed.
+	ErrInvalidCEP = errors.New("invalid zipcode")
+	// ErrNotFound is returned for 404 responses: the CEP does not exist.
+	ErrNotFound = errors.New("zipcode not found")
+	// ErrRateLimited is returned for 429 responses; see APIError.RetryAfter.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServerUnavailable is returned for 502, 503 and 504 responses, which
+	// include maintenance and provider outages; see APIError.RetryAfter.
+	ErrServerUnavailable = errors.New("server unavailable")
+)
+
+// APIError describes a non-2xx response.
+type APIError struct {
+	StatusCode int
+	// Code is the machine-readable code of the response body, when present
+	// (e.g. "maintenance", "quota_exceeded").
+	Code    string
+	Message string
+	// RetryAfter is parsed from the Retry-After header; zero when absent.
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("temperature API returned %d", e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error for the status, or nil for statuses
+// without one.
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+type Temperature struct {
+	City  string  `json:"city"`
+	TempC float64 `json:"temp_C"`
+	TempF float64 `json:"temp_F"`
+	TempK float64 `json:"temp_K"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New returns a client for the API at baseURL (e.g. "http://localhost:8080").
+// A nil httpClient uses http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+// Temperature returns the current temperature of the city of cep.
+func (c *Client) Temperature(ctx context.Context, cep string) (Temperature, error) {
+	body, err := json.Marshal(map[string]string{"cep": cep})
+	if err != nil {
+		return Temperature{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/temperature", bytes.NewReader(body))
+	if err != nil {
+		return Temperature{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Temperature{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Temperature{}, newAPIError(resp)
+	}
+
+	var temp Temperature
+	if err := json.NewDecoder(resp.Body).Decode(&temp); err != nil {
+		return Temperature{}, fmt.Errorf("failed to decode temperature: %w", err)
+	}
+	return temp, nil
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnprocessableEntity:
+		e.kind = ErrInvalidCEP
+	case http.StatusNotFound:
+		e.kind = ErrNotFound
+	case http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		e.kind = ErrServerUnavailable
+	}
+
+	// Errors come as {"message", "code"} JSON or, for the older responses,
+	// as plain text
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Message != "" {
+		e.Message, e.Code = body.Message, body.Code
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	return e
+}
+
+// parseRetryAfter accepts both forms of the header: delay in seconds or an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTemperature(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/temperature" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"city":"São Paulo","temp_C":20,"temp_F":68,"temp_K":293}`))
+	}))
+	defer srv.Close()
+
+	temp, err := New(srv.URL+"/", nil).Temperature(context.Background(), "01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp.City != "São Paulo" || temp.TempC != 20 {
+		t.Errorf("Temperature() = %+v", temp)
+	}
+}
+
+func TestTemperatureErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		want       error
+		code       string
+		message    string
+		wait       time.Duration
+	}{
+		{"invalid", 422, "", `{"message":"invalid zipcode"}`, ErrInvalidCEP, "", "invalid zipcode", 0},
+		{"not found as plain text", 404, "", "can not find zipcode", ErrNotFound, "", "can not find zipcode", 0},
+		{"rate limited", 429, "30", `{"message":"too many requests"}`, ErrRateLimited, "", "too many requests", 30 * time.Second},
+		{"maintenance", 503, "300", `{"message":"service under maintenance","code":"maintenance"}`, ErrServerUnavailable, "maintenance", "service under maintenance", 5 * time.Minute},
+		{"provider down", 502, "", `{"message":"provider unavailable","code":"provider_unavailable"}`, ErrServerUnavailable, "provider_unavailable", "provider unavailable", 0},
+		{"internal", 500, "", `{"message":"internal server error"}`, nil, "", "internal server error", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := New(srv.URL, nil).Temperature(context.Background(), "01001000")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *APIError", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			if tt.want == nil && apiErr.Unwrap() != nil {
+				t.Errorf("Unwrap() = %v, want nil", apiErr.Unwrap())
+			}
+			if apiErr.Code != tt.code || apiErr.Message != tt.message || apiErr.RetryAfter != tt.wait {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"Mon, 04 Aug 2025 12:01:00 GMT", time.Minute},
+		{"Mon, 04 Aug 2025 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
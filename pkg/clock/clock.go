@@ -0,0 +1,54 @@
+// Package clock provides the current time and random draws that expirations,
+// cooldowns and jitter depend on, so tests can control them.
+package clock
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time for code that compares ages and
+// expirations, so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a Clock that only moves when told to.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Rand returns a float in [0, 1); it is the seam for jitter decisions.
+type Rand func() float64
+
+// DefaultRand draws from the math/rand/v2 global source.
+var DefaultRand Rand = rand.Float64
+
+// Fixed returns a Rand that always returns v.
+func Fixed(v float64) Rand {
+	return func() float64 { return v }
+}
@@ -0,0 +1,19 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+
+	if got := clk.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %s, want %s", got, start)
+	}
+	clk.Advance(90 * time.Second)
+	if got := clk.Now(); !got.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Now() after Advance = %s, want %s", got, start.Add(90*time.Second))
+	}
+}
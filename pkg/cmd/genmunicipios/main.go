@@ -0,0 +1,170 @@
+// Command genmunicipios vendors the full list of Brazilian municipios, with
+// the approximate coordinates of each seat, into pkg/geo/municipios.csv. It
+// downloads a municipios export keyed by IBGE code and rewrites it in the
+// layout pkg/geo embeds (codigo_ibge,nome,uf,latitude,longitude), sorted by
+// code. Run it from pkg/geo through go generate and commit the result:
+//
+//	go generate ./geo
+//
+// The file is only written when the export covers every state and at least
+// minMunicipios municipios, so a truncated download never replaces the
+// vendored copy.
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultURL is a CSV export of the IBGE municipios with one row per municipio:
+// codigo_ibge,nome,latitude,longitude,capital,codigo_uf,...
+const DefaultURL = "https://raw.githubusercontent.com/kelvins/municipios-brasileiros/main/csv/municipios.csv"
+
+// Brazil has 5,570 municipios; the check leaves room for a few being merged
+// or split between IBGE releases.
+const minMunicipios = 5500
+
+var ufByCode = map[string]string{
+	"11": "RO", "12": "AC", "13": "AM", "14": "RR", "15": "PA", "16": "AP", "17": "TO",
+	"21": "MA", "22": "PI", "23": "CE", "24": "RN", "25": "PB", "26": "PE", "27": "AL", "28": "SE", "29": "BA",
+	"31": "MG", "32": "ES", "33": "RJ", "35": "SP",
+	"41": "PR", "42": "SC", "43": "RS",
+	"50": "MS", "51": "MT", "52": "GO", "53": "DF",
+}
+
+type municipio struct {
+	ibge, name, uf, latitude, longitude string
+}
+
+func main() {
+	url := flag.String("url", DefaultURL, "municipios export to download")
+	out := flag.String("out", "municipios.csv", "file to write")
+	flag.Parse()
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(*url)
+	if err != nil {
+		log.Fatalf("failed to download %s: %v", *url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("failed to download %s: status %d", *url, resp.StatusCode)
+	}
+
+	rows, err := convert(resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(rows) < minMunicipios {
+		log.Fatalf("export has %d municipios, want at least %d; keeping %s", len(rows), minMunicipios, *out)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("failed to create %s: %v", *out, err)
+	}
+	if err := write(f, rows); err != nil {
+		f.Close()
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+	log.Printf("wrote %d municipios to %s", len(rows), *out)
+}
+
+// convert reads the export, validates every row and returns the municipios
+// sorted by IBGE code. Columns are found by name, so their order may change.
+func convert(r io.Reader) ([]municipio, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	for _, name := range []string{"codigo_ibge", "nome", "latitude", "longitude"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("export has no %s column", name)
+		}
+	}
+
+	var rows []municipio
+	seen := make(map[string]bool)
+	states := make(map[string]bool)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export: %w", err)
+		}
+
+		m := municipio{
+			ibge:      record[col["codigo_ibge"]],
+			name:      record[col["nome"]],
+			latitude:  record[col["latitude"]],
+			longitude: record[col["longitude"]],
+		}
+		if len(m.ibge) != 7 {
+			return nil, fmt.Errorf("invalid IBGE code %q", m.ibge)
+		}
+		uf, ok := ufByCode[m.ibge[:2]]
+		if !ok {
+			return nil, fmt.Errorf("IBGE code %s has an unknown state", m.ibge)
+		}
+		m.uf = uf
+		if seen[m.ibge] {
+			return nil, fmt.Errorf("IBGE code %s appears twice", m.ibge)
+		}
+		if err := checkCoordinates(m); err != nil {
+			return nil, err
+		}
+
+		seen[m.ibge] = true
+		states[uf] = true
+		rows = append(rows, m)
+	}
+
+	if len(states) != len(ufByCode) {
+		return nil, fmt.Errorf("export covers %d of %d states", len(states), len(ufByCode))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ibge < rows[j].ibge })
+	return rows, nil
+}
+
+// checkCoordinates rejects coordinates that cannot be in Brazil, which catches
+// swapped or misparsed columns.
+func checkCoordinates(m municipio) error {
+	lat, latErr := strconv.ParseFloat(m.latitude, 64)
+	lon, lonErr := strconv.ParseFloat(m.longitude, 64)
+	if latErr != nil || lonErr != nil {
+		return fmt.Errorf("invalid coordinates for municipio %s", m.ibge)
+	}
+	if lat < -34 || lat > 6 || lon < -74 || lon > -28 {
+		return fmt.Errorf("coordinates of municipio %s (%s,%s) are outside Brazil", m.ibge, m.latitude, m.longitude)
+	}
+	return nil
+}
+
+func write(w io.Writer, rows []municipio) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"codigo_ibge", "nome", "uf", "latitude", "longitude"})
+	for _, m := range rows {
+		cw.Write([]string{m.ibge, m.name, m.uf, m.latitude, m.longitude})
+	}
+	cw.Flush()
+	return cw.Error()
+}
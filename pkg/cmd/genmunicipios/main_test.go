@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// export builds an export in the upstream layout with one made-up municipio
+// per state plus the given rows.
+func export(extra ...string) string {
+	var b strings.Builder
+	b.WriteString("codigo_ibge,nome,latitude,longitude,capital,codigo_uf,siafi_id,ddd,fuso_horario\n")
+	codes := make([]string, 0, len(ufByCode))
+	for code := range ufByCode {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "%s99999,Municipio %s,-10.5,-50.25,0,%s,0000,61,America/Sao_Paulo\n", code, code, code)
+	}
+	for _, row := range extra {
+		b.WriteString(row + "\n")
+	}
+	return b.String()
+}
+
+func TestConvert(t *testing.T) {
+	rows, err := convert(strings.NewReader(export(
+		"3509502,Campinas,-22.9053,-47.0659,0,35,6291,19,America/Sao_Paulo",
+	)))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(rows) != len(ufByCode)+1 {
+		t.Fatalf("convert returned %d rows, want %d", len(rows), len(ufByCode)+1)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, rows); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "codigo_ibge,nome,uf,latitude,longitude\n1199999,Municipio 11,RO,-10.5,-50.25\n") {
+		t.Errorf("output does not start with the header and the lowest code:\n%s", out)
+	}
+	if !strings.Contains(out, "\n3509502,Campinas,SP,-22.9053,-47.0659\n") {
+		t.Errorf("output has no Campinas row:\n%s", out)
+	}
+}
+
+func TestConvert_Rejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing column", "codigo_ibge,nome,latitude\n3509502,Campinas,-22.9053\n"},
+		{"short code", export("350950,Campinas,-22.9053,-47.0659,0,35,6291,19,America/Sao_Paulo")},
+		{"unknown state", export("9909502,Campinas,-22.9053,-47.0659,0,99,6291,19,America/Sao_Paulo")},
+		{"duplicate code", export("3599999,Campinas,-22.9053,-47.0659,0,35,6291,19,America/Sao_Paulo")},
+		{"swapped coordinates", export("3509502,Campinas,-47.0659,-22.9053,0,35,6291,19,America/Sao_Paulo")},
+		{"missing coordinates", export("3509502,Campinas,,,0,35,6291,19,America/Sao_Paulo")},
+		{"missing states", "codigo_ibge,nome,latitude,longitude\n3509502,Campinas,-22.9053,-47.0659\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := convert(strings.NewReader(tt.input)); err == nil {
+				t.Error("convert accepted the export")
+			}
+		})
+	}
+}
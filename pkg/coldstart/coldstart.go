@@ -0,0 +1,194 @@
+// Package coldstart measures how long a process takes to start serving: the
+// duration of each initialization stage, the time from process start to
+// ready and the latency of the first request, so cold starts on Cloud Run can
+// be quantified and optimized.
+package coldstart
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/fhsmendes/open-telemetry/pkg/coldstart"
+
+// processStart is set while the packages are initialized, before main runs,
+// which is as close to the start of the process as Go gets.
+var processStart = time.Now()
+
+var (
+	meter = otel.Meter(scope)
+
+	stageDuration, _ = meter.Float64Histogram(
+		"coldstart.stage.duration",
+		metric.WithDescription("Duration of the initialization stages of the process, by stage"),
+		metric.WithUnit("s"),
+	)
+	readyDuration, _ = meter.Float64Histogram(
+		"coldstart.ready.duration",
+		metric.WithDescription("Time from process start until the process was ready to serve"),
+		metric.WithUnit("s"),
+	)
+	firstRequestDuration, _ = meter.Float64Histogram(
+		"coldstart.first_request.duration",
+		metric.WithDescription("Duration of the first request the process served"),
+		metric.WithUnit("s"),
+	)
+	firstRequestDelay, _ = meter.Float64Histogram(
+		"coldstart.first_request.delay",
+		metric.WithDescription("Time from process start until the first request was answered"),
+		metric.WithUnit("s"),
+	)
+)
+
+// ProcessStart is when the process started.
+func ProcessStart() time.Time {
+	return processStart
+}
+
+// Stage is the timing of an initialization stage.
+type Stage struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Report is the start of the process as served by status endpoints.
+type Report struct {
+	ProcessStart time.Time `json:"process_start"`
+	// ReadyMs is the time from process start to ready, zero until then.
+	ReadyMs float64       `json:"ready_ms,omitempty"`
+	Stages  []StageReport `json:"stages"`
+}
+
+type StageReport struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Recorder times the initialization of a process. Telemetry recorded before
+// the providers are set up is dropped, so the stages are only recorded once
+// Ready is called.
+type Recorder struct {
+	start time.Time
+
+	mu     sync.Mutex
+	stages []Stage
+	ready  time.Time
+
+	served atomic.Bool
+}
+
+func New() *Recorder {
+	return &Recorder{start: processStart}
+}
+
+// Stage runs fn as the initialization stage name and returns its error.
+func (r *Recorder) Stage(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.mu.Lock()
+	r.stages = append(r.stages, Stage{Name: name, Start: start, Duration: time.Since(start), Err: err})
+	r.mu.Unlock()
+	return err
+}
+
+// Stages returns the stages timed so far, in the order they ran.
+func (r *Recorder) Stages() []Stage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Stage(nil), r.stages...)
+}
+
+// Report returns the stages timed so far and, once ready, how long the
+// process took to get there.
+func (r *Recorder) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{ProcessStart: r.start, Stages: make([]StageReport, len(r.stages))}
+	if !r.ready.IsZero() {
+		report.ReadyMs = milliseconds(r.ready.Sub(r.start))
+	}
+	for i, stage := range r.stages {
+		report.Stages[i] = StageReport{Name: stage.Name, DurationMs: milliseconds(stage.Duration)}
+		if stage.Err != nil {
+			report.Stages[i].Error = stage.Err.Error()
+		}
+	}
+	return report
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Ready marks the process ready to serve. It records the process start time
+// as a gauge and the stages as metrics and as spans of a cold-start trace
+// that begins at process start.
+func (r *Recorder) Ready(ctx context.Context) {
+	r.mu.Lock()
+	r.ready = time.Now()
+	ready, stages := r.ready, append([]Stage(nil), r.stages...)
+	r.mu.Unlock()
+
+	meter.Float64ObservableGauge("process.start_time",
+		metric.WithDescription("Unix time the process started at"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(float64(r.start.UnixNano()) / 1e9)
+			return nil
+		}),
+	)
+
+	tracer := otel.Tracer(scope)
+	ctx, span := tracer.Start(ctx, "cold-start", trace.WithNewRoot(), trace.WithTimestamp(r.start))
+	for _, stage := range stages {
+		attrs := metric.WithAttributes(attribute.String("stage", stage.Name))
+		stageDuration.Record(ctx, stage.Duration.Seconds(), attrs)
+
+		_, child := tracer.Start(ctx, "init "+stage.Name, trace.WithTimestamp(stage.Start))
+		if stage.Err != nil {
+			child.RecordError(stage.Err)
+			child.SetStatus(codes.Error, stage.Err.Error())
+		}
+		child.End(trace.WithTimestamp(stage.Start.Add(stage.Duration)))
+	}
+	readyDuration.Record(ctx, ready.Sub(r.start).Seconds())
+	span.End(trace.WithTimestamp(ready))
+}
+
+// Middleware records the duration of the first request the process answers,
+// and how long after process start it was answered, marking its span with
+// coldstart.first_request.
+func (r *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.served.Load() {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, req)
+		if !r.served.CompareAndSwap(false, true) {
+			return
+		}
+		ctx := req.Context()
+		end := time.Now()
+		firstRequestDuration.Record(ctx, end.Sub(start).Seconds())
+		firstRequestDelay.Record(ctx, end.Sub(r.start).Seconds())
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Bool("coldstart.first_request", true),
+			attribute.Float64("coldstart.since_start_ms", milliseconds(end.Sub(r.start))),
+		)
+	})
+}
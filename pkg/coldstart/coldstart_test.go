@@ -0,0 +1,79 @@
+package coldstart
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestReady(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	r := New()
+	r.Stage("config", func() error { return nil })
+	if err := r.Stage("collector-dial", func() error { return errors.New("unreachable") }); err == nil {
+		t.Error("Stage() should return the error of the stage")
+	}
+	r.Ready(context.Background())
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want the cold start and its 2 stages", len(spans))
+	}
+	root := spans[2]
+	if root.Name != "cold-start" || !root.StartTime.Equal(ProcessStart()) {
+		t.Errorf("root span = %s starting at %s, want cold-start at process start", root.Name, root.StartTime)
+	}
+	for i, name := range []string{"init config", "init collector-dial"} {
+		if spans[i].Name != name || spans[i].Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("span %d = %s, want %s under cold-start", i, spans[i].Name, name)
+		}
+	}
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("failed stage status = %v, want error", spans[1].Status.Code)
+	}
+	if got := r.Stages(); len(got) != 2 || got[1].Err == nil {
+		t.Errorf("Stages() = %+v", got)
+	}
+	if got := r.Report(); got.ReadyMs <= 0 || len(got.Stages) != 2 || got.Stages[1].Error != "unreachable" {
+		t.Errorf("Report() = %+v", got)
+	}
+}
+
+func TestMiddlewareMarksFirstRequest(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	r := New()
+	h := r.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	for range 2 {
+		ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		span.End()
+	}
+
+	spans := exporter.GetSpans()
+	for i, want := range []bool{true, false} {
+		marked := false
+		for _, kv := range spans[i].Attributes {
+			if kv.Key == attribute.Key("coldstart.first_request") {
+				marked = kv.Value.AsBool()
+			}
+		}
+		if marked != want {
+			t.Errorf("request %d marked as first = %v, want %v", i+1, marked, want)
+		}
+	}
+}
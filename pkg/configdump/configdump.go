@@ -0,0 +1,71 @@
+// Package configdump reports the configuration a running service resolved,
+// with secrets masked, for the /admin/config endpoint.
+package configdump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+// Var describes an environment variable a service reads. Default is the value
+// the service uses when the variable is unset.
+type Var struct {
+	Name    string
+	Default string
+	Secret  bool
+}
+
+// Entry is the resolved value of a Var and where it came from: "env", "file"
+// (a .env file loaded at startup) or "default".
+type Entry struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Resolve looks up every var in the environment. fileValues holds the values
+// read from the .env file; since the file only fills variables that were not
+// already set, a value matching the file is attributed to it.
+func Resolve(vars []Var, fileValues map[string]string) map[string]Entry {
+	entries := make(map[string]Entry, len(vars))
+	for _, v := range vars {
+		value, ok := os.LookupEnv(v.Name)
+		entry := Entry{Value: value, Source: "env"}
+		switch {
+		case !ok || value == "":
+			entry = Entry{Value: v.Default, Source: "default"}
+		case fileValues[v.Name] == value:
+			entry.Source = "file"
+		}
+		if v.Secret {
+			entry.Value = Mask(entry.Value)
+		}
+		entries[v.Name] = entry
+	}
+	return entries
+}
+
+// Mask hides a secret behind a fingerprint of long values, the start of their
+// SHA-256, so operators can tell which key was loaded without the dump giving
+// away any of its characters.
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) < 12 {
+		return "****"
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "****" + hex.EncodeToString(sum[:4])
+}
+
+// Handler serves the resolved configuration as JSON, keyed by variable name.
+func Handler(vars []Var, fileValues map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(Resolve(vars, fileValues))
+	}
+}
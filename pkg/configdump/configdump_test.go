@@ -0,0 +1,65 @@
+package configdump
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("HTTP_TIMEOUT", "30s")
+	t.Setenv("APIKeyWeather", "2091343afd4c4900823232247250408")
+	t.Setenv("PORT", "8081")
+
+	vars := []Var{
+		{Name: "HTTP_TIMEOUT", Default: "60s"},
+		{Name: "ROUTE_TIMEOUTS", Default: "/temperature=4s"},
+		{Name: "APIKeyWeather", Secret: true},
+		{Name: "PORT", Default: "8081"},
+	}
+	entries := Resolve(vars, map[string]string{"PORT": "8081"})
+
+	want := map[string]Entry{
+		"HTTP_TIMEOUT":   {Value: "30s", Source: "env"},
+		"ROUTE_TIMEOUTS": {Value: "/temperature=4s", Source: "default"},
+		"APIKeyWeather":  {Value: "****a2a61335", Source: "env"},
+		"PORT":           {Value: "8081", Source: "file"},
+	}
+	for name, w := range want {
+		if got := entries[name]; got != w {
+			t.Errorf("Resolve()[%s] = %+v, want %+v", name, got, w)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"":                                "",
+		"short":                           "****",
+		"2091343afd4c4900823232247250408": "****a2a61335",
+	}
+	for value, want := range tests {
+		if got := Mask(value); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Setenv("RESPONSE_SIGNING_KEYS", "k1=supersecretvalue")
+
+	rec := httptest.NewRecorder()
+	Handler([]Var{{Name: "RESPONSE_SIGNING_KEYS", Secret: true}}, nil)(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var entries map[string]Entry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if got := entries["RESPONSE_SIGNING_KEYS"].Value; got != "****8f2a3597" {
+		t.Errorf("RESPONSE_SIGNING_KEYS = %q, want masked", got)
+	}
+}
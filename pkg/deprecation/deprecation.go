@@ -0,0 +1,250 @@
+// Package deprecation marks routes and response fields as deprecated from
+// configuration. Requests that use them are answered with Deprecation, Sunset
+// and Link headers and counted by client, so migrations such as v1 to v2 can
+// be announced to clients and tracked until the old behavior is removed.
+package deprecation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DateLayout = "2006-01-02"
+
+// ClientHeader names the caller in the usage counter; without it the product
+// of the User-Agent is used.
+const ClientHeader = "X-Client-Name"
+
+// OtherClient counts the callers whose name is not one of Config.Clients, so
+// callers cannot add metric series by sending new names.
+const OtherClient = "other"
+
+var deprecatedUsage, _ = otel.Meter("github.com/fhsmendes/open-telemetry/pkg/deprecation").Int64Counter(
+	"deprecation.usage",
+	metric.WithDescription("Requests that used a deprecated route or response field, by target and client"),
+)
+
+// Rule deprecates a route, "GET /history" or "/history" for every method, or
+// a response field, "field temp_C".
+type Rule struct {
+	Target string    `json:"target"`
+	Since  time.Time `json:"since"`
+	Sunset time.Time `json:"sunset,omitempty"`
+}
+
+func (r Rule) field() (string, bool) {
+	return strings.CutPrefix(r.Target, "field ")
+}
+
+type Config struct {
+	Rules []Rule
+	// Link documents the migration and is sent as a rel="deprecation" link.
+	Link string
+	// Clients are the client names, lowercase, the usage counter tells apart;
+	// every other caller is counted as OtherClient.
+	Clients []string
+}
+
+// LoadConfig reads DEPRECATIONS, ";"-separated target=since[/sunset] entries
+// with dates as YYYY-MM-DD (e.g.
+// "GET /history=2026-10-01/2027-01-31;field temp_C=2026-10-01"),
+// DEPRECATION_LINK and DEPRECATION_CLIENTS (comma-separated client names).
+func LoadConfig(getenv func(string) string) (Config, error) {
+	cfg := Config{Link: getenv("DEPRECATION_LINK")}
+	for _, client := range strings.Split(getenv("DEPRECATION_CLIENTS"), ",") {
+		if client = strings.ToLower(strings.TrimSpace(client)); client != "" {
+			cfg.Clients = append(cfg.Clients, client)
+		}
+	}
+	for _, entry := range strings.Split(getenv("DEPRECATIONS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		rule, err := parseRule(entry)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid DEPRECATIONS entry %q: %w", entry, err)
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	return cfg, nil
+}
+
+func parseRule(entry string) (Rule, error) {
+	target, dates, ok := strings.Cut(entry, "=")
+	target = strings.Join(strings.Fields(target), " ")
+	if !ok || target == "" {
+		return Rule{}, fmt.Errorf("expected target=since[/sunset]")
+	}
+	rule := Rule{Target: target}
+	if _, isField := rule.field(); !isField && !strings.Contains(target, "/") {
+		return Rule{}, fmt.Errorf("target must be a route (\"GET /path\" or \"/path\") or \"field <name>\"")
+	}
+
+	since, sunset, _ := strings.Cut(dates, "/")
+	var err error
+	if rule.Since, err = time.Parse(DateLayout, strings.TrimSpace(since)); err != nil {
+		return Rule{}, fmt.Errorf("since must be a date in the format YYYY-MM-DD")
+	}
+	if sunset = strings.TrimSpace(sunset); sunset != "" {
+		if rule.Sunset, err = time.Parse(DateLayout, sunset); err != nil {
+			return Rule{}, fmt.Errorf("sunset must be a date in the format YYYY-MM-DD")
+		}
+		if rule.Sunset.Before(rule.Since) {
+			return Rule{}, fmt.Errorf("sunset is before the deprecation date")
+		}
+	}
+	return rule, nil
+}
+
+// Policy applies the deprecation rules to requests; it is read-only after New
+// and safe for concurrent use.
+type Policy struct {
+	link    string
+	rules   []Rule
+	routes  map[string]Rule
+	fields  map[string]Rule
+	clients map[string]bool
+}
+
+func New(cfg Config) *Policy {
+	p := &Policy{
+		link:    cfg.Link,
+		rules:   cfg.Rules,
+		routes:  make(map[string]Rule),
+		fields:  make(map[string]Rule),
+		clients: make(map[string]bool),
+	}
+	for _, client := range cfg.Clients {
+		p.clients[client] = true
+	}
+	for _, rule := range cfg.Rules {
+		if field, ok := rule.field(); ok {
+			p.fields[field] = rule
+		} else {
+			p.routes[rule.Target] = rule
+		}
+	}
+	return p
+}
+
+func (p *Policy) Rules() []Rule {
+	return p.rules
+}
+
+type ctxKey struct{}
+
+type request struct {
+	policy *Policy
+	w      http.ResponseWriter
+	r      *http.Request
+}
+
+// Middleware answers requests to deprecated routes with the deprecation
+// headers and lets handlers report deprecated fields with UseField.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	if len(p.rules) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := serve.RoutePath(r.Context(), r.URL.Path)
+		if rule, ok := p.routes[r.Method+" "+path]; ok {
+			p.apply(w, r, rule)
+		} else if rule, ok := p.routes[path]; ok {
+			p.apply(w, r, rule)
+		}
+		if len(p.fields) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, request{policy: p, w: w, r: r}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UseField reports that the response being built includes field. When the
+// field is deprecated the response gets the deprecation headers, so it must be
+// called before the response header is written.
+func UseField(ctx context.Context, field string) {
+	req, ok := ctx.Value(ctxKey{}).(request)
+	if !ok {
+		return
+	}
+	if rule, ok := req.policy.fields[field]; ok {
+		req.policy.apply(req.w, req.r, rule)
+	}
+}
+
+func (p *Policy) apply(w http.ResponseWriter, r *http.Request, rule Rule) {
+	h := w.Header()
+	// The earliest deprecation and sunset win when a response uses several
+	// deprecated targets
+	if since, err := parseDeprecation(h.Get("Deprecation")); err != nil || rule.Since.Before(since) {
+		h.Set("Deprecation", "@"+strconv.FormatInt(rule.Since.Unix(), 10))
+	}
+	if !rule.Sunset.IsZero() {
+		if sunset, err := http.ParseTime(h.Get("Sunset")); err != nil || rule.Sunset.Before(sunset) {
+			h.Set("Sunset", rule.Sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+	if p.link != "" && h.Get("Link") == "" {
+		h.Set("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", p.link))
+	}
+
+	ctx := r.Context()
+	trace.SpanFromContext(ctx).AddEvent("deprecated", trace.WithAttributes(attribute.String("deprecation.target", rule.Target)))
+	deprecatedUsage.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("target", rule.Target),
+		attribute.String("client", p.client(r)),
+	))
+}
+
+func parseDeprecation(v string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(strings.TrimPrefix(v, "@"), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// ClientName identifies the caller of r: the X-Client-Name header, or the
+// product of the User-Agent ("curl/8.5.0" is "curl"). Callers choose it, so it
+// is not bounded.
+func ClientName(r *http.Request) string {
+	name := strings.TrimSpace(r.Header.Get(ClientHeader))
+	if name == "" {
+		name, _, _ = strings.Cut(r.UserAgent(), "/")
+		name, _, _ = strings.Cut(strings.TrimSpace(name), " ")
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return strings.ToLower(name)
+}
+
+// client is the name r is counted under: its ClientName when configured, and
+// OtherClient otherwise.
+func (p *Policy) client(r *http.Request) string {
+	if name := ClientName(r); name == "unknown" || p.clients[name] {
+		return name
+	}
+	return OtherClient
+}
+
+// CopyHeaders copies the deprecation headers of an upstream response to dst,
+// so a proxy passes on the deprecations of the service behind it.
+func CopyHeaders(dst, src http.Header) {
+	for _, name := range []string{"Deprecation", "Sunset", "Link"} {
+		if v := src.Get(name); v != "" {
+			dst.Set(name, v)
+		}
+	}
+}
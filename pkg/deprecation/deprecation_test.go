@@ -0,0 +1,138 @@
+package deprecation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		rules   int
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"route and field", "GET /history=2026-10-01/2027-01-31; field temp_C=2026-10-01", 2, false},
+		{"any method", "/temperature/trend=2026-10-01", 1, false},
+		{"missing dates", "GET /history", 0, true},
+		{"bad date", "GET /history=01/10/2026", 0, true},
+		{"sunset before since", "GET /history=2027-01-31/2026-10-01", 0, true},
+		{"not a route", "history=2026-10-01", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(func(key string) string {
+				if key == "DEPRECATIONS" {
+					return tt.value
+				}
+				return ""
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(cfg.Rules) != tt.rules {
+				t.Errorf("rules = %d, want %d", len(cfg.Rules), tt.rules)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg, err := LoadConfig(func(key string) string {
+		switch key {
+		case "DEPRECATIONS":
+			return "GET /history=2026-10-01/2027-01-31;/trend=2026-11-01;field temp_C=2026-09-01/2027-06-30"
+		case "DEPRECATION_LINK":
+			return "https://example.com/migration"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(cfg).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("legacy") == "true" {
+			UseField(r.Context(), "temp_C")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		deprecation string
+		sunset      string
+	}{
+		{"deprecated route", http.MethodGet, "/history", "@1790812800", "Sun, 31 Jan 2027 00:00:00 GMT"},
+		{"other method", http.MethodPost, "/history", "", ""},
+		{"any method", http.MethodPost, "/trend", "@1793491200", ""},
+		{"deprecated field", http.MethodGet, "/temperature?legacy=true", "@1788220800", "Wed, 30 Jun 2027 00:00:00 GMT"},
+		{"earliest wins", http.MethodGet, "/history?legacy=true", "@1788220800", "Sun, 31 Jan 2027 00:00:00 GMT"},
+		{"not deprecated", http.MethodGet, "/temperature", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if got := rec.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != tt.sunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.sunset)
+			}
+			wantLink := ""
+			if tt.deprecation != "" {
+				wantLink = `<https://example.com/migration>; rel="deprecation"`
+			}
+			if got := rec.Header().Get("Link"); got != wantLink {
+				t.Errorf("Link = %q, want %q", got, wantLink)
+			}
+		})
+	}
+}
+
+func TestClientName(t *testing.T) {
+	tests := []struct {
+		header, userAgent, want string
+	}{
+		{"Importer", "curl/8.5.0", "importer"},
+		{"", "curl/8.5.0", "curl"},
+		{"", "Mozilla/5.0 (X11; Linux x86_64)", "mozilla"},
+		{"", "", "unknown"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(ClientHeader, tt.header)
+		r.Header.Set("User-Agent", tt.userAgent)
+		if got := ClientName(r); got != tt.want {
+			t.Errorf("ClientName(%q, %q) = %q, want %q", tt.header, tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyClient(t *testing.T) {
+	p := New(Config{Clients: []string{"importer", "curl"}})
+	tests := []struct {
+		header, userAgent, want string
+	}{
+		{"Importer", "", "importer"},
+		{"", "curl/8.5.0", "curl"},
+		{"", "Mozilla/5.0 (X11; Linux x86_64)", OtherClient},
+		{"made-up-client-4711", "", OtherClient},
+		{"", "", "unknown"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(ClientHeader, tt.header)
+		r.Header.Set("User-Agent", tt.userAgent)
+		if got := p.client(r); got != tt.want {
+			t.Errorf("client(%q, %q) = %q, want %q", tt.header, tt.userAgent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package geo locates Brazilian cities. It resolves "city, UF" pairs to
+// coordinates through pluggable geocoding providers, trying them in order and
+// caching the results, with a span and a metric data point for every lookup,
+// and IBGE municipio codes to state, region and coordinates from an embedded
+// dataset.
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/fhsmendes/open-telemetry/pkg/geo"
+
+// ErrNotFound is returned when no provider knows the city.
+var ErrNotFound = errors.New("city not found")
+
+var lookups, _ = otel.Meter(scope).Int64Counter(
+	"geo.lookups",
+	metric.WithDescription("Number of geocoding lookups, by provider and result (found, not_found, error or cached)"),
+)
+
+type Coordinates struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+// Provider geocodes a city within a state; uf may be empty.
+type Provider interface {
+	Name() string
+	Geocode(ctx context.Context, city, uf string) (Coordinates, error)
+}
+
+// Resolver asks its providers in order until one finds the city, falling
+// through on ErrNotFound and on errors. Results, including ErrNotFound, are
+// cached for the resolver's TTL.
+type Resolver struct {
+	providers []Provider
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	clock   clock.Clock
+	entries map[string]entry
+}
+
+type entry struct {
+	coords    Coordinates
+	err       error
+	expiresAt time.Time
+}
+
+func NewResolver(ttl time.Duration, providers ...Provider) *Resolver {
+	return &Resolver{providers: providers, ttl: ttl, clock: clock.Real{}, entries: make(map[string]entry)}
+}
+
+// SetClock replaces the clock used for cache expirations.
+func (r *Resolver) SetClock(clk clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clk
+}
+
+// Resolve returns the coordinates of city in uf.
+func (r *Resolver) Resolve(ctx context.Context, city, uf string) (Coordinates, error) {
+	ctx, span := otel.Tracer(scope).Start(ctx, "geocode", trace.WithAttributes(
+		attribute.String("geo.city", city),
+		attribute.String("geo.uf", uf),
+	))
+	defer span.End()
+
+	key := strings.ToLower(strings.TrimSpace(city)) + "|" + strings.ToUpper(strings.TrimSpace(uf))
+	if e, ok := r.cached(key); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", "cache"), attribute.String("result", "cached")))
+		return e.coords, e.err
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	var errs []error
+	for _, p := range r.providers {
+		coords, err := p.Geocode(ctx, city, uf)
+		result := "found"
+		switch {
+		case errors.Is(err, ErrNotFound):
+			result = "not_found"
+		case err != nil:
+			result = "error"
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+		lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", p.Name()), attribute.String("result", result)))
+		span.AddEvent("geo.provider", trace.WithAttributes(attribute.String("provider", p.Name()), attribute.String("result", result)))
+
+		if err == nil {
+			span.SetAttributes(
+				attribute.String("geo.provider", p.Name()),
+				attribute.Float64("geo.lat", coords.Latitude),
+				attribute.Float64("geo.lon", coords.Longitude),
+			)
+			r.store(key, entry{coords: coords})
+			return coords, nil
+		}
+	}
+
+	// Provider failures are not cached, so the next lookup retries them
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "geocoding failed")
+		return Coordinates{}, err
+	}
+	r.store(key, entry{err: ErrNotFound})
+	return Coordinates{}, ErrNotFound
+}
+
+func (r *Resolver) cached(key string) (entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok || r.clock.Now().After(e.expiresAt) {
+		delete(r.entries, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+func (r *Resolver) store(key string, e entry) {
+	if r.ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	e.expiresAt = r.clock.Now().Add(r.ttl)
+	r.entries[key] = e
+	r.mu.Unlock()
+}
+
+// stateNames maps each UF to the state name geocoders report.
+var stateNames = map[string]string{
+	"AC": "Acre", "AL": "Alagoas", "AP": "Amapá", "AM": "Amazonas", "BA": "Bahia",
+	"CE": "Ceará", "DF": "Distrito Federal", "ES": "Espírito Santo", "GO": "Goiás",
+	"MA": "Maranhão", "MT": "Mato Grosso", "MS": "Mato Grosso do Sul", "MG": "Minas Gerais",
+	"PA": "Pará", "PB": "Paraíba", "PR": "Paraná", "PE": "Pernambuco", "PI": "Piauí",
+	"RJ": "Rio de Janeiro", "RN": "Rio Grande do Norte", "RS": "Rio Grande do Sul",
+	"RO": "Rondônia", "RR": "Roraima", "SC": "Santa Catarina", "SP": "São Paulo",
+	"SE": "Sergipe", "TO": "Tocantins",
+}
+
+// StateName returns the name of the state uf, or "" for an unknown UF.
+func StateName(uf string) string {
+	return stateNames[strings.ToUpper(uf)]
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+type fakeProvider struct {
+	name   string
+	coords Coordinates
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Geocode(context.Context, string, string) (Coordinates, error) {
+	p.calls++
+	return p.coords, p.err
+}
+
+func TestResolverFallsThroughAndCaches(t *testing.T) {
+	failing := &fakeProvider{name: "failing", err: errors.New("timeout")}
+	missing := &fakeProvider{name: "missing", err: ErrNotFound}
+	found := &fakeProvider{name: "found", coords: Coordinates{Latitude: -7.1, Longitude: -34.8}}
+	r := NewResolver(time.Hour, failing, missing, found)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r.SetClock(clk)
+
+	for i := 0; i < 2; i++ {
+		coords, err := r.Resolve(context.Background(), "João Pessoa", "PB")
+		if err != nil || coords != found.coords {
+			t.Fatalf("Resolve() = %v, %v, want %v", coords, err, found.coords)
+		}
+	}
+	if failing.calls != 1 || found.calls != 1 {
+		t.Errorf("providers called %d and %d times, want the second lookup cached", failing.calls, found.calls)
+	}
+
+	// Past the TTL the providers are asked again
+	clk.Advance(time.Hour + time.Second)
+	r.Resolve(context.Background(), "João Pessoa", "PB")
+	if found.calls != 2 {
+		t.Errorf("found called %d times after the TTL, want 2", found.calls)
+	}
+
+	if _, err := NewResolver(time.Hour, missing).Resolve(context.Background(), "Atlantis", "SP"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("countryCode") == "BR":
+			w.Write([]byte(`{"results":[
+				{"latitude":-9.4,"longitude":-40.5,"admin1":"Pernambuco"},
+				{"latitude":-9.5,"longitude":-40.6,"admin1":"Bahia"}]}`))
+		case r.URL.Query().Get("state") == "Bahia":
+			if r.Header.Get("User-Agent") != "test-agent" {
+				t.Errorf("User-Agent = %q, want test-agent", r.Header.Get("User-Agent"))
+			}
+			w.Write([]byte(`[{"lat":"-9.5","lon":"-40.6"}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		provider Provider
+		uf       string
+		want     Coordinates
+		wantErr  error
+	}{
+		{"open-meteo picks the state", OpenMeteo{URL: srv.URL}, "BA", Coordinates{-9.5, -40.6}, nil},
+		{"open-meteo without state", OpenMeteo{URL: srv.URL}, "", Coordinates{-9.4, -40.5}, nil},
+		{"open-meteo wrong state", OpenMeteo{URL: srv.URL}, "RS", Coordinates{}, ErrNotFound},
+		{"nominatim", Nominatim{URL: srv.URL, UserAgent: "test-agent"}, "BA", Coordinates{-9.5, -40.6}, nil},
+		{"nominatim not found", Nominatim{URL: srv.URL}, "RS", Coordinates{}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.Geocode(context.Background(), "Juazeiro", tt.uf)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Geocode() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
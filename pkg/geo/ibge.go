@@ -0,0 +1,129 @@
+package geo
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// municipios.csv follows the IBGE municipios layout
+// (codigo_ibge,nome,uf,latitude,longitude) and is written by
+// cmd/genmunicipios from the full municipios export. Regenerate it with
+// go generate; the generator refuses to write a partial export.
+//
+//go:generate go run ../cmd/genmunicipios -out municipios.csv
+//go:embed municipios.csv
+var municipiosCSV []byte
+
+// IBGE state codes are the first two digits of a municipio code.
+var ufByCode = map[string]string{
+	"11": "RO", "12": "AC", "13": "AM", "14": "RR", "15": "PA", "16": "AP", "17": "TO",
+	"21": "MA", "22": "PI", "23": "CE", "24": "RN", "25": "PB", "26": "PE", "27": "AL", "28": "SE", "29": "BA",
+	"31": "MG", "32": "ES", "33": "RJ", "35": "SP",
+	"41": "PR", "42": "SC", "43": "RS",
+	"50": "MS", "51": "MT", "52": "GO", "53": "DF",
+}
+
+// IBGE region codes are the first digit of a municipio code.
+var regionByCode = map[byte]string{
+	'1': "Norte",
+	'2': "Nordeste",
+	'3': "Sudeste",
+	'4': "Sul",
+	'5': "Centro-Oeste",
+}
+
+// Municipio is a Brazilian municipio identified by its IBGE code. Coordinates
+// are approximate and only known for the municipios in the embedded dataset.
+type Municipio struct {
+	IBGE   string
+	UF     string
+	Region string
+	Coordinates
+}
+
+// HasCoordinates reports whether m carries coordinates from the dataset.
+func (m Municipio) HasCoordinates() bool {
+	return m.Latitude != 0 || m.Longitude != 0
+}
+
+var (
+	loadOnce   sync.Once
+	municipios map[string]Municipio
+	loadErr    error
+)
+
+func load() {
+	municipios, loadErr = parseMunicipios(bytes.NewReader(municipiosCSV))
+}
+
+func parseMunicipios(r io.Reader) (map[string]Municipio, error) {
+	set := make(map[string]Municipio)
+
+	cr := csv.NewReader(r)
+	if _, err := cr.Read(); err != nil {
+		return set, fmt.Errorf("failed to read municipios header: %w", err)
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return set, nil
+		}
+		if err != nil {
+			return set, fmt.Errorf("failed to read municipios: %w", err)
+		}
+
+		lat, latErr := strconv.ParseFloat(record[3], 64)
+		lon, lonErr := strconv.ParseFloat(record[4], 64)
+		if latErr != nil || lonErr != nil {
+			return set, fmt.Errorf("invalid coordinates for municipio %s", record[0])
+		}
+
+		set[record[0]] = Municipio{
+			IBGE:        record[0],
+			UF:          record[2],
+			Region:      regionByCode[record[0][0]],
+			Coordinates: Coordinates{Latitude: lat, Longitude: lon},
+		}
+	}
+}
+
+// LookupMunicipio resolves an IBGE municipio code into its state, region and,
+// when the municipio is in the embedded dataset, approximate coordinates.
+// State and region are derived from the code itself, so they are available
+// for any valid code.
+func LookupMunicipio(ibge string) (Municipio, bool) {
+	loadOnce.Do(load)
+	return lookupMunicipio(municipios, ibge)
+}
+
+func lookupMunicipio(set map[string]Municipio, ibge string) (Municipio, bool) {
+	if len(ibge) != 7 {
+		return Municipio{}, false
+	}
+	if m, ok := set[ibge]; ok {
+		return m, true
+	}
+
+	uf, ok := ufByCode[ibge[:2]]
+	if !ok {
+		return Municipio{}, false
+	}
+	return Municipio{
+		IBGE:   ibge,
+		UF:     uf,
+		Region: regionByCode[ibge[0]],
+	}, true
+}
+
+// MunicipiosError reports whether the embedded municipios dataset failed to
+// load.
+func MunicipiosError() error {
+	loadOnce.Do(load)
+	return loadErr
+}
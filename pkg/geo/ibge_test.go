@@ -0,0 +1,71 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMunicipios(t *testing.T) {
+	if err := MunicipiosError(); err != nil {
+		t.Fatalf("embedded municipios dataset failed to load: %v", err)
+	}
+}
+
+func TestLookupMunicipio(t *testing.T) {
+	tests := []struct {
+		name       string
+		ibge       string
+		wantOK     bool
+		wantUF     string
+		wantRegion string
+		wantCoords bool
+	}{
+		{"capital in dataset", "3550308", true, "SP", "Sudeste", true},
+		{"capital in north", "1302603", true, "AM", "Norte", true},
+		{"municipio outside dataset", "3509502", true, "SP", "Sudeste", false},
+		{"federal district", "5300108", true, "DF", "Centro-Oeste", true},
+		{"unknown state code", "9999999", false, "", "", false},
+		{"too short", "355030", false, "", "", false},
+		{"empty", "", false, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := LookupMunicipio(tt.ibge)
+			if ok != tt.wantOK {
+				t.Fatalf("LookupMunicipio(%q) ok = %v, want %v", tt.ibge, ok, tt.wantOK)
+			}
+			if m.UF != tt.wantUF || m.Region != tt.wantRegion {
+				t.Errorf("LookupMunicipio(%q) = %s/%s, want %s/%s", tt.ibge, m.UF, m.Region, tt.wantUF, tt.wantRegion)
+			}
+			if m.HasCoordinates() != tt.wantCoords {
+				t.Errorf("LookupMunicipio(%q) has coordinates = %v, want %v", tt.ibge, m.HasCoordinates(), tt.wantCoords)
+			}
+		})
+	}
+}
+
+func TestLookupMunicipio_FullExport(t *testing.T) {
+	set, err := parseMunicipios(strings.NewReader(`codigo_ibge,nome,uf,latitude,longitude
+3509502,Campinas,SP,-22.9053,-47.0659
+3550308,São Paulo,SP,-23.5329,-46.6395
+`))
+	if err != nil {
+		t.Fatalf("parseMunicipios: %v", err)
+	}
+
+	m, ok := lookupMunicipio(set, "3509502")
+	if !ok || !m.HasCoordinates() {
+		t.Fatalf("lookupMunicipio(Campinas) = %+v, %v, want coordinates", m, ok)
+	}
+	if m.UF != "SP" || m.Region != "Sudeste" || m.Latitude != -22.9053 || m.Longitude != -47.0659 {
+		t.Errorf("lookupMunicipio(Campinas) = %+v", m)
+	}
+}
+
+func TestParseMunicipios_InvalidCoordinates(t *testing.T) {
+	_, err := parseMunicipios(strings.NewReader("codigo_ibge,nome,uf,latitude,longitude\n3509502,Campinas,SP,,-47.0659\n"))
+	if err == nil {
+		t.Error("parseMunicipios accepted a row without latitude")
+	}
+}
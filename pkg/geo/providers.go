@@ -0,0 +1,126 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultOpenMeteoURL = "https://geocoding-api.open-meteo.com/v1"
+	DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+)
+
+// OpenMeteo geocodes with the Open-Meteo geocoding API, picking the first
+// Brazilian result in the requested state.
+type OpenMeteo struct {
+	Client *http.Client
+	URL    string
+}
+
+func (OpenMeteo) Name() string { return "open-meteo" }
+
+func (p OpenMeteo) Geocode(ctx context.Context, city, uf string) (Coordinates, error) {
+	q := url.Values{
+		"name":        {city},
+		"count":       {"10"},
+		"language":    {"pt"},
+		"format":      {"json"},
+		"countryCode": {"BR"},
+	}
+	var body struct {
+		Results []struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			Admin1    string  `json:"admin1"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, p.Client, orDefault(p.URL, DefaultOpenMeteoURL)+"/search?"+q.Encode(), "", &body); err != nil {
+		return Coordinates{}, err
+	}
+
+	state := StateName(uf)
+	for _, r := range body.Results {
+		if state == "" || strings.EqualFold(r.Admin1, state) {
+			return Coordinates{Latitude: r.Latitude, Longitude: r.Longitude}, nil
+		}
+	}
+	return Coordinates{}, ErrNotFound
+}
+
+// Nominatim geocodes with the OpenStreetMap Nominatim API. Its usage policy
+// requires a UserAgent identifying the application.
+type Nominatim struct {
+	Client    *http.Client
+	URL       string
+	UserAgent string
+}
+
+func (Nominatim) Name() string { return "nominatim" }
+
+func (p Nominatim) Geocode(ctx context.Context, city, uf string) (Coordinates, error) {
+	q := url.Values{
+		"city":    {city},
+		"country": {"Brasil"},
+		"format":  {"jsonv2"},
+		"limit":   {"1"},
+	}
+	if state := StateName(uf); state != "" {
+		q.Set("state", state)
+	}
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := getJSON(ctx, p.Client, orDefault(p.URL, DefaultNominatimURL)+"/search?"+q.Encode(), p.UserAgent, &results); err != nil {
+		return Coordinates{}, err
+	}
+	if len(results) == 0 {
+		return Coordinates{}, ErrNotFound
+	}
+
+	lat, latErr := strconv.ParseFloat(results[0].Lat, 64)
+	lon, lonErr := strconv.ParseFloat(results[0].Lon, 64)
+	if latErr != nil || lonErr != nil {
+		return Coordinates{}, fmt.Errorf("invalid coordinates %q,%q", results[0].Lat, results[0].Lon)
+	}
+	return Coordinates{Latitude: lat, Longitude: lon}, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u, userAgent string, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func orDefault(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewAddsTraceContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New("test", &buf)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	logger.InfoContext(ctx, "lookup done", "cep", "01001000")
+	logger.Info("no span")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	for _, want := range []string{"cep=01001000", "trace_id=" + sc.TraceID().String(), "span_id=" + sc.SpanID().String()} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line %q does not contain %q", lines[0], want)
+		}
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("line %q has a trace_id without a span in ctx", lines[1])
+	}
+}
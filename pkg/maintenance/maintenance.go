@@ -0,0 +1,120 @@
+// Package maintenance implements a switch that makes a service answer its
+// regular routes with 503 and a structured payload during planned work, while
+// health, readiness and admin routes keep being served.
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMessage    = "service under maintenance"
+	DefaultRetryAfter = 5 * time.Minute
+)
+
+type State struct {
+	Enabled           bool   `json:"enabled"`
+	Message           string `json:"message"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+type response struct {
+	Message           string `json:"message"`
+	Code              string `json:"code"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+// LoadState reads MAINTENANCE_MODE ("true" to start in maintenance),
+// MAINTENANCE_MESSAGE and MAINTENANCE_RETRY_AFTER (e.g. "10m").
+func LoadState(getenv func(string) string) (State, error) {
+	state := State{
+		Enabled:           getenv("MAINTENANCE_MODE") == "true",
+		Message:           getenv("MAINTENANCE_MESSAGE"),
+		RetryAfterSeconds: int(DefaultRetryAfter.Seconds()),
+	}
+	if v := getenv("MAINTENANCE_RETRY_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return State{}, fmt.Errorf("invalid MAINTENANCE_RETRY_AFTER %q: expected a non-negative duration", v)
+		}
+		state.RetryAfterSeconds = int(d.Seconds())
+	}
+	return normalize(state), nil
+}
+
+func normalize(s State) State {
+	if s.Message == "" {
+		s.Message = DefaultMessage
+	}
+	return s
+}
+
+// Switch holds the current maintenance state; it is safe for concurrent use.
+type Switch struct {
+	mu    sync.RWMutex
+	state State
+}
+
+func NewSwitch(state State) *Switch {
+	return &Switch{state: normalize(state)}
+}
+
+func (s *Switch) State() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+func (s *Switch) Set(state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = normalize(state)
+}
+
+// Middleware answers every request with 503, a Retry-After header and a
+// "maintenance" payload while the switch is on. Apply it only to the routes
+// that should stop during maintenance.
+func (s *Switch) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.State()
+		if !state.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfterSeconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(response{
+			Message:           state.Message,
+			Code:              "maintenance",
+			RetryAfterSeconds: state.RetryAfterSeconds,
+		})
+	})
+}
+
+// Handler reports the state on GET and replaces it with the JSON body on POST.
+func (s *Switch) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var state State
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"message": "invalid maintenance state"})
+			return
+		}
+		if state.RetryAfterSeconds <= 0 {
+			state.RetryAfterSeconds = int(DefaultRetryAfter.Seconds())
+		}
+		s.Set(state)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.State())
+}
@@ -0,0 +1,81 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestMiddleware(t *testing.T) {
+	s := NewSwitch(State{})
+	h := s.Middleware(ok)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/temperature", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status while off = %d, want 200", rec.Code)
+	}
+
+	s.Set(State{Enabled: true, RetryAfterSeconds: 120})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/temperature", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while on = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "120" {
+		t.Errorf("Retry-After = %q, want 120", got)
+	}
+
+	var body response
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.Code != "maintenance" || body.Message != DefaultMessage || body.RetryAfterSeconds != 120 {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := NewSwitch(State{})
+
+	rec := httptest.NewRecorder()
+	s.Handler(rec, httptest.NewRequest(http.MethodPost, "/admin/maintenance",
+		strings.NewReader(`{"enabled":true,"message":"migrating weather provider"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	state := s.State()
+	if !state.Enabled || state.Message != "migrating weather provider" || state.RetryAfterSeconds != 300 {
+		t.Errorf("state after POST = %+v", state)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler(rec, httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status for an invalid body = %d, want 400", rec.Code)
+	}
+	if !s.State().Enabled {
+		t.Error("invalid body changed the state")
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	env := map[string]string{"MAINTENANCE_MODE": "true", "MAINTENANCE_RETRY_AFTER": "10m"}
+	state, err := LoadState(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Enabled || state.RetryAfterSeconds != 600 || state.Message != DefaultMessage {
+		t.Errorf("LoadState() = %+v", state)
+	}
+
+	env["MAINTENANCE_RETRY_AFTER"] = "soon"
+	if _, err := LoadState(func(name string) string { return env[name] }); err == nil {
+		t.Error("LoadState() should reject an invalid MAINTENANCE_RETRY_AFTER")
+	}
+}
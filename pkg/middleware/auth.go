@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HeaderAPIKey carries the caller's API key, admin keys included.
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeys maps API keys to the identity of their holder, e.g. the operator an
+// admin key was issued to.
+type APIKeys map[string]string
+
+// ParseAPIKeys parses comma separated key:identity pairs, e.g.
+// "k1:alice,k2:deploy-bot".
+func ParseAPIKeys(value string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, identity, ok := strings.Cut(pair, ":")
+		key, identity = strings.TrimSpace(key), strings.TrimSpace(identity)
+		if !ok || key == "" || identity == "" {
+			return nil, fmt.Errorf("invalid API key %q: expected key:identity", pair)
+		}
+		keys[key] = identity
+	}
+	return keys, nil
+}
+
+// Lookup returns the identity of key. Every key is compared in constant time,
+// so response times do not tell how much of a guess was right.
+func (k APIKeys) Lookup(key string) (string, bool) {
+	var identity string
+	found := false
+	for candidate, id := range k {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			identity, found = id, true
+		}
+	}
+	return identity, found
+}
+
+type identityKey struct{}
+
+// RequireAPIKey answers 401 to requests whose X-API-Key is not one of keys
+// and attaches the identity of the key to the others. Without keys every
+// request is rejected, so the routes it guards are closed unless configured.
+func RequireAPIKey(keys APIKeys) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderAPIKey)
+			identity, ok := keys.Lookup(key)
+			if key == "" || !ok {
+				w.Header().Set("WWW-Authenticate", HeaderAPIKey)
+				writeJSONError(w, http.StatusUnauthorized, "a valid "+HeaderAPIKey+" is required")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+		})
+	}
+}
+
+// Identity returns the identity RequireAPIKey authenticated the request as,
+// empty for requests it did not guard.
+func Identity(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
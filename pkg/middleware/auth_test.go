@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys(" k1:alice, k2:deploy-bot ,")
+	if err != nil || len(keys) != 2 || keys["k1"] != "alice" || keys["k2"] != "deploy-bot" {
+		t.Errorf("ParseAPIKeys() = %v, %v", keys, err)
+	}
+	for _, value := range []string{"k1", "k1:", ":alice"} {
+		if _, err := ParseAPIKeys(value); err == nil {
+			t.Errorf("ParseAPIKeys(%q) should fail", value)
+		}
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	drained := false
+	routes := func(keys APIKeys) http.Handler {
+		r := chi.NewRouter()
+		r.Group(func(r chi.Router) {
+			r.Use(RequireAPIKey(keys))
+			r.Post("/drain", func(w http.ResponseWriter, r *http.Request) {
+				drained = true
+				w.Write([]byte(Identity(r.Context())))
+			})
+		})
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		keys       APIKeys
+		key        string
+		wantStatus int
+	}{
+		{"no key", APIKeys{"k1": "alice"}, "", http.StatusUnauthorized},
+		{"unknown key", APIKeys{"k1": "alice"}, "k2", http.StatusUnauthorized},
+		{"no keys configured", nil, "", http.StatusUnauthorized},
+		{"admin key", APIKeys{"k1": "alice"}, "k1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drained = false
+			req := httptest.NewRequest(http.MethodPost, "/drain", nil)
+			if tt.key != "" {
+				req.Header.Set(HeaderAPIKey, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			routes(tt.keys).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST /drain = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if drained != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("drained = %v, want it only with an admin key", drained)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != "alice" {
+				t.Errorf("identity = %q, want alice", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package middleware holds the HTTP middlewares shared by service-input and
+// service-orchestration, so both services run the same chain in the same order.
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+type Middleware = func(http.Handler) http.Handler
+
+type Options struct {
+	// ContentType, when set, is sent on every response unless the handler
+	// overrides it.
+	ContentType string
+
+	// TrustedProxies lists the proxies whose X-Forwarded-For and X-Real-IP
+	// headers are believed. When empty no headers are, and the client is
+	// the connection's address.
+	TrustedProxies []netip.Prefix
+	// TrustAllProxies believes every request's headers instead, which is
+	// only safe behind a proxy that overwrites them, such as Cloud Run.
+	TrustAllProxies bool
+
+	// MaxBodyBytes and MaxJSONDepth bound request bodies; zero uses
+	// DefaultMaxBodyBytes and DefaultMaxJSONDepth.
+	MaxBodyBytes int64
+	MaxJSONDepth int
+
+	// ExtraBodyTypes lists media types accepted on request bodies besides
+	// application/json, for legacy clients.
+	ExtraBodyTypes []string
+
+	// IDs generates request IDs; nil reuses the trace ID of the server span,
+	// falling back to random trace-compatible IDs when tracing is off.
+	IDs telemetry.IDGenerator
+
+	// RateLimit is the requests per second allowed to each client, in bursts
+	// of up to RateBurst; zero disables the limit.
+	RateLimit float64
+	RateBurst int
+}
+
+// Stack returns the middlewares every service must install with r.Use, in the
+// order they must run: tracing first so the whole request runs in the server
+// span, request ID next so it can reuse the trace ID and everything after it
+// can log it, real IP before the logger so access logs show the client
+// address, recovery before everything that may panic, security headers so
+// even rejections carry them, then the rate limit, by the real IP, before the
+// body checks read anything. Authentication is per route: RequireAPIKey
+// guards the admin routes only.
+func Stack(opts Options) []Middleware {
+	realIP := RealIP(opts.TrustedProxies)
+	if opts.TrustAllProxies {
+		realIP = chimw.RealIP
+	}
+
+	maxBodyBytes, maxJSONDepth := opts.MaxBodyBytes, opts.MaxJSONDepth
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	if maxJSONDepth <= 0 {
+		maxJSONDepth = DefaultMaxJSONDepth
+	}
+
+	ids := opts.IDs
+	if ids == nil {
+		ids = telemetry.TraceIDs{}
+	}
+
+	stack := []Middleware{
+		Tracing,
+		RequestID(ids),
+		realIP,
+		chimw.Logger,
+		chimw.Recoverer,
+		SecurityHeaders,
+	}
+	if opts.RateLimit > 0 {
+		stack = append(stack, RateLimit(opts.RateLimit, max(opts.RateBurst, 1)))
+	}
+	stack = append(stack, RequireJSON(maxBodyBytes, maxJSONDepth, opts.ExtraBodyTypes...))
+	if opts.ContentType != "" {
+		stack = append(stack, chimw.SetHeader("Content-Type", opts.ContentType))
+	}
+	return stack
+}
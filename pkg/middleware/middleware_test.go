@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestStackOrder locks in the order documented on Stack through what each
+// middleware can see of the ones before it.
+func TestStackOrder(t *testing.T) {
+	stack := Stack(Options{
+		ContentType:    "application/json",
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		MaxBodyBytes:   16,
+		RateLimit:      1,
+		RateBurst:      1,
+	})
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+	})
+	for i := len(stack) - 1; i >= 0; i-- {
+		h = stack[i](h)
+	}
+
+	request := func(path, client, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		client     string
+		body       string
+		wantStatus int
+	}{
+		{"first request", "/", "192.0.2.1", "{}", http.StatusOK},
+		// The limit runs after RealIP, so clients behind the proxy are
+		// limited apart, and before the body checks, which never see it
+		{"limited before the body is read", "/", "192.0.2.1", strings.Repeat("x", 64), http.StatusTooManyRequests},
+		{"other client", "/", "192.0.2.2", strings.Repeat("x", 64), http.StatusRequestEntityTooLarge},
+		// Recovery runs inside the request ID and security headers
+		{"panic", "/panic", "192.0.2.3", "{}", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(tt.path, tt.client, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			// Every response, rejections included, went through the request
+			// ID and security headers
+			if rec.Header().Get("X-Request-Id") == "" {
+				t.Error("X-Request-Id missing")
+			}
+			if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Error("security headers missing")
+			}
+		})
+	}
+}
+
+func TestStackClientAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"no trusted proxies", Options{}, "203.0.113.9"},
+		{"untrusted proxy", Options{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, "203.0.113.9"},
+		{"trusted proxy", Options{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}}, "192.0.2.1"},
+		{"every proxy trusted", Options{TrustAllProxies: true}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _, _ = strings.Cut(r.RemoteAddr, ":")
+			})
+			stack := Stack(tt.opts)
+			for i := len(stack) - 1; i >= 0; i-- {
+				h = stack[i](h)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.9:1234"
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("client = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitClients bounds how many clients are tracked; past it the
+// clients whose bucket has refilled are forgotten.
+const maxRateLimitClients = 10000
+
+// ParseRateLimit parses RATE_LIMIT, the requests per second allowed to each
+// client, and RATE_LIMIT_BURST, how many may come at once. An empty rate
+// disables the limit; an empty burst allows one second of requests.
+func ParseRateLimit(rate, burst string) (float64, int, error) {
+	if rate == "" {
+		return 0, 0, nil
+	}
+	perSecond, err := strconv.ParseFloat(rate, 64)
+	if err != nil || perSecond <= 0 {
+		return 0, 0, fmt.Errorf("invalid RATE_LIMIT %q: expected a positive number of requests per second", rate)
+	}
+	if burst == "" {
+		return perSecond, int(math.Ceil(perSecond)), nil
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("invalid RATE_LIMIT_BURST %q: expected a positive integer", burst)
+	}
+	return perSecond, n, nil
+}
+
+// RateLimit answers 429 with a Retry-After to clients sending more than
+// perSecond requests per second, after a burst of burst requests. Clients
+// are told apart by r.RemoteAddr, so it must run after RealIP.
+func RateLimit(perSecond float64, burst int) Middleware {
+	return newRateLimiter(perSecond, burst, time.Now).middleware
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+	now       func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+}
+
+func newRateLimiter(perSecond float64, burst int, now func() time.Time) *rateLimiter {
+	return &rateLimiter{perSecond: perSecond, burst: float64(burst), now: now, clients: make(map[string]*bucket)}
+}
+
+// take spends a token of client, returning how long to wait when there is
+// none left.
+func (l *rateLimiter) take(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= maxRateLimitClients {
+			l.forgetIdle(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forgetIdle drops the clients whose bucket is full again, which behave as
+// new clients anyway.
+func (l *rateLimiter) forgetIdle(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := r.RemoteAddr
+		if addr, ok := remoteAddr(r.RemoteAddr); ok {
+			client = addr.String()
+		}
+		if ok, wait := l.take(client); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		rate, burst string
+		wantRate    float64
+		wantBurst   int
+		wantErr     bool
+	}{
+		{rate: "", burst: "5"},
+		{rate: "10", wantRate: 10, wantBurst: 10},
+		{rate: "0.5", wantRate: 0.5, wantBurst: 1},
+		{rate: "2", burst: "20", wantRate: 2, wantBurst: 20},
+		{rate: "0", wantErr: true},
+		{rate: "fast", wantErr: true},
+		{rate: "2", burst: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		rate, burst, err := ParseRateLimit(tt.rate, tt.burst)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimit(%q, %q) error = %v, want error %t", tt.rate, tt.burst, err, tt.wantErr)
+			continue
+		}
+		if rate != tt.wantRate || burst != tt.wantBurst {
+			t.Errorf("ParseRateLimit(%q, %q) = %v, %d, want %v, %d", tt.rate, tt.burst, rate, burst, tt.wantRate, tt.wantBurst)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(2, 3, func() time.Time { return now })
+	h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/temperature", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		advance     time.Duration
+		remote      string
+		wantStatus  int
+		wantRetryIn string
+	}{
+		{"burst 1", 0, "192.0.2.1:1000", http.StatusOK, ""},
+		{"burst 2", 0, "192.0.2.1:1001", http.StatusOK, ""},
+		{"burst 3", 0, "192.0.2.1:1002", http.StatusOK, ""},
+		{"over the burst", 0, "192.0.2.1:1003", http.StatusTooManyRequests, "1"},
+		{"other client", 0, "192.0.2.2:1000", http.StatusOK, ""},
+		{"refilled one token", 500 * time.Millisecond, "192.0.2.1:1004", http.StatusOK, ""},
+		{"spent again", 0, "192.0.2.1:1005", http.StatusTooManyRequests, "1"},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		rec := request(tt.remote)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tt.wantRetryIn {
+			t.Errorf("%s: Retry-After = %q, want %q", tt.name, got, tt.wantRetryIn)
+		}
+	}
+}
+
+func TestRateLimitForgetsIdleClients(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(1, 1, func() time.Time { return now })
+	for i := 0; i < maxRateLimitClients; i++ {
+		l.take(strconv.Itoa(i))
+	}
+	now = now.Add(time.Second)
+	if ok, _ := l.take("new"); !ok {
+		t.Fatal("new client limited")
+	}
+	if len(l.clients) != 1 {
+		t.Errorf("%d clients tracked, want the refilled ones forgotten", len(l.clients))
+	}
+}
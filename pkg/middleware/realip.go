@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustAllProxies, as the whole TRUSTED_PROXIES value, trusts the forwarding
+// headers of every request.
+const TrustAllProxies = "*"
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or single IPs,
+// or TrustAllProxies, reported as all.
+func ParseTrustedProxies(value string) (prefixes []netip.Prefix, all bool, err error) {
+	if strings.TrimSpace(value) == TrustAllProxies {
+		return nil, true, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, false, nil
+}
+
+// RealIP sets r.RemoteAddr to the client address, trusting X-Forwarded-For and
+// X-Real-IP only when the request comes from one of the trusted proxies.
+// X-Forwarded-For is read right to left, skipping trusted hops, so entries a
+// client prepends itself are ignored.
+func RealIP(trusted []netip.Prefix) Middleware {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remote, ok := remoteAddr(r.RemoteAddr)
+			if ok && isTrusted(remote) {
+				if client, ok := clientFromHeaders(r.Header, isTrusted); ok {
+					r.RemoteAddr = client.String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientFromHeaders(h http.Header, isTrusted func(netip.Addr) bool) (netip.Addr, bool) {
+	var hops []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Anything left of a malformed hop cannot be trusted either
+			return netip.Addr{}, false
+		}
+		if !isTrusted(addr) {
+			return addr, true
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP"))); err == nil {
+		return addr, true
+	}
+	return netip.Addr{}, false
+}
+
+func remoteAddr(hostport string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr, err == nil
+}
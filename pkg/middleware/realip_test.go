@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, all, err := ParseTrustedProxies(" 10.0.0.0/8, 192.168.1.10 ,::1")
+	if err != nil || all {
+		t.Fatalf("ParseTrustedProxies() = %v, %v", all, err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.10/32", "::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("ParseTrustedProxies() = %v, want %v", prefixes, want)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+
+	if prefixes, all, err := ParseTrustedProxies(" * "); err != nil || !all || prefixes != nil {
+		t.Errorf("ParseTrustedProxies(*) = %v, %v, %v, want every proxy trusted", prefixes, all, err)
+	}
+	if _, all, _ := ParseTrustedProxies(""); all {
+		t.Error("ParseTrustedProxies(\"\") trusts every proxy")
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "not-an-ip", "*, 10.0.0.0/8"} {
+		if _, _, err := ParseTrustedProxies(invalid); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, _, _ := ParseTrustedProxies("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{"direct client spoofing header", "203.0.113.7:5000", "1.2.3.4", "", "203.0.113.7:5000"},
+		{"through trusted proxy", "10.0.0.2:5000", "198.51.100.20", "", "198.51.100.20"},
+		{"spoofed hop before real client", "10.0.0.2:5000", "1.2.3.4, 198.51.100.20", "", "198.51.100.20"},
+		{"chain of trusted proxies", "10.0.0.2:5000", "198.51.100.20, 10.0.0.9", "", "198.51.100.20"},
+		{"x-real-ip from trusted proxy", "10.0.0.2:5000", "", "198.51.100.20", "198.51.100.20"},
+		{"malformed hop", "10.0.0.2:5000", "garbage", "", "10.0.0.2:5000"},
+		{"trusted proxy without headers", "10.0.0.2:5000", "", "", "10.0.0.2:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
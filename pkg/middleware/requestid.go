@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// validRequestID bounds the incoming X-Request-Id values that are recorded,
+// so clients cannot inject arbitrary text into spans.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID assigns every request an ID from gen. The ID is stored where
+// chi's GetReqID and Logger find it, sent back in X-Request-Id and set as the
+// request.id attribute of the current span. A client's own X-Request-Id does
+// not replace it, since that would break the link between the ID and the
+// trace; a well-formed one is kept as the request.client_id attribute so
+// the request can still be found by it. It runs after Tracing so a
+// telemetry.TraceIDs generator sees the server span.
+func RequestID(gen telemetry.IDGenerator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			id := gen.NewRequestID(ctx)
+
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(attribute.String("request.id", id))
+			if clientID := r.Header.Get(chimw.RequestIDHeader); clientID != id && validRequestID.MatchString(clientID) {
+				span.SetAttributes(attribute.String("request.client_id", clientID))
+			}
+			w.Header().Set(chimw.RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, chimw.RequestIDKey, id)))
+		})
+	}
+}
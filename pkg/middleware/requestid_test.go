@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fixedIDs string
+
+func (f fixedIDs) NewRequestID(context.Context) string { return string(f) }
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantClientID string
+	}{
+		{"generates when missing", "", ""},
+		{"keeps client ID aside", "client-42", "client-42"},
+		{"ignores malformed ID", "bad id\r\nX-Injected: 1", ""},
+		{"same as generated", "generated", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			defer tp.Shutdown(context.Background())
+
+			var seen string
+			h := RequestID(fixedIDs("generated"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = chimw.GetReqID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(chimw.RequestIDHeader, tt.header)
+			}
+			ctx, span := tp.Tracer("").Start(req.Context(), "request")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req.WithContext(ctx))
+			span.End()
+
+			if seen != "generated" {
+				t.Errorf("GetReqID() = %q, want the generated ID", seen)
+			}
+			if got := rec.Header().Get(chimw.RequestIDHeader); got != "generated" {
+				t.Errorf("X-Request-Id = %q, want the generated ID", got)
+			}
+			attrs := map[string]string{}
+			for _, kv := range exporter.GetSpans()[0].Attributes {
+				attrs[string(kv.Key)] = kv.Value.AsString()
+			}
+			if attrs["request.id"] != "generated" || attrs["request.client_id"] != tt.wantClientID {
+				t.Errorf("span attributes = %v, want request.id generated and request.client_id %q", attrs, tt.wantClientID)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// responseWriter records what a handler sends and how long sending took:
+// writes block while the client is slow to read, so their duration tells a
+// slow client or a large payload apart from time spent before responding.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int64
+	// firstByte is when the header was sent and lastByte when the last write
+	// or flush returned; writing is the time spent inside them.
+	firstByte time.Time
+	lastByte  time.Time
+	writing   time.Duration
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses are not the final status
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+		w.firstByte = time.Now()
+	}
+	start := time.Now()
+	w.ResponseWriter.WriteHeader(code)
+	w.wrote(start)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.sendHeader()
+	start := time.Now()
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	w.wrote(start)
+	return n, err
+}
+
+// FlushError is found by http.ResponseController before it unwraps the
+// writer, so flushes of streamed responses are timed too.
+func (w *responseWriter) FlushError() error {
+	w.sendHeader()
+	start := time.Now()
+	err := http.NewResponseController(w.ResponseWriter).Flush()
+	w.wrote(start)
+	return err
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController for the
+// features not timed here, such as hijacking and deadlines.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// sendHeader records the implicit 200 sent by a write without WriteHeader.
+func (w *responseWriter) sendHeader() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.firstByte = time.Now()
+	}
+}
+
+func (w *responseWriter) wrote(start time.Time) {
+	w.lastByte = time.Now()
+	w.writing += w.lastByte.Sub(start)
+}
+
+// Status is the status sent, or 200 when the handler sent nothing.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	h := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS sent over plain HTTP: %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	req.TLS = &tls.ConnectionState{}
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got == "" {
+		t.Error("HSTS missing over TLS")
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		also        []string
+		wantStatus  int
+	}{
+		{"json body", http.MethodPost, "application/json", `{"cep":"01001000"}`, nil, http.StatusOK},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", `{"cep":"01001000"}`, nil, http.StatusOK},
+		{"no body", http.MethodPost, "", "", nil, http.StatusOK},
+		{"get ignores content type", http.MethodGet, "text/plain", "01001000", nil, http.StatusOK},
+		{"form body", http.MethodPost, "application/x-www-form-urlencoded", "cep=01001000", nil, http.StatusUnsupportedMediaType},
+		{"form body allowed", http.MethodPost, "application/x-www-form-urlencoded", "cep=01001000", []string{"application/x-www-form-urlencoded"}, http.StatusOK},
+		{"missing content type", http.MethodPost, "", `{"cep":"01001000"}`, nil, http.StatusUnsupportedMediaType},
+		{"too large", http.MethodPost, "application/json", `{"cep":"` + strings.Repeat("0", 100) + `"}`, nil, http.StatusRequestEntityTooLarge},
+		{"too deep", http.MethodPost, "application/json", `{"a":[[[{"b":1}]]]}`, nil, http.StatusBadRequest},
+		{"invalid json is left to the handler", http.MethodPost, "application/json", `{"cep":`, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBody string
+			h := RequireJSON(64, 4, tt.also...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/temperature", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && gotBody != tt.body {
+				t.Errorf("handler body = %q, want %q", gotBody, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("error getting temperature"))
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	rec := httptest.NewRecorder()
+	Timeout(10*time.Millisecond)(slow).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("slow handler status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	if body := rec.Body.String(); body != "{\"message\":\"request timeout\"}\n" {
+		t.Errorf("slow handler body = %q, want the timeout error JSON", body)
+	}
+
+	rec = httptest.NewRecorder()
+	Timeout(time.Second)(fast).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("fast handler = %d %q, want 200 ok", rec.Code, rec.Body.String())
+	}
+}
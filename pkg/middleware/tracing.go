@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/fhsmendes/open-telemetry/pkg/middleware"
+
+var (
+	meter = otel.Meter(scope)
+
+	requestDuration, _ = meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP server requests, by route pattern, method and status code"),
+	)
+	responseBodySize, _ = meter.Int64Histogram(
+		"http.server.response.body.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP server response bodies, by route pattern, method and status code"),
+	)
+	responseWriteDuration, _ = meter.Float64Histogram(
+		"http.server.response.write.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time spent writing HTTP server responses to the client, by route pattern, method and status code"),
+	)
+)
+
+// Tracing extracts the propagated trace context and baggage from the request
+// headers and runs the request in a server span. The span name and the
+// http.route label of the request duration metric use the chi route pattern
+// (e.g. "GET /admin/requests/{trace_id}") instead of the raw path, so IDs and
+// CEPs in URLs never multiply span names or metric series. Requests that
+// match no route are labeled by method only, or by the wildcard pattern of
+// the router they were mounted under.
+//
+// Sending the response gets its own send-response span, from the header to
+// the last write, with the body size and the time spent blocked in writes;
+// the server span records when the first byte was sent. Together they show
+// slow clients and large payloads apart from the time spent building the
+// response.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(scope).Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		ww := &responseWriter{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		attrs := []attribute.KeyValue{
+			attribute.String("http.request.method", r.Method),
+			attribute.Int("http.response.status_code", status),
+		}
+		if route := RoutePattern(r); route != "" {
+			span.SetName(r.Method + " " + route)
+			attrs = append(attrs, attribute.String("http.route", route))
+		}
+		span.SetAttributes(attrs...)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+		recordResponse(ctx, span, ww, start, attrs)
+	})
+}
+
+func recordResponse(ctx context.Context, span trace.Span, ww *responseWriter, start time.Time, attrs []attribute.KeyValue) {
+	span.SetAttributes(attribute.Int64("http.response.body.size", ww.bytes))
+	responseBodySize.Record(ctx, ww.bytes, metric.WithAttributes(attrs...))
+	if ww.firstByte.IsZero() {
+		// Nothing was sent; net/http sends an empty 200 after the handler
+		return
+	}
+
+	span.SetAttributes(attribute.Float64("http.response.first_byte_ms", milliseconds(ww.firstByte.Sub(start))))
+	_, send := otel.Tracer(scope).Start(ctx, "send-response", trace.WithTimestamp(ww.firstByte))
+	send.SetAttributes(
+		attribute.Int64("http.response.body.size", ww.bytes),
+		attribute.Float64("http.response.write.duration_ms", milliseconds(ww.writing)),
+	)
+	send.End(trace.WithTimestamp(ww.lastByte))
+	responseWriteDuration.Record(ctx, ww.writing.Seconds(), metric.WithAttributes(attrs...))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// RoutePattern returns the chi route pattern r matched, including the
+// patterns of the routers it is mounted under, or "" when it matched none.
+// It is only complete once routing is done, i.e. after the handler returns.
+func RoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
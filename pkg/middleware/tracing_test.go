@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingNamesSpansByRoutePattern(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	r.Get("/admin/requests/{trace_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	root := chi.NewRouter()
+	root.Mount("/api/v1", r)
+	for _, path := range []string{"/api/v1/admin/requests/4bf92f3577b34da6", "/api/v1/unknown/123"} {
+		root.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var spans tracetest.SpanStubs
+	for _, s := range exporter.GetSpans() {
+		if s.SpanKind == trace.SpanKindServer {
+			spans = append(spans, s)
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d server spans, want 2", len(spans))
+	}
+
+	tests := []struct {
+		name   string
+		route  string
+		status int64
+	}{
+		{"GET /api/v1/admin/requests/{trace_id}", "/api/v1/admin/requests/{trace_id}", 404},
+		{"GET /api/v1/*", "/api/v1/*", 404},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name != tt.name {
+			t.Errorf("span %d name = %q, want %q", i, span.Name, tt.name)
+		}
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		if got := attrs["http.route"].AsString(); got != tt.route {
+			t.Errorf("span %d http.route = %q, want %q", i, got, tt.route)
+		}
+		if got := attrs["http.response.status_code"].AsInt64(); got != tt.status {
+			t.Errorf("span %d status code = %d, want %d", i, got, tt.status)
+		}
+	}
+}
+
+func TestTracingRecordsResponseWriting(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	h := Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello, "))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() through the wrapper: %v", err)
+		}
+		w.Write([]byte("world"))
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !rec.Flushed || rec.Body.String() != "hello, world" {
+		t.Fatalf("response = %q (flushed %v), want the whole body flushed", rec.Body, rec.Flushed)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	send, ok := spans["send-response"]
+	if !ok {
+		t.Fatalf("no send-response span in %v", spans)
+	}
+	server := spans["GET"]
+	if send.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("send-response is not a child of the server span")
+	}
+	if send.StartTime.Before(server.StartTime) || send.EndTime.After(server.EndTime) {
+		t.Error("send-response is not within the server span")
+	}
+
+	for _, s := range []tracetest.SpanStub{server, send} {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range s.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		if got := attrs["http.response.body.size"].AsInt64(); got != 12 {
+			t.Errorf("%s body size = %d, want 12", s.Name, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package redact masks credentials carried in URL query strings, so upstream
+// API keys never reach spans, logs or error responses.
+package redact
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces the value of every secret parameter.
+const Mask = "REDACTED"
+
+// SecretParams are the query parameters treated as credentials.
+var SecretParams = []string{"key", "api_key", "apikey", "token", "access_token"}
+
+var secretInText = regexp.MustCompile(`(?i)([?&](?:` + strings.Join(SecretParams, "|") + `)=)[^&\s"'#]*`)
+
+// String masks secret query parameters in any text that embeds URLs, such as
+// the message of a *url.Error.
+func String(s string) string {
+	return secretInText.ReplaceAllString(s, "${1}"+Mask)
+}
+
+// URL returns u as a string with the secret query parameters masked.
+func URL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	for p := range q {
+		if isSecret(p) {
+			q.Set(p, Mask)
+		}
+	}
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+func isSecret(param string) bool {
+	for _, p := range SecretParams {
+		if strings.EqualFold(param, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Error returns err with its message masked; errors.Is and errors.As still see
+// the original chain. *url.Error is masked in place so callers that inspect
+// its URL field get the masked one too.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = String(urlErr.URL)
+	}
+	msg := err.Error()
+	if masked := String(msg); masked != msg {
+		return &redactedError{msg: masked, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,60 @@
+package redact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no secret", "https://viacep.com.br/ws/01001000/json/", "https://viacep.com.br/ws/01001000/json/"},
+		{"first param", "https://api.weatherapi.com/v1/current.json?key=abc123&q=Recife", "https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Recife"},
+		{"later param", "GET /v1?q=Recife&api_key=abc123", "GET /v1?q=Recife&api_key=REDACTED"},
+		{"case insensitive", "/v1?Token=abc", "/v1?Token=REDACTED"},
+		{"inside error", `Get "https://x/v1?key=abc&q=a": context deadline exceeded`, `Get "https://x/v1?key=REDACTED&q=a": context deadline exceeded`},
+		{"not a param", "monkey=banana", "monkey=banana"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	u, _ := url.Parse("https://api.weatherapi.com/v1/current.json?key=abc123&q=Recife")
+	if got, want := URL(u), "https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Recife"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+	if u.Query().Get("key") != "abc123" {
+		t.Error("URL() modified its argument")
+	}
+}
+
+func TestError(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://x/v1?key=abc123&q=a", Err: context.DeadlineExceeded}
+	err := Error(fmt.Errorf("weatherapi: %w", urlErr))
+
+	if got, want := err.Error(), `weatherapi: Get "https://x/v1?key=REDACTED&q=a": context deadline exceeded`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("masked error should still match its cause")
+	}
+	if urlErr.URL != "https://x/v1?key=REDACTED&q=a" {
+		t.Errorf("url.Error URL = %q, want it masked", urlErr.URL)
+	}
+	if Error(nil) != nil {
+		t.Error("Error(nil) should be nil")
+	}
+}
@@ -0,0 +1,60 @@
+// Package selfcheck runs the startup checks behind the --check flag of both
+// services and prints a one-line summary per check.
+package selfcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Check is a named startup check. A Check without Run is reported as skipped,
+// with Skip as the reason.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+	Skip string
+}
+
+// Run executes checks in order, each bounded by timeout, writes their results
+// to w and reports whether all of them passed.
+func Run(ctx context.Context, w io.Writer, timeout time.Duration, checks []Check) bool {
+	ok := true
+	for _, c := range checks {
+		if c.Run == nil {
+			fmt.Fprintf(w, "[skip] %s: %s\n", c.Name, c.Skip)
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := c.Run(checkCtx)
+		cancel()
+
+		if err != nil {
+			ok = false
+			fmt.Fprintf(w, "[fail] %s: %v\n", c.Name, err)
+			continue
+		}
+		fmt.Fprintf(w, "[ok]   %s (%s)\n", c.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return ok
+}
+
+// RequireEnv returns a check that fails when any of the variables is unset,
+// without printing their values.
+func RequireEnv(getenv func(string) string, names ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var missing []string
+		for _, name := range names {
+			if getenv(name) == "" {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing %v", missing)
+		}
+		return nil
+	}
+}
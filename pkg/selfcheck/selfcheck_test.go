@@ -0,0 +1,54 @@
+package selfcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	ok := Run(context.Background(), &out, time.Second, []Check{
+		{Name: "config", Run: func(ctx context.Context) error { return nil }},
+		{Name: "collector", Run: func(ctx context.Context) error { return errors.New("connection refused") }},
+		{Name: "providers", Skip: "run with --probe to enable"},
+	})
+
+	if ok {
+		t.Error("Run() = true, want false when a check fails")
+	}
+	for _, want := range []string{"[ok]   config", "[fail] collector: connection refused", "[skip] providers: run with --probe to enable"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunAppliesTimeout(t *testing.T) {
+	var out bytes.Buffer
+	ok := Run(context.Background(), &out, 10*time.Millisecond, []Check{
+		{Name: "slow", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	})
+	if ok {
+		t.Error("Run() = true, want false when a check times out")
+	}
+}
+
+func TestRequireEnv(t *testing.T) {
+	env := map[string]string{"SERVICE_B_URL": "http://service-orchestration:8081"}
+	getenv := func(name string) string { return env[name] }
+
+	if err := RequireEnv(getenv, "SERVICE_B_URL")(context.Background()); err != nil {
+		t.Errorf("RequireEnv(SERVICE_B_URL) error = %v", err)
+	}
+	err := RequireEnv(getenv, "SERVICE_B_URL", "APIKeyWeather")(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "APIKeyWeather") {
+		t.Errorf("RequireEnv(APIKeyWeather) error = %v, want missing APIKeyWeather", err)
+	}
+}
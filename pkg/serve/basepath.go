@@ -0,0 +1,47 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ParseBasePath validates the BASE_PATH a service is mounted under behind a
+// gateway (e.g. "/api/weather/v1"). Empty and "/" mean the root.
+func ParseBasePath(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "/" {
+		return "", nil
+	}
+	if !strings.HasPrefix(value, "/") {
+		return "", fmt.Errorf("invalid BASE_PATH %q: must start with /", value)
+	}
+	if strings.ContainsAny(value, "{}*?#") {
+		return "", fmt.Errorf("invalid BASE_PATH %q: must be a literal path", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// WithBasePath serves h under basePath, which the handlers behind it do not
+// see: chi routes match the path relative to the mount point.
+func WithBasePath(basePath string, h http.Handler) http.Handler {
+	if basePath == "" {
+		return h
+	}
+	r := chi.NewRouter()
+	r.Mount(basePath, h)
+	return r
+}
+
+// RoutePath returns the path of the request of ctx relative to the router's
+// mount point, so routes are named the same way with or without BASE_PATH.
+// path, usually the request's URL path, is returned outside a router.
+func RoutePath(ctx context.Context, path string) string {
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return path
+}
@@ -0,0 +1,151 @@
+// Package serve runs a service's HTTP server, optionally terminating TLS itself
+// for deployments that do not sit behind a TLS-terminating platform such as
+// Cloud Run.
+package serve
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const defaultAutocertCacheDir = "/var/cache/autocert"
+
+// TLSConfig selects how certificates are obtained: from CertFile/KeyFile, or
+// from Let's Encrypt for AutocertDomains. With neither set the server speaks
+// plain HTTP.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	AutocertDomains  []string
+	AutocertCacheDir string
+
+	// RedirectAddr, when set, is the address of a plain HTTP listener that
+	// redirects to HTTPS (and answers ACME challenges when using autocert).
+	RedirectAddr string
+	// PublicPort is the HTTPS port clients reach, used in redirects. It
+	// defaults to the server's own port, which is wrong behind port
+	// forwarding or a container port mapping.
+	PublicPort string
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// LoadTLSConfig reads TLS_CERT_FILE, TLS_KEY_FILE, TLS_AUTOCERT_DOMAINS
+// (comma-separated), TLS_AUTOCERT_CACHE_DIR, TLS_REDIRECT_ADDR and
+// TLS_PUBLIC_PORT.
+func LoadTLSConfig(getenv func(string) string) (TLSConfig, error) {
+	cfg := TLSConfig{
+		CertFile:         getenv("TLS_CERT_FILE"),
+		KeyFile:          getenv("TLS_KEY_FILE"),
+		AutocertCacheDir: getenv("TLS_AUTOCERT_CACHE_DIR"),
+		RedirectAddr:     getenv("TLS_REDIRECT_ADDR"),
+		PublicPort:       getenv("TLS_PUBLIC_PORT"),
+	}
+	for _, domain := range strings.Split(getenv("TLS_AUTOCERT_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.AutocertDomains = append(cfg.AutocertDomains, domain)
+		}
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return TLSConfig{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.CertFile != "" && len(cfg.AutocertDomains) > 0 {
+		return TLSConfig{}, errors.New("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	}
+	if cfg.RedirectAddr != "" && !cfg.Enabled() {
+		return TLSConfig{}, errors.New("TLS_REDIRECT_ADDR requires TLS to be configured")
+	}
+	if cfg.PublicPort != "" {
+		if n, err := strconv.Atoi(cfg.PublicPort); err != nil || n < 1 || n > 65535 {
+			return TLSConfig{}, fmt.Errorf("invalid TLS_PUBLIC_PORT %q: expected a port number", cfg.PublicPort)
+		}
+		if cfg.RedirectAddr == "" {
+			return TLSConfig{}, errors.New("TLS_PUBLIC_PORT requires TLS_REDIRECT_ADDR")
+		}
+	}
+	if len(cfg.AutocertDomains) > 0 && cfg.AutocertCacheDir == "" {
+		cfg.AutocertCacheDir = defaultAutocertCacheDir
+	}
+	return cfg, nil
+}
+
+// ListenAndServe serves server over TLS when cfg is enabled and over plain
+// HTTP otherwise. The redirect listener, if any, is closed when server shuts
+// down.
+func ListenAndServe(server *http.Server, cfg TLSConfig) error {
+	if !cfg.Enabled() {
+		return server.ListenAndServe()
+	}
+
+	if server.Protocols != nil {
+		server.Protocols.SetHTTP2(true)
+	}
+
+	redirect := redirectToHTTPS(httpsPort(server.Addr, cfg.PublicPort))
+	if len(cfg.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		}
+		server.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect)
+	} else {
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.RedirectAddr != "" {
+		redirectServer := &http.Server{Addr: cfg.RedirectAddr, Handler: redirect}
+		server.RegisterOnShutdown(func() { redirectServer.Close() })
+		go func() {
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("HTTPS redirect listener failed", "addr", cfg.RedirectAddr, "error", err)
+			}
+		}()
+	}
+
+	return server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+}
+
+// httpsPort is the port redirects point at: publicPort when set, otherwise
+// the port of tlsAddr, the address the HTTPS server listens on, defaulting
+// to 443.
+func httpsPort(tlsAddr, publicPort string) string {
+	if publicPort != "" {
+		return publicPort
+	}
+	if _, p, err := net.SplitHostPort(tlsAddr); err == nil && p != "" && p != "https" {
+		return p
+	}
+	return "443"
+}
+
+// redirectToHTTPS redirects to the same host and path on port. The port is
+// left out of the target when it is the default 443.
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
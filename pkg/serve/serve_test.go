@@ -0,0 +1,152 @@
+package serve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLoadTLSConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{"disabled", map[string]string{}, false, false},
+		{"files", map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem"}, true, false},
+		{"autocert", map[string]string{"TLS_AUTOCERT_DOMAINS": "api.example.com, www.example.com"}, true, false},
+		{"cert without key", map[string]string{"TLS_CERT_FILE": "cert.pem"}, false, true},
+		{"files and autocert", map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_AUTOCERT_DOMAINS": "api.example.com"}, false, true},
+		{"redirect without tls", map[string]string{"TLS_REDIRECT_ADDR": ":80"}, false, true},
+		{"public port", map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_REDIRECT_ADDR": ":8080", "TLS_PUBLIC_PORT": "443"}, true, false},
+		{"public port without redirect", map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_PUBLIC_PORT": "443"}, false, true},
+		{"invalid public port", map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_REDIRECT_ADDR": ":8080", "TLS_PUBLIC_PORT": "https"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadTLSConfig(func(name string) string { return tt.env[name] })
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadTLSConfig() expected error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTLSConfig() unexpected error: %v", err)
+			}
+			if cfg.Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", cfg.Enabled(), tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestLoadTLSConfigAutocertDefaults(t *testing.T) {
+	cfg, err := LoadTLSConfig(func(name string) string {
+		return map[string]string{"TLS_AUTOCERT_DOMAINS": "api.example.com, ,www.example.com"}[name]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.AutocertDomains) != 2 || cfg.AutocertDomains[1] != "www.example.com" {
+		t.Errorf("AutocertDomains = %v", cfg.AutocertDomains)
+	}
+	if cfg.AutocertCacheDir != defaultAutocertCacheDir {
+		t.Errorf("AutocertCacheDir = %q, want %q", cfg.AutocertCacheDir, defaultAutocertCacheDir)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		tlsAddr    string
+		publicPort string
+		url        string
+		want       string
+	}{
+		{"default port", ":443", "", "http://api.example.com:80/temperature?cep=01001000", "https://api.example.com/temperature?cep=01001000"},
+		{"unset address", "", "", "http://api.example.com/temperature", "https://api.example.com/temperature"},
+		{"custom port", ":8443", "", "http://api.example.com:8080/temperature?cep=01001000", "https://api.example.com:8443/temperature?cep=01001000"},
+		{"host and port", "0.0.0.0:8443", "", "http://api.example.com/", "https://api.example.com:8443/"},
+		{"ipv6 host", ":443", "", "http://[::1]:8080/", "https://[::1]/"},
+		{"ipv6 host and custom port", ":8443", "", "http://[::1]:8080/", "https://[::1]:8443/"},
+		{"public port behind a mapping", ":8443", "443", "http://api.example.com/temperature", "https://api.example.com/temperature"},
+		{"public port differs", ":8443", "9443", "http://api.example.com/", "https://api.example.com:9443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(httpsPort(tt.tlsAddr, tt.publicPort)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"/api/weather/v1", "/api/weather/v1", false},
+		{"/api/weather/v1/", "/api/weather/v1", false},
+		{"api/weather", "", true},
+		{"/api/{version}", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBasePath(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBasePath(%q) = %q, %v, want %q (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/temperature", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(chi.RouteContext(r.Context()).RoutePath))
+	})
+	h := WithBasePath("/api/weather/v1", r)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/weather/v1/temperature", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("prefixed route status = %d, want 200", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unprefixed route status = %d, want 404", rec.Code)
+	}
+}
+
+func TestRoutePath(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/temperature", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RoutePath(r.Context(), r.URL.Path)))
+	})
+	rec := httptest.NewRecorder()
+	WithBasePath("/api/weather/v1", r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/weather/v1/temperature", nil))
+	if got := rec.Body.String(); got != "/temperature" {
+		t.Errorf("RoutePath() under a base path = %q, want /temperature", got)
+	}
+
+	if got := RoutePath(context.Background(), "/temperature"); got != "/temperature" {
+		t.Errorf("RoutePath() outside a router = %q, want the given path", got)
+	}
+}
@@ -0,0 +1,257 @@
+package spillover
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record is the JSON form of a span in the spillover file. It keeps what the
+// OTLP exporter sends, including the resource and scope, so spans from any
+// service can be replayed with their original identity.
+type record struct {
+	TraceID      string    `json:"trace_id"`
+	SpanID       string    `json:"span_id"`
+	TraceState   string    `json:"trace_state,omitempty"`
+	ParentSpanID string    `json:"parent_span_id,omitempty"`
+	Remote       bool      `json:"parent_remote,omitempty"`
+	Name         string    `json:"name"`
+	Kind         int       `json:"kind"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Attributes   []kv      `json:"attributes,omitempty"`
+	Events       []event   `json:"events,omitempty"`
+	Links        []link    `json:"links,omitempty"`
+	StatusCode   uint32    `json:"status_code,omitempty"`
+	StatusDesc   string    `json:"status_description,omitempty"`
+	Resource     []kv      `json:"resource,omitempty"`
+	SchemaURL    string    `json:"schema_url,omitempty"`
+	ScopeName    string    `json:"scope_name,omitempty"`
+	ScopeVersion string    `json:"scope_version,omitempty"`
+}
+
+type event struct {
+	Name       string    `json:"name"`
+	Time       time.Time `json:"time"`
+	Attributes []kv      `json:"attributes,omitempty"`
+}
+
+type link struct {
+	TraceID    string `json:"trace_id"`
+	SpanID     string `json:"span_id"`
+	Attributes []kv   `json:"attributes,omitempty"`
+}
+
+// kv is an attribute with its type, so numbers and slices decode back to the
+// same attribute type.
+type kv struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func fromSpan(s sdktrace.ReadOnlySpan) record {
+	r := record{
+		TraceID:      s.SpanContext().TraceID().String(),
+		SpanID:       s.SpanContext().SpanID().String(),
+		TraceState:   s.SpanContext().TraceState().String(),
+		Name:         s.Name(),
+		Kind:         int(s.SpanKind()),
+		Start:        s.StartTime(),
+		End:          s.EndTime(),
+		Attributes:   fromAttributes(s.Attributes()),
+		StatusCode:   uint32(s.Status().Code),
+		StatusDesc:   s.Status().Description,
+		ScopeName:    s.InstrumentationScope().Name,
+		ScopeVersion: s.InstrumentationScope().Version,
+	}
+	if s.Parent().IsValid() {
+		r.ParentSpanID = s.Parent().SpanID().String()
+		r.Remote = s.Parent().IsRemote()
+	}
+	if res := s.Resource(); res != nil {
+		r.Resource = fromAttributes(res.Attributes())
+		r.SchemaURL = res.SchemaURL()
+	}
+	for _, e := range s.Events() {
+		r.Events = append(r.Events, event{Name: e.Name, Time: e.Time, Attributes: fromAttributes(e.Attributes)})
+	}
+	for _, l := range s.Links() {
+		r.Links = append(r.Links, link{
+			TraceID:    l.SpanContext.TraceID().String(),
+			SpanID:     l.SpanContext.SpanID().String(),
+			Attributes: fromAttributes(l.Attributes),
+		})
+	}
+	return r
+}
+
+func fromAttributes(attrs []attribute.KeyValue) []kv {
+	out := make([]kv, 0, len(attrs))
+	for _, a := range attrs {
+		value, err := json.Marshal(a.Value.AsInterface())
+		if err != nil {
+			continue
+		}
+		out = append(out, kv{Key: string(a.Key), Type: a.Value.Type().String(), Value: value})
+	}
+	return out
+}
+
+func (r record) span() (sdktrace.ReadOnlySpan, error) {
+	traceID, err := trace.TraceIDFromHex(r.TraceID)
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := trace.SpanIDFromHex(r.SpanID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := trace.ParseTraceState(r.TraceState)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := tracetest.SpanStub{
+		Name: r.Name,
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+			TraceState: state,
+		}),
+		SpanKind:             trace.SpanKind(r.Kind),
+		StartTime:            r.Start,
+		EndTime:              r.End,
+		Attributes:           toAttributes(r.Attributes),
+		Status:               sdktrace.Status{Code: codes.Code(r.StatusCode), Description: r.StatusDesc},
+		Resource:             resource.NewWithAttributes(r.SchemaURL, toAttributes(r.Resource)...),
+		InstrumentationScope: instrumentation.Scope{Name: r.ScopeName, Version: r.ScopeVersion},
+	}
+	if r.ParentSpanID != "" {
+		parentID, err := trace.SpanIDFromHex(r.ParentSpanID)
+		if err != nil {
+			return nil, err
+		}
+		stub.Parent = trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     parentID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     r.Remote,
+		})
+	}
+	for _, e := range r.Events {
+		stub.Events = append(stub.Events, sdktrace.Event{Name: e.Name, Time: e.Time, Attributes: toAttributes(e.Attributes)})
+	}
+	for _, l := range r.Links {
+		linkTrace, err := trace.TraceIDFromHex(l.TraceID)
+		if err != nil {
+			return nil, err
+		}
+		linkSpan, err := trace.SpanIDFromHex(l.SpanID)
+		if err != nil {
+			return nil, err
+		}
+		stub.Links = append(stub.Links, sdktrace.Link{
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: linkTrace, SpanID: linkSpan}),
+			Attributes:  toAttributes(l.Attributes),
+		})
+	}
+	return stub.Snapshot(), nil
+}
+
+func toAttributes(kvs []kv) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(kvs))
+	for _, a := range kvs {
+		if v, ok := toValue(a); ok {
+			out = append(out, attribute.KeyValue{Key: attribute.Key(a.Key), Value: v})
+		}
+	}
+	return out
+}
+
+func toValue(a kv) (attribute.Value, bool) {
+	switch a.Type {
+	case "BOOL":
+		return decode(a.Value, attribute.BoolValue)
+	case "INT64":
+		return decode(a.Value, attribute.Int64Value)
+	case "FLOAT64":
+		return decode(a.Value, attribute.Float64Value)
+	case "STRING":
+		return decode(a.Value, attribute.StringValue)
+	case "BOOLSLICE":
+		return decode(a.Value, attribute.BoolSliceValue)
+	case "INT64SLICE":
+		return decode(a.Value, attribute.Int64SliceValue)
+	case "FLOAT64SLICE":
+		return decode(a.Value, attribute.Float64SliceValue)
+	case "STRINGSLICE":
+		return decode(a.Value, attribute.StringSliceValue)
+	}
+	return attribute.Value{}, false
+}
+
+func decode[T any](raw json.RawMessage, value func(T) attribute.Value) (attribute.Value, bool) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return attribute.Value{}, false
+	}
+	return value(v), true
+}
+
+// ReadFile calls fn with the spans in the spillover file at path, batchSize at
+// a time and in the order they were spilled. Lines that cannot be decoded are
+// skipped and counted in the returned number.
+func ReadFile(path string, batchSize int, fn func([]sdktrace.ReadOnlySpan) error) (skipped int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return read(f, batchSize, fn)
+}
+
+func read(r io.Reader, batchSize int, fn func([]sdktrace.ReadOnlySpan) error) (skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+
+	var batch []sdktrace.ReadOnlySpan
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			skipped++
+			continue
+		}
+		span, err := rec.span()
+		if err != nil {
+			skipped++
+			continue
+		}
+		batch = append(batch, span)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return skipped, err
+			}
+			batch = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return skipped, fmt.Errorf("failed to read span spillover file: %w", err)
+	}
+	if len(batch) > 0 {
+		return skipped, fn(batch)
+	}
+	return skipped, nil
+}
@@ -0,0 +1,173 @@
+// Package spillover keeps spans the OTLP exporter could not deliver, such as
+// during collector maintenance, by writing them to a local JSON lines file
+// that can be replayed into the collector later.
+package spillover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const DefaultMaxBytes = 100 << 20
+
+var ErrFull = errors.New("span spillover file is full")
+
+var spilledSpans, _ = otel.Meter("github.com/fhsmendes/open-telemetry/pkg/spillover").Int64Counter(
+	"spillover.spans",
+	metric.WithDescription("Spans the exporter failed to deliver, by whether they were spilled to disk or dropped"),
+)
+
+type Config struct {
+	// Path is the spillover file; empty disables spilling.
+	Path     string
+	MaxBytes int64
+}
+
+// LoadConfig reads SPAN_SPILLOVER_PATH and SPAN_SPILLOVER_MAX_BYTES (default
+// 100 MiB).
+func LoadConfig(getenv func(string) string) (Config, error) {
+	cfg := Config{Path: getenv("SPAN_SPILLOVER_PATH"), MaxBytes: DefaultMaxBytes}
+	if v := getenv("SPAN_SPILLOVER_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid SPAN_SPILLOVER_MAX_BYTES %q: expected a positive integer", v)
+		}
+		cfg.MaxBytes = n
+	}
+	return cfg, nil
+}
+
+// Exporter wraps a span exporter and writes the batches it fails to export to
+// a file. The OTLP exporters retry on their own before giving up, so a failed
+// batch means the collector has been unreachable for a while. Once the file
+// reaches its size cap further spans are dropped.
+//
+// Before each spill the Exporter checks that the file at its path is still the
+// one it has open, and starts a new one when it was moved aside or removed, so
+// a file can be taken away for replay while the service runs.
+type Exporter struct {
+	next     sdktrace.SpanExporter
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Wrap returns next unchanged when cfg.Path is empty, and an Exporter spilling
+// to cfg.Path otherwise.
+func Wrap(next sdktrace.SpanExporter, cfg Config) (sdktrace.SpanExporter, error) {
+	if cfg.Path == "" {
+		return next, nil
+	}
+	return New(next, cfg.Path, cfg.MaxBytes)
+}
+
+// New opens (or creates) the spillover file at path.
+func New(next sdktrace.SpanExporter, path string, maxBytes int64) (*Exporter, error) {
+	f, size, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{next: next, path: path, maxBytes: maxBytes, file: f, size: size}, nil
+}
+
+func open(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open span spillover file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to open span spillover file: %w", err)
+	}
+	return f, info.Size(), nil
+}
+
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.next.ExportSpans(ctx, spans)
+	if err == nil {
+		return nil
+	}
+
+	written, spillErr := e.spill(spans)
+	spilledSpans.Add(ctx, int64(written), metric.WithAttributes(attribute.String("result", "spilled")))
+	if dropped := len(spans) - written; dropped > 0 {
+		spilledSpans.Add(ctx, int64(dropped), metric.WithAttributes(attribute.String("result", "dropped")))
+	}
+	if spillErr != nil {
+		return errors.Join(err, spillErr)
+	}
+	otel.Handle(fmt.Errorf("span export failed, spilled %d spans to %s: %w", written, e.file.Name(), err))
+	return nil
+}
+
+// spill appends spans to the file until it is full and returns how many were
+// written.
+func (e *Exporter) spill(spans []sdktrace.ReadOnlySpan) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.reopenIfMoved(); err != nil {
+		return 0, err
+	}
+	for i, s := range spans {
+		line, err := json.Marshal(fromSpan(s))
+		if err != nil {
+			return i, err
+		}
+		line = append(line, '\n')
+		if e.size+int64(len(line)) > e.maxBytes {
+			return i, ErrFull
+		}
+		n, err := e.file.Write(line)
+		e.size += int64(n)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(spans), nil
+}
+
+// reopenIfMoved starts a new file at the path when the open one is no longer
+// there.
+func (e *Exporter) reopenIfMoved() error {
+	current, err := e.file.Stat()
+	if err != nil {
+		return err
+	}
+	onDisk, err := os.Stat(e.path)
+	if err == nil && os.SameFile(current, onDisk) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	f, size, err := open(e.path)
+	if err != nil {
+		return err
+	}
+	e.file.Close()
+	e.file, e.size = f, size
+	return nil
+}
+
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	closeErr := e.file.Close()
+	e.mu.Unlock()
+	return errors.Join(e.next.Shutdown(ctx), closeErr)
+}
@@ -0,0 +1,170 @@
+package spillover
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeExporter struct {
+	err      error
+	exported []sdktrace.ReadOnlySpan
+}
+
+func (e *fakeExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.exported = append(e.exported, spans...)
+	return nil
+}
+
+func (e *fakeExporter) Shutdown(context.Context) error { return nil }
+
+func testSpan(name string) tracetest.SpanStub {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	parentID, _ := trace.SpanIDFromHex("00f067aa0ba902b8")
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	return tracetest.SpanStub{
+		Name:        name,
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}),
+		Parent:      trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID, TraceFlags: trace.FlagsSampled, Remote: true}),
+		SpanKind:    trace.SpanKindServer,
+		StartTime:   start,
+		EndTime:     start.Add(120 * time.Millisecond),
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.route", "/temperature"),
+			attribute.Int("http.response.status_code", 502),
+			attribute.Float64("temp_c", 21.5),
+			attribute.Bool("cache.hit", false),
+			attribute.StringSlice("providers", []string{"viacep", "weatherapi"}),
+		},
+		Events: []sdktrace.Event{{Name: "retry", Time: start.Add(time.Millisecond), Attributes: []attribute.KeyValue{attribute.Int("attempt", 2)}}},
+		Status: sdktrace.Status{Code: codes.Error, Description: "Bad Gateway"},
+		Resource: resource.NewSchemaless(
+			attribute.String("service.name", "service-orchestration"),
+		),
+		InstrumentationScope: instrumentation.Scope{Name: "service-orchestration"},
+	}
+}
+
+func TestSpillsFailedBatchesAndReadsThemBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	next := &fakeExporter{err: errors.New("collector unavailable")}
+	exp, err := New(next, path, DefaultMaxBytes)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	spans := []sdktrace.ReadOnlySpan{testSpan("GET /temperature").Snapshot(), testSpan("viacep").Snapshot(), testSpan("weatherapi").Snapshot()}
+	if err := exp.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatalf("ExportSpans returned %v after spilling", err)
+	}
+	exp.Shutdown(context.Background())
+
+	var batches [][]sdktrace.ReadOnlySpan
+	skipped, err := ReadFile(path, 2, func(b []sdktrace.ReadOnlySpan) error {
+		batches = append(batches, b)
+		return nil
+	})
+	if err != nil || skipped != 0 {
+		t.Fatalf("ReadFile: skipped %d, err %v", skipped, err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("got batches of %d spans, want 2 and 1", len(batches))
+	}
+
+	want := tracetest.SpanStubFromReadOnlySpan(spans[0])
+	got := tracetest.SpanStubFromReadOnlySpan(batches[0][0])
+	if got.Name != want.Name || got.SpanContext.TraceID() != want.SpanContext.TraceID() || got.Parent.SpanID() != want.Parent.SpanID() || !got.Parent.IsRemote() {
+		t.Errorf("span identity = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(got.Attributes, want.Attributes) {
+		t.Errorf("attributes = %v, want %v", got.Attributes, want.Attributes)
+	}
+	if !reflect.DeepEqual(got.Events, want.Events) || got.Status != want.Status || !got.EndTime.Equal(want.EndTime) {
+		t.Errorf("events/status/end = %v %v %v, want %v %v %v", got.Events, got.Status, got.EndTime, want.Events, want.Status, want.EndTime)
+	}
+	if !got.Resource.Equal(want.Resource) || got.InstrumentationScope != want.InstrumentationScope {
+		t.Errorf("resource/scope = %v %v, want %v %v", got.Resource, got.InstrumentationScope, want.Resource, want.InstrumentationScope)
+	}
+}
+
+func TestDoesNotSpillExportedSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	next := &fakeExporter{}
+	exp, _ := New(next, path, DefaultMaxBytes)
+	exp.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{testSpan("GET /temperature").Snapshot()})
+	exp.Shutdown(context.Background())
+
+	var n int
+	ReadFile(path, 10, func(b []sdktrace.ReadOnlySpan) error { n += len(b); return nil })
+	if n != 0 || len(next.exported) != 1 {
+		t.Errorf("spilled %d spans and exported %d, want 0 and 1", n, len(next.exported))
+	}
+}
+
+func TestStopsAtSizeCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exp, _ := New(&fakeExporter{err: errors.New("collector unavailable")}, path, 1500)
+
+	spans := []sdktrace.ReadOnlySpan{testSpan("a").Snapshot(), testSpan("b").Snapshot(), testSpan("c").Snapshot()}
+	err := exp.ExportSpans(context.Background(), spans)
+	exp.Shutdown(context.Background())
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("ExportSpans error = %v, want ErrFull", err)
+	}
+
+	var n int
+	ReadFile(path, 10, func(b []sdktrace.ReadOnlySpan) error { n += len(b); return nil })
+	if n == 0 || n == len(spans) {
+		t.Errorf("spilled %d spans, want some but not all of %d", n, len(spans))
+	}
+}
+
+func TestStartsANewFileWhenMovedAside(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spans.jsonl")
+	exp, _ := New(&fakeExporter{err: errors.New("collector unavailable")}, path, 1500)
+	defer exp.Shutdown(context.Background())
+
+	// Fill the file up to its cap, then move it aside for replay
+	exp.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{testSpan("a").Snapshot(), testSpan("b").Snapshot()})
+	moved := filepath.Join(dir, "spans.replay.jsonl")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{testSpan("c").Snapshot()}); err != nil {
+		t.Fatalf("ExportSpans after the move = %v, want a spill to a new file", err)
+	}
+
+	spilled := func(path string) (names []string) {
+		ReadFile(path, 10, func(b []sdktrace.ReadOnlySpan) error {
+			for _, s := range b {
+				names = append(names, s.Name())
+			}
+			return nil
+		})
+		return names
+	}
+	if got := spilled(path); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("new file holds %v, want [c]", got)
+	}
+	if got := spilled(moved); len(got) == 0 || slices.Contains(got, "c") {
+		t.Errorf("moved file holds %v, want only spans spilled before the move", got)
+	}
+}
@@ -0,0 +1,36 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecoverPanic recovers a panic in the calling goroutine and records it on the
+// span carried by ctx, so async work never takes the whole process down and the
+// failure stays attached to its trace. It must be called with defer and returns
+// the recovered error through err when one is given.
+func RecoverPanic(ctx context.Context, name string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	panicErr := fmt.Errorf("panic in %s: %v", name, r)
+	stack := string(debug.Stack())
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(panicErr, trace.WithAttributes(attribute.String("panic.stack", stack)))
+	span.SetStatus(codes.Error, "panic recovered")
+
+	// The stack is logged too, for panics outside of any span
+	slog.ErrorContext(ctx, "Panic recovered", "error", panicErr, "panic.stack", stack)
+
+	if err != nil {
+		*err = panicErr
+	}
+}
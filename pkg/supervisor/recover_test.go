@@ -0,0 +1,29 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	run := func() (err error) {
+		defer RecoverPanic(context.Background(), "worker", &err)
+		panic("boom")
+	}
+
+	err := run()
+	if err == nil || err.Error() != "panic in worker: boom" {
+		t.Errorf("RecoverPanic error = %v, want panic in worker: boom", err)
+	}
+}
+
+func TestRecoverPanic_NoPanic(t *testing.T) {
+	run := func() (err error) {
+		defer RecoverPanic(context.Background(), "worker", &err)
+		return nil
+	}
+
+	if err := run(); err != nil {
+		t.Errorf("RecoverPanic without panic returned %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+type TaskStatus struct {
+	Running   bool   `json:"running"`
+	Restarts  int    `json:"restarts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Supervisor runs the service's background goroutines. A task that panics or
+// returns an error is restarted with exponential backoff; a task that returns
+// nil, or whose context is cancelled, is considered finished. Work started
+// with Spawn runs once instead.
+type Supervisor struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks map[string]*TaskStatus
+	// spawned counts the Spawn calls still running, by name
+	spawned map[string]int
+}
+
+func New(ctx context.Context) *Supervisor {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Supervisor{
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		ctx:        ctx,
+		cancel:     cancel,
+		tasks:      make(map[string]*TaskStatus),
+		spawned:    make(map[string]int),
+	}
+}
+
+// Go starts fn under supervision as name.
+func (s *Supervisor) Go(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	s.tasks[name] = &TaskStatus{Running: true}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.update(name, func(t *TaskStatus) { t.Running = false })
+
+		backoff := s.MinBackoff
+		for {
+			start := time.Now()
+			err := runTask(s.ctx, name, fn)
+			if err == nil || s.ctx.Err() != nil {
+				return
+			}
+
+			slog.ErrorContext(s.ctx, "Background task failed, restarting", "task", name, "backoff", backoff, "error", err)
+			s.update(name, func(t *TaskStatus) {
+				t.Restarts++
+				t.LastError = err.Error()
+			})
+
+			// A task that ran for a while before failing starts over from the
+			// shortest backoff
+			if time.Since(start) > s.MaxBackoff {
+				backoff = s.MinBackoff
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, s.MaxBackoff)
+		}
+	}()
+}
+
+func runTask(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	defer RecoverPanic(ctx, name, &err)
+	return fn(ctx)
+}
+
+// Spawn runs fn once in the background as name, for work a request starts
+// that must outlive it, such as a comparison with another provider. fn gets
+// ctx rather than the supervisor's context, so it stays in the request's trace
+// and Shutdown waits for it instead of cancelling it; fn must bound itself
+// with a timeout. A panic is recovered and recorded on the span of ctx, and fn
+// is not restarted. Spawned work is not listed by Status.
+func (s *Supervisor) Spawn(ctx context.Context, name string, fn func(ctx context.Context)) {
+	s.mu.Lock()
+	s.spawned[name]++
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			s.spawned[name]--
+			s.mu.Unlock()
+		}()
+		defer RecoverPanic(ctx, name, nil)
+		fn(ctx)
+	}()
+}
+
+func (s *Supervisor) update(name string, fn func(t *TaskStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.tasks[name])
+}
+
+// Status returns a snapshot of every task started with Go.
+func (s *Supervisor) Status() map[string]TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make(map[string]TaskStatus, len(s.tasks))
+	for name, t := range s.tasks {
+		status[name] = *t
+	}
+	return status
+}
+
+// Shutdown cancels every task and waits for them, and for the spawned work, to
+// return, or for ctx to be done, whichever comes first.
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		var running []string
+		for name, t := range s.Status() {
+			if t.Running {
+				running = append(running, name)
+			}
+		}
+		s.mu.Lock()
+		for name, n := range s.spawned {
+			if n > 0 {
+				running = append(running, fmt.Sprintf("%s (%d)", name, n))
+			}
+		}
+		s.mu.Unlock()
+		sort.Strings(running)
+		return fmt.Errorf("background tasks still running after shutdown: %v", running)
+	}
+}
@@ -0,0 +1,138 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestSupervisor() *Supervisor {
+	s := New(context.Background())
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+	return s
+}
+
+func TestRestartsFailingTasks(t *testing.T) {
+	s := newTestSupervisor()
+
+	var runs atomic.Int32
+	done := make(chan struct{})
+	s.Go("flaky", func(ctx context.Context) error {
+		switch runs.Add(1) {
+		case 1:
+			return errors.New("collector unreachable")
+		case 2:
+			panic("nil map")
+		}
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task was not restarted")
+	}
+	s.Shutdown(context.Background())
+
+	status := s.Status()["flaky"]
+	if status.Restarts != 2 {
+		t.Errorf("restarts = %d, want 2", status.Restarts)
+	}
+	if status.Running {
+		t.Error("finished task reported as running")
+	}
+	if status.LastError == "" {
+		t.Error("last error not recorded")
+	}
+}
+
+func TestShutdownStopsTasks(t *testing.T) {
+	s := newTestSupervisor()
+
+	started := make(chan struct{})
+	s.Go("prober", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	})
+	<-started
+
+	if !s.Status()["prober"].Running {
+		t.Error("task should be running before shutdown")
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if s.Status()["prober"].Running {
+		t.Error("task still running after shutdown")
+	}
+}
+
+func TestShutdownReportsStuckTasks(t *testing.T) {
+	s := newTestSupervisor()
+
+	release := make(chan struct{})
+	defer close(release)
+	s.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil {
+		t.Error("Shutdown() should report a task that ignores cancellation")
+	}
+}
+
+func TestSpawnRunsOnce(t *testing.T) {
+	s := newTestSupervisor()
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+	var runs atomic.Int32
+	got := make(chan any, 1)
+	s.Spawn(ctx, "crosscheck", func(ctx context.Context) {
+		runs.Add(1)
+		got <- ctx.Value(key{})
+		panic("decoder exploded")
+	})
+
+	if v := <-got; v != "request" {
+		t.Errorf("spawned work got %v, want the caller's context", v)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if runs.Load() != 1 {
+		t.Errorf("spawned work ran %d times, want once despite the panic", runs.Load())
+	}
+	if len(s.Status()) != 0 {
+		t.Errorf("Status() = %v, want spawned work left out", s.Status())
+	}
+}
+
+func TestShutdownWaitsForSpawnedWork(t *testing.T) {
+	s := newTestSupervisor()
+
+	release := make(chan struct{})
+	s.Spawn(context.Background(), "export", func(ctx context.Context) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "export (1)") {
+		t.Errorf("Shutdown() = %v, want the running export reported", err)
+	}
+
+	close(release)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v after the export finished", err)
+	}
+}
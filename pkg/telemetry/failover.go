@@ -0,0 +1,351 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultFailoverAfter = 3
+	DefaultFailbackProbe = 30 * time.Second
+)
+
+var endpointSwitches, _ = otel.Meter("github.com/fhsmendes/open-telemetry/pkg/telemetry").Int64Counter(
+	"telemetry.collector.endpoint_switches",
+	metric.WithDescription("Number of times the exporters switched collector endpoint, by endpoint switched to and reason"),
+)
+
+// FailoverConfig lists the collector endpoints in order of preference.
+type FailoverConfig struct {
+	Endpoints []string
+	// After is the number of consecutive failed export calls that moves the
+	// exporters to the next endpoint.
+	After int
+	// Probe is how often the preferred endpoints are checked while a fallback
+	// one is active, to fail back once they recover.
+	Probe time.Duration
+}
+
+// LoadFailoverConfig reads the primary endpoint from
+// OTEL_EXPORTER_OTLP_ENDPOINT and the fallback ones from
+// OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS (comma-separated, in order of
+// preference), with OTEL_EXPORTER_OTLP_FAILOVER_AFTER (default 3 failed
+// calls) and OTEL_EXPORTER_OTLP_FAILBACK_PROBE (default 30s).
+func LoadFailoverConfig(getenv func(string) string) (FailoverConfig, error) {
+	cfg := FailoverConfig{After: DefaultFailoverAfter, Probe: DefaultFailbackProbe}
+	if primary := strings.TrimSpace(getenv("OTEL_EXPORTER_OTLP_ENDPOINT")); primary != "" {
+		cfg.Endpoints = append(cfg.Endpoints, primary)
+	}
+	for _, endpoint := range strings.Split(getenv("OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS"), ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			cfg.Endpoints = append(cfg.Endpoints, endpoint)
+		}
+	}
+	if len(cfg.Endpoints) == 0 {
+		return FailoverConfig{}, errors.New("OTEL_EXPORTER_OTLP_ENDPOINT is not set")
+	}
+
+	if raw := getenv("OTEL_EXPORTER_OTLP_FAILOVER_AFTER"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return FailoverConfig{}, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_FAILOVER_AFTER %q: expected a positive number of calls", raw)
+		}
+		cfg.After = n
+	}
+	if raw := getenv("OTEL_EXPORTER_OTLP_FAILBACK_PROBE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return FailoverConfig{}, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_FAILBACK_PROBE %q: expected a positive duration", raw)
+		}
+		cfg.Probe = d
+	}
+	return cfg, nil
+}
+
+// CollectorStatus reports the collector endpoint the exporters use.
+type CollectorStatus struct {
+	Active    string    `json:"active"`
+	Endpoints []string  `json:"endpoints"`
+	Switches  int64     `json:"switches"`
+	Since     time.Time `json:"since"`
+	// Connected is when the first export picked the endpoint to start with,
+	// which took ConnectMs; nil until then.
+	Connected *time.Time `json:"connected,omitempty"`
+	ConnectMs float64    `json:"connect_ms,omitempty"`
+	// ConnectError is why no endpoint was reachable then, leaving the first
+	// one active.
+	ConnectError string `json:"connect_error,omitempty"`
+}
+
+// Failover is a gRPC connection for the OTLP exporters that moves between
+// collector endpoints: to the next one when export calls keep failing, and
+// back to a preferred one as soon as it accepts connections again. Every
+// exporter built on Conn follows the switch, so the signals always go to the
+// same collector.
+type Failover struct {
+	cfg      FailoverConfig
+	opts     []grpc.DialOption
+	resolver *manual.Resolver
+	conn     *grpc.ClientConn
+	// probe reports whether an endpoint accepts connections.
+	probe func(ctx context.Context, endpoint string) error
+
+	connect sync.Once
+
+	mu         sync.Mutex
+	active     int
+	failures   int
+	switches   int64
+	since      time.Time
+	connected  *time.Time
+	connectDur time.Duration
+	connectErr error
+}
+
+// NewFailover returns a connection to the endpoints of cfg, using opts for
+// every endpoint. It does not block: the first export call picks the first
+// reachable endpoint, so a slow or unreachable collector does not delay the
+// start of the service.
+func NewFailover(cfg FailoverConfig, opts ...grpc.DialOption) (*Failover, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("no collector endpoint configured")
+	}
+	f := &Failover{
+		cfg:      cfg,
+		opts:     opts,
+		resolver: manual.NewBuilderWithScheme("otlp-failover"),
+		since:    time.Now(),
+	}
+	f.probe = f.dial
+	f.resolver.InitialState(resolver.State{Addresses: []resolver.Address{address(cfg.Endpoints[0])}})
+
+	var err error
+	f.conn, err = grpc.NewClient(f.resolver.Scheme()+":///collector", append(opts,
+		grpc.WithResolvers(f.resolver),
+		grpc.WithChainUnaryInterceptor(f.observe),
+	)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+	}
+	return f, nil
+}
+
+// Conn is the connection to build the exporters on.
+func (f *Failover) Conn() *grpc.ClientConn {
+	return f.conn
+}
+
+func (f *Failover) Status() CollectorStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := CollectorStatus{
+		Active:    f.cfg.Endpoints[f.active],
+		Endpoints: f.cfg.Endpoints,
+		Switches:  f.switches,
+		Since:     f.since,
+		Connected: f.connected,
+	}
+	if f.connected != nil {
+		status.ConnectMs = float64(f.connectDur.Microseconds()) / 1000
+	}
+	if f.connectErr != nil {
+		status.ConnectError = f.connectErr.Error()
+	}
+	return status
+}
+
+// Close closes the connection; call it once the exporters have shut down.
+func (f *Failover) Close() error {
+	return f.conn.Close()
+}
+
+// observe counts consecutive export calls that failed for want of a working
+// collector, and fails over once there are cfg.After of them. Rejections of
+// the data itself say nothing about the endpoint and are not counted.
+func (f *Failover) observe(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	f.connect.Do(func() { f.connectFirst(ctx) })
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+	default:
+		f.mu.Lock()
+		f.failures = 0
+		f.mu.Unlock()
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures++
+	if f.failures >= f.cfg.After && len(f.cfg.Endpoints) > 1 {
+		f.switchTo(ctx, (f.active+1)%len(f.cfg.Endpoints), "failover")
+	}
+	return err
+}
+
+// connectFirst points the connection at the first reachable endpoint, within
+// the deadline of the first export call. When none is reachable the first
+// endpoint stays active and failover takes over from there.
+func (f *Failover) connectFirst(ctx context.Context) {
+	start := time.Now()
+	active, err := f.firstReachable(ctx, len(f.cfg.Endpoints))
+	connected := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected, f.connectDur, f.connectErr = &connected, connected.Sub(start), err
+	if err == nil && active != f.active {
+		f.active, f.since = active, connected
+		f.resolver.UpdateState(resolver.State{Addresses: []resolver.Address{address(f.cfg.Endpoints[active])}})
+	}
+}
+
+// Failback probes the endpoints preferred over the active one every cfg.Probe
+// and switches to the first that is reachable, until ctx is done. Services
+// run it as a supervised background task.
+func (f *Failover) Failback(ctx context.Context) error {
+	ticker := time.NewTicker(f.cfg.Probe)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		f.mu.Lock()
+		active := f.active
+		f.mu.Unlock()
+		if active == 0 {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, f.cfg.Probe/2)
+		preferred, err := f.firstReachable(probeCtx, active)
+		cancel()
+		if err != nil {
+			continue
+		}
+		f.mu.Lock()
+		// A failover during the probe may have moved further away already
+		if preferred < f.active {
+			f.switchTo(context.Background(), preferred, "failback")
+		}
+		f.mu.Unlock()
+	}
+}
+
+// firstReachable returns the index of the first of the n most preferred
+// endpoints that accepts a connection.
+func (f *Failover) firstReachable(ctx context.Context, n int) (int, error) {
+	var errs []error
+	for i, endpoint := range f.cfg.Endpoints[:n] {
+		err := f.probe(ctx, endpoint)
+		if err == nil {
+			return i, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return 0, fmt.Errorf("no collector endpoint is reachable: %w", errors.Join(errs...))
+}
+
+// switchTo points the connection at endpoint i; f.mu must be held.
+func (f *Failover) switchTo(ctx context.Context, i int, reason string) {
+	if i == f.active {
+		return
+	}
+	f.active, f.failures, f.since = i, 0, time.Now()
+	f.switches++
+	f.resolver.UpdateState(resolver.State{Addresses: []resolver.Address{address(f.cfg.Endpoints[i])}})
+	endpointSwitches.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", f.cfg.Endpoints[i]),
+		attribute.String("reason", reason),
+	))
+}
+
+func (f *Failover) dial(ctx context.Context, endpoint string) error {
+	return dial(ctx, endpoint, f.opts)
+}
+
+// CheckCollectors connects to every collector endpoint configured by the
+// OTEL_EXPORTER_OTLP_* variables at once, for the services' startup checks.
+// It passes when one of them is reachable, since the exporters fail over to
+// the others.
+func CheckCollectors(ctx context.Context, getenv func(string) string) error {
+	cfg, err := LoadFailoverConfig(getenv)
+	if err != nil {
+		return err
+	}
+	exporterConfig, err := LoadExporterConfig(getenv)
+	if err != nil {
+		return err
+	}
+	opts := exporterConfig.DialOptions()
+
+	errs := make([]error, len(cfg.Endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range cfg.Endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := dial(ctx, endpoint, opts); err != nil {
+				errs[i] = fmt.Errorf("failed to reach collector at %s: %w", endpoint, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dial waits until a connection to endpoint is ready, fails, or ctx ends.
+func dial(ctx context.Context, endpoint string, opts []grpc.DialOption) error {
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.TransientFailure {
+			return errors.New("connection failed")
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// address keeps TLS verifying each endpoint against its own host name rather
+// than the name of the shared connection.
+func address(endpoint string) resolver.Address {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	return resolver.Address{Addr: endpoint, ServerName: host}
+}
@@ -0,0 +1,178 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestLoadFailoverConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{"primary only", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4317"}, []string{"collector:4317"}, false},
+		{"fallbacks", map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT":           "collector:4317",
+			"OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS": "collector-b:4317, ,collector-c:4317",
+		}, []string{"collector:4317", "collector-b:4317", "collector-c:4317"}, false},
+		{"no endpoint", map[string]string{}, nil, true},
+		{"invalid after", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4317", "OTEL_EXPORTER_OTLP_FAILOVER_AFTER": "0"}, nil, true},
+		{"invalid probe", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4317", "OTEL_EXPORTER_OTLP_FAILBACK_PROBE": "soon"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFailoverConfig(func(k string) string { return tt.env[k] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFailoverConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(cfg.Endpoints) != len(tt.want) {
+				t.Fatalf("Endpoints = %v, want %v", cfg.Endpoints, tt.want)
+			}
+			for i := range tt.want {
+				if cfg.Endpoints[i] != tt.want[i] {
+					t.Errorf("Endpoints = %v, want %v", cfg.Endpoints, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// collector serves gRPC on addr (":0" picks a port) until stopped. It has no
+// services, so calls that reach it fail with Unimplemented.
+func collector(t *testing.T, addr string) (*grpc.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return srv, lis.Addr().String()
+}
+
+func TestFailoverSwitchesAndFailsBack(t *testing.T) {
+	primary, primaryAddr := collector(t, "127.0.0.1:0")
+	_, secondaryAddr := collector(t, "127.0.0.1:0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	f, err := NewFailover(FailoverConfig{Endpoints: []string{primaryAddr, secondaryAddr}, After: 2, Probe: 50 * time.Millisecond},
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	go f.Failback(ctx)
+
+	export := func() codes.Code {
+		callCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		return status.Code(f.Conn().Invoke(callCtx, "/otlp.Test/Export", &emptypb.Empty{}, &emptypb.Empty{}))
+	}
+	if code := export(); code != codes.Unimplemented {
+		t.Fatalf("export to the primary = %s, want it to reach the collector", code)
+	}
+
+	primary.Stop()
+	for i := 0; i < 2; i++ {
+		if code := export(); code != codes.Unavailable {
+			t.Fatalf("export %d with the primary down = %s, want Unavailable", i, code)
+		}
+	}
+	if got := f.Status(); got.Active != secondaryAddr || got.Switches != 1 {
+		t.Fatalf("status = %+v, want the secondary active after 1 switch", got)
+	}
+	// Calls fail until the connection to the secondary is up
+	deadline := time.Now().Add(5 * time.Second)
+	for export() != codes.Unimplemented {
+		if time.Now().After(deadline) {
+			t.Fatal("exports never reached the secondary after failover")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	collector(t, primaryAddr)
+	deadline = time.Now().Add(5 * time.Second)
+	for f.Status().Active != primaryAddr {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v, want the primary active again", f.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFailoverConnectsToFirstReachableEndpoint(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := lis.Addr().String()
+	lis.Close()
+	_, up := collector(t, "127.0.0.1:0")
+
+	f, err := NewFailover(FailoverConfig{Endpoints: []string{down, up}, After: 3, Probe: time.Minute},
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := f.Status(); got.Connected != nil || got.Active != down {
+		t.Fatalf("status before any export = %+v, want nothing dialed yet", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if code := status.Code(f.Conn().Invoke(ctx, "/otlp.Test/Export", &emptypb.Empty{}, &emptypb.Empty{})); code != codes.Unimplemented {
+		t.Errorf("first export = %s, want it to reach %s", code, up)
+	}
+	if got := f.Status(); got.Active != up || got.Connected == nil || got.ConnectError != "" || got.Switches != 0 {
+		t.Errorf("status = %+v, want %s active after connecting", got, up)
+	}
+}
+
+func TestCheckCollectors(t *testing.T) {
+	_, reachable := collector(t, "127.0.0.1:0")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := lis.Addr().String()
+	lis.Close()
+
+	tests := []struct {
+		name     string
+		primary  string
+		failover string
+		wantErr  bool
+	}{
+		{"primary reachable", reachable, "", false},
+		{"fallback reachable", unreachable, reachable, false},
+		{"none reachable", unreachable, "", true},
+		{"no endpoint", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":           tt.primary,
+				"OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS": tt.failover,
+				"OTEL_EXPORTER_OTLP_INSECURE":           "true",
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := CheckCollectors(ctx, func(k string) string { return env[k] }); (err != nil) != tt.wantErr {
+				t.Errorf("CheckCollectors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
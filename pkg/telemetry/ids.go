@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IDGenerator generates the request IDs sent in X-Request-Id and logged with
+// each request.
+type IDGenerator interface {
+	NewRequestID(ctx context.Context) string
+}
+
+// RandomIDs generates random 32-character lowercase hex IDs, the format of a
+// W3C trace-id, so any ID can later be used to look up a trace.
+type RandomIDs struct{}
+
+func (RandomIDs) NewRequestID(context.Context) string {
+	var id trace.TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// TraceIDs reuses the trace ID of the span in ctx, so logs, headers and
+// traces share one identifier. Without a valid span, e.g. when tracing is
+// disabled, it uses Fallback, or RandomIDs when Fallback is nil.
+type TraceIDs struct {
+	Fallback IDGenerator
+}
+
+func (g TraceIDs) NewRequestID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	if g.Fallback != nil {
+		return g.Fallback.NewRequestID(ctx)
+	}
+	return RandomIDs{}.NewRequestID(ctx)
+}
@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var traceIDFormat = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+type fixedIDs string
+
+func (f fixedIDs) NewRequestID(context.Context) string { return string(f) }
+
+func TestTraceIDs(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9},
+		SpanID:  trace.SpanID{1},
+	})
+	traced := trace.ContextWithSpanContext(context.Background(), sc)
+
+	tests := []struct {
+		name string
+		gen  TraceIDs
+		ctx  context.Context
+		want string
+	}{
+		{"reuses trace ID", TraceIDs{}, traced, sc.TraceID().String()},
+		{"uses fallback without span", TraceIDs{Fallback: fixedIDs("req-1")}, context.Background(), "req-1"},
+		{"ignores fallback with span", TraceIDs{Fallback: fixedIDs("req-1")}, traced, sc.TraceID().String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gen.NewRequestID(tt.ctx); got != tt.want {
+				t.Errorf("NewRequestID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := (TraceIDs{}).NewRequestID(context.Background()); !traceIDFormat.MatchString(got) {
+		t.Errorf("NewRequestID() without span = %q, want a W3C trace-id", got)
+	}
+}
+
+func TestRandomIDsAreTraceCompatible(t *testing.T) {
+	a, b := RandomIDs{}.NewRequestID(context.Background()), RandomIDs{}.NewRequestID(context.Background())
+	for _, id := range []string{a, b} {
+		if _, err := trace.TraceIDFromHex(id); err != nil || !traceIDFormat.MatchString(id) {
+			t.Errorf("RandomIDs generated %q, not a valid trace-id: %v", id, err)
+		}
+	}
+	if a == b {
+		t.Errorf("RandomIDs generated %q twice", a)
+	}
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Ownership attribute keys, the same in every service so a trace search by
+// team spans all of them.
+const (
+	TeamKey       = attribute.Key("org.team")
+	CostCenterKey = attribute.Key("org.cost_center")
+	TierKey       = attribute.Key("org.tier")
+)
+
+// Ownership describes who owns a service; empty fields are not stamped.
+type Ownership struct {
+	Team       string
+	CostCenter string
+	Tier       string
+}
+
+// LoadOwnership reads SERVICE_TEAM, SERVICE_COST_CENTER and SERVICE_TIER.
+func LoadOwnership(getenv func(string) string) Ownership {
+	return Ownership{
+		Team:       strings.TrimSpace(getenv("SERVICE_TEAM")),
+		CostCenter: strings.TrimSpace(getenv("SERVICE_COST_CENTER")),
+		Tier:       strings.TrimSpace(getenv("SERVICE_TIER")),
+	}
+}
+
+func (o Ownership) Attributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, a := range []struct {
+		key   attribute.Key
+		value string
+	}{
+		{TeamKey, o.Team},
+		{CostCenterKey, o.CostCenter},
+		{TierKey, o.Tier},
+	} {
+		if a.value != "" {
+			attrs = append(attrs, a.key.String(a.value))
+		}
+	}
+	return attrs
+}
+
+// OwnershipProcessor stamps the ownership attributes on every span as it
+// starts, so call sites never add them and they are set before any other
+// processor sees the span.
+type OwnershipProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+func NewOwnershipProcessor(o Ownership) *OwnershipProcessor {
+	return &OwnershipProcessor{attrs: o.Attributes()}
+}
+
+func (p *OwnershipProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if len(p.attrs) > 0 {
+		s.SetAttributes(p.attrs...)
+	}
+}
+
+func (p *OwnershipProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (p *OwnershipProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *OwnershipProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOwnershipProcessor(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want map[attribute.Key]string
+	}{
+		{"unset", nil, map[attribute.Key]string{}},
+		{
+			"every attribute",
+			map[string]string{"SERVICE_TEAM": "weather", "SERVICE_COST_CENTER": " cc-42 ", "SERVICE_TIER": "1"},
+			map[attribute.Key]string{TeamKey: "weather", CostCenterKey: "cc-42", TierKey: "1"},
+		},
+		{"team only", map[string]string{"SERVICE_TEAM": "weather"}, map[attribute.Key]string{TeamKey: "weather"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(
+				sdktrace.WithSpanProcessor(NewOwnershipProcessor(LoadOwnership(func(k string) string { return tt.env[k] }))),
+				sdktrace.WithSpanProcessor(recorder),
+			)
+			_, span := provider.Tracer("test").Start(context.Background(), "op")
+			span.End()
+
+			got := map[attribute.Key]string{}
+			for _, kv := range recorder.Ended()[0].Attributes() {
+				got[kv.Key] = kv.Value.AsString()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("attributes = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
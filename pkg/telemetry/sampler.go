@@ -0,0 +1,217 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultBoostWindow      = time.Minute
+	DefaultBoostDuration    = 5 * time.Minute
+	DefaultBoostDecay       = 5 * time.Minute
+	DefaultBoostMinRequests = 20
+)
+
+// SamplingConfig sets the trace sampling ratio and when it is boosted.
+type SamplingConfig struct {
+	// Ratio is the share of traces sampled in normal operation.
+	Ratio float64
+
+	// BoostErrorRate is the share of failed server spans in a BoostWindow that
+	// raises sampling to every trace for BoostDuration, after which the ratio
+	// decays back to Ratio over BoostDecay. Zero disables boosting.
+	BoostErrorRate   float64
+	BoostWindow      time.Duration
+	BoostDuration    time.Duration
+	BoostDecay       time.Duration
+	BoostMinRequests int
+}
+
+// LoadSamplingConfig reads TRACE_SAMPLE_RATIO (0 to 1, default 1),
+// TRACE_BOOST_ERROR_RATE (0 to 1, default 0 to disable), TRACE_BOOST_WINDOW,
+// TRACE_BOOST_DURATION, TRACE_BOOST_DECAY and TRACE_BOOST_MIN_REQUESTS (the
+// server spans a window needs before its error rate is trusted).
+func LoadSamplingConfig(getenv func(string) string) (SamplingConfig, error) {
+	cfg := SamplingConfig{
+		Ratio:            1,
+		BoostWindow:      DefaultBoostWindow,
+		BoostDuration:    DefaultBoostDuration,
+		BoostDecay:       DefaultBoostDecay,
+		BoostMinRequests: DefaultBoostMinRequests,
+	}
+
+	for _, f := range []struct {
+		env string
+		dst *float64
+	}{
+		{"TRACE_SAMPLE_RATIO", &cfg.Ratio},
+		{"TRACE_BOOST_ERROR_RATE", &cfg.BoostErrorRate},
+	} {
+		v := getenv(f.env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 1 {
+			return SamplingConfig{}, fmt.Errorf("invalid %s %q: expected a number between 0 and 1", f.env, v)
+		}
+		*f.dst = n
+	}
+
+	for _, f := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"TRACE_BOOST_WINDOW", &cfg.BoostWindow},
+		{"TRACE_BOOST_DURATION", &cfg.BoostDuration},
+		{"TRACE_BOOST_DECAY", &cfg.BoostDecay},
+	} {
+		v := getenv(f.env)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return SamplingConfig{}, fmt.Errorf("invalid %s %q: expected a positive duration", f.env, v)
+		}
+		*f.dst = d
+	}
+
+	if v := getenv("TRACE_BOOST_MIN_REQUESTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return SamplingConfig{}, fmt.Errorf("invalid TRACE_BOOST_MIN_REQUESTS %q: expected a positive integer", v)
+		}
+		cfg.BoostMinRequests = n
+	}
+	return cfg, nil
+}
+
+// BoostSampler samples Ratio of traces and raises that to every trace while
+// the service is failing, so incidents are fully traced. It is also a span
+// processor: register it with sdktrace.WithSpanProcessor so it can watch the
+// status of the server spans. Processors only see recorded spans, so while
+// boosting is enabled the server spans it does not sample are still recorded,
+// though never exported, and every request counts towards the error rate.
+type BoostSampler struct {
+	cfg SamplingConfig
+	now func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	errors      int
+	boostUntil  time.Time
+}
+
+func NewBoostSampler(cfg SamplingConfig) *BoostSampler {
+	return &BoostSampler{cfg: cfg, now: time.Now}
+}
+
+// Ratio is the share of traces currently sampled.
+func (s *BoostSampler) Ratio() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ratio(s.now())
+}
+
+// ratio must be called with s.mu held.
+func (s *BoostSampler) ratio(now time.Time) float64 {
+	if s.boostUntil.IsZero() {
+		return s.cfg.Ratio
+	}
+	since := now.Sub(s.boostUntil)
+	if since < 0 {
+		return 1
+	}
+	if since >= s.cfg.BoostDecay {
+		return s.cfg.Ratio
+	}
+	decayed := float64(since) / float64(s.cfg.BoostDecay)
+	return 1 - (1-s.cfg.Ratio)*decayed
+}
+
+func (s *BoostSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	ratio := s.Ratio()
+	result := sdktrace.TraceIDRatioBased(ratio).ShouldSample(p)
+	if result.Decision == sdktrace.RecordAndSample && ratio > s.cfg.Ratio {
+		result.Attributes = append(result.Attributes, attribute.Bool("sampling.boosted", true))
+	}
+	if result.Decision == sdktrace.Drop {
+		result.Decision = s.unsampled(p.Kind)
+	}
+	return result
+}
+
+// unsampled is the decision for a span of kind that is not sampled:
+// RecordOnly for server spans while boosting is enabled, so OnEnd sees them.
+func (s *BoostSampler) unsampled(kind trace.SpanKind) sdktrace.SamplingDecision {
+	if s.cfg.BoostErrorRate > 0 && kind == trace.SpanKindServer {
+		return sdktrace.RecordOnly
+	}
+	return sdktrace.Drop
+}
+
+// ParentBased returns the sampler to install in the tracer provider: it
+// follows the decision of sampled parents, samples roots with s, and records
+// the server spans of unsampled remote parents without sampling them.
+func (s *BoostSampler) ParentBased() sdktrace.Sampler {
+	return sdktrace.ParentBased(s, sdktrace.WithRemoteParentNotSampled(unsampledParent{s}))
+}
+
+type unsampledParent struct {
+	s *BoostSampler
+}
+
+func (u unsampledParent) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{
+		Decision:   u.s.unsampled(p.Kind),
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (u unsampledParent) Description() string {
+	return "UnsampledParent{" + u.s.Description() + "}"
+}
+
+func (s *BoostSampler) Description() string {
+	return fmt.Sprintf("BoostSampler{ratio=%g,boost_error_rate=%g}", s.cfg.Ratio, s.cfg.BoostErrorRate)
+}
+
+func (s *BoostSampler) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd counts the finished server spans and starts a boost when the error
+// rate of the current window crosses the threshold.
+func (s *BoostSampler) OnEnd(span sdktrace.ReadOnlySpan) {
+	if s.cfg.BoostErrorRate <= 0 || span.SpanKind() != trace.SpanKindServer {
+		return
+	}
+	failed := span.Status().Code == codes.Error
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.windowStart) >= s.cfg.BoostWindow {
+		s.windowStart = now
+		s.requests, s.errors = 0, 0
+	}
+	s.requests++
+	if failed {
+		s.errors++
+	}
+	if s.requests >= s.cfg.BoostMinRequests && float64(s.errors)/float64(s.requests) >= s.cfg.BoostErrorRate {
+		s.boostUntil = now.Add(s.cfg.BoostDuration)
+	}
+}
+
+func (s *BoostSampler) Shutdown(context.Context) error   { return nil }
+func (s *BoostSampler) ForceFlush(context.Context) error { return nil }
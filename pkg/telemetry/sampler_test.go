@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoadSamplingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    SamplingConfig
+		wantErr bool
+	}{
+		{
+			"defaults",
+			nil,
+			SamplingConfig{Ratio: 1, BoostWindow: DefaultBoostWindow, BoostDuration: DefaultBoostDuration, BoostDecay: DefaultBoostDecay, BoostMinRequests: DefaultBoostMinRequests},
+			false,
+		},
+		{
+			"boost",
+			map[string]string{"TRACE_SAMPLE_RATIO": "0.1", "TRACE_BOOST_ERROR_RATE": "0.05", "TRACE_BOOST_DURATION": "10m", "TRACE_BOOST_MIN_REQUESTS": "5"},
+			SamplingConfig{Ratio: 0.1, BoostErrorRate: 0.05, BoostWindow: DefaultBoostWindow, BoostDuration: 10 * time.Minute, BoostDecay: DefaultBoostDecay, BoostMinRequests: 5},
+			false,
+		},
+		{"ratio above one", map[string]string{"TRACE_SAMPLE_RATIO": "2"}, SamplingConfig{}, true},
+		{"zero window", map[string]string{"TRACE_BOOST_WINDOW": "0s"}, SamplingConfig{}, true},
+		{"invalid min requests", map[string]string{"TRACE_BOOST_MIN_REQUESTS": "none"}, SamplingConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadSamplingConfig(func(k string) string { return tt.env[k] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSamplingConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LoadSamplingConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func endServerSpan(s *BoostSampler, failed bool) {
+	stub := tracetest.SpanStub{SpanKind: trace.SpanKindServer}
+	if failed {
+		stub.Status = sdktrace.Status{Code: codes.Error}
+	}
+	s.OnEnd(stub.Snapshot())
+}
+
+func TestBoostSampler(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewBoostSampler(SamplingConfig{
+		Ratio:            0.1,
+		BoostErrorRate:   0.5,
+		BoostWindow:      time.Minute,
+		BoostDuration:    5 * time.Minute,
+		BoostDecay:       4 * time.Minute,
+		BoostMinRequests: 4,
+	})
+	s.now = func() time.Time { return now }
+
+	// Too few requests to trust the error rate
+	for range 3 {
+		endServerSpan(s, true)
+	}
+	if got := s.Ratio(); got != 0.1 {
+		t.Fatalf("ratio before min requests = %g, want 0.1", got)
+	}
+
+	endServerSpan(s, false)
+	if got := s.Ratio(); got != 1 {
+		t.Fatalf("ratio after errors = %g, want 1", got)
+	}
+
+	steps := []struct {
+		after time.Duration
+		want  float64
+	}{
+		{4 * time.Minute, 1},
+		{7 * time.Minute, 0.55},
+		{9 * time.Minute, 0.1},
+	}
+	start := now
+	for _, step := range steps {
+		now = start.Add(step.after)
+		if got := s.Ratio(); got < step.want-1e-9 || got > step.want+1e-9 {
+			t.Errorf("ratio after %s = %g, want %g", step.after, got, step.want)
+		}
+	}
+}
+
+func TestBoostSamplerIgnoresInternalSpans(t *testing.T) {
+	s := NewBoostSampler(SamplingConfig{Ratio: 0.1, BoostErrorRate: 0.1, BoostWindow: time.Minute, BoostDuration: time.Minute, BoostDecay: time.Minute, BoostMinRequests: 1})
+	s.OnEnd(tracetest.SpanStub{SpanKind: trace.SpanKindClient, Status: sdktrace.Status{Code: codes.Error}}.Snapshot())
+
+	if got := s.Ratio(); got != 0.1 {
+		t.Errorf("ratio = %g, want 0.1", got)
+	}
+	res := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: trace.TraceID{8: 0xff}})
+	if res.Decision != sdktrace.Drop {
+		t.Errorf("decision = %v, want Drop for a trace outside the ratio", res.Decision)
+	}
+}
+
+func TestBoostSamplerCountsUnsampledServerSpans(t *testing.T) {
+	s := NewBoostSampler(SamplingConfig{Ratio: 0, BoostErrorRate: 0.5, BoostWindow: time.Minute, BoostDuration: time.Minute, BoostDecay: time.Minute, BoostMinRequests: 2})
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSpanProcessor(s),
+		sdktrace.WithSampler(s.ParentBased()),
+	)
+	tracer := tp.Tracer("")
+
+	// A root request, and one whose caller did not sample it
+	unsampled := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, Remote: true})
+	for _, ctx := range []context.Context{context.Background(), trace.ContextWithRemoteSpanContext(context.Background(), unsampled)} {
+		ctx, span := tracer.Start(ctx, "request", trace.WithSpanKind(trace.SpanKindServer))
+		if !span.IsRecording() || span.SpanContext().IsSampled() {
+			t.Errorf("server span recording %v, sampled %v; want recorded only", span.IsRecording(), span.SpanContext().IsSampled())
+		}
+		_, child := tracer.Start(ctx, "lookup")
+		if child.IsRecording() {
+			t.Error("child of an unsampled server span is recorded")
+		}
+		child.End()
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+	}
+
+	if got := s.Ratio(); got != 1 {
+		t.Errorf("ratio = %g after unsampled requests failed, want 1", got)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("exported %d unsampled spans", len(spans))
+	}
+}
@@ -0,0 +1,109 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultShutdownTimeout       = 10 * time.Second
+	DefaultSignalShutdownTimeout = 5 * time.Second
+)
+
+// ShutdownConfig bounds how long flushing the telemetry may delay process
+// exit: Total for every signal together and PerSignal for each of them.
+type ShutdownConfig struct {
+	Total     time.Duration
+	PerSignal time.Duration
+}
+
+// LoadShutdownConfig reads OTEL_SHUTDOWN_TIMEOUT (default 10s) and
+// OTEL_SHUTDOWN_SIGNAL_TIMEOUT (default 5s).
+func LoadShutdownConfig(getenv func(string) string) (ShutdownConfig, error) {
+	cfg := ShutdownConfig{Total: DefaultShutdownTimeout, PerSignal: DefaultSignalShutdownTimeout}
+	for _, v := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"OTEL_SHUTDOWN_TIMEOUT", &cfg.Total},
+		{"OTEL_SHUTDOWN_SIGNAL_TIMEOUT", &cfg.PerSignal},
+	} {
+		raw := getenv(v.env)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return ShutdownConfig{}, fmt.Errorf("invalid %s %q: expected a positive duration", v.env, raw)
+		}
+		*v.dst = d
+	}
+	return cfg, nil
+}
+
+// Signal is a telemetry pipeline to flush and shut down, e.g. the tracer
+// provider as "traces".
+type Signal struct {
+	Name     string
+	Shutdown func(context.Context) error
+}
+
+// ShutdownResult reports how one signal shut down; Err is nil when everything
+// it buffered was exported.
+type ShutdownResult struct {
+	Signal   string
+	Duration time.Duration
+	Err      error
+}
+
+// Shutdown shuts the signals down in parallel, so a collector that stopped
+// answering one of them does not keep the others from flushing. It returns
+// after cfg.Total at the latest, reporting the signals that were still
+// flushing with the deadline error, in the order they were given.
+func Shutdown(ctx context.Context, cfg ShutdownConfig, signals ...Signal) []ShutdownResult {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Total)
+	defer cancel()
+
+	type done struct {
+		i      int
+		result ShutdownResult
+	}
+	ch := make(chan done, len(signals))
+	start := time.Now()
+	for i, s := range signals {
+		go func() {
+			signalCtx, cancel := context.WithTimeout(ctx, cfg.PerSignal)
+			defer cancel()
+			err := s.Shutdown(signalCtx)
+			ch <- done{i, ShutdownResult{Signal: s.Name, Duration: time.Since(start), Err: err}}
+		}()
+	}
+
+	results := make([]ShutdownResult, len(signals))
+	finished := make([]bool, len(signals))
+	for range signals {
+		select {
+		case d := <-ch:
+			results[d.i], finished[d.i] = d.result, true
+		case <-ctx.Done():
+			// Signals that finished while the deadline fired are already
+			// in ch; report them rather than their timeout.
+			for drained := false; !drained; {
+				select {
+				case d := <-ch:
+					results[d.i], finished[d.i] = d.result, true
+				default:
+					drained = true
+				}
+			}
+			for i, s := range signals {
+				if !finished[i] {
+					results[i] = ShutdownResult{Signal: s.Name, Duration: time.Since(start), Err: ctx.Err()}
+				}
+			}
+			return results
+		}
+	}
+	return results
+}
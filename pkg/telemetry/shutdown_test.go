@@ -0,0 +1,68 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	errExport := errors.New("export failed")
+	block := make(chan struct{})
+	defer close(block)
+
+	signals := []Signal{
+		{"traces", func(context.Context) error { return nil }},
+		{"metrics", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{"logs", func(context.Context) error { return errExport }},
+		// Ignores its context, so only the total deadline stops waiting for it
+		{"stuck", func(context.Context) error {
+			<-block
+			return nil
+		}},
+	}
+
+	start := time.Now()
+	results := Shutdown(context.Background(), ShutdownConfig{Total: 100 * time.Millisecond, PerSignal: 20 * time.Millisecond}, signals...)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %s, want about the total timeout", elapsed)
+	}
+
+	want := []struct {
+		signal string
+		err    error
+	}{
+		{"traces", nil},
+		{"metrics", context.DeadlineExceeded},
+		{"logs", errExport},
+		{"stuck", context.DeadlineExceeded},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		if results[i].Signal != w.signal || !errors.Is(results[i].Err, w.err) {
+			t.Errorf("result %d = %s: %v, want %s: %v", i, results[i].Signal, results[i].Err, w.signal, w.err)
+		}
+	}
+	if results[1].Duration > 90*time.Millisecond {
+		t.Errorf("metrics took %s, want the per-signal timeout", results[1].Duration)
+	}
+}
+
+func TestLoadShutdownConfig(t *testing.T) {
+	env := map[string]string{"OTEL_SHUTDOWN_TIMEOUT": "3s"}
+	cfg, err := LoadShutdownConfig(func(key string) string { return env[key] })
+	if err != nil || cfg.Total != 3*time.Second || cfg.PerSignal != DefaultSignalShutdownTimeout {
+		t.Errorf("LoadShutdownConfig() = %+v, %v", cfg, err)
+	}
+
+	env["OTEL_SHUTDOWN_SIGNAL_TIMEOUT"] = "0s"
+	if _, err := LoadShutdownConfig(func(key string) string { return env[key] }); err == nil {
+		t.Error("LoadShutdownConfig accepted a zero timeout")
+	}
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatusFunc derives the status of a span from the error its operation
+// returned.
+type StatusFunc func(err error) (codes.Code, string)
+
+// ErrorStatus is Ok for a nil error and Error with its message otherwise.
+func ErrorStatus(err error) (codes.Code, string) {
+	if err == nil {
+		return codes.Ok, ""
+	}
+	return codes.Error, err.Error()
+}
+
+// scopedSpan makes End and SetStatus idempotent: the span is ended once and
+// keeps the first status it is given, so the most specific status set deep in
+// the call chain is not overwritten by a generic one further up.
+type scopedSpan struct {
+	trace.Span
+	ended     atomic.Bool
+	statusSet atomic.Bool
+}
+
+func (s *scopedSpan) End(options ...trace.SpanEndOption) {
+	if s.ended.CompareAndSwap(false, true) {
+		s.Span.End(options...)
+	}
+}
+
+func (s *scopedSpan) SetStatus(code codes.Code, description string) {
+	if s.statusSet.CompareAndSwap(false, true) {
+		s.Span.SetStatus(code, description)
+	}
+}
+
+// WithSpan runs fn inside a new span named name and guarantees the span is
+// ended exactly once, even if fn panics. When fn did not set a status itself,
+// the returned error is recorded and status derives the span status from it.
+func WithSpan(ctx context.Context, tracer trace.Tracer, name string, status StatusFunc, fn func(ctx context.Context, span trace.Span) error) error {
+	ctx, s := tracer.Start(ctx, name)
+	span := &scopedSpan{Span: s}
+	defer span.End()
+
+	err := fn(trace.ContextWithSpan(ctx, span), span)
+
+	if !span.statusSet.Load() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.SetStatus(status(err))
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithSpan(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(ctx context.Context, span trace.Span) error
+		wantCode codes.Code
+		wantDesc string
+	}{
+		{
+			"success",
+			func(ctx context.Context, span trace.Span) error { return nil },
+			codes.Ok, "",
+		},
+		{
+			"returned error",
+			func(ctx context.Context, span trace.Span) error { return errors.New("service B unavailable") },
+			codes.Error, "service B unavailable",
+		},
+		{
+			"first status wins",
+			func(ctx context.Context, span trace.Span) error {
+				span.SetStatus(codes.Error, "failed to decode JSON response")
+				span.SetStatus(codes.Error, "city not found")
+				return errors.New("generic")
+			},
+			codes.Error, "failed to decode JSON response",
+		},
+		{
+			"manual end is idempotent",
+			func(ctx context.Context, span trace.Span) error {
+				span.End()
+				span.End()
+				return nil
+			},
+			codes.Unset, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+			WithSpan(context.Background(), tracer, "step", ErrorStatus, tt.fn)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("ended spans = %d, want exactly 1", len(spans))
+			}
+			status := spans[0].Status()
+			if status.Code != tt.wantCode || status.Description != tt.wantDesc {
+				t.Errorf("status = %v %q, want %v %q", status.Code, status.Description, tt.wantCode, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestWithSpan_ChildrenAreSiblings(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	WithSpan(context.Background(), tracer, "parent", ErrorStatus, func(ctx context.Context, parent trace.Span) error {
+		WithSpan(ctx, tracer, "first", ErrorStatus, func(ctx context.Context, span trace.Span) error { return nil })
+		WithSpan(ctx, tracer, "second", ErrorStatus, func(ctx context.Context, span trace.Span) error { return nil })
+		return nil
+	})
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		byName[s.Name()] = s
+	}
+	parentID := byName["parent"].SpanContext().SpanID()
+	for _, name := range []string{"first", "second"} {
+		if byName[name].Parent().SpanID() != parentID {
+			t.Errorf("span %q parent = %s, want %s", name, byName[name].Parent().SpanID(), parentID)
+		}
+	}
+}
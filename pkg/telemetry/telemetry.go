@@ -0,0 +1,95 @@
+// Package telemetry holds the OTLP exporter settings shared by the services,
+// so both connect to the collector or backend the same way.
+package telemetry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// ExporterConfig configures the gRPC connection and calls of the OTLP
+// exporters.
+type ExporterConfig struct {
+	Compression string
+	// Headers are sent with every export call, e.g. backend API keys.
+	Headers map[string]string
+	// Insecure disables TLS, as needed by the local collector. It must be
+	// asked for, so a misconfigured deployment fails to connect instead of
+	// sending telemetry and its headers in plain text.
+	Insecure bool
+}
+
+// LoadExporterConfig reads OTEL_EXPORTER_OTLP_COMPRESSION ("gzip" or
+// "none"), OTEL_EXPORTER_OTLP_HEADERS (e.g. "x-honeycomb-team=abc,x-tenant=b",
+// with URL-encoded values) and OTEL_EXPORTER_OTLP_INSECURE ("true" to
+// connect without TLS; defaults to false).
+func LoadExporterConfig(getenv func(string) string) (ExporterConfig, error) {
+	cfg := ExporterConfig{
+		Compression: CompressionNone,
+		Insecure:    getenv("OTEL_EXPORTER_OTLP_INSECURE") == "true",
+	}
+
+	switch v := strings.ToLower(strings.TrimSpace(getenv("OTEL_EXPORTER_OTLP_COMPRESSION"))); v {
+	case "", CompressionNone:
+	case CompressionGzip:
+		cfg.Compression = CompressionGzip
+	default:
+		return ExporterConfig{}, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_COMPRESSION %q: expected gzip or none", v)
+	}
+
+	headers, err := ParseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return ExporterConfig{}, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	cfg.Headers = headers
+	return cfg, nil
+}
+
+// ParseHeaders parses comma-separated key=value pairs in the format of the
+// OpenTelemetry OTEL_EXPORTER_OTLP_HEADERS variable.
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("entry %q: expected key=value", entry)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %w", key, err)
+		}
+		headers[strings.ToLower(key)] = decoded
+	}
+	return headers, nil
+}
+
+// DialOptions returns the options for the gRPC connection shared by the
+// exporters. Compression is set here because exporters built on an existing
+// connection ignore their own compression option.
+func (c ExporterConfig) DialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(nil))}
+	if c.Insecure {
+		opts[0] = grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	if c.Compression == CompressionGzip {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	return opts
+}
@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single", "x-honeycomb-team=abc123", map[string]string{"x-honeycomb-team": "abc123"}, false},
+		{"several with spaces", " X-Tenant = a , authorization=Basic%20dXNlcg%3D%3D ", map[string]string{"x-tenant": "a", "authorization": "Basic dXNlcg=="}, false},
+		{"missing value separator", "x-honeycomb-team", nil, true},
+		{"missing key", "=abc", nil, true},
+		{"bad escape", "x-key=%zz", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHeaders(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeaders(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeaders(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExporterConfig(t *testing.T) {
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_COMPRESSION": "GZIP",
+		"OTEL_EXPORTER_OTLP_HEADERS":     "x-honeycomb-team=abc",
+		"OTEL_EXPORTER_OTLP_INSECURE":    "false",
+	}
+	cfg, err := LoadExporterConfig(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Compression != CompressionGzip || cfg.Insecure || cfg.Headers["x-honeycomb-team"] != "abc" {
+		t.Errorf("LoadExporterConfig() = %+v", cfg)
+	}
+	if len(cfg.DialOptions()) != 2 {
+		t.Errorf("DialOptions() has %d options, want credentials and compression", len(cfg.DialOptions()))
+	}
+
+	delete(env, "OTEL_EXPORTER_OTLP_INSECURE")
+	if cfg, _ := LoadExporterConfig(func(key string) string { return env[key] }); cfg.Insecure {
+		t.Error("LoadExporterConfig() disabled TLS without OTEL_EXPORTER_OTLP_INSECURE=true")
+	}
+
+	env["OTEL_EXPORTER_OTLP_COMPRESSION"] = "zstd"
+	if _, err := LoadExporterConfig(func(key string) string { return env[key] }); err == nil {
+		t.Error("LoadExporterConfig() should reject unknown compressions")
+	}
+}
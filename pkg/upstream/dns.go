@@ -0,0 +1,369 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultDNSCacheTTL = time.Minute
+
+const (
+	// defaultFallbackDelay is net.Dialer's default wait before racing the
+	// other address family.
+	defaultFallbackDelay = 300 * time.Millisecond
+	// minDialTimeout is the least time an address gets when the deadline is
+	// split between several, as in net.Dialer.
+	minDialTimeout = 2 * time.Second
+)
+
+var dnsDuration, _ = otel.Meter("github.com/fhsmendes/open-telemetry/pkg/upstream").Float64Histogram(
+	"dns.lookup.duration",
+	metric.WithDescription("Time to resolve upstream hosts, by host and source (override, cache, lookup or stale)"),
+	metric.WithUnit("ms"),
+)
+
+// DNSConfig configures how upstream hosts are resolved.
+type DNSConfig struct {
+	// CacheTTL is how long resolved addresses are reused; zero resolves on
+	// every new connection.
+	CacheTTL time.Duration
+	// Overrides pins hosts to fixed addresses, like /etc/hosts.
+	Overrides map[string][]string
+	// Prefer orders the resolved addresses: "ipv4", "ipv6" or "" for the
+	// resolver's order.
+	Prefer string
+}
+
+// LoadDNSConfig reads DNS_CACHE_TTL (e.g. "30s", default 1m; "0" disables
+// caching), DNS_HOST_OVERRIDES (e.g. "viacep.com.br=203.0.113.10|203.0.113.11")
+// and DNS_PREFER ("ipv4" or "ipv6").
+func LoadDNSConfig(getenv func(string) string) (DNSConfig, error) {
+	cfg := DNSConfig{CacheTTL: DefaultDNSCacheTTL, Overrides: make(map[string][]string)}
+
+	if v := getenv("DNS_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return DNSConfig{}, fmt.Errorf("invalid DNS_CACHE_TTL %q: expected a non-negative duration", v)
+		}
+		cfg.CacheTTL = d
+	}
+
+	for _, entry := range strings.Split(getenv("DNS_HOST_OVERRIDES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, addrs, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return DNSConfig{}, fmt.Errorf("invalid DNS_HOST_OVERRIDES entry %q: expected host=ip|ip", entry)
+		}
+		for _, addr := range strings.Split(addrs, "|") {
+			addr = strings.TrimSpace(addr)
+			if net.ParseIP(addr) == nil {
+				return DNSConfig{}, fmt.Errorf("invalid DNS_HOST_OVERRIDES entry %q: %q is not an IP address", entry, addr)
+			}
+			cfg.Overrides[host] = append(cfg.Overrides[host], addr)
+		}
+	}
+
+	switch cfg.Prefer = strings.ToLower(strings.TrimSpace(getenv("DNS_PREFER"))); cfg.Prefer {
+	case "", "ipv4", "ipv6":
+	default:
+		return DNSConfig{}, fmt.Errorf("invalid DNS_PREFER %q: expected ipv4 or ipv6", cfg.Prefer)
+	}
+	return cfg, nil
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// Resolver resolves upstream hosts with a cache and static overrides. When a
+// lookup fails it falls back to the last addresses it resolved for the host,
+// even if they expired, so a DNS hiccup does not fail the call.
+type Resolver struct {
+	cfg    DNSConfig
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+func NewResolver(cfg DNSConfig) *Resolver {
+	return &Resolver{
+		cfg:    cfg,
+		lookup: net.DefaultResolver.LookupHost,
+		cache:  make(map[string]dnsEntry),
+	}
+}
+
+// LookupHost returns the addresses of host, reporting the lookup to the
+// httptrace.ClientTrace in ctx. DNSDoneInfo.Coalesced is set when the answer
+// did not come from a fresh lookup.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ct := httptrace.ContextClientTrace(ctx)
+	if ct != nil && ct.DNSStart != nil {
+		ct.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+
+	start := time.Now()
+	host = strings.ToLower(host)
+	addrs, source, err := r.resolve(ctx, host)
+	dnsDuration.Record(ctx, float64(time.Since(start).Microseconds())/1000,
+		metric.WithAttributes(attribute.String("host", host), attribute.String("source", source)))
+
+	if ct != nil && ct.DNSDone != nil {
+		info := httptrace.DNSDoneInfo{Err: err, Coalesced: source != "lookup"}
+		for _, a := range addrs {
+			info.Addrs = append(info.Addrs, net.IPAddr{IP: net.ParseIP(a)})
+		}
+		ct.DNSDone(info)
+	}
+	return addrs, err
+}
+
+// resolve returns the addresses of host and where they came from: "override",
+// "cache", "lookup" or "stale".
+func (r *Resolver) resolve(ctx context.Context, host string) ([]string, string, error) {
+	if addrs, ok := r.cfg.Overrides[host]; ok {
+		return addrs, "override", nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}, "override", nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.addrs, "cache", nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	source := "lookup"
+	if err != nil {
+		if !ok {
+			return nil, source, err
+		}
+		addrs, source = cached.addrs, "stale"
+	} else {
+		addrs = r.order(addrs)
+		if r.cfg.CacheTTL > 0 {
+			r.mu.Lock()
+			r.cache[host] = dnsEntry{addrs: addrs, expires: now.Add(r.cfg.CacheTTL)}
+			r.mu.Unlock()
+		}
+	}
+	return addrs, source, nil
+}
+
+// order puts the preferred address family first, keeping the resolver's
+// order within each family.
+func (r *Resolver) order(addrs []string) []string {
+	if r.cfg.Prefer == "" {
+		return addrs
+	}
+	var first, rest []string
+	for _, a := range addrs {
+		isV4 := net.ParseIP(a).To4() != nil
+		if isV4 == (r.cfg.Prefer == "ipv4") {
+			first = append(first, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return append(first, rest...)
+}
+
+// DialContext dials addr with dialer, resolving its host through r. Like
+// net.Dialer, it tries the addresses of each family in turn, splitting
+// dialer.Timeout between them, and races the other family against the first
+// after dialer.FallbackDelay (Happy Eyeballs, RFC 6555).
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+
+		if dialer.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+			defer cancel()
+		}
+		// Each address gets its own share of the deadline set above
+		d := *dialer
+		d.Timeout = 0
+
+		if d.FallbackDelay < 0 {
+			return dialSerial(ctx, &d, network, port, addrs)
+		}
+		primaries, fallbacks := partition(addrs)
+		return dialParallel(ctx, &d, network, port, primaries, fallbacks)
+	}
+}
+
+// partition splits addrs into those of the same family as the first one and
+// the rest.
+func partition(addrs []string) (primaries, fallbacks []string) {
+	isV4 := func(a string) bool { return net.ParseIP(a).To4() != nil }
+	first := isV4(addrs[0])
+	for _, a := range addrs {
+		if isV4(a) == first {
+			primaries = append(primaries, a)
+		} else {
+			fallbacks = append(fallbacks, a)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialParallel dials primaries and, after d.FallbackDelay or as soon as they
+// all fail, fallbacks, returning the first connection established.
+func dialParallel(ctx context.Context, d *net.Dialer, network, port string, primaries, fallbacks []string) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, d, network, port, primaries)
+	}
+
+	returned := make(chan struct{})
+	defer close(returned)
+
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan dialResult)
+	race := func(ctx context.Context, addrs []string, primary bool) {
+		conn, err := dialSerial(ctx, d, network, port, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go race(primaryCtx, primaries, true)
+
+	delay := d.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+	defer fallbackCancel()
+
+	var primary, fallback dialResult
+	for {
+		select {
+		case <-fallbackTimer.C:
+			go race(fallbackCtx, fallbacks, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+			if primary.err != nil && fallback.err != nil {
+				return nil, primary.err
+			}
+			// The primaries failed before the fallback started
+			if res.primary && fallbackTimer.Stop() {
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
+
+// dialSerial dials addrs in turn until one connects, giving each an equal
+// share of the time left before the deadline of ctx.
+func dialSerial(ctx context.Context, d *net.Dialer, network, port string, addrs []string) (net.Conn, error) {
+	var lastErr error
+	for i, a := range addrs {
+		if err := ctx.Err(); err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			break
+		}
+
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			share := time.Until(deadline) / time.Duration(len(addrs)-i)
+			if share < minDialTimeout {
+				share = min(minDialTimeout, time.Until(deadline))
+			}
+			dialCtx, cancel = context.WithTimeout(ctx, share)
+		}
+		conn, err := d.DialContext(dialCtx, network, net.JoinHostPort(a, port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Transport returns a clone of http.DefaultTransport that resolves hosts
+// through r and records the resolution time on the span of each request.
+func (r *Resolver) Transport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = r.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return dnsTraceTransport{next: t}
+}
+
+// dnsTraceTransport records how long resolving the upstream host took on the
+// request's span, for requests that opened a new connection.
+type dnsTraceTransport struct {
+	next http.RoundTripper
+}
+
+func (t dnsTraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return t.next.RoundTrip(req)
+	}
+
+	var start time.Time
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { start = time.Now() },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			span.SetAttributes(
+				attribute.Float64("dns.duration_ms", float64(time.Since(start).Microseconds())/1000),
+				attribute.Bool("dns.cached", info.Coalesced),
+			)
+		},
+	})
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
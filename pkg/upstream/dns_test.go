@@ -0,0 +1,129 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadDNSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    DNSConfig
+		wantErr bool
+	}{
+		{"defaults", nil, DNSConfig{CacheTTL: DefaultDNSCacheTTL, Overrides: map[string][]string{}}, false},
+		{
+			"overrides",
+			map[string]string{"DNS_CACHE_TTL": "0", "DNS_HOST_OVERRIDES": "ViaCEP.com.br=203.0.113.10|203.0.113.11, api.weatherapi.com=2001:db8::1", "DNS_PREFER": "IPv4"},
+			DNSConfig{Overrides: map[string][]string{"viacep.com.br": {"203.0.113.10", "203.0.113.11"}, "api.weatherapi.com": {"2001:db8::1"}}, Prefer: "ipv4"},
+			false,
+		},
+		{"negative ttl", map[string]string{"DNS_CACHE_TTL": "-1s"}, DNSConfig{}, true},
+		{"override without address", map[string]string{"DNS_HOST_OVERRIDES": "viacep.com.br"}, DNSConfig{}, true},
+		{"override with hostname", map[string]string{"DNS_HOST_OVERRIDES": "viacep.com.br=example.com"}, DNSConfig{}, true},
+		{"unknown preference", map[string]string{"DNS_PREFER": "ipv5"}, DNSConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadDNSConfig(func(k string) string { return tt.env[k] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadDNSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadDNSConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeLookup struct {
+	calls int
+	addrs []string
+	err   error
+}
+
+func (f *fakeLookup) LookupHost(context.Context, string) ([]string, error) {
+	f.calls++
+	return f.addrs, f.err
+}
+
+func TestResolver(t *testing.T) {
+	lookup := &fakeLookup{addrs: []string{"2001:db8::1", "203.0.113.10"}}
+	r := NewResolver(DNSConfig{
+		CacheTTL:  time.Minute,
+		Overrides: map[string][]string{"pinned.example": {"198.51.100.7"}},
+		Prefer:    "ipv4",
+	})
+	r.lookup = lookup.LookupHost
+
+	var coalesced []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) { coalesced = append(coalesced, info.Coalesced) },
+	})
+
+	addrs, err := r.LookupHost(ctx, "viacep.com.br")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"203.0.113.10", "2001:db8::1"}) {
+		t.Fatalf("first lookup = %v, %v; want IPv4 first", addrs, err)
+	}
+	r.LookupHost(ctx, "ViaCEP.com.br")
+	if lookup.calls != 1 {
+		t.Errorf("resolver looked up %d times, want 1 with the cache", lookup.calls)
+	}
+
+	if addrs, _ := r.LookupHost(ctx, "pinned.example"); !reflect.DeepEqual(addrs, []string{"198.51.100.7"}) || lookup.calls != 1 {
+		t.Errorf("override = %v after %d lookups, want the pinned address without a lookup", addrs, lookup.calls)
+	}
+	if !reflect.DeepEqual(coalesced, []bool{false, true, true}) {
+		t.Errorf("DNSDone coalesced = %v, want [false true true]", coalesced)
+	}
+}
+
+func TestResolverServesStaleAddressesOnFailure(t *testing.T) {
+	lookup := &fakeLookup{addrs: []string{"203.0.113.10"}}
+	r := NewResolver(DNSConfig{CacheTTL: time.Nanosecond})
+	r.lookup = lookup.LookupHost
+
+	r.LookupHost(context.Background(), "viacep.com.br")
+	time.Sleep(time.Millisecond)
+	lookup.err = errors.New("i/o timeout")
+
+	addrs, err := r.LookupHost(context.Background(), "viacep.com.br")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"203.0.113.10"}) {
+		t.Errorf("lookup after failure = %v, %v; want the stale address", addrs, err)
+	}
+	if _, err := r.LookupHost(context.Background(), "unknown.example"); err == nil {
+		t.Error("failed lookup of an uncached host returned no error")
+	}
+}
+
+func TestPartition(t *testing.T) {
+	primaries, fallbacks := partition([]string{"2001:db8::1", "203.0.113.10", "2001:db8::2", "203.0.113.11"})
+	if !reflect.DeepEqual(primaries, []string{"2001:db8::1", "2001:db8::2"}) || !reflect.DeepEqual(fallbacks, []string{"203.0.113.10", "203.0.113.11"}) {
+		t.Errorf("partition() = %v, %v; want the first family, then the other", primaries, fallbacks)
+	}
+}
+
+func TestResolverDialFallsBackToTheOtherFamily(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// Nothing listens on the IPv6 loopback, so the IPv4 fallback must connect
+	r := NewResolver(DNSConfig{Overrides: map[string][]string{"dual.example": {"::1", "127.0.0.1"}}})
+	dial := r.DialContext(&net.Dialer{Timeout: 5 * time.Second, FallbackDelay: time.Minute})
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("dual.example", port))
+	if err != nil {
+		t.Fatalf("dial = %v, want a connection over IPv4", err)
+	}
+	conn.Close()
+}
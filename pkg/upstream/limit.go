@@ -0,0 +1,86 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DefaultMaxResponseBytes bounds the response body of targets that do not set
+// MaxResponseBytes.
+const DefaultMaxResponseBytes = 4 << 20
+
+var responseLimitExceeded, _ = meter.Int64Counter(
+	"upstream.response.limit_exceeded",
+	metric.WithDescription("Number of upstream responses larger than the target's maximum response size, by target"),
+)
+
+// ResponseTooLargeError is returned when a target answers with more than
+// Limit bytes: by the call when the response declares its length, otherwise
+// by the body once the limit is passed, so decoders reading it fail with it.
+type ResponseTooLargeError struct {
+	Target string
+	Limit  int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s response exceeds %d bytes", e.Target, e.Limit)
+}
+
+type limitTransport struct {
+	target string
+	limit  int64
+	next   http.RoundTripper
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.ContentLength > t.limit {
+		resp.Body.Close()
+		return nil, t.exceeded(req.Context())
+	}
+	resp.Body = &limitedBody{
+		ReadCloser: resp.Body,
+		reader:     io.LimitReader(resp.Body, t.limit+1),
+		transport:  t,
+		ctx:        req.Context(),
+	}
+	return resp, nil
+}
+
+func (t *limitTransport) exceeded(ctx context.Context) error {
+	responseLimitExceeded.Add(ctx, 1, metric.WithAttributes(attribute.String("target", t.target)))
+	return &ResponseTooLargeError{Target: t.target, Limit: t.limit}
+}
+
+// limitedBody reads at most one byte past the limit, which tells a body of
+// exactly the limit apart from a larger one.
+type limitedBody struct {
+	io.ReadCloser
+	reader    io.Reader
+	transport *limitTransport
+	ctx       context.Context
+
+	read int64
+	err  error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if over := b.read - b.transport.limit; over > 0 {
+		b.err = b.transport.exceeded(b.ctx)
+		return n - int(over), b.err
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+package upstream
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrBreakerOpen is returned, without calling the upstream, while a target's
+// breaker is open.
+var ErrBreakerOpen = errors.New("upstream circuit breaker open")
+
+var meter = otel.Meter("github.com/fhsmendes/open-telemetry/pkg/upstream")
+
+var breakerRejections, _ = meter.Int64Counter(
+	"upstream.breaker.rejections",
+	metric.WithDescription("Number of upstream calls rejected while the target's circuit breaker was open, by target"),
+)
+
+// retryBackoff is the wait before the first retry; it doubles on each one.
+const retryBackoff = 100 * time.Millisecond
+
+type authTransport struct {
+	next http.RoundTripper
+	auth Auth
+}
+
+// RoundTrip adds the credential unless the caller already set one, as the
+// weather key pool does.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.auth.Header != "" && req.Header.Get(t.auth.Header) == "" {
+		req.Header.Set(t.auth.Header, t.auth.Value)
+	}
+	if t.auth.QueryParam != "" {
+		q := req.URL.Query()
+		if q.Get(t.auth.QueryParam) == "" {
+			q.Set(t.auth.QueryParam, t.auth.Value)
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+}
+
+// RoundTrip retries idempotent requests without a body after network errors
+// and 502, 503 and 504 responses, backing off between attempts.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || (req.Body != nil && req.Body != http.NoBody) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt == t.retries || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	var tooLarge *ResponseTooLargeError
+	if errors.Is(err, ErrBreakerOpen) || errors.As(err, &tooLarge) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+type breakerTransport struct {
+	target   string
+	next     http.RoundTripper
+	failures int
+	cooldown time.Duration
+	clock    clock.Clock
+
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+}
+
+// RoundTrip counts network errors and 5xx responses as failures. Once the
+// breaker has been open for cooldown, calls go through again and the first
+// failure reopens it.
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.clock.Now().Before(t.openUntil) {
+		t.mu.Unlock()
+		breakerRejections.Add(req.Context(), 1, metric.WithAttributes(attribute.String("target", t.target)))
+		return nil, ErrBreakerOpen
+	}
+	t.mu.Unlock()
+
+	resp, err := t.next.RoundTrip(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		t.consecutive++
+		if t.consecutive >= t.failures {
+			t.openUntil = t.clock.Now().Add(t.cooldown)
+			t.consecutive = t.failures - 1
+		}
+	} else {
+		t.consecutive = 0
+	}
+	return resp, err
+}
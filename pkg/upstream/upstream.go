@@ -0,0 +1,218 @@
+// Package upstream configures the HTTP dependencies of the services as named
+// targets, each with its URL, timeout, retries, circuit breaker, credentials
+// and response size limit, and builds the client every call to a target goes through. A
+// new upstream only needs an entry in the targets file.
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+// Duration is a time.Duration written as a string ("3s") in the targets file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"3s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Breaker opens after Failures consecutive failed calls and rejects calls
+// with ErrBreakerOpen for Cooldown. Zero Failures disables it.
+type Breaker struct {
+	Failures int      `json:"failures,omitempty"`
+	Cooldown Duration `json:"cooldown,omitempty"`
+}
+
+// Auth adds the credential read from the ValueEnv variable to every request,
+// as the Header header or the QueryParam query parameter. Secrets never live
+// in the targets file itself.
+type Auth struct {
+	Header     string `json:"header,omitempty"`
+	QueryParam string `json:"query_param,omitempty"`
+	ValueEnv   string `json:"value_env,omitempty"`
+
+	// Value is resolved from ValueEnv by Load.
+	Value string `json:"-"`
+}
+
+// Target is a named upstream dependency.
+type Target struct {
+	Name string `json:"name"`
+	// URL is the base URL that request paths are appended to.
+	URL string `json:"url"`
+	// Timeout bounds each call, retries included; zero leaves it to the
+	// caller's context.
+	Timeout Duration `json:"timeout,omitempty"`
+	// Retries is how many times a GET or HEAD is retried after a network
+	// error or a 502, 503 or 504.
+	Retries int     `json:"retries,omitempty"`
+	Breaker Breaker `json:"breaker,omitempty"`
+	Auth    Auth    `json:"auth,omitempty"`
+	// MaxResponseBytes bounds the response body read from the target, so a
+	// misbehaving upstream cannot make callers buffer unbounded data; zero
+	// uses DefaultMaxResponseBytes.
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty"`
+}
+
+func (t Target) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("target %s: url %q must be an absolute http or https URL", t.Name, t.URL)
+	}
+	if t.Timeout < 0 || t.Retries < 0 || t.Breaker.Failures < 0 || t.Breaker.Cooldown < 0 || t.MaxResponseBytes < 0 {
+		return fmt.Errorf("target %s: timeout, retries, breaker and response size settings must not be negative", t.Name)
+	}
+	if t.Auth.Header != "" && t.Auth.QueryParam != "" {
+		return fmt.Errorf("target %s: auth sets both header and query_param", t.Name)
+	}
+	return nil
+}
+
+// Config holds the targets by name.
+type Config map[string]Target
+
+// Load returns defaults overridden by the targets in the JSON array of the
+// UPSTREAMS_FILE file, if set; a target in the file replaces the default of
+// the same name. Defaults carry the legacy variables (e.g. SERVICE_B_URL),
+// so deployments without a targets file keep working. Default targets
+// without a URL are dropped, leaving callers to report them as missing.
+func Load(getenv func(string) string, defaults ...Target) (Config, error) {
+	cfg := make(Config)
+	for _, t := range defaults {
+		if t.URL != "" {
+			cfg[t.Name] = t
+		}
+	}
+
+	if path := getenv("UPSTREAMS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UPSTREAMS_FILE: %w", err)
+		}
+		var targets []Target
+		if err := json.Unmarshal(data, &targets); err != nil {
+			return nil, fmt.Errorf("invalid UPSTREAMS_FILE %s: %w", path, err)
+		}
+		for _, t := range targets {
+			cfg[t.Name] = t
+		}
+	}
+
+	for name, t := range cfg {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("invalid upstream: %w", err)
+		}
+		t.URL = strings.TrimSuffix(t.URL, "/")
+		if t.Auth.ValueEnv != "" {
+			t.Auth.Value = getenv(t.Auth.ValueEnv)
+		}
+		cfg[name] = t
+	}
+	return cfg, nil
+}
+
+// Registry builds and keeps one client per target, so breaker state is
+// shared by every call to it. Each client is built on its first use, so
+// targets a process never calls cost nothing at startup.
+type Registry struct {
+	targets   Config
+	clients   map[string]*lazyClient
+	base      *http.Client
+	transport http.RoundTripper
+	clock     clock.Clock
+}
+
+type lazyClient struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// NewRegistry returns the clients of cfg, all sending their requests through
+// base; nil uses http.DefaultTransport.
+func NewRegistry(cfg Config, base http.RoundTripper) *Registry {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	r := &Registry{
+		targets:   cfg,
+		clients:   make(map[string]*lazyClient, len(cfg)),
+		base:      &http.Client{Transport: base},
+		transport: base,
+		clock:     clock.Real{},
+	}
+	for name := range cfg {
+		r.clients[name] = &lazyClient{}
+	}
+	return r
+}
+
+// Target returns the target named name.
+func (r *Registry) Target(name string) (Target, bool) {
+	t, ok := r.targets[name]
+	return t, ok
+}
+
+// URL returns the base URL of the target named name, or fallback when there
+// is no such target.
+func (r *Registry) URL(name, fallback string) string {
+	if t, ok := r.targets[name]; ok {
+		return t.URL
+	}
+	return fallback
+}
+
+// SetClock replaces the clock breakers time their cooldown with. Clients
+// already built keep the clock they were built with.
+func (r *Registry) SetClock(clk clock.Clock) {
+	r.clock = clk
+}
+
+// Client returns the client of the target named name; unknown targets get a
+// plain client over the registry's base transport.
+func (r *Registry) Client(name string) *http.Client {
+	c, ok := r.clients[name]
+	if !ok {
+		return r.base
+	}
+	c.once.Do(func() { c.client = r.targets[name].client(r.transport, r.clock) })
+	return c.client
+}
+
+func (t Target) client(base http.RoundTripper, clk clock.Clock) *http.Client {
+	limit := t.MaxResponseBytes
+	if limit == 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	transport := http.RoundTripper(&limitTransport{target: t.Name, limit: limit, next: base})
+	if t.Auth.Value != "" && (t.Auth.Header != "" || t.Auth.QueryParam != "") {
+		transport = &authTransport{next: transport, auth: t.Auth}
+	}
+	if t.Breaker.Failures > 0 {
+		transport = &breakerTransport{target: t.Name, next: transport, failures: t.Breaker.Failures, cooldown: time.Duration(t.Breaker.Cooldown), clock: clk}
+	}
+	if t.Retries > 0 {
+		transport = &retryTransport{next: transport, retries: t.Retries}
+	}
+	return &http.Client{Transport: transport, Timeout: time.Duration(t.Timeout)}
+}
@@ -0,0 +1,169 @@
+package upstream
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upstreams.json")
+	file := `[
+		{"name": "weatherapi", "url": "https://weather.example/v1/", "timeout": "2s", "retries": 1,
+		 "breaker": {"failures": 5, "cooldown": "30s"},
+		 "auth": {"query_param": "key", "value_env": "WEATHER_KEY"}},
+		{"name": "geocoder", "url": "https://geo.example"}
+	]`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"UPSTREAMS_FILE": path, "WEATHER_KEY": "secret"}
+
+	cfg, err := Load(func(k string) string { return env[k] },
+		Target{Name: "weatherapi", URL: "https://api.weatherapi.com/v1"},
+		Target{Name: "viacep", URL: "https://viacep.com.br"},
+		Target{Name: "service-b"},
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	weather := cfg["weatherapi"]
+	if weather.URL != "https://weather.example/v1" || weather.Timeout != Duration(2*time.Second) || weather.Auth.Value != "secret" {
+		t.Errorf("weatherapi = %+v, want the file entry with its key resolved", weather)
+	}
+	if cfg["viacep"].URL != "https://viacep.com.br" || cfg["geocoder"].URL != "https://geo.example" {
+		t.Errorf("Load() = %+v, want defaults merged with new targets", cfg)
+	}
+	if _, ok := cfg["service-b"]; ok {
+		t.Errorf("Load() kept default service-b without a URL")
+	}
+
+	for _, invalid := range []Target{
+		{Name: "relative", URL: "/api"},
+		{Name: "negative", URL: "https://x.example", Retries: -1},
+		{Name: "both", URL: "https://x.example", Auth: Auth{Header: "X-Key", QueryParam: "key"}},
+	} {
+		if _, err := Load(func(string) string { return "" }, invalid); err == nil {
+			t.Errorf("Load(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestClientRetriesAndAuth(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			t.Errorf("X-API-Key = %q, want secret", r.Header.Get("X-API-Key"))
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewRegistry(Config{"svc": {Name: "svc", URL: srv.URL, Retries: 2, Auth: Auth{Header: "X-API-Key", Value: "secret"}}}, nil)
+	resp, err := r.Client("svc").Get(r.URL("svc", "") + "/ping")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("got status %d after %d calls, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := NewRegistry(Config{"svc": {Name: "svc", URL: srv.URL, Breaker: Breaker{Failures: 2, Cooldown: Duration(time.Minute)}}}, nil)
+	r.SetClock(clk)
+	client := r.Client("svc")
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("call %d error = %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("third call error = %v, want ErrBreakerOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("upstream called %d times, want 2", calls.Load())
+	}
+
+	// Once the cooldown is over a call goes through, and its failure reopens
+	// the breaker
+	clk.Advance(time.Minute)
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("call after the cooldown error = %v", err)
+	}
+	resp.Body.Close()
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("call after a failed retry error = %v, want ErrBreakerOpen", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("upstream called %d times, want 3", calls.Load())
+	}
+}
+
+func TestClientResponseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		chunked bool
+		wantErr bool
+	}{
+		{"within limit", 16, false, false},
+		{"declared too large", 17, false, true},
+		{"streamed within limit", 16, true, false},
+		{"streamed too large", 17, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := strings.Repeat("x", tt.size)
+				if !tt.chunked {
+					w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				}
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+
+			r := NewRegistry(Config{"svc": {Name: "svc", URL: srv.URL, Retries: 1, MaxResponseBytes: 16}}, nil)
+			resp, err := r.Client("svc").Get(srv.URL)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+
+			var tooLarge *ResponseTooLargeError
+			if got := errors.As(err, &tooLarge); got != tt.wantErr {
+				t.Fatalf("error = %v, want ResponseTooLargeError: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (tooLarge.Target != "svc" || tooLarge.Limit != 16) {
+				t.Errorf("error = %+v, want target svc and limit 16", tooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+const (
+	defaultResponseCacheTTL = 30 * time.Second
+	maxResponseCacheEntries = 1000
+)
+
+// responseCache guarda as respostas de sucesso do serviço B por CEP, Accept e
+// chave do cliente, para que requisições repetidas dentro do TTL nem cheguem
+// ao serviço B.
+type responseCache struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	response  serviceBResponse
+	expiresAt time.Time
+}
+
+var cepCache *responseCache
+
+func newResponseCache(ttl time.Duration, clk clock.Clock) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		clock:   clk,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+func (c *responseCache) get(key string) (serviceBResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return serviceBResponse{}, false
+	}
+	if c.clock.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return serviceBResponse{}, false
+	}
+	return entry.response, true
+}
+
+// set guarda apenas respostas 200 não degradadas; erros e fallbacks do
+// serviço B devem ser refeitos na próxima requisição.
+func (c *responseCache) set(key string, resp serviceBResponse) {
+	if resp.StatusCode != http.StatusOK || resp.Degraded != "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if len(c.entries) >= maxResponseCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// Sem entradas expiradas, descarta uma qualquer para manter o cache pequeno
+	for k := range c.entries {
+		if len(c.entries) < maxResponseCacheEntries {
+			break
+		}
+		delete(c.entries, k)
+	}
+
+	c.entries[key] = cachedResponse{response: resp, expiresAt: now.Add(c.ttl)}
+}
+
+// bypassCache indica se o cliente pediu uma resposta nova com
+// Cache-Control: no-cache (ou no-store); a resposta nova ainda atualiza o cache.
+func bypassCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return true
+		}
+	}
+	return r.Header.Get("Pragma") == "no-cache"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func TestResponseCache(t *testing.T) {
+	ok := serviceBResponse{StatusCode: http.StatusOK, Body: []byte(`{"temp_C":20}`)}
+	tests := []struct {
+		name    string
+		resp    serviceBResponse
+		age     time.Duration
+		wantHit bool
+	}{
+		{"fresh", ok, 0, true},
+		{"expired", ok, time.Minute + time.Second, false},
+		{"error not stored", serviceBResponse{StatusCode: http.StatusInternalServerError}, 0, false},
+		{"degraded not stored", serviceBResponse{StatusCode: http.StatusOK, Degraded: "city_from_cache"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			c := newResponseCache(time.Minute, fake)
+			c.set("01001000|", tt.resp)
+			fake.Advance(tt.age)
+
+			got, hit := c.get("01001000|")
+			if hit != tt.wantHit {
+				t.Fatalf("get() hit = %t, want %t", hit, tt.wantHit)
+			}
+			if hit && string(got.Body) != string(tt.resp.Body) {
+				t.Errorf("get() body = %s, want %s", got.Body, tt.resp.Body)
+			}
+			if !hit && len(c.entries) != 0 {
+				t.Errorf("%d entries left, want expired and uncacheable ones dropped", len(c.entries))
+			}
+		})
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	ok := serviceBResponse{StatusCode: http.StatusOK}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := newResponseCache(time.Minute, fake)
+	c.set("0", ok)
+	fake.Advance(time.Minute + time.Second)
+	for i := 1; i < maxResponseCacheEntries; i++ {
+		c.set(fmt.Sprint(i), ok)
+	}
+
+	// The expired entry makes room for the new one
+	c.set("new", ok)
+	if len(c.entries) != maxResponseCacheEntries {
+		t.Errorf("%d entries, want %d", len(c.entries), maxResponseCacheEntries)
+	}
+	if _, hit := c.get("0"); hit {
+		t.Error("expired entry kept")
+	}
+	if _, hit := c.get("1"); !hit {
+		t.Error("live entry evicted while an expired one was there")
+	}
+
+	// Without expired entries any entry is dropped to keep the bound
+	c.set("newer", ok)
+	if len(c.entries) != maxResponseCacheEntries {
+		t.Errorf("%d entries, want %d", len(c.entries), maxResponseCacheEntries)
+	}
+	if _, hit := c.get("newer"); !hit {
+		t.Error("new entry not stored")
+	}
+}
+
+func TestBypassCache(t *testing.T) {
+	tests := []struct {
+		header, value string
+		want          bool
+	}{
+		{"", "", false},
+		{"Cache-Control", "no-cache", true},
+		{"Cache-Control", "max-age=0, No-Store", true},
+		{"Cache-Control", "max-age=60", false},
+		{"Pragma", "no-cache", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		if got := bypassCache(r); got != tt.want {
+			t.Errorf("bypassCache(%s: %q) = %t, want %t", tt.header, tt.value, got, tt.want)
+		}
+	}
+}
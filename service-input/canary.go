@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// canaryTarget é o nome da instância canário no UPSTREAMS_FILE.
+	canaryTarget = "service-orchestration-canary"
+
+	canaryTimeout        = 5 * time.Second
+	maxCanaryComparisons = 10
+	maxCanaryValueLength = 256
+)
+
+var canaryComparisons, _ = otel.Meter("service-input").Int64Counter(
+	"input.canary.comparisons",
+	metric.WithDescription("Number of responses compared with the canary, by result (match, mismatch, error or skipped)"),
+)
+
+var canaryMismatches, _ = otel.Meter("service-input").Int64Counter(
+	"input.canary.mismatches",
+	metric.WithDescription("Number of response fields that differed between service B and the canary, by field"),
+)
+
+// canaryDiffer repete uma porcentagem das requisições na instância canário do
+// serviço B e compara as respostas em segundo plano, sem afetar a resposta
+// entregue ao cliente. rand decide quais requisições são comparadas.
+type canaryDiffer struct {
+	client  *http.Client
+	url     string
+	percent float64
+	rand    func() float64
+	slots   chan struct{}
+	// tasks roda as comparações; o Shutdown dele espera as que estão em
+	// andamento
+	tasks *supervisor.Supervisor
+}
+
+var canary *canaryDiffer
+
+func newCanaryDiffer(client *http.Client, url string, percent float64, rand func() float64, tasks *supervisor.Supervisor) *canaryDiffer {
+	return &canaryDiffer{
+		client:  client,
+		url:     url,
+		percent: percent,
+		rand:    rand,
+		slots:   make(chan struct{}, maxCanaryComparisons),
+		tasks:   tasks,
+	}
+}
+
+// parseCanaryPercent lê CANARY_DIFF_PERCENT (0 a 100); vazio desabilita.
+func parseCanaryPercent(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || p < 0 || p > 100 {
+		return 0, fmt.Errorf("invalid CANARY_DIFF_PERCENT %q: expected a number from 0 to 100", value)
+	}
+	return p, nil
+}
+
+// maybeCompare sorteia a requisição e, se escolhida, compara primary com a
+// resposta do canário. Com muitas comparações em andamento a requisição é
+// ignorada, para que o canário nunca acumule trabalho.
+func (c *canaryDiffer) maybeCompare(ctx context.Context, code postalCode, accept, apiKey string, primary serviceBResponse) {
+	if c.rand()*100 >= c.percent {
+		return
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		canaryComparisons.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "skipped")))
+		return
+	}
+
+	c.tasks.Spawn(context.WithoutCancel(ctx), "canary-diff", func(ctx context.Context) {
+		defer func() { <-c.slots }()
+		c.compare(ctx, code, accept, apiKey, primary)
+	})
+}
+
+func (c *canaryDiffer) compare(ctx context.Context, code postalCode, accept, apiKey string, primary serviceBResponse) {
+	ctx, cancel := context.WithTimeout(ctx, canaryTimeout)
+	defer cancel()
+
+	tracer := otel.Tracer("service-input-tracer")
+	telemetry.WithSpan(ctx, tracer, "canary-diff", telemetry.ErrorStatus, func(ctx context.Context, span trace.Span) error {
+		candidate, err := callBackend(ctx, span, c.client, c.url, code, accept, apiKey)
+		if err != nil {
+			canaryComparisons.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error")))
+			return err
+		}
+
+		mismatches := diffResponses(primary, candidate)
+		result := "match"
+		if len(mismatches) > 0 {
+			result = "mismatch"
+		}
+		canaryComparisons.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+		span.SetAttributes(attribute.String("canary.result", result), attribute.Int("canary.mismatches", len(mismatches)))
+
+		for _, m := range mismatches {
+			canaryMismatches.Add(ctx, 1, metric.WithAttributes(attribute.String("field", m.Field)))
+			span.AddEvent("canary.mismatch", trace.WithAttributes(
+				attribute.String("field", m.Field),
+				attribute.String("primary", m.Primary),
+				attribute.String("canary", m.Canary),
+			))
+		}
+		if len(mismatches) > 0 {
+			slog.WarnContext(ctx, "Canary response differs", "cep", code.Code, "country", code.Country, "fields", len(mismatches))
+		}
+		return nil
+	})
+}
+
+type canaryMismatch struct {
+	Field   string
+	Primary string
+	Canary  string
+}
+
+// diffResponses compara o status e os campos JSON das duas respostas. Campos
+// aninhados aparecem com o caminho separado por ponto (ex: "alerts.0.event").
+func diffResponses(primary, canary serviceBResponse) []canaryMismatch {
+	var mismatches []canaryMismatch
+	if primary.StatusCode != canary.StatusCode {
+		mismatches = append(mismatches, canaryMismatch{
+			Field:   "status",
+			Primary: strconv.Itoa(primary.StatusCode),
+			Canary:  strconv.Itoa(canary.StatusCode),
+		})
+	}
+
+	var a, b any
+	errA := json.Unmarshal(primary.Body, &a)
+	errB := json.Unmarshal(canary.Body, &b)
+	if errA != nil || errB != nil {
+		if !bytes.Equal(primary.Body, canary.Body) {
+			mismatches = append(mismatches, canaryMismatch{Field: "body", Primary: truncate(string(primary.Body)), Canary: truncate(string(canary.Body))})
+		}
+		return mismatches
+	}
+	return diffJSON("", a, b, mismatches)
+}
+
+func diffJSON(path string, a, b any, mismatches []canaryMismatch) []canaryMismatch {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			keys := make(map[string]bool)
+			for k := range av {
+				keys[k] = true
+			}
+			for k := range bv {
+				keys[k] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				mismatches = diffJSON(joinPath(path, k), av[k], bv[k], mismatches)
+			}
+			return mismatches
+		}
+	case []any:
+		if bv, ok := b.([]any); ok && len(av) == len(bv) {
+			for i := range av {
+				mismatches = diffJSON(joinPath(path, strconv.Itoa(i)), av[i], bv[i], mismatches)
+			}
+			return mismatches
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		mismatches = append(mismatches, canaryMismatch{Field: path, Primary: jsonValue(a), Canary: jsonValue(b)})
+	}
+	return mismatches
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func jsonValue(v any) string {
+	if v == nil {
+		return "<missing>"
+	}
+	data, _ := json.Marshal(v)
+	return truncate(string(data))
+}
+
+func truncate(s string) string {
+	if len(s) > maxCanaryValueLength {
+		return truncateUTF8(s, maxCanaryValueLength) + "..."
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffResponses(t *testing.T) {
+	response := func(status int, body string) serviceBResponse {
+		return serviceBResponse{StatusCode: status, Body: []byte(body)}
+	}
+	tests := []struct {
+		name            string
+		primary, canary serviceBResponse
+		want            []canaryMismatch
+	}{
+		{
+			name:    "equal",
+			primary: response(200, `{"city":"São Paulo","temp_C":20}`),
+			canary:  response(200, `{"temp_C":20,"city":"São Paulo"}`),
+		},
+		{
+			name:    "status and field",
+			primary: response(200, `{"temp_C":20}`),
+			canary:  response(500, `{"temp_C":21}`),
+			want: []canaryMismatch{
+				{Field: "status", Primary: "200", Canary: "500"},
+				{Field: "temp_C", Primary: "20", Canary: "21"},
+			},
+		},
+		{
+			name:    "missing field",
+			primary: response(200, `{"city":"São Paulo"}`),
+			canary:  response(200, `{"city":"São Paulo","region":"Sudeste"}`),
+			want:    []canaryMismatch{{Field: "region", Primary: "<missing>", Canary: `"Sudeste"`}},
+		},
+		{
+			name:    "nested array",
+			primary: response(200, `{"alerts":[{"event":"Storm"}]}`),
+			canary:  response(200, `{"alerts":[{"event":"Flood"}]}`),
+			want:    []canaryMismatch{{Field: "alerts.0.event", Primary: `"Storm"`, Canary: `"Flood"`}},
+		},
+		{
+			name:    "array length",
+			primary: response(200, `{"alerts":[]}`),
+			canary:  response(200, `{"alerts":[{"event":"Storm"}]}`),
+			want:    []canaryMismatch{{Field: "alerts", Primary: `[]`, Canary: `[{"event":"Storm"}]`}},
+		},
+		{
+			name:    "plain text",
+			primary: response(404, "can not find zipcode"),
+			canary:  response(404, "not found"),
+			want:    []canaryMismatch{{Field: "body", Primary: "can not find zipcode", Canary: "not found"}},
+		},
+		{
+			name:    "equal plain text",
+			primary: response(404, "can not find zipcode"),
+			canary:  response(404, "can not find zipcode"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffResponses(tt.primary, tt.canary); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffResponses() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffResponsesTruncates(t *testing.T) {
+	long := strings.Repeat("a", maxCanaryValueLength+10)
+	got := diffResponses(
+		serviceBResponse{StatusCode: 200, Body: []byte(long)},
+		serviceBResponse{StatusCode: 200, Body: []byte("b")},
+	)
+	if len(got) != 1 || got[0].Primary != long[:maxCanaryValueLength]+"..." {
+		t.Errorf("diffResponses() = %+v, want the primary body truncated", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/audit"
+	"github.com/fhsmendes/open-telemetry/pkg/selfcheck"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+)
+
+// runCheck valida o que o serviço precisa para subir, sem atender tráfego, e
+// retorna o código de saída do processo. O serviço B só é chamado com probe.
+func runCheck(probe bool) int {
+	checks := []selfcheck.Check{
+		{Name: "config", Run: func(ctx context.Context) error {
+			_, err := loadConfig()
+			return err
+		}},
+		{Name: "audit log", Run: func(ctx context.Context) error {
+			auditLog, err := audit.NewLogger(os.Getenv("AUDIT_LOG_PATH"))
+			if err != nil {
+				return err
+			}
+			return auditLog.Close()
+		}},
+	}
+
+	if os.Getenv("TRACING_ENABLED") != "false" {
+		checks = append(checks, selfcheck.Check{Name: "collector", Run: func(ctx context.Context) error {
+			return telemetry.CheckCollectors(ctx, os.Getenv)
+		}})
+	} else {
+		checks = append(checks, selfcheck.Check{Name: "collector", Skip: "tracing disabled"})
+	}
+
+	if probe {
+		checks = append(checks, selfcheck.Check{Name: "service-orchestration", Run: probeServiceB})
+	} else {
+		checks = append(checks, selfcheck.Check{Name: "service-orchestration", Skip: "run with --probe to call it"})
+	}
+
+	if !selfcheck.Run(context.Background(), os.Stdout, 5*time.Second, checks) {
+		fmt.Println("self-check failed")
+		return 1
+	}
+	fmt.Println("self-check passed")
+	return 0
+}
+
+// probeServiceB consulta o /readyz do serviço B em vez de /temperature para
+// não gastar cota dos provedores dele.
+func probeServiceB(ctx context.Context) error {
+	upstreams, err := loadUpstreams(os.Getenv)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstreams[serviceBTarget].URL+"/readyz", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := serviceBClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("readyz returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// serviceBTarget é o nome do serviço B no UPSTREAMS_FILE.
+const serviceBTarget = "service-orchestration"
+
+// serviceBURL é a URL base do serviço B, validada em main por parseServiceBURL.
+var serviceBURL string
+
+// serviceBClient é compartilhado entre as requisições para reaproveitar conexões
+// com o serviço B em vez de abrir uma nova conexão TCP a cada chamada. Em main,
+// ganha os retries, o breaker e a autenticação do destino do serviço B.
+var serviceBClient = &http.Client{Transport: newServiceBTransport(os.Getenv("SERVICE_B_H2C") == "true")}
+
+// newServiceBTransport cria um transport ajustado para o serviço B. Com h2c
+// habilitado, usa HTTP/2 sem TLS (prior knowledge) e multiplexa as requisições
+// em uma única conexão.
+func newServiceBTransport(h2c bool) *http.Transport {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	if h2c {
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = &protocols
+	}
+
+	return transport
+}
+
+// isUnreachable indica se a chamada ao serviço B falhou antes de conectar
+// (DNS, conexão recusada, timeout de dial), o que geralmente aponta para um
+// SERVICE_B_URL errado e não para uma falha do serviço B.
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
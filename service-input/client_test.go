@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestIsUnreachable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: dial, want: true},
+		{name: "dns", err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "service-b", IsNotFound: true}}, want: true},
+		{name: "wrapped by the client", err: &url.Error{Op: "Post", URL: "http://service-b:8080", Err: dial}, want: true},
+		{name: "wrapped with context", err: fmt.Errorf("calling service B: %w", dial), want: true},
+		{name: "read", err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: false},
+		{name: "plain", err: errors.New("unexpected EOF"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUnreachable(tt.err); got != tt.want {
+				t.Errorf("isUnreachable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUnreachableClosedPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := &http.Client{Transport: newServiceBTransport(false)}
+	_, err = client.Get("http://" + addr)
+	if err == nil || !isUnreachable(err) {
+		t.Errorf("isUnreachable(%v) = false, want true for a closed port", err)
+	}
+}
@@ -0,0 +1,298 @@
+// Command loadgen gera carga contra o POST /temperature do service-input para
+// testes de capacidade. Cada requisição vira um span de cliente exportado para
+// o mesmo collector dos serviços, então os traces da carga aparecem completos,
+// do loadgen até as APIs externas. O exporter usa as mesmas variáveis
+// OTEL_EXPORTER_OTLP_* dos serviços; para um collector local sem TLS, defina
+// OTEL_EXPORTER_OTLP_INSECURE=true.
+//
+// Uso:
+//
+//	OTEL_EXPORTER_OTLP_INSECURE=true go run ./cmd/loadgen -url http://localhost:8080/temperature -rps 50 -duration 1m \
+//		-ceps "01001000=5,20040002=3,30130010=1"
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const defaultCEPs = "01001000=5,20040002=3,30130010=2,40010000=1,99999999=1"
+
+func main() {
+	target := flag.String("url", "http://localhost:8080/temperature", "URL do POST /temperature")
+	rps := flag.Float64("rps", 10, "requisições por segundo")
+	duration := flag.Duration("duration", 30*time.Second, "duração do teste")
+	cepSpec := flag.String("ceps", defaultCEPs, "distribuição de CEPs no formato cep=peso,cep=peso")
+	maxInFlight := flag.Int("max-in-flight", 200, "limite de requisições simultâneas; acima dele as requisições são descartadas")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout de cada requisição")
+	collector := flag.String("collector", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "endpoint OTLP gRPC; vazio não exporta spans")
+	flag.Parse()
+
+	if *rps <= 0 || *duration <= 0 || *maxInFlight <= 0 {
+		log.Fatal("rps, duration e max-in-flight devem ser positivos")
+	}
+	ceps, err := parseDistribution(*cepSpec)
+	if err != nil {
+		log.Fatalf("invalid -ceps: %v", err)
+	}
+
+	shutdown, err := initTracing(*collector)
+	if err != nil {
+		log.Fatalf("failed to init tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Printf("failed to flush spans: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	client := &http.Client{Timeout: *timeout}
+	tracer := otel.Tracer("loadgen")
+	results := &results{statuses: make(map[string]int)}
+
+	fmt.Printf("Gerando %.1f req/s contra %s por %s\n", *rps, *target, *duration)
+
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, *maxInFlight)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rps))
+	defer ticker.Stop()
+
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			results.drop()
+			continue
+		}
+
+		wg.Add(1)
+		go func(cep string) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			latency, status := send(context.Background(), client, tracer, *target, cep)
+			results.add(latency, status)
+		}(ceps.pick())
+	}
+	wg.Wait()
+
+	results.print(os.Stdout, time.Since(start))
+}
+
+// send faz uma requisição dentro de um span de cliente e propaga o contexto
+// de trace para o service-input.
+func send(ctx context.Context, client *http.Client, tracer trace.Tracer, target, cep string) (time.Duration, string) {
+	ctx, span := tracer.Start(ctx, "loadgen-request", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(attribute.String("cep", cep), attribute.String("http.url", target))
+
+	body, _ := json.Marshal(map[string]string{"cep": cep})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create request")
+		return 0, "error"
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "request failed")
+		return time.Since(start), "error"
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return latency, strconv.Itoa(resp.StatusCode)
+}
+
+func initTracing(collector string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if collector == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterConfig, err := telemetry.LoadExporterConfig(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(collector, exporterConfig.DialOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceName("loadgen")))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		conn.Close()
+		return err
+	}, nil
+}
+
+// distribution sorteia CEPs proporcionalmente aos pesos configurados.
+type distribution struct {
+	ceps    []string
+	weights []int
+	total   int
+}
+
+func parseDistribution(value string) (*distribution, error) {
+	d := &distribution{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		cep, weight := entry, 1
+		if c, w, ok := strings.Cut(entry, "="); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(w))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q: expected a positive integer", entry)
+			}
+			cep, weight = strings.TrimSpace(c), n
+		}
+
+		d.ceps = append(d.ceps, cep)
+		d.weights = append(d.weights, weight)
+		d.total += weight
+	}
+	if d.total == 0 {
+		return nil, fmt.Errorf("at least one cep is required")
+	}
+	return d, nil
+}
+
+func (d *distribution) pick() string {
+	n := rand.IntN(d.total)
+	for i, w := range d.weights {
+		if n < w {
+			return d.ceps[i]
+		}
+		n -= w
+	}
+	return d.ceps[len(d.ceps)-1]
+}
+
+type results struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[string]int
+	dropped   int
+}
+
+func (r *results) add(latency time.Duration, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[status]++
+	if status != "error" {
+		r.latencies = append(r.latencies, latency)
+	}
+}
+
+func (r *results) drop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped++
+}
+
+func (r *results) print(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sent := 0
+	statuses := make([]string, 0, len(r.statuses))
+	for status, n := range r.statuses {
+		sent += n
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	fmt.Fprintf(w, "\nRequisições: %d em %s (%.1f req/s), descartadas: %d\n", sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds(), r.dropped)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %s: %d\n", status, r.statuses[status])
+	}
+
+	if len(r.latencies) == 0 {
+		return
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	fmt.Fprintln(w, "Latência:")
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Fprintf(w, "  p%.0f: %s\n", p, percentile(r.latencies, p).Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "  max: %s\n", r.latencies[len(r.latencies)-1].Round(time.Microsecond))
+}
+
+// percentile usa o método nearest-rank sobre latências já ordenadas.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantCEPs    []string
+		wantWeights []int
+		wantErr     bool
+	}{
+		{name: "weights", value: "01001000=5, 20040002 = 3", wantCEPs: []string{"01001000", "20040002"}, wantWeights: []int{5, 3}},
+		{name: "default weight", value: "01001000,20040002=2,", wantCEPs: []string{"01001000", "20040002"}, wantWeights: []int{1, 2}},
+		{name: "zero weight", value: "01001000=0", wantErr: true},
+		{name: "negative weight", value: "01001000=-1", wantErr: true},
+		{name: "invalid weight", value: "01001000=abc", wantErr: true},
+		{name: "empty", value: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parseDistribution(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDistribution(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(d.ceps, tt.wantCEPs) || !slices.Equal(d.weights, tt.wantWeights) {
+				t.Errorf("parseDistribution(%q) = %v %v, want %v %v", tt.value, d.ceps, d.weights, tt.wantCEPs, tt.wantWeights)
+			}
+		})
+	}
+}
+
+func TestDistributionPick(t *testing.T) {
+	d, err := parseDistribution("01001000=3,20040002=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for range 1000 {
+		counts[d.pick()]++
+	}
+	if len(counts) != 2 || counts["01001000"] <= counts["20040002"] {
+		t.Errorf("pick() counts = %v, want only the configured CEPs, weighted 3:1", counts)
+	}
+
+	single, _ := parseDistribution("99999999=7")
+	for range 10 {
+		if got := single.pick(); got != "99999999" {
+			t.Fatalf("pick() = %q, want the only CEP", got)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10},
+		{10, 10},
+		{50, 50},
+		{95, 100},
+		{99, 100},
+		{100, 100},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(p%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]time.Duration{42}, 99); got != 42 {
+		t.Errorf("percentile of a single sample = %v, want 42", got)
+	}
+}
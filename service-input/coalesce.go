@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// coalescer agrupa requisições idênticas (mesmo CEP) em uma única chamada ao
+// serviço B, distribuindo a resposta para todas elas. Quem chega enquanto a
+// chamada está em andamento, ou até window depois do seu início, recebe o
+// mesmo resultado; a primeira requisição não espera a janela.
+//
+// A chamada compartilhada tem o próprio span, "coalesced-call", filho do span
+// da requisição que a iniciou; o span de cada requisição que a reaproveita
+// recebe um link para ele.
+type coalescer struct {
+	window time.Duration
+	// timeout limita a chamada compartilhada, que não é cancelada por nenhuma
+	// das requisições e não pode ficar presa a um serviço B travado.
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done   chan struct{}
+	span   trace.SpanContext
+	result serviceBResponse
+	err    error
+}
+
+var cepCoalescer *coalescer
+
+func newCoalescer(window, timeout time.Duration) *coalescer {
+	return &coalescer{
+		window:  window,
+		timeout: timeout,
+		calls:   make(map[string]*coalescedCall),
+	}
+}
+
+// Do executa fn uma única vez para as chamadas com a mesma chave e aguarda o
+// resultado até ctx ser cancelado. fn recebe um contexto próprio, limitado por
+// timeout, pois é compartilhada entre requisições, e carrega o span da chamada.
+// O retorno shared indica se o resultado veio de uma chamada iniciada por
+// outra requisição.
+func (c *coalescer) Do(ctx context.Context, key string, fn func(ctx context.Context) (serviceBResponse, error)) (serviceBResponse, bool, error) {
+	c.mu.Lock()
+	call, shared := c.calls[key]
+	if !shared {
+		callCtx, span := otel.Tracer("service-input-tracer").Start(context.WithoutCancel(ctx), "coalesced-call")
+		call = &coalescedCall{done: make(chan struct{}), span: span.SpanContext()}
+		c.calls[key] = call
+		go c.run(callCtx, span, key, call, fn)
+	}
+	c.mu.Unlock()
+
+	if shared {
+		trace.SpanFromContext(ctx).AddLink(trace.Link{SpanContext: call.span})
+	}
+
+	select {
+	case <-call.done:
+		return call.result, shared, call.err
+	case <-ctx.Done():
+		return serviceBResponse{}, shared, ctx.Err()
+	}
+}
+
+func (c *coalescer) run(ctx context.Context, span trace.Span, key string, call *coalescedCall, fn func(ctx context.Context) (serviceBResponse, error)) {
+	start := time.Now()
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	call.result, call.err = fn(callCtx)
+	cancel()
+	if call.err != nil {
+		span.RecordError(call.err)
+		span.SetStatus(codes.Error, call.err.Error())
+	}
+	span.End()
+	close(call.done)
+
+	// Erros não são compartilhados com quem chega depois; respostas, até o
+	// fim da janela
+	if remaining := c.window - time.Since(start); call.err == nil && remaining > 0 {
+		time.AfterFunc(remaining, func() { c.forget(key, call) })
+		return
+	}
+	c.forget(key, call)
+}
+
+func (c *coalescer) forget(key string, call *coalescedCall) {
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+}
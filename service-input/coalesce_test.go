@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type coalesceResult struct {
+	resp   serviceBResponse
+	shared bool
+	err    error
+}
+
+// startDo runs c.Do in the background and waits until the call of key is
+// registered, so calls started after it join it.
+func startDo(t *testing.T, ctx context.Context, c *coalescer, key string, fn func(context.Context) (serviceBResponse, error)) <-chan coalesceResult {
+	t.Helper()
+	out := make(chan coalesceResult, 1)
+	go func() {
+		resp, shared, err := c.Do(ctx, key, fn)
+		out <- coalesceResult{resp, shared, err}
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		_, ok := c.calls[key]
+		c.mu.Unlock()
+		if ok {
+			return out
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("call was never registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalescerJoin(t *testing.T) {
+	c := newCoalescer(time.Hour, time.Second)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (serviceBResponse, error) {
+		calls.Add(1)
+		<-release
+		return serviceBResponse{StatusCode: 200}, nil
+	}
+
+	leader := startDo(t, context.Background(), c, "01001000", fn)
+	follower := startDo(t, context.Background(), c, "01001000", fn)
+	close(release)
+
+	for i, got := range []coalesceResult{<-leader, <-follower} {
+		if got.err != nil || got.resp.StatusCode != 200 || got.shared != (i == 1) {
+			t.Errorf("call %d = %+v, want 200 shared only by the follower", i, got)
+		}
+	}
+	// A request arriving after the call ended, within the window, is served
+	// its result right away
+	if resp, shared, err := c.Do(context.Background(), "01001000", fn); err != nil || !shared || resp.StatusCode != 200 {
+		t.Errorf("Do() within the window = %+v, %v, %v, want the shared result", resp, shared, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("service B called %d times, want 1", n)
+	}
+}
+
+func TestCoalescerDoesNotWaitForTheWindow(t *testing.T) {
+	c := newCoalescer(time.Hour, time.Second)
+	start := time.Now()
+	c.Do(context.Background(), "01001000", func(ctx context.Context) (serviceBResponse, error) {
+		return serviceBResponse{StatusCode: 200}, nil
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() took %s, want it to return as soon as the call does", elapsed)
+	}
+}
+
+func TestCoalescerFollowerTimesOut(t *testing.T) {
+	c := newCoalescer(time.Hour, time.Second)
+	release := make(chan struct{})
+	defer close(release)
+	fn := func(ctx context.Context) (serviceBResponse, error) {
+		<-release
+		return serviceBResponse{StatusCode: 200}, nil
+	}
+
+	startDo(t, context.Background(), c, "01001000", fn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, shared, err := c.Do(ctx, "01001000", fn); !shared || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() = shared %v, %v, want the follower to give up at its deadline", shared, err)
+	}
+}
+
+func TestCoalescerBoundsTheSharedCall(t *testing.T) {
+	c := newCoalescer(time.Hour, 10*time.Millisecond)
+	_, _, err := c.Do(context.Background(), "01001000", func(ctx context.Context) (serviceBResponse, error) {
+		<-ctx.Done()
+		return serviceBResponse{}, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want the shared call to time out", err)
+	}
+}
+
+func TestCoalescerErrorFanOut(t *testing.T) {
+	c := newCoalescer(time.Hour, time.Second)
+	unavailable := errors.New("service B unavailable")
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (serviceBResponse, error) {
+		calls.Add(1)
+		<-release
+		return serviceBResponse{}, unavailable
+	}
+
+	leader := startDo(t, context.Background(), c, "01001000", fn)
+	follower := startDo(t, context.Background(), c, "01001000", fn)
+	// Gives the follower time to join before the error, which is not kept
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i, got := range []coalesceResult{<-leader, <-follower} {
+		if !errors.Is(got.err, unavailable) {
+			t.Errorf("call %d error = %v, want the shared error", i, got.err)
+		}
+	}
+
+	// Errors are not kept for the window: the next request calls again
+	c.Do(context.Background(), "01001000", fn)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("service B called %d times, want 2", n)
+	}
+}
+
+func TestCoalescerSpans(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	c := newCoalescer(time.Hour, time.Second)
+	release := make(chan struct{})
+	var callSpan trace.SpanContext
+	fn := func(ctx context.Context) (serviceBResponse, error) {
+		callSpan = trace.SpanContextFromContext(ctx)
+		<-release
+		return serviceBResponse{StatusCode: 200}, nil
+	}
+
+	leaderCtx, leaderSpan := tp.Tracer("test").Start(context.Background(), "leader")
+	followerCtx, followerSpan := tp.Tracer("test").Start(context.Background(), "follower")
+	leader := startDo(t, leaderCtx, c, "01001000", fn)
+	follower := startDo(t, followerCtx, c, "01001000", fn)
+	close(release)
+	<-leader
+	<-follower
+	leaderSpan.End()
+	followerSpan.End()
+
+	var shared sdktrace.ReadOnlySpan
+	for _, s := range spans.Ended() {
+		if s.Name() == "coalesced-call" {
+			shared = s
+		}
+	}
+	if shared == nil {
+		t.Fatal("no coalesced-call span was recorded")
+	}
+	if shared.SpanContext().SpanID() != callSpan.SpanID() {
+		t.Errorf("fn ran under span %s, want the coalesced-call span %s", callSpan.SpanID(), shared.SpanContext().SpanID())
+	}
+	if shared.Parent().SpanID() != leaderSpan.SpanContext().SpanID() {
+		t.Errorf("coalesced-call parent = %s, want the leader span", shared.Parent().SpanID())
+	}
+
+	for _, s := range spans.Ended() {
+		if s.Name() != "follower" {
+			continue
+		}
+		if links := s.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != shared.SpanContext().SpanID() {
+			t.Errorf("follower links = %+v, want one link to the coalesced-call span", links)
+		}
+	}
+}
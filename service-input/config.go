@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/attrfilter"
+	"github.com/fhsmendes/open-telemetry/pkg/cassette"
+	"github.com/fhsmendes/open-telemetry/pkg/configdump"
+	"github.com/fhsmendes/open-telemetry/pkg/deprecation"
+	"github.com/fhsmendes/open-telemetry/pkg/maintenance"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"github.com/fhsmendes/open-telemetry/pkg/spillover"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+)
+
+const (
+	defaultTimeout     = 60 * time.Second
+	defaultCassetteDir = "testdata/cassettes"
+)
+
+// config reúne as variáveis de ambiente do serviço já validadas. O main e o
+// --check usam o mesmo loadConfig, para que o check recuse exatamente o que
+// impediria o serviço de subir.
+type config struct {
+	Port     string
+	BasePath string
+	TLS      serve.TLSConfig
+	Timeouts timeoutConfig
+
+	// ServiceBURL é a URL do serviço B em Upstreams, já validada.
+	Upstreams   upstream.Config
+	ServiceBURL string
+	// CanaryPercent é a fração das respostas comparadas com o canário; zero
+	// desabilita. MirrorPercent e MirrorRate só valem com um destino de
+	// espelhamento.
+	CanaryPercent float64
+	MirrorPercent float64
+	MirrorRate    float64
+
+	// ResponseCacheTTL zero desabilita o cache de respostas, e
+	// CoalesceWindow zero desabilita o agrupamento de requisições.
+	ResponseCacheTTL time.Duration
+	CoalesceWindow   time.Duration
+	// Signer assina as respostas; nil quando não há RESPONSE_SIGNING_KEYS.
+	Signer         *responseSigner
+	LegacyRequests bool
+
+	CassetteMode cassette.Mode
+	CassetteDir  string
+
+	TrustedProxies  []netip.Prefix
+	TrustAllProxies bool
+	RateLimit       float64
+	RateBurst       int
+	// AdminAPIKeys abrem o /drain e o /admin/*; sem nenhuma, essas rotas
+	// recusam todas as requisições.
+	AdminAPIKeys middleware.APIKeys
+
+	Maintenance  maintenance.State
+	Deprecations deprecation.Config
+	AuditLogPath string
+}
+
+// loadConfig lê e valida a configuração do serviço. Cada grupo de variáveis é
+// documentado na função que o lê.
+func loadConfig() (config, error) {
+	cfg := config{
+		Port:             os.Getenv("PORT"),
+		ResponseCacheTTL: defaultResponseCacheTTL,
+		LegacyRequests:   os.Getenv("LEGACY_REQUEST_FORMATS") == "true",
+		CassetteDir:      os.Getenv("HTTP_CASSETTE_DIR"),
+		AuditLogPath:     os.Getenv("AUDIT_LOG_PATH"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.CassetteDir == "" {
+		cfg.CassetteDir = defaultCassetteDir
+	}
+
+	var err error
+	if cfg.Timeouts, err = loadTimeoutConfig(); err != nil {
+		return config{}, err
+	}
+	if v := os.Getenv("RESPONSE_CACHE_TTL"); v != "" {
+		if cfg.ResponseCacheTTL, err = time.ParseDuration(v); err != nil {
+			return config{}, fmt.Errorf("invalid RESPONSE_CACHE_TTL: %w", err)
+		}
+	}
+	if v := os.Getenv("COALESCE_WINDOW"); v != "" {
+		if cfg.CoalesceWindow, err = time.ParseDuration(v); err != nil {
+			return config{}, fmt.Errorf("invalid COALESCE_WINDOW: %w", err)
+		}
+	}
+	if cfg.Signer, err = parseSigningKeys(os.Getenv("RESPONSE_SIGNING_KEYS")); err != nil {
+		return config{}, fmt.Errorf("invalid RESPONSE_SIGNING_KEYS: %w", err)
+	}
+	if cfg.CassetteMode, err = cassette.ParseMode(os.Getenv("HTTP_CASSETTE_MODE")); err != nil {
+		return config{}, fmt.Errorf("invalid HTTP_CASSETTE_MODE: %w", err)
+	}
+
+	if cfg.Upstreams, err = loadUpstreams(os.Getenv); err != nil {
+		return config{}, err
+	}
+	if cfg.ServiceBURL, err = parseServiceBURL(cfg.Upstreams[serviceBTarget].URL); err != nil {
+		return config{}, err
+	}
+	if cfg.CanaryPercent, err = parseCanaryPercent(os.Getenv("CANARY_DIFF_PERCENT")); err != nil {
+		return config{}, err
+	}
+	if _, ok := cfg.Upstreams[canaryTarget]; cfg.CanaryPercent > 0 && !ok {
+		return config{}, errors.New("CANARY_DIFF_PERCENT is set but neither CANARY_URL nor UPSTREAMS_FILE has a " + canaryTarget + " target")
+	}
+	if _, ok := cfg.Upstreams[mirrorTarget]; ok {
+		if cfg.MirrorPercent, cfg.MirrorRate, err = parseMirrorConfig(os.Getenv); err != nil {
+			return config{}, err
+		}
+	}
+
+	if cfg.TrustedProxies, cfg.TrustAllProxies, err = middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return config{}, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	if cfg.RateLimit, cfg.RateBurst, err = middleware.ParseRateLimit(os.Getenv("RATE_LIMIT"), os.Getenv("RATE_LIMIT_BURST")); err != nil {
+		return config{}, err
+	}
+	if cfg.AdminAPIKeys, err = middleware.ParseAPIKeys(os.Getenv("ADMIN_API_KEYS")); err != nil {
+		return config{}, fmt.Errorf("invalid ADMIN_API_KEYS: %w", err)
+	}
+	if cfg.TLS, err = serve.LoadTLSConfig(os.Getenv); err != nil {
+		return config{}, fmt.Errorf("invalid TLS config: %w", err)
+	}
+	if cfg.BasePath, err = serve.ParseBasePath(os.Getenv("BASE_PATH")); err != nil {
+		return config{}, err
+	}
+	if cfg.Maintenance, err = maintenance.LoadState(os.Getenv); err != nil {
+		return config{}, fmt.Errorf("invalid maintenance config: %w", err)
+	}
+	if cfg.Deprecations, err = deprecation.LoadConfig(os.Getenv); err != nil {
+		return config{}, fmt.Errorf("invalid deprecation config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Timeouts padrão por rota; devem ser maiores que os do serviço B para que o
+// 504 dele seja repassado ao cliente.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/temperature": 5 * time.Second,
+}
+
+type timeoutConfig struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// loadTimeoutConfig lê HTTP_TIMEOUT (ex: "60s") e ROUTE_TIMEOUTS
+// (ex: "/temperature=2s,/temperature/batch=10s") das variáveis de ambiente.
+func loadTimeoutConfig() (timeoutConfig, error) {
+	cfg := timeoutConfig{
+		Default: defaultTimeout,
+		Routes:  make(map[string]time.Duration),
+	}
+	for route, d := range defaultRouteTimeouts {
+		cfg.Routes[route] = d
+	}
+
+	if v := os.Getenv("HTTP_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return timeoutConfig{}, fmt.Errorf("invalid HTTP_TIMEOUT: %w", err)
+		}
+		cfg.Default = d
+	}
+
+	for _, entry := range strings.Split(os.Getenv("ROUTE_TIMEOUTS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, timeout, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(route) == "" {
+			return timeoutConfig{}, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: expected route=duration", entry)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(timeout))
+		if err != nil {
+			return timeoutConfig{}, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: %w", entry, err)
+		}
+		if d <= 0 {
+			return timeoutConfig{}, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: duration must be positive", entry)
+		}
+
+		cfg.Routes[strings.TrimSpace(route)] = d
+	}
+
+	return cfg, nil
+}
+
+// loadUpstreams lê os destinos do UPSTREAMS_FILE; sem ele, o serviço B vem
+// de SERVICE_B_URL, o canário de CANARY_URL e o staging de MIRROR_URL.
+func loadUpstreams(getenv func(string) string) (upstream.Config, error) {
+	return upstream.Load(getenv,
+		upstream.Target{Name: serviceBTarget, URL: getenv("SERVICE_B_URL")},
+		upstream.Target{Name: canaryTarget, URL: getenv("CANARY_URL")},
+		upstream.Target{Name: mirrorTarget, URL: getenv("MIRROR_URL")},
+	)
+}
+
+// parseServiceBURL valida a URL do serviço B na inicialização, para que um
+// deploy sem ela falhe ao subir em vez de no meio de uma requisição.
+func parseServiceBURL(value string) (string, error) {
+	if value == "" {
+		return "", errors.New("SERVICE_B_URL environment variable not set and UPSTREAMS_FILE has no " + serviceBTarget + " target")
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid SERVICE_B_URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid SERVICE_B_URL %q: expected an absolute http or https URL", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+func (c timeoutConfig) timeoutFor(route string) time.Duration {
+	if d, ok := c.Routes[route]; ok {
+		return d
+	}
+	return c.Default
+}
+
+// configVars lista as variáveis de ambiente lidas pelo serviço, com o valor
+// usado quando não estão definidas, para o /admin/config.
+var configVars = []configdump.Var{
+	{Name: "PORT", Default: "8080"},
+	{Name: "BASE_PATH"},
+	{Name: "HTTP_TIMEOUT", Default: defaultTimeout.String()},
+	{Name: "ROUTE_TIMEOUTS", Default: "/temperature=" + defaultRouteTimeouts["/temperature"].String()},
+	{Name: "SERVICE_B_URL"},
+	{Name: "UPSTREAMS_FILE"},
+	{Name: "CANARY_URL"},
+	{Name: "CANARY_DIFF_PERCENT", Default: "0"},
+	{Name: "MIRROR_URL"},
+	{Name: "MIRROR_SAMPLE_PERCENT", Default: strconv.Itoa(defaultMirrorSamplePercent)},
+	{Name: "MIRROR_RATE_LIMIT", Default: strconv.Itoa(defaultMirrorRateLimit)},
+	{Name: "SERVICE_B_H2C", Default: "false"},
+	{Name: "COALESCE_WINDOW"},
+	{Name: "RESPONSE_CACHE_TTL", Default: defaultResponseCacheTTL.String()},
+	{Name: "LEGACY_REQUEST_FORMATS", Default: "false"},
+	{Name: "RESPONSE_SIGNING_KEYS", Secret: true},
+	{Name: "AUDIT_LOG_PATH"},
+	{Name: "HTTP_CASSETTE_MODE", Default: "off"},
+	{Name: "HTTP_CASSETTE_DIR", Default: defaultCassetteDir},
+	{Name: "TRUSTED_PROXIES"},
+	{Name: "RATE_LIMIT"},
+	{Name: "RATE_LIMIT_BURST"},
+	{Name: "ADMIN_API_KEYS", Secret: true},
+	{Name: "TLS_CERT_FILE"},
+	{Name: "TLS_KEY_FILE"},
+	{Name: "TLS_AUTOCERT_DOMAINS"},
+	{Name: "TLS_AUTOCERT_CACHE_DIR"},
+	{Name: "TLS_REDIRECT_ADDR"},
+	{Name: "TLS_PUBLIC_PORT"},
+	{Name: "MAINTENANCE_MODE", Default: "false"},
+	{Name: "MAINTENANCE_MESSAGE", Default: maintenance.DefaultMessage},
+	{Name: "MAINTENANCE_RETRY_AFTER", Default: maintenance.DefaultRetryAfter.String()},
+	{Name: "DEPRECATIONS"},
+	{Name: "DEPRECATION_LINK"},
+	{Name: "DEPRECATION_CLIENTS"},
+	{Name: "TRACING_ENABLED", Default: "true"},
+	{Name: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+	{Name: "OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS"},
+	{Name: "OTEL_EXPORTER_OTLP_FAILOVER_AFTER", Default: strconv.Itoa(telemetry.DefaultFailoverAfter)},
+	{Name: "OTEL_EXPORTER_OTLP_FAILBACK_PROBE", Default: telemetry.DefaultFailbackProbe.String()},
+	{Name: "OTEL_EXPORTER_OTLP_COMPRESSION", Default: telemetry.CompressionNone},
+	{Name: "OTEL_EXPORTER_OTLP_HEADERS", Secret: true},
+	{Name: "OTEL_EXPORTER_OTLP_INSECURE", Default: "false"},
+	{Name: "TRACE_SAMPLE_RATIO", Default: "1"},
+	{Name: "SERVICE_TEAM"},
+	{Name: "SERVICE_COST_CENTER"},
+	{Name: "SERVICE_TIER"},
+	{Name: "TRACE_BOOST_ERROR_RATE", Default: "0"},
+	{Name: "TRACE_BOOST_WINDOW", Default: telemetry.DefaultBoostWindow.String()},
+	{Name: "TRACE_BOOST_DURATION", Default: telemetry.DefaultBoostDuration.String()},
+	{Name: "TRACE_BOOST_DECAY", Default: telemetry.DefaultBoostDecay.String()},
+	{Name: "TRACE_BOOST_MIN_REQUESTS", Default: strconv.Itoa(telemetry.DefaultBoostMinRequests)},
+	{Name: "OTEL_SHUTDOWN_TIMEOUT", Default: telemetry.DefaultShutdownTimeout.String()},
+	{Name: "OTEL_SHUTDOWN_SIGNAL_TIMEOUT", Default: telemetry.DefaultSignalShutdownTimeout.String()},
+	{Name: "SPAN_SPILLOVER_PATH"},
+	{Name: "SPAN_SPILLOVER_MAX_BYTES", Default: strconv.Itoa(spillover.DefaultMaxBytes)},
+	{Name: "SPAN_ATTRIBUTE_ALLOWLIST"},
+	{Name: "SPAN_ATTRIBUTE_DENYLIST", Default: attrfilter.DefaultDeny},
+	{Name: "DEPLOYMENT_ENVIRONMENT", Default: "development"},
+}
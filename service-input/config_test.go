@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseServiceBURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "http", value: "http://service-b:8080", want: "http://service-b:8080"},
+		{name: "https", value: "https://service-b.example.com", want: "https://service-b.example.com"},
+		{name: "trailing slash", value: "http://service-b:8080/", want: "http://service-b:8080"},
+		{name: "trailing path", value: "http://service-b:8080/api/", want: "http://service-b:8080/api"},
+		{name: "empty", value: "", wantErr: true},
+		{name: "bad scheme", value: "ftp://service-b:8080", wantErr: true},
+		{name: "missing scheme", value: "service-b:8080", wantErr: true},
+		{name: "missing host", value: "http://", wantErr: true},
+		{name: "relative path", value: "/api", wantErr: true},
+		{name: "unparsable", value: "http://service-b:port", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseServiceBURL(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseServiceBURL(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseServiceBURL(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("SERVICE_B_URL", "http://service-b:8080/")
+	t.Setenv("COALESCE_WINDOW", "50ms")
+	t.Setenv("ADMIN_API_KEYS", "secret:ops")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.ServiceBURL != "http://service-b:8080" || cfg.Port != "8080" || cfg.ResponseCacheTTL != defaultResponseCacheTTL {
+		t.Errorf("loadConfig() = %+v, want the service B URL and the defaults", cfg)
+	}
+	if cfg.CoalesceWindow != 50*time.Millisecond || cfg.AdminAPIKeys["secret"] != "ops" {
+		t.Errorf("loadConfig() = %+v, want COALESCE_WINDOW and ADMIN_API_KEYS applied", cfg)
+	}
+}
+
+// O --check usa o mesmo loadConfig que o main, então cada variável que
+// impediria o serviço de subir também falha o check.
+func TestLoadConfig_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"RESPONSE_CACHE_TTL":      "soon",
+		"COALESCE_WINDOW":         "soon",
+		"ADMIN_API_KEYS":          "no-identity",
+		"BASE_PATH":               "api",
+		"MAINTENANCE_RETRY_AFTER": "-1m",
+		"CANARY_DIFF_PERCENT":     "10",
+		"HTTP_CASSETTE_MODE":      "sometimes",
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("SERVICE_B_URL", "http://service-b:8080")
+			t.Setenv(name, value)
+			if _, err := loadConfig(); err == nil {
+				t.Errorf("loadConfig() accepted %s=%q", name, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+type LifecycleStatus struct {
+	Ready    bool  `json:"ready"`
+	Draining bool  `json:"draining"`
+	InFlight int64 `json:"in_flight"`
+	Drained  bool  `json:"drained"`
+}
+
+// lifecycleManager controla a prontidão e as requisições em andamento para que
+// a plataforma possa drenar a instância antes de encerrá-la.
+type lifecycleManager struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+
+	shutdownHooks []func(ctx context.Context) error
+}
+
+func (m *lifecycleManager) startDrain() {
+	m.draining.Store(true)
+}
+
+// onShutdown registra fn para ser chamada por shutdown. Os hooks rodam na
+// ordem inversa do registro, como chamadas adiadas com defer.
+func (m *lifecycleManager) onShutdown(fn func(ctx context.Context) error) {
+	m.shutdownHooks = append(m.shutdownHooks, fn)
+}
+
+// shutdown roda todos os hooks registrados e junta os seus erros.
+func (m *lifecycleManager) shutdown(ctx context.Context) error {
+	var errs []error
+	for i := len(m.shutdownHooks) - 1; i >= 0; i-- {
+		errs = append(errs, m.shutdownHooks[i](ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *lifecycleManager) status() LifecycleStatus {
+	draining := m.draining.Load()
+	inFlight := m.inFlight.Load()
+	return LifecycleStatus{
+		Ready:    !draining,
+		Draining: draining,
+		InFlight: inFlight,
+		Drained:  draining && inFlight == 0,
+	}
+}
+
+func (m *lifecycleManager) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *lifecycleManager) handleReady(w http.ResponseWriter, r *http.Request) {
+	status := m.status()
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleHealthz indica apenas que o processo está vivo. Ao contrário do
+// /readyz, continua 200 durante a drenagem e a manutenção, para que a
+// plataforma não reinicie a instância.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleDrain desliga a prontidão no POST e informa o progresso da drenagem:
+// 202 enquanto houver requisições em andamento e 200 quando drenado.
+func (m *lifecycleManager) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		m.startDrain()
+	}
+
+	status := m.status()
+	if status.Drained {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(status)
+}
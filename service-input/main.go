@@ -2,73 +2,198 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-
+	"unicode/utf8"
+
+	"github.com/fhsmendes/open-telemetry/pkg/attrfilter"
+	"github.com/fhsmendes/open-telemetry/pkg/audit"
+	"github.com/fhsmendes/open-telemetry/pkg/buildinfo"
+	"github.com/fhsmendes/open-telemetry/pkg/cassette"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/configdump"
+	"github.com/fhsmendes/open-telemetry/pkg/deprecation"
+	"github.com/fhsmendes/open-telemetry/pkg/logging"
+	"github.com/fhsmendes/open-telemetry/pkg/maintenance"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"github.com/fhsmendes/open-telemetry/pkg/spillover"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log/global"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-func initProvider(serviceName, collectorURL string) (func(context.Context) error, error) {
+// initProvider configura os providers de telemetria e devolve o failover do
+// coletor usado pelos exportadores (nil com a telemetria desligada).
+func initProvider(serviceName string, enabled bool) (func(context.Context) []telemetry.ShutdownResult, *telemetry.Failover, error) {
+	if !enabled {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+		return func(context.Context) []telemetry.ShutdownResult { return nil }, nil, nil
+	}
+
 	ctx := context.Background()
 
+	info := buildinfo.Get()
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(info.Version),
+			semconv.DeploymentEnvironment(info.Environment),
+			attribute.String("vcs.commit", info.Commit),
 		),
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, collectorURL, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	exporterConfig, err := telemetry.LoadExporterConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	shutdownConfig, err := telemetry.LoadShutdownConfig(os.Getenv)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+		return nil, nil, err
 	}
 
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	failoverConfig, err := telemetry.LoadFailoverConfig(os.Getenv)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, nil, err
 	}
+	collector, err := telemetry.NewFailover(failoverConfig, exporterConfig.DialOptions()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := collector.Conn()
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
+	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	attrFilter, err := attrfilter.Load(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sampling, err := telemetry.LoadSamplingConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	sampler := telemetry.NewBoostSampler(sampling)
+
+	spill, err := spillover.LoadConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	spanExporter, err := spillover.Wrap(traceExporter, spill)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bsp := sdktrace.NewBatchSpanProcessor(spanExporter)
 	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSpanProcessor(bsp),
+		sdktrace.WithSpanProcessor(telemetry.NewOwnershipProcessor(telemetry.LoadOwnership(os.Getenv))),
+		sdktrace.WithSpanProcessor(attrfilter.NewProcessor(bsp, attrFilter)),
+		sdktrace.WithSpanProcessor(sampler),
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler.ParentBased()),
 	)
 	otel.SetTracerProvider(traceProvider)
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn), otlpmetricgrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create metric exporter: %w", err)
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	logExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn), otlploggrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create log exporter: %w", err)
+	}
+
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithResource(res),
+	)
+	global.SetLoggerProvider(loggerProvider)
+
+	return func(ctx context.Context) []telemetry.ShutdownResult {
+		results := telemetry.Shutdown(ctx, shutdownConfig,
+			telemetry.Signal{Name: "traces", Shutdown: traceProvider.Shutdown},
+			telemetry.Signal{Name: "metrics", Shutdown: meterProvider.Shutdown},
+			telemetry.Signal{Name: "logs", Shutdown: loggerProvider.Shutdown},
+		)
+		collector.Close()
+		return results
+	}, collector, nil
+}
+
+const maxTransactionIDLength = 128
 
-	return traceProvider.Shutdown, nil
+// truncateUTF8 corta s em no máximo n bytes sem partir um caractere UTF-8 ao
+// meio, o que deixaria o valor inválido em baggage, spans e logs.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 type CEPRequest struct {
 	CEP string `json:"cep"`
+	// Country é o país do código postal (BR, PT ou US); vazio é o Brasil.
+	Country string `json:"country,omitempty"`
 }
 
 type TemperatureResponse struct {
@@ -80,6 +205,7 @@ type TemperatureResponse struct {
 
 type ErrorResponse struct {
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 }
 
 func validateCEP(cep string) bool {
@@ -96,92 +222,225 @@ func handleCEPRequest(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tracer := otel.Tracer("service-input-tracer")
 
-	ctx, span := tracer.Start(ctx, "validate-cep")
-	defer span.End()
+	// Propaga o ID de transação do cliente (ex: ID do pedido) via baggage
+	if transactionID := r.Header.Get("X-Transaction-ID"); transactionID != "" {
+		transactionID = truncateUTF8(transactionID, maxTransactionIDLength)
+		if member, err := baggage.NewMemberRaw("transaction.id", transactionID); err == nil {
+			if bag, err := baggage.FromContext(ctx).SetMember(member); err == nil {
+				ctx = baggage.ContextWithBaggage(ctx, bag)
+			}
+		}
+	}
 
-	var req CEPRequest
+	var code postalCode
+	err := telemetry.WithSpan(ctx, tracer, "validate-cep", telemetry.ErrorStatus, func(ctx context.Context, span trace.Span) error {
+		if transactionID := baggage.FromContext(ctx).Member("transaction.id").Value(); transactionID != "" {
+			span.SetAttributes(attribute.String("transaction.id", transactionID))
+		}
 
-	// Decodifica o JSON da requisição
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		span.SetAttributes(attribute.String("error", "invalid json"))
+		// Decodifica o corpo da requisição
+		req, err := parseCEPRequest(r)
+		if err != nil {
+			span.SetAttributes(attribute.String("error", "invalid json"))
+			return errors.New("invalid json")
+		}
+
+		// Adiciona CEP e país como atributos do span
+		span.SetAttributes(attribute.String("cep", req.CEP), attribute.String("postal.country", req.Country))
+
+		// Valida e normaliza o código postal para enviar para o serviço B
+		code, err = parsePostalCode(req.Country, req.CEP)
+		switch {
+		case errors.Is(err, errUnsupportedCountry):
+			span.SetAttributes(attribute.String("error", "unsupported country"))
+		case err != nil:
+			span.SetAttributes(attribute.String("error", "invalid cep format"))
+		}
+		return err
+	})
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, errUnsupportedCountry) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Message: "unsupported country", Code: "unsupported_country"})
+			return
+		}
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		json.NewEncoder(w).Encode(ErrorResponse{Message: "invalid zipcode"})
 		return
 	}
 
-	// Adiciona CEP como atributo do span
-	span.SetAttributes(attribute.String("cep", req.CEP))
+	// O Accept é repassado para que o serviço B escolha a nomenclatura dos campos,
+	// e o X-API-Key para que aplique o tier do cliente. A chave entra no cache e
+	// no agrupamento, para que a resposta de um tier nunca sirva a outro.
+	accept := r.Header.Get("Accept")
+	apiKey := r.Header.Get(middleware.HeaderAPIKey)
+	cacheKey := code.key() + "|" + accept + "|" + apiKeyFingerprint(apiKey)
+
+	// Responde do cache sem chamar o serviço B, a menos que o cliente peça no-cache
+	cacheStatus := "MISS"
+	if cepCache != nil && bypassCache(r) {
+		cacheStatus = "BYPASS"
+	}
+	if cepCache != nil && cacheStatus == "MISS" {
+		if cached, ok := cepCache.get(cacheKey); ok {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.status", "HIT"))
+			w.Header().Set("X-Cache", "HIT")
+			deprecation.CopyHeaders(w.Header(), cached.Deprecation)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(cached.StatusCode)
+			w.Write(cached.Body)
+			return
+		}
+	}
 
-	// Valida o CEP
-	if !validateCEP(req.CEP) {
-		span.SetAttributes(attribute.String("error", "invalid cep format"))
+	// Chama o serviço B
+	var result serviceBResponse
+	err = telemetry.WithSpan(ctx, tracer, "call-service-orchestration", telemetry.ErrorStatus, func(ctx context.Context, span trace.Span) error {
+		span.SetAttributes(attribute.String("clean_cep", code.Code))
+
+		var err error
+		if cepCoalescer != nil {
+			var shared bool
+			result, shared, err = cepCoalescer.Do(ctx, cacheKey, func(callCtx context.Context) (serviceBResponse, error) {
+				return callServiceB(callCtx, trace.SpanFromContext(callCtx), code, accept, apiKey)
+			})
+			span.SetAttributes(attribute.Bool("coalesced", shared))
+		} else {
+			result, err = callServiceB(ctx, span, code, accept, apiKey)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Repassa a indicação de resposta degradada do serviço B
+		if result.Degraded != "" {
+			span.SetAttributes(
+				attribute.Bool("degraded", true),
+				attribute.String("degraded.reason", result.Degraded),
+			)
+		}
+		return nil
+	})
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "invalid zipcode"})
+		// Serviço B inalcançável costuma ser configuração errada, não falha interna
+		if isUnreachable(err) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ErrorResponse{Message: "service-orchestration is unreachable", Code: "upstream_misconfigured"})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Message: "internal server error"})
 		return
 	}
 
-	// Limpa o CEP para enviar para o serviço B
-	cleanCEP := strings.ReplaceAll(req.CEP, "-", "")
-	cleanCEP = strings.ReplaceAll(cleanCEP, " ", "")
-	span.End()
+	// A comparação com o canário roda em segundo plano e não altera a resposta
+	if canary != nil {
+		canary.maybeCompare(ctx, code, accept, apiKey, result)
+	}
 
-	// Chama o serviço B
-	ctx, spanServiceB := tracer.Start(ctx, "call-service-orchestration")
-	defer spanServiceB.End()
+	if cepCache != nil {
+		cepCache.set(cacheKey, result)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.status", cacheStatus))
+		w.Header().Set("X-Cache", cacheStatus)
+	}
 
-	serviceBURL := os.Getenv("SERVICE_B_URL")
-	if serviceBURL == "" {
-		log.Fatal("SERVICE_B_URL environment variable not set")
+	if result.Degraded != "" {
+		w.Header().Set("X-Degraded", result.Degraded)
+	}
+	// Repassa as depreciações do serviço B (ex: campos legados da resposta)
+	deprecation.CopyHeaders(w.Header(), result.Deprecation)
+	// Repassa o Retry-After para que clientes (ex: pkg/client) saibam quando tentar de novo
+	if result.RetryAfter != "" {
+		w.Header().Set("Retry-After", result.RetryAfter)
 	}
 
-	url := fmt.Sprintf("%s/temperature?cep=%s", serviceBURL, cleanCEP)
-	spanServiceB.SetAttributes(
-		attribute.String("service.b.url", url),
-		attribute.String("clean_cep", cleanCEP),
-	)
+	// Retorna a resposta do serviço B com o mesmo status code
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(result.StatusCode)
+	w.Write(result.Body)
+}
+
+type serviceBResponse struct {
+	StatusCode int
+	Body       []byte
+	Degraded   string
+	RetryAfter string
+	// Deprecation guarda os headers de depreciação do serviço B
+	Deprecation http.Header
+}
+
+// deprecationHeaders copia apenas os headers de depreciação, que são guardados
+// junto com a resposta no cache.
+func deprecationHeaders(src http.Header) http.Header {
+	h := make(http.Header)
+	deprecation.CopyHeaders(h, src)
+	return h
+}
+
+// apiKeyFingerprint identifica a chave do cliente nas chaves do cache sem
+// guardá-la em claro; sem chave retorna vazio.
+func apiKeyFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func callServiceB(ctx context.Context, span trace.Span, code postalCode, accept, apiKey string) (serviceBResponse, error) {
+	return callBackend(ctx, span, serviceBClient, serviceBURL, code, accept, apiKey)
+}
+
+// callBackend consulta o /temperature de uma instância do serviço B em baseURL.
+func callBackend(ctx context.Context, span trace.Span, client *http.Client, baseURL string, code postalCode, accept, apiKey string) (serviceBResponse, error) {
+	url := baseURL + "/temperature?" + code.query()
+	span.SetAttributes(attribute.String("service.b.url", url))
 
 	// Cria requisição com contexto de tracing
 	reqServiceB, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		spanServiceB.SetAttributes(attribute.String("error", "failed to create request"))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "internal server error"})
-		return
+		span.SetAttributes(attribute.String("error", "failed to create request"))
+		return serviceBResponse{}, err
 	}
 
+	userAgent := "service-input/" + buildinfo.Get().Version
+	reqServiceB.Header.Set("User-Agent", userAgent)
+	if accept != "" {
+		reqServiceB.Header.Set("Accept", accept)
+	}
+	if apiKey != "" {
+		reqServiceB.Header.Set(middleware.HeaderAPIKey, apiKey)
+	}
+	span.SetAttributes(attribute.String("http.user_agent", userAgent))
+
 	// Injeta headers de tracing na requisição
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(reqServiceB.Header))
 
-	client := &http.Client{}
 	resp, err := client.Do(reqServiceB)
 	if err != nil {
-		spanServiceB.SetAttributes(attribute.String("error", "service b call failed"))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "internal server error"})
-		return
+		span.SetAttributes(attribute.String("error", "service b call failed"))
+		return serviceBResponse{}, err
 	}
 	defer resp.Body.Close()
 
-	spanServiceB.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 
 	// Lê a resposta do serviço B
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		spanServiceB.SetAttributes(attribute.String("error", "failed to read response"))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Message: "internal server error"})
-		return
+		span.SetAttributes(attribute.String("error", "failed to read response"))
+		return serviceBResponse{}, err
 	}
 
-	// Retorna a resposta do serviço B com o mesmo status code
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
+	return serviceBResponse{
+		StatusCode:  resp.StatusCode,
+		Body:        body,
+		Degraded:    resp.Header.Get("X-Degraded"),
+		RetryAfter:  resp.Header.Get("Retry-After"),
+		Deprecation: deprecationHeaders(resp.Header),
+	}, nil
 }
 
 func main() {
@@ -190,43 +449,165 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	// Guardado para o /admin/config distinguir valores vindos do .env
+	envFile, _ := godotenv.Read()
+
+	check := flag.Bool("check", false, "validate config, secrets and the collector connection, then exit")
+	probe := flag.Bool("probe", false, "with --check, also call service-orchestration")
+	flag.Parse()
+	if *check {
+		os.Exit(runCheck(*probe))
+	}
+
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	shutdown, err := initProvider("service-input", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	tracingEnabled := os.Getenv("TRACING_ENABLED") != "false"
+	if !tracingEnabled {
+		log.Println("Tracing disabled, using no-op tracer provider")
+	}
+
+	shutdown, collector, err := initProvider("service-input", tracingEnabled)
 	if err != nil {
 		log.Fatalf("failed to initialize tracing provider: %v", err)
 	}
+	// Também direciona o pacote log para o slog, exportando todas as linhas
+	slog.SetDefault(logging.New("service-input", os.Stderr))
 	defer func() {
-		if err := shutdown(ctx); err != nil {
-			log.Fatalf("failed to shutdown tracing provider: %v", err)
+		// O ctx já foi cancelado aqui; o flush é limitado por OTEL_SHUTDOWN_TIMEOUT
+		for _, result := range shutdown(context.WithoutCancel(ctx)) {
+			if result.Err != nil {
+				log.Printf("Failed to flush %s after %s: %v", result.Signal, result.Duration.Round(time.Millisecond), result.Err)
+				continue
+			}
+			log.Printf("Flushed %s in %s", result.Signal, result.Duration.Round(time.Millisecond))
 		}
 	}()
 
-	r := chi.NewRouter()
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Middlewares
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.SetHeader("Content-Type", "application/json"))
+	// Roda as tarefas em segundo plano e o trabalho que as requisições deixam
+	// em andamento, como as comparações com o canário
+	tasks := supervisor.New(ctx)
+	if collector != nil {
+		tasks.Go("collector-failback", collector.Failback)
+	}
 
-	// Rotas
-	r.Post("/temperature", handleCEPRequest)
+	if cfg.ResponseCacheTTL > 0 {
+		cepCache = newResponseCache(cfg.ResponseCacheTTL, clock.Real{})
+		log.Printf("Response cache enabled with TTL %s", cfg.ResponseCacheTTL)
+	}
+
+	if cfg.CoalesceWindow > 0 {
+		// A chamada compartilhada tem o mesmo limite da rota que a originou
+		cepCoalescer = newCoalescer(cfg.CoalesceWindow, cfg.Timeouts.timeoutFor("/temperature"))
+		log.Printf("Request coalescing enabled with window %s", cfg.CoalesceWindow)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	acceptLegacyRequests = cfg.LegacyRequests
+	var extraBodyTypes []string
+	if acceptLegacyRequests {
+		extraBodyTypes = append(extraBodyTypes, formContentType)
+		log.Println("Accepting legacy request formats (JSON string and form-encoded)")
 	}
 
+	if cfg.CassetteMode != cassette.ModeOff {
+		// Grava ou reproduz as respostas do serviço B em disco
+		log.Printf("Upstream cassette in %s mode using %s", cfg.CassetteMode, cfg.CassetteDir)
+		serviceBClient.Transport = cassette.Wrap(serviceBClient.Transport, cfg.CassetteMode, cfg.CassetteDir)
+	}
+
+	serviceBURL = cfg.ServiceBURL
+	registry := upstream.NewRegistry(cfg.Upstreams, serviceBClient.Transport)
+	serviceBClient = registry.Client(serviceBTarget)
+
+	if cfg.CanaryPercent > 0 {
+		target, _ := registry.Target(canaryTarget)
+		canary = newCanaryDiffer(registry.Client(canaryTarget), target.URL, cfg.CanaryPercent, rand.Float64, tasks)
+		log.Printf("Comparing %.1f%% of responses with the canary at %s", cfg.CanaryPercent, target.URL)
+	}
+
+	if target, ok := registry.Target(mirrorTarget); ok {
+		mirror = newShadowMirror(registry.Client(mirrorTarget), target.URL, cfg.MirrorPercent, cfg.MirrorRate, rand.Float64, time.Now, tasks)
+		log.Printf("Mirroring %.1f%% of requests (up to %.1f/s) to %s", cfg.MirrorPercent, cfg.MirrorRate, target.URL)
+	}
+
+	if len(cfg.AdminAPIKeys) == 0 {
+		log.Println("ADMIN_API_KEYS is empty: /drain and /admin/* reject every request")
+	}
+
+	maintenanceSwitch := maintenance.NewSwitch(cfg.Maintenance)
+
+	auditLog, err := audit.NewLogger(cfg.AuditLogPath)
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	lm := &lifecycleManager{}
+	// As tarefas em segundo plano só param depois que o servidor deixa de
+	// aceitar requisições, e antes do flush da telemetria
+	lm.onShutdown(tasks.Shutdown)
+
+	r := chi.NewRouter()
+
+	// Middlewares
+	r.Use(middleware.Stack(middleware.Options{
+		ContentType:     "application/json",
+		TrustedProxies:  cfg.TrustedProxies,
+		TrustAllProxies: cfg.TrustAllProxies,
+		ExtraBodyTypes:  extraBodyTypes,
+		RateLimit:       cfg.RateLimit,
+		RateBurst:       cfg.RateBurst,
+	})...)
+	if cfg.Signer != nil {
+		r.Use(cfg.Signer.middleware)
+	}
+	r.Use(deprecation.New(cfg.Deprecations).Middleware)
+
+	// Rotas
+	r.Group(func(r chi.Router) {
+		r.Use(lm.track)
+		r.Use(maintenanceSwitch.Middleware)
+		if mirror != nil {
+			r.Use(mirror.middleware)
+		}
+		r.With(middleware.Timeout(cfg.Timeouts.timeoutFor("/temperature"))).Post("/temperature", handleCEPRequest)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(cfg.Timeouts.Default))
+		r.Get("/version", buildinfo.Handler)
+		r.Get("/healthz", handleHealthz)
+		r.Get("/readyz", lm.handleReady)
+	})
+	// Rotas administrativas, só com uma chave de ADMIN_API_KEYS no X-API-Key
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(cfg.Timeouts.Default))
+		r.Use(middleware.RequireAPIKey(cfg.AdminAPIKeys))
+		r.Get("/drain", lm.handleDrain)
+		r.With(auditLog.Middleware("drain", func() any { return lm.status() })).Post("/drain", lm.handleDrain)
+		r.Get("/admin/maintenance", maintenanceSwitch.Handler)
+		r.With(auditLog.Middleware("maintenance", func() any { return maintenanceSwitch.State() })).Post("/admin/maintenance", maintenanceSwitch.Handler)
+		r.Get("/admin/audit", auditLog.Handler)
+		r.Get("/admin/config", configdump.Handler(configVars, envFile))
+	})
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: serve.WithBasePath(cfg.BasePath, r)}
+
+	lm.onShutdown(server.Shutdown)
+
+	var serving sync.WaitGroup
+	serving.Add(1)
 	go func() {
-		log.Printf("Service Input running on port %s", port)
-		if err := http.ListenAndServe(":"+port, r); err != nil {
+		defer serving.Done()
+		log.Printf("Service Input running on port %s", cfg.Port)
+		if err := serve.ListenAndServe(server, cfg.TLS); err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
@@ -239,6 +620,13 @@ func main() {
 		log.Println("Shutting down due to other reason...")
 	}
 
-	_, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	lm.startDrain()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
+
+	if err := lm.shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shutdown: %v", err)
+	}
+	serving.Wait()
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+)
+
+// TestHandleCEPRequestAPIKey checks that the caller's key reaches service B
+// and that responses cached for one key are not served to another.
+func TestHandleCEPRequestAPIKey(t *testing.T) {
+	calls := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key":"` + r.Header.Get(middleware.HeaderAPIKey) + `"}`))
+	}))
+	defer backend.Close()
+
+	prevURL, prevCache, prevCoalescer := serviceBURL, cepCache, cepCoalescer
+	serviceBURL, cepCache, cepCoalescer = backend.URL, newResponseCache(time.Minute, clock.Real{}), nil
+	defer func() { serviceBURL, cepCache, cepCoalescer = prevURL, prevCache, prevCoalescer }()
+
+	tests := []struct {
+		key, wantBody, wantCache string
+		wantCalls                int
+	}{
+		{key: "premium-key", wantBody: `{"key":"premium-key"}`, wantCache: "MISS", wantCalls: 1},
+		{key: "", wantBody: `{"key":""}`, wantCache: "MISS", wantCalls: 2},
+		{key: "premium-key", wantBody: `{"key":"premium-key"}`, wantCache: "HIT", wantCalls: 2},
+		{key: "", wantBody: `{"key":""}`, wantCache: "HIT", wantCalls: 2},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"cep":"01001000"}`))
+		if tt.key != "" {
+			req.Header.Set(middleware.HeaderAPIKey, tt.key)
+		}
+		rec := httptest.NewRecorder()
+		handleCEPRequest(rec, req)
+
+		if got := rec.Body.String(); got != tt.wantBody {
+			t.Errorf("request %d: body = %s, want %s", i, got, tt.wantBody)
+		}
+		if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+			t.Errorf("request %d: X-Cache = %q, want %q", i, got, tt.wantCache)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("request %d: service B called %d times, want %d", i, calls, tt.wantCalls)
+		}
+	}
+}
+
+// TestHandleCEPRequestCountry checks that the country reaches service B and
+// keeps apart the cached responses of the same code in different countries.
+func TestHandleCEPRequestCountry(t *testing.T) {
+	var queries []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+
+	prevURL, prevCache, prevCoalescer := serviceBURL, cepCache, cepCoalescer
+	serviceBURL, cepCache, cepCoalescer = backend.URL, newResponseCache(time.Minute, clock.Real{}), nil
+	defer func() { serviceBURL, cepCache, cepCoalescer = prevURL, prevCache, prevCoalescer }()
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantCache  string
+	}{
+		{body: `{"cep":"90210"}`, wantStatus: http.StatusUnprocessableEntity},
+		{body: `{"cep":"90210","country":"US"}`, wantStatus: http.StatusOK, wantCache: "MISS"},
+		{body: `{"cep":"90210-1234","country":"us"}`, wantStatus: http.StatusOK, wantCache: "HIT"},
+		{body: `{"cep":"1000-001","country":"PT"}`, wantStatus: http.StatusOK, wantCache: "MISS"},
+		{body: `{"cep":"01001000","country":"AR"}`, wantStatus: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleCEPRequest(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus)
+		}
+		if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+			t.Errorf("request %d: X-Cache = %q, want %q", i, got, tt.wantCache)
+		}
+	}
+	if want := []string{"cep=90210&country=US", "cep=1000-001&country=PT"}; strings.Join(queries, " ") != strings.Join(want, " ") {
+		t.Errorf("service B queries = %q, want %q", queries, want)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "short", s: "pedido-1", n: 128, want: "pedido-1"},
+		{name: "ascii", s: "pedido-1", n: 6, want: "pedido"},
+		{name: "on a boundary", s: "São", n: 3, want: "Sã"},
+		{name: "inside a rune", s: "São", n: 2, want: "S"},
+		{name: "inside a wide rune", s: "a😀", n: 4, want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateUTF8(tt.s, tt.n); got != tt.want {
+				t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/carrier"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// mirrorTarget é o nome do ambiente de staging no UPSTREAMS_FILE.
+	mirrorTarget = "staging-mirror"
+
+	defaultMirrorSamplePercent = 10
+	defaultMirrorRateLimit     = 5
+	mirrorTimeout              = 5 * time.Second
+	maxMirrorRequests          = 10
+)
+
+// mirroredHeaders são os únicos headers repassados ao staging; credenciais,
+// cookies, IPs e IDs de transação do cliente nunca saem de produção.
+var mirroredHeaders = []string{"Content-Type", "Accept", "User-Agent"}
+
+var mirrorRequests, _ = otel.Meter("service-input").Int64Counter(
+	"input.mirror.requests",
+	metric.WithDescription("Number of requests mirrored to staging, by result (sent, error or dropped)"),
+)
+
+// shadowMirror encaminha uma amostra das requisições de produção para o
+// staging em segundo plano, limitada a rate requisições por segundo. A
+// resposta do staging é descartada e nunca afeta a do cliente. rand sorteia
+// as requisições e now é o relógio do limite de taxa.
+type shadowMirror struct {
+	client   *http.Client
+	url      string
+	percent  float64
+	interval time.Duration
+	rand     func() float64
+	now      func() time.Time
+
+	mu   sync.Mutex
+	next time.Time
+
+	slots chan struct{}
+	// tasks roda as requisições espelhadas; o Shutdown dele espera as que
+	// estão em andamento
+	tasks *supervisor.Supervisor
+}
+
+var mirror *shadowMirror
+
+func newShadowMirror(client *http.Client, url string, percent, rate float64, rand func() float64, now func() time.Time, tasks *supervisor.Supervisor) *shadowMirror {
+	return &shadowMirror{
+		client:   client,
+		url:      url,
+		percent:  percent,
+		interval: time.Duration(float64(time.Second) / rate),
+		rand:     rand,
+		now:      now,
+		slots:    make(chan struct{}, maxMirrorRequests),
+		tasks:    tasks,
+	}
+}
+
+// parseMirrorConfig lê MIRROR_SAMPLE_PERCENT (0 a 100) e MIRROR_RATE_LIMIT
+// (requisições por segundo).
+func parseMirrorConfig(getenv func(string) string) (percent, rate float64, err error) {
+	percent, rate = defaultMirrorSamplePercent, defaultMirrorRateLimit
+	if v := getenv("MIRROR_SAMPLE_PERCENT"); v != "" {
+		percent, err = strconv.ParseFloat(v, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid MIRROR_SAMPLE_PERCENT %q: expected a number from 0 to 100", v)
+		}
+	}
+	if v := getenv("MIRROR_RATE_LIMIT"); v != "" {
+		rate, err = strconv.ParseFloat(v, 64)
+		if err != nil || rate <= 0 {
+			return 0, 0, fmt.Errorf("invalid MIRROR_RATE_LIMIT %q: expected a positive number", v)
+		}
+	}
+	return percent, rate, nil
+}
+
+// allow libera no máximo uma requisição a cada interval.
+func (m *shadowMirror) allow(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Before(m.next) {
+		return false
+	}
+	m.next = now.Add(m.interval)
+	return true
+}
+
+func (m *shadowMirror) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.rand()*100 >= m.percent {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !m.allow(m.now()) {
+			mirrorRequests.Add(r.Context(), 1, metric.WithAttributes(attribute.String("result", "dropped")))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// O corpo já foi lido e limitado por middleware.RequireJSON
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		header := make(http.Header)
+		for _, name := range mirroredHeaders {
+			if v := r.Header.Get(name); v != "" {
+				header.Set(name, v)
+			}
+		}
+		method, uri := r.Method, r.URL.RequestURI()
+
+		next.ServeHTTP(w, r)
+
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			mirrorRequests.Add(r.Context(), 1, metric.WithAttributes(attribute.String("result", "dropped")))
+			return
+		}
+		link := trace.LinkFromContext(r.Context())
+		m.tasks.Spawn(context.Background(), "mirror-request", func(ctx context.Context) {
+			defer func() { <-m.slots }()
+			m.send(ctx, link, method, uri, header, body)
+		})
+	})
+}
+
+// send roda em um trace próprio, ligado ao da requisição original, para que
+// o tráfego de staging não se misture aos traces de produção.
+func (m *shadowMirror) send(ctx context.Context, link trace.Link, method, uri string, header http.Header, body []byte) {
+	ctx, cancel := context.WithTimeout(ctx, mirrorTimeout)
+	defer cancel()
+
+	tracer := otel.Tracer("service-input-tracer")
+	ctx, span := tracer.Start(ctx, "mirror-request", trace.WithNewRoot(), trace.WithLinks(link), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, method, m.url+uri, bytes.NewReader(body))
+	if err != nil {
+		m.fail(ctx, span, err)
+		return
+	}
+	req.Header = header
+	req.Header.Set("X-Shadow-Request", "true")
+	carrier.Inject(ctx, carrier.Headers(req.Header))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		m.fail(ctx, span, err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	mirrorRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "sent")))
+}
+
+func (m *shadowMirror) fail(ctx context.Context, span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "mirror request failed")
+	mirrorRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error")))
+}
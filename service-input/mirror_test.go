@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+)
+
+func fixedRand(v float64) func() float64 {
+	return func() float64 { return v }
+}
+
+func TestParseMirrorConfig(t *testing.T) {
+	tests := []struct {
+		percent, rate         string
+		wantPercent, wantRate float64
+		wantErr               bool
+	}{
+		{wantPercent: defaultMirrorSamplePercent, wantRate: defaultMirrorRateLimit},
+		{percent: "50", rate: "0.5", wantPercent: 50, wantRate: 0.5},
+		{percent: "0", wantPercent: 0, wantRate: defaultMirrorRateLimit},
+		{percent: "101", wantErr: true},
+		{percent: "ten", wantErr: true},
+		{rate: "0", wantErr: true},
+		{rate: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		env := map[string]string{"MIRROR_SAMPLE_PERCENT": tt.percent, "MIRROR_RATE_LIMIT": tt.rate}
+		percent, rate, err := parseMirrorConfig(func(key string) string { return env[key] })
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMirrorConfig(%q, %q) error = %v, want error %t", tt.percent, tt.rate, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (percent != tt.wantPercent || rate != tt.wantRate) {
+			t.Errorf("parseMirrorConfig(%q, %q) = %v, %v, want %v, %v", tt.percent, tt.rate, percent, rate, tt.wantPercent, tt.wantRate)
+		}
+	}
+}
+
+func TestShadowMirrorAllow(t *testing.T) {
+	m := newShadowMirror(http.DefaultClient, "", 100, 2, fixedRand(0), time.Now, supervisor.New(context.Background()))
+	now := time.Now()
+	tests := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{100 * time.Millisecond, false},
+		{500 * time.Millisecond, true},
+		{999 * time.Millisecond, false},
+		{time.Second, true},
+	}
+	for _, tt := range tests {
+		if got := m.allow(now.Add(tt.at)); got != tt.want {
+			t.Errorf("allow(+%v) = %t, want %t", tt.at, got, tt.want)
+		}
+	}
+}
+
+type mirroredRequest struct {
+	uri    string
+	header http.Header
+	body   string
+}
+
+func TestShadowMirrorMiddleware(t *testing.T) {
+	received := make(chan mirroredRequest, 1)
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirroredRequest{uri: r.URL.RequestURI(), header: r.Header, body: string(body)}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer staging.Close()
+
+	m := newShadowMirror(staging.Client(), staging.URL, 100, 1000, fixedRand(0), time.Now, supervisor.New(context.Background()))
+	var handled string
+	h := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler still reads the whole body
+		body, _ := io.ReadAll(r.Body)
+		handled = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/?debug=1", strings.NewReader(`{"cep":"01001000"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "secret")
+	req.Header.Set("Cookie", "session=1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || handled != `{"cep":"01001000"}` {
+		t.Fatalf("handler got %q and answered %d; the mirror must not change the request", handled, rec.Code)
+	}
+
+	select {
+	case got := <-received:
+		if got.uri != "/?debug=1" || got.body != `{"cep":"01001000"}` {
+			t.Errorf("mirrored %s with %q, want the original request", got.uri, got.body)
+		}
+		if got.header.Get("X-Shadow-Request") != "true" || got.header.Get("Content-Type") != "application/json" {
+			t.Errorf("mirrored headers %v, want Content-Type and X-Shadow-Request", got.header)
+		}
+		if got.header.Get("X-API-Key") != "" || got.header.Get("Cookie") != "" {
+			t.Errorf("mirrored headers %v carry credentials", got.header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request not mirrored")
+	}
+}
+
+func TestShadowMirrorSamples(t *testing.T) {
+	mirrored := make(chan struct{}, 1)
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- struct{}{}
+	}))
+	defer staging.Close()
+
+	m := newShadowMirror(staging.Client(), staging.URL, 10, 1000, fixedRand(0.5), time.Now, supervisor.New(context.Background()))
+	h := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+	select {
+	case <-mirrored:
+		t.Error("request mirrored although the draw was above the sample percentage")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestShadowMirrorDelaysShutdown(t *testing.T) {
+	release := make(chan struct{})
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer staging.Close()
+
+	tasks := supervisor.New(context.Background())
+	m := newShadowMirror(staging.Client(), staging.URL, 100, 1000, fixedRand(0), time.Now, tasks)
+	h := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tasks.Shutdown(ctx); err == nil {
+		t.Error("Shutdown() returned with a mirrored request in flight")
+	}
+
+	close(release)
+	if err := tasks.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v after the request finished", err)
+	}
+}
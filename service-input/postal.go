@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultCountry é o país dos pedidos que não informam country.
+const defaultCountry = "BR"
+
+var (
+	errUnsupportedCountry = errors.New("unsupported country")
+	errInvalidPostalCode  = errors.New("invalid postal code")
+)
+
+var (
+	ptCode = regexp.MustCompile(`^(\d{4})-?(\d{3})$`)
+	usCode = regexp.MustCompile(`^(\d{5})(-\d{4})?$`)
+)
+
+// postalValidators normaliza os códigos postais dos países que o serviço B
+// atende, da mesma forma que ele: o CEP sem formatação, o código português
+// como NNNN-NNN e o ZIP americano com 5 dígitos, mesmo quando vem como ZIP+4.
+var postalValidators = map[string]func(code string) (string, bool){
+	"BR": func(code string) (string, bool) {
+		if !validateCEP(code) {
+			return "", false
+		}
+		return strings.NewReplacer("-", "", " ", "").Replace(code), true
+	},
+	"PT": func(code string) (string, bool) {
+		m := ptCode.FindStringSubmatch(strings.TrimSpace(code))
+		if m == nil {
+			return "", false
+		}
+		return m[1] + "-" + m[2], true
+	},
+	"US": func(code string) (string, bool) {
+		m := usCode.FindStringSubmatch(strings.TrimSpace(code))
+		if m == nil {
+			return "", false
+		}
+		return m[1], true
+	},
+}
+
+// postalCode é um código postal validado e normalizado, com o país (ISO 3166-1
+// alfa-2) a que pertence.
+type postalCode struct {
+	Country string
+	Code    string
+}
+
+// parsePostalCode valida code como um código postal de country; country vazio
+// é o Brasil.
+func parsePostalCode(country, code string) (postalCode, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		country = defaultCountry
+	}
+	validate, ok := postalValidators[country]
+	if !ok {
+		return postalCode{}, errUnsupportedCountry
+	}
+	normalized, ok := validate(code)
+	if !ok {
+		return postalCode{}, errInvalidPostalCode
+	}
+	return postalCode{Country: country, Code: normalized}, nil
+}
+
+// key identifica o código no cache e no agrupamento. CEPs mantêm o código
+// puro, como no serviço B, e os outros países recebem o prefixo "CC:".
+func (p postalCode) key() string {
+	if p.Country == defaultCountry {
+		return p.Code
+	}
+	return p.Country + ":" + p.Code
+}
+
+// query é a query string do /temperature do serviço B para o código.
+func (p postalCode) query() string {
+	q := url.Values{"cep": {p.Code}}
+	if p.Country != defaultCountry {
+		q.Set("country", p.Country)
+	}
+	return q.Encode()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePostalCode(t *testing.T) {
+	tests := []struct {
+		country, code string
+		want          postalCode
+		wantKey       string
+		wantQuery     string
+		wantErr       error
+	}{
+		{country: "", code: "01001-000", want: postalCode{"BR", "01001000"}, wantKey: "01001000", wantQuery: "cep=01001000"},
+		{country: "br", code: "01001000", want: postalCode{"BR", "01001000"}, wantKey: "01001000", wantQuery: "cep=01001000"},
+		{country: "PT", code: "1000001", want: postalCode{"PT", "1000-001"}, wantKey: "PT:1000-001", wantQuery: "cep=1000-001&country=PT"},
+		{country: "us", code: "90210-1234", want: postalCode{"US", "90210"}, wantKey: "US:90210", wantQuery: "cep=90210&country=US"},
+		{country: "BR", code: "1000-001", wantErr: errInvalidPostalCode},
+		{country: "PT", code: "01001000", wantErr: errInvalidPostalCode},
+		{country: "AR", code: "01001000", wantErr: errUnsupportedCountry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country+"/"+tt.code, func(t *testing.T) {
+			got, err := parsePostalCode(tt.country, tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parsePostalCode() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want || got.key() != tt.wantKey || got.query() != tt.wantQuery {
+				t.Errorf("parsePostalCode() = %+v (key %q, query %q), want %+v (key %q, query %q)", got, got.key(), got.query(), tt.want, tt.wantKey, tt.wantQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// acceptLegacyRequests habilita, via LEGACY_REQUEST_FORMATS, os formatos de
+// corpo de clientes antigos além de {"cep": "..."}.
+var acceptLegacyRequests bool
+
+const formContentType = "application/x-www-form-urlencoded"
+
+// parseCEPRequest lê o corpo da requisição para CEPRequest. Por padrão só
+// aceita o objeto JSON; com acceptLegacyRequests também aceita uma string JSON
+// ("01001000") e o campo cep de um formulário.
+func parseCEPRequest(r *http.Request) (CEPRequest, error) {
+	var req CEPRequest
+
+	if !acceptLegacyRequests {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return CEPRequest{}, err
+		}
+		return req, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == formContentType {
+		if err := r.ParseForm(); err != nil {
+			return CEPRequest{}, err
+		}
+		if !r.PostForm.Has("cep") {
+			return CEPRequest{}, errors.New("missing cep form field")
+		}
+		return CEPRequest{CEP: r.PostForm.Get("cep"), Country: r.PostForm.Get("country")}, nil
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return CEPRequest{}, err
+	}
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, `"`) {
+		if err := json.Unmarshal(raw, &req.CEP); err != nil {
+			return CEPRequest{}, err
+		}
+		return req, nil
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return CEPRequest{}, err
+	}
+	return req, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCEPRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		legacy      bool
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{name: "object", body: `{"cep":"01001000"}`, want: "01001000"},
+		{name: "string without legacy", body: `"01001000"`, wantErr: true},
+		{name: "form without legacy", contentType: formContentType, body: "cep=01001000", wantErr: true},
+		{name: "invalid json", body: `{"cep":`, wantErr: true},
+		{name: "legacy object", legacy: true, body: `{"cep":"01001000"}`, want: "01001000"},
+		{name: "legacy string", legacy: true, body: ` "01001000"`, want: "01001000"},
+		{name: "legacy form", legacy: true, contentType: formContentType + "; charset=utf-8", body: "cep=01001000", want: "01001000"},
+		{name: "legacy form without cep", legacy: true, contentType: formContentType, body: "zip=01001000", wantErr: true},
+		{name: "legacy number", legacy: true, body: `1001000`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := acceptLegacyRequests
+			acceptLegacyRequests = tt.legacy
+			defer func() { acceptLegacyRequests = prev }()
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			got, err := parseCEPRequest(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCEPRequest() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got.CEP != tt.want {
+				t.Errorf("parseCEPRequest() CEP = %q, want %q", got.CEP, tt.want)
+			}
+		})
+	}
+}
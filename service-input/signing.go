@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+// responseSigner assina o corpo das respostas com HMAC-SHA256 no header
+// X-Signature. Durante a rotação de chaves, a resposta é assinada com todas as
+// chaves configuradas, permitindo que consumidores com a chave antiga ou a nova
+// validem o payload: "kid1=<assinatura>, kid2=<assinatura>".
+type responseSigner struct {
+	keys []signingKey
+}
+
+// parseSigningKeys lê chaves no formato "id:segredo,id2:segredo2".
+func parseSigningKeys(value string) (*responseSigner, error) {
+	var keys []signingKey
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signing key entry: expected id:secret")
+		}
+		keys = append(keys, signingKey{id: id, secret: []byte(secret)})
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return &responseSigner{keys: keys}, nil
+}
+
+func (s *responseSigner) sign(body []byte) string {
+	signatures := make([]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(body)
+		signatures = append(signatures, k.id+"="+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}
+	return strings.Join(signatures, ", ")
+}
+
+// streamedContentTypes são enviados à medida que o handler escreve. Assiná-los
+// exigiria guardar o fluxo inteiro, então eles seguem sem X-Signature.
+var streamedContentTypes = []string{"text/event-stream", "application/x-ndjson"}
+
+func isStreamed(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return slices.Contains(streamedContentTypes, mediaType)
+}
+
+func (s *responseSigner) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		if bw.streaming {
+			return
+		}
+
+		w.Header().Set("X-Signature", s.sign(bw.body.Bytes()))
+		w.WriteHeader(bw.status)
+		w.Write(bw.body.Bytes())
+	})
+}
+
+// bufferedWriter guarda a resposta em memória para que ela possa ser assinada
+// antes do envio dos headers. Respostas de streamedContentTypes passam direto
+// para o ResponseWriter original.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	streaming   bool
+	body        bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	bw.status = code
+	if isStreamed(bw.Header().Get("Content-Type")) {
+		bw.streaming = true
+		bw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+	if bw.streaming {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+// FlushError é encontrado por http.ResponseController antes de Unwrap, para
+// que um flush não envie os headers de uma resposta que ainda será assinada.
+func (bw *bufferedWriter) FlushError() error {
+	bw.WriteHeader(http.StatusOK)
+	if !bw.streaming {
+		return nil
+	}
+	return http.NewResponseController(bw.ResponseWriter).Flush()
+}
+
+// Unwrap expõe o ResponseWriter original a http.ResponseController, para
+// recursos como deadlines.
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
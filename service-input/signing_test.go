@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseSigningKeys(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantIDs []string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: " , "},
+		{value: "k1:s1", wantIDs: []string{"k1"}},
+		{value: "k1:s1, k2:s2", wantIDs: []string{"k1", "k2"}},
+		{value: "k1", wantErr: true},
+		{value: ":s1", wantErr: true},
+		{value: "k1:", wantErr: true},
+	}
+	for _, tt := range tests {
+		signer, err := parseSigningKeys(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSigningKeys(%q) error = %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(tt.wantIDs) == 0 {
+			if signer != nil {
+				t.Errorf("parseSigningKeys(%q) = %+v, want no signer", tt.value, signer)
+			}
+			continue
+		}
+		if signer == nil || len(signer.keys) != len(tt.wantIDs) {
+			t.Errorf("parseSigningKeys(%q) = %+v, want keys %v", tt.value, signer, tt.wantIDs)
+			continue
+		}
+		for i, id := range tt.wantIDs {
+			if signer.keys[i].id != id {
+				t.Errorf("parseSigningKeys(%q) key %d = %q, want %q", tt.value, i, signer.keys[i].id, id)
+			}
+		}
+	}
+}
+
+func hmacSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestResponseSignerMiddleware(t *testing.T) {
+	signer, err := parseSigningKeys("old:s1,new:s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = `{"temp_C":20}`
+	h := signer.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		// Only the first status counts, as with a real ResponseWriter
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(body))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec.Body.String() != body {
+		t.Errorf("body = %s, want %s", rec.Body, body)
+	}
+	// Signed with every key during a rotation
+	want := "old=" + hmacSignature("s1", body) + ", new=" + hmacSignature("s2", body)
+	if got := rec.Header().Get("X-Signature"); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+}
+
+func TestResponseSignerMiddlewareStreams(t *testing.T) {
+	signer, err := parseSigningKeys("k:s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h := signer.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("data: 1\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() = %v", err)
+		}
+		// The event reaches the client before the handler returns
+		if !rec.Flushed || rec.Body.String() != "data: 1\n\n" {
+			t.Errorf("after Flush() the client got %q, flushed %v", rec.Body, rec.Flushed)
+		}
+	}))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("X-Signature"); got != "" {
+		t.Errorf("X-Signature = %q on a streamed response, want none", got)
+	}
+}
+
+func TestResponseSignerMiddlewareFlushKeepsSigning(t *testing.T) {
+	signer, err := parseSigningKeys("k:s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = `{"temp_C":20}`
+	rec := httptest.NewRecorder()
+	h := signer.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+		http.NewResponseController(w).Flush()
+		if rec.Flushed {
+			t.Error("Flush() sent the headers before the response was signed")
+		}
+	}))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := rec.Header().Get("X-Signature"), "k="+hmacSignature("s", body); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package adaptive
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWindow     = 100
+	DefaultMinSamples = 20
+)
+
+// Controller derives an upstream timeout from the latencies of its recent
+// calls: the p99 of a rolling window times Factor, clamped to [Min, Max].
+// Until MinSamples calls were observed it answers Max.
+type Controller struct {
+	Factor     float64
+	Min        time.Duration
+	Max        time.Duration
+	MinSamples int
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func New(window int, factor float64, min, max time.Duration) *Controller {
+	return &Controller{
+		Factor:     factor,
+		Min:        min,
+		Max:        max,
+		MinSamples: DefaultMinSamples,
+		samples:    make([]time.Duration, 0, window),
+	}
+}
+
+// Observe adds the latency of a finished call. Calls cut by the timeout should
+// be observed too, so a degrading provider pushes the timeout up to Max
+// instead of failing at a deadline tuned for its healthy latency.
+func (c *Controller) Observe(latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.samples) < cap(c.samples) {
+		c.samples = append(c.samples, latency)
+		return
+	}
+	c.samples[c.next] = latency
+	c.next = (c.next + 1) % len(c.samples)
+}
+
+func (c *Controller) Timeout() time.Duration {
+	c.mu.Lock()
+	if len(c.samples) < c.MinSamples || len(c.samples) == 0 {
+		c.mu.Unlock()
+		return c.Max
+	}
+	sorted := append([]time.Duration(nil), c.samples...)
+	c.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p99 := sorted[(len(sorted)*99+99)/100-1]
+
+	timeout := time.Duration(float64(p99) * c.Factor)
+	return max(c.Min, min(timeout, c.Max))
+}
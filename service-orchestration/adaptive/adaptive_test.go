@@ -0,0 +1,50 @@
+package adaptive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestControllerTimeout(t *testing.T) {
+	c := New(100, 2, 100*time.Millisecond, 3*time.Second)
+
+	if got := c.Timeout(); got != 3*time.Second {
+		t.Errorf("Timeout() without samples = %s, want the 3s max", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Observe(200 * time.Millisecond)
+	}
+	if got := c.Timeout(); got != 400*time.Millisecond {
+		t.Errorf("Timeout() = %s, want p99*factor = 400ms", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Observe(10 * time.Millisecond)
+	}
+	if got := c.Timeout(); got != 100*time.Millisecond {
+		t.Errorf("Timeout() for a fast provider = %s, want the 100ms min", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		c.Observe(5 * time.Second)
+	}
+	if got := c.Timeout(); got != 3*time.Second {
+		t.Errorf("Timeout() for a degraded provider = %s, want the 3s max", got)
+	}
+}
+
+func TestControllerWindow(t *testing.T) {
+	c := New(10, 1, 0, time.Minute)
+	c.MinSamples = 1
+
+	for i := 0; i < 10; i++ {
+		c.Observe(time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		c.Observe(100 * time.Millisecond)
+	}
+	if got := c.Timeout(); got != 100*time.Millisecond {
+		t.Errorf("Timeout() = %s, want old samples dropped from the window", got)
+	}
+}
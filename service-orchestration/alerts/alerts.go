@@ -0,0 +1,210 @@
+// Package alerts builds the recommended Prometheus alerting rules from the
+// service configuration, so alerts fire on the thresholds the code enforces
+// rather than on copies of them that drift.
+//
+// Metric names follow the OpenTelemetry collector's Prometheus exporter: dots
+// become underscores, counters get a _total suffix and histograms their unit
+// (e.g. http.server.request.duration becomes
+// http_server_request_duration_seconds).
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/config"
+)
+
+const (
+	// LatencyWarningRatio is the share of a route's timeout its p95 latency
+	// may reach before alerting, leaving room to act before requests are cut
+	// off with 504.
+	LatencyWarningRatio = 0.8
+	// BudgetExceededRatio is the share of /temperature requests allowed to
+	// overrun the latency budget of a stage.
+	BudgetExceededRatio = 0.05
+	// QuotaErrorRatio is the share of weather API calls rejected for quota
+	// that means every key is close to exhausted.
+	QuotaErrorRatio = 0.5
+)
+
+// burnRates are the multiwindow error budget burn rate alerts: a fast burn
+// spends 2% of a 30-day budget in an hour, a slow one 5% in six hours.
+var burnRates = []struct {
+	name        string
+	severity    string
+	rate        float64
+	long, short string
+}{
+	{"Fast", "critical", 14.4, "1h", "5m"},
+	{"Slow", "warning", 6, "6h", "30m"},
+}
+
+type RuleFile struct {
+	Groups []Group `yaml:"groups"`
+}
+
+type Group struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// Generate returns the rules for the service exported under job: error
+// budget burn against cfg.AvailabilityTarget, latency against the route
+// timeouts and stage budgets, open circuit breakers for the upstream targets
+// that have one, and weather API quota errors when keys are configured.
+func Generate(cfg config.Config, job string) RuleFile {
+	groups := []Group{
+		availability(cfg, job),
+		latency(cfg, job),
+	}
+	if g := breakers(cfg, job); len(g.Rules) > 0 {
+		groups = append(groups, g)
+	}
+	if len(cfg.WeatherAPIKeys) > 0 {
+		groups = append(groups, quota(job))
+	}
+	return RuleFile{Groups: groups}
+}
+
+func availability(cfg config.Config, job string) Group {
+	errorBudget := 1 - cfg.AvailabilityTarget
+	errorRatio := func(window string) string {
+		return fmt.Sprintf(`sum(rate(http_server_request_duration_seconds_count{job=%q,http_response_status_code=~"5.."}[%s]))
+  / sum(rate(http_server_request_duration_seconds_count{job=%q}[%s]))`, job, window, job, window)
+	}
+
+	g := Group{Name: job + "-availability"}
+	for _, b := range burnRates {
+		threshold := number(b.rate * errorBudget)
+		g.Rules = append(g.Rules, Rule{
+			Alert: b.name + "ErrorBudgetBurn",
+			Expr: fmt.Sprintf("(\n  %s\n) > %s\nand\n(\n  %s\n) > %s",
+				errorRatio(b.long), threshold, errorRatio(b.short), threshold),
+			Labels: map[string]string{"severity": b.severity},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s is burning its error budget %gx faster than allowed", job, b.rate),
+				"description": fmt.Sprintf("More than %s of requests failed with a 5xx status over the last %s and %s, against an availability SLO of %s.",
+					percent(b.rate*errorBudget), b.long, b.short, percent(cfg.AvailabilityTarget)),
+			},
+		})
+	}
+	return g
+}
+
+func latency(cfg config.Config, job string) Group {
+	g := Group{Name: job + "-latency"}
+
+	for _, route := range sortedKeys(cfg.RouteTimeouts) {
+		timeout := cfg.RouteTimeouts[route]
+		threshold := time.Duration(float64(timeout) * LatencyWarningRatio)
+		// The route label carries the base path the router is mounted under
+		label := cfg.BasePath + route
+		g.Rules = append(g.Rules, Rule{
+			Alert: "HighRouteLatency",
+			Expr: fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(http_server_request_duration_seconds_bucket{job=%q,http_route=%q}[5m]))) > %s`,
+				job, label, number(threshold.Seconds())),
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning", "route": label},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("p95 latency of %s is close to its %s timeout", label, timeout),
+				"description": fmt.Sprintf("The p95 latency of %s has been above %s for 10 minutes; requests are cut off with 504 at %s.", label, threshold, timeout),
+			},
+		})
+	}
+
+	lookups := fmt.Sprintf(`sum(rate(http_server_request_duration_seconds_count{job=%q,http_route=%q}[10m]))`, job, cfg.BasePath+"/temperature")
+	for _, stage := range sortedKeys(cfg.LatencyBudgets) {
+		budget := cfg.LatencyBudgets[stage]
+		g.Rules = append(g.Rules, Rule{
+			Alert: "LatencyBudgetExceeded",
+			Expr: fmt.Sprintf(`sum(rate(orchestration_budget_exceeded_total{job=%q,stage=%q}[10m])) / %s > %s`,
+				job, stage, lookups, number(BudgetExceededRatio)),
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning", "stage": stage},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("The %s stage often overruns its %s latency budget", stage, budget),
+				"description": fmt.Sprintf("More than %s of lookups spent longer than %s in the %s stage over the last 10 minutes.", percent(BudgetExceededRatio), budget, stage),
+			},
+		})
+	}
+	return g
+}
+
+func breakers(cfg config.Config, job string) Group {
+	g := Group{Name: job + "-upstreams"}
+	for _, name := range sortedKeys(cfg.Upstreams) {
+		target := cfg.Upstreams[name]
+		if target.Breaker.Failures == 0 {
+			continue
+		}
+		g.Rules = append(g.Rules, Rule{
+			Alert:  "CircuitBreakerOpen",
+			Expr:   fmt.Sprintf(`sum(increase(upstream_breaker_rejections_total{job=%q,target=%q}[5m])) > 0`, job, name),
+			Labels: map[string]string{"severity": "warning", "target": name},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("The circuit breaker of %s is open", name),
+				"description": fmt.Sprintf("Calls to %s are being rejected without reaching it: the breaker opened after %d consecutive failures and stays open for %s at a time.",
+					name, target.Breaker.Failures, time.Duration(target.Breaker.Cooldown)),
+			},
+		})
+	}
+	return g
+}
+
+func quota(job string) Group {
+	quotaErrors := fmt.Sprintf(`sum(increase(weather_api_key_calls_total{job=%q,result="quota"}[15m]))`, job)
+	return Group{
+		Name: job + "-quota",
+		Rules: []Rule{
+			{
+				Alert:  "WeatherAPIKeyQuotaExceeded",
+				Expr:   quotaErrors + " > 0",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary":     "A weather API key ran out of quota",
+					"description": "At least one weather API key was rejected for quota in the last 15 minutes and was quarantined.",
+				},
+			},
+			{
+				Alert: "WeatherAPIQuotaExhausted",
+				Expr: fmt.Sprintf("%s\n  / sum(increase(weather_api_key_calls_total{job=%q}[15m])) > %s",
+					quotaErrors, job, number(QuotaErrorRatio)),
+				For:    "10m",
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary":     "Weather API calls are failing for quota",
+					"description": fmt.Sprintf("More than %s of weather API calls were rejected for quota; lookups are answered with quota_exceeded.", percent(QuotaErrorRatio)),
+				},
+			},
+		},
+	}
+}
+
+// number formats v for an expression without float noise such as
+// 0.07200000000000001.
+func number(v float64) string {
+	return fmt.Sprintf("%.6g", v)
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.4g%%", v*100)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package alerts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/config"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := config.Config{
+		AvailabilityTarget: 0.999,
+		BasePath:           "/api/weather/v1",
+		RouteTimeouts:      map[string]time.Duration{"/temperature": 4 * time.Second},
+		LatencyBudgets:     map[string]time.Duration{"weather": 2 * time.Second},
+		Upstreams: upstream.Config{
+			"viacep":     {Name: "viacep", Breaker: upstream.Breaker{Failures: 5, Cooldown: upstream.Duration(30 * time.Second)}},
+			"weatherapi": {Name: "weatherapi"},
+		},
+	}
+
+	rules := map[string][]Rule{}
+	for _, g := range Generate(cfg, "orchestration").Groups {
+		for _, r := range g.Rules {
+			rules[r.Alert] = append(rules[r.Alert], r)
+		}
+	}
+
+	tests := []struct {
+		alert string
+		count int
+		want  string
+	}{
+		{"FastErrorBudgetBurn", 1, ") > 0.0144"},
+		{"SlowErrorBudgetBurn", 1, ") > 0.006"},
+		{"HighRouteLatency", 1, `http_route="/api/weather/v1/temperature"}[5m]))) > 3.2`},
+		{"LatencyBudgetExceeded", 1, `stage="weather"`},
+		{"CircuitBreakerOpen", 1, `target="viacep"`},
+		// No weather API keys are configured, so no calls are counted per key
+		{"WeatherAPIQuotaExhausted", 0, ""},
+	}
+	for _, tt := range tests {
+		got := rules[tt.alert]
+		if len(got) != tt.count {
+			t.Errorf("%s: %d rules, want %d", tt.alert, len(got), tt.count)
+			continue
+		}
+		if tt.count > 0 && !strings.Contains(got[0].Expr, tt.want) {
+			t.Errorf("%s: expr %q does not contain %q", tt.alert, got[0].Expr, tt.want)
+		}
+		for _, r := range got {
+			if !strings.Contains(r.Expr, `job="orchestration"`) {
+				t.Errorf("%s: expr %q is not scoped to the job", tt.alert, r.Expr)
+			}
+		}
+	}
+
+	cfg.WeatherAPIKeys = []string{"key"}
+	groups := Generate(cfg, "orchestration").Groups
+	if last := groups[len(groups)-1]; last.Name != "orchestration-quota" || len(last.Rules) != 2 {
+		t.Errorf("last group = %+v, want the quota rules", last)
+	}
+}
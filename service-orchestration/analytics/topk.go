@@ -0,0 +1,174 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+)
+
+// Item is a tracked key and its estimated count. Error is the most the count
+// may be overestimated by, inherited from the key it replaced.
+type Item struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error,omitempty"`
+}
+
+// TopK estimates the most frequent keys of a stream in fixed memory using the
+// Space-Saving algorithm: it keeps at most capacity counters and, when a new
+// key arrives while full, it takes over the counter with the lowest count.
+// Keys that are really among the top ones are never evicted.
+type TopK struct {
+	mu       sync.Mutex
+	capacity int
+	counters map[string]*Item
+}
+
+func NewTopK(capacity int) *TopK {
+	return &TopK{capacity: capacity, counters: make(map[string]*Item, capacity)}
+}
+
+func (t *TopK) Add(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if item, ok := t.counters[key]; ok {
+		item.Count++
+		return
+	}
+	if len(t.counters) < t.capacity {
+		t.counters[key] = &Item{Key: key, Count: 1}
+		return
+	}
+
+	var min *Item
+	for _, item := range t.counters {
+		if min == nil || item.Count < min.Count {
+			min = item
+		}
+	}
+	delete(t.counters, min.Key)
+	t.counters[key] = &Item{Key: key, Count: min.Count + 1, Error: min.Count}
+}
+
+// Contains reports whether key currently holds a counter.
+func (t *TopK) Contains(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.counters[key]
+	return ok
+}
+
+// Guaranteed returns how many times key was certainly seen, its count less
+// the error inherited from the key it replaced, and how many tracked keys
+// were certainly seen more often. A key that just took over a counter is
+// guaranteed once, however high the count it inherited.
+func (t *TopK) Guaranteed(key string) (count int64, rank int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, ok := t.counters[key]
+	if !ok {
+		return 0, 0, false
+	}
+	count = item.Count - item.Error
+	for _, other := range t.counters {
+		if other.Count-other.Error > count {
+			rank++
+		}
+	}
+	return count, rank, true
+}
+
+// Top returns up to n items ordered by count, highest first.
+func (t *TopK) Top(n int) []Item {
+	t.mu.Lock()
+	items := make([]Item, 0, len(t.counters))
+	for _, item := range t.counters {
+		items = append(items, *item)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Key < items[j].Key
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+const (
+	DefaultCityLabels        = 20
+	DefaultCityLabelMinCount = 10
+)
+
+// Tracker keeps the most requested CEPs and cities.
+type Tracker struct {
+	CEPs   *TopK
+	Cities *TopK
+
+	// CityLabels is how many of the top cities CityLabel names, and
+	// CityLabelMinCount how many lookups a city needs for it.
+	CityLabels        int
+	CityLabelMinCount int64
+}
+
+func NewTracker(capacity int) *Tracker {
+	return &Tracker{
+		CEPs:              NewTopK(capacity),
+		Cities:            NewTopK(capacity),
+		CityLabels:        DefaultCityLabels,
+		CityLabelMinCount: DefaultCityLabelMinCount,
+	}
+}
+
+// Record counts a lookup; city is empty when the CEP could not be resolved.
+func (t *Tracker) Record(cep, city string) {
+	t.CEPs.Add(cep)
+	if city != "" {
+		t.Cities.Add(city)
+	}
+}
+
+// CityLabel returns city when it is among the CityLabels cities certainly
+// looked up most, at least CityLabelMinCount times, and "other" otherwise, so
+// it can be used as a metric attribute with bounded cardinality. Counts that
+// may be inherited are left out: Space-Saving admits every new key, so the
+// long tail of cities would otherwise each get a label as they arrive. A nil
+// Tracker labels every city "other".
+func (t *Tracker) CityLabel(city string) string {
+	if t == nil || city == "" {
+		return "other"
+	}
+	if count, rank, ok := t.Cities.Guaranteed(city); ok && count >= t.CityLabelMinCount && rank < t.CityLabels {
+		return city
+	}
+	return "other"
+}
+
+// TopHandler serves the most requested CEPs and cities; ?limit= caps each list
+// (default 10, at most the tracker's capacity).
+func TopHandler(t *Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := validation.NewParams(r.URL.Query())
+		limit := p.Int("limit", 10, 1, t.CEPs.capacity)
+		if errs := p.Errors(); len(errs) > 0 {
+			validation.WriteProblem(w, p.Status(), "invalid request parameters", errs)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			CEPs   []Item `json:"ceps"`
+			Cities []Item `json:"cities"`
+		}{t.CEPs.Top(limit), t.Cities.Top(limit)})
+	}
+}
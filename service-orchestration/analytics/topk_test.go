@@ -0,0 +1,105 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTopKKeepsHeavyHitters(t *testing.T) {
+	topk := NewTopK(5)
+
+	// Two heavy hitters mixed with a long tail of keys seen once
+	for i := 0; i < 100; i++ {
+		topk.Add("01001000")
+		if i%2 == 0 {
+			topk.Add("20040002")
+		}
+		topk.Add(fmt.Sprintf("tail-%d", i))
+	}
+
+	top := topk.Top(2)
+	if len(top) != 2 || top[0].Key != "01001000" || top[1].Key != "20040002" {
+		t.Fatalf("Top(2) = %+v, want 01001000 then 20040002", top)
+	}
+	if top[0].Count < 100 {
+		t.Errorf("01001000 count = %d, want >= 100", top[0].Count)
+	}
+	if got := len(topk.Top(0)); got != 5 {
+		t.Errorf("tracked keys = %d, want capacity 5", got)
+	}
+}
+
+func TestCityLabel(t *testing.T) {
+	tracker := NewTracker(50)
+	tracker.CityLabels, tracker.CityLabelMinCount = 2, 5
+	for i := 0; i < 120; i++ {
+		tracker.Record("01001000", "São Paulo")
+		if i < 110 {
+			tracker.Record("20040002", "Rio de Janeiro")
+		}
+		if i < 100 {
+			tracker.Record("30130010", "Belo Horizonte")
+		}
+	}
+
+	// A long tail of cities looked up once each, as the metric records them
+	for i := 0; i < 1000; i++ {
+		city := fmt.Sprintf("Cidade %d", i)
+		tracker.Record(fmt.Sprintf("%08d", i), city)
+		if got := tracker.CityLabel(city); got != "other" {
+			t.Fatalf("CityLabel(%s) = %q, want other", city, got)
+		}
+	}
+
+	for city, want := range map[string]string{"São Paulo": "São Paulo", "Rio de Janeiro": "Rio de Janeiro", "Belo Horizonte": "other"} {
+		if got := tracker.CityLabel(city); got != want {
+			t.Errorf("CityLabel(%s) = %q, want %q (top %d only)", city, got, want, tracker.CityLabels)
+		}
+	}
+	if got := tracker.CityLabel(""); got != "other" {
+		t.Errorf("CityLabel(\"\") = %q, want other", got)
+	}
+	if got := (*Tracker)(nil).CityLabel("São Paulo"); got != "other" {
+		t.Errorf("CityLabel on a nil Tracker = %q, want other", got)
+	}
+}
+
+func TestTopHandler(t *testing.T) {
+	tracker := NewTracker(10)
+	tracker.Record("01001000", "São Paulo")
+	tracker.Record("01001000", "São Paulo")
+	tracker.Record("20040002", "Rio de Janeiro")
+	tracker.Record("99999999", "")
+
+	rec := httptest.NewRecorder()
+	TopHandler(tracker)(rec, httptest.NewRequest(http.MethodGet, "/admin/top?limit=1", nil))
+
+	var body struct {
+		CEPs   []Item `json:"ceps"`
+		Cities []Item `json:"cities"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.CEPs) != 1 || body.CEPs[0] != (Item{Key: "01001000", Count: 2}) {
+		t.Errorf("ceps = %+v, want 01001000 x2", body.CEPs)
+	}
+	if len(body.Cities) != 1 || body.Cities[0].Key != "São Paulo" {
+		t.Errorf("cities = %+v, want São Paulo", body.Cities)
+	}
+
+	rec = httptest.NewRecorder()
+	TopHandler(tracker)(rec, httptest.NewRequest(http.MethodGet, "/admin/top?limit=abc", nil))
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("invalid limit status = %d (%s), want a 400 problem", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	TopHandler(tracker)(rec, httptest.NewRequest(http.MethodGet, "/admin/top?limit=0", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("out of range limit status = %d, want 422", rec.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/memo"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/policy"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ModeBestEffort = "best_effort"
+	ModeAtomic     = "atomic"
+)
+
+const DefaultMaxItems = 100
+
+type Request struct {
+	CEPs []string `json:"ceps"`
+}
+
+type Item struct {
+	Index  int             `json:"index"`
+	CEP    string          `json:"cep"`
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Memo lists the results the item reused from an earlier item of the
+	// batch ("city", "temperature") instead of calling the provider again.
+	Memo []string `json:"memo,omitempty"`
+}
+
+type Response struct {
+	Mode   string `json:"mode"`
+	Failed int    `json:"failed"`
+	Items  []Item `json:"items"`
+}
+
+// Handler looks up every CEP in the request body through lookup, on the
+// workers of pool so batches cannot starve single lookups. With
+// ?mode=best_effort, the default, items run concurrently, every item is
+// answered and the response is 207 when any of them failed. With ?mode=atomic
+// items run one at a time and the batch stops at the first failure, answered
+// with that item's status. Clients accepting application/x-ndjson get each
+// item as soon as it is ready instead; see stream. Items share upstream
+// results through a memo, so duplicate CEPs and CEPs of the same city call
+// each provider once.
+func Handler(lookup history.LookupFunc, pool *workpool.Pool, maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := validation.NewParams(r.URL.Query())
+		mode := p.Enum("mode", ModeBestEffort, ModeBestEffort, ModeAtomic)
+		if errs := p.Errors(); len(errs) > 0 {
+			validation.WriteProblem(w, p.Status(), "invalid request parameters", errs)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.CEPs) == 0 {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "body must be {\"ceps\": [...]} with at least one cep"})
+			return
+		}
+		if len(req.CEPs) > maxItems {
+			writeJSON(w, http.StatusRequestEntityTooLarge, models.ErrorResponse{Message: fmt.Sprintf("at most %d ceps per batch", maxItems)})
+			return
+		}
+
+		ctx := policy.WithInput(r.Context(), func(in *policy.Input) { in.BatchSize = len(req.CEPs) })
+		r = r.WithContext(memo.NewContext(ctx))
+		if wantsNDJSON(r) {
+			stream(w, r, lookup, pool, mode, req.CEPs)
+			return
+		}
+
+		resp := Response{Mode: mode, Items: make([]Item, len(req.CEPs))}
+		if mode == ModeAtomic {
+			for i, cep := range req.CEPs {
+				resp.Items[i] = runItem(r, lookup, pool, i, cep)
+				if resp.Items[i].Error != "" {
+					resp.Items = resp.Items[:i+1]
+					resp.Failed = 1
+					writeJSON(w, resp.Items[i].Status, resp)
+					return
+				}
+			}
+		} else {
+			var wg sync.WaitGroup
+			for i, cep := range req.CEPs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					resp.Items[i] = runItem(r, lookup, pool, i, cep)
+				}()
+			}
+			wg.Wait()
+			for _, item := range resp.Items {
+				if item.Error != "" {
+					resp.Failed++
+				}
+			}
+		}
+
+		status := http.StatusOK
+		if resp.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		writeJSON(w, status, resp)
+	}
+}
+
+// runItem looks up one CEP in its own span once a worker of pool is free.
+func runItem(r *http.Request, lookup history.LookupFunc, pool *workpool.Pool, i int, cep string) Item {
+	var item Item
+	tracing.WithSpan(r.Context(), otel.Tracer("service-orchestration"), "batch-item", func(ctx context.Context, span trace.Span) error {
+		ctx, hits := memo.WithHits(ctx)
+		err := pool.Do(ctx, func() {
+			item = lookupItem(ctx, lookup, r, cep)
+		})
+		if err != nil {
+			item = Item{CEP: cep, Status: http.StatusServiceUnavailable, Error: err.Error()}
+		}
+		item.Index = i
+		item.Memo = hits.Kinds()
+		span.SetAttributes(
+			attribute.Int("batch.index", i),
+			attribute.String("cep", cep),
+			attribute.Int("http.status_code", item.Status),
+			attribute.StringSlice("batch.memo_hits", item.Memo),
+		)
+		if item.Error != "" {
+			return fmt.Errorf("cep %s: %s", cep, item.Error)
+		}
+		return nil
+	})
+	return item
+}
+
+// lookupItem runs a single /temperature lookup, keeping the caller's headers so
+// content negotiation and transaction IDs apply to every item, and its
+// ?country= so every item is a postal code of the same country.
+func lookupItem(ctx context.Context, lookup history.LookupFunc, r *http.Request, cep string) Item {
+	query := url.Values{"cep": {cep}}
+	if country := r.URL.Query().Get("country"); country != "" {
+		query.Set("country", country)
+	}
+	status, body := lookup(ctx, query, r.Header.Clone())
+
+	item := Item{CEP: cep, Status: status}
+	if status < 400 {
+		item.Result = json.RawMessage(body)
+		return item
+	}
+
+	var errResp models.ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+		item.Error = errResp.Message
+	} else {
+		item.Error = strings.TrimSpace(string(body))
+	}
+	if item.Error == "" {
+		item.Error = http.StatusText(status)
+	}
+	return item
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
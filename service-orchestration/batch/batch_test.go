@@ -0,0 +1,138 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/memo"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+)
+
+func fakeLookup(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+	switch query.Get("cep") {
+	case "01001000":
+		return http.StatusOK, []byte(`{"city":"São Paulo","temp_C":20}`)
+	case "99999999":
+		return http.StatusNotFound, []byte("can not find zipcode")
+	default:
+		return http.StatusBadGateway, []byte(`{"message":"provider unavailable","code":"provider_unavailable"}`)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		ceps   string
+		status int
+		items  int
+		failed int
+	}{
+		{"all succeed", "", `["01001000","01001000"]`, http.StatusOK, 2, 0},
+		{"best effort", "?mode=best_effort", `["01001000","99999999","20040002"]`, http.StatusMultiStatus, 3, 2},
+		{"atomic stops at first failure", "?mode=atomic", `["01001000","99999999","20040002"]`, http.StatusNotFound, 2, 1},
+		{"atomic all succeed", "?mode=atomic", `["01001000"]`, http.StatusOK, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/temperature/batch"+tt.query, strings.NewReader(`{"ceps":`+tt.ceps+`}`))
+			rec := httptest.NewRecorder()
+			Handler(fakeLookup, workpool.New("batch", 2), DefaultMaxItems).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response body %q: %v", rec.Body.String(), err)
+			}
+			if len(resp.Items) != tt.items || resp.Failed != tt.failed {
+				t.Errorf("items = %d, failed = %d, want %d and %d", len(resp.Items), resp.Failed, tt.items, tt.failed)
+			}
+		})
+	}
+}
+
+func TestHandlerMemoizesLookups(t *testing.T) {
+	var calls atomic.Int32
+	lookup := func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		city, _, _ := memo.Do(ctx, memo.KindCity, query.Get("cep"), func() (string, error) {
+			calls.Add(1)
+			return "São Paulo", nil
+		})
+		body, _ := json.Marshal(map[string]string{"city": city})
+		return http.StatusOK, body
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/temperature/batch", strings.NewReader(`{"ceps":["01001000","01001000","01001000"]}`))
+	rec := httptest.NewRecorder()
+	Handler(lookup, workpool.New("batch", 2), DefaultMaxItems).ServeHTTP(rec, req)
+
+	var resp Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+	hits := 0
+	for _, item := range resp.Items {
+		if len(item.Memo) == 1 && item.Memo[0] == memo.KindCity {
+			hits++
+		}
+	}
+	if hits != 2 {
+		t.Errorf("items = %+v, want 2 annotated with a city memo hit", resp.Items)
+	}
+
+	// Memos do not outlive the batch
+	rec = httptest.NewRecorder()
+	Handler(lookup, workpool.New("batch", 2), DefaultMaxItems).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/temperature/batch", strings.NewReader(`{"ceps":["01001000"]}`)))
+	if got := calls.Load(); got != 2 {
+		t.Errorf("upstream calls after a second batch = %d, want 2", got)
+	}
+}
+
+func TestHandlerItemErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/temperature/batch", strings.NewReader(`{"ceps":["99999999","20040002"]}`))
+	rec := httptest.NewRecorder()
+	Handler(fakeLookup, workpool.New("batch", 2), DefaultMaxItems).ServeHTTP(rec, req)
+
+	var resp Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if got := resp.Items[0].Error; got != "can not find zipcode" {
+		t.Errorf("plain-text error = %q", got)
+	}
+	if got := resp.Items[1].Error; got != "provider unavailable" {
+		t.Errorf("JSON error = %q", got)
+	}
+}
+
+func TestHandlerRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+	}{
+		{"unknown mode", "?mode=all_or_nothing", `{"ceps":["01001000"]}`, http.StatusUnprocessableEntity},
+		{"empty batch", "", `{"ceps":[]}`, http.StatusBadRequest},
+		{"too many items", "", `{"ceps":["1","2","3"]}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/temperature/batch"+tt.query, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			Handler(fakeLookup, workpool.New("batch", 2), 2).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
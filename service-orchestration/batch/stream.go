@@ -0,0 +1,68 @@
+package batch
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+)
+
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Summary is the last line of a streamed batch.
+type Summary struct {
+	Mode   string `json:"mode"`
+	Items  int    `json:"items"`
+	Failed int    `json:"failed"`
+}
+
+func wantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeNDJSON)
+}
+
+// stream answers a batch as NDJSON: one Item per line, flushed as soon as it
+// is ready, then a Summary line. Best-effort items are written in the order
+// they finish, so clients match them to the request by index. The status is
+// sent before any lookup runs and is always 200; failures are reported by the
+// items and the summary.
+func stream(w http.ResponseWriter, r *http.Request, lookup history.LookupFunc, pool *workpool.Pool, mode string, ceps []string) {
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	summary := Summary{Mode: mode}
+	write := func(item Item) {
+		encoder.Encode(item)
+		rc.Flush()
+		summary.Items++
+		if item.Error != "" {
+			summary.Failed++
+		}
+	}
+
+	if mode == ModeAtomic {
+		for i, cep := range ceps {
+			item := runItem(r, lookup, pool, i, cep)
+			write(item)
+			if item.Error != "" {
+				break
+			}
+		}
+	} else {
+		items := make(chan Item, len(ceps))
+		for i, cep := range ceps {
+			go func() {
+				items <- runItem(r, lookup, pool, i, cep)
+			}()
+		}
+		for range ceps {
+			write(<-items)
+		}
+	}
+
+	encoder.Encode(summary)
+	rc.Flush()
+}
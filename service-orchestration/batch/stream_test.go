@@ -0,0 +1,75 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+)
+
+func TestHandlerStreamsNDJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		ceps    string
+		indexes map[int]bool
+		failed  int
+	}{
+		{"best effort", "", `["01001000","99999999","20040002"]`, map[int]bool{0: true, 1: true, 2: true}, 2},
+		{"atomic stops at first failure", "?mode=atomic", `["01001000","99999999","20040002"]`, map[int]bool{0: true, 1: true}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/temperature/batch"+tt.query, strings.NewReader(`{"ceps":`+tt.ceps+`}`))
+			req.Header.Set("Accept", ContentTypeNDJSON)
+			rec := httptest.NewRecorder()
+			Handler(fakeLookup, workpool.New("batch", 2), DefaultMaxItems).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != ContentTypeNDJSON {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if !rec.Flushed {
+				t.Error("response was not flushed")
+			}
+
+			var lines []string
+			scanner := bufio.NewScanner(rec.Body)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			if len(lines) != len(tt.indexes)+1 {
+				t.Fatalf("got %d lines, want %d items and a summary: %q", len(lines), len(tt.indexes), lines)
+			}
+
+			seen := make(map[int]bool)
+			for _, line := range lines[:len(lines)-1] {
+				var item Item
+				if err := json.Unmarshal([]byte(line), &item); err != nil || item.CEP == "" {
+					t.Fatalf("invalid item line %q: %v", line, err)
+				}
+				seen[item.Index] = true
+			}
+			for i := range tt.indexes {
+				if !seen[i] {
+					t.Errorf("item %d missing from %q", i, lines)
+				}
+			}
+
+			var summary Summary
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+				t.Fatalf("invalid summary line: %v", err)
+			}
+			if summary.Items != len(tt.indexes) || summary.Failed != tt.failed {
+				t.Errorf("summary = %+v, want %d items and %d failed", summary, len(tt.indexes), tt.failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+)
+
+const (
+	KindSingle = "single"
+	KindBatch  = "batch"
+)
+
+// ValidationResult describes whether a payload would be accepted and every
+// problem found in it.
+type ValidationResult struct {
+	Valid  bool                    `json:"valid"`
+	Kind   string                  `json:"kind,omitempty"`
+	Mode   string                  `json:"mode,omitempty"`
+	Items  int                     `json:"items,omitempty"`
+	Errors []validation.FieldError `json:"errors,omitempty"`
+}
+
+// ValidateHandler checks a lookup payload without running any lookup, so
+// clients can test their requests cheaply. The body is either a single lookup,
+// {"cep": "..."}, or a batch, {"ceps": [...]} with ?mode= as accepted by
+// Handler. The response is always 200 with every problem found; valid tells
+// whether the payload would be accepted.
+func ValidateHandler(maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := validation.NewParams(r.URL.Query())
+		mode := p.Enum("mode", ModeBestEffort, ModeBestEffort, ModeAtomic)
+
+		result := ValidationResult{Errors: p.Errors()}
+		result.Errors = append(result.Errors, validatePayload(r, maxItems, &result)...)
+		if result.Kind == KindBatch {
+			result.Mode = mode
+		}
+		result.Valid = len(result.Errors) == 0
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func validatePayload(r *http.Request, maxItems int, result *ValidationResult) []validation.FieldError {
+	var body map[string]json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return []validation.FieldError{{Field: "body", Code: validation.CodeInvalidType, Message: "body must be a JSON object"}}
+	}
+	if decoder.More() {
+		return []validation.FieldError{{Field: "body", Code: validation.CodeInvalidType, Message: "unexpected data after JSON value"}}
+	}
+
+	var errs []validation.FieldError
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if key != "cep" && key != "ceps" {
+			errs = append(errs, validation.FieldError{Field: key, Code: validation.CodeNotAllowed, Message: "unknown field"})
+		}
+	}
+
+	rawCEP, single := body["cep"]
+	rawCEPs, batch := body["ceps"]
+	switch {
+	case single && batch:
+		return append(errs, validation.FieldError{Field: "body", Code: validation.CodeNotAllowed, Message: "send either cep or ceps, not both"})
+	case single:
+		result.Kind, result.Items = KindSingle, 1
+		return append(errs, validateCEP("cep", rawCEP)...)
+	case batch:
+		result.Kind = KindBatch
+	default:
+		return append(errs, validation.FieldError{Field: "ceps", Code: validation.CodeRequired, Message: "body must have cep or ceps"})
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(rawCEPs, &items); err != nil {
+		return append(errs, validation.FieldError{Field: "ceps", Code: validation.CodeInvalidType, Message: "ceps must be an array of strings"})
+	}
+	result.Items = len(items)
+	if len(items) == 0 {
+		errs = append(errs, validation.FieldError{Field: "ceps", Code: validation.CodeRequired, Message: "ceps must have at least one cep"})
+	}
+	if len(items) > maxItems {
+		errs = append(errs, validation.FieldError{Field: "ceps", Code: validation.CodeOutOfRange, Message: fmt.Sprintf("at most %d ceps per batch", maxItems)})
+	}
+	for i, item := range items {
+		errs = append(errs, validateCEP(fmt.Sprintf("ceps[%d]", i), item)...)
+	}
+	return errs
+}
+
+func validateCEP(field string, raw json.RawMessage) []validation.FieldError {
+	var cep string
+	if err := json.Unmarshal(raw, &cep); err != nil {
+		return []validation.FieldError{{Field: field, Code: validation.CodeInvalidType, Message: field + " must be a string"}}
+	}
+	if !utils.IsValidCEP(cep) {
+		return []validation.FieldError{{Field: field, Code: validation.CodeInvalidFormat, Message: field + " must be 8 digits"}}
+	}
+	return nil
+}
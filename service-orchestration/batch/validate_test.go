@@ -0,0 +1,59 @@
+package batch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		valid  bool
+		kind   string
+		fields []string
+	}{
+		{"valid single", "", `{"cep":"01001000"}`, true, KindSingle, nil},
+		{"valid batch", "?mode=atomic", `{"ceps":["01001000","20040002"]}`, true, KindBatch, nil},
+		{"invalid single", "", `{"cep":"0100-000"}`, false, KindSingle, []string{"cep"}},
+		{"invalid items", "", `{"ceps":[1,"123"]}`, false, KindBatch, []string{"ceps[0]", "ceps[1]"}},
+		{"unknown mode", "?mode=all", `{"ceps":["01001000"]}`, false, KindBatch, []string{"mode"}},
+		{"too many items", "", `{"ceps":["01001000","01001000","01001000"]}`, false, KindBatch, []string{"ceps"}},
+		{"empty batch", "", `{"ceps":[]}`, false, KindBatch, []string{"ceps"}},
+		{"ceps not an array", "", `{"ceps":"01001000"}`, false, KindBatch, []string{"ceps"}},
+		{"unknown field", "", `{"cep":"01001000","city":"x"}`, false, KindSingle, []string{"city"}},
+		{"both kinds", "", `{"cep":"01001000","ceps":[]}`, false, "", []string{"body"}},
+		{"no ceps", "", `{}`, false, "", []string{"ceps"}},
+		{"not JSON", "", `ceps`, false, "", []string{"body"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/validate"+tt.query, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			ValidateHandler(2).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			var result ValidationResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("invalid response body %q: %v", rec.Body.String(), err)
+			}
+			if result.Valid != tt.valid || result.Kind != tt.kind {
+				t.Errorf("valid = %v, kind = %q, want %v and %q", result.Valid, result.Kind, tt.valid, tt.kind)
+			}
+			var fields []string
+			for _, e := range result.Errors {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("error fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
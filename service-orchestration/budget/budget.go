@@ -0,0 +1,57 @@
+package budget
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stages of a temperature lookup that have a latency budget. StageEncode is
+// the JSON encoding of the response, part of the stage that writes it.
+const (
+	StageValidation = "validation"
+	StageCEPLookup  = "cep_lookup"
+	StageWeather    = "weather"
+	StageConvert    = "convert"
+	StageEncode     = "encode"
+)
+
+// Stages lists every stage with a latency budget, in the order a lookup runs
+// them.
+var Stages = []string{StageValidation, StageCEPLookup, StageWeather, StageConvert, StageEncode}
+
+var budgetExceeded, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.budget.exceeded",
+	metric.WithDescription("Number of request stages that took longer than their latency budget, by stage"),
+)
+
+var budgets map[string]time.Duration
+
+// Set replaces the per-stage budgets. Stages without a budget are not checked.
+func Set(b map[string]time.Duration) {
+	budgets = b
+}
+
+// Observe compares the time a stage took with its budget, annotates span with
+// the result and counts the overrun. It reports whether the budget was exceeded.
+func Observe(ctx context.Context, span trace.Span, stage string, elapsed time.Duration) bool {
+	limit, ok := budgets[stage]
+	if !ok {
+		return false
+	}
+
+	exceeded := elapsed > limit
+	span.SetAttributes(
+		attribute.String("budget.stage", stage),
+		attribute.Int64("budget.ms", limit.Milliseconds()),
+		attribute.Bool("budget.exceeded", exceeded),
+	)
+	if exceeded {
+		budgetExceeded.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", stage)))
+	}
+	return exceeded
+}
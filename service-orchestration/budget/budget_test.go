@@ -0,0 +1,33 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestObserve(t *testing.T) {
+	Set(map[string]time.Duration{StageWeather: 2 * time.Second})
+	defer Set(nil)
+
+	_, span := noop.NewTracerProvider().Tracer("test").Start(context.Background(), "stage")
+
+	tests := []struct {
+		stage    string
+		elapsed  time.Duration
+		exceeded bool
+	}{
+		{StageWeather, time.Second, false},
+		{StageWeather, 2 * time.Second, false},
+		{StageWeather, 3 * time.Second, true},
+		{StageEncode, time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		if got := Observe(context.Background(), span, tt.stage, tt.elapsed); got != tt.exceeded {
+			t.Errorf("Observe(%s, %s) = %v, want %v", tt.stage, tt.elapsed, got, tt.exceeded)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+type entry[V any] struct {
+	value     V
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// Cache is an in-memory store that remembers when each value was stored, so
+// callers can decide per use how old is too old. When full, an arbitrary entry
+// is evicted to make room.
+//
+// With a TTL set, GetOrLoad serves entries until they expire and makes
+// concurrent misses on the same key share a single load; without one it loads
+// every time.
+type Cache[V any] struct {
+	name string
+
+	mu         sync.RWMutex
+	items      map[string]entry[V]
+	maxEntries int
+
+	ttl    time.Duration
+	jitter float64
+
+	clock clock.Clock
+	rand  clock.Rand
+
+	flightMu sync.Mutex
+	flights  map[string]*flight[V]
+}
+
+// flight is a load in progress, whose result is handed to every caller that
+// missed the same key meanwhile.
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func New[V any](maxEntries int) *Cache[V] {
+	return &Cache[V]{
+		items:      make(map[string]entry[V]),
+		maxEntries: maxEntries,
+		flights:    make(map[string]*flight[V]),
+		clock:      clock.Real{},
+		rand:       clock.DefaultRand,
+	}
+}
+
+// SetClock replaces the clock used for entry ages and expirations.
+func (c *Cache[V]) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+// SetRand replaces the source used to jitter TTLs.
+func (c *Cache[V]) SetRand(r clock.Rand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rand = r
+}
+
+// SetTTL sets how long entries stay fresh for GetOrLoad. Each entry's TTL is
+// spread by up to ±jitter (a fraction, e.g. 0.1 for 10%) so keys stored
+// together, such as right after a deploy, do not all expire at once. A zero
+// TTL makes every entry stale immediately.
+func (c *Cache[V]) SetTTL(ttl time.Duration, jitter float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.jitter = jitter
+}
+
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok && c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		for k := range c.items {
+			delete(c.items, k)
+			break
+		}
+	}
+	now := c.clock.Now()
+	c.items[key] = entry[V]{value: value, storedAt: now, expiresAt: now.Add(c.jitteredTTL())}
+}
+
+// Get returns the value for key and how long ago it was stored, regardless of
+// whether it has expired.
+func (c *Cache[V]) Get(key string) (V, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	return e.value, c.clock.Now().Sub(e.storedAt), true
+}
+
+// Fresh returns the value for key only while it has not expired.
+func (c *Cache[V]) Fresh(key string) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.items[key]
+	if !ok || !c.clock.Now().Before(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// GetOrLoad returns the fresh value for key, or calls load to refresh it. Only
+// one load per key runs at a time; callers missing the key meanwhile wait for
+// it, until ctx is done, and get its result instead of loading again. The
+// loaded value is stored only when load reports it as cacheable and returns
+// no error. Without a TTL nothing is ever fresh, so every call loads at once
+// and only the stored value is shared, as a stale one. The
+// returned bool reports whether the value came from the cache or another
+// caller's load rather than from this call's.
+func (c *Cache[V]) GetOrLoad(ctx context.Context, key string, load func() (V, bool, error)) (V, bool, error) {
+	c.mu.RLock()
+	ttl := c.ttl
+	c.mu.RUnlock()
+	if ttl <= 0 {
+		v, cacheable, err := load()
+		if err == nil && cacheable {
+			// Kept for callers that accept stale values
+			c.Set(key, v)
+		}
+		return v, false, err
+	}
+
+	for {
+		if v, ok := c.Fresh(key); ok {
+			return v, true, nil
+		}
+
+		c.flightMu.Lock()
+		f, shared := c.flights[key]
+		if !shared {
+			f = &flight[V]{done: make(chan struct{})}
+			c.flights[key] = f
+		}
+		c.flightMu.Unlock()
+
+		if !shared {
+			return c.fly(key, f, load)
+		}
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			var zero V
+			return zero, false, ctx.Err()
+		}
+		// A load canceled or timed out with the request that started it says
+		// nothing of the key; callers still waiting for a value load it again
+		if isContextErr(f.err) && ctx.Err() == nil {
+			continue
+		}
+		return f.value, f.err == nil, f.err
+	}
+}
+
+// fly runs load for the callers waiting on f. A load that panics fails
+// them with an error rather than its zero value, and the panic goes on.
+func (c *Cache[V]) fly(key string, f *flight[V], load func() (V, bool, error)) (V, bool, error) {
+	returned := false
+	defer func() {
+		var r any
+		if !returned {
+			r = recover()
+			f.err = fmt.Errorf("cache: load panicked: %v", r)
+		}
+		c.flightMu.Lock()
+		delete(c.flights, key)
+		c.flightMu.Unlock()
+		close(f.done)
+		if !returned {
+			panic(r)
+		}
+	}()
+
+	v, cacheable, err := load()
+	returned = true
+	if err == nil && cacheable {
+		c.Set(key, v)
+	}
+	f.value, f.err = v, err
+	return v, false, err
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+func (c *Cache[V]) jitteredTTL() time.Duration {
+	if c.ttl <= 0 || c.jitter <= 0 {
+		return c.ttl
+	}
+	spread := (c.rand()*2 - 1) * c.jitter
+	return c.ttl + time.Duration(float64(c.ttl)*spread)
+}
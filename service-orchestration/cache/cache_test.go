@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func TestCache(t *testing.T) {
+	c := New[string](2)
+
+	if _, _, ok := c.Get("01001000"); ok {
+		t.Fatal("Get on empty cache should miss")
+	}
+
+	c.Set("01001000", "São Paulo")
+	v, age, ok := c.Get("01001000")
+	if !ok || v != "São Paulo" {
+		t.Errorf("Get(01001000) = %q, %v, want São Paulo", v, ok)
+	}
+	if age < 0 {
+		t.Errorf("Get(01001000) age = %s, want >= 0", age)
+	}
+
+	c.Set("01001000", "Sao Paulo")
+	if v, _, _ := c.Get("01001000"); v != "Sao Paulo" {
+		t.Errorf("Get after overwrite = %q, want Sao Paulo", v)
+	}
+
+	c.Set("20040002", "Rio de Janeiro")
+	c.Set("30130010", "Belo Horizonte")
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2 after eviction", c.Len())
+	}
+	if _, _, ok := c.Get("30130010"); !ok {
+		t.Error("most recently set key should be present after eviction")
+	}
+}
+
+func TestFreshHonorsTTL(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC))
+	c := New[string](10)
+	c.SetClock(clk)
+
+	c.Set("01001000", "São Paulo")
+	if _, ok := c.Fresh("01001000"); ok {
+		t.Error("entry should not be fresh without a TTL")
+	}
+	if _, _, ok := c.Get("01001000"); !ok {
+		t.Error("Get should still return expired entries")
+	}
+
+	c.SetTTL(time.Hour, 0)
+	c.Set("01001000", "São Paulo")
+	clk.Advance(59 * time.Minute)
+	if v, ok := c.Fresh("01001000"); !ok || v != "São Paulo" {
+		t.Errorf("Fresh(01001000) = %q, %v, want São Paulo", v, ok)
+	}
+
+	clk.Advance(time.Minute)
+	if _, ok := c.Fresh("01001000"); ok {
+		t.Error("entry should expire once its TTL has passed")
+	}
+	if _, age, _ := c.Get("01001000"); age != time.Hour {
+		t.Errorf("Get(01001000) age = %s, want 1h", age)
+	}
+}
+
+func TestJitteredTTL(t *testing.T) {
+	tests := []struct {
+		rand float64
+		want time.Duration
+	}{
+		{0, 48 * time.Second},
+		{0.5, time.Minute},
+		{0.75, 66 * time.Second},
+	}
+
+	for _, tt := range tests {
+		c := New[string](10)
+		c.SetTTL(time.Minute, 0.2)
+		c.SetRand(clock.Fixed(tt.rand))
+		if got := c.jitteredTTL(); got != tt.want {
+			t.Errorf("jitteredTTL() with rand %v = %s, want %s", tt.rand, got, tt.want)
+		}
+	}
+}
+
+func TestGetOrLoadSharesConcurrentLoads(t *testing.T) {
+	c := New[float64](10)
+	c.SetTTL(time.Minute, 0)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func() (float64, bool, error) {
+		loads.Add(1)
+		<-release
+		return 25, true, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _, err := c.GetOrLoad(context.Background(), "São Paulo", load); err != nil || v != 25 {
+				t.Errorf("GetOrLoad() = %v, %v, want 25", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("load called %d times, want 1", got)
+	}
+	if len(c.flights) != 0 {
+		t.Errorf("%d loads left in flight after they finished", len(c.flights))
+	}
+}
+
+func TestGetOrLoadSkipsUncacheableValues(t *testing.T) {
+	c := New[float64](10)
+	c.SetTTL(time.Minute, 0)
+
+	if _, _, err := c.GetOrLoad(context.Background(), "Recife", func() (float64, bool, error) { return 0, true, errors.New("provider down") }); err == nil {
+		t.Error("GetOrLoad should return the load error")
+	}
+	if _, _, err := c.GetOrLoad(context.Background(), "Recife", func() (float64, bool, error) { return 500, false, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want 0 after failed and uncacheable loads", c.Len())
+	}
+
+	c.GetOrLoad(context.Background(), "Recife", func() (float64, bool, error) { return 30, true, nil })
+	v, hit, _ := c.GetOrLoad(context.Background(), "Recife", func() (float64, bool, error) { return 31, true, nil })
+	if !hit || v != 30 {
+		t.Errorf("GetOrLoad() = %v, hit %v, want cached 30", v, hit)
+	}
+}
+
+func TestGetOrLoadWithoutTTLLoadsConcurrently(t *testing.T) {
+	c := New[float64](10)
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	load := func() (float64, bool, error) {
+		started <- struct{}{}
+		<-release
+		return 25, true, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, hit, _ := c.GetOrLoad(context.Background(), "Natal", load); hit {
+				t.Error("GetOrLoad() without a TTL reported a hit")
+			}
+		}()
+	}
+	// Both loads start without waiting for each other
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("loads of the same key were serialized")
+		}
+	}
+	close(release)
+	wg.Wait()
+	if _, _, ok := c.Get("Natal"); !ok {
+		t.Error("loaded value not kept for stale reads")
+	}
+}
+
+func TestGetOrLoadWaitHonorsContext(t *testing.T) {
+	c := New[float64](10)
+	c.SetTTL(time.Minute, 0)
+
+	release := make(chan struct{})
+	defer close(release)
+	go c.GetOrLoad(context.Background(), "Belém", func() (float64, bool, error) {
+		<-release
+		return 31, true, nil
+	})
+	for {
+		c.flightMu.Lock()
+		n := len(c.flights)
+		c.flightMu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := c.GetOrLoad(ctx, "Belém", func() (float64, bool, error) {
+		t.Error("waiter loaded the key again")
+		return 0, false, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetOrLoad() error = %v, want the waiter's deadline", err)
+	}
+}
+
+func TestGetOrLoadRetriesAfterLeaderContextError(t *testing.T) {
+	for _, leaderErr := range []error{context.Canceled, context.DeadlineExceeded} {
+		t.Run(leaderErr.Error(), func(t *testing.T) {
+			c := New[float64](10)
+			c.SetTTL(time.Minute, 0)
+
+			release := make(chan struct{})
+			go c.GetOrLoad(context.Background(), "Manaus", func() (float64, bool, error) {
+				<-release
+				return 0, false, leaderErr
+			})
+			waitForFlight(t, c)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				v, hit, err := c.GetOrLoad(context.Background(), "Manaus", func() (float64, bool, error) {
+					return 33, true, nil
+				})
+				if err != nil || hit || v != 33 {
+					t.Errorf("GetOrLoad() = %v, hit %v, %v, want its own load of 33", v, hit, err)
+				}
+			}()
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			<-done
+		})
+	}
+}
+
+func TestGetOrLoadFailsWaitersWhenLoadPanics(t *testing.T) {
+	c := New[float64](10)
+	c.SetTTL(time.Minute, 0)
+
+	release := make(chan struct{})
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the load's panic was swallowed")
+			}
+		}()
+		c.GetOrLoad(context.Background(), "Cuiabá", func() (float64, bool, error) {
+			<-release
+			panic("provider client bug")
+		})
+	}()
+	waitForFlight(t, c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, hit, err := c.GetOrLoad(context.Background(), "Cuiabá", func() (float64, bool, error) {
+			t.Error("waiter loaded the key again")
+			return 0, false, nil
+		})
+		if err == nil || hit {
+			t.Errorf("GetOrLoad() = %v, hit %v, %v, want the panic as an error", v, hit, err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+}
+
+func waitForFlight(t *testing.T, c *Cache[float64]) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.flightMu.Lock()
+		n := len(c.flights)
+		c.flightMu.Unlock()
+		if n == 1 {
+			return
+		}
+	}
+	t.Fatal("load never started")
+}
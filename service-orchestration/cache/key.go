@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"net/url"
+	"strings"
+)
+
+// AnyProvider marks a cached weather value that does not depend on which
+// provider answered, such as a temperature already converted to Celsius.
+const AnyProvider = "any"
+
+// WeatherKey identifies a cached weather answer. Every request option that
+// changes what a provider returns is part of the key, so a variant cached for
+// one request (e.g. with the forecast alerts) is never served to a request
+// that asked for another one.
+type WeatherKey struct {
+	// Provider is the provider that answers, or AnyProvider.
+	Provider string
+	// Query is the city or the coordinates sent to the provider.
+	Query string
+	// Extended is set for the forecast asked for the alerts.
+	Extended bool
+}
+
+// String returns the key in a canonical form: the query is compared case- and
+// space-insensitively, and an empty provider is spelled out so two spellings
+// of the same request always share an entry.
+func (k WeatherKey) String() string {
+	provider := strings.ToLower(strings.TrimSpace(k.Provider))
+	if provider == "" {
+		provider = AnyProvider
+	}
+
+	values := url.Values{
+		"provider": {provider},
+		"q":        {strings.ToLower(strings.Join(strings.Fields(k.Query), " "))},
+		"extended": {flag(k.Extended)},
+	}
+	// Encode sorts by name and escapes the values, so a query cannot forge
+	// another option
+	return "weather?" + values.Encode()
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
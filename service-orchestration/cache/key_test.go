@@ -0,0 +1,40 @@
+package cache
+
+import "testing"
+
+func TestWeatherKey(t *testing.T) {
+	base := WeatherKey{Provider: "weatherapi", Query: "São Paulo, SP, Brazil"}
+
+	same := []struct {
+		name string
+		key  WeatherKey
+	}{
+		{"case and spaces", WeatherKey{Provider: "WeatherAPI", Query: "  são paulo,  sp, brazil "}},
+	}
+	for _, tt := range same {
+		if got, want := tt.key.String(), base.String(); got != want {
+			t.Errorf("%s: key %q, want %q", tt.name, got, want)
+		}
+	}
+
+	different := []struct {
+		name string
+		key  WeatherKey
+	}{
+		{"provider", WeatherKey{Provider: "open-meteo", Query: base.Query}},
+		{"any provider", WeatherKey{Query: base.Query}},
+		{"extended", WeatherKey{Provider: base.Provider, Query: base.Query, Extended: true}},
+		{"query", WeatherKey{Provider: base.Provider, Query: "Santos, SP, Brazil"}},
+		{"query forging an option", WeatherKey{Provider: base.Provider, Query: base.Query + "&extended=1"}},
+	}
+	for _, tt := range different {
+		if tt.key.String() == base.String() {
+			t.Errorf("%s: key %q matches the base key", tt.name, tt.key.String())
+		}
+	}
+
+	if got, want := (WeatherKey{Provider: "weatherapi", Query: "Recife", Extended: true}).String(),
+		"weather?extended=1&provider=weatherapi&q=recife"; got != want {
+		t.Errorf("key = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Backend identifies where the entries live in span events. Backends that
+// serialize values (e.g. Redis) should also report the serialization time.
+const Backend = "memory"
+
+// SetName sets the name the cache is reported under in span events.
+func (c *Cache[V]) SetName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+// keyHashSecret keys the HMAC of KeyHash. Keys such as CEPs come from a
+// small space, so a plain hash of one is reversed by hashing every value.
+var keyHashSecret []byte
+
+// SetKeyHashSecret sets the secret key hashes are computed with; empty, the
+// default, leaves the key hash out of telemetry.
+func SetKeyHashSecret(secret string) {
+	keyHashSecret = []byte(secret)
+}
+
+// KeyHash identifies a key in telemetry without recording the key itself,
+// or returns "" when no secret is set.
+func KeyHash(key string) string {
+	if len(keyHashSecret) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, keyHashSecret)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// GetContext is Get, recording a cache.get event on the span in ctx.
+func (c *Cache[V]) GetContext(ctx context.Context, key string) (V, time.Duration, bool) {
+	start := time.Now()
+	v, age, ok := c.Get(key)
+	c.event(ctx, "cache.get", key, time.Since(start), attribute.Bool("cache.hit", ok))
+	return v, age, ok
+}
+
+// SetContext is Set, recording a cache.set event on the span in ctx.
+func (c *Cache[V]) SetContext(ctx context.Context, key string, value V) {
+	start := time.Now()
+	c.Set(key, value)
+	c.event(ctx, "cache.set", key, time.Since(start))
+}
+
+// GetOrLoadContext is GetOrLoad, recording a cache.get event and, when the
+// loaded value is stored, a cache.set event on the span in ctx. The time spent
+// in load is not counted as cache time.
+func (c *Cache[V]) GetOrLoadContext(ctx context.Context, key string, load func() (V, bool, error)) (V, bool, error) {
+	var (
+		loadTime time.Duration
+		stored   bool
+	)
+	start := time.Now()
+	v, hit, err := c.GetOrLoad(ctx, key, func() (V, bool, error) {
+		loadStart := time.Now()
+		v, cacheable, err := load()
+		loadTime = time.Since(loadStart)
+		stored = err == nil && cacheable
+		return v, cacheable, err
+	})
+
+	c.event(ctx, "cache.get", key, time.Since(start)-loadTime, attribute.Bool("cache.hit", hit))
+	if stored {
+		c.event(ctx, "cache.set", key, 0)
+	}
+	return v, hit, err
+}
+
+func (c *Cache[V]) event(ctx context.Context, name, key string, d time.Duration, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	c.mu.RLock()
+	cacheName := c.name
+	c.mu.RUnlock()
+
+	attrs = append(attrs,
+		attribute.String("cache.name", cacheName),
+		attribute.String("cache.backend", Backend),
+		attribute.Int64("cache.duration_us", d.Microseconds()),
+	)
+	if hash := KeyHash(key); hash != "" {
+		attrs = append(attrs, attribute.String("cache.key_hash", hash))
+	}
+	span.AddEvent(name, trace.WithAttributes(attrs...))
+}
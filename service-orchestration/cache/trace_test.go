@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestContextMethodsRecordEvents(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "lookup")
+
+	c := New[float64](10)
+	c.SetName("temperature")
+
+	c.GetOrLoadContext(ctx, "São Paulo", func() (float64, bool, error) { return 20, true, nil })
+	c.GetContext(ctx, "São Paulo")
+	span.End()
+
+	events := exporter.GetSpans()[0].Events
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Name)
+	}
+	if len(names) != 3 || names[0] != "cache.get" || names[1] != "cache.set" || names[2] != "cache.get" {
+		t.Fatalf("events = %v, want cache.get (miss), cache.set, cache.get (hit)", names)
+	}
+
+	attrs := make(map[string]any)
+	for _, kv := range events[2].Attributes {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["cache.name"] != "temperature" || attrs["cache.backend"] != Backend || attrs["cache.hit"] != true {
+		t.Errorf("cache.get attributes = %v", attrs)
+	}
+	if _, ok := attrs["cache.key_hash"]; ok {
+		t.Errorf("cache.key_hash = %v without a secret, want it left out", attrs["cache.key_hash"])
+	}
+}
+
+func TestKeyHash(t *testing.T) {
+	defer SetKeyHashSecret("")
+
+	if got := KeyHash("01001000"); got != "" {
+		t.Errorf("KeyHash() without a secret = %q, want none", got)
+	}
+
+	SetKeyHashSecret("secret-a")
+	a := KeyHash("01001000")
+	plain := sha256.Sum256([]byte("01001000"))
+	if len(a) != 16 || a == hex.EncodeToString(plain[:8]) || a != KeyHash("01001000") {
+		t.Errorf("KeyHash() = %q, want a stable keyed hash, not the plain SHA-256", a)
+	}
+	SetKeyHashSecret("secret-b")
+	if KeyHash("01001000") == a {
+		t.Error("KeyHash() did not change with the secret")
+	}
+}
@@ -0,0 +1,120 @@
+package cepdb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+)
+
+// faixas.csv follows the Correios CEP range layout
+// (cep_inicio,cep_fim,localidade,uf,ibge). The embedded file only carries the
+// main range of each state capital; mount the full export with CEP_DATASET to
+// cover every locality.
+//
+//go:embed faixas.csv
+var faixasCSV []byte
+
+type cepRange struct {
+	start, end string
+	address    models.ViaCEP
+}
+
+// Dataset answers CEP lookups from a snapshot of CEP ranges.
+type Dataset struct {
+	version string
+	ranges  []cepRange
+}
+
+// Parse reads a CEP range CSV. The dataset version is derived from its
+// contents, so two snapshots with the same data share a version.
+func Parse(data []byte) (*Dataset, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = 5
+	if _, err := r.Read(); err != nil {
+		return nil, fmt.Errorf("failed to read CEP dataset header: %w", err)
+	}
+
+	var ranges []cepRange
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CEP dataset: %w", err)
+		}
+		if len(record[0]) != 8 || len(record[1]) != 8 || record[0] > record[1] {
+			return nil, fmt.Errorf("invalid CEP range %s-%s", record[0], record[1])
+		}
+		ranges = append(ranges, cepRange{
+			start:   record[0],
+			end:     record[1],
+			address: models.ViaCEP{Localidade: record[2], UF: record[3], IBGE: record[4]},
+		})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("CEP dataset is empty")
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	sum := sha256.Sum256(data)
+	return &Dataset{version: hex.EncodeToString(sum[:6]), ranges: ranges}, nil
+}
+
+func Embedded() (*Dataset, error) {
+	return Parse(faixasCSV)
+}
+
+func LoadFile(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CEP dataset: %w", err)
+	}
+	return Parse(data)
+}
+
+func (d *Dataset) Version() string {
+	return d.version
+}
+
+func (d *Dataset) Lookup(cep string) (models.ViaCEP, bool) {
+	i := sort.Search(len(d.ranges), func(i int) bool { return d.ranges[i].end >= cep })
+	if i == len(d.ranges) || d.ranges[i].start > cep {
+		return models.ViaCEP{}, false
+	}
+	return d.ranges[i].address, true
+}
+
+// Store holds the dataset currently used for lookups and lets a refresh swap
+// it without blocking readers.
+type Store struct {
+	current atomic.Pointer[Dataset]
+}
+
+func NewStore(d *Dataset) *Store {
+	s := &Store{}
+	s.current.Store(d)
+	return s
+}
+
+func (s *Store) Replace(d *Dataset) {
+	s.current.Store(d)
+}
+
+func (s *Store) Lookup(cep string) (models.ViaCEP, bool) {
+	return s.current.Load().Lookup(cep)
+}
+
+func (s *Store) Version() string {
+	return s.current.Load().Version()
+}
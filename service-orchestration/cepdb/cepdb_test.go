@@ -0,0 +1,127 @@
+package cepdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	d, err := Embedded()
+	if err != nil {
+		t.Fatalf("embedded CEP dataset failed to load: %v", err)
+	}
+	if d.Version() == "" {
+		t.Error("embedded CEP dataset has no version")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	d, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		cep      string
+		wantOK   bool
+		wantCity string
+	}{
+		{"range start", "01000000", true, "São Paulo"},
+		{"inside range", "01001000", true, "São Paulo"},
+		{"second range of same city", "08100000", true, "São Paulo"},
+		{"range end", "91999999", true, "Porto Alegre"},
+		{"gap between ranges", "06000000", false, ""},
+		{"before first range", "00999999", false, ""},
+		{"after last range", "99999999", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, ok := d.Lookup(tt.cep)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup(%q) ok = %v, want %v", tt.cep, ok, tt.wantOK)
+			}
+			if address.Localidade != tt.wantCity {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.cep, address.Localidade, tt.wantCity)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidDatasets(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"header only":    "cep_inicio,cep_fim,localidade,uf,ibge\n",
+		"reversed range": "cep_inicio,cep_fim,localidade,uf,ibge\n02000000,01000000,São Paulo,SP,3550308\n",
+		"short cep":      "cep_inicio,cep_fim,localidade,uf,ibge\n0100,01999999,São Paulo,SP,3550308\n",
+		"missing column": "cep_inicio,cep_fim,localidade,uf,ibge\n01000000,01999999,São Paulo,SP\n",
+	}
+
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	embedded, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(embedded)
+
+	body := "cep_inicio,cep_fim,localidade,uf,ibge\n13000000,13149999,Campinas,SP,3509502\n"
+	sum := sha256.Sum256([]byte(body))
+	checksum := hex.EncodeToString(sum[:]) + "  faixas.csv\n"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/faixas.csv":
+			w.Write([]byte(body))
+		case "/faixas.csv.sha256":
+			w.Write([]byte(checksum))
+		case "/tampered.csv":
+			w.Write([]byte(body + "14000000,14114999,Ribeirão Preto,SP,3543402\n"))
+		case "/wrong.sha256":
+			w.Write([]byte(strings.Repeat("0", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	failures := []struct {
+		name        string
+		url         string
+		checksumURL string
+	}{
+		{"missing checksum", srv.URL + "/tampered.csv", ""},
+		{"tampered dataset", srv.URL + "/tampered.csv", srv.URL + "/faixas.csv.sha256"},
+		{"wrong checksum", srv.URL + "/faixas.csv", srv.URL + "/wrong.sha256"},
+	}
+	for _, tt := range failures {
+		if err := store.Refresh(context.Background(), srv.Client(), tt.url, tt.checksumURL); err == nil {
+			t.Errorf("%s: expected refresh to fail", tt.name)
+		}
+		if store.Version() != embedded.Version() {
+			t.Fatalf("%s: failed refresh replaced the current dataset", tt.name)
+		}
+	}
+
+	if err := store.Refresh(context.Background(), srv.Client(), srv.URL+"/faixas.csv", ""); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if store.Version() == embedded.Version() {
+		t.Error("refresh did not replace the dataset")
+	}
+	if address, ok := store.Lookup("13010000"); !ok || address.Localidade != "Campinas" {
+		t.Errorf("Lookup after refresh = %+v, %v", address, ok)
+	}
+}
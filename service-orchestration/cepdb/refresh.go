@@ -0,0 +1,132 @@
+package cepdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Target is the upstream target the dataset is downloaded through, so
+	// downloads get its retries, breaker, credentials and DNS settings.
+	Target = "cep-dataset"
+
+	// MaxDatasetSize bounds a downloaded snapshot; the full Correios export
+	// is well below it.
+	MaxDatasetSize = 64 << 20
+)
+
+var ErrChecksumMismatch = errors.New("CEP dataset checksum mismatch")
+
+var updateAttempts, _ = otel.Meter("service-orchestration").Int64Counter(
+	"cep_dataset.update.attempts",
+	metric.WithDescription("Number of CEP dataset update attempts, by result"),
+)
+
+// Refresh downloads the dataset at url with client, verifies it against the
+// SHA-256 published at checksumURL (url + ".sha256" when empty) and swaps it
+// into the store. The current dataset is kept when any step fails.
+func (s *Store) Refresh(ctx context.Context, client *http.Client, url, checksumURL string) error {
+	if checksumURL == "" {
+		checksumURL = url + ".sha256"
+	}
+
+	tracer := otel.Tracer("service-orchestration")
+	result := "updated"
+	err := tracing.WithSpan(ctx, tracer, "cep-dataset-update", func(ctx context.Context, span trace.Span) error {
+		span.SetAttributes(
+			attribute.String("cep_dataset.url", url),
+			attribute.String("cep_dataset.previous_version", s.Version()),
+		)
+
+		want, err := fetchChecksum(ctx, client, checksumURL)
+		if err != nil {
+			result = "checksum_unavailable"
+			return err
+		}
+
+		data, err := download(ctx, client, url, MaxDatasetSize)
+		if err != nil {
+			result = "download_failed"
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			result = "checksum_mismatch"
+			span.SetAttributes(
+				attribute.String("cep_dataset.checksum.expected", want),
+				attribute.String("cep_dataset.checksum.actual", got),
+			)
+			return ErrChecksumMismatch
+		}
+
+		d, err := Parse(data)
+		if err != nil {
+			result = "invalid_dataset"
+			return err
+		}
+
+		if d.Version() == s.Version() {
+			result = "unchanged"
+		} else {
+			s.Replace(d)
+		}
+		span.SetAttributes(attribute.String("cep_dataset.version", d.Version()))
+		return nil
+	})
+
+	updateAttempts.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	return err
+}
+
+// fetchChecksum reads a sha256sum-style file ("<hex>  <name>") and returns the
+// hex digest.
+func fetchChecksum(ctx context.Context, client *http.Client, url string) (string, error) {
+	data, err := download(ctx, client, url, 1024)
+	if err != nil {
+		return "", fmt.Errorf("failed to download CEP dataset checksum: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid CEP dataset checksum file")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+func download(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("failed to download %s: larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
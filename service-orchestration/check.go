@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/cepdb"
+	"github.com/fhsmendes/deploy-cloud-run/config"
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/fhsmendes/deploy-cloud-run/keypool"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/open-telemetry/pkg/audit"
+	"github.com/fhsmendes/open-telemetry/pkg/selfcheck"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runCheck validates what the service needs to start, without serving traffic,
+// and returns the process exit code. Providers are only called when probe is
+// set, since each call spends WeatherAPI quota.
+func runCheck(probe bool) int {
+	var cfg config.Config
+
+	checks := []selfcheck.Check{
+		{Name: "config", Run: func(ctx context.Context) error {
+			var err error
+			cfg, err = config.Load()
+			return err
+		}},
+		{Name: "secrets", Run: func(ctx context.Context) error {
+			if len(cfg.WeatherAPIKeys) == 0 {
+				return errors.New("missing APIKeyWeather or WEATHER_API_KEYS")
+			}
+			return nil
+		}},
+		{Name: "cep dataset", Run: func(ctx context.Context) error {
+			switch cfg.CEPDataset {
+			case "":
+				return nil
+			case "embedded":
+				_, err := cepdb.Embedded()
+				return err
+			}
+			_, err := cepdb.LoadFile(cfg.CEPDataset)
+			return err
+		}},
+		{Name: "storage", Run: func(ctx context.Context) error {
+			// The sqlite driver keeps the audit log in the database, so the
+			// file is only opened with the file driver
+			if cfg.DBDriver == database.DriverSQLite {
+				db, err := database.Open(cfg.DatabasePath)
+				if err != nil {
+					return err
+				}
+				return db.Close()
+			}
+			auditLog, err := audit.NewLogger(os.Getenv("AUDIT_LOG_PATH"))
+			if err != nil {
+				return err
+			}
+			return auditLog.Close()
+		}},
+	}
+
+	if os.Getenv("TRACING_ENABLED") != "false" {
+		checks = append(checks, selfcheck.Check{Name: "collector", Run: func(ctx context.Context) error {
+			return telemetry.CheckCollectors(ctx, os.Getenv)
+		}})
+	} else {
+		checks = append(checks, selfcheck.Check{Name: "collector", Skip: "tracing disabled"})
+	}
+
+	if probe {
+		checks = append(checks,
+			selfcheck.Check{Name: "provider viacep", Run: func(ctx context.Context) error {
+				_, err := utils.GetCityFromCEP(ctx, "01001000", trace.SpanFromContext(ctx))
+				return err
+			}},
+			selfcheck.Check{Name: "provider weatherapi", Run: func(ctx context.Context) error {
+				// Round-robin over the pool calls every configured key once
+				utils.SetWeatherAPIKeys(keypool.New(cfg.WeatherAPIKeys, keypool.RoundRobin, 0))
+				for range cfg.WeatherAPIKeys {
+					if _, err := utils.GetTemperature(ctx, "São Paulo", trace.SpanFromContext(ctx)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}},
+		)
+	} else {
+		checks = append(checks, selfcheck.Check{Name: "providers", Skip: "run with --probe to call them"})
+	}
+
+	if !selfcheck.Run(context.Background(), os.Stdout, 5*time.Second, checks) {
+		fmt.Println("self-check failed")
+		return 1
+	}
+	fmt.Println("self-check passed")
+	return 0
+}
@@ -0,0 +1,114 @@
+// Package cityname turns city names into weather API queries that the
+// provider resolves unambiguously, through an alias table for known problem
+// names and by appending the state and country to the rest.
+package cityname
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fhsmendes/open-telemetry/pkg/geo"
+)
+
+// Decision records how a query was built.
+type Decision string
+
+const (
+	Unchanged     Decision = "unchanged"
+	Alias         Decision = "alias"
+	Disambiguated Decision = "disambiguated"
+)
+
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+	"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+	"Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+	"Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+	"Ú", "U", "Ù", "U", "Û", "U", "Ü", "U",
+	"Ç", "C", "Ñ", "N",
+)
+
+// RemoveAccents replaces the accented letters used in Brazilian place names
+// with their plain ASCII letters.
+func RemoveAccents(s string) string {
+	return accents.Replace(s)
+}
+
+// Fold returns the form names are matched in: without accents, lowercase and
+// with single spaces.
+func Fold(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(RemoveAccents(s))), " ")
+}
+
+// Normalizer builds weather API queries for cities. The zero value leaves
+// every name unchanged.
+type Normalizer struct {
+	aliases      map[string]string
+	disambiguate bool
+}
+
+// New returns a Normalizer with aliases keyed by city name, optionally
+// followed by "/UF" to apply to a single state (e.g. "bom jesus/PI"). Keys are
+// matched after Fold. With disambiguate, names without an alias are sent as
+// "City, State, Brazil".
+func New(aliases map[string]string, disambiguate bool) *Normalizer {
+	n := &Normalizer{aliases: make(map[string]string, len(aliases)), disambiguate: disambiguate}
+	for key, query := range aliases {
+		city, uf, _ := strings.Cut(key, "/")
+		key = Fold(city)
+		if uf != "" {
+			key += "/" + strings.ToUpper(strings.TrimSpace(uf))
+		}
+		n.aliases[key] = query
+	}
+	return n
+}
+
+// LoadAliases reads a JSON object mapping city names to queries from path.
+func LoadAliases(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var aliases map[string]string
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("invalid city aliases file %s: %w", path, err)
+	}
+	for city, query := range aliases {
+		if strings.TrimSpace(city) == "" || strings.TrimSpace(query) == "" {
+			return nil, fmt.Errorf("invalid city aliases file %s: empty city or query in %q: %q", path, city, query)
+		}
+	}
+	return aliases, nil
+}
+
+// Normalize returns the query for city in uf and how it was built. A state
+// specific alias wins over one for every state.
+func (n *Normalizer) Normalize(city, uf string) (string, Decision) {
+	if n == nil {
+		return city, Unchanged
+	}
+
+	folded := Fold(city)
+	uf = strings.ToUpper(strings.TrimSpace(uf))
+	if query, ok := n.aliases[folded+"/"+uf]; ok {
+		return query, Alias
+	}
+	if query, ok := n.aliases[folded]; ok {
+		return query, Alias
+	}
+
+	state := geo.StateName(uf)
+	if !n.disambiguate || state == "" || city == "" {
+		return city, Unchanged
+	}
+	return RemoveAccents(city) + ", " + RemoveAccents(state) + ", Brazil", Disambiguated
+}
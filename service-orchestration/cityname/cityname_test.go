@@ -0,0 +1,34 @@
+package cityname
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	n := New(map[string]string{
+		"São Paulo":    "Sao Paulo, Brazil",
+		"bom jesus/pi": "-9.07,-44.36",
+	}, true)
+
+	tests := []struct {
+		name         string
+		normalizer   *Normalizer
+		city, uf     string
+		wantQuery    string
+		wantDecision Decision
+	}{
+		{"alias matched without accents", n, "sao  paulo", "SP", "Sao Paulo, Brazil", Alias},
+		{"state alias", n, "Bom Jesus", "PI", "-9.07,-44.36", Alias},
+		{"state alias for another state", n, "Bom Jesus", "RS", "Bom Jesus, Rio Grande do Sul, Brazil", Disambiguated},
+		{"accents removed", n, "Maceió", "AL", "Maceio, Alagoas, Brazil", Disambiguated},
+		{"unknown UF", n, "Recife", "", "Recife", Unchanged},
+		{"disambiguation off", New(nil, false), "Recife", "PE", "Recife", Unchanged},
+		{"nil normalizer", nil, "Recife", "PE", "Recife", Unchanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, decision := tt.normalizer.Normalize(tt.city, tt.uf)
+			if query != tt.wantQuery || decision != tt.wantDecision {
+				t.Errorf("Normalize(%q, %q) = %q, %s; want %q, %s", tt.city, tt.uf, query, decision, tt.wantQuery, tt.wantDecision)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Command genalerts writes the recommended Prometheus alerting rules for the
+// service, built from the same configuration it runs with: the availability
+// SLO, route timeouts, stage latency budgets, upstream circuit breakers and
+// weather API keys. Run it with the deployment's environment (or .env) and
+// commit or ship the output alongside it:
+//
+//	go run ./cmd/genalerts -job service-orchestration -out alerts.rules.yml
+//
+// The job must match the service name the collector exports metrics under.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/fhsmendes/deploy-cloud-run/alerts"
+	"github.com/fhsmendes/deploy-cloud-run/config"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	job := flag.String("job", "service-orchestration", "Prometheus job label of the service's metrics")
+	out := flag.String("out", "", "rules file to write; empty writes to stdout")
+	flag.Parse()
+
+	// Like the service, values from .env never override the environment
+	godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(alerts.Generate(cfg, *job)); err != nil {
+		log.Fatalf("failed to write rules: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		log.Fatalf("failed to write rules: %v", err)
+	}
+}
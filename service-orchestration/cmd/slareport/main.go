@@ -0,0 +1,81 @@
+// Command slareport builds availability and latency SLA numbers per endpoint
+// and provider from the lookup history file written by the service when
+// HISTORY_PATH is set.
+//
+//	go run ./cmd/slareport -file history.jsonl -from 2025-07-01 -to 2025-08-01 -format csv
+//
+// Without -from and -to the report covers the previous calendar month (UTC).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/sla"
+)
+
+func main() {
+	file := flag.String("file", os.Getenv("HISTORY_PATH"), "lookup history file (JSON lines)")
+	fromFlag := flag.String("from", "", "start of the period, inclusive (YYYY-MM-DD or RFC 3339)")
+	toFlag := flag.String("to", "", "end of the period, exclusive (YYYY-MM-DD or RFC 3339)")
+	format := flag.String("format", "json", "output format: json or csv")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("a history file is required: pass -file or set HISTORY_PATH")
+	}
+	if *format != "json" && *format != "csv" {
+		log.Fatalf("invalid -format %q: expected json or csv", *format)
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, -1, 0)
+	var err error
+	if *fromFlag != "" {
+		if from, err = parseTime(*fromFlag); err != nil {
+			log.Fatalf("invalid -from: %v", err)
+		}
+	}
+	if *toFlag != "" {
+		if to, err = parseTime(*toFlag); err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+	}
+	if !from.Before(to) {
+		log.Fatal("-from must be before -to")
+	}
+
+	collector := sla.NewCollector(from, to)
+	if err := history.ReadFile(*file, collector.Add); err != nil {
+		log.Fatalf("failed to read lookup history: %v", err)
+	}
+	report := collector.Report()
+
+	if *format == "csv" {
+		err = sla.WriteCSV(os.Stdout, report)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+	return t.UTC(), nil
+}
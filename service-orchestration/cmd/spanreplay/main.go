@@ -0,0 +1,81 @@
+// Command spanreplay re-exports the spans a service spilled to
+// SPAN_SPILLOVER_PATH while the collector was unreachable. Spans keep their
+// original service resource, so files from either service can be replayed.
+// Move the file aside first; the service starts a new one at its next spill,
+// so spans spilled during the replay are kept for the next run:
+//
+//	mv spans.jsonl spans.replay.jsonl
+//	OTEL_EXPORTER_OTLP_INSECURE=true go run ./cmd/spanreplay -file spans.replay.jsonl -collector localhost:4317 -remove
+//
+// The exporter is configured by the same OTEL_EXPORTER_OTLP_* variables as the
+// services.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/spillover"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	file := flag.String("file", os.Getenv("SPAN_SPILLOVER_PATH"), "span spillover file (JSON lines)")
+	collector := flag.String("collector", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP gRPC endpoint")
+	batchSize := flag.Int("batch", 512, "spans per export call")
+	remove := flag.Bool("remove", false, "delete the file once every span was exported")
+	flag.Parse()
+
+	if *file == "" || *collector == "" {
+		log.Fatal("a spillover file and a collector are required: pass -file and -collector")
+	}
+	if *batchSize <= 0 {
+		log.Fatal("-batch must be positive")
+	}
+
+	exporterConfig, err := telemetry.LoadExporterConfig(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := grpc.NewClient(*collector, exporterConfig.DialOptions()...)
+	if err != nil {
+		log.Fatalf("failed to create gRPC connection: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		log.Fatalf("failed to create trace exporter: %v", err)
+	}
+
+	exported := 0
+	skipped, err := spillover.ReadFile(*file, *batchSize, func(spans []sdktrace.ReadOnlySpan) error {
+		exportCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := exporter.ExportSpans(exportCtx, spans); err != nil {
+			return err
+		}
+		exported += len(spans)
+		return nil
+	})
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	exporter.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Fatalf("replay stopped after %d spans: %v", exported, err)
+	}
+
+	log.Printf("Replayed %d spans from %s (%d unreadable lines skipped)", exported, *file, skipped)
+	if *remove {
+		if err := os.Remove(*file); err != nil {
+			log.Fatalf("failed to remove %s: %v", *file, err)
+		}
+	}
+}
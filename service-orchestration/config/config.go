@@ -0,0 +1,963 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/budget"
+	"github.com/fhsmendes/deploy-cloud-run/cepdb"
+	"github.com/fhsmendes/deploy-cloud-run/cityname"
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/idempotency"
+	"github.com/fhsmendes/deploy-cloud-run/keypool"
+	"github.com/fhsmendes/deploy-cloud-run/metricview"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/policy"
+	"github.com/fhsmendes/deploy-cloud-run/postal"
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+	"github.com/fhsmendes/open-telemetry/pkg/cassette"
+	"github.com/fhsmendes/open-telemetry/pkg/deprecation"
+	"github.com/fhsmendes/open-telemetry/pkg/maintenance"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+)
+
+const (
+	DefaultTimeout                    = 60 * time.Second
+	DefaultProbeInterval              = time.Minute
+	DefaultCEPDatasetRefreshInterval  = 24 * time.Hour
+	DefaultWeatherCacheJitter         = 0.1
+	DefaultWeatherAlertsCacheTTL      = 10 * time.Minute
+	DefaultAdaptiveTimeoutFactor      = 1.5
+	DefaultAdaptiveTimeoutMin         = 200 * time.Millisecond
+	DefaultAdaptiveTimeoutMax         = 3 * time.Second
+	DefaultCassetteDir                = "testdata/cassettes"
+	DefaultGeocodingCacheTTL          = 24 * time.Hour
+	DefaultWeatherCrossCheckThreshold = 3.0
+	DefaultAvailabilityTarget         = 0.995
+	DefaultCachePrefetchInterval      = 10 * time.Minute
+)
+
+// DefaultRouteTimeouts must stay below the timeout service-input uses for its
+// call to this service, so the caller sees our 504 instead of its own.
+var DefaultRouteTimeouts = map[string]time.Duration{
+	"/temperature": 4 * time.Second,
+}
+
+// DefaultLatencyBudgets split the /temperature route timeout between the
+// stages of a lookup, keeping most of it for the two provider calls.
+var DefaultLatencyBudgets = map[string]time.Duration{
+	budget.StageValidation: 5 * time.Millisecond,
+	budget.StageCEPLookup:  1500 * time.Millisecond,
+	budget.StageWeather:    2 * time.Second,
+	budget.StageConvert:    5 * time.Millisecond,
+	budget.StageEncode:     5 * time.Millisecond,
+}
+
+type Config struct {
+	// DefaultTimeout, from HTTP_TIMEOUT (e.g. "60s"), bounds routes without
+	// one in RouteTimeouts, which ROUTE_TIMEOUTS (e.g.
+	// "/temperature=2s,/temperature/batch=10s") adds to.
+	DefaultTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+
+	// LatencyBudgets is how long each stage of a lookup may take before it is
+	// flagged as slow. LATENCY_BUDGETS (e.g. "weather=1s,encode=2ms", by
+	// budget.Stages names) overrides DefaultLatencyBudgets.
+	LatencyBudgets map[string]time.Duration
+
+	// StageTimeouts cancels a stage of a lookup that runs longer; stages
+	// without one are bounded only by the route timeout. STAGE_TIMEOUTS (e.g.
+	// "cep_lookup=2s") takes the same names as LATENCY_BUDGETS but encode.
+	StageTimeouts map[string]time.Duration
+
+	// BatchWorkers, from BATCH_WORKERS, bounds how many batch items and
+	// replays run at once.
+	BatchWorkers int
+
+	// ProbeInterval, from PROVIDER_PROBE_INTERVAL, controls the provider
+	// health prober; zero disables it. The weather API bills every call, so
+	// it is only probed when PROVIDER_PROBE_WEATHERAPI is "true".
+	ProbeInterval   time.Duration
+	ProbeWeatherAPI bool
+
+	// JobSchedules overrides the cron schedule of scheduled jobs by name
+	// (JOB_SCHEDULES, e.g. "provider-prober=*/5 * * * *;cep-dataset-refresh=@daily"),
+	// and JobsDisabled lists the jobs that start paused (JOBS_DISABLED,
+	// comma-separated).
+	JobSchedules map[string]string
+	JobsDisabled []string
+
+	// UFPolicy allows or denies states through UF_ALLOWLIST and UF_DENYLIST.
+	UFPolicy policy.UFPolicy
+	// AccessPolicy, loaded from POLICY_FILE, decides which requests are
+	// served by API key tier, route, UF and batch size; nil serves every
+	// request. APIKeyTiers, from API_KEY_TIERS, maps the X-API-Key of
+	// callers to their tier.
+	AccessPolicy *policy.AccessPolicy
+	APIKeyTiers  map[string]string
+	// AdminAPIKeys, from ADMIN_API_KEYS, are the X-API-Key values that open
+	// /drain and /admin/*, mapped to the identity audited for them; without
+	// any those routes reject every request.
+	AdminAPIKeys middleware.APIKeys
+
+	// FieldNaming is the default response key naming, set by
+	// JSON_FIELD_NAMING ("legacy" or "snake_case"); clients can override it
+	// per request with an Accept profile.
+	FieldNaming models.FieldNaming
+
+	// WeatherCacheTTL, from WEATHER_CACHE_TTL, is how long a temperature is
+	// served from the cache before the weather API is called again; zero
+	// always calls it. WeatherCacheJitter, from WEATHER_CACHE_TTL_JITTER,
+	// spreads that TTL per entry (0.1 = ±10%).
+	WeatherCacheTTL    time.Duration
+	WeatherCacheJitter float64
+	// WeatherAlertsCacheTTL, from WEATHER_ALERTS_CACHE_TTL, is how long the
+	// alerts of a location are reused for extended responses; it shares
+	// WeatherCacheJitter.
+	WeatherAlertsCacheTTL time.Duration
+	// CachePrefetchTop, from CACHE_PREFETCH_TOP, is how many of the most
+	// looked up CEPs the cache-prefetch job resolves every
+	// CachePrefetchInterval (CACHE_PREFETCH_INTERVAL), so their address and
+	// temperature are cached before they are asked for again; zero disables
+	// the job.
+	CachePrefetchTop      int
+	CachePrefetchInterval time.Duration
+	// CacheKeyHashSecret, from CACHE_KEY_HASH_SECRET, keys the cache key
+	// hashes recorded in span events; empty leaves them out.
+	CacheKeyHashSecret string
+
+	// AdaptiveTimeouts, enabled by ADAPTIVE_TIMEOUTS=true, sets each
+	// provider's call deadline to the p99 of its recent latencies times
+	// AdaptiveTimeoutFactor (ADAPTIVE_TIMEOUT_FACTOR), clamped to
+	// [AdaptiveTimeoutMin, AdaptiveTimeoutMax] (ADAPTIVE_TIMEOUT_MIN and
+	// ADAPTIVE_TIMEOUT_MAX).
+	AdaptiveTimeouts      bool
+	AdaptiveTimeoutFactor float64
+	AdaptiveTimeoutMin    time.Duration
+	AdaptiveTimeoutMax    time.Duration
+
+	// WeatherAPIKeys, from WEATHER_API_KEYS (comma-separated, falling back to
+	// the key of the weatherapi target), are spread across requests with
+	// WeatherAPIKeyStrategy (WEATHER_API_KEY_STRATEGY); a key that hits its
+	// quota is set aside for WeatherAPIKeyQuarantine
+	// (WEATHER_API_KEY_QUARANTINE).
+	WeatherAPIKeys          []string
+	WeatherAPIKeyStrategy   keypool.Strategy
+	WeatherAPIKeyQuarantine time.Duration
+
+	// CityAliases, from CITY_ALIASES_FILE (a JSON object of city, or
+	// "city/UF", to query), replace the weather API query of known problem
+	// city names; with CityQueryDisambiguate, which CITY_QUERY_DISAMBIGUATE
+	// "false" turns off, other names are sent as "City, State, Brazil".
+	CityAliases           map[string]string
+	CityQueryDisambiguate bool
+
+	// AllowClientCity lets callers send the city of the CEP as ?city= to
+	// skip ViaCEP; ALLOW_CLIENT_CITY "false" disallows it.
+	AllowClientCity bool
+
+	// RejectImplausibleTemperatures fails lookups whose temperature is out
+	// of the plausible range, set by TEMPERATURE_RANGE_MODE=reject; the
+	// default, "flag", answers them marked as out of range.
+	RejectImplausibleTemperatures bool
+
+	// WeatherCrossCheckPercent (WEATHER_CROSSCHECK_PERCENT) of fresh weather
+	// API readings are compared with Open-Meteo; differences above
+	// WeatherCrossCheckThreshold (WEATHER_CROSSCHECK_THRESHOLD) degrees
+	// Celsius are reported.
+	WeatherCrossCheckPercent   float64
+	WeatherCrossCheckThreshold float64
+
+	// WeatherProviders, from WEATHER_PROVIDERS (e.g. "weatherapi,open-meteo"),
+	// are the weather providers lookups may use, in their static order; with
+	// more than one, each lookup goes to the one with the best recent p95
+	// latency and success rate once every provider has RoutingMinSamples
+	// (ROUTING_MIN_SAMPLES) calls, and RoutingExplorePercent
+	// (ROUTING_EXPLORE_PERCENT) of lookups try another one first.
+	WeatherProviders []string
+	// WeatherProvidersByCountry, from WEATHER_PROVIDERS_BY_COUNTRY (e.g.
+	// "PT=open-meteo,weatherapi;US=weatherapi"), replaces WeatherProviders
+	// for the postal codes of a country, e.g. to prefer the provider that
+	// covers its region best. Each country is routed on its own recent
+	// latencies.
+	WeatherProvidersByCountry map[string][]string
+	RoutingMinSamples         int
+	RoutingExplorePercent     float64
+
+	// GeocodingProviders, from GEOCODING_PROVIDERS (e.g.
+	// "open-meteo,nominatim"), are tried in order to find the coordinates of
+	// cities missing from the embedded dataset; empty disables geocoding.
+	// Results are cached for GeocodingCacheTTL (GEOCODING_CACHE_TTL).
+	GeocodingProviders []string
+	GeocodingCacheTTL  time.Duration
+
+	// IdempotencyKeyTTL, from IDEMPOTENCY_KEY_TTL, is how long a batch
+	// response is replayed for retries carrying the same Idempotency-Key.
+	IdempotencyKeyTTL time.Duration
+
+	// DBDriver, from DB_DRIVER, selects where the lookup history, idempotency
+	// keys and audit log are kept: database.DriverFile, or
+	// database.DriverSQLite at DatabasePath (DATABASE_PATH).
+	DBDriver     string
+	DatabasePath string
+
+	// HistoryExport configures /admin/history/export through
+	// HISTORY_EXPORT_SYNC_MAX_RANGE, HISTORY_EXPORT_DIR and
+	// HISTORY_EXPORT_WEBHOOK_URL.
+	HistoryExport history.ExportConfig
+	// HistoryRetention bounds the lookups kept in the database or
+	// HISTORY_PATH (HISTORY_RETENTION_DAYS, HISTORY_PURGE_GRACE and
+	// HISTORY_PURGE_BATCH_SIZE), purged every HistoryPurgeInterval
+	// (HISTORY_PURGE_INTERVAL) by the history-purge job.
+	HistoryRetention     history.Retention
+	HistoryPurgeInterval time.Duration
+
+	// AvailabilityTarget, from SLO_AVAILABILITY_TARGET (e.g. "0.995"), is
+	// the share of requests that must not fail with a 5xx status, the SLO
+	// the generated error rate alerts burn against.
+	AvailabilityTarget float64
+
+	// Upstreams are the provider targets, starting from
+	// utils.DefaultUpstreams; see upstream.Load for UPSTREAMS_FILE.
+	Upstreams upstream.Config
+
+	// DNS configures how provider hosts are resolved; see
+	// upstream.LoadDNSConfig.
+	DNS upstream.DNSConfig
+
+	// ProviderCallPrices, from PROVIDER_CALL_PRICES (e.g.
+	// "weatherapi=0.0005"), is the price of one call to each provider, used
+	// to estimate the cost of every request.
+	ProviderCallPrices map[string]float64
+
+	// TrustedProxies, from TRUSTED_PROXIES (comma-separated CIDRs), limits
+	// whose forwarding headers determine the client IP; empty trusts no
+	// headers, unless TrustAllProxies ("*") trusts them all.
+	TrustedProxies  []netip.Prefix
+	TrustAllProxies bool
+	// RateLimit, from RATE_LIMIT, is the requests per second each client IP
+	// may send, in bursts of up to RateBurst (RATE_LIMIT_BURST); zero
+	// disables the limit.
+	RateLimit float64
+	RateBurst int
+
+	// CassetteMode, from HTTP_CASSETTE_MODE ("record" or "replay"), records
+	// upstream responses to CassetteDir (HTTP_CASSETTE_DIR), or replays them
+	// from it instead of calling the providers.
+	CassetteMode cassette.Mode
+	CassetteDir  string
+
+	// StubWeatherTemperature, when set by STUB_WEATHER_TEMPERATURE, is the
+	// temperature in Celsius every lookup answers without calling the
+	// weather providers, for smoke tests.
+	StubWeatherTemperature *float64
+
+	// TLS is only needed outside Cloud Run, which terminates TLS itself; see
+	// serve.LoadTLSConfig.
+	TLS serve.TLSConfig
+
+	// BasePath, from BASE_PATH (e.g. "/api/weather/v1"), is the gateway
+	// prefix every route is served under; empty serves them from the root.
+	BasePath string
+
+	// Maintenance is the state the maintenance switch starts in, read by
+	// maintenance.LoadState; it can be changed at runtime through
+	// /admin/maintenance.
+	Maintenance maintenance.State
+
+	// Deprecation marks routes and response fields as deprecated; see
+	// deprecation.LoadConfig.
+	Deprecation deprecation.Config
+
+	// Metrics shapes the exported metric streams: METRIC_VIEWS_FILE (a JSON
+	// array of metricview.View), METRIC_DROP_ATTRIBUTES (comma-separated)
+	// and METRIC_CARDINALITY_LIMIT.
+	Metrics metricview.Config
+
+	// CEPDataset, from CEP_DATASET, enables the offline CEP fallback:
+	// "embedded" uses the dataset shipped with the binary, anything else is
+	// the path of a mounted file. Empty disables the fallback. The dataset is
+	// refreshed every CEPDatasetRefreshInterval
+	// (CEP_DATASET_REFRESH_INTERVAL) from CEPDatasetURL (CEP_DATASET_URL, or
+	// a cep-dataset target in UPSTREAMS_FILE), checked against
+	// CEPDatasetChecksumURL (CEP_DATASET_CHECKSUM_URL).
+	CEPDataset                string
+	CEPDatasetURL             string
+	CEPDatasetChecksumURL     string
+	CEPDatasetRefreshInterval time.Duration
+}
+
+// Load reads the Config from the environment, starting from the defaults
+// above. Each field documents the variables it is read from.
+func Load() (Config, error) {
+	cfg := Config{
+		DefaultTimeout:  DefaultTimeout,
+		RouteTimeouts:   make(map[string]time.Duration),
+		LatencyBudgets:  make(map[string]time.Duration),
+		ProbeInterval:   DefaultProbeInterval,
+		ProbeWeatherAPI: os.Getenv("PROVIDER_PROBE_WEATHERAPI") == "true",
+		BatchWorkers:    workpool.DefaultBatchWorkers,
+
+		CEPDataset:                os.Getenv("CEP_DATASET"),
+		CEPDatasetURL:             os.Getenv("CEP_DATASET_URL"),
+		CEPDatasetChecksumURL:     os.Getenv("CEP_DATASET_CHECKSUM_URL"),
+		CEPDatasetRefreshInterval: DefaultCEPDatasetRefreshInterval,
+		CacheKeyHashSecret:        os.Getenv("CACHE_KEY_HASH_SECRET"),
+
+		WeatherCacheJitter:    DefaultWeatherCacheJitter,
+		WeatherAlertsCacheTTL: DefaultWeatherAlertsCacheTTL,
+		CachePrefetchInterval: DefaultCachePrefetchInterval,
+
+		AdaptiveTimeouts:      os.Getenv("ADAPTIVE_TIMEOUTS") == "true",
+		AdaptiveTimeoutFactor: DefaultAdaptiveTimeoutFactor,
+		AdaptiveTimeoutMin:    DefaultAdaptiveTimeoutMin,
+		AdaptiveTimeoutMax:    DefaultAdaptiveTimeoutMax,
+
+		CassetteDir: DefaultCassetteDir,
+
+		WeatherAPIKeyQuarantine: keypool.DefaultQuarantine,
+
+		GeocodingCacheTTL:     DefaultGeocodingCacheTTL,
+		WeatherProviders:      []string{"weatherapi"},
+		RoutingMinSamples:     routing.DefaultMinSamples,
+		RoutingExplorePercent: routing.DefaultExplorePercent,
+		IdempotencyKeyTTL:     idempotency.DefaultTTL,
+		DatabasePath:          database.DefaultPath,
+		AvailabilityTarget:    DefaultAvailabilityTarget,
+		HistoryExport: history.ExportConfig{
+			Dir:          os.Getenv("HISTORY_EXPORT_DIR"),
+			SyncMaxRange: history.DefaultExportSyncMaxRange,
+			WebhookURL:   os.Getenv("HISTORY_EXPORT_WEBHOOK_URL"),
+		},
+		HistoryRetention: history.Retention{
+			Grace:     history.DefaultPurgeGrace,
+			BatchSize: history.DefaultPurgeBatchSize,
+		},
+		HistoryPurgeInterval: history.DefaultPurgeInterval,
+
+		WeatherCrossCheckThreshold: DefaultWeatherCrossCheckThreshold,
+
+		CityQueryDisambiguate: os.Getenv("CITY_QUERY_DISAMBIGUATE") != "false",
+		AllowClientCity:       os.Getenv("ALLOW_CLIENT_CITY") != "false",
+
+		Metrics: metricview.Config{
+			DropAttributes:   splitList(metricview.DefaultDropAttributes),
+			CardinalityLimit: metricview.DefaultCardinalityLimit,
+		},
+	}
+	for route, d := range DefaultRouteTimeouts {
+		cfg.RouteTimeouts[route] = d
+	}
+	for stage, d := range DefaultLatencyBudgets {
+		cfg.LatencyBudgets[stage] = d
+	}
+
+	if v := os.Getenv("HTTP_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid HTTP_TIMEOUT: %w", err)
+		}
+		cfg.DefaultTimeout = d
+	}
+
+	if v := os.Getenv("BATCH_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid BATCH_WORKERS %q: expected a positive integer", v)
+		}
+		cfg.BatchWorkers = n
+	}
+
+	if v := os.Getenv("PROVIDER_PROBE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PROVIDER_PROBE_INTERVAL: %w", err)
+		}
+		cfg.ProbeInterval = d
+	}
+
+	if v := os.Getenv("CEP_DATASET_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid CEP_DATASET_REFRESH_INTERVAL: %w", err)
+		}
+		if d <= 0 {
+			return Config{}, fmt.Errorf("invalid CEP_DATASET_REFRESH_INTERVAL: duration must be positive")
+		}
+		cfg.CEPDatasetRefreshInterval = d
+	}
+
+	if v := os.Getenv("WEATHER_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid WEATHER_CACHE_TTL: %w", err)
+		}
+		cfg.WeatherCacheTTL = d
+	}
+
+	if v := os.Getenv("WEATHER_ALERTS_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid WEATHER_ALERTS_CACHE_TTL: %w", err)
+		}
+		cfg.WeatherAlertsCacheTTL = d
+	}
+
+	if v := os.Getenv("WEATHER_CACHE_TTL_JITTER"); v != "" {
+		jitter, err := strconv.ParseFloat(v, 64)
+		if err != nil || jitter < 0 || jitter >= 1 {
+			return Config{}, fmt.Errorf("invalid WEATHER_CACHE_TTL_JITTER %q: expected a fraction between 0 and 1", v)
+		}
+		cfg.WeatherCacheJitter = jitter
+	}
+
+	if v := os.Getenv("CACHE_PREFETCH_TOP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid CACHE_PREFETCH_TOP %q: expected a non-negative integer", v)
+		}
+		cfg.CachePrefetchTop = n
+	}
+	if v := os.Getenv("CACHE_PREFETCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid CACHE_PREFETCH_INTERVAL %q: expected a positive duration", v)
+		}
+		cfg.CachePrefetchInterval = d
+	}
+
+	switch v := os.Getenv("TEMPERATURE_RANGE_MODE"); v {
+	case "", "flag":
+	case "reject":
+		cfg.RejectImplausibleTemperatures = true
+	default:
+		return Config{}, fmt.Errorf("invalid TEMPERATURE_RANGE_MODE %q: expected \"flag\" or \"reject\"", v)
+	}
+
+	var err error
+	cfg.TrustedProxies, cfg.TrustAllProxies, err = middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	cfg.RateLimit, cfg.RateBurst, err = middleware.ParseRateLimit(os.Getenv("RATE_LIMIT"), os.Getenv("RATE_LIMIT_BURST"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	cassetteMode, err := cassette.ParseMode(os.Getenv("HTTP_CASSETTE_MODE"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid HTTP_CASSETTE_MODE: %w", err)
+	}
+	cfg.CassetteMode = cassetteMode
+	if v := os.Getenv("HTTP_CASSETTE_DIR"); v != "" {
+		cfg.CassetteDir = v
+	}
+
+	if v := os.Getenv("STUB_WEATHER_TEMPERATURE"); v != "" {
+		celsius, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(celsius) || math.IsInf(celsius, 0) {
+			return Config{}, fmt.Errorf("invalid STUB_WEATHER_TEMPERATURE %q: expected a temperature in Celsius", v)
+		}
+		cfg.StubWeatherTemperature = &celsius
+	}
+
+	tlsConfig, err := serve.LoadTLSConfig(os.Getenv)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.TLS = tlsConfig
+
+	basePath, err := serve.ParseBasePath(os.Getenv("BASE_PATH"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.BasePath = basePath
+
+	maintenanceState, err := maintenance.LoadState(os.Getenv)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Maintenance = maintenanceState
+
+	cfg.Deprecation, err = deprecation.LoadConfig(os.Getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if v := os.Getenv("METRIC_VIEWS_FILE"); v != "" {
+		views, err := metricview.LoadFile(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid METRIC_VIEWS_FILE: %w", err)
+		}
+		cfg.Metrics.Views = views
+	}
+	if v, ok := os.LookupEnv("METRIC_DROP_ATTRIBUTES"); ok {
+		cfg.Metrics.DropAttributes = splitList(v)
+	}
+	if v := os.Getenv("METRIC_CARDINALITY_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid METRIC_CARDINALITY_LIMIT %q: expected an integer", v)
+		}
+		cfg.Metrics.CardinalityLimit = n
+	}
+
+	if v := os.Getenv("ADAPTIVE_TIMEOUT_FACTOR"); v != "" {
+		factor, err := strconv.ParseFloat(v, 64)
+		if err != nil || factor < 1 {
+			return Config{}, fmt.Errorf("invalid ADAPTIVE_TIMEOUT_FACTOR %q: expected a number of at least 1", v)
+		}
+		cfg.AdaptiveTimeoutFactor = factor
+	}
+
+	if v := os.Getenv("ADAPTIVE_TIMEOUT_MIN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid ADAPTIVE_TIMEOUT_MIN: %w", err)
+		}
+		cfg.AdaptiveTimeoutMin = d
+	}
+
+	if v := os.Getenv("ADAPTIVE_TIMEOUT_MAX"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid ADAPTIVE_TIMEOUT_MAX: %w", err)
+		}
+		cfg.AdaptiveTimeoutMax = d
+	}
+
+	if cfg.AdaptiveTimeoutMin <= 0 || cfg.AdaptiveTimeoutMax < cfg.AdaptiveTimeoutMin {
+		return Config{}, fmt.Errorf("invalid adaptive timeout bounds: ADAPTIVE_TIMEOUT_MIN must be positive and not above ADAPTIVE_TIMEOUT_MAX")
+	}
+
+	cfg.DNS, err = upstream.LoadDNSConfig(os.Getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	upstreams, err := upstream.Load(os.Getenv, utils.DefaultUpstreams...)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Upstreams = upstreams
+
+	// The CEP dataset is downloaded through its own target: one from
+	// UPSTREAMS_FILE also sets the dataset URL, and CEP_DATASET_URL alone
+	// creates a plain one. Either way the response limit must fit the
+	// dataset.
+	if t, ok := upstreams[cepdb.Target]; ok {
+		if cfg.CEPDatasetURL == "" {
+			cfg.CEPDatasetURL = t.URL
+		}
+		if t.MaxResponseBytes == 0 {
+			t.MaxResponseBytes = cepdb.MaxDatasetSize
+			upstreams[cepdb.Target] = t
+		}
+	} else if cfg.CEPDatasetURL != "" {
+		upstreams[cepdb.Target] = upstream.Target{Name: cepdb.Target, URL: cfg.CEPDatasetURL, MaxResponseBytes: cepdb.MaxDatasetSize}
+	}
+
+	cfg.WeatherAPIKeys = splitList(os.Getenv("WEATHER_API_KEYS"))
+	if key := cfg.Upstreams["weatherapi"].Auth.Value; len(cfg.WeatherAPIKeys) == 0 && key != "" {
+		cfg.WeatherAPIKeys = []string{key}
+	}
+
+	if v := os.Getenv("WEATHER_CROSSCHECK_PERCENT"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 100 {
+			return Config{}, fmt.Errorf("invalid WEATHER_CROSSCHECK_PERCENT %q: expected a number from 0 to 100", v)
+		}
+		cfg.WeatherCrossCheckPercent = p
+	}
+
+	if v := os.Getenv("WEATHER_CROSSCHECK_THRESHOLD"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			return Config{}, fmt.Errorf("invalid WEATHER_CROSSCHECK_THRESHOLD %q: expected a non-negative number of degrees Celsius", v)
+		}
+		cfg.WeatherCrossCheckThreshold = t
+	}
+
+	if v := os.Getenv("WEATHER_PROVIDERS"); v != "" {
+		cfg.WeatherProviders = splitList(v)
+		for _, name := range cfg.WeatherProviders {
+			if !validWeatherProvider(name) {
+				return Config{}, fmt.Errorf("invalid WEATHER_PROVIDERS entry %q: expected weatherapi or open-meteo", name)
+			}
+		}
+	}
+
+	if v := os.Getenv("WEATHER_PROVIDERS_BY_COUNTRY"); v != "" {
+		byCountry, err := parseWeatherProvidersByCountry(v)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.WeatherProvidersByCountry = byCountry
+	}
+
+	if v := os.Getenv("ROUTING_MIN_SAMPLES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid ROUTING_MIN_SAMPLES %q: expected a positive integer", v)
+		}
+		cfg.RoutingMinSamples = n
+	}
+
+	if v := os.Getenv("ROUTING_EXPLORE_PERCENT"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 100 {
+			return Config{}, fmt.Errorf("invalid ROUTING_EXPLORE_PERCENT %q: expected a number from 0 to 100", v)
+		}
+		cfg.RoutingExplorePercent = p
+	}
+
+	if path := os.Getenv("CITY_ALIASES_FILE"); path != "" {
+		aliases, err := cityname.LoadAliases(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid CITY_ALIASES_FILE: %w", err)
+		}
+		cfg.CityAliases = aliases
+	}
+
+	cfg.GeocodingProviders = splitList(os.Getenv("GEOCODING_PROVIDERS"))
+	for _, name := range cfg.GeocodingProviders {
+		if name != "open-meteo" && name != "nominatim" {
+			return Config{}, fmt.Errorf("invalid GEOCODING_PROVIDERS entry %q: expected open-meteo or nominatim", name)
+		}
+	}
+
+	if v := os.Getenv("GEOCODING_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid GEOCODING_CACHE_TTL: %w", err)
+		}
+		cfg.GeocodingCacheTTL = d
+	}
+
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid IDEMPOTENCY_KEY_TTL: %w", err)
+		}
+		if d <= 0 {
+			return Config{}, fmt.Errorf("invalid IDEMPOTENCY_KEY_TTL: duration must be positive")
+		}
+		cfg.IdempotencyKeyTTL = d
+	}
+
+	dbDriver, err := database.ParseDriver(os.Getenv("DB_DRIVER"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid DB_DRIVER: %w", err)
+	}
+	cfg.DBDriver = dbDriver
+	if v := os.Getenv("DATABASE_PATH"); v != "" {
+		cfg.DatabasePath = v
+	}
+
+	if v := os.Getenv("SLO_AVAILABILITY_TARGET"); v != "" {
+		target, err := strconv.ParseFloat(v, 64)
+		if err != nil || target <= 0 || target >= 1 {
+			return Config{}, fmt.Errorf("invalid SLO_AVAILABILITY_TARGET %q: expected a fraction between 0 and 1", v)
+		}
+		cfg.AvailabilityTarget = target
+	}
+
+	if v := os.Getenv("HISTORY_EXPORT_SYNC_MAX_RANGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid HISTORY_EXPORT_SYNC_MAX_RANGE %q: expected a positive duration", v)
+		}
+		cfg.HistoryExport.SyncMaxRange = d
+	}
+
+	if v := os.Getenv("HISTORY_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid HISTORY_RETENTION_DAYS %q: expected a non-negative number of days", v)
+		}
+		cfg.HistoryRetention.Days = n
+	}
+	if v := os.Getenv("HISTORY_PURGE_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid HISTORY_PURGE_GRACE %q: expected a non-negative duration", v)
+		}
+		cfg.HistoryRetention.Grace = d
+	}
+	if v := os.Getenv("HISTORY_PURGE_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid HISTORY_PURGE_BATCH_SIZE %q: expected a positive integer", v)
+		}
+		cfg.HistoryRetention.BatchSize = n
+	}
+	if v := os.Getenv("HISTORY_PURGE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid HISTORY_PURGE_INTERVAL %q: expected a positive duration", v)
+		}
+		cfg.HistoryPurgeInterval = d
+	}
+
+	strategy, err := keypool.ParseStrategy(os.Getenv("WEATHER_API_KEY_STRATEGY"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid WEATHER_API_KEY_STRATEGY: %w", err)
+	}
+	cfg.WeatherAPIKeyStrategy = strategy
+
+	if v := os.Getenv("WEATHER_API_KEY_QUARANTINE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid WEATHER_API_KEY_QUARANTINE: %w", err)
+		}
+		cfg.WeatherAPIKeyQuarantine = d
+	}
+
+	prices, err := ParseProviderCallPrices(os.Getenv("PROVIDER_CALL_PRICES"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.ProviderCallPrices = prices
+
+	ufPolicy, err := policy.ParseUFPolicy(os.Getenv("UF_ALLOWLIST"), os.Getenv("UF_DENYLIST"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.UFPolicy = ufPolicy
+
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		access, err := policy.LoadAccessPolicy(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid POLICY_FILE: %w", err)
+		}
+		cfg.AccessPolicy = access
+	}
+	tiers, err := policy.ParseAPIKeyTiers(os.Getenv("API_KEY_TIERS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid API_KEY_TIERS: %w", err)
+	}
+	cfg.APIKeyTiers = tiers
+	adminKeys, err := middleware.ParseAPIKeys(os.Getenv("ADMIN_API_KEYS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid ADMIN_API_KEYS: %w", err)
+	}
+	cfg.AdminAPIKeys = adminKeys
+
+	naming, err := models.ParseFieldNaming(os.Getenv("JSON_FIELD_NAMING"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid JSON_FIELD_NAMING: %w", err)
+	}
+	cfg.FieldNaming = naming
+
+	routes, err := ParseRouteTimeouts(os.Getenv("ROUTE_TIMEOUTS"))
+	if err != nil {
+		return Config{}, err
+	}
+	for route, d := range routes {
+		cfg.RouteTimeouts[route] = d
+	}
+
+	cfg.JobSchedules, err = ParseJobSchedules(os.Getenv("JOB_SCHEDULES"))
+	if err != nil {
+		return Config{}, err
+	}
+	for _, name := range strings.Split(os.Getenv("JOBS_DISABLED"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.JobsDisabled = append(cfg.JobsDisabled, name)
+		}
+	}
+
+	budgets, err := parseDurations("LATENCY_BUDGETS", "stage", os.Getenv("LATENCY_BUDGETS"))
+	if err != nil {
+		return Config{}, err
+	}
+	if err := checkStages("LATENCY_BUDGETS", budgets, budget.Stages); err != nil {
+		return Config{}, err
+	}
+	for stage, d := range budgets {
+		cfg.LatencyBudgets[stage] = d
+	}
+
+	cfg.StageTimeouts, err = parseDurations("STAGE_TIMEOUTS", "stage", os.Getenv("STAGE_TIMEOUTS"))
+	if err != nil {
+		return Config{}, err
+	}
+	// Encoding happens within the stage that writes the response, which is
+	// never cut short
+	timedStages := slices.DeleteFunc(slices.Clone(budget.Stages), func(s string) bool { return s == budget.StageEncode })
+	if err := checkStages("STAGE_TIMEOUTS", cfg.StageTimeouts, timedStages); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func ParseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	return parseDurations("ROUTE_TIMEOUTS", "route", value)
+}
+
+// parseDurations parses a "key=duration,key=duration" list read from the
+// environment variable env.
+func parseDurations(env, key, value string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, timeout, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected %s=duration", env, entry, key)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(timeout))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", env, entry, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: duration must be positive", env, entry)
+		}
+
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations, nil
+}
+
+// checkStages rejects the entries of env whose stage is not one of stages, so
+// a misspelled stage cannot silently go unchecked.
+func checkStages(env string, durations map[string]time.Duration, stages []string) error {
+	for stage := range durations {
+		if !slices.Contains(stages, stage) {
+			return fmt.Errorf("invalid %s stage %q: expected one of %s", env, stage, strings.Join(stages, ", "))
+		}
+	}
+	return nil
+}
+
+func (c Config) TimeoutFor(route string) time.Duration {
+	if d, ok := c.RouteTimeouts[route]; ok {
+		return d
+	}
+	return c.DefaultTimeout
+}
+
+// JobSpec returns the configured schedule of the job name, or one that runs it
+// every interval.
+func (c Config) JobSpec(name string, interval time.Duration) string {
+	if spec, ok := c.JobSchedules[name]; ok {
+		return spec
+	}
+	return "@every " + interval.String()
+}
+
+// ParseJobSchedules parses a "name=spec;name=spec" list. Entries are separated
+// by semicolons because cron expressions contain spaces and commas.
+func ParseJobSchedules(value string) (map[string]string, error) {
+	schedules := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(entry, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" || spec == "" {
+			return nil, fmt.Errorf("invalid JOB_SCHEDULES entry %q: expected job=schedule", entry)
+		}
+		schedules[name] = spec
+	}
+	return schedules, nil
+}
+
+func ParseProviderCallPrices(value string) (map[string]float64, error) {
+	prices := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		provider, price, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(provider) == "" {
+			return nil, fmt.Errorf("invalid PROVIDER_CALL_PRICES entry %q: expected provider=price", entry)
+		}
+
+		p, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROVIDER_CALL_PRICES entry %q: %w", entry, err)
+		}
+		if p < 0 {
+			return nil, fmt.Errorf("invalid PROVIDER_CALL_PRICES entry %q: price must not be negative", entry)
+		}
+
+		prices[strings.TrimSpace(provider)] = p
+	}
+	return prices, nil
+}
+
+func validWeatherProvider(name string) bool {
+	return name == "weatherapi" || name == "open-meteo"
+}
+
+// weatherProviderServes reports whether the weather provider name can serve
+// every postal code of country: Open-Meteo only takes coordinates.
+func weatherProviderServes(name string, country postal.Country) bool {
+	return name != "open-meteo" || country.Coordinates
+}
+
+// parseWeatherProvidersByCountry parses semicolon separated country=providers
+// entries, the providers comma separated in their static order.
+func parseWeatherProvidersByCountry(value string) (map[string][]string, error) {
+	byCountry := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		code, list, ok := strings.Cut(entry, "=")
+		country, err := postal.Lookup(strings.TrimSpace(code))
+		if !ok || strings.TrimSpace(code) == "" || err != nil {
+			return nil, fmt.Errorf("invalid WEATHER_PROVIDERS_BY_COUNTRY entry %q: expected a supported country (%s)=providers", entry, strings.Join(postal.Codes(), ", "))
+		}
+		providers := splitList(list)
+		if len(providers) == 0 {
+			return nil, fmt.Errorf("invalid WEATHER_PROVIDERS_BY_COUNTRY entry %q: expected at least one provider", entry)
+		}
+		served := false
+		for _, name := range providers {
+			if !validWeatherProvider(name) {
+				return nil, fmt.Errorf("invalid WEATHER_PROVIDERS_BY_COUNTRY provider %q: expected weatherapi or open-meteo", name)
+			}
+			served = served || weatherProviderServes(name, country)
+		}
+		if !served {
+			return nil, fmt.Errorf("invalid WEATHER_PROVIDERS_BY_COUNTRY entry %q: open-meteo needs coordinates, which the postal codes of %s do not always resolve to; add weatherapi", entry, country.Code)
+		}
+		byCountry[country.Code] = providers
+	}
+	return byCountry, nil
+}
+
+// splitList splits a comma-separated value, skipping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
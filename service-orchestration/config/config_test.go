@@ -0,0 +1,278 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/cepdb"
+)
+
+func TestParseRouteTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]time.Duration
+		wantErr  bool
+	}{
+		{"empty", "", map[string]time.Duration{}, false},
+		{"single route", "/temperature=2s", map[string]time.Duration{"/temperature": 2 * time.Second}, false},
+		{
+			"multiple routes with spaces",
+			" /temperature=2s , /temperature/batch=10s ",
+			map[string]time.Duration{"/temperature": 2 * time.Second, "/temperature/batch": 10 * time.Second},
+			false,
+		},
+		{"missing duration", "/temperature", nil, true},
+		{"missing route", "=2s", nil, true},
+		{"invalid duration", "/temperature=fast", nil, true},
+		{"zero duration", "/temperature=0s", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseRouteTimeouts(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRouteTimeouts(%q) expected error, got %v", tt.value, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRouteTimeouts(%q) unexpected error: %v", tt.value, err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("ParseRouteTimeouts(%q) = %v, want %v", tt.value, result, tt.expected)
+			}
+			for route, d := range tt.expected {
+				if result[route] != d {
+					t.Errorf("ParseRouteTimeouts(%q)[%q] = %s, want %s", tt.value, route, result[route], d)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeoutFor(t *testing.T) {
+	cfg := Config{
+		DefaultTimeout: time.Minute,
+		RouteTimeouts:  map[string]time.Duration{"/temperature": 2 * time.Second},
+	}
+
+	if got := cfg.TimeoutFor("/temperature"); got != 2*time.Second {
+		t.Errorf("TimeoutFor(/temperature) = %s, want 2s", got)
+	}
+	if got := cfg.TimeoutFor("/version"); got != time.Minute {
+		t.Errorf("TimeoutFor(/version) = %s, want 1m", got)
+	}
+}
+
+func TestLoadRejectsUnknownStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		value   string
+		wantErr bool
+	}{
+		{"known budget", "LATENCY_BUDGETS", "weather=1s,encode=2ms", false},
+		{"misspelled budget", "LATENCY_BUDGETS", "wether=1s", true},
+		{"known stage timeout", "STAGE_TIMEOUTS", "cep_lookup=2s,convert=10ms", false},
+		{"misspelled stage timeout", "STAGE_TIMEOUTS", "cep-lookup=2s", true},
+		{"encode has no timeout", "STAGE_TIMEOUTS", "encode=10ms", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			if _, err := Load(); (err != nil) != tt.wantErr {
+				t.Errorf("Load() with %s=%q error = %v, wantErr %v", tt.env, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadCachePrefetch(t *testing.T) {
+	t.Setenv("CACHE_PREFETCH_TOP", "20")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CachePrefetchTop != 20 || cfg.CachePrefetchInterval != DefaultCachePrefetchInterval {
+		t.Errorf("prefetch = top %d every %s, want top 20 every %s", cfg.CachePrefetchTop, cfg.CachePrefetchInterval, DefaultCachePrefetchInterval)
+	}
+
+	for env, value := range map[string]string{"CACHE_PREFETCH_TOP": "-1", "CACHE_PREFETCH_INTERVAL": "0s"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() accepted %s=%q", env, value)
+			}
+		})
+	}
+}
+
+func TestLoadTemperatureRangeMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"flag", false, false},
+		{"reject", true, false},
+		{"drop", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TEMPERATURE_RANGE_MODE", tt.value)
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cfg.RejectImplausibleTemperatures != tt.want {
+				t.Errorf("RejectImplausibleTemperatures = %v, want %v", cfg.RejectImplausibleTemperatures, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultLatencyBudgets(t *testing.T) {
+	want := "validation=5ms,cep_lookup=1.5s,weather=2s,convert=5ms,encode=5ms"
+	if got := defaultLatencyBudgets(); got != want {
+		t.Errorf("defaultLatencyBudgets() = %q, want %q", got, want)
+	}
+}
+
+func TestParseProviderCallPrices(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]float64
+		wantErr  bool
+	}{
+		{"empty", "", map[string]float64{}, false},
+		{"multiple providers", " weatherapi=0.0005 , viacep=0 ", map[string]float64{"weatherapi": 0.0005, "viacep": 0}, false},
+		{"missing price", "weatherapi", nil, true},
+		{"missing provider", "=0.1", nil, true},
+		{"invalid price", "weatherapi=cheap", nil, true},
+		{"negative price", "weatherapi=-1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseProviderCallPrices(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseProviderCallPrices(%q) expected error, got %v", tt.value, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProviderCallPrices(%q) unexpected error: %v", tt.value, err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("ParseProviderCallPrices(%q) = %v, want %v", tt.value, result, tt.expected)
+			}
+			for provider, p := range tt.expected {
+				if result[provider] != p {
+					t.Errorf("ParseProviderCallPrices(%q)[%q] = %v, want %v", tt.value, provider, result[provider], p)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJobSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{
+			"cron and descriptor",
+			"provider-prober=*/5 * * * *; cep-dataset-refresh=@daily",
+			map[string]string{"provider-prober": "*/5 * * * *", "cep-dataset-refresh": "@daily"},
+			false,
+		},
+		{"cron with lists", "provider-prober=0 8,20 * * 1-5", map[string]string{"provider-prober": "0 8,20 * * 1-5"}, false},
+		{"missing schedule", "provider-prober=", nil, true},
+		{"missing name", "=@daily", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseJobSchedules(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseJobSchedules(%q) expected error, got %v", tt.value, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJobSchedules(%q) unexpected error: %v", tt.value, err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("ParseJobSchedules(%q) = %v, want %v", tt.value, result, tt.expected)
+			}
+			for name, spec := range tt.expected {
+				if result[name] != spec {
+					t.Errorf("ParseJobSchedules(%q)[%q] = %q, want %q", tt.value, name, result[name], spec)
+				}
+			}
+		})
+	}
+}
+
+func TestJobSpec(t *testing.T) {
+	cfg := Config{JobSchedules: map[string]string{"provider-prober": "*/5 * * * *"}}
+
+	if got := cfg.JobSpec("provider-prober", time.Minute); got != "*/5 * * * *" {
+		t.Errorf("JobSpec(provider-prober) = %q, want the configured schedule", got)
+	}
+	if got := cfg.JobSpec("cep-dataset-refresh", 24*time.Hour); got != "@every 24h0m0s" {
+		t.Errorf("JobSpec(cep-dataset-refresh) = %q, want @every 24h0m0s", got)
+	}
+}
+
+func TestParseWeatherProvidersByCountry(t *testing.T) {
+	got, err := parseWeatherProvidersByCountry(" pt=open-meteo, weatherapi ; US=weatherapi;")
+	if err != nil || len(got) != 2 || len(got["PT"]) != 2 || got["PT"][0] != "open-meteo" || got["US"][0] != "weatherapi" {
+		t.Errorf("parseWeatherProvidersByCountry() = %v, %v", got, err)
+	}
+	if _, err := parseWeatherProvidersByCountry("US=open-meteo"); err != nil {
+		t.Errorf("open-meteo alone should serve a country located by its postal codes: %v", err)
+	}
+	// CEPs are only located for the cities of the municipios dataset
+	for _, value := range []string{"PT", "AR=weatherapi", "=weatherapi", "PT=", "PT=accuweather", "BR=open-meteo"} {
+		if _, err := parseWeatherProvidersByCountry(value); err == nil {
+			t.Errorf("parseWeatherProvidersByCountry(%q) should fail", value)
+		}
+	}
+}
+
+func TestLoadCEPDatasetTarget(t *testing.T) {
+	t.Setenv("CEP_DATASET_URL", "https://example.com/faixas.csv")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := cfg.Upstreams[cepdb.Target]; target.URL != "https://example.com/faixas.csv" || target.MaxResponseBytes != cepdb.MaxDatasetSize {
+		t.Errorf("CEP_DATASET_URL target = %+v, want the dataset URL and size limit", target)
+	}
+
+	t.Setenv("CEP_DATASET_URL", "")
+	path := filepath.Join(t.TempDir(), "upstreams.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"cep-dataset","url":"https://mirror.example.com/faixas.csv","retries":2}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UPSTREAMS_FILE", path)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CEPDatasetURL != "https://mirror.example.com/faixas.csv" || cfg.Upstreams[cepdb.Target].MaxResponseBytes != cepdb.MaxDatasetSize {
+		t.Errorf("UPSTREAMS_FILE target gave URL %q and target %+v, want the target URL and size limit", cfg.CEPDatasetURL, cfg.Upstreams[cepdb.Target])
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fhsmendes/deploy-cloud-run/budget"
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/idempotency"
+	"github.com/fhsmendes/deploy-cloud-run/keypool"
+	"github.com/fhsmendes/deploy-cloud-run/metricview"
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+	"github.com/fhsmendes/open-telemetry/pkg/attrfilter"
+	"github.com/fhsmendes/open-telemetry/pkg/configdump"
+	"github.com/fhsmendes/open-telemetry/pkg/maintenance"
+	"github.com/fhsmendes/open-telemetry/pkg/spillover"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+)
+
+// Vars lists every environment variable the service reads, with the value it
+// falls back to, for the /admin/config dump.
+var Vars = []configdump.Var{
+	{Name: "PORT", Default: "8081"},
+	{Name: "BASE_PATH"},
+	{Name: "HTTP_TIMEOUT", Default: DefaultTimeout.String()},
+	{Name: "ROUTE_TIMEOUTS", Default: "/temperature=" + DefaultRouteTimeouts["/temperature"].String()},
+	{Name: "LATENCY_BUDGETS", Default: defaultLatencyBudgets()},
+	{Name: "STAGE_TIMEOUTS"},
+	{Name: "BATCH_WORKERS", Default: strconv.Itoa(workpool.DefaultBatchWorkers)},
+	{Name: "PROVIDER_PROBE_INTERVAL", Default: DefaultProbeInterval.String()},
+	{Name: "PROVIDER_PROBE_WEATHERAPI", Default: "false"},
+	{Name: "ASYNC_WORKERS", Default: "2"},
+	{Name: "ASYNC_QUEUE_SIZE", Default: "100"},
+	{Name: "ASYNC_MAX_ATTEMPTS", Default: "3"},
+	{Name: "ASYNC_RETRY_BACKOFF", Default: "1s"},
+	{Name: "JOB_SCHEDULES"},
+	{Name: "JOBS_DISABLED"},
+	{Name: "UF_ALLOWLIST"},
+	{Name: "UF_DENYLIST"},
+	{Name: "POLICY_FILE"},
+	{Name: "API_KEY_TIERS", Secret: true},
+	{Name: "ADMIN_API_KEYS", Secret: true},
+	{Name: "JSON_FIELD_NAMING", Default: "legacy"},
+	{Name: "CEP_DATASET"},
+	{Name: "CEP_DATASET_URL"},
+	{Name: "CEP_DATASET_CHECKSUM_URL"},
+	{Name: "CEP_DATASET_REFRESH_INTERVAL", Default: DefaultCEPDatasetRefreshInterval.String()},
+	{Name: "WEATHER_CACHE_TTL", Default: "0s"},
+	{Name: "WEATHER_ALERTS_CACHE_TTL", Default: DefaultWeatherAlertsCacheTTL.String()},
+	{Name: "WEATHER_CACHE_TTL_JITTER", Default: strconv.FormatFloat(DefaultWeatherCacheJitter, 'f', -1, 64)},
+	{Name: "CACHE_KEY_HASH_SECRET", Secret: true},
+	{Name: "PROVIDER_CALL_PRICES"},
+	{Name: "ADAPTIVE_TIMEOUTS", Default: "false"},
+	{Name: "ADAPTIVE_TIMEOUT_FACTOR", Default: strconv.FormatFloat(DefaultAdaptiveTimeoutFactor, 'f', -1, 64)},
+	{Name: "ADAPTIVE_TIMEOUT_MIN", Default: DefaultAdaptiveTimeoutMin.String()},
+	{Name: "ADAPTIVE_TIMEOUT_MAX", Default: DefaultAdaptiveTimeoutMax.String()},
+	{Name: "WEATHER_QUERY_BY_COORDINATES", Default: "false"},
+	{Name: "CITY_ALIASES_FILE"},
+	{Name: "CITY_QUERY_DISAMBIGUATE", Default: "true"},
+	{Name: "ALLOW_CLIENT_CITY", Default: "true"},
+	{Name: "WEATHER_CROSSCHECK_PERCENT", Default: "0"},
+	{Name: "WEATHER_CROSSCHECK_THRESHOLD", Default: strconv.FormatFloat(DefaultWeatherCrossCheckThreshold, 'g', -1, 64)},
+	{Name: "WEATHER_PROVIDERS", Default: "weatherapi"},
+	{Name: "WEATHER_PROVIDERS_BY_COUNTRY"},
+	{Name: "ROUTING_MIN_SAMPLES", Default: strconv.Itoa(routing.DefaultMinSamples)},
+	{Name: "ROUTING_EXPLORE_PERCENT", Default: strconv.FormatFloat(routing.DefaultExplorePercent, 'g', -1, 64)},
+	{Name: "GEOCODING_PROVIDERS"},
+	{Name: "GEOCODING_CACHE_TTL", Default: DefaultGeocodingCacheTTL.String()},
+	{Name: "WEATHER_STRICT_DECODING", Default: "false"},
+	{Name: "STALE_TEMPERATURE_MAX_AGE", Default: "1h0m0s"},
+	{Name: "TEMPERATURE_RANGE_MODE", Default: "flag"},
+	{Name: "CACHE_PREFETCH_TOP", Default: "0"},
+	{Name: "CACHE_PREFETCH_INTERVAL", Default: DefaultCachePrefetchInterval.String()},
+	{Name: "UPSTREAMS_FILE"},
+	{Name: "DNS_CACHE_TTL", Default: upstream.DefaultDNSCacheTTL.String()},
+	{Name: "DNS_HOST_OVERRIDES"},
+	{Name: "DNS_PREFER"},
+	{Name: "APIKeyWeather", Secret: true},
+	{Name: "WEATHER_API_KEYS", Secret: true},
+	{Name: "WEATHER_API_KEY_STRATEGY", Default: string(keypool.RoundRobin)},
+	{Name: "WEATHER_API_KEY_QUARANTINE", Default: keypool.DefaultQuarantine.String()},
+	{Name: "UPSTREAM_CONTACT_URL"},
+	{Name: "AUDIT_LOG_PATH"},
+	{Name: "HISTORY_PATH"},
+	{Name: "IDEMPOTENCY_PATH"},
+	{Name: "WEBHOOK_PATH"},
+	{Name: "IDEMPOTENCY_KEY_TTL", Default: idempotency.DefaultTTL.String()},
+	{Name: "DB_DRIVER", Default: database.DriverFile},
+	{Name: "DATABASE_PATH", Default: database.DefaultPath},
+	{Name: "HISTORY_EXPORT_SYNC_MAX_RANGE", Default: history.DefaultExportSyncMaxRange.String()},
+	{Name: "HISTORY_EXPORT_DIR"},
+	{Name: "HISTORY_EXPORT_WEBHOOK_URL"},
+	{Name: "HISTORY_RETENTION_DAYS", Default: "0"},
+	{Name: "HISTORY_PURGE_GRACE", Default: history.DefaultPurgeGrace.String()},
+	{Name: "HISTORY_PURGE_BATCH_SIZE", Default: strconv.Itoa(history.DefaultPurgeBatchSize)},
+	{Name: "HISTORY_PURGE_INTERVAL", Default: history.DefaultPurgeInterval.String()},
+	{Name: "SLO_AVAILABILITY_TARGET", Default: strconv.FormatFloat(DefaultAvailabilityTarget, 'f', -1, 64)},
+	{Name: "HTTP_CASSETTE_MODE", Default: "off"},
+	{Name: "STUB_WEATHER_TEMPERATURE"},
+	{Name: "HTTP_CASSETTE_DIR", Default: DefaultCassetteDir},
+	{Name: "TRUSTED_PROXIES"},
+	{Name: "RATE_LIMIT"},
+	{Name: "RATE_LIMIT_BURST"},
+	{Name: "TLS_CERT_FILE"},
+	{Name: "TLS_KEY_FILE"},
+	{Name: "TLS_AUTOCERT_DOMAINS"},
+	{Name: "TLS_AUTOCERT_CACHE_DIR"},
+	{Name: "TLS_REDIRECT_ADDR"},
+	{Name: "TLS_PUBLIC_PORT"},
+	{Name: "MAINTENANCE_MODE", Default: "false"},
+	{Name: "MAINTENANCE_MESSAGE", Default: maintenance.DefaultMessage},
+	{Name: "MAINTENANCE_RETRY_AFTER", Default: maintenance.DefaultRetryAfter.String()},
+	{Name: "DEPRECATIONS"},
+	{Name: "DEPRECATION_LINK"},
+	{Name: "DEPRECATION_CLIENTS"},
+	{Name: "TRACING_ENABLED", Default: "true"},
+	{Name: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+	{Name: "OTEL_EXPORTER_OTLP_FAILOVER_ENDPOINTS"},
+	{Name: "OTEL_EXPORTER_OTLP_FAILOVER_AFTER", Default: strconv.Itoa(telemetry.DefaultFailoverAfter)},
+	{Name: "OTEL_EXPORTER_OTLP_FAILBACK_PROBE", Default: telemetry.DefaultFailbackProbe.String()},
+	{Name: "OTEL_EXPORTER_OTLP_COMPRESSION", Default: telemetry.CompressionNone},
+	{Name: "OTEL_EXPORTER_OTLP_HEADERS", Secret: true},
+	{Name: "OTEL_EXPORTER_OTLP_INSECURE", Default: "false"},
+	{Name: "TRACE_SAMPLE_RATIO", Default: "1"},
+	{Name: "SERVICE_TEAM"},
+	{Name: "SERVICE_COST_CENTER"},
+	{Name: "SERVICE_TIER"},
+	{Name: "TRACE_BOOST_ERROR_RATE", Default: "0"},
+	{Name: "TRACE_BOOST_WINDOW", Default: telemetry.DefaultBoostWindow.String()},
+	{Name: "TRACE_BOOST_DURATION", Default: telemetry.DefaultBoostDuration.String()},
+	{Name: "TRACE_BOOST_DECAY", Default: telemetry.DefaultBoostDecay.String()},
+	{Name: "TRACE_BOOST_MIN_REQUESTS", Default: strconv.Itoa(telemetry.DefaultBoostMinRequests)},
+	{Name: "OTEL_SHUTDOWN_TIMEOUT", Default: telemetry.DefaultShutdownTimeout.String()},
+	{Name: "OTEL_SHUTDOWN_SIGNAL_TIMEOUT", Default: telemetry.DefaultSignalShutdownTimeout.String()},
+	{Name: "SPAN_SPILLOVER_PATH"},
+	{Name: "SPAN_SPILLOVER_MAX_BYTES", Default: strconv.Itoa(spillover.DefaultMaxBytes)},
+	{Name: "SPAN_ATTRIBUTE_ALLOWLIST"},
+	{Name: "SPAN_ATTRIBUTE_DENYLIST", Default: attrfilter.DefaultDeny},
+	{Name: "METRIC_VIEWS_FILE"},
+	{Name: "METRIC_DROP_ATTRIBUTES", Default: metricview.DefaultDropAttributes},
+	{Name: "METRIC_CARDINALITY_LIMIT", Default: strconv.Itoa(metricview.DefaultCardinalityLimit)},
+	{Name: "DEPLOYMENT_ENVIRONMENT", Default: "development"},
+}
+
+// defaultLatencyBudgets formats DefaultLatencyBudgets as LATENCY_BUDGETS, in
+// the order a lookup runs its stages.
+func defaultLatencyBudgets() string {
+	var entries []string
+	for _, stage := range budget.Stages {
+		if d, ok := DefaultLatencyBudgets[stage]; ok {
+			entries = append(entries, stage+"="+d.String())
+		}
+	}
+	return strings.Join(entries, ",")
+}
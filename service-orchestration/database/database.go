@@ -0,0 +1,167 @@
+// Package database opens the embedded SQLite database that can hold the lookup
+// history, the idempotency keys, the webhook deliveries and the audit log
+// instead of JSON lines files.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// Pure Go driver, so the image keeps building without cgo
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DriverFile keeps every store in memory, or in the JSON lines file set by
+	// its *_PATH variable.
+	DriverFile = "file"
+	// DriverSQLite keeps every store in one SQLite database.
+	DriverSQLite = "sqlite"
+
+	DefaultPath = "orchestration.db"
+)
+
+// ParseDriver validates DB_DRIVER; empty is DriverFile.
+func ParseDriver(value string) (string, error) {
+	switch driver := strings.ToLower(strings.TrimSpace(value)); driver {
+	case "", DriverFile:
+		return DriverFile, nil
+	case DriverSQLite:
+		return DriverSQLite, nil
+	}
+	return "", fmt.Errorf("invalid database driver %q: expected file or sqlite", value)
+}
+
+// Migration is one step of the schema, applied once and in order.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations are the schema of every store. Times are stored as Unix
+// nanoseconds and structured fields as JSON. Append new migrations; never edit
+// one that was released.
+var Migrations = []Migration{
+	{1, "create lookups", `
+CREATE TABLE lookups (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	trace_id       TEXT    NOT NULL,
+	transaction_id TEXT    NOT NULL DEFAULT '',
+	cep            TEXT    NOT NULL,
+	time           INTEGER NOT NULL,
+	route          TEXT    NOT NULL DEFAULT '',
+	status         INTEGER NOT NULL,
+	duration_ms    REAL    NOT NULL,
+	body           TEXT    NOT NULL,
+	providers      TEXT,
+	events         TEXT
+);
+CREATE INDEX lookups_trace_id ON lookups (trace_id);
+CREATE INDEX lookups_cep ON lookups (cep);
+CREATE INDEX lookups_transaction_id ON lookups (transaction_id);`},
+	{2, "create idempotency records", `
+CREATE TABLE idempotency_records (
+	key          TEXT    PRIMARY KEY,
+	request_hash TEXT    NOT NULL,
+	route        TEXT    NOT NULL,
+	status       INTEGER NOT NULL,
+	content_type TEXT    NOT NULL DEFAULT '',
+	body         TEXT    NOT NULL,
+	created      INTEGER NOT NULL,
+	expires      INTEGER NOT NULL,
+	replays      INTEGER NOT NULL DEFAULT 0,
+	last_replay  INTEGER
+);
+CREATE INDEX idempotency_records_expires ON idempotency_records (expires);`},
+	{3, "create audit entries", `
+CREATE TABLE audit_entries (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	time     INTEGER NOT NULL,
+	actor    TEXT    NOT NULL,
+	action   TEXT    NOT NULL,
+	before   TEXT,
+	after    TEXT,
+	trace_id TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX audit_entries_action ON audit_entries (action);`},
+	{4, "soft-delete lookups", `
+ALTER TABLE lookups ADD COLUMN deleted INTEGER;
+CREATE INDEX lookups_time ON lookups (time);
+CREATE INDEX lookups_deleted ON lookups (deleted);`},
+	{5, "create webhook deliveries", `
+CREATE TABLE webhook_deliveries (
+	id           TEXT    PRIMARY KEY,
+	event        TEXT    NOT NULL,
+	url          TEXT    NOT NULL,
+	payload      TEXT    NOT NULL,
+	status       TEXT    NOT NULL,
+	created      INTEGER NOT NULL,
+	next_attempt INTEGER,
+	attempts     TEXT    NOT NULL,
+	trace        TEXT
+);
+CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status);`},
+}
+
+// Open opens (or creates) the SQLite database at path and migrates it to the
+// latest schema.
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open(DriverSQLite, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; sharing one connection serializes the
+	// stores instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate applies the migrations db has not seen yet, each in its own
+// transaction, recording them in schema_migrations.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name    TEXT    NOT NULL
+)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range Migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(db, m); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
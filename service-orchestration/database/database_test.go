@@ -0,0 +1,86 @@
+package database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/audit"
+)
+
+func TestOpenMigratesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orchestration.db")
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() #%d: %v", i+1, err)
+		}
+
+		var applied int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+			t.Fatal(err)
+		}
+		if applied != len(Migrations) {
+			t.Errorf("Open() #%d: %d migrations recorded, want %d", i+1, applied, len(Migrations))
+		}
+		db.Close()
+	}
+}
+
+func TestParseDriver(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", DriverFile, false},
+		{"file", DriverFile, false},
+		{" SQLite ", DriverSQLite, false},
+		{"postgres", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDriver(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseDriver(%q) = %q, %v, want %q (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orchestration.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := audit.NewSQLLogger(db)
+	if err != nil {
+		t.Fatalf("audit.NewSQLLogger() unexpected error: %v", err)
+	}
+	at := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	l.Record(audit.Entry{Time: at, Actor: "ops@example.com", Action: "drain", Before: "ready", After: "draining"})
+	l.Record(audit.Entry{Time: at, Actor: "ops@example.com", Action: "maintenance", After: map[string]any{"enabled": true}})
+	db.Close()
+
+	// Entries must survive a restart
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	reopened, err := audit.NewSQLLogger(db)
+	if err != nil {
+		t.Fatalf("audit.NewSQLLogger() reopen unexpected error: %v", err)
+	}
+
+	entries := reopened.Entries("", "")
+	if len(entries) != 2 {
+		t.Fatalf("Entries() = %+v, want 2 entries", entries)
+	}
+	if e := entries[0]; e.Action != "drain" || e.Before != "ready" || e.After != "draining" || !e.Time.Equal(at) {
+		t.Errorf("first entry = %+v, want drain ready -> draining at %s", e, at)
+	}
+	if e := entries[1]; e.Before != nil || !reflect.DeepEqual(e.After, map[string]any{"enabled": true}) {
+		t.Errorf("second entry = %+v, want no before and enabled after", e)
+	}
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Error is a domain failure that maps to a fixed HTTP status. Errors with a
+// Code are answered with a JSON models.ErrorResponse; the ones without keep the
+// plain-text body documented in the README.
+type Error struct {
+	Status  int
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrInvalidCEP          = &Error{Status: http.StatusUnprocessableEntity, Message: "invalid zipcode"}
+	ErrInvalidFormat       = &Error{Status: http.StatusBadRequest, Message: "invalid format or locale", Code: "invalid_format"}
+	ErrCEPNotFound         = &Error{Status: http.StatusNotFound, Message: "can not find zipcode"}
+	ErrCityNotFound        = &Error{Status: http.StatusNotFound, Message: "can not find city", Code: "city_not_found"}
+	ErrUnsupportedCountry  = &Error{Status: http.StatusBadRequest, Message: "unsupported country", Code: "unsupported_country"}
+	ErrFeatureUnsupported  = &Error{Status: http.StatusBadRequest, Message: "feature not supported by the weather providers of the country", Code: "feature_unsupported"}
+	ErrUFNotAllowed        = &Error{Status: http.StatusForbidden, Message: "zipcode outside allowed states", Code: "uf_not_allowed"}
+	ErrAccessDenied        = &Error{Status: http.StatusForbidden, Message: "request not allowed by the access policy", Code: "access_denied"}
+	ErrProviderUnavailable = &Error{Status: http.StatusBadGateway, Message: "upstream provider unavailable", Code: "provider_unavailable"}
+	ErrQuotaExceeded       = &Error{Status: http.StatusServiceUnavailable, Message: "upstream provider quota exceeded", Code: "quota_exceeded"}
+)
+
+// ProviderError describes a failed call to an upstream provider; Err is nil
+// when the provider answered with an unexpected StatusCode. It matches
+// ErrQuotaExceeded when the provider rejected the call for rate or quota
+// reasons and ErrProviderUnavailable otherwise, and unwraps to the underlying
+// cause so callers can still inspect it with errors.As.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Err        error
+}
+
+func NewProviderError(provider string, statusCode int, err error) *ProviderError {
+	return &ProviderError{Provider: provider, StatusCode: statusCode, Err: err}
+}
+
+func (e *ProviderError) Error() string {
+	switch {
+	case e.Err == nil:
+		return fmt.Sprintf("%s returned status %d", e.Provider, e.StatusCode)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("%s returned status %d: %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.kind()}
+	}
+	return []error{e.kind(), e.Err}
+}
+
+func (e *ProviderError) kind() *Error {
+	// WeatherAPI answers 403 once the key's monthly quota is used up
+	if e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusForbidden {
+		return ErrQuotaExceeded
+	}
+	return ErrProviderUnavailable
+}
+
+// HTTPStatus returns the status a handler should answer err with; a deadline
+// that ran out is a gateway timeout, and other errors outside the domain are
+// internal errors.
+func HTTPStatus(err error) int {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Status
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// SpanStatus returns the span status for err. Client errors leave the status
+// unset, since the service behaved correctly; everything else is an error.
+func SpanStatus(err error) (codes.Code, string) {
+	if err == nil {
+		return codes.Ok, ""
+	}
+	if HTTPStatus(err) < http.StatusInternalServerError {
+		return codes.Unset, ""
+	}
+	return codes.Error, err.Error()
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+type schemaError struct{}
+
+func (schemaError) Error() string { return "invalid body" }
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"invalid cep", ErrInvalidCEP, http.StatusUnprocessableEntity},
+		{"wrapped not found", fmt.Errorf("viacep: %w", ErrCEPNotFound), http.StatusNotFound},
+		{"uf not allowed", ErrUFNotAllowed, http.StatusForbidden},
+		{"provider down", NewProviderError("viacep", 0, errors.New("connection refused")), http.StatusBadGateway},
+		{"provider error status", NewProviderError("weatherapi", http.StatusInternalServerError, errors.New("boom")), http.StatusBadGateway},
+		{"provider rate limited", NewProviderError("weatherapi", http.StatusTooManyRequests, errors.New("slow down")), http.StatusServiceUnavailable},
+		{"provider quota", NewProviderError("weatherapi", http.StatusForbidden, errors.New("quota")), http.StatusServiceUnavailable},
+		{"city not found", ErrCityNotFound, http.StatusNotFound},
+		{"deadline", fmt.Errorf("weatherapi: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err); got != tt.want {
+				t.Errorf("HTTPStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderErrorMatching(t *testing.T) {
+	err := fmt.Errorf("get temperature: %w", NewProviderError("weatherapi", http.StatusOK, schemaError{}))
+
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Error("provider error should match ErrProviderUnavailable")
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		t.Error("provider error with status 200 should not match ErrQuotaExceeded")
+	}
+	var cause schemaError
+	if !errors.As(err, &cause) {
+		t.Error("provider error should unwrap to its cause")
+	}
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Provider != "weatherapi" {
+		t.Errorf("errors.As(*ProviderError) = %+v", providerErr)
+	}
+}
+
+func TestSpanStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.Ok},
+		{ErrInvalidCEP, codes.Unset},
+		{ErrCEPNotFound, codes.Unset},
+		{NewProviderError("viacep", 0, errors.New("timeout")), codes.Error},
+		{errors.New("boom"), codes.Error},
+	}
+
+	for _, tt := range tests {
+		if got, _ := SpanStatus(tt.err); got != tt.want {
+			t.Errorf("SpanStatus(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
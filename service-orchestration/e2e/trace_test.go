@@ -0,0 +1,217 @@
+//go:build e2e
+
+// Package e2e checks both services running together, as started by
+// docker-compose.e2e.yml, through the spans the collector writes to a file.
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// span is the part of an OTLP JSON span the assertions need.
+type span struct {
+	Service      string
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Status       struct {
+		// The collector writes the code as a number; other encoders spell
+		// out the enum name
+		Code json.RawMessage `json:"code"`
+	} `json:"status"`
+}
+
+func (s span) failed() bool {
+	code := strings.Trim(string(s.Status.Code), `"`)
+	return code == "2" || code == "STATUS_CODE_ERROR"
+}
+
+type exportRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string `json:"key"`
+				Value struct {
+					StringValue string `json:"stringValue"`
+				} `json:"value"`
+			} `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []span `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+// readTrace returns the spans of traceID in the file exporter output at path,
+// one OTLP export request per line.
+func readTrace(path, traceID string) ([]span, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var spans []span
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var req exportRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		for _, rs := range req.ResourceSpans {
+			service := ""
+			for _, a := range rs.Resource.Attributes {
+				if a.Key == "service.name" {
+					service = a.Value.StringValue
+				}
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					if strings.EqualFold(s.TraceID, traceID) {
+						s.Service = service
+						spans = append(spans, s)
+					}
+				}
+			}
+		}
+	}
+	return spans, scanner.Err()
+}
+
+// edge is a span expected in the trace, under the span named parent of
+// parentService; an empty parent marks the root of the request.
+type edge struct {
+	service, name         string
+	parentService, parent string
+}
+
+// expectedTree is the span tree of a successful lookup through both services.
+// It only lists the spans every lookup makes; optional stages, such as the
+// UF policy check, are allowed in addition.
+var expectedTree = []edge{
+	{"service-input", "POST /temperature", "", ""},
+	{"service-input", "validate-cep", "service-input", "POST /temperature"},
+	{"service-input", "call-service-orchestration", "service-input", "POST /temperature"},
+	// Propagation across the HTTP call: the server span of the orchestration
+	// continues the span that made the call
+	{"service-orchestration", "GET /temperature", "service-input", "call-service-orchestration"},
+	{"service-orchestration", "temperature-handler", "service-orchestration", "GET /temperature"},
+	{"service-orchestration", "validate-cep", "service-orchestration", "temperature-handler"},
+	{"service-orchestration", "get-city-from-cep", "service-orchestration", "temperature-handler"},
+	{"service-orchestration", "get-temperature-from-weather-api", "service-orchestration", "temperature-handler"},
+	{"service-orchestration", "convert-temperatures", "service-orchestration", "temperature-handler"},
+	{"service-orchestration", "write-response", "service-orchestration", "temperature-handler"},
+}
+
+func TestLookupSpanTree(t *testing.T) {
+	inputURL := env("E2E_INPUT_URL", "http://localhost:8080")
+	tracesFile := env("E2E_TRACES_FILE", "../../e2e-output/traces.jsonl")
+	cep := env("E2E_CEP", "01001000")
+
+	// The request joins a trace of our own, so its spans can be told apart
+	// from every other request in the file
+	ids := make([]byte, 24)
+	rand.Read(ids)
+	traceID, parentID := hex.EncodeToString(ids[:16]), hex.EncodeToString(ids[16:])
+
+	req, err := http.NewRequest(http.MethodPost, inputURL+"/temperature", bytes.NewBufferString(fmt.Sprintf(`{"cep":%q}`, cep)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceID, parentID))
+	// Skip the service-input cache, which would answer without calling the
+	// orchestration
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /temperature: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /temperature = %d, want 200", resp.StatusCode)
+	}
+
+	// Spans reach the file after the SDK and collector batches are flushed
+	var spans []span
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		spans, err = readTrace(tracesFile, traceID)
+		if err == nil && len(spans) >= len(expectedTree) {
+			// Give late spans of the same trace one more flush
+			time.Sleep(2 * time.Second)
+			spans, err = readTrace(tracesFile, traceID)
+			break
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		t.Fatalf("reading %s: %v", tracesFile, err)
+	}
+
+	byID := make(map[string]span, len(spans))
+	for _, s := range spans {
+		byID[s.SpanID] = s
+	}
+	for _, want := range expectedTree {
+		var found []span
+		for _, s := range spans {
+			if s.Service == want.service && s.Name == want.name {
+				found = append(found, s)
+			}
+		}
+		if len(found) != 1 {
+			t.Errorf("%s %q: found %d spans, want 1", want.service, want.name, len(found))
+			continue
+		}
+		s := found[0]
+
+		if want.parent == "" {
+			if s.ParentSpanID != parentID {
+				t.Errorf("%s %q: parent %s, want the caller's span %s", want.service, want.name, s.ParentSpanID, parentID)
+			}
+			continue
+		}
+		parent, ok := byID[s.ParentSpanID]
+		if !ok {
+			t.Errorf("%s %q: parent span %s is not in the trace", want.service, want.name, s.ParentSpanID)
+			continue
+		}
+		if parent.Service != want.parentService || parent.Name != want.parent {
+			t.Errorf("%s %q: parent is %s %q, want %s %q", want.service, want.name, parent.Service, parent.Name, want.parentService, want.parent)
+		}
+	}
+
+	for _, s := range spans {
+		if s.failed() {
+			t.Errorf("%s %q has an error status in a successful lookup", s.Service, s.Name)
+		}
+	}
+	if t.Failed() {
+		for _, s := range spans {
+			t.Logf("span %s parent %s: %s %q", s.SpanID, s.ParentSpanID, s.Service, s.Name)
+		}
+	}
+}
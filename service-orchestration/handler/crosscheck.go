@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	crossCheckTimeout = 5 * time.Second
+	maxCrossChecks    = 10
+)
+
+var (
+	crossCheckPercent   float64
+	crossCheckThreshold float64
+	crossCheckRand      = clock.DefaultRand
+	crossCheckSlots     = make(chan struct{}, maxCrossChecks)
+	crossCheckTasks     *supervisor.Supervisor
+
+	openMeteoTemperature = utils.GetOpenMeteoTemperature
+)
+
+var crossChecks, _ = otel.Meter("service-orchestration").Int64Counter(
+	"weather.crosscheck.comparisons",
+	metric.WithDescription("Number of weather API readings cross-checked with Open-Meteo, by result (agree, disagree, error or skipped)"),
+)
+
+var crossCheckDifference, _ = otel.Meter("service-orchestration").Float64Histogram(
+	"weather.crosscheck.difference",
+	metric.WithUnit("Cel"),
+	metric.WithDescription("Absolute difference between the weather API and Open-Meteo temperatures"),
+)
+
+// SetWeatherCrossCheck makes percent of fresh weather API readings be
+// compared with Open-Meteo in the background; differences above threshold
+// degrees Celsius are logged and counted as disagreements. The checks run on
+// tasks, whose Shutdown waits for those in flight. Zero percent, the default,
+// disables it.
+func SetWeatherCrossCheck(percent, threshold float64, tasks *supervisor.Supervisor) {
+	crossCheckPercent = percent
+	crossCheckThreshold = threshold
+	crossCheckTasks = tasks
+}
+
+// maybeCrossCheck samples a reading for cross-checking. Open-Meteo only takes
+// coordinates, so locations without them are skipped; the check never
+// delays or changes the response, and its Open-Meteo call is not counted in
+// the usage of the request that triggered it.
+func maybeCrossCheck(ctx context.Context, tracer trace.Tracer, location *models.Location, tempC float64) {
+	if crossCheckPercent <= 0 || location == nil || !location.HasCoordinates() || crossCheckRand()*100 >= crossCheckPercent {
+		return
+	}
+
+	select {
+	case crossCheckSlots <- struct{}{}:
+	default:
+		crossChecks.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "skipped")))
+		return
+	}
+
+	ctx = usage.WithoutUsage(context.WithoutCancel(ctx))
+	lat, lon := location.Latitude, location.Longitude
+	crossCheckTasks.Spawn(ctx, "weather-crosscheck", func(ctx context.Context) {
+		defer func() { <-crossCheckSlots }()
+		ctx, cancel := context.WithTimeout(ctx, crossCheckTimeout)
+		defer cancel()
+
+		tracing.WithSpan(ctx, tracer, "weather-crosscheck", func(ctx context.Context, span trace.Span) error {
+			other, err := openMeteoTemperature(ctx, lat, lon, span)
+			if err != nil {
+				crossChecks.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error")))
+				return err
+			}
+
+			diff := math.Abs(tempC - other)
+			result := "agree"
+			if diff > crossCheckThreshold {
+				result = "disagree"
+				slog.WarnContext(ctx, "Weather providers disagree", "weatherapi_c", tempC, "open_meteo_c", other, "difference_c", diff)
+			}
+			crossChecks.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+			crossCheckDifference.Record(ctx, diff)
+			span.SetAttributes(
+				attribute.Float64("weatherapi.temperature_celsius", tempC),
+				attribute.Float64("open_meteo.temperature_celsius", other),
+				attribute.Float64("crosscheck.difference_celsius", diff),
+				attribute.String("crosscheck.result", result),
+			)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useOpenMeteo makes cross-checks read celsius from Open-Meteo and sample
+// with r, until the test ends. It returns the number of Open-Meteo calls and
+// the usage each of them saw.
+func useOpenMeteo(t *testing.T, percent, threshold, celsius float64, r clock.Rand) (calls *int, seen *[]*usage.Usage) {
+	t.Helper()
+	calls, seen = new(int), new([]*usage.Usage)
+	prevFetch, prevRand := openMeteoTemperature, crossCheckRand
+	openMeteoTemperature = func(ctx context.Context, lat, lon float64, span trace.Span) (float64, error) {
+		*calls++
+		*seen = append(*seen, usage.FromContext(ctx))
+		return celsius, nil
+	}
+	crossCheckRand = r
+	SetWeatherCrossCheck(percent, threshold, supervisor.New(context.Background()))
+	t.Cleanup(func() {
+		openMeteoTemperature, crossCheckRand = prevFetch, prevRand
+		SetWeatherCrossCheck(0, 0, nil)
+	})
+	return calls, seen
+}
+
+func crossCheck(t *testing.T, ctx context.Context, tempC float64) []tracetest.SpanStub {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	saoPaulo := &models.Location{UF: "SP", Latitude: -23.55, Longitude: -46.63}
+	maybeCrossCheck(ctx, tp.Tracer(""), saoPaulo, tempC)
+	if err := crossCheckTasks.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return exporter.GetSpans()
+}
+
+func TestCrossCheckSampling(t *testing.T) {
+	tests := []struct {
+		name string
+		draw float64
+		want int
+	}{
+		{name: "drawn", draw: 0.05, want: 1},
+		{name: "not drawn", draw: 0.5, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, _ := useOpenMeteo(t, 10, 1, 20, clock.Fixed(tt.draw))
+			crossCheck(t, context.Background(), 20)
+			if *calls != tt.want {
+				t.Errorf("Open-Meteo called %d times, want %d", *calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrossCheckSkipsWhenSlotsAreTaken(t *testing.T) {
+	calls, _ := useOpenMeteo(t, 100, 1, 20, clock.Fixed(0))
+	for range maxCrossChecks {
+		crossCheckSlots <- struct{}{}
+	}
+	defer func() {
+		for range maxCrossChecks {
+			<-crossCheckSlots
+		}
+	}()
+
+	crossCheck(t, context.Background(), 20)
+	if *calls != 0 {
+		t.Errorf("Open-Meteo called %d times with every slot taken, want 0", *calls)
+	}
+}
+
+func TestCrossCheckThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float64
+		want      string
+	}{
+		{name: "within", threshold: 2, want: "agree"},
+		{name: "above", threshold: 1, want: "disagree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useOpenMeteo(t, 100, tt.threshold, 21.5, clock.Fixed(0))
+			spans := crossCheck(t, context.Background(), 20)
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want the cross-check span", len(spans))
+			}
+			for _, kv := range spans[0].Attributes {
+				if kv.Key == "crosscheck.result" {
+					if got := kv.Value.AsString(); got != tt.want {
+						t.Errorf("crosscheck.result = %q, want %q", got, tt.want)
+					}
+					return
+				}
+			}
+			t.Errorf("span attributes %v lack crosscheck.result", spans[0].Attributes)
+		})
+	}
+}
+
+func TestCrossCheckIsNotBilledToTheRequest(t *testing.T) {
+	_, seen := useOpenMeteo(t, 100, 1, 20, clock.Fixed(0))
+	ctx, u := usage.NewContext(context.Background())
+
+	crossCheck(t, ctx, 20)
+	if len(*seen) != 1 || (*seen)[0] != nil {
+		t.Errorf("Open-Meteo saw usage %v, want none", *seen)
+	}
+	if got := u.Cost(); got.UpstreamCalls != 0 {
+		t.Errorf("request usage = %+v, want no provider calls", got)
+	}
+}
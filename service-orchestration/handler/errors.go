@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+)
+
+// writeError answers err with the status from domain.HTTPStatus and returns it
+// so the caller's span records it. Domain errors with a code get a JSON body,
+// the others keep the plain-text message; errors outside the domain are
+// answered with fallback, since their text is not meant for clients.
+func writeError(w http.ResponseWriter, err error, fallback string) error {
+	status := domain.HTTPStatus(err)
+
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		w.WriteHeader(status)
+		w.Write([]byte(fallback))
+		return err
+	}
+
+	if domainErr.Code == "" {
+		w.WriteHeader(status)
+		w.Write([]byte(domainErr.Message))
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{Message: domainErr.Message, Code: domainErr.Code})
+	return err
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Lookup runs the /temperature lookup of query with header, as a batch item,
+// a trend or a replay does, and returns the status and body it would answer.
+// It is a history.LookupFunc.
+func Lookup(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/temperature?"+query.Encode(), nil)
+	if err != nil {
+		return http.StatusInternalServerError, []byte(err.Error())
+	}
+	if header != nil {
+		r.Header = header
+	}
+	w := &lookupResponse{header: make(http.Header)}
+	TemperatureHandler(w, r)
+	return w.Status(), w.body.Bytes()
+}
+
+// lookupResponse keeps the response of a Lookup in memory.
+type lookupResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *lookupResponse) Header() http.Header { return w.header }
+
+func (w *lookupResponse) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *lookupResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Status is the status written, 200 when the handler wrote none.
+func (w *lookupResponse) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
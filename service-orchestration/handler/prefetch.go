@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fhsmendes/deploy-cloud-run/analytics"
+	"github.com/fhsmendes/deploy-cloud-run/postal"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Prefetch resolves the address and temperature of the n postal codes tracker
+// counted most, so they are in the address and temperature caches before they
+// are looked up again. Temperatures still fresh in the cache are not fetched
+// again, and the prefetched lookups are not counted by tracker. It goes on
+// past failed codes and returns their errors together.
+func Prefetch(ctx context.Context, tracker *analytics.Tracker, n int) error {
+	tracer := otel.Tracer("service-orchestration")
+	var errs []error
+	for _, item := range tracker.CEPs.Top(n) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := tracing.WithSpan(ctx, tracer, "prefetch", func(ctx context.Context, span trace.Span) error {
+			return prefetch(ctx, tracer, span, item.Key)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prefetch %s: %w", item.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func prefetch(ctx context.Context, tracer trace.Tracer, span trace.Span, key string) error {
+	countryCode, code := postal.SplitKey(key)
+	country, err := postal.Lookup(countryCode)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("cep", code), attribute.String("postal.country", country.Code))
+
+	l := &lookup{tracer: tracer, mainSpan: span, cep: code, country: country, prefetch: true}
+	if err := resolveCity(ctx, span, l); err != nil {
+		return err
+	}
+	return fetchWeather(ctx, span, l)
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/analytics"
+	"github.com/fhsmendes/deploy-cloud-run/cache"
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPrefetch(t *testing.T) {
+	viaCEP, weather := useMocks(t)
+	tracker := analytics.NewTracker(10)
+	for i := 0; i < 3; i++ {
+		tracker.Record("01001000", "São Paulo")
+	}
+	tracker.Record("99999999", "")
+	tracker.Record("99999999", "")
+	tracker.Record("80010000", "Curitiba")
+
+	viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(models.ViaCEP{Localidade: "São Paulo", UF: "SP"}, nil).Once()
+	viaCEP.On("GetAddressFromCEP", mock.Anything, "99999999", mock.Anything).Return(models.ViaCEP{}, domain.ErrCEPNotFound).Once()
+	weather.On("GetReading", mock.Anything, "São Paulo", mock.Anything).Return(utils.Reading{Celsius: 25}, nil).Once()
+
+	// Only the two most looked up CEPs are prefetched; the unknown one fails
+	// without stopping the others
+	if err := Prefetch(context.Background(), tracker, 2); err == nil {
+		t.Error("Prefetch() = nil, want the error of the unknown CEP")
+	}
+
+	if got, _, ok := addressCache.Get("01001000"); !ok || got.Localidade != "São Paulo" {
+		t.Errorf("address cache = %+v, %v, want São Paulo", got, ok)
+	}
+	key := cache.WeatherKey{Provider: cache.AnyProvider, Query: "São Paulo"}.String()
+	if got, _, ok := temperatureCache.Get(key); !ok || got.Celsius != 25 {
+		t.Errorf("temperature cache = %+v, %v, want 25°C", got, ok)
+	}
+	if top := tracker.CEPs.Top(1); top[0].Count != 3 {
+		t.Errorf("top CEP count = %d after prefetch, want 3", top[0].Count)
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	WeatherProviderWeatherAPI = "weatherapi"
+	WeatherProviderOpenMeteo  = "open-meteo"
+	// WeatherProviderStub answers every lookup with the temperature set by
+	// SetStubTemperature.
+	WeatherProviderStub = "stub"
+)
+
+var (
+	weatherRouter = routing.New(WeatherProviderWeatherAPI)
+	// countryRouters replace weatherRouter for the postal codes of a country.
+	countryRouters map[string]*routing.Router
+)
+
+// Capabilities are the features a weather provider serves besides the
+// current temperature, as integrated here rather than as its API offers them.
+type Capabilities struct {
+	// Forecast is the forecast endpoint extended responses take the weather
+	// alerts from.
+	Forecast   bool
+	AirQuality bool
+}
+
+// weatherCapabilities is the capability matrix of the weather providers.
+var weatherCapabilities = map[string]Capabilities{
+	WeatherProviderWeatherAPI: {Forecast: true, AirQuality: true},
+	WeatherProviderOpenMeteo:  {},
+}
+
+// weatherAPI is the client of the weather API provider.
+var weatherAPI utils.WeatherAPIClient = utils.WeatherAPI{}
+
+var stubTemperature *float64
+
+// SetStubTemperature makes lookups answer celsius instead of calling the
+// weather providers, so smoke tests run without spending API quota; nil
+// calls the providers again.
+func SetStubTemperature(celsius *float64) {
+	stubTemperature = celsius
+}
+
+// SetWeatherRouter sets the weather providers lookups can use and how they
+// are ranked; by default only the weather API is used.
+func SetWeatherRouter(r *routing.Router) {
+	weatherRouter = r
+}
+
+// SetCountryWeatherRouters sets the weather providers, and their ranking, of
+// the countries routed apart from the others.
+func SetCountryWeatherRouters(routers map[string]*routing.Router) {
+	countryRouters = routers
+}
+
+// weatherRouterFor returns the router of the postal codes of country.
+func weatherRouterFor(country string) *routing.Router {
+	if r, ok := countryRouters[country]; ok {
+		return r
+	}
+	return weatherRouter
+}
+
+// requiredCapabilities returns the capabilities the feature-specific params
+// of query ask for: extended responses carry the alerts of the forecast.
+func requiredCapabilities(query url.Values) Capabilities {
+	return Capabilities{Forecast: query.Get("extended") == "true"}
+}
+
+// checkCapabilities rejects the feature-specific params of query when no
+// weather provider routed for country can serve them, before any provider
+// is called. Stubbed lookups call no provider and accept them all.
+func checkCapabilities(query url.Values, country string) error {
+	required := requiredCapabilities(query)
+	if stubTemperature != nil || required == (Capabilities{}) {
+		return nil
+	}
+	for _, provider := range weatherRouterFor(country).Providers() {
+		c := weatherCapabilities[provider]
+		if (!required.Forecast || c.Forecast) && (!required.AirQuality || c.AirQuality) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no weather provider for %s serves %s", domain.ErrFeatureUnsupported, country, requiredFeatures(required))
+}
+
+func requiredFeatures(c Capabilities) string {
+	var features []string
+	if c.Forecast {
+		features = append(features, "forecasts")
+	}
+	if c.AirQuality {
+		features = append(features, "air quality")
+	}
+	return strings.Join(features, " and ")
+}
+
+// fetchTemperature asks the weather providers, best ranked first, until one
+// answers, and returns its reading and name. Open-Meteo only takes
+// coordinates, so it is skipped for locations without them, and reports a
+// single unit, so its readings are always converted.
+func fetchTemperature(ctx context.Context, span trace.Span, country, query string, location *models.Location) (utils.Reading, string, error) {
+	if stubTemperature != nil {
+		span.SetAttributes(attribute.String("weather.provider", WeatherProviderStub))
+		history.AddEvent(ctx, "provider "+WeatherProviderStub, outcome(nil), time.Now(), 0)
+		return utils.Reading{Celsius: *stubTemperature}, WeatherProviderStub, nil
+	}
+
+	router := weatherRouterFor(country)
+	providers, decision := router.Rank(func(provider string) bool {
+		return provider != WeatherProviderOpenMeteo || (location != nil && location.HasCoordinates())
+	})
+	span.SetAttributes(
+		attribute.String("routing.decision", decision),
+		attribute.StringSlice("routing.order", providers),
+	)
+
+	err := errors.New("no weather provider available")
+	for _, provider := range providers {
+		var reading utils.Reading
+		callStart := time.Now()
+		switch provider {
+		case WeatherProviderWeatherAPI:
+			reading, err = weatherAPI.GetReading(ctx, query, span)
+		case WeatherProviderOpenMeteo:
+			reading.Celsius, err = utils.GetOpenMeteoTemperature(ctx, location.Latitude, location.Longitude, span)
+		}
+		// A city the provider does not know is the caller's mistake, and
+		// does not count against the provider
+		failure := err
+		if errors.Is(err, domain.ErrCityNotFound) {
+			failure = nil
+		}
+		router.Observe(provider, time.Since(callStart), failure)
+		history.SetProviderResult(ctx, provider, err == nil)
+		history.AddEvent(ctx, "provider "+provider, outcome(err), callStart, time.Since(callStart))
+		if err == nil {
+			span.SetAttributes(attribute.String("weather.provider", provider))
+			return reading, provider, nil
+		}
+	}
+	return utils.Reading{}, "", err
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+)
+
+// fakeZippopotam answers Zippopotam lookups of Lisbon and Open-Meteo
+// forecasts, recording the coordinates it was asked for.
+func fakeZippopotam(t *testing.T, forecastQuery *string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pt/1000-001":
+			w.Write([]byte(`{"places":[{"place name":"Lisboa","state":"Lisboa","state abbreviation":"","latitude":"38.7167","longitude":"-9.1333"}]}`))
+		case "/forecast":
+			*forecastQuery = r.URL.RawQuery
+			w.Write([]byte(`{"current":{"temperature_2m":18.5}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	utils.SetUpstreams(upstream.Config{
+		"zippopotam":          {Name: "zippopotam", URL: srv.URL},
+		"open-meteo-forecast": {Name: "open-meteo-forecast", URL: srv.URL},
+	}, clock.Real{})
+	t.Cleanup(func() { utils.SetUpstreams(nil, clock.Real{}) })
+}
+
+func TestTemperatureHandlerCountry(t *testing.T) {
+	var forecastQuery string
+	fakeZippopotam(t, &forecastQuery)
+	SetCountryWeatherRouters(map[string]*routing.Router{"PT": routing.New(WeatherProviderOpenMeteo)})
+	t.Cleanup(func() { SetCountryWeatherRouters(nil) })
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"located by zippopotam", "cep=1000001&country=PT", http.StatusOK, `"city":"Lisboa"`},
+		{"unknown code", "cep=9999-999&country=PT", http.StatusNotFound, ""},
+		{"invalid format", "cep=01001000&country=PT", http.StatusUnprocessableEntity, ""},
+		{"unsupported country", "cep=1000-001&country=AR", http.StatusBadRequest, `"code":"unsupported_country"`},
+		// Open-Meteo has no forecast endpoint integrated for the alerts
+		{"missing capability", "cep=1000-001&country=PT&extended=true", http.StatusBadRequest, `"code":"feature_unsupported"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecastQuery = ""
+			rec := httptest.NewRecorder()
+			TemperatureHandler(rec, httptest.NewRequest(http.MethodGet, "/temperature?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !strings.Contains(forecastQuery, "latitude=38.7167") || !strings.Contains(forecastQuery, "longitude=-9.1333") {
+				t.Errorf("Open-Meteo asked for %q, want the coordinates from Zippopotam", forecastQuery)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["temp_C"] != 18.5 {
+				t.Errorf("body = %s, want temp_C 18.5", rec.Body)
+			}
+		})
+	}
+}
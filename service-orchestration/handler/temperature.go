@@ -1,97 +1,788 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/fhsmendes/deploy-cloud-run/analytics"
+	"github.com/fhsmendes/deploy-cloud-run/budget"
+	"github.com/fhsmendes/deploy-cloud-run/cache"
+	"github.com/fhsmendes/deploy-cloud-run/cepdb"
+	"github.com/fhsmendes/deploy-cloud-run/cityname"
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/memo"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/pipeline"
+	"github.com/fhsmendes/deploy-cloud-run/policy"
+	"github.com/fhsmendes/deploy-cloud-run/postal"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
 	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/deprecation"
+	"github.com/fhsmendes/open-telemetry/pkg/geo"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/language"
 )
 
+const dataQualityOutOfRange = "temperature_out_of_range"
+
+const (
+	degradationCityFromCache      = "city_from_cache"
+	degradationCityFromOfflineCEP = "city_from_offline_dataset"
+	degradationStaleTemperature   = "stale_temperature"
+)
+
+const defaultStaleTemperatureMaxAge = time.Hour
+
+var (
+	addressCache       = newCache[models.ViaCEP]("address")
+	temperatureCache   = newCache[utils.Reading]("temperature")
+	weatherAlertsCache = newCache[[]models.WeatherAlert]("weather_alerts")
+)
+
+func newCache[V any](name string) *cache.Cache[V] {
+	c := cache.New[V](10000)
+	c.SetName(name)
+	return c
+}
+
+var degradedResponses, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.degraded_responses",
+	metric.WithDescription("Number of responses served with a degraded dependency, by degradation state"),
+)
+
+var ufPolicy policy.UFPolicy
+var accessPolicy *policy.AccessPolicy
+var fieldNaming = models.NamingLegacy
+var offlineCEPs *cepdb.Store
+var lookupStats *analytics.Tracker
+var geocoder *geo.Resolver
+var cityNames *cityname.Normalizer
+
+var temperatureConversions, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.temperature_conversions",
+	metric.WithDescription("Number of responses by how their Fahrenheit value was obtained: from the provider or converted from Celsius"),
+)
+
+var lookupsByCity, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.lookups",
+	metric.WithDescription("Number of CEP lookups by city; cities outside the tracked top ones are counted as other"),
+)
+
+// SetUFPolicy configures which states TemperatureHandler serves.
+func SetUFPolicy(p policy.UFPolicy) {
+	ufPolicy = p
+}
+
+// SetAccessPolicy configures the rules deciding which requests
+// TemperatureHandler serves; nil serves every request.
+func SetAccessPolicy(p *policy.AccessPolicy) {
+	accessPolicy = p
+}
+
+// SetOfflineCEPs enables answering lookups from an offline CEP dataset when
+// ViaCEP is unavailable and the address is not cached.
+func SetOfflineCEPs(s *cepdb.Store) {
+	offlineCEPs = s
+}
+
+// SetTemperatureCacheTTL lets temperatures be served from the cache for up to
+// ttl (spread by ±jitter) before the weather API is called again. Zero, the
+// default, calls the weather API on every request.
+func SetTemperatureCacheTTL(ttl time.Duration, jitter float64) {
+	temperatureCache.SetTTL(ttl, jitter)
+}
+
+// SetWeatherAlertsCacheTTL sets how long the alerts of a location are reused
+// before the weather API is asked again.
+func SetWeatherAlertsCacheTTL(ttl time.Duration, jitter float64) {
+	weatherAlertsCache.SetTTL(ttl, jitter)
+}
+
+// SetClock replaces the clock used to age cached addresses, temperatures and
+// weather alerts.
+func SetClock(clk clock.Clock) {
+	addressCache.SetClock(clk)
+	temperatureCache.SetClock(clk)
+	weatherAlertsCache.SetClock(clk)
+}
+
+// SetRand replaces the source used to jitter cache TTLs and to sample
+// readings for cross-checking.
+func SetRand(r clock.Rand) {
+	addressCache.SetRand(r)
+	temperatureCache.SetRand(r)
+	weatherAlertsCache.SetRand(r)
+	crossCheckRand = r
+}
+
+// SetLookupTracker enables counting the most requested CEPs and cities.
+func SetLookupTracker(t *analytics.Tracker) {
+	lookupStats = t
+}
+
+// SetCityNormalizer sets how city names become weather API queries; nil, the
+// default, sends them unchanged.
+func SetCityNormalizer(n *cityname.Normalizer) {
+	cityNames = n
+}
+
+// SetGeocoder enables looking up the coordinates of cities missing from the
+// embedded municipios dataset.
+func SetGeocoder(r *geo.Resolver) {
+	geocoder = r
+}
+
+// SetFieldNaming sets the response key naming used when the Accept header
+// does not ask for a specific profile.
+func SetFieldNaming(n models.FieldNaming) {
+	fieldNaming = n
+}
+
+// lookupOfflineCEP finds the postal code of country in the offline dataset,
+// which only carries Brazilian CEPs.
+func lookupOfflineCEP(country, cep string) (models.ViaCEP, bool) {
+	if offlineCEPs == nil || country != postal.Brazil {
+		return models.ViaCEP{}, false
+	}
+	return offlineCEPs.Lookup(cep)
+}
+
+func staleTemperatureMaxAge() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("STALE_TEMPERATURE_MAX_AGE")); err == nil {
+		return d
+	}
+	return defaultStaleTemperatureMaxAge
+}
+
+var weatherAlertsServed, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.weather.alerts.served",
+	metric.WithDescription("Number of active weather alerts included in extended responses, by severity"),
+)
+
+var implausibleTemperatures, _ = otel.Meter("service-orchestration").Int64Counter(
+	"weather.temperature.implausible",
+	metric.WithDescription("Number of provider temperatures outside the plausible range"),
+)
+
+// geocodeLocation fills in the coordinates of loc from the geocoder. It is best
+// effort: without coordinates the response simply omits them and the weather
+// API is queried by city name.
+func geocodeLocation(ctx context.Context, loc *models.Location, address models.ViaCEP) {
+	start := time.Now()
+	coords, err := geocoder.Resolve(ctx, address.Localidade, address.UF)
+	if err != nil {
+		history.AddEvent(ctx, "geocode", "failed", start, time.Since(start))
+		slog.WarnContext(ctx, "Failed to geocode city", "city", address.Localidade, "uf", address.UF, "error", err)
+		return
+	}
+	history.AddEvent(ctx, "geocode", "found", start, time.Since(start))
+	loc.Latitude, loc.Longitude = coords.Latitude, coords.Longitude
+}
+
+// weatherQueryFor returns the weather API query for city in uf of country and
+// records on span how it was built. Coordinates, aliases and the state
+// disambiguator all avoid ambiguous city names (e.g. "Bom Jesus" exists in
+// several states). The aliases only cover Brazilian cities, so cities of
+// other countries are disambiguated by their state and country.
+func weatherQueryFor(span trace.Span, country postal.Country, city, uf string, location *models.Location) string {
+	if location != nil && location.HasCoordinates() && os.Getenv("WEATHER_QUERY_BY_COORDINATES") == "true" {
+		span.SetAttributes(attribute.String("city.normalization", "coordinates"))
+		return fmt.Sprintf("%f,%f", location.Latitude, location.Longitude)
+	}
+	if country.Code != postal.Brazil {
+		span.SetAttributes(attribute.String("city.normalization", "country"))
+		if uf == "" {
+			return city + ", " + country.Name
+		}
+		return city + ", " + uf + ", " + country.Name
+	}
+
+	query, decision := cityNames.Normalize(city, uf)
+	span.SetAttributes(attribute.String("city.normalization", string(decision)))
+	return query
+}
+
+// weatherAlerts returns the alerts active for query. Alerts are best effort:
+// when the weather API fails the response is served without them.
+func weatherAlerts(ctx context.Context, tracer trace.Tracer, query string) []models.WeatherAlert {
+	if stubTemperature != nil {
+		return nil
+	}
+	var alerts []models.WeatherAlert
+	// Alerts come from the forecast endpoint, asked without air quality
+	key := cache.WeatherKey{Provider: WeatherProviderWeatherAPI, Query: query, Extended: true}.String()
+	err := tracing.WithSpan(ctx, tracer, "get-weather-alerts", func(ctx context.Context, span trace.Span) error {
+		cached, hit, err := weatherAlertsCache.GetOrLoadContext(ctx, key, func() ([]models.WeatherAlert, bool, error) {
+			alerts, err := utils.GetWeatherAlerts(ctx, query, time.Now(), span)
+			return alerts, true, err
+		})
+		span.SetAttributes(attribute.Bool("cache.hit", hit))
+		if err != nil {
+			return err
+		}
+
+		// Cached alerts may have expired since they were fetched
+		now := time.Now()
+		for _, alert := range cached {
+			if !alert.Expires.IsZero() && alert.Expires.Before(now) {
+				continue
+			}
+			alerts = append(alerts, alert)
+			weatherAlertsServed.Add(ctx, 1, metric.WithAttributes(attribute.String("severity", alert.Severity)))
+		}
+		span.SetAttributes(attribute.Int("weather.alerts", len(alerts)))
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Serving response without weather alerts", "error", err)
+	}
+	return alerts
+}
+
+// Stages of a /temperature lookup, in the order they run.
+const (
+	stageValidate     = "validate-cep"
+	stageResolveCity  = "get-city-from-cep"
+	stageClientCity   = "check-client-city"
+	stageUFPolicy     = "check-uf-policy"
+	stageAccess       = "check-access-policy"
+	stageAccessUF     = "check-access-policy-uf"
+	stageFetchWeather = "get-temperature-from-weather-api"
+	stageConvert      = "convert-temperatures"
+	stageRespond      = "write-response"
+)
+
+// failureMessages answer the errors of a stage that are not domain errors, so
+// their text is never shown to clients.
+var failureMessages = map[string]string{
+	stageResolveCity:  "can not find zipcode",
+	stageClientCity:   "can not find zipcode",
+	stageFetchWeather: "error getting temperature",
+}
+
+var stageTimeouts map[string]time.Duration
+
+var allowClientCity = true
+
+var rejectImplausibleTemperatures bool
+
+// SetAllowClientCity sets whether callers may send the city of the CEP (?city=,
+// optionally with ?uf=) so the lookup skips ViaCEP.
+func SetAllowClientCity(allow bool) {
+	allowClientCity = allow
+}
+
+// SetRejectImplausibleTemperatures sets whether a lookup whose temperature is
+// out of the plausible range fails instead of being answered flagged.
+func SetRejectImplausibleTemperatures(reject bool) {
+	rejectImplausibleTemperatures = reject
+}
+
+// SetStageTimeouts bounds how long each stage of a lookup may run, keyed by
+// budget stage (budget.StageCEPLookup, budget.StageWeather...). Stages without
+// a timeout run until the route times out.
+func SetStageTimeouts(timeouts map[string]time.Duration) {
+	stageTimeouts = timeouts
+}
+
+// lookup is the state a /temperature request carries through its stages.
+type lookup struct {
+	w        http.ResponseWriter
+	r        *http.Request
+	tracer   trace.Tracer
+	mainSpan trace.Span
+	usage    *usage.Usage
+
+	cep          string
+	country      postal.Country
+	clientCity   string
+	clientUF     string
+	address      models.ViaCEP
+	location     *models.Location
+	degradations []string
+	reading      utils.Reading
+	plausible    bool
+	staleAge     time.Duration
+	temps        models.Temperature
+	// displayLocale is set when the caller asked for ?format=display.
+	displayLocale *language.Tag
+	// access is set once the access policy decided the request.
+	access *policy.Decision
+	// prefetch is set for lookups run by Prefetch, which are not counted.
+	prefetch bool
+}
+
+// temperaturePipeline returns the stages of a lookup: validate the postal
+// code, resolve its city, check the state policy, fetch the weather, convert the
+// temperature and write the response. With clientCity the city the caller sent
+// is checked instead of asking ViaCEP.
+func temperaturePipeline(tracer trace.Tracer, clientCity bool) *pipeline.Pipeline[*lookup] {
+	stage := func(name, budgetStage string, run func(ctx context.Context, span trace.Span, l *lookup) error) pipeline.Stage[*lookup] {
+		return pipeline.Stage[*lookup]{Name: name, Budget: budgetStage, Timeout: stageTimeouts[budgetStage], Run: run}
+	}
+
+	p := pipeline.New(tracer,
+		stage(stageValidate, budget.StageValidation, validateCEP),
+		stage(stageResolveCity, budget.StageCEPLookup, resolveCity),
+		stage(stageFetchWeather, budget.StageWeather, fetchWeather),
+		stage(stageConvert, budget.StageConvert, convertTemperature),
+		stage(stageRespond, "", writeTemperature),
+	)
+	// The state checks run after whichever stage resolves the city
+	cityStage := stageResolveCity
+	if clientCity {
+		p.Replace(stageResolveCity, stage(stageClientCity, budget.StageCEPLookup, useClientCity))
+		cityStage = stageClientCity
+	}
+	if ufPolicy.Enabled() {
+		p.InsertAfter(cityStage, stage(stageUFPolicy, "", checkUFPolicy))
+	}
+	if accessPolicy != nil {
+		// Rules that do not depend on the state are decided before calling
+		// ViaCEP; the others once the city is resolved
+		p.InsertAfter(stageValidate, stage(stageAccess, "", checkAccess))
+		p.InsertAfter(cityStage, stage(stageAccessUF, "", checkAccess))
+	}
+	return p
+}
+
 func TemperatureHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract tracing context from HTTP headers
-	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	// The incoming trace context was already extracted by middleware.Tracing,
+	// which runs this handler inside the server span.
+	ctx := r.Context()
 	tracer := otel.Tracer("service-orchestration")
 
-	ctx, mainSpan := tracer.Start(ctx, "temperature-handler")
-	defer mainSpan.End()
+	tracing.WithSpan(ctx, tracer, "temperature-handler", func(ctx context.Context, mainSpan trace.Span) error {
+		return handleTemperature(ctx, w, r, tracer, mainSpan)
+	})
+}
 
-	cep := r.URL.Query().Get("cep")
-	mainSpan.SetAttributes(attribute.String("cep", cep))
+func handleTemperature(ctx context.Context, w http.ResponseWriter, r *http.Request, tracer trace.Tracer, mainSpan trace.Span) error {
+	history.SetTraceID(ctx, mainSpan.SpanContext().TraceID())
 
-	fmt.Println("Received request for zipcode:", cep)
+	ctx, reqUsage := usage.NewContext(ctx)
+	defer reqUsage.Record(ctx, mainSpan)
 
-	if !utils.IsValidCEP(cep) {
-		fmt.Println("Invalid zipcode:", cep)
-		mainSpan.SetStatus(codes.Error, "invalid zipcode")
-		mainSpan.SetAttributes(attribute.Bool("valid_cep", false))
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		w.Write([]byte("invalid zipcode"))
-		return
+	if transactionID := utils.TransactionID(ctx, r.Header); transactionID != "" {
+		mainSpan.SetAttributes(attribute.String(utils.TransactionIDBaggageKey, transactionID))
 	}
 
-	fmt.Println("Valid zipcode:", cep)
-	mainSpan.SetAttributes(attribute.Bool("valid_cep", true))
+	query := r.URL.Query()
+	l := &lookup{w: w, r: r, tracer: tracer, mainSpan: mainSpan, usage: reqUsage, cep: query.Get("cep")}
+	if allowClientCity {
+		l.clientCity = strings.TrimSpace(query.Get("city"))
+		l.clientUF = strings.ToUpper(strings.TrimSpace(query.Get("uf")))
+	}
+	mainSpan.SetAttributes(attribute.String("cep", l.cep))
 
-	ctx, spanCity := tracer.Start(ctx, "get-city-from-cep")
-	spanCity.SetAttributes(attribute.String("cep", cep))
+	country, err := postal.Lookup(query.Get("country"))
+	if err != nil {
+		return writeError(w, fmt.Errorf("%w: %q, expected one of %s", domain.ErrUnsupportedCountry, query.Get("country"), strings.Join(postal.Codes(), ", ")), "")
+	}
+	l.country = country
+	mainSpan.SetAttributes(attribute.String("postal.country", country.Code))
+	if err := checkCapabilities(query, country.Code); err != nil {
+		return writeError(w, err, "")
+	}
 
-	city, err := utils.GetCityFromCEP(ctx, cep, spanCity)
+	locale, err := displayLocale(query)
 	if err != nil {
-		fmt.Println("Error getting city from zipcode:", err)
-		spanCity.SetStatus(codes.Error, "city not found")
-		spanCity.End()
-		mainSpan.SetStatus(codes.Error, "can not find zipcode")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("can not find zipcode"))
-		return
+		return writeError(w, err, "")
 	}
-	spanCity.SetAttributes(attribute.String("city", city))
-	spanCity.SetStatus(codes.Ok, "city found successfully")
-	spanCity.End()
+	l.displayLocale = locale
+
+	slog.InfoContext(ctx, "Received request", "cep", l.cep, "country", country.Code)
+
+	err = temperaturePipeline(tracer, l.clientCity != "").Run(ctx, l)
+	var stageErr *pipeline.Error
+	if !errors.As(err, &stageErr) {
+		return err
+	}
+	return writeError(w, stageErr.Err, failureMessages[stageErr.Stage])
+}
 
-	fmt.Println("City found:", city)
+// displayLocale returns the locale of the formatted temperatures asked for
+// with ?format=display (and optionally ?locale=), or nil when the caller
+// wants the numeric fields only.
+func displayLocale(query url.Values) (*language.Tag, error) {
+	switch query.Get("format") {
+	case "":
+		return nil, nil
+	case "display":
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", domain.ErrInvalidFormat, query.Get("format"))
+	}
 
-	ctx, spanTemp := tracer.Start(ctx, "get-temperature-from-weather-api")
-	spanTemp.SetAttributes(attribute.String("city", city))
+	locale := utils.DefaultDisplayLocale
+	if value := query.Get("locale"); value != "" {
+		tag, err := language.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: locale %q: %v", domain.ErrInvalidFormat, value, err)
+		}
+		locale = tag
+	}
+	return &locale, nil
+}
 
-	tempC, err := utils.GetTemperature(ctx, city, spanTemp)
+// validateCEP checks the postal code against the format of its country and
+// normalizes it, e.g. Portuguese codes to NNNN-NNN.
+func validateCEP(ctx context.Context, span trace.Span, l *lookup) error {
+	code, valid := l.country.Validate(l.cep)
+	l.mainSpan.SetAttributes(attribute.Bool("valid_cep", valid))
+	if !valid {
+		slog.InfoContext(ctx, "Invalid zipcode", "cep", l.cep, "country", l.country.Code)
+		return domain.ErrInvalidCEP
+	}
+	l.cep = code
+	slog.InfoContext(ctx, "Valid zipcode", "cep", l.cep)
+	return nil
+}
+
+// resolveCity finds the address of the postal code with the provider of its
+// country, falling back to the address cache and then the offline dataset
+// when the provider is unavailable. Lookups of the same batch share the
+// provider's answer for a code.
+func resolveCity(ctx context.Context, span trace.Span, l *lookup) (err error) {
+	provider := l.country.Provider.Name()
+	key := postal.Key(l.country.Code, l.cep)
+	span.SetAttributes(attribute.String("cep", l.cep), attribute.String("city.source", provider))
+	defer func() {
+		recordLookup(ctx, l)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting city from zipcode", "cep", l.cep, "error", err)
+		}
+	}()
+
+	callStart := time.Now()
+	var memoHit bool
+	l.address, memoHit, err = memo.Do(ctx, memo.KindCity, key, func() (models.ViaCEP, error) {
+		return l.country.Provider.Lookup(ctx, l.cep, span)
+	})
+	span.SetAttributes(attribute.Bool("memo.hit", memoHit))
+	history.SetProviderResult(ctx, provider, err == nil || errors.Is(err, domain.ErrCEPNotFound))
+	history.AddEvent(ctx, "provider "+provider, outcome(err), callStart, time.Since(callStart))
 	if err != nil {
-		fmt.Println("Error getting temperature:", err)
-		spanTemp.SetStatus(codes.Error, "failed to get temperature")
-		spanTemp.End()
-		mainSpan.SetStatus(codes.Error, "error getting temperature")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("error getting temperature"))
-		return
+		if errors.Is(err, domain.ErrCEPNotFound) {
+			return err
+		}
+
+		degradation := degradationCityFromCache
+		cachedAddress, _, cached := addressCache.GetContext(ctx, key)
+		history.AddEvent(ctx, "cache address", fmt.Sprintf("fallback hit=%t", cached), time.Now(), 0)
+		if cached {
+			slog.WarnContext(ctx, "Address provider unavailable, using cached city", "provider", provider, "city", cachedAddress.Localidade)
+			l.address = cachedAddress
+		} else if offlineAddress, ok := lookupOfflineCEP(l.country.Code, l.cep); ok {
+			slog.WarnContext(ctx, "Address provider unavailable, using offline dataset city", "provider", provider, "city", offlineAddress.Localidade)
+			history.AddEvent(ctx, "offline dataset", "city found", time.Now(), 0)
+			l.address = offlineAddress
+			degradation = degradationCityFromOfflineCEP
+			span.SetAttributes(attribute.String("cep_dataset.version", offlineCEPs.Version()))
+		} else {
+			return err
+		}
+		err = nil
+
+		l.degradations = append(l.degradations, degradation)
+		degradedResponses.Add(ctx, 1, metric.WithAttributes(attribute.String("state", degradation)))
+		span.SetAttributes(attribute.String("degradation", degradation))
+	} else {
+		addressCache.SetContext(ctx, key, l.address)
+	}
+
+	locateCity(ctx, span, l)
+	return nil
+}
+
+// useClientCity takes the city the caller sent instead of asking ViaCEP. When
+// the CEP is in the address cache or the offline dataset the city must match
+// the one known for it; when it does not, the lookup falls back to ViaCEP.
+// Unknown CEPs are trusted, so the response is only as accurate as the caller.
+func useClientCity(ctx context.Context, span trace.Span, l *lookup) error {
+	span.SetAttributes(attribute.String("cep", l.cep), attribute.String("client_city", l.clientCity))
+
+	known, _, ok := addressCache.GetContext(ctx, postal.Key(l.country.Code, l.cep))
+	if !ok {
+		known, ok = lookupOfflineCEP(l.country.Code, l.cep)
 	}
-	spanTemp.SetAttributes(attribute.Float64("temperature_celsius", tempC))
-	spanTemp.SetStatus(codes.Ok, "temperature retrieved successfully")
-	spanTemp.End()
+	consistency := "unverified"
+	if ok {
+		consistency = "match"
+		if cityname.Fold(known.Localidade) != cityname.Fold(l.clientCity) || (l.clientUF != "" && l.clientUF != known.UF) {
+			consistency = "mismatch"
+		}
+	}
+	span.SetAttributes(attribute.String("client_city.consistency", consistency))
+	history.AddEvent(ctx, "client city", consistency, time.Now(), 0)
+
+	if consistency == "mismatch" {
+		slog.WarnContext(ctx, "Client city does not match zipcode, asking ViaCEP", "cep", l.cep, "client_city", l.clientCity, "known_city", known.Localidade)
+		return resolveCity(ctx, span, l)
+	}
+	// The state and access policies cannot be checked without the state of
+	// the city
+	if !ok && l.clientUF == "" && (ufPolicy.Enabled() || (accessPolicy != nil && l.access == nil)) {
+		return resolveCity(ctx, span, l)
+	}
+
+	span.SetAttributes(attribute.String("city.source", "client"))
+	if ok {
+		l.address = known
+	} else {
+		l.address = models.ViaCEP{Localidade: l.clientCity, UF: l.clientUF}
+	}
+	recordLookup(ctx, l)
+	locateCity(ctx, span, l)
+	return nil
+}
 
-	fmt.Println("Temperature in Celsius:", tempC)
+// recordLookup counts the lookup of the CEP and its city for /admin/top.
+func recordLookup(ctx context.Context, l *lookup) {
+	if lookupStats != nil && !l.prefetch {
+		lookupStats.Record(postal.Key(l.country.Code, l.cep), l.address.Localidade)
+		lookupsByCity.Add(ctx, 1, metric.WithAttributes(attribute.String("city", lookupStats.CityLabel(l.address.Localidade))))
+	}
+}
 
-	_, spanConvert := tracer.Start(ctx, "convert-temperatures")
-	temps := utils.ConvertTemperatures(tempC)
-	temps.City = city
-	spanConvert.SetAttributes(
-		attribute.Float64("temp_celsius", temps.TempC),
-		attribute.Float64("temp_fahrenheit", temps.TempF),
-		attribute.Float64("temp_kelvin", temps.TempK),
+// locateCity fills in the location of the resolved city, geocoding it when the
+// municipios dataset has no coordinates for it. Cities outside the dataset
+// take the coordinates the postal code provider returned, if any.
+func locateCity(ctx context.Context, span trace.Span, l *lookup) {
+	if m, ok := geo.LookupMunicipio(l.address.IBGE); ok {
+		l.location = &models.Location{IBGE: m.IBGE, UF: m.UF, Region: m.Region, Latitude: m.Latitude, Longitude: m.Longitude}
+		span.SetAttributes(
+			attribute.String("ibge", m.IBGE),
+			attribute.String("region", m.Region),
+		)
+		if geocoder != nil && !m.HasCoordinates() {
+			geocodeLocation(ctx, l.location, l.address)
+		}
+	} else if l.address.Latitude != 0 || l.address.Longitude != 0 {
+		// Providers such as Zippopotam locate the postal code themselves
+		l.location = &models.Location{UF: l.address.UF, Latitude: l.address.Latitude, Longitude: l.address.Longitude}
+	}
+	span.SetAttributes(
+		attribute.String("city", l.address.Localidade),
+		attribute.String("uf", l.address.UF),
 	)
-	spanConvert.SetStatus(codes.Ok, "temperatures converted successfully")
-	spanConvert.End()
+	slog.InfoContext(ctx, "City found", "city", l.address.Localidade)
+}
+
+func checkUFPolicy(ctx context.Context, span trace.Span, l *lookup) error {
+	allowed := ufPolicy.Allowed(l.address.UF)
+	l.mainSpan.SetAttributes(attribute.Bool("uf_policy.allowed", allowed))
+	if !allowed {
+		slog.InfoContext(ctx, "Zipcode outside allowed states", "cep", l.cep, "uf", l.address.UF)
+		return domain.ErrUFNotAllowed
+	}
+	return nil
+}
+
+// checkAccess evaluates the access policy on the request, answering 403 when
+// a rule denies it. The decision and the rule that made it are recorded on
+// the lookup span.
+func checkAccess(ctx context.Context, span trace.Span, l *lookup) error {
+	if l.access != nil {
+		return nil
+	}
+	in, ok := policy.InputFromContext(ctx)
+	if !ok {
+		in = policy.Input{Tier: policy.TierAnonymous, Route: l.r.URL.Path}
+	}
+	if l.address.UF != "" || l.address.Localidade != "" {
+		in.UF, in.Resolved = l.address.UF, true
+	}
+	decision, decided := accessPolicy.Evaluate(in)
+	if !decided {
+		span.SetAttributes(attribute.Bool("policy.decided", false))
+		return nil
+	}
+	l.access = &decision
+	l.mainSpan.SetAttributes(
+		attribute.Bool("policy.allowed", decision.Allowed),
+		attribute.String("policy.rule_id", decision.RuleID),
+		attribute.String("policy.tier", in.Tier),
+	)
+	if !decision.Allowed {
+		slog.InfoContext(ctx, "Request denied by the access policy", "cep", l.cep, "rule", decision.RuleID, "tier", in.Tier)
+		return domain.ErrAccessDenied
+	}
+	return nil
+}
+
+// fetchWeather gets the temperature of the city from the cache or the weather
+// providers, falling back to a stale cached temperature when they fail.
+func fetchWeather(ctx context.Context, span trace.Span, l *lookup) (err error) {
+	city := l.address.Localidade
+	span.SetAttributes(attribute.String("city", city))
+	defer func() {
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting temperature", "city", city, "error", err)
+		}
+	}()
+
+	weatherQuery := weatherQueryFor(span, l.country, city, l.address.UF, l.location)
+	if weatherQuery != city {
+		span.SetAttributes(attribute.String("weather.query", weatherQuery))
+	}
+
+	// Readings are stored as the provider gave them whichever provider
+	// answered, so the routed providers share entries. Only plausible readings are cached, so a
+	// bad reading is never served again as fresh or stale data. Lookups of
+	// the same batch share the providers' answer for a query, cached or not
+	key := cache.WeatherKey{Provider: cache.AnyProvider, Query: weatherQuery}.String()
+	var hit bool
+	l.reading, hit, err = temperatureCache.GetOrLoadContext(ctx, key, func() (utils.Reading, bool, error) {
+		type fetched struct {
+			reading  utils.Reading
+			provider string
+		}
+		f, memoHit, err := memo.Do(ctx, memo.KindTemperature, key, func() (fetched, error) {
+			reading, provider, err := fetchTemperature(ctx, span, l.country.Code, weatherQuery, l.location)
+			return fetched{reading, provider}, err
+		})
+		span.SetAttributes(attribute.Bool("memo.hit", memoHit))
+		if !memoHit && f.provider == WeatherProviderWeatherAPI {
+			maybeCrossCheck(ctx, l.tracer, l.location, f.reading.Celsius)
+		}
+		return f.reading, utils.IsPlausibleTemperature(f.reading.Celsius), err
+	})
+	span.SetAttributes(attribute.Bool("cache.hit", hit))
+	history.AddEvent(ctx, "cache temperature", fmt.Sprintf("hit=%t", hit), time.Now(), 0)
+	if hit {
+		usage.FromContext(ctx).AddCacheHit()
+	}
+	if err != nil {
+		cachedReading, age, cached := temperatureCache.GetContext(ctx, key)
+		if !cached || age > staleTemperatureMaxAge() {
+			return err
+		}
+		err = nil
+
+		slog.WarnContext(ctx, "Weather API unavailable, using cached temperature", "temp_c", cachedReading.Celsius, "age", age)
+		history.AddEvent(ctx, "cache temperature", fmt.Sprintf("stale fallback, age %s", age.Round(time.Second)), time.Now(), 0)
+		l.reading = cachedReading
+		l.staleAge = age
+		l.degradations = append(l.degradations, degradationStaleTemperature)
+		degradedResponses.Add(ctx, 1, metric.WithAttributes(attribute.String("state", degradationStaleTemperature)))
+		span.SetAttributes(
+			attribute.String("degradation", degradationStaleTemperature),
+			attribute.Int64("temperature_age_seconds", int64(age.Seconds())),
+		)
+	}
+	span.SetAttributes(attribute.Float64("temperature_celsius", l.reading.Celsius))
 
-	fmt.Println("Converted temperatures:", temps)
+	l.plausible = utils.IsPlausibleTemperature(l.reading.Celsius)
+	if !l.plausible {
+		slog.WarnContext(ctx, "Implausible temperature from weather API", "city", city, "temp_c", l.reading.Celsius)
+		implausibleTemperatures.Add(ctx, 1, metric.WithAttributes(attribute.String("city", lookupStats.CityLabel(city))))
+		span.SetAttributes(attribute.String("data_quality", dataQualityOutOfRange))
 
-	mainSpan.SetAttributes(attribute.String("response_city", city))
-	mainSpan.SetStatus(codes.Ok, "request processed successfully")
+		if rejectImplausibleTemperatures {
+			return errors.New("temperature out of plausible range")
+		}
+	}
+	slog.InfoContext(ctx, "Temperature found", "temp_c", l.reading.Celsius)
+	return nil
+}
+
+// convertTemperature fills in the response temperatures, taking Fahrenheit
+// from the provider when it reported it. The path taken is recorded because a
+// converted value can differ slightly from the one the provider shows.
+func convertTemperature(ctx context.Context, span trace.Span, l *lookup) error {
+	var path string
+	l.temps, path = utils.ConvertReading(l.reading)
+	l.temps.City = l.address.Localidade
+	if !l.plausible {
+		l.temps.DataQuality = dataQualityOutOfRange
+	}
+	temperatureConversions.Add(ctx, 1, metric.WithAttributes(attribute.String("path", path)))
+	span.SetAttributes(
+		attribute.Float64("temp_celsius", l.temps.TempC),
+		attribute.Float64("temp_fahrenheit", l.temps.TempF),
+		attribute.Float64("temp_kelvin", l.temps.TempK),
+		attribute.String("temperature.conversion", path),
+	)
+	return nil
+}
+
+// writeTemperature adds the optional parts of the response (extended data,
+// degradations and cost) and writes it in the field naming the client asked
+// for.
+func writeTemperature(ctx context.Context, span trace.Span, l *lookup) error {
+	city := l.address.Localidade
+	temps := &l.temps
+
+	if l.r.URL.Query().Get("extended") == "true" {
+		temps.Location = l.location
+		temps.Alerts = weatherAlerts(ctx, l.tracer, weatherQueryFor(l.mainSpan, l.country, city, l.address.UF, l.location))
+	}
+	if len(l.degradations) > 0 {
+		temps.Meta = &models.ResponseMeta{
+			Degraded:              true,
+			Degradations:          l.degradations,
+			TemperatureAgeSeconds: int64(l.staleAge.Seconds()),
+		}
+		l.mainSpan.SetAttributes(
+			attribute.Bool("degraded", true),
+			attribute.String("degraded.reason", strings.Join(l.degradations, ",")),
+			attribute.StringSlice("degradations", l.degradations),
+		)
+		l.w.Header().Set("X-Degraded", strings.Join(l.degradations, ","))
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(temps)
+	if l.displayLocale != nil {
+		temps.Display = utils.FormatTemperatures(*temps, *l.displayLocale)
+		l.mainSpan.SetAttributes(attribute.String("response.display_locale", temps.Display.Locale))
+	}
+
+	slog.InfoContext(ctx, "Converted temperatures", "temps", *temps)
+
+	temps.Naming = models.NamingFromAccept(l.r.Header.Get("Accept"), fieldNaming)
+	// Lets DEPRECATIONS sunset the legacy keys ("field temp_C")
+	for _, field := range temps.Naming.TemperatureFields() {
+		deprecation.UseField(ctx, field)
+	}
+	// Only the snake_case (v2) profile carries the cost block, so legacy
+	// clients keep the response they have always parsed
+	if temps.Naming == models.NamingSnakeCase {
+		if temps.Meta == nil {
+			temps.Meta = &models.ResponseMeta{}
+		}
+		cost := l.usage.Cost()
+		temps.Meta.Cost = &cost
+	}
+	l.mainSpan.SetAttributes(
+		attribute.String("response_city", city),
+		attribute.String("response.field_naming", string(temps.Naming)),
+	)
+
+	l.w.Header().Set("Content-Type", "application/json")
+	l.w.WriteHeader(http.StatusOK)
+	start := time.Now()
+	json.NewEncoder(l.w).Encode(temps)
+	budget.Observe(ctx, span, budget.StageEncode, time.Since(start))
+	return nil
+}
+
+// outcome describes err for the lookup timeline.
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
 }
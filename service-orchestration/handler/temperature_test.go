@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/cache"
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/policy"
+	"github.com/fhsmendes/deploy-cloud-run/postal"
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/deploy-cloud-run/utils/mocks"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTemperaturePipelineStages(t *testing.T) {
+	ufs, err := policy.ParseUFPolicy("SP", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := policy.ParseAccessPolicy([]byte("default: allow\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		clientCity bool
+		ufs        policy.UFPolicy
+		access     *policy.AccessPolicy
+		want       []string
+	}{
+		{
+			name: "default",
+			want: []string{stageValidate, stageResolveCity, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			name:       "client city",
+			clientCity: true,
+			want:       []string{stageValidate, stageClientCity, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			name: "UF policy",
+			ufs:  ufs,
+			want: []string{stageValidate, stageResolveCity, stageUFPolicy, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			// The policy must not be skipped by sending ?city=
+			name:       "client city with UF policy",
+			clientCity: true,
+			ufs:        ufs,
+			want:       []string{stageValidate, stageClientCity, stageUFPolicy, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			// Rules without a state are decided before resolving the city
+			name:   "access policy",
+			access: access,
+			want:   []string{stageValidate, stageAccess, stageResolveCity, stageAccessUF, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			// Nor the rules that depend on the state
+			name:       "client city with access policy",
+			clientCity: true,
+			access:     access,
+			want:       []string{stageValidate, stageAccess, stageClientCity, stageAccessUF, stageFetchWeather, stageConvert, stageRespond},
+		},
+		{
+			name:       "all",
+			clientCity: true,
+			ufs:        ufs,
+			access:     access,
+			want:       []string{stageValidate, stageAccess, stageClientCity, stageAccessUF, stageUFPolicy, stageFetchWeather, stageConvert, stageRespond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetUFPolicy(tt.ufs)
+			SetAccessPolicy(tt.access)
+			defer SetUFPolicy(policy.UFPolicy{})
+			defer SetAccessPolicy(nil)
+
+			got := temperaturePipeline(noop.NewTracerProvider().Tracer(""), tt.clientCity).Stages()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useMocks makes lookups resolve CEPs with viaCEP and ask weather for the
+// temperature, with empty caches, until the test ends.
+func useMocks(t *testing.T) (viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+	t.Helper()
+	viaCEP, weather = mocks.NewViaCEPClient(t), mocks.NewWeatherAPIClient(t)
+
+	brazil, err := postal.Lookup(postal.Brazil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mocked := brazil
+	mocked.Provider = postal.ViaCEPProvider(viaCEP)
+	postal.Register(mocked)
+
+	prevWeather, prevRouter := weatherAPI, weatherRouter
+	weatherAPI, weatherRouter = weather, routing.New(WeatherProviderWeatherAPI)
+	addressCache = newCache[models.ViaCEP]("address")
+	temperatureCache = newCache[utils.Reading]("temperature")
+	t.Cleanup(func() {
+		postal.Register(brazil)
+		weatherAPI, weatherRouter = prevWeather, prevRouter
+		SetStubTemperature(nil)
+		SetUFPolicy(policy.UFPolicy{})
+	})
+	return viaCEP, weather
+}
+
+func TestTemperatureHandler(t *testing.T) {
+	saoPaulo := models.ViaCEP{Localidade: "São Paulo", UF: "SP"}
+	unavailable := domain.NewProviderError("upstream", http.StatusServiceUnavailable, nil)
+	stub := 21.0
+
+	tests := []struct {
+		name  string
+		query string
+		// setup primes the caches and sets the mock expectations; calls
+		// without an expectation fail the test
+		setup        func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient)
+		wantStatus   int
+		wantBody     string
+		wantDegraded string
+	}{
+		{
+			name:  "resolved",
+			query: "cep=01001000",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(saoPaulo, nil).Once()
+				weather.On("GetReading", mock.Anything, "São Paulo", mock.Anything).Return(utils.Reading{Celsius: 25}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"temp_C":25,"temp_F":77,"temp_K":298`,
+		},
+		{
+			name:       "invalid CEP",
+			query:      "cep=0100",
+			setup:      func(*mocks.ViaCEPClient, *mocks.WeatherAPIClient) {},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:  "unknown CEP",
+			query: "cep=99999999",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "99999999", mock.Anything).Return(models.ViaCEP{}, domain.ErrCEPNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "client city skips ViaCEP",
+			query: "cep=80010000&city=Curitiba&uf=PR",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				weather.On("GetReading", mock.Anything, "Curitiba", mock.Anything).Return(utils.Reading{Celsius: 15}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"city":"Curitiba"`,
+		},
+		{
+			// The state the caller sent is checked like a resolved one
+			name:  "client city outside the UF policy",
+			query: "cep=80010000&city=Curitiba&uf=PR",
+			setup: func(*mocks.ViaCEPClient, *mocks.WeatherAPIClient) {
+				ufs, _ := policy.ParseUFPolicy("SP", "")
+				SetUFPolicy(ufs)
+			},
+			wantStatus: http.StatusForbidden,
+			wantBody:   `"code":"uf_not_allowed"`,
+		},
+		{
+			name:  "stub",
+			query: "cep=01001000",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				SetStubTemperature(&stub)
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(saoPaulo, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"temp_C":21`,
+		},
+		{
+			name:  "missing capability",
+			query: "cep=01001000&extended=true",
+			setup: func(*mocks.ViaCEPClient, *mocks.WeatherAPIClient) {
+				weatherRouter = routing.New(WeatherProviderOpenMeteo)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"feature_unsupported"`,
+		},
+		{
+			name:  "city from cache",
+			query: "cep=01001000",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				addressCache.Set("01001000", saoPaulo)
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(models.ViaCEP{}, unavailable).Once()
+				weather.On("GetReading", mock.Anything, "São Paulo", mock.Anything).Return(utils.Reading{Celsius: 25}, nil).Once()
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     `"city":"São Paulo"`,
+			wantDegraded: degradationCityFromCache,
+		},
+		{
+			name:  "stale temperature",
+			query: "cep=01001000",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				temperatureCache.Set(cache.WeatherKey{Provider: cache.AnyProvider, Query: "São Paulo"}.String(), utils.Reading{Celsius: 19})
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(saoPaulo, nil).Once()
+				weather.On("GetReading", mock.Anything, "São Paulo", mock.Anything).Return(utils.Reading{}, unavailable).Once()
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     `"temp_C":19`,
+			wantDegraded: degradationStaleTemperature,
+		},
+		{
+			name:  "weather unavailable",
+			query: "cep=01001000",
+			setup: func(viaCEP *mocks.ViaCEPClient, weather *mocks.WeatherAPIClient) {
+				viaCEP.On("GetAddressFromCEP", mock.Anything, "01001000", mock.Anything).Return(saoPaulo, nil).Once()
+				weather.On("GetReading", mock.Anything, "São Paulo", mock.Anything).Return(utils.Reading{}, errors.New("connection refused")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   failureMessages[stageFetchWeather],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viaCEP, weather := useMocks(t)
+			tt.setup(viaCEP, weather)
+
+			rec := httptest.NewRecorder()
+			TemperatureHandler(rec, httptest.NewRequest(http.MethodGet, "/temperature?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("X-Degraded"); got != tt.wantDegraded {
+				t.Errorf("X-Degraded = %q, want %q", got, tt.wantDegraded)
+			}
+		})
+	}
+}
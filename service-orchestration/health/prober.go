@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type ProbeFunc func(ctx context.Context) error
+
+type probe struct {
+	name string
+	fn   ProbeFunc
+}
+
+// Prober periodically runs synthetic requests against the upstream providers
+// and keeps the latest result of each one.
+type Prober struct {
+	timeout time.Duration
+	probes  []probe
+
+	mu      sync.RWMutex
+	results map[string]models.ProviderHealth
+}
+
+func NewProber(timeout time.Duration) *Prober {
+	return &Prober{
+		timeout: timeout,
+		results: make(map[string]models.ProviderHealth),
+	}
+}
+
+func (p *Prober) Register(name string, fn ProbeFunc) {
+	p.probes = append(p.probes, probe{name: name, fn: fn})
+}
+
+// RunOnce runs every probe and records its result. The service scheduler
+// calls it on the probe schedule.
+func (p *Prober) RunOnce(ctx context.Context) {
+	for _, pr := range p.probes {
+		start := time.Now()
+		err := p.run(ctx, pr)
+		latency := time.Since(start)
+
+		result := models.ProviderHealth{
+			Healthy:     err == nil,
+			LatencyMs:   float64(latency.Microseconds()) / 1000,
+			LastChecked: start.UTC(),
+		}
+		if err != nil {
+			result.LastError = err.Error()
+		}
+
+		p.mu.Lock()
+		p.results[pr.name] = result
+		p.mu.Unlock()
+	}
+}
+
+func (p *Prober) run(ctx context.Context, pr probe) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	defer supervisor.RecoverPanic(ctx, "probe "+pr.name, &err)
+
+	return pr.fn(ctx)
+}
+
+func (p *Prober) Results() map[string]models.ProviderHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := make(map[string]models.ProviderHealth, len(p.results))
+	for name, r := range p.results {
+		results[name] = r
+	}
+	return results
+}
+
+// RegisterMetrics exposes the probe results as provider.* gauges.
+func (p *Prober) RegisterMetrics() error {
+	meter := otel.Meter("service-orchestration")
+
+	up, err := meter.Int64ObservableGauge("provider.up",
+		metric.WithDescription("Whether the last synthetic probe of the provider succeeded (1) or failed (0)"))
+	if err != nil {
+		return err
+	}
+	latency, err := meter.Float64ObservableGauge("provider.probe.latency",
+		metric.WithDescription("Latency of the last synthetic probe of the provider"),
+		metric.WithUnit("ms"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for name, r := range p.Results() {
+			attrs := metric.WithAttributes(attribute.String("provider", name))
+			var v int64
+			if r.Healthy {
+				v = 1
+			}
+			o.ObserveInt64(up, v, attrs)
+			o.ObserveFloat64(latency, r.LatencyMs, attrs)
+		}
+		return nil
+	}, up, latency)
+	return err
+}
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProberRecordsResults(t *testing.T) {
+	p := NewProber(time.Second)
+	p.Register("viacep", func(ctx context.Context) error { return nil })
+	p.Register("weatherapi", func(ctx context.Context) error { return errors.New("status 503") })
+
+	if got := p.Results(); len(got) != 0 {
+		t.Fatalf("Results() before any run = %+v, want none", got)
+	}
+	p.RunOnce(context.Background())
+
+	results := p.Results()
+	if r := results["viacep"]; !r.Healthy || r.LastError != "" || r.LastChecked.IsZero() {
+		t.Errorf("viacep = %+v, want healthy", r)
+	}
+	if r := results["weatherapi"]; r.Healthy || r.LastError != "status 503" {
+		t.Errorf("weatherapi = %+v, want unhealthy with the probe error", r)
+	}
+}
+
+func TestProberTimesOutProbes(t *testing.T) {
+	p := NewProber(10 * time.Millisecond)
+	p.Register("viacep", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	p.RunOnce(context.Background())
+
+	r := p.Results()["viacep"]
+	if r.Healthy || r.LastError != context.DeadlineExceeded.Error() {
+		t.Errorf("viacep = %+v, want unhealthy after the probe timeout", r)
+	}
+	if r.LatencyMs >= 1000 {
+		t.Errorf("latency = %.1fms, want the probe cut at the timeout", r.LatencyMs)
+	}
+}
+
+func TestProberRecoversPanics(t *testing.T) {
+	p := NewProber(time.Second)
+	p.Register("viacep", func(ctx context.Context) error { panic("decoder exploded") })
+	p.Register("weatherapi", func(ctx context.Context) error { return nil })
+
+	p.RunOnce(context.Background())
+
+	results := p.Results()
+	if r := results["viacep"]; r.Healthy || !strings.Contains(r.LastError, "decoder exploded") {
+		t.Errorf("viacep = %+v, want the panic recorded as a failure", r)
+	}
+	if r := results["weatherapi"]; !r.Healthy {
+		t.Errorf("weatherapi = %+v, want it probed after the panic", r)
+	}
+}
+
+func TestProberMetrics(t *testing.T) {
+	prev := otel.GetMeterProvider()
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	p := NewProber(time.Second)
+	p.Register("viacep", func(ctx context.Context) error { return nil })
+	p.Register("weatherapi", func(ctx context.Context) error { return errors.New("status 503") })
+	if err := p.RegisterMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	p.RunOnce(context.Background())
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	up := make(map[string]int64)
+	var latencies int
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Gauge[int64]:
+			for _, dp := range data.DataPoints {
+				provider, _ := dp.Attributes.Value(attribute.Key("provider"))
+				up[provider.AsString()] = dp.Value
+			}
+		case metricdata.Gauge[float64]:
+			latencies += len(data.DataPoints)
+		}
+	}
+	if up["viacep"] != 1 || up["weatherapi"] != 0 || len(up) != 2 {
+		t.Errorf("provider.up = %v, want viacep 1 and weatherapi 0", up)
+	}
+	if latencies != 2 {
+		t.Errorf("provider.probe.latency has %d points, want one per provider", latencies)
+	}
+}
@@ -0,0 +1,443 @@
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+	"github.com/fhsmendes/deploy-cloud-run/webhook"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/go-chi/chi/v5"
+	"github.com/parquet-go/parquet-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+
+	// DefaultExportSyncMaxRange is the widest range streamed in the response;
+	// wider or open ranges run as a background job.
+	DefaultExportSyncMaxRange = 24 * time.Hour
+
+	// WebhookExportFinished is the event of the completion webhook.
+	WebhookExportFinished = "history.export.finished"
+
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+
+	// maxExportJobs is how many jobs are kept; the oldest finished ones are
+	// dropped, with their files, past it.
+	maxExportJobs = 100
+)
+
+var exportContentTypes = map[string]string{
+	ExportCSV:     "text/csv; charset=utf-8",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+type ExportConfig struct {
+	// Dir is where background exports are written; empty is a directory
+	// under os.TempDir.
+	Dir          string
+	SyncMaxRange time.Duration
+	// WebhookURL, when set, receives a POST with the ExportJob as JSON each
+	// time a background export finishes, through the Dispatcher set with
+	// Exporter.SetWebhooks.
+	WebhookURL string
+}
+
+// ExportJob is a background export of a range of lookups.
+type ExportJob struct {
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	Format   string     `json:"format"`
+	From     *time.Time `json:"from,omitempty"`
+	To       time.Time  `json:"to"`
+	Rows     int        `json:"rows"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Download is the path the export is served from once done.
+	Download string `json:"download,omitempty"`
+	// Webhook is the ID of the completion webhook delivery, whose attempts
+	// /admin/webhooks/{id} reports.
+	Webhook string `json:"webhook,omitempty"`
+
+	path string
+}
+
+// Exporter serves exports of the lookups in a store, streaming narrow ranges
+// and running wide ones as background jobs.
+type Exporter struct {
+	store    Store
+	cfg      ExportConfig
+	webhooks *webhook.Dispatcher
+	// tasks runs the background jobs; its Shutdown waits for them
+	tasks *supervisor.Supervisor
+
+	mu   sync.Mutex
+	jobs map[string]*ExportJob
+}
+
+// NewExporter returns an Exporter that runs its background jobs on tasks.
+func NewExporter(store Store, cfg ExportConfig, tasks *supervisor.Supervisor) *Exporter {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "history-exports")
+	}
+	if cfg.SyncMaxRange <= 0 {
+		cfg.SyncMaxRange = DefaultExportSyncMaxRange
+	}
+	return &Exporter{
+		store: store,
+		cfg:   cfg,
+		tasks: tasks,
+		jobs:  make(map[string]*ExportJob),
+	}
+}
+
+// SetWebhooks sets the dispatcher that delivers the completion webhook.
+func (e *Exporter) SetWebhooks(d *webhook.Dispatcher) {
+	e.webhooks = d
+}
+
+// Handler exports the lookups between ?from= and ?to= (RFC 3339 times or
+// dates; a date as ?to= includes that whole day) as ?format=csv or parquet.
+// Ranges up to SyncMaxRange are streamed; wider or open ones, or any with
+// ?async=true, start a job answered with 202 and its status URL.
+func (e *Exporter) Handler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p := validation.NewParams(q)
+	format := p.Enum("format", ExportCSV, ExportCSV, ExportParquet)
+
+	var f Filter
+	var fromErr, toErr error
+	f.From, fromErr = parseExportTime(q.Get("from"), false)
+	p.Check(fromErr == nil, "from", validation.CodeInvalidType, fmt.Sprintf("invalid from: %v", fromErr))
+	f.To, toErr = parseExportTime(q.Get("to"), true)
+	p.Check(toErr == nil, "to", validation.CodeInvalidType, fmt.Sprintf("invalid to: %v", toErr))
+	if f.To.IsZero() {
+		f.To = time.Now().UTC()
+	}
+	if fromErr == nil && toErr == nil {
+		p.Check(f.From.IsZero() || f.From.Before(f.To), "from", validation.CodeOutOfRange, "from must be before to")
+	}
+	if errs := p.Errors(); len(errs) > 0 {
+		validation.WriteProblem(w, p.Status(), "invalid request parameters", errs)
+		return
+	}
+
+	if q.Get("async") == "true" || f.From.IsZero() || f.To.Sub(f.From) > e.cfg.SyncMaxRange {
+		job, err := e.start(r, format, f)
+		if err != nil {
+			writeExportError(w, http.StatusInternalServerError, "failed to start export")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", r.URL.Path+"/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(format, f)))
+	w.WriteHeader(http.StatusOK)
+	// The status is already sent, so a failure can only cut the body short
+	writeExport(w, format, e.store, f)
+}
+
+// JobHandler reports the status of the export job {id}.
+func (e *Exporter) JobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := e.job(chi.URLParam(r, "id"))
+	if !ok {
+		writeExportError(w, http.StatusNotFound, "export not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadHandler serves the file of the finished export job {id}.
+func (e *Exporter) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := e.job(chi.URLParam(r, "id"))
+	if !ok {
+		writeExportError(w, http.StatusNotFound, "export not found")
+		return
+	}
+	if job.Status != JobDone {
+		writeExportError(w, http.StatusConflict, "export is "+job.Status)
+		return
+	}
+	f, err := os.Open(job.path)
+	if err != nil {
+		writeExportError(w, http.StatusGone, "export file is no longer available")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", exportContentTypes[job.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(job.path)))
+	http.ServeContent(w, r, filepath.Base(job.path), *job.Finished, f)
+}
+
+// job returns a copy of the job, safe to read while it runs.
+func (e *Exporter) job(id string) (ExportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return ExportJob{}, false
+	}
+	return *job, true
+}
+
+func (e *Exporter) start(r *http.Request, format string, f Filter) (ExportJob, error) {
+	if err := os.MkdirAll(e.cfg.Dir, 0o700); err != nil {
+		return ExportJob{}, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	job := &ExportJob{
+		ID:      hex.EncodeToString(id),
+		Status:  JobRunning,
+		Format:  format,
+		To:      f.To,
+		Created: time.Now().UTC(),
+	}
+	if !f.From.IsZero() {
+		from := f.From
+		job.From = &from
+	}
+	job.path = filepath.Join(e.cfg.Dir, job.ID+"-"+exportFilename(format, f))
+	download := r.URL.Path + "/" + job.ID + "/download"
+
+	e.mu.Lock()
+	e.jobs[job.ID] = job
+	e.prune()
+	snapshot := *job
+	e.mu.Unlock()
+
+	// The job outlives the request but stays in its trace
+	ctx := context.WithoutCancel(r.Context())
+	e.tasks.Spawn(ctx, "history-export", func(ctx context.Context) {
+		e.run(ctx, job, f, download)
+	})
+	return snapshot, nil
+}
+
+func (e *Exporter) run(ctx context.Context, job *ExportJob, f Filter, download string) {
+	var rows int
+	err := tracing.WithSpan(ctx, otel.Tracer("service-orchestration"), "history-export", func(ctx context.Context, span trace.Span) error {
+		span.SetAttributes(attribute.String("export.id", job.ID), attribute.String("export.format", job.Format))
+		file, err := os.OpenFile(job.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		rows, err = writeExport(file, job.Format, e.store, f)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		span.SetAttributes(attribute.Int("export.rows", rows))
+		return err
+	})
+
+	notify := e.cfg.WebhookURL != "" && e.webhooks != nil
+	finished := time.Now().UTC()
+	e.mu.Lock()
+	if notify {
+		job.Webhook = "history-export-" + job.ID
+	}
+	job.Rows = rows
+	job.Finished = &finished
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		os.Remove(job.path)
+	} else {
+		job.Status = JobDone
+		job.Download = download
+	}
+	snapshot := *job
+	e.mu.Unlock()
+
+	if !notify {
+		return
+	}
+	if _, err := e.webhooks.Enqueue(ctx, snapshot.Webhook, WebhookExportFinished, e.cfg.WebhookURL, snapshot); err != nil {
+		slog.ErrorContext(ctx, "Failed to enqueue the export webhook", "export_id", job.ID, "error", err)
+		e.mu.Lock()
+		job.Webhook = ""
+		e.mu.Unlock()
+	}
+}
+
+// prune drops the oldest finished jobs past maxExportJobs. e.mu must be held.
+func (e *Exporter) prune() {
+	if len(e.jobs) <= maxExportJobs {
+		return
+	}
+	var finished []*ExportJob
+	for _, job := range e.jobs {
+		if job.Status != JobRunning {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Created.Before(finished[j].Created) })
+	for _, job := range finished[:min(len(finished), len(e.jobs)-maxExportJobs)] {
+		os.Remove(job.path)
+		delete(e.jobs, job.ID)
+	}
+}
+
+// exportRow is a lookup as one flat row; providers are kept as JSON and the
+// events, which only explain a single lookup, are left out.
+type exportRow struct {
+	TraceID       string  `parquet:"trace_id"`
+	TransactionID string  `parquet:"transaction_id"`
+	CEP           string  `parquet:"cep"`
+	Time          int64   `parquet:"time,timestamp(microsecond)"`
+	Route         string  `parquet:"route"`
+	Status        int32   `parquet:"status"`
+	DurationMs    float64 `parquet:"duration_ms"`
+	Providers     string  `parquet:"providers"`
+	Body          string  `parquet:"body"`
+}
+
+var csvHeader = []string{"trace_id", "transaction_id", "cep", "time", "route", "status", "duration_ms", "providers", "body"}
+
+func newExportRow(l Lookup) exportRow {
+	row := exportRow{
+		TraceID:       l.TraceID,
+		TransactionID: l.TransactionID,
+		CEP:           l.CEP,
+		Time:          l.Time.UnixMicro(),
+		Route:         l.Route,
+		Status:        int32(l.Status),
+		DurationMs:    l.DurationMs,
+		Body:          l.Body,
+	}
+	if len(l.Providers) > 0 {
+		providers, _ := json.Marshal(l.Providers)
+		row.Providers = string(providers)
+	}
+	return row
+}
+
+// writeExport writes the lookups matching f to w, oldest first, and returns
+// how many were written.
+func writeExport(w io.Writer, format string, store Store, f Filter) (int, error) {
+	var rows int
+	var write func(exportRow) error
+	var finish func() error
+
+	switch format {
+	case ExportParquet:
+		pw := parquet.NewGenericWriter[exportRow](w)
+		write = func(row exportRow) error {
+			_, err := pw.Write([]exportRow{row})
+			return err
+		}
+		finish = pw.Close
+	default:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		write = func(row exportRow) error {
+			return cw.Write([]string{
+				row.TraceID,
+				row.TransactionID,
+				row.CEP,
+				time.UnixMicro(row.Time).UTC().Format(time.RFC3339Nano),
+				row.Route,
+				strconv.Itoa(int(row.Status)),
+				strconv.FormatFloat(row.DurationMs, 'f', -1, 64),
+				row.Providers,
+				row.Body,
+			})
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	err := scan(store, f, func(l Lookup) error {
+		rows++
+		return write(newExportRow(l))
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, finish()
+}
+
+// scan walks the store with Scan when it can, and otherwise with the lookups
+// List returns.
+func scan(store Store, f Filter, fn func(Lookup) error) error {
+	if s, ok := store.(Scanner); ok {
+		return s.Scan(f, fn)
+	}
+	f.Limit = 0
+	lookups, err := store.List(f)
+	if err != nil {
+		return err
+	}
+	for i := len(lookups) - 1; i >= 0; i-- {
+		if err := fn(lookups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseExportTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or a YYYY-MM-DD date", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func exportFilename(format string, f Filter) string {
+	from := "start"
+	if !f.From.IsZero() {
+		from = f.From.Format("20060102T150405Z")
+	}
+	return fmt.Sprintf("history-%s-%s.%s", from, f.To.Format("20060102T150405Z"), format)
+}
+
+func writeExportError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
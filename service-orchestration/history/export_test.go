@@ -0,0 +1,208 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+	"github.com/fhsmendes/deploy-cloud-run/webhook"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/go-chi/chi/v5"
+	"github.com/parquet-go/parquet-go"
+)
+
+func exportStore() *MemoryStore {
+	store := NewMemoryStore(10)
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	store.Add(Lookup{TraceID: "1", CEP: "01001000", Time: day.Add(-time.Hour), Status: 200})
+	store.Add(Lookup{TraceID: "2", CEP: "20040002", Time: day.Add(time.Hour), Status: 502, Providers: map[string]bool{"viacep": false}, Body: `{"message":"a, b"}`})
+	store.Add(Lookup{TraceID: "3", CEP: "01001000", Time: day.Add(20 * time.Hour), Status: 200})
+	return store
+}
+
+// runWebhooks returns a dispatcher delivering until the test ends.
+func runWebhooks(t *testing.T) *webhook.Dispatcher {
+	d := webhook.NewDispatcher(webhook.NewMemoryStore())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d
+}
+
+func exportRouter(e *Exporter) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/admin/history/export", e.Handler)
+	r.Get("/admin/history/export/{id}", e.JobHandler)
+	r.Get("/admin/history/export/{id}/download", e.DownloadHandler)
+	return r
+}
+
+func TestExportCSV(t *testing.T) {
+	h := exportRouter(NewExporter(exportStore(), ExportConfig{Dir: t.TempDir()}, supervisor.New(context.Background())))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/history/export?from=2026-10-14&to=2026-10-14", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "2" || records[2][0] != "3" {
+		t.Fatalf("records = %v, want the header and lookups 2 and 3", records)
+	}
+	if got := records[1][7]; got != `{"viacep":false}` {
+		t.Errorf("providers = %q", got)
+	}
+	if got := records[1][8]; got != `{"message":"a, b"}` {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	h := exportRouter(NewExporter(exportStore(), ExportConfig{Dir: t.TempDir()}, supervisor.New(context.Background())))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/history/export?format=parquet&from=2026-10-13T20:00:00Z&to=2026-10-14T02:00:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	rows, err := parquet.Read[exportRow](bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].TraceID != "1" || rows[1].Status != 502 {
+		t.Errorf("rows = %+v, want lookups 1 and 2", rows)
+	}
+}
+
+func TestExportRejectsInvalidRequests(t *testing.T) {
+	h := exportRouter(NewExporter(exportStore(), ExportConfig{Dir: t.TempDir()}, supervisor.New(context.Background())))
+
+	tests := []struct {
+		target string
+		status int
+		field  string
+	}{
+		{"/admin/history/export?format=xlsx&from=2026-10-14", http.StatusUnprocessableEntity, "format"},
+		{"/admin/history/export?from=yesterday", http.StatusBadRequest, "from"},
+		{"/admin/history/export?from=2026-10-15&to=2026-10-14", http.StatusUnprocessableEntity, "from"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.status)
+		}
+		var problem validation.Problem
+		json.NewDecoder(rec.Body).Decode(&problem)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field {
+			t.Errorf("%s: errors = %+v, want one for %s", tt.target, problem.Errors, tt.field)
+		}
+	}
+}
+
+func TestExportJob(t *testing.T) {
+	notified := make(chan ExportJob, 1)
+	webhookIDs := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhookIDs <- r.Header.Get("Webhook-Id")
+		var job ExportJob
+		json.NewDecoder(r.Body).Decode(&job)
+		notified <- job
+	}))
+	defer webhook.Close()
+
+	e := NewExporter(exportStore(), ExportConfig{Dir: t.TempDir(), WebhookURL: webhook.URL}, supervisor.New(context.Background()))
+	webhooks := runWebhooks(t)
+	e.SetWebhooks(webhooks)
+	h := exportRouter(e)
+
+	// An open range is too wide to stream
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/history/export?to=2026-10-15", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+	var started ExportJob
+	json.NewDecoder(rec.Body).Decode(&started)
+
+	var job ExportJob
+	select {
+	case job = <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion webhook was not called")
+	}
+	if job.ID != started.ID || job.Status != JobDone || job.Rows != 3 {
+		t.Fatalf("notified job = %+v, want %s done with 3 rows", job, started.ID)
+	}
+	if rec.Header().Get("Location") != "/admin/history/export/"+job.ID {
+		t.Errorf("Location = %q", rec.Header().Get("Location"))
+	}
+	// The job links to its delivery, whose attempts are audited
+	if id := <-webhookIDs; id != "history-export-"+job.ID || job.Webhook != id {
+		t.Errorf("Webhook-Id = %q for job webhook %q, want the delivery of the job", id, job.Webhook)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, job.Download, nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || bytes.Count(body, []byte("\n")) != 4 {
+		t.Errorf("download = %d %q, want the header and 3 lookups", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/history/export/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", rec.Code)
+	}
+}
+
+// blockingStore holds every scan until release is closed.
+type blockingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s blockingStore) Scan(f Filter, fn func(Lookup) error) error {
+	<-s.release
+	return nil
+}
+
+func TestExportJobsDelayShutdown(t *testing.T) {
+	store := blockingStore{MemoryStore: exportStore(), release: make(chan struct{})}
+	tasks := supervisor.New(context.Background())
+	e := NewExporter(store, ExportConfig{Dir: t.TempDir()}, tasks)
+	rec := httptest.NewRecorder()
+	exportRouter(e).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/history/export?to=2026-10-15", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+
+	// The job is held by its store, so shutdown gives up at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tasks.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown returned before the job finished")
+	}
+
+	close(store.release)
+	if err := tasks.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown after the job finished: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// maxLineSize bounds the lookups read back from a history file. Longer lines
+// are skipped like any other line that cannot be decoded, rather than
+// failing the whole read.
+const maxLineSize = 16 << 20
+
+// FileStore persists every lookup to an append-only JSON lines file, so reports
+// can be built from the full history, and answers queries from the most recent
+// lookups kept in memory. Purge bounds the file by retention.
+type FileStore struct {
+	*MemoryStore
+
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// NewFileStore opens (or creates) the history file at path, loading the most
+// recent maxEntries lookups already in it.
+func NewFileStore(path string, maxEntries int) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(maxEntries), path: path}
+
+	err := ReadFile(path, func(l Lookup) error {
+		return s.MemoryStore.Add(l)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read lookup history: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lookup history: %w", err)
+	}
+	s.file = f
+	return s, nil
+}
+
+func (s *FileStore) Add(l Lookup) error {
+	s.MemoryStore.Add(l)
+
+	line, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Scan walks the whole file rather than the lookups kept in memory.
+func (s *FileStore) Scan(f Filter, fn func(Lookup) error) error {
+	return ReadFile(s.path, func(l Lookup) error {
+		if !f.matches(l) {
+			return nil
+		}
+		return fn(l)
+	})
+}
+
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+// Purge rewrites the history file without the lookups older than r.Days.
+// The file has no soft delete, so they are deleted at once and r.Grace does
+// not apply.
+func (s *FileStore) Purge(ctx context.Context, now time.Time, r Retention) (PurgeResult, error) {
+	var result PurgeResult
+	if !r.Enabled() {
+		return result, nil
+	}
+	cutoff := now.AddDate(0, 0, -r.Days)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return result, fmt.Errorf("failed to purge lookup history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	err = ReadFile(s.path, func(l Lookup) error {
+		if l.Time.Before(cutoff) {
+			result.Deleted++
+			return nil
+		}
+		line, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(line, '\n'))
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(0o600)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("failed to purge lookup history: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return result, fmt.Errorf("failed to reopen lookup history: %w", err)
+	}
+	s.file.Close()
+	s.file = f
+	s.MemoryStore.dropBefore(cutoff)
+	purgedRows.Add(ctx, result.Deleted, metric.WithAttributes(attribute.String("phase", "delete")))
+	return result, nil
+}
+
+// ReadFile calls fn with every lookup stored in the history file at path, in
+// the order they were recorded. Lines that cannot be decoded, or are longer
+// than maxLineSize, are skipped.
+func ReadFile(path string, fn func(Lookup) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := readLine(r)
+		if len(line) > 0 {
+			var l Lookup
+			if json.Unmarshal(line, &l) == nil {
+				if err := fn(l); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readLine returns the next line of r without its newline. Lookups store the
+// response body, so lines can be far longer than the reader's buffer; a line
+// longer than maxLineSize is discarded and returned empty.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > maxLineSize {
+			tooLong, line = true, nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), err
+	}
+}
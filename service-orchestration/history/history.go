@@ -0,0 +1,308 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Lookup struct {
+	TraceID       string    `json:"trace_id"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	CEP           string    `json:"cep"`
+	Time          time.Time `json:"time"`
+	Route         string    `json:"route,omitempty"`
+	Status        int       `json:"status"`
+	DurationMs    float64   `json:"duration_ms"`
+	Body          string    `json:"body"`
+
+	// Providers holds whether each upstream provider called during the
+	// lookup answered successfully.
+	Providers map[string]bool `json:"providers,omitempty"`
+
+	// Events are the steps of the lookup, in the order they started.
+	Events []Event `json:"events,omitempty"`
+}
+
+// Event is a step of a lookup, such as a stage, a provider call or a cache
+// interaction, kept so the lookup can be explained without its trace.
+type Event struct {
+	Name string `json:"name"`
+	// OffsetMs is when the step started, relative to the request.
+	OffsetMs   float64 `json:"offset_ms"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Detail     string  `json:"detail,omitempty"`
+}
+
+type Filter struct {
+	CEP           string
+	TransactionID string
+	// From and To bound the time of the lookup, To excluded; zero leaves
+	// that side open.
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+func (f Filter) matches(l Lookup) bool {
+	if f.CEP != "" && l.CEP != f.CEP {
+		return false
+	}
+	if f.TransactionID != "" && l.TransactionID != f.TransactionID {
+		return false
+	}
+	if !f.From.IsZero() && l.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !l.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+type Store interface {
+	Add(l Lookup) error
+	Get(traceID string) (Lookup, bool, error)
+	List(f Filter) ([]Lookup, error)
+}
+
+// Scanner is implemented by stores that can walk every lookup they persisted,
+// not only the most recent ones List returns, such as for exports.
+type Scanner interface {
+	// Scan calls fn with the matching lookups, oldest first, stopping at the
+	// first error fn returns. Filter.Limit is ignored.
+	Scan(f Filter, fn func(Lookup) error) error
+}
+
+// MemoryStore keeps the most recent lookups in memory.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	lookups    []Lookup
+	maxEntries int
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{maxEntries: maxEntries}
+}
+
+func (s *MemoryStore) Add(l Lookup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lookups = append(s.lookups, l)
+	if len(s.lookups) > s.maxEntries {
+		s.lookups = s.lookups[len(s.lookups)-s.maxEntries:]
+	}
+	return nil
+}
+
+// dropBefore removes the lookups recorded before cutoff.
+func (s *MemoryStore) dropBefore(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.lookups[:0]
+	for _, l := range s.lookups {
+		if !l.Time.Before(cutoff) {
+			kept = append(kept, l)
+		}
+	}
+	s.lookups = kept
+}
+
+func (s *MemoryStore) Get(traceID string) (Lookup, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.lookups) - 1; i >= 0; i-- {
+		if s.lookups[i].TraceID == traceID {
+			return s.lookups[i], true, nil
+		}
+	}
+	return Lookup{}, false, nil
+}
+
+// List returns matching lookups, most recent first.
+func (s *MemoryStore) List(f Filter) ([]Lookup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []Lookup{}
+	for i := len(s.lookups) - 1; i >= 0; i-- {
+		l := s.lookups[i]
+		if !f.matches(l) {
+			continue
+		}
+		result = append(result, l)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Scan(f Filter, fn func(Lookup) error) error {
+	s.mu.RLock()
+	lookups := make([]Lookup, len(s.lookups))
+	copy(lookups, s.lookups)
+	s.mu.RUnlock()
+
+	for _, l := range lookups {
+		if !f.matches(l) {
+			continue
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pendingKey struct{}
+
+type pending struct {
+	mu        sync.Mutex
+	start     time.Time
+	traceID   string
+	providers map[string]bool
+	events    []Event
+}
+
+// SetTraceID attaches the trace ID of the lookup being handled so Middleware
+// can store it. Handlers call it once their root span is started.
+func SetTraceID(ctx context.Context, traceID trace.TraceID) {
+	if !traceID.IsValid() {
+		return
+	}
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.mu.Lock()
+		p.traceID = traceID.String()
+		p.mu.Unlock()
+	}
+}
+
+// SetProviderResult records whether provider answered the lookup being handled.
+// A provider called more than once keeps its last result.
+func SetProviderResult(ctx context.Context, provider string, ok bool) {
+	p, found := ctx.Value(pendingKey{}).(*pending)
+	if !found {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.providers == nil {
+		p.providers = make(map[string]bool)
+	}
+	p.providers[provider] = ok
+}
+
+// AddEvent records a step of the lookup being handled that started at start
+// and took d; instantaneous steps pass a zero d.
+func AddEvent(ctx context.Context, name, detail string, start time.Time, d time.Duration) {
+	p, found := ctx.Value(pendingKey{}).(*pending)
+	if !found {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, Event{
+		Name:       name,
+		OffsetMs:   milliseconds(start.Sub(p.start)),
+		DurationMs: milliseconds(d),
+		Detail:     detail,
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// LookupFunc runs a /temperature lookup of query with header from inside the
+// service, such as a batch item or a replay, and returns the status and body
+// of its response.
+type LookupFunc func(ctx context.Context, query url.Values, header http.Header) (status int, body []byte)
+
+// Middleware records every response of the wrapped lookup handler in store.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := &pending{start: time.Now()}
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), pendingKey{}, p)))
+			p.save(r.Context(), store, r.Header, r.URL.Query().Get("cep"), serve.RoutePath(r.Context(), r.URL.Path), rw.status, rw.body.String())
+		})
+	}
+}
+
+// Record records every response of lookup in store, as Middleware does for
+// lookups served over HTTP.
+func Record(store Store, lookup LookupFunc) LookupFunc {
+	return func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		p := &pending{start: time.Now()}
+		status, body := lookup(context.WithValue(ctx, pendingKey{}, p), query, header)
+		p.save(ctx, store, header, query.Get("cep"), serve.RoutePath(ctx, "/temperature"), status, string(body))
+		return status, body
+	}
+}
+
+// save stores the lookup once the handler attached its trace ID.
+func (p *pending) save(ctx context.Context, store Store, header http.Header, cep, route string, status int, body string) {
+	duration := time.Since(p.start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.traceID == "" {
+		return
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	store.Add(Lookup{
+		TraceID:       p.traceID,
+		TransactionID: utils.TransactionID(ctx, header),
+		CEP:           cep,
+		Time:          p.start.UTC(),
+		Route:         route,
+		Status:        status,
+		DurationMs:    milliseconds(duration),
+		Body:          body,
+		Providers:     p.providers,
+		Events:        sortedEvents(p.events),
+	})
+}
+
+// sortedEvents orders events by start; steps are recorded when they end, so
+// an enclosing stage comes after the calls it made.
+func sortedEvents(events []Event) []Event {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OffsetMs < events[j].OffsetMs })
+	return events
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,315 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareRecordsLookup(t *testing.T) {
+	store := NewMemoryStore(10)
+	traceID := trace.TraceID{0x4b, 0xf9}
+
+	h := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetTraceID(r.Context(), traceID)
+		SetProviderResult(r.Context(), "viacep", true)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("can not find zipcode"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/temperature?cep=99999999", nil))
+
+	l, ok, _ := store.Get(traceID.String())
+	if !ok {
+		t.Fatal("lookup was not recorded")
+	}
+	if l.CEP != "99999999" || l.Status != http.StatusNotFound || l.Body != "can not find zipcode" {
+		t.Errorf("recorded lookup = %+v", l)
+	}
+	if l.Route != "/temperature" || !l.Providers["viacep"] {
+		t.Errorf("recorded route and providers = %q, %v", l.Route, l.Providers)
+	}
+}
+
+func TestRecordLookup(t *testing.T) {
+	store := NewMemoryStore(10)
+	traceID := trace.TraceID{0x4c, 0x01}
+
+	lookup := Record(store, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		SetTraceID(ctx, traceID)
+		return http.StatusOK, []byte(`{"temp_C":25}`)
+	})
+	status, body := lookup(context.Background(), url.Values{"cep": {"01001000"}}, http.Header{})
+	if status != http.StatusOK || string(body) != `{"temp_C":25}` {
+		t.Errorf("lookup = %d, %s", status, body)
+	}
+
+	l, ok, _ := store.Get(traceID.String())
+	if !ok {
+		t.Fatal("lookup was not recorded")
+	}
+	if l.CEP != "01001000" || l.Status != http.StatusOK || l.Route != "/temperature" {
+		t.Errorf("recorded lookup = %+v", l)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	store, err := NewFileStore(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add(Lookup{TraceID: "1", CEP: "01001000", Status: 200})
+	store.Add(Lookup{TraceID: "2", CEP: "20040002", Status: 502})
+	store.Close()
+
+	reopened, err := NewFileStore(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if l, ok, _ := reopened.Get("2"); !ok || l.Status != 502 {
+		t.Errorf("Get(2) after reopening = %+v, %v", l, ok)
+	}
+
+	var all []string
+	ReadFile(path, func(l Lookup) error {
+		all = append(all, l.TraceID)
+		return nil
+	})
+	if len(all) != 2 {
+		t.Errorf("ReadFile() = %v, want every lookup ever stored", all)
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "orchestration.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	at := time.Date(2026, 10, 15, 12, 0, 0, 500, time.UTC)
+	store.Add(Lookup{TraceID: "1", CEP: "01001000", Time: at, Status: 200, Providers: map[string]bool{"viacep": true}})
+	store.Add(Lookup{TraceID: "2", TransactionID: "tx", CEP: "20040002", Status: 502, Events: []Event{{Name: "stage validate", DurationMs: 0.5}}})
+	store.Add(Lookup{TraceID: "3", CEP: "01001000", Status: 200})
+
+	if l, ok, err := store.Get("1"); err != nil || !ok || !l.Time.Equal(at) || !l.Providers["viacep"] {
+		t.Errorf("Get(1) = %+v, %v, %v", l, ok, err)
+	}
+	if l, _, _ := store.Get("2"); len(l.Events) != 1 || l.Events[0].Name != "stage validate" {
+		t.Errorf("Get(2) events = %+v", l.Events)
+	}
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all, newest first", Filter{}, []string{"3", "2", "1"}},
+		{"cep", Filter{CEP: "01001000"}, []string{"3", "1"}},
+		{"transaction", Filter{TransactionID: "tx"}, []string{"2"}},
+		{"limit", Filter{Limit: 1}, []string{"3"}},
+	}
+	for _, tt := range tests {
+		lookups, err := store.List(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, l := range lookups {
+			got = append(got, l.TraceID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: List() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var scanned []string
+	store.Scan(Filter{CEP: "01001000", Limit: 1}, func(l Lookup) error {
+		scanned = append(scanned, l.TraceID)
+		return nil
+	})
+	if !reflect.DeepEqual(scanned, []string{"1", "3"}) {
+		t.Errorf("Scan(cep) = %v, want every match, oldest first", scanned)
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	store := NewMemoryStore(2)
+	store.Add(Lookup{TraceID: "1", CEP: "01001000"})
+	store.Add(Lookup{TraceID: "2", CEP: "20040002"})
+	store.Add(Lookup{TraceID: "3", CEP: "01001000"})
+
+	all, _ := store.List(Filter{})
+	if len(all) != 2 || all[0].TraceID != "3" {
+		t.Errorf("List() = %+v, want the 2 most recent, newest first", all)
+	}
+
+	byCEP, _ := store.List(Filter{CEP: "01001000"})
+	if len(byCEP) != 1 || byCEP[0].TraceID != "3" {
+		t.Errorf("List(cep) = %+v, want only trace 3", byCEP)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   Lookup
+		fields []string
+	}{
+		{
+			"identical",
+			Lookup{Status: 200, Body: `{"city":"São Paulo","temp_C":20}`},
+			Lookup{Status: 200, Body: `{"city":"São Paulo","temp_C":20}`},
+			nil,
+		},
+		{
+			"temperature changed",
+			Lookup{Status: 200, Body: `{"city":"São Paulo","temp_C":20,"temp_F":68}`},
+			Lookup{Status: 200, Body: `{"city":"São Paulo","temp_C":25,"temp_F":77}`},
+			[]string{"temp_C", "temp_F"},
+		},
+		{
+			"status and text body changed",
+			Lookup{Status: 404, Body: "can not find zipcode"},
+			Lookup{Status: 200, Body: `{"city":"São Paulo"}`},
+			[]string{"status", "body"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := Diff(tt.a, tt.b)
+			if len(diffs) != len(tt.fields) {
+				t.Fatalf("Diff() = %+v, want fields %v", diffs, tt.fields)
+			}
+			for i, d := range diffs {
+				if d.Field != tt.fields[i] {
+					t.Errorf("Diff()[%d].Field = %q, want %q", i, d.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	store := NewMemoryStore(10)
+	traceID := trace.TraceID{0x4b, 0xfa}
+
+	h := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetTraceID(r.Context(), traceID)
+		stage := time.Now()
+		call := stage.Add(time.Millisecond)
+		AddEvent(r.Context(), "provider viacep", "ok", call, 120*time.Millisecond)
+		AddEvent(r.Context(), "stage cep_lookup", "ok", stage, 121*time.Millisecond)
+		AddEvent(r.Context(), "cache temperature", "hit", stage.Add(122*time.Millisecond), 0)
+		w.Write([]byte(`{"city":"São Paulo","temp_C":25}`))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/temperature?cep=01001000", nil))
+
+	l, _, _ := store.Get(traceID.String())
+	timeline := NewTimeline(l)
+
+	var steps []string
+	for _, s := range timeline.Steps {
+		steps = append(steps, s.Step)
+	}
+	want := []string{"received", "stage cep_lookup", "provider viacep", "cache temperature", "responded"}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+
+	text := timeline.String()
+	for _, line := range []string{"received: GET /temperature cep=01001000", "provider viacep (120.0ms): ok", "responded: 200 OK"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("timeline text does not contain %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestMiddlewareRecordsRouteRelativeToBasePath(t *testing.T) {
+	store := NewMemoryStore(10)
+	traceID := trace.TraceID{0x4b, 0xfb}
+
+	r := chi.NewRouter()
+	r.With(Middleware(store)).Get("/temperature", func(w http.ResponseWriter, r *http.Request) {
+		SetTraceID(r.Context(), traceID)
+	})
+	root := chi.NewRouter()
+	root.Mount("/api/weather/v1", r)
+	root.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/weather/v1/temperature?cep=01001000", nil))
+
+	if l, _, _ := store.Get(traceID.String()); l.Route != "/temperature" {
+		t.Errorf("recorded route = %q, want /temperature", l.Route)
+	}
+}
+
+func TestFileStoreSkipsUnreadableLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	huge, _ := json.Marshal(Lookup{TraceID: "huge", Body: strings.Repeat("x", maxLineSize)})
+	long, _ := json.Marshal(Lookup{TraceID: "long", Body: strings.Repeat("x", 2<<20)})
+	contents := string(huge) + "\n{not json\n" + string(long) + "\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileStore(path, 10)
+	if err != nil {
+		t.Fatalf("NewFileStore() = %v, want the bad lines skipped", err)
+	}
+	defer store.Close()
+	if _, ok, _ := store.Get("long"); !ok {
+		t.Error("lookup longer than the scanner's old 1MB limit not loaded")
+	}
+	if _, ok, _ := store.Get("huge"); ok {
+		t.Error("lookup longer than maxLineSize loaded")
+	}
+}
+
+func TestFileStorePurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := NewFileStore(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store.Add(Lookup{TraceID: "old", Time: now.AddDate(0, 0, -40)})
+	store.Add(Lookup{TraceID: "recent", Time: now.AddDate(0, 0, -1)})
+
+	result, err := store.Purge(context.Background(), now, Retention{Days: 30})
+	if err != nil || result.Deleted != 1 {
+		t.Fatalf("Purge() = %+v, %v; want 1 lookup deleted", result, err)
+	}
+	store.Add(Lookup{TraceID: "new", Time: now})
+
+	var kept []string
+	ReadFile(path, func(l Lookup) error {
+		kept = append(kept, l.TraceID)
+		return nil
+	})
+	if !reflect.DeepEqual(kept, []string{"recent", "new"}) {
+		t.Errorf("file holds %v after purging, want [recent new]", kept)
+	}
+	if _, ok, _ := store.Get("old"); ok {
+		t.Error("purged lookup still served from memory")
+	}
+}
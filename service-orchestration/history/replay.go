@@ -0,0 +1,119 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Difference struct {
+	Field    string `json:"field"`
+	Original any    `json:"original"`
+	Replay   any    `json:"replay"`
+}
+
+type ReplayResult struct {
+	TraceID     string       `json:"trace_id"`
+	Original    Lookup       `json:"original"`
+	Replay      Lookup       `json:"replay"`
+	Differences []Difference `json:"differences"`
+}
+
+// HistoryHandler lists stored lookups, filtered by ?cep= and ?transaction_id=
+// and limited by ?limit=.
+func HistoryHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := Filter{
+			CEP:           r.URL.Query().Get("cep"),
+			TransactionID: r.URL.Query().Get("transaction_id"),
+			Limit:         100,
+		}
+		fmt.Sscan(r.URL.Query().Get("limit"), &f.Limit)
+
+		lookups, err := store.List(f)
+		if err != nil {
+			http.Error(w, "failed to list history", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(lookups)
+	}
+}
+
+// ReplayHandler re-runs the lookup stored under {trace_id} against lookup and
+// returns the differences between the original and the new result.
+func ReplayHandler(store Store, lookup LookupFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traceID := chi.URLParam(r, "trace_id")
+		original, ok, err := store.Get(traceID)
+		if err != nil {
+			http.Error(w, "failed to read history", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "lookup not found"})
+			return
+		}
+
+		status, body := lookup(r.Context(), url.Values{"cep": {original.CEP}}, http.Header{})
+		replay := Lookup{CEP: original.CEP, Status: status, Body: string(body)}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ReplayResult{
+			TraceID:     traceID,
+			Original:    original,
+			Replay:      replay,
+			Differences: Diff(original, replay),
+		})
+	}
+}
+
+// Diff compares two lookups field by field. JSON bodies are compared per
+// top-level field; other bodies are compared as a whole.
+func Diff(a, b Lookup) []Difference {
+	diffs := []Difference{}
+	if a.Status != b.Status {
+		diffs = append(diffs, Difference{Field: "status", Original: a.Status, Replay: b.Status})
+	}
+
+	var aFields, bFields map[string]any
+	aErr := json.Unmarshal([]byte(a.Body), &aFields)
+	bErr := json.Unmarshal([]byte(b.Body), &bFields)
+	if aErr != nil || bErr != nil {
+		if strings.TrimSpace(a.Body) != strings.TrimSpace(b.Body) {
+			diffs = append(diffs, Difference{Field: "body", Original: a.Body, Replay: b.Body})
+		}
+		return diffs
+	}
+
+	keys := make(map[string]bool)
+	for k := range aFields {
+		keys[k] = true
+	}
+	for k := range bFields {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		if !reflect.DeepEqual(aFields[k], bFields[k]) {
+			diffs = append(diffs, Difference{Field: k, Original: aFields[k], Replay: bFields[k]})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// DefaultPurgeGrace is how long soft-deleted lookups are kept before
+	// they are deleted for good.
+	DefaultPurgeGrace = 7 * 24 * time.Hour
+	// DefaultPurgeBatchSize is how many rows a purge statement touches at a
+	// time, so recording lookups is never blocked for long.
+	DefaultPurgeBatchSize = 500
+	// DefaultPurgeInterval is how often the history-purge job runs.
+	DefaultPurgeInterval = time.Hour
+)
+
+var purgedRows, _ = otel.Meter("service-orchestration").Int64Counter(
+	"history.purged_rows",
+	metric.WithDescription("Lookups removed from the history by retention, by phase (soft_delete, delete)"),
+)
+
+// Retention bounds the lookups kept. Lookups older than Days are
+// soft-deleted, hidden from every query, and deleted for good Grace later;
+// the grace leaves a window to recover them from the database by hand.
+type Retention struct {
+	// Days is how many days of lookups are kept; zero keeps them forever.
+	Days      int
+	Grace     time.Duration
+	BatchSize int
+}
+
+func (r Retention) Enabled() bool {
+	return r.Days > 0
+}
+
+// PurgeResult counts the lookups a purge removed.
+type PurgeResult struct {
+	SoftDeleted int64 `json:"soft_deleted"`
+	Deleted     int64 `json:"deleted"`
+}
+
+// Purger is implemented by stores whose history grows without bound and
+// must be purged by retention. MemoryStore already keeps only its most
+// recent lookups.
+type Purger interface {
+	Purge(ctx context.Context, now time.Time, r Retention) (PurgeResult, error)
+}
+
+// Purge soft-deletes the lookups older than r.Days and deletes those
+// soft-deleted more than r.Grace before now, r.BatchSize rows at a time.
+func (s *SQLStore) Purge(ctx context.Context, now time.Time, r Retention) (PurgeResult, error) {
+	var result PurgeResult
+	if !r.Enabled() {
+		return result, nil
+	}
+	if r.BatchSize <= 0 {
+		r.BatchSize = DefaultPurgeBatchSize
+	}
+
+	cutoff := now.AddDate(0, 0, -r.Days).UnixNano()
+	n, err := s.inBatches(ctx, r.BatchSize, `UPDATE lookups SET deleted = ? WHERE id IN (
+	SELECT id FROM lookups WHERE deleted IS NULL AND time < ? LIMIT ?)`, now.UnixNano(), cutoff)
+	result.SoftDeleted = n
+	purgedRows.Add(ctx, n, metric.WithAttributes(attribute.String("phase", "soft_delete")))
+	if err != nil {
+		return result, fmt.Errorf("failed to soft-delete lookups: %w", err)
+	}
+
+	n, err = s.inBatches(ctx, r.BatchSize, `DELETE FROM lookups WHERE id IN (
+	SELECT id FROM lookups WHERE deleted IS NOT NULL AND deleted < ? LIMIT ?)`, now.Add(-r.Grace).UnixNano())
+	result.Deleted = n
+	purgedRows.Add(ctx, n, metric.WithAttributes(attribute.String("phase", "delete")))
+	if err != nil {
+		return result, fmt.Errorf("failed to delete lookups: %w", err)
+	}
+	return result, nil
+}
+
+// inBatches runs query, which ends with a LIMIT placeholder, until it
+// affects fewer than size rows, returning how many it affected in total.
+// Each batch is a statement of its own, so other writers get the single
+// connection in between.
+func (s *SQLStore) inBatches(ctx context.Context, size int, query string, args ...any) (int64, error) {
+	var total int64
+	for {
+		res, err := s.db.ExecContext(ctx, query, append(args, size)...)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(size) {
+			return total, nil
+		}
+	}
+}
+
+// PurgeHandler purges the history by r now rather than at the next run of
+// the history-purge job, and reports how many lookups were removed.
+func PurgeHandler(store Purger, r Retention) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !r.Enabled() {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"message": "history retention is not configured"})
+			return
+		}
+		result, err := store.Purge(req.Context(), time.Now(), r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{"message": err.Error(), "purged": result})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,78 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/database"
+)
+
+func TestSQLStorePurge(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "orchestration.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for i, age := range []time.Duration{40 * 24 * time.Hour, 35 * 24 * time.Hour, 31 * 24 * time.Hour, time.Hour} {
+		store.Add(Lookup{TraceID: string(rune('a' + i)), CEP: "01001000", Time: now.Add(-age), Status: 200})
+	}
+	retention := Retention{Days: 30, Grace: 24 * time.Hour, BatchSize: 2}
+
+	result, err := store.Purge(context.Background(), now, retention)
+	if err != nil || result != (PurgeResult{SoftDeleted: 3}) {
+		t.Fatalf("Purge() = %+v, %v, want 3 soft-deleted", result, err)
+	}
+	if lookups, _ := store.List(Filter{}); len(lookups) != 1 || lookups[0].TraceID != "d" {
+		t.Errorf("List() = %+v, want only the recent lookup", lookups)
+	}
+	if _, ok, _ := store.Get("a"); ok {
+		t.Error("Get() found a soft-deleted lookup")
+	}
+	var rows int
+	db.QueryRow(`SELECT COUNT(*) FROM lookups`).Scan(&rows)
+	if rows != 4 {
+		t.Errorf("%d rows left, want soft-deleted ones kept during the grace", rows)
+	}
+
+	result, err = store.Purge(context.Background(), now.Add(2*24*time.Hour), retention)
+	if err != nil || result != (PurgeResult{Deleted: 3}) {
+		t.Fatalf("Purge() after the grace = %+v, %v, want 3 deleted", result, err)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM lookups`).Scan(&rows)
+	if rows != 1 {
+		t.Errorf("%d rows left, want 1", rows)
+	}
+}
+
+func TestPurgeHandler(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "orchestration.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	store.Add(Lookup{TraceID: "1", CEP: "01001000", Time: time.Now().AddDate(0, 0, -10), Status: 200})
+
+	rec := httptest.NewRecorder()
+	PurgeHandler(store, Retention{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/history/purge", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status without retention = %d, want 409", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	PurgeHandler(store, Retention{Days: 7, Grace: time.Hour}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/history/purge", nil))
+	var result PurgeResult
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || result.SoftDeleted != 1 {
+		t.Errorf("purge = %d %+v, want 200 with 1 soft-deleted", rec.Code, result)
+	}
+}
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore keeps every lookup in the lookups table of a database opened with
+// database.Open, answering queries from it rather than from memory. Lookups
+// soft-deleted by Purge are left out of every query.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// scanPageSize is how many rows Scan reads per query. The database has a single
+// connection, so paging lets lookups be recorded while an export runs.
+const scanPageSize = 500
+
+const lookupColumns = `trace_id, transaction_id, cep, time, route, status, duration_ms, body, providers, events`
+
+func (s *SQLStore) Add(l Lookup) error {
+	providers, err := marshalNullable(l.Providers, len(l.Providers) > 0)
+	if err != nil {
+		return err
+	}
+	events, err := marshalNullable(l.Events, len(l.Events) > 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO lookups (`+lookupColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.TraceID, l.TransactionID, l.CEP, l.Time.UnixNano(), l.Route, l.Status, l.DurationMs, l.Body, providers, events)
+	if err != nil {
+		return fmt.Errorf("failed to store lookup: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(traceID string) (Lookup, bool, error) {
+	rows, err := s.db.Query(`SELECT id, `+lookupColumns+` FROM lookups WHERE trace_id = ? AND deleted IS NULL ORDER BY id DESC LIMIT 1`, traceID)
+	if err != nil {
+		return Lookup{}, false, err
+	}
+	var l Lookup
+	found := false
+	err = eachLookup(rows, func(_ int64, row Lookup) error {
+		l, found = row, true
+		return nil
+	})
+	return l, found, err
+}
+
+// List returns matching lookups, most recent first.
+func (s *SQLStore) List(f Filter) ([]Lookup, error) {
+	query, args := selectLookups(f)
+	query += ` ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := []Lookup{}
+	err = eachLookup(rows, func(_ int64, l Lookup) error {
+		result = append(result, l)
+		return nil
+	})
+	return result, err
+}
+
+// Scan reads the matching lookups a page at a time, so an export of the whole
+// table is never held in memory.
+func (s *SQLStore) Scan(f Filter, fn func(Lookup) error) error {
+	var after int64
+	for {
+		query, args := selectLookups(f)
+		query += ` AND id > ? ORDER BY id LIMIT ?`
+		rows, err := s.db.Query(query, append(args, after, scanPageSize)...)
+		if err != nil {
+			return err
+		}
+
+		var page []Lookup
+		err = eachLookup(rows, func(id int64, l Lookup) error {
+			after = id
+			page = append(page, l)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, l := range page {
+			if err := fn(l); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+	}
+}
+
+func selectLookups(f Filter) (string, []any) {
+	where := []string{"deleted IS NULL"}
+	var args []any
+	if f.CEP != "" {
+		where = append(where, "cep = ?")
+		args = append(args, f.CEP)
+	}
+	if f.TransactionID != "" {
+		where = append(where, "transaction_id = ?")
+		args = append(args, f.TransactionID)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, f.From.UnixNano())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, f.To.UnixNano())
+	}
+
+	return `SELECT id, ` + lookupColumns + ` FROM lookups WHERE ` + strings.Join(where, " AND "), args
+}
+
+func eachLookup(rows *sql.Rows, fn func(id int64, l Lookup) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var l Lookup
+		var id, nanos int64
+		var providers, events sql.NullString
+		if err := rows.Scan(&id, &l.TraceID, &l.TransactionID, &l.CEP, &nanos, &l.Route, &l.Status, &l.DurationMs, &l.Body, &providers, &events); err != nil {
+			return err
+		}
+		l.Time = time.Unix(0, nanos).UTC()
+		if providers.Valid {
+			if err := json.Unmarshal([]byte(providers.String), &l.Providers); err != nil {
+				return fmt.Errorf("invalid providers of lookup %s: %w", l.TraceID, err)
+			}
+		}
+		if events.Valid {
+			if err := json.Unmarshal([]byte(events.String), &l.Events); err != nil {
+				return fmt.Errorf("invalid events of lookup %s: %w", l.TraceID, err)
+			}
+		}
+		if err := fn(id, l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// marshalNullable encodes v as JSON, or as NULL when it is empty.
+func marshalNullable(v any, set bool) (sql.NullString, error) {
+	if !set {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
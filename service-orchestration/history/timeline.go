@@ -0,0 +1,96 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Timeline is a stored lookup laid out step by step for support, from the
+// request being received to the response being sent.
+type Timeline struct {
+	TraceID    string          `json:"trace_id"`
+	CEP        string          `json:"cep"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Status     int             `json:"status"`
+	DurationMs float64         `json:"duration_ms"`
+	Steps      []TimelineEntry `json:"steps"`
+}
+
+type TimelineEntry struct {
+	AtMs       float64 `json:"at_ms"`
+	Step       string  `json:"step"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Detail     string  `json:"detail,omitempty"`
+}
+
+func NewTimeline(l Lookup) Timeline {
+	t := Timeline{
+		TraceID:    l.TraceID,
+		CEP:        l.CEP,
+		ReceivedAt: l.Time,
+		Status:     l.Status,
+		DurationMs: l.DurationMs,
+	}
+
+	route := l.Route
+	if route == "" {
+		route = "/temperature"
+	}
+	t.Steps = append(t.Steps, TimelineEntry{Step: "received", Detail: fmt.Sprintf("GET %s cep=%s", route, l.CEP)})
+	for _, e := range l.Events {
+		t.Steps = append(t.Steps, TimelineEntry{AtMs: e.OffsetMs, Step: e.Name, DurationMs: e.DurationMs, Detail: e.Detail})
+	}
+	t.Steps = append(t.Steps, TimelineEntry{AtMs: l.DurationMs, Step: "responded", Detail: fmt.Sprintf("%d %s", l.Status, http.StatusText(l.Status))})
+	return t
+}
+
+// String renders the timeline as plain text, one step per line.
+func (t Timeline) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "trace %s, cep %s, received %s\n", t.TraceID, t.CEP, t.ReceivedAt.Format(time.RFC3339Nano))
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "%+10.1fms  %s", s.AtMs, s.Step)
+		if s.DurationMs > 0 {
+			fmt.Fprintf(&b, " (%.1fms)", s.DurationMs)
+		}
+		if s.Detail != "" {
+			fmt.Fprintf(&b, ": %s", s.Detail)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// TimelineHandler serves the timeline of the lookup stored under {trace_id}
+// as JSON, or as plain text with ?format=text.
+func TimelineHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ok, err := store.Get(chi.URLParam(r, "trace_id"))
+		if err != nil {
+			http.Error(w, "failed to read history", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "lookup not found"})
+			return
+		}
+
+		timeline := NewTimeline(l)
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, timeline)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(timeline)
+	}
+}
@@ -0,0 +1,103 @@
+package idempotency
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileStore persists records to a JSON lines file, so keys survive restarts,
+// and answers lookups from memory. Every change appends a line; the file is
+// compacted to the unexpired records each time it is opened.
+type FileStore struct {
+	*MemoryStore
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileStore opens (or creates) the idempotency file at path.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore()}
+
+	if err := s.load(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read idempotency keys: %w", err)
+	}
+	if err := s.compact(path); err != nil {
+		return nil, fmt.Errorf("failed to compact idempotency keys: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open idempotency keys: %w", err)
+	}
+	s.file = f
+	return s, nil
+}
+
+// load reads every record in path; later lines replace earlier ones for the
+// same key, and lines that cannot be decoded are skipped.
+func (s *FileStore) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := clk.Now()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Key == "" {
+			continue
+		}
+		if now.Before(r.Expires) {
+			s.records[r.Key] = r
+		} else {
+			delete(s.records, r.Key)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites path with one line per loaded record.
+func (s *FileStore) compact(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, r := range s.records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Put(r Record) error {
+	s.MemoryStore.Put(r)
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
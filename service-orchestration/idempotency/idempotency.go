@@ -0,0 +1,281 @@
+// Package idempotency lets callers retry a mutating request with an
+// Idempotency-Key header and get the original response back instead of having
+// the request processed again. Responses are kept in a Store, which can be
+// persisted so retries are honoured across restarts.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/batch"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	DefaultTTL = 24 * time.Hour
+
+	maxKeyLength = 255
+)
+
+var clk clock.Clock = clock.Real{}
+
+// SetClock replaces the clock records are timestamped and expired with, in
+// the middleware and every Store.
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Record is the stored outcome of the first request made with a key, or,
+// while that request runs, a pending record holding the key.
+type Record struct {
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	Route       string    `json:"route"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        string    `json:"body"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+
+	// Replays counts the retries answered from this record.
+	Replays    int        `json:"replays"`
+	LastReplay *time.Time `json:"last_replay,omitempty"`
+}
+
+// Pending reports whether the request of the record is still running, or was
+// cut short by a restart before its lease expired.
+func (r Record) Pending() bool {
+	return r.Status == 0
+}
+
+type Store interface {
+	Get(key string) (Record, bool, error)
+	Put(r Record) error
+}
+
+// MemoryStore keeps records in memory until they expire.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(key string) (Record, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.records[key]
+	if !ok || !clk.Now().Before(r.Expires) {
+		return Record{}, false, nil
+	}
+	return r, true, nil
+}
+
+func (s *MemoryStore) Put(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clk.Now()
+	for key, old := range s.records {
+		if !now.Before(old.Expires) {
+			delete(s.records, key)
+		}
+	}
+	s.records[r.Key] = r
+	return nil
+}
+
+// Middleware answers a request carrying an Idempotency-Key already seen within
+// ttl with the stored response, marked with Idempotent-Replayed. Reusing a key
+// for a different request is rejected with 422, and a retry that arrives while
+// the first request is still running with 409. The running request holds its
+// key with a pending record in store, so retries are rejected across restarts
+// too; the pending record of a request cut short by a restart expires after
+// lease, which should cover the longest the request can run. Server errors
+// and responses of requests whose context ended, such as by a route timeout,
+// are not stored, so the request can be retried with the same key.
+func Middleware(store Store, ttl, lease time.Duration) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "idempotency key too long", Code: "invalid_idempotency_key"})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "failed to read request body"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			hash := requestHash(r, body)
+			span := trace.SpanFromContext(r.Context())
+
+			now := clk.Now().UTC()
+			mu.Lock()
+			record, ok, err := store.Get(key)
+			if err == nil && !ok {
+				err = store.Put(Record{Key: key, RequestHash: hash, Route: serve.RoutePath(r.Context(), r.URL.Path), Created: now, Expires: now.Add(lease)})
+			}
+			mu.Unlock()
+
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "failed to read idempotency key"})
+				return
+			}
+			if ok && record.Pending() {
+				writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "a request with this idempotency key is in progress", Code: "idempotency_key_in_use"})
+				return
+			}
+			if ok {
+				if record.RequestHash != hash {
+					writeJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Message: "idempotency key was used for a different request", Code: "idempotency_key_reused"})
+					return
+				}
+				span.SetAttributes(attribute.Bool("idempotency.replayed", true))
+				replay(w, store, record)
+				return
+			}
+
+			span.SetAttributes(attribute.Bool("idempotency.replayed", false))
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			result := Record{
+				Key:         key,
+				RequestHash: hash,
+				Route:       serve.RoutePath(r.Context(), r.URL.Path),
+				Status:      recordedStatus(rw),
+				ContentType: rw.Header().Get("Content-Type"),
+				Body:        rw.body.String(),
+				Created:     now,
+				Expires:     clk.Now().UTC().Add(ttl),
+			}
+			if result.Status == 0 || result.Status >= http.StatusInternalServerError || r.Context().Err() != nil {
+				// Frees the key for a retry
+				result = Record{Key: key, Expires: now}
+			}
+			mu.Lock()
+			store.Put(result)
+			mu.Unlock()
+		})
+	}
+}
+
+// recordedStatus is the status a response is stored with. A streamed batch
+// sends its status line before any item runs, so its outcome is taken from
+// the summary on its last line instead: 200 when every item succeeded and 207
+// otherwise, as a buffered batch answers. Zero marks a stream cut short
+// before its summary, which is not stored.
+func recordedStatus(rw *recordingWriter) int {
+	if !strings.HasPrefix(rw.Header().Get("Content-Type"), batch.ContentTypeNDJSON) {
+		return rw.status
+	}
+	lines := strings.Split(strings.TrimSpace(rw.body.String()), "\n")
+	var summary batch.Summary
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil || summary.Mode == "" {
+		return 0
+	}
+	if summary.Failed > 0 {
+		return http.StatusMultiStatus
+	}
+	return rw.status
+}
+
+func replay(w http.ResponseWriter, store Store, record Record) {
+	now := clk.Now().UTC()
+	record.Replays++
+	record.LastReplay = &now
+	store.Put(record)
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(record.Status)
+	io.WriteString(w, record.Body)
+}
+
+// Handler reports the record stored under {key}, for auditing retries.
+func Handler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, ok, err := store.Get(chi.URLParam(r, "key"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "failed to read idempotency key"})
+			return
+		}
+		if !ok {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "idempotency key not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, record)
+	}
+}
+
+// requestHash identifies a request by method, route, query and body, so a key
+// cannot be replayed for a different request.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+serve.RoutePath(r.Context(), r.URL.Path)+"\n"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streamed responses are still flushed.
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,251 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/batch"
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+)
+
+func countingHandler(calls *atomic.Int32, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/temperature/batch", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		handlerCode  int
+		first        [2]string
+		retry        [2]string
+		wantCalls    int32
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{"replays with the same key", http.StatusOK, [2]string{"k1", `{"ceps":["01001000"]}`}, [2]string{"k1", `{"ceps":["01001000"]}`}, 1, http.StatusOK, true},
+		{"replays client errors", http.StatusBadRequest, [2]string{"k1", `{}`}, [2]string{"k1", `{}`}, 1, http.StatusBadRequest, true},
+		{"runs server errors again", http.StatusBadGateway, [2]string{"k1", `{}`}, [2]string{"k1", `{}`}, 2, http.StatusBadGateway, false},
+		{"rejects a reused key", http.StatusOK, [2]string{"k1", `{"ceps":["01001000"]}`}, [2]string{"k1", `{"ceps":["20040020"]}`}, 1, http.StatusUnprocessableEntity, false},
+		{"runs without a key", http.StatusOK, [2]string{"", `{}`}, [2]string{"", `{}`}, 2, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			h := Middleware(NewMemoryStore(), time.Hour, time.Minute)(countingHandler(&calls, tt.handlerCode))
+
+			first := post(h, tt.first[0], tt.first[1])
+			retry := post(h, tt.retry[0], tt.retry[1])
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", got, tt.wantCalls)
+			}
+			if retry.Code != tt.wantStatus {
+				t.Errorf("retry status = %d, want %d", retry.Code, tt.wantStatus)
+			}
+			if got := retry.Header().Get(HeaderReplayed) == "true"; got != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", got, tt.wantReplayed)
+			}
+			if tt.wantReplayed && retry.Body.String() != first.Body.String() {
+				t.Errorf("replayed body = %q, want %q", retry.Body, first.Body)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsConcurrentRetry(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Middleware(NewMemoryStore(), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		post(h, "k1", `{}`)
+		close(done)
+	}()
+	<-started
+
+	if rec := post(h, "k1", `{}`); rec.Code != http.StatusConflict {
+		t.Errorf("concurrent retry status = %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestMiddlewareKeyExpires(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(fake)
+	t.Cleanup(func() { SetClock(clock.Real{}) })
+
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(), time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK))
+	post(h, "k1", `{}`)
+
+	fake.Advance(time.Hour - time.Second)
+	if rec := post(h, "k1", `{}`); rec.Header().Get(HeaderReplayed) != "true" || calls.Load() != 1 {
+		t.Errorf("retry within the TTL ran the handler again (calls = %d)", calls.Load())
+	}
+
+	fake.Advance(time.Second)
+	if rec := post(h, "k1", `{}`); rec.Header().Get(HeaderReplayed) != "" || calls.Load() != 2 {
+		t.Errorf("retry after the TTL was replayed (calls = %d)", calls.Load())
+	}
+}
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	var calls atomic.Int32
+	post(Middleware(store, time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK)), "k1", `{}`)
+	store.Put(Record{Key: "expired", Expires: time.Now().Add(-time.Minute)})
+	store.Close()
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore after restart: %v", err)
+	}
+	defer reopened.Close()
+
+	rec := post(Middleware(reopened, time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK)), "k1", `{}`)
+	if calls.Load() != 1 || rec.Header().Get(HeaderReplayed) != "true" {
+		t.Errorf("retry after restart ran the handler again (calls = %d)", calls.Load())
+	}
+	if _, ok, _ := reopened.Get("expired"); ok {
+		t.Error("expired record was loaded")
+	}
+	if r, _, _ := reopened.Get("k1"); r.Replays != 1 {
+		t.Errorf("replays = %d, want 1", r.Replays)
+	}
+}
+
+func TestSQLStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orchestration.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("database.Open: %v", err)
+	}
+	var calls atomic.Int32
+	store := NewSQLStore(db)
+	post(Middleware(store, time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK)), "k1", `{}`)
+	store.Put(Record{Key: "expired", Expires: time.Now().Add(-time.Minute)})
+	db.Close()
+
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatalf("database.Open after restart: %v", err)
+	}
+	defer db.Close()
+	reopened := NewSQLStore(db)
+
+	rec := post(Middleware(reopened, time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK)), "k1", `{}`)
+	if calls.Load() != 1 || rec.Header().Get(HeaderReplayed) != "true" || rec.Body.String() != `{}` {
+		t.Errorf("retry after restart ran the handler again (calls = %d)", calls.Load())
+	}
+	if _, ok, _ := reopened.Get("expired"); ok {
+		t.Error("expired record was returned")
+	}
+	if r, _, _ := reopened.Get("k1"); r.Replays != 1 || r.LastReplay == nil {
+		t.Errorf("record = %+v, want 1 replay", r)
+	}
+}
+
+func TestMiddlewareDoesNotStoreTimedOutRequests(t *testing.T) {
+	var calls atomic.Int32
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-r.Context().Done()
+		// Items failed by the deadline, as a batch reports them
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"failed":1}`))
+	})
+	h := middleware.Timeout(10 * time.Millisecond)(Middleware(NewMemoryStore(), time.Hour, time.Minute)(slow))
+
+	if rec := post(h, "k1", `{}`); rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", rec.Code)
+	}
+	if rec := post(h, "k1", `{}`); rec.Header().Get(HeaderReplayed) == "true" || calls.Load() != 2 {
+		t.Errorf("retry after a timeout was replayed (calls = %d), want it run again", calls.Load())
+	}
+}
+
+func TestMiddlewareStoresStreamsBySummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStored bool
+		wantStatus int
+	}{
+		{"every item succeeded", `{"cep":"01001000","status":200}` + "\n" + `{"mode":"best_effort","items":1,"failed":0}` + "\n", true, http.StatusOK},
+		{"every item failed", `{"cep":"01001000","status":502}` + "\n" + `{"mode":"best_effort","items":1,"failed":1}` + "\n", true, http.StatusMultiStatus},
+		{"cut before the summary", `{"cep":"01001000","status":200}` + "\n", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			h := Middleware(store, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", batch.ContentTypeNDJSON)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			post(h, "k1", `{}`)
+
+			record, ok, _ := store.Get("k1")
+			if ok != tt.wantStored || (ok && record.Status != tt.wantStatus) {
+				t.Errorf("record = %+v, stored %v, want stored %v with status %d", record, ok, tt.wantStored, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPendingKeySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	// The process stops while the first request holds the key
+	store.Put(Record{Key: "k1", Created: time.Now(), Expires: time.Now().Add(time.Minute)})
+	store.Close()
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore after restart: %v", err)
+	}
+	defer reopened.Close()
+
+	var calls atomic.Int32
+	if rec := post(Middleware(reopened, time.Hour, time.Minute)(countingHandler(&calls, http.StatusOK)), "k1", `{}`); rec.Code != http.StatusConflict || calls.Load() != 0 {
+		t.Errorf("retry after restart = %d (calls = %d), want 409 while the key is pending", rec.Code, calls.Load())
+	}
+}
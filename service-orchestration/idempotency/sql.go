@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLStore keeps records in the idempotency_records table of a database opened
+// with database.Open. Expired records are deleted as new ones are stored.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Get(key string) (Record, bool, error) {
+	var r Record
+	var created, expires int64
+	var lastReplay sql.NullInt64
+	err := s.db.QueryRow(`SELECT key, request_hash, route, status, content_type, body, created, expires, replays, last_replay
+FROM idempotency_records WHERE key = ? AND expires > ?`, key, clk.Now().UnixNano()).
+		Scan(&r.Key, &r.RequestHash, &r.Route, &r.Status, &r.ContentType, &r.Body, &created, &expires, &r.Replays, &lastReplay)
+	if err == sql.ErrNoRows {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	r.Created = time.Unix(0, created).UTC()
+	r.Expires = time.Unix(0, expires).UTC()
+	if lastReplay.Valid {
+		t := time.Unix(0, lastReplay.Int64).UTC()
+		r.LastReplay = &t
+	}
+	return r, true, nil
+}
+
+func (s *SQLStore) Put(r Record) error {
+	if _, err := s.db.Exec(`DELETE FROM idempotency_records WHERE expires <= ?`, clk.Now().UnixNano()); err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	var lastReplay sql.NullInt64
+	if r.LastReplay != nil {
+		lastReplay = sql.NullInt64{Int64: r.LastReplay.UnixNano(), Valid: true}
+	}
+	_, err := s.db.Exec(`INSERT INTO idempotency_records (key, request_hash, route, status, content_type, body, created, expires, replays, last_replay)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (key) DO UPDATE SET
+	request_hash = excluded.request_hash,
+	route = excluded.route,
+	status = excluded.status,
+	content_type = excluded.content_type,
+	body = excluded.body,
+	created = excluded.created,
+	expires = excluded.expires,
+	replays = excluded.replays,
+	last_replay = excluded.last_replay`,
+		r.Key, r.RequestHash, r.Route, r.Status, r.ContentType, r.Body, r.Created.UnixNano(), r.Expires.UnixNano(), r.Replays, lastReplay)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Strategy chooses which of the available keys serves the next request.
+type Strategy string
+
+const (
+	// RoundRobin uses the keys in turn.
+	RoundRobin Strategy = "round_robin"
+	// LeastThrottled prefers the key whose last quota error is the oldest,
+	// keys that were never throttled first.
+	LeastThrottled Strategy = "least_throttled"
+)
+
+const DefaultQuarantine = time.Minute
+
+func ParseStrategy(value string) (Strategy, error) {
+	switch Strategy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", RoundRobin:
+		return RoundRobin, nil
+	case LeastThrottled:
+		return LeastThrottled, nil
+	}
+	return "", fmt.Errorf("invalid key strategy %q: expected round_robin or least_throttled", value)
+}
+
+var (
+	meter = otel.Meter("service-orchestration")
+
+	keyCalls, _ = meter.Int64Counter(
+		"weather.api_key.calls",
+		metric.WithDescription("Number of weather API calls per key and result"),
+	)
+	keyQuarantines, _ = meter.Int64Counter(
+		"weather.api_key.quarantines",
+		metric.WithDescription("Number of times a weather API key was quarantined after a quota error"),
+	)
+)
+
+type key struct {
+	id            string
+	value         string
+	quarantinedTo time.Time
+	lastThrottled time.Time
+}
+
+// Key is a key handed out by Pool.Pick; ID identifies it in metrics by its
+// position in the configured list, without exposing the secret.
+type Key struct {
+	ID    string
+	Value string
+}
+
+// Pool spreads requests across several API keys and sets aside, for the
+// quarantine period, keys that hit their quota.
+type Pool struct {
+	strategy   Strategy
+	quarantine time.Duration
+	clock      clock.Clock
+
+	mu   sync.Mutex
+	keys []*key
+	next int
+}
+
+func New(values []string, strategy Strategy, quarantine time.Duration) *Pool {
+	p := &Pool{strategy: strategy, quarantine: quarantine, clock: clock.Real{}}
+	for i, v := range values {
+		p.keys = append(p.keys, &key{id: fmt.Sprintf("key-%d", i+1), value: v})
+	}
+	return p
+}
+
+// SetClock replaces the clock used to expire quarantines.
+func (p *Pool) SetClock(clk clock.Clock) {
+	p.clock = clk
+}
+
+func (p *Pool) Len() int {
+	return len(p.keys)
+}
+
+// Pick returns the next key to use. When every key is quarantined it returns
+// domain.ErrQuotaExceeded.
+func (p *Pool) Pick() (Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	var chosen *key
+	for i := range p.keys {
+		k := p.keys[(p.next+i)%len(p.keys)]
+		if now.Before(k.quarantinedTo) {
+			continue
+		}
+		if chosen == nil {
+			chosen = k
+			if p.strategy == RoundRobin {
+				break
+			}
+			continue
+		}
+		// Keys never throttled have a zero lastThrottled and win; ties keep
+		// the round-robin order
+		if k.lastThrottled.Before(chosen.lastThrottled) {
+			chosen = k
+		}
+	}
+	if chosen == nil {
+		return Key{}, domain.ErrQuotaExceeded
+	}
+
+	for i, k := range p.keys {
+		if k == chosen {
+			p.next = (i + 1) % len(p.keys)
+		}
+	}
+	return Key{ID: chosen.id, Value: chosen.value}, nil
+}
+
+// Report records the outcome of a call made with k. Quota errors quarantine
+// the key.
+func (p *Pool) Report(ctx context.Context, k Key, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		result = "quota"
+	case err != nil:
+		result = "error"
+	}
+	keyCalls.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("key", k.ID),
+		attribute.String("result", result),
+	))
+	if result != "quota" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pk := range p.keys {
+		if pk.id == k.ID {
+			now := p.clock.Now()
+			pk.quarantinedTo = now.Add(p.quarantine)
+			pk.lastThrottled = now
+		}
+	}
+	keyQuarantines.Add(ctx, 1, metric.WithAttributes(attribute.String("key", k.ID)))
+}
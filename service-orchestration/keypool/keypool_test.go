@@ -0,0 +1,113 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func pick(t *testing.T, p *Pool) string {
+	t.Helper()
+	k, err := p.Pick()
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	return k.ID
+}
+
+func TestRoundRobin(t *testing.T) {
+	p := New([]string{"a", "b", "c"}, RoundRobin, time.Minute)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, pick(t, p))
+	}
+	want := []string{"key-1", "key-2", "key-3", "key-1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("picks = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC))
+	p := New([]string{"a", "b"}, RoundRobin, time.Minute)
+	p.SetClock(clk)
+
+	k, _ := p.Pick()
+	p.Report(context.Background(), k, domain.NewProviderError("weatherapi", 429, nil))
+
+	for i := 0; i < 3; i++ {
+		if id := pick(t, p); id != "key-2" {
+			t.Errorf("pick while key-1 is quarantined = %s, want key-2", id)
+		}
+	}
+
+	k, _ = p.Pick()
+	p.Report(context.Background(), k, domain.NewProviderError("weatherapi", 403, nil))
+	if _, err := p.Pick(); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("Pick() with every key quarantined error = %v, want quota exceeded", err)
+	}
+
+	clk.Advance(time.Minute)
+	if _, err := p.Pick(); err != nil {
+		t.Errorf("Pick() after the quarantine error = %v", err)
+	}
+}
+
+func TestOtherErrorsDoNotQuarantine(t *testing.T) {
+	p := New([]string{"a"}, RoundRobin, time.Minute)
+
+	k, _ := p.Pick()
+	p.Report(context.Background(), k, domain.NewProviderError("weatherapi", 500, nil))
+	if _, err := p.Pick(); err != nil {
+		t.Errorf("Pick() after a server error = %v, want the key still available", err)
+	}
+}
+
+func TestLeastThrottled(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC))
+	p := New([]string{"a", "b", "c"}, LeastThrottled, time.Second)
+	p.SetClock(clk)
+
+	quota := domain.NewProviderError("weatherapi", 429, nil)
+	p.Report(context.Background(), Key{ID: "key-2"}, quota)
+	clk.Advance(time.Second)
+	p.Report(context.Background(), Key{ID: "key-1"}, quota)
+	clk.Advance(time.Second)
+
+	if id := pick(t, p); id != "key-3" {
+		t.Errorf("first pick = %s, want key-3 which was never throttled", id)
+	}
+	p.Report(context.Background(), Key{ID: "key-3"}, quota)
+	clk.Advance(time.Second)
+
+	if id := pick(t, p); id != "key-2" {
+		t.Errorf("second pick = %s, want key-2 which was throttled longest ago", id)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Strategy
+		wantErr bool
+	}{
+		{"", RoundRobin, false},
+		{"round_robin", RoundRobin, false},
+		{" Least_Throttled ", LeastThrottled, false},
+		{"random", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStrategy(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStrategy(%q) = %q, %v, want %q (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/open-telemetry/pkg/buildinfo"
+	"github.com/fhsmendes/open-telemetry/pkg/coldstart"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+)
+
+// Readiness is the public answer of /readyz. It carries only what the platform
+// needs to route traffic; everything else stays on the admin-only /statusz.
+type Readiness struct {
+	Ready    bool  `json:"ready"`
+	Draining bool  `json:"draining"`
+	InFlight int64 `json:"in_flight"`
+}
+
+type Status struct {
+	Ready     bool                             `json:"ready"`
+	Draining  bool                             `json:"draining"`
+	InFlight  int64                            `json:"in_flight"`
+	Drained   bool                             `json:"drained"`
+	Providers map[string]models.ProviderHealth `json:"providers,omitempty"`
+	Datasets  map[string]string                `json:"datasets,omitempty"`
+	Tasks     map[string]supervisor.TaskStatus `json:"tasks,omitempty"`
+	Jobs      map[string]models.JobStatus      `json:"jobs,omitempty"`
+	Collector *telemetry.CollectorStatus       `json:"collector,omitempty"`
+	Init      *coldstart.Report                `json:"init,omitempty"`
+}
+
+// Manager tracks readiness and in-flight requests so the platform can drain an
+// instance before terminating it.
+type Manager struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+
+	providers func() map[string]models.ProviderHealth
+	datasets  map[string]func() string
+	tasks     func() map[string]supervisor.TaskStatus
+	jobs      func() map[string]models.JobStatus
+	collector func() telemetry.CollectorStatus
+	init      func() coldstart.Report
+	shutdown  []func(ctx context.Context) error
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// SetProviderStatus registers the source of the provider health reported by
+// /statusz.
+func (m *Manager) SetProviderStatus(fn func() map[string]models.ProviderHealth) {
+	m.providers = fn
+}
+
+// SetDatasetVersion registers a dataset whose current version is reported by
+// /statusz under name.
+func (m *Manager) SetDatasetVersion(name string, version func() string) {
+	if m.datasets == nil {
+		m.datasets = make(map[string]func() string)
+	}
+	m.datasets[name] = version
+}
+
+// SetTaskStatus registers the source of the background task state reported by
+// /statusz.
+func (m *Manager) SetTaskStatus(fn func() map[string]supervisor.TaskStatus) {
+	m.tasks = fn
+}
+
+// SetJobStatus registers the source of the scheduled job state reported by
+// /statusz.
+func (m *Manager) SetJobStatus(fn func() map[string]models.JobStatus) {
+	m.jobs = fn
+}
+
+// SetCollectorStatus registers the source of the collector endpoint reported
+// by /statusz.
+func (m *Manager) SetCollectorStatus(fn func() telemetry.CollectorStatus) {
+	m.collector = fn
+}
+
+// SetInitReport registers the source of the start-up timings reported by
+// /statusz.
+func (m *Manager) SetInitReport(fn func() coldstart.Report) {
+	m.init = fn
+}
+
+// OnShutdown registers fn to be called by Shutdown. Hooks run in reverse
+// registration order, like deferred calls.
+func (m *Manager) OnShutdown(fn func(ctx context.Context) error) {
+	m.shutdown = append(m.shutdown, fn)
+}
+
+// Shutdown runs every registered shutdown hook and joins their errors.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var errs []error
+	for i := len(m.shutdown) - 1; i >= 0; i-- {
+		errs = append(errs, m.shutdown[i](ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) StartDrain() {
+	m.draining.Store(true)
+}
+
+func (m *Manager) Status() Status {
+	draining := m.draining.Load()
+	inFlight := m.inFlight.Load()
+	status := Status{
+		Ready:    !draining,
+		Draining: draining,
+		InFlight: inFlight,
+		Drained:  draining && inFlight == 0,
+	}
+	if m.providers != nil {
+		status.Providers = m.providers()
+	}
+	if len(m.datasets) > 0 {
+		status.Datasets = make(map[string]string, len(m.datasets))
+		for name, version := range m.datasets {
+			status.Datasets[name] = version()
+		}
+	}
+	if m.tasks != nil {
+		status.Tasks = m.tasks()
+	}
+	if m.jobs != nil {
+		status.Jobs = m.jobs()
+	}
+	if m.collector != nil {
+		collector := m.collector()
+		status.Collector = &collector
+	}
+	if m.init != nil {
+		report := m.init()
+		status.Init = &report
+	}
+	return status
+}
+
+// Track counts the requests handled by next as in flight.
+func (m *Manager) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ReadyHandler answers /readyz with the Readiness of the instance, 503 while it
+// drains.
+func (m *Manager) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	draining := m.draining.Load()
+	readiness := Readiness{
+		Ready:    !draining,
+		Draining: draining,
+		InFlight: m.inFlight.Load(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
+
+func (m *Manager) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(struct {
+		Status
+		Build buildinfo.Info `json:"build"`
+	}{m.Status(), buildinfo.Get()})
+}
+
+// DrainHandler flips readiness to false on POST and reports the drain progress.
+// It answers 202 while requests are still in flight and 200 once drained.
+func (m *Manager) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		m.StartDrain()
+	}
+
+	status := m.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if status.Drained {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/open-telemetry/pkg/coldstart"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+)
+
+func TestManagerDrain(t *testing.T) {
+	m := NewManager()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := m.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/temperature", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	m.DrainHandler(rec, httptest.NewRequest(http.MethodPost, "/drain", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("drain with in-flight request status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if status := m.Status(); status.Ready || status.InFlight != 1 || status.Drained {
+		t.Errorf("status while draining = %+v, want not ready with 1 in flight", status)
+	}
+
+	rec = httptest.NewRecorder()
+	m.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz while draining status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	close(release)
+	<-done
+
+	rec = httptest.NewRecorder()
+	m.DrainHandler(rec, httptest.NewRequest(http.MethodGet, "/drain", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("drain after requests finished status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if status := m.Status(); !status.Drained || status.InFlight != 0 {
+		t.Errorf("status after drain = %+v, want drained", status)
+	}
+}
+
+func TestReadyHandlerHidesStatusDetails(t *testing.T) {
+	m := NewManager()
+	m.SetProviderStatus(func() map[string]models.ProviderHealth {
+		return map[string]models.ProviderHealth{"weatherapi": {LastError: "dial tcp 10.0.0.7:443: connection refused"}}
+	})
+	m.SetTaskStatus(func() map[string]supervisor.TaskStatus {
+		return map[string]supervisor.TaskStatus{"provider-prober": {LastError: "panic in provider-prober: boom\ngoroutine 7 [running]:"}}
+	})
+	m.SetJobStatus(func() map[string]models.JobStatus {
+		return map[string]models.JobStatus{"history-purge": {Schedule: "@hourly"}}
+	})
+	m.SetCollectorStatus(func() telemetry.CollectorStatus {
+		return telemetry.CollectorStatus{Active: "collector:4317"}
+	})
+	m.SetInitReport(func() coldstart.Report { return coldstart.Report{ReadyMs: 120} })
+	m.SetDatasetVersion("cep", func() string { return "v1" })
+
+	rec := httptest.NewRecorder()
+	m.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("readyz status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode readyz: %v", err)
+	}
+	want := map[string]any{"ready": true, "draining": false, "in_flight": float64(0)}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("readyz body = %v, want only %v", body, want)
+	}
+}
+
+func TestManagerDatasetVersions(t *testing.T) {
+	m := NewManager()
+	if status := m.Status(); status.Datasets != nil {
+		t.Errorf("datasets without registrations = %v, want nil", status.Datasets)
+	}
+
+	version := "v1"
+	m.SetDatasetVersion("cep", func() string { return version })
+	if got := m.Status().Datasets["cep"]; got != "v1" {
+		t.Errorf("cep dataset version = %q, want v1", got)
+	}
+
+	version = "v2"
+	if got := m.Status().Datasets["cep"]; got != "v2" {
+		t.Errorf("cep dataset version after refresh = %q, want v2", got)
+	}
+}
+
+func TestManagerCollectorStatus(t *testing.T) {
+	m := NewManager()
+	if status := m.Status(); status.Collector != nil {
+		t.Errorf("collector without registration = %+v, want nil", status.Collector)
+	}
+
+	m.SetCollectorStatus(func() telemetry.CollectorStatus {
+		return telemetry.CollectorStatus{Active: "collector-b:4317", Endpoints: []string{"collector:4317", "collector-b:4317"}, Switches: 1}
+	})
+	if got := m.Status().Collector; got == nil || got.Active != "collector-b:4317" {
+		t.Errorf("collector = %+v, want collector-b:4317 active", got)
+	}
+}
+
+func TestManagerInitReport(t *testing.T) {
+	m := NewManager()
+	if status := m.Status(); status.Init != nil {
+		t.Errorf("init without registration = %+v, want nil", status.Init)
+	}
+
+	m.SetInitReport(func() coldstart.Report {
+		return coldstart.Report{ReadyMs: 120, Stages: []coldstart.StageReport{{Name: "config", DurationMs: 2}}}
+	})
+	if got := m.Status().Init; got == nil || got.ReadyMs != 120 || len(got.Stages) != 1 {
+		t.Errorf("init = %+v, want the registered report", got)
+	}
+}
+
+func TestManagerShutdownHooks(t *testing.T) {
+	m := NewManager()
+
+	var order []string
+	m.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "server")
+		return nil
+	})
+	m.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "tasks")
+		return errors.New("prober still running")
+	})
+
+	if err := m.Shutdown(context.Background()); err == nil {
+		t.Error("Shutdown() should return the hook error")
+	}
+	if len(order) != 2 || order[0] != "tasks" || order[1] != "server" {
+		t.Errorf("shutdown order = %v, want [tasks server]", order)
+	}
+}
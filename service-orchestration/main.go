@@ -2,26 +2,79 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/fhsmendes/deploy-cloud-run/adaptive"
+	"github.com/fhsmendes/deploy-cloud-run/analytics"
+	"github.com/fhsmendes/deploy-cloud-run/batch"
+	"github.com/fhsmendes/deploy-cloud-run/budget"
+	"github.com/fhsmendes/deploy-cloud-run/cache"
+	"github.com/fhsmendes/deploy-cloud-run/cepdb"
+	"github.com/fhsmendes/deploy-cloud-run/cityname"
+	"github.com/fhsmendes/deploy-cloud-run/config"
+	"github.com/fhsmendes/deploy-cloud-run/database"
 	"github.com/fhsmendes/deploy-cloud-run/handler"
+	"github.com/fhsmendes/deploy-cloud-run/health"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/idempotency"
+	"github.com/fhsmendes/deploy-cloud-run/keypool"
+	"github.com/fhsmendes/deploy-cloud-run/lifecycle"
+	"github.com/fhsmendes/deploy-cloud-run/metricview"
+	"github.com/fhsmendes/deploy-cloud-run/policy"
+	"github.com/fhsmendes/deploy-cloud-run/queue"
+	"github.com/fhsmendes/deploy-cloud-run/routing"
+	"github.com/fhsmendes/deploy-cloud-run/scheduler"
+	"github.com/fhsmendes/deploy-cloud-run/trend"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"github.com/fhsmendes/deploy-cloud-run/webhook"
+	"github.com/fhsmendes/deploy-cloud-run/workpool"
+	"github.com/fhsmendes/open-telemetry/pkg/attrfilter"
+	"github.com/fhsmendes/open-telemetry/pkg/audit"
+	"github.com/fhsmendes/open-telemetry/pkg/buildinfo"
+	"github.com/fhsmendes/open-telemetry/pkg/cassette"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/coldstart"
+	"github.com/fhsmendes/open-telemetry/pkg/configdump"
+	"github.com/fhsmendes/open-telemetry/pkg/deprecation"
+	"github.com/fhsmendes/open-telemetry/pkg/geo"
+	"github.com/fhsmendes/open-telemetry/pkg/logging"
+	"github.com/fhsmendes/open-telemetry/pkg/maintenance"
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"github.com/fhsmendes/open-telemetry/pkg/spillover"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log/global"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func main() {
@@ -30,38 +83,415 @@ func main() {
 		log.Println("No .env file found or error loading it, using environment variables")
 	}
 
+	// Kept so /admin/config can tell values loaded from .env apart from the
+	// ones set in the environment
+	envFile, _ := godotenv.Read()
+
+	check := flag.Bool("check", false, "validate config, secrets and the collector connection, then exit")
+	probe := flag.Bool("probe", false, "with --check, also call the upstream providers")
+	flag.Parse()
+	if *check {
+		os.Exit(runCheck(*probe))
+	}
+
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	shutdown, err := initProvider("service-orchestration", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	tracingEnabled := os.Getenv("TRACING_ENABLED") != "false"
+	if !tracingEnabled {
+		log.Println("Tracing disabled, using no-op tracer provider")
+	}
+
+	// Stages of the start timed for the cold start metrics, recorded once the
+	// telemetry providers are up
+	boot := coldstart.New()
+
+	var cfg config.Config
+	err := boot.Stage("config", func() (err error) {
+		cfg, err = config.Load()
+		return err
+	})
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	var shutdown func(context.Context) []telemetry.ShutdownResult
+	var collector *telemetry.Failover
+	err = boot.Stage("telemetry", func() (err error) {
+		shutdown, collector, err = initProvider(utils.ServiceName, tracingEnabled, cfg.Metrics)
+		return err
+	})
 	if err != nil {
 		log.Fatalf("failed to initialize tracing provider: %v", err)
 	}
+	// Also routes the log package through slog, so every line is exported
+	slog.SetDefault(logging.New(utils.ServiceName, os.Stderr))
 	defer func() {
-		if err := shutdown(ctx); err != nil {
-			log.Fatalf("failed to shutdown tracing provider: %v", err)
+		// ctx is canceled by then; the flush is bounded by OTEL_SHUTDOWN_TIMEOUT
+		for _, result := range shutdown(context.WithoutCancel(ctx)) {
+			if result.Err != nil {
+				log.Printf("Failed to flush %s after %s: %v", result.Signal, result.Duration.Round(time.Millisecond), result.Err)
+				continue
+			}
+			log.Printf("Flushed %s in %s", result.Signal, result.Duration.Round(time.Millisecond))
 		}
 	}()
 
+	clk, rnd := clock.Real{}, clock.DefaultRand
+	handler.SetClock(clk)
+	idempotency.SetClock(clk)
+	handler.SetRand(rnd)
+	handler.SetUFPolicy(cfg.UFPolicy)
+	handler.SetAccessPolicy(cfg.AccessPolicy)
+	handler.SetFieldNaming(cfg.FieldNaming)
+	handler.SetTemperatureCacheTTL(cfg.WeatherCacheTTL, cfg.WeatherCacheJitter)
+	handler.SetWeatherAlertsCacheTTL(cfg.WeatherAlertsCacheTTL, cfg.WeatherCacheJitter)
+	cache.SetKeyHashSecret(cfg.CacheKeyHashSecret)
+	utils.SetUpstreams(cfg.Upstreams, clk)
+	utils.SetResolver(upstream.NewResolver(cfg.DNS))
+	if cfg.CassetteMode != cassette.ModeOff {
+		log.Printf("Upstream cassette in %s mode using %s", cfg.CassetteMode, cfg.CassetteDir)
+		utils.SetCassette(cfg.CassetteMode, cfg.CassetteDir)
+	}
+	if cfg.StubWeatherTemperature != nil {
+		log.Printf("Weather providers stubbed: every lookup answers %g°C", *cfg.StubWeatherTemperature)
+		handler.SetStubTemperature(cfg.StubWeatherTemperature)
+	}
+	if len(cfg.AdminAPIKeys) == 0 {
+		log.Println("ADMIN_API_KEYS is empty: /drain and /admin/* reject every request")
+	}
+
+	var db *sql.DB
+	if cfg.DBDriver == database.DriverSQLite {
+		db, err = database.Open(cfg.DatabasePath)
+		if err != nil {
+			log.Fatalf("failed to open database: %v", err)
+		}
+		defer db.Close()
+		log.Printf("Persisting history, idempotency keys, webhook deliveries and audit log to SQLite at %s", cfg.DatabasePath)
+	}
+
+	var auditLog *audit.Logger
+	if db != nil {
+		auditLog, err = audit.NewSQLLogger(db)
+	} else {
+		auditLog, err = audit.NewLogger(os.Getenv("AUDIT_LOG_PATH"))
+	}
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	var lookups history.Store = history.NewMemoryStore(1000)
+	if db != nil {
+		lookups = history.NewSQLStore(db)
+	} else if path := os.Getenv("HISTORY_PATH"); path != "" {
+		fileStore, err := history.NewFileStore(path, 1000)
+		if err != nil {
+			log.Fatalf("failed to open lookup history: %v", err)
+		}
+		defer fileStore.Close()
+		lookups = fileStore
+	}
+
+	var idempotencyKeys idempotency.Store = idempotency.NewMemoryStore()
+	if db != nil {
+		idempotencyKeys = idempotency.NewSQLStore(db)
+	} else if path := os.Getenv("IDEMPOTENCY_PATH"); path != "" {
+		fileStore, err := idempotency.NewFileStore(path)
+		if err != nil {
+			log.Fatalf("failed to open idempotency keys: %v", err)
+		}
+		defer fileStore.Close()
+		idempotencyKeys = fileStore
+	}
+
+	var webhookDeliveries webhook.Store = webhook.NewMemoryStore()
+	if db != nil {
+		webhookDeliveries = webhook.NewSQLStore(db)
+	} else if path := os.Getenv("WEBHOOK_PATH"); path != "" {
+		fileStore, err := webhook.NewFileStore(path)
+		if err != nil {
+			log.Fatalf("failed to open webhook deliveries: %v", err)
+		}
+		defer fileStore.Close()
+		webhookDeliveries = fileStore
+	}
+	webhooks := webhook.NewDispatcher(webhookDeliveries)
+	webhooks.SetClock(clk)
+
+	// Runs the background tasks, and the exports and cross-checks requests
+	// leave running
+	tasks := supervisor.New(ctx)
+
+	historyExport := history.NewExporter(lookups, cfg.HistoryExport, tasks)
+	historyExport.SetWebhooks(webhooks)
+
+	lookupStats := analytics.NewTracker(100)
+	handler.SetLookupTracker(lookupStats)
+	usage.SetPrices(cfg.ProviderCallPrices)
+	budget.Set(cfg.LatencyBudgets)
+	handler.SetStageTimeouts(cfg.StageTimeouts)
+	if len(cfg.WeatherAPIKeys) > 0 {
+		keys := keypool.New(cfg.WeatherAPIKeys, cfg.WeatherAPIKeyStrategy, cfg.WeatherAPIKeyQuarantine)
+		keys.SetClock(clk)
+		utils.SetWeatherAPIKeys(keys)
+	}
+	if cfg.AdaptiveTimeouts {
+		utils.SetAdaptiveTimeouts(func() *adaptive.Controller {
+			return adaptive.New(adaptive.DefaultWindow, cfg.AdaptiveTimeoutFactor, cfg.AdaptiveTimeoutMin, cfg.AdaptiveTimeoutMax)
+		})
+	}
+
+	handler.SetWeatherCrossCheck(cfg.WeatherCrossCheckPercent, cfg.WeatherCrossCheckThreshold, tasks)
+	weatherRouter := routing.New(cfg.WeatherProviders...)
+	weatherRouter.MinSamples = cfg.RoutingMinSamples
+	weatherRouter.ExplorePercent = cfg.RoutingExplorePercent
+	weatherRouter.Rand = rnd
+	handler.SetWeatherRouter(weatherRouter)
+	countryRouters := make(map[string]*routing.Router, len(cfg.WeatherProvidersByCountry))
+	for country, providers := range cfg.WeatherProvidersByCountry {
+		r := routing.New(providers...)
+		r.MinSamples = cfg.RoutingMinSamples
+		r.ExplorePercent = cfg.RoutingExplorePercent
+		r.Rand = rnd
+		countryRouters[country] = r
+	}
+	handler.SetCountryWeatherRouters(countryRouters)
+	handler.SetCityNormalizer(cityname.New(cfg.CityAliases, cfg.CityQueryDisambiguate))
+	handler.SetAllowClientCity(cfg.AllowClientCity)
+	handler.SetRejectImplausibleTemperatures(cfg.RejectImplausibleTemperatures)
+	if len(cfg.GeocodingProviders) > 0 {
+		var providers []geo.Provider
+		for _, name := range cfg.GeocodingProviders {
+			client, url := utils.UpstreamClient(name)
+			switch name {
+			case "open-meteo":
+				providers = append(providers, geo.OpenMeteo{Client: client, URL: url})
+			case "nominatim":
+				providers = append(providers, geo.Nominatim{Client: client, URL: url, UserAgent: utils.UserAgent()})
+			}
+		}
+		geocoder := geo.NewResolver(cfg.GeocodingCacheTTL, providers...)
+		geocoder.SetClock(clk)
+		handler.SetGeocoder(geocoder)
+		log.Printf("Geocoding cities without coordinates with %s", strings.Join(cfg.GeocodingProviders, ", "))
+	}
+
+	lm := lifecycle.NewManager()
+	lm.SetTaskStatus(tasks.Status)
+
+	jobs := scheduler.New()
+	lm.SetJobStatus(jobs.Status)
+	lm.SetInitReport(boot.Report)
+	if collector != nil {
+		lm.SetCollectorStatus(collector.Status)
+		tasks.Go("collector-failback", collector.Failback)
+	}
+	addJob := func(job scheduler.Job) {
+		if err := jobs.Add(job); err != nil {
+			log.Fatalf("failed to schedule job: %v", err)
+		}
+	}
+
+	if cfg.CEPDataset != "" {
+		var dataset *cepdb.Dataset
+		err := boot.Stage("cep-dataset", func() (err error) {
+			if cfg.CEPDataset == "embedded" {
+				dataset, err = cepdb.Embedded()
+			} else {
+				dataset, err = cepdb.LoadFile(cfg.CEPDataset)
+			}
+			return err
+		})
+		if err != nil {
+			log.Fatalf("failed to load CEP dataset: %v", err)
+		}
+		ceps := cepdb.NewStore(dataset)
+		if cfg.CEPDatasetURL != "" {
+			client, _ := utils.UpstreamClient(cepdb.Target)
+			addJob(scheduler.Job{
+				Name: "cep-dataset-refresh",
+				Spec: cfg.JobSpec("cep-dataset-refresh", cfg.CEPDatasetRefreshInterval),
+				Run: func(ctx context.Context) error {
+					return ceps.Refresh(ctx, client, cfg.CEPDatasetURL, cfg.CEPDatasetChecksumURL)
+				},
+			})
+		}
+		handler.SetOfflineCEPs(ceps)
+		lm.SetDatasetVersion("cep", ceps.Version)
+	}
+
+	if cfg.ProbeInterval > 0 {
+		prober := health.NewProber(5 * time.Second)
+		prober.Register("viacep", func(ctx context.Context) error {
+			_, err := utils.GetCityFromCEP(ctx, "01001000", trace.SpanFromContext(ctx))
+			return err
+		})
+		// Every probe is a billed weather API call, so it is opt-in, and
+		// never spends the quota a stubbed weather provider saves
+		if cfg.ProbeWeatherAPI && cfg.StubWeatherTemperature == nil {
+			prober.Register("weatherapi", func(ctx context.Context) error {
+				_, err := utils.GetTemperature(ctx, "São Paulo", trace.SpanFromContext(ctx))
+				return err
+			})
+		}
+		if err := prober.RegisterMetrics(); err != nil {
+			log.Fatalf("failed to start provider prober: %v", err)
+		}
+		addJob(scheduler.Job{
+			Name:       "provider-prober",
+			Spec:       cfg.JobSpec("provider-prober", cfg.ProbeInterval),
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				prober.RunOnce(ctx)
+				return nil
+			},
+		})
+		lm.SetProviderStatus(prober.Results)
+	}
+
+	asyncCfg, err := queue.LoadConfig()
+	if err != nil {
+		log.Fatalf("failed to load async queue config: %v", err)
+	}
+	asyncLookups := queue.New(asyncCfg, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		ctx, cancel := context.WithTimeout(ctx, cfg.TimeoutFor("/temperature"))
+		defer cancel()
+		return handler.Lookup(ctx, query, header)
+	})
+	for i := 1; i <= asyncCfg.Workers; i++ {
+		tasks.Go(fmt.Sprintf("async-lookup-worker-%d", i), asyncLookups.Run)
+	}
+
+	if cfg.CachePrefetchTop > 0 {
+		addJob(scheduler.Job{
+			Name: "cache-prefetch",
+			Spec: cfg.JobSpec("cache-prefetch", cfg.CachePrefetchInterval),
+			Run: func(ctx context.Context) error {
+				return handler.Prefetch(ctx, lookupStats, cfg.CachePrefetchTop)
+			},
+		})
+	}
+
+	purger, canPurge := lookups.(history.Purger)
+	if cfg.HistoryRetention.Enabled() {
+		if canPurge {
+			addJob(scheduler.Job{
+				Name: "history-purge",
+				Spec: cfg.JobSpec("history-purge", cfg.HistoryPurgeInterval),
+				Run: func(ctx context.Context) error {
+					_, err := purger.Purge(ctx, time.Now(), cfg.HistoryRetention)
+					return err
+				},
+			})
+		} else {
+			log.Printf("HISTORY_RETENTION_DAYS does not apply to the in-memory history, which keeps its most recent lookups")
+		}
+	}
+
+	for _, name := range cfg.JobsDisabled {
+		if err := jobs.SetEnabled(name, false); err != nil {
+			log.Fatalf("invalid JOBS_DISABLED entry %q: %v", name, err)
+		}
+	}
+	tasks.Go("scheduler", jobs.Run)
+	tasks.Go("webhooks", webhooks.Run)
+
+	maintenanceSwitch := maintenance.NewSwitch(cfg.Maintenance)
+
+	// Batches and replays get their own workers so they cannot take every
+	// upstream connection away from single lookups
+	batchPool := workpool.New("batch", cfg.BatchWorkers)
+	if err := workpool.RegisterMetrics(batchPool); err != nil {
+		log.Fatalf("failed to register worker pool metrics: %v", err)
+	}
+
 	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Get("/temperature", handler.TemperatureHandler)
+	r.Use(middleware.Stack(middleware.Options{
+		TrustedProxies:  cfg.TrustedProxies,
+		TrustAllProxies: cfg.TrustAllProxies,
+		RateLimit:       cfg.RateLimit,
+		RateBurst:       cfg.RateBurst,
+	})...)
+	r.Use(deprecation.New(cfg.Deprecation).Middleware)
+	r.Use(boot.Middleware)
+	r.Group(func(r chi.Router) {
+		r.Use(lm.Track)
+		r.Use(maintenanceSwitch.Middleware)
+		r.Use(policy.Middleware(cfg.APIKeyTiers))
+		r.With(middleware.Timeout(cfg.TimeoutFor("/temperature")), history.Middleware(lookups)).Get("/temperature", handler.TemperatureHandler)
+		r.With(middleware.Timeout(cfg.TimeoutFor("/temperature/trend"))).Get("/temperature/trend", trend.Handler(lookups, history.Record(lookups, handler.Lookup), clk))
+		r.With(middleware.Timeout(cfg.TimeoutFor("/temperature/batch")), idempotency.Middleware(idempotencyKeys, cfg.IdempotencyKeyTTL, cfg.TimeoutFor("/temperature/batch"))).Post("/temperature/batch", batch.Handler(handler.Lookup, batchPool, batch.DefaultMaxItems))
+		r.With(middleware.Timeout(cfg.DefaultTimeout)).Post("/temperature/async", asyncLookups.EnqueueHandler)
+		r.With(middleware.Timeout(cfg.DefaultTimeout)).Get("/temperature/async/{id}", asyncLookups.StatusHandler)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(cfg.DefaultTimeout))
+		r.Get("/version", buildinfo.Handler)
+		r.Get("/healthz", handler.HealthzHandler)
+		r.Post("/validate", batch.ValidateHandler(batch.DefaultMaxItems))
+		r.Get("/readyz", lm.ReadyHandler)
+	})
+	// Admin routes, and the history and status that expose stored lookups and
+	// internal endpoints, are only served to callers with an ADMIN_API_KEYS key
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(cfg.DefaultTimeout))
+		r.Use(middleware.RequireAPIKey(cfg.AdminAPIKeys))
+		r.Get("/statusz", lm.StatusHandler)
+		r.Get("/history", history.HistoryHandler(lookups))
+		r.Get("/drain", lm.DrainHandler)
+		r.With(auditLog.Middleware("drain", func() any { return lm.Status() })).Post("/drain", lm.DrainHandler)
+		r.Get("/admin/maintenance", maintenanceSwitch.Handler)
+		r.With(auditLog.Middleware("maintenance", func() any { return maintenanceSwitch.State() })).Post("/admin/maintenance", maintenanceSwitch.Handler)
+		r.Get("/admin/audit", auditLog.Handler)
+		r.Get("/admin/idempotency/{key}", idempotency.Handler(idempotencyKeys))
+		r.Get("/admin/dead-letters", asyncLookups.DeadLettersHandler)
+		r.Get("/admin/dead-letters/{id}", asyncLookups.DeadLetterHandler)
+		r.With(auditLog.Middleware("dead-letter-requeue", func() any { return asyncLookups.DeadLetters() })).
+			Post("/admin/dead-letters/{id}/requeue", asyncLookups.RequeueHandler)
+		r.Get("/admin/webhooks", webhook.ListHandler(webhookDeliveries))
+		r.Get("/admin/webhooks/{id}", webhook.Handler(webhookDeliveries))
+		r.Get("/admin/jobs", jobs.ListHandler)
+		r.With(auditLog.Middleware("job", func() any { return jobs.Status() })).Post("/admin/jobs/{name}", jobs.UpdateHandler)
+		r.With(auditLog.Middleware("job-run", func() any { return nil })).Post("/admin/jobs/{name}/run", jobs.TriggerHandler)
+		r.Get("/admin/config", configdump.Handler(config.Vars, envFile))
+		r.Get("/admin/requests/{trace_id}", history.TimelineHandler(lookups))
+		r.Get("/admin/history/export", historyExport.Handler)
+		r.Get("/admin/history/export/{id}", historyExport.JobHandler)
+		r.Get("/admin/history/export/{id}/download", historyExport.DownloadHandler)
+		if canPurge {
+			r.With(auditLog.Middleware("history-purge", func() any { return nil })).
+				Post("/admin/history/purge", history.PurgeHandler(purger, cfg.HistoryRetention))
+		}
+		r.Get("/admin/top", analytics.TopHandler(lookupStats))
+		r.With(auditLog.Middleware("replay", func() any { return nil }), batchPool.Middleware).
+			Post("/admin/replay/{trace_id}", history.ReplayHandler(lookups, handler.Lookup))
+	})
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8081"
 	}
 
+	// Accept HTTP/2 without TLS (h2c) so callers can multiplex requests on one connection
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{Addr: ":" + port, Handler: serve.WithBasePath(cfg.BasePath, r), Protocols: &protocols}
+
+	// Background tasks, and the exports and cross-checks requests started,
+	// stop only after the server has stopped taking requests
+	lm.OnShutdown(tasks.Shutdown)
+	lm.OnShutdown(server.Shutdown)
+
+	boot.Ready(ctx)
 	go func() {
 		log.Printf("Service Orchestration running on port %s", port)
-		if err := http.ListenAndServe(":"+port, r); err != nil {
+		if err := serve.ListenAndServe(server, cfg.TLS); err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
@@ -74,45 +504,131 @@ func main() {
 		log.Println("Shutting down due to other reason...")
 	}
 
-	_, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	lm.StartDrain()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
+
+	if err := lm.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shutdown: %v", err)
+	}
 }
 
-func initProvider(serviceName, collectorURL string) (func(context.Context) error, error) {
+// initProvider sets up the telemetry providers, exporting through the
+// collector failover it returns (nil when telemetry is disabled). Nothing
+// waits for the collector: the first export connects to it.
+func initProvider(serviceName string, enabled bool, metrics metricview.Config) (func(context.Context) []telemetry.ShutdownResult, *telemetry.Failover, error) {
+	if !enabled {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+		return func(context.Context) []telemetry.ShutdownResult { return nil }, nil, nil
+	}
+
 	ctx := context.Background()
 
+	info := buildinfo.Get()
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(info.Version),
+			semconv.DeploymentEnvironment(info.Environment),
+			attribute.String("vcs.commit", info.Commit),
 		),
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, collectorURL, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	exporterConfig, err := telemetry.LoadExporterConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	shutdownConfig, err := telemetry.LoadShutdownConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	failoverConfig, err := telemetry.LoadFailoverConfig(os.Getenv)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+		return nil, nil, err
 	}
+	collector, err := telemetry.NewFailover(failoverConfig, exporterConfig.DialOptions()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := collector.Conn()
 
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithHeaders(exporterConfig.Headers))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
+	attrFilter, err := attrfilter.Load(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sampling, err := telemetry.LoadSamplingConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	sampler := telemetry.NewBoostSampler(sampling)
+
+	spill, err := spillover.LoadConfig(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	spanExporter, err := spillover.Wrap(traceExporter, spill)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bsp := sdktrace.NewBatchSpanProcessor(spanExporter)
 	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSpanProcessor(bsp),
+		sdktrace.WithSpanProcessor(telemetry.NewOwnershipProcessor(telemetry.LoadOwnership(os.Getenv))),
+		sdktrace.WithSpanProcessor(attrfilter.NewProcessor(bsp, attrFilter)),
+		sdktrace.WithSpanProcessor(sampler),
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler.ParentBased()),
 	)
 	otel.SetTracerProvider(traceProvider)
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn), otlpmetricgrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create metric exporter: %w", err)
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(append(metrics.Options(),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)...)
+	otel.SetMeterProvider(meterProvider)
+
+	logExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn), otlploggrpc.WithHeaders(exporterConfig.Headers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create log exporter: %w", err)
+	}
+
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithResource(res),
+	)
+	global.SetLoggerProvider(loggerProvider)
 
-	return traceProvider.Shutdown, nil
+	return func(ctx context.Context) []telemetry.ShutdownResult {
+		results := telemetry.Shutdown(ctx, shutdownConfig,
+			telemetry.Signal{Name: "traces", Shutdown: traceProvider.Shutdown},
+			telemetry.Signal{Name: "metrics", Shutdown: meterProvider.Shutdown},
+			telemetry.Signal{Name: "logs", Shutdown: loggerProvider.Shutdown},
+		)
+		collector.Close()
+		return results
+	}, collector, nil
 }
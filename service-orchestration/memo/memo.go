@@ -0,0 +1,147 @@
+// Package memo shares upstream results between the lookups of one request
+// that looks up many CEPs, such as a batch: duplicate CEPs, or CEPs of the
+// same city, make one upstream call each, and the lookups that reused a result
+// are told so.
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Kinds of memoized results.
+const (
+	KindCity        = "city"
+	KindTemperature = "temperature"
+)
+
+var memoHits, _ = otel.Meter("service-orchestration").Int64Counter(
+	"orchestration.memo.hits",
+	metric.WithDescription("Number of upstream calls avoided by reusing a result within the same request, by kind"),
+)
+
+type entry struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// Memo holds the results of one request, keyed by kind and key.
+type Memo struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// Hits lists the kinds of results one lookup took from the memo.
+type Hits struct {
+	mu    sync.Mutex
+	kinds []string
+}
+
+func (h *Hits) add(kind string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.kinds = append(h.kinds, kind)
+}
+
+// Kinds returns the kinds reused, in the order they were, or nil.
+func (h *Hits) Kinds() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.kinds...)
+}
+
+type memoKey struct{}
+type hitsKey struct{}
+
+// NewContext returns a context carrying a new, empty Memo, for a request whose
+// lookups share results.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memoKey{}, &Memo{entries: make(map[string]*entry)})
+}
+
+// WithHits returns a context that records the results one lookup reuses.
+func WithHits(ctx context.Context) (context.Context, *Hits) {
+	h := &Hits{}
+	return context.WithValue(ctx, hitsKey{}, h), h
+}
+
+// Do returns the result of fn for kind and key, calling it only for the first
+// lookup of the request that asks; the others wait for that call and get its
+// result, errors included, with hit set. A call cut short by its own lookup's
+// context is not kept: the lookups waiting for it call fn themselves. Without
+// a Memo in ctx, fn is always called.
+func Do[V any](ctx context.Context, kind, key string, fn func() (V, error)) (v V, hit bool, err error) {
+	m, _ := ctx.Value(memoKey{}).(*Memo)
+	if m == nil {
+		v, err = fn()
+		return v, false, err
+	}
+
+	k := kind + "\x00" + key
+	for {
+		m.mu.Lock()
+		e, ok := m.entries[k]
+		if !ok {
+			e = &entry{done: make(chan struct{})}
+			m.entries[k] = e
+		}
+		m.mu.Unlock()
+
+		if !ok {
+			return call(m, k, e, fn)
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return v, false, ctx.Err()
+		}
+		if isContextErr(e.err) && ctx.Err() == nil {
+			continue
+		}
+		if h, _ := ctx.Value(hitsKey{}).(*Hits); h != nil {
+			h.add(kind)
+		}
+		memoHits.Add(ctx, 1, metric.WithAttributes(attribute.String("kind", kind)))
+		v, _ = e.value.(V)
+		return v, true, e.err
+	}
+}
+
+// call runs fn for the lookups waiting on e. A panicking fn fails them with
+// an error rather than its zero value, and the panic goes on.
+func call[V any](m *Memo, k string, e *entry, fn func() (V, error)) (V, bool, error) {
+	returned := false
+	defer func() {
+		var r any
+		if !returned {
+			r = recover()
+			e.err = fmt.Errorf("memo: lookup panicked: %v", r)
+		}
+		if isContextErr(e.err) {
+			m.mu.Lock()
+			delete(m.entries, k)
+			m.mu.Unlock()
+		}
+		close(e.done)
+		if !returned {
+			panic(r)
+		}
+	}()
+
+	v, err := fn()
+	returned = true
+	e.value, e.err = v, err
+	return v, false, err
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,114 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	ctx := NewContext(context.Background())
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	lookup := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "São Paulo", nil
+	}
+
+	var wg sync.WaitGroup
+	hits := make([]*Hits, 3)
+	results := make([]string, 3)
+	hit := make([]bool, 3)
+	for i := range hits {
+		var itemCtx context.Context
+		itemCtx, hits[i] = WithHits(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], hit[i], _ = Do(itemCtx, KindCity, "01001000", lookup)
+		}()
+	}
+	// The results match whether the other lookups wait for the call or find
+	// it done; holding it makes waiting the likely case
+	for calls.Load() == 0 {
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("lookup called %d times, want 1", calls.Load())
+	}
+	hitCount := 0
+	for i := range hits {
+		if results[i] != "São Paulo" {
+			t.Errorf("result %d = %q", i, results[i])
+		}
+		if hit[i] {
+			hitCount++
+			if got := hits[i].Kinds(); len(got) != 1 || got[0] != KindCity {
+				t.Errorf("hits of lookup %d = %v, want [city]", i, got)
+			}
+		} else if got := hits[i].Kinds(); len(got) != 0 {
+			t.Errorf("hits of the lookup that called = %v, want none", got)
+		}
+	}
+	if hitCount != 2 {
+		t.Errorf("%d lookups reused the result, want 2", hitCount)
+	}
+}
+
+func TestDoKeepsErrorsAndKinds(t *testing.T) {
+	ctx := NewContext(context.Background())
+	notFound := errors.New("not found")
+
+	Do(ctx, KindCity, "99999999", func() (string, error) { return "", notFound })
+	if _, hit, err := Do(ctx, KindCity, "99999999", func() (string, error) { return "called", nil }); !hit || !errors.Is(err, notFound) {
+		t.Errorf("Do() = hit %t, err %v; want the memoized error", hit, err)
+	}
+	// The same key of another kind is a different result
+	if v, hit, _ := Do(ctx, KindTemperature, "99999999", func() (string, error) { return "called", nil }); hit || v != "called" {
+		t.Errorf("Do() of another kind = %q, hit %t; want a call", v, hit)
+	}
+}
+
+func TestDoWithoutMemo(t *testing.T) {
+	calls := 0
+	for range 2 {
+		Do(context.Background(), KindCity, "01001000", func() (string, error) {
+			calls++
+			return "", nil
+		})
+	}
+	if calls != 2 {
+		t.Errorf("lookup called %d times outside a memo, want 2", calls)
+	}
+}
+
+func TestDoDoesNotShareContextErrors(t *testing.T) {
+	ctx := NewContext(context.Background())
+
+	Do(ctx, KindTemperature, "Recife", func() (float64, error) { return 0, context.DeadlineExceeded })
+	if v, hit, err := Do(ctx, KindTemperature, "Recife", func() (float64, error) { return 29, nil }); hit || err != nil || v != 29 {
+		t.Errorf("Do() after a timed-out call = %v, hit %t, %v; want a call of its own", v, hit, err)
+	}
+}
+
+func TestDoRecordsPanics(t *testing.T) {
+	ctx := NewContext(context.Background())
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the lookup's panic was swallowed")
+			}
+		}()
+		Do(ctx, KindTemperature, "Natal", func() (float64, error) { panic("provider client bug") })
+	}()
+	if v, hit, err := Do(ctx, KindTemperature, "Natal", func() (float64, error) { return 30, nil }); err == nil {
+		t.Errorf("Do() after a panicked call = %v, hit %t; want the panic as an error", v, hit)
+	}
+}
@@ -0,0 +1,119 @@
+// Package metricview shapes the streams the MeterProvider exports: it renames
+// instruments, sets histogram buckets and drops high-cardinality attributes
+// according to a views file, and caps the attribute sets each instrument may
+// collect so a label explosion cannot overwhelm the backend.
+package metricview
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	// DefaultDropAttributes keeps raw CEPs out of every metric.
+	DefaultDropAttributes = "cep"
+	// DefaultCardinalityLimit is the number of distinct attribute sets an
+	// instrument collects per cycle; further sets are aggregated into a single
+	// otel.metric.overflow=true data point.
+	DefaultCardinalityLimit = 2000
+)
+
+// View customizes the instruments whose name matches Instrument, a
+// path.Match pattern such as "workpool.*".
+type View struct {
+	Instrument     string    `json:"instrument"`
+	Name           string    `json:"name,omitempty"`
+	Buckets        []float64 `json:"buckets,omitempty"`
+	DropAttributes []string  `json:"drop_attributes,omitempty"`
+}
+
+// Config holds the metric views and cardinality guards of the MeterProvider.
+type Config struct {
+	Views []View
+	// DropAttributes are removed from every instrument.
+	DropAttributes []string
+	// CardinalityLimit of zero or less disables the limit.
+	CardinalityLimit int
+}
+
+// LoadFile reads a JSON array of views from path.
+func LoadFile(path string) ([]View, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var views []View
+	if err := json.Unmarshal(data, &views); err != nil {
+		return nil, fmt.Errorf("invalid metric views file %s: %w", path, err)
+	}
+	for i, v := range views {
+		if err := v.validate(); err != nil {
+			return nil, fmt.Errorf("invalid metric view %d in %s: %w", i, path, err)
+		}
+	}
+	return views, nil
+}
+
+func (v View) validate() error {
+	if v.Instrument == "" {
+		return fmt.Errorf("instrument is required")
+	}
+	if _, err := path.Match(v.Instrument, ""); err != nil {
+		return fmt.Errorf("instrument pattern %q: %w", v.Instrument, err)
+	}
+	if !sort.Float64sAreSorted(v.Buckets) {
+		return fmt.Errorf("buckets of %q must be increasing", v.Instrument)
+	}
+	return nil
+}
+
+// Options returns the MeterProvider options that apply c.
+func (c Config) Options() []sdkmetric.Option {
+	return []sdkmetric.Option{
+		sdkmetric.WithView(c.view),
+		sdkmetric.WithCardinalityLimit(c.CardinalityLimit),
+	}
+}
+
+// view merges every matching View into a single stream, so an instrument
+// matched by several views is still exported once. Names and buckets are
+// taken from the first view that sets them; dropped attributes add up.
+func (c Config) view(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+	stream := sdkmetric.Stream{Name: inst.Name, Description: inst.Description, Unit: inst.Unit}
+	drop := append([]string(nil), c.DropAttributes...)
+	matched := len(drop) > 0
+	renamed, bucketed := false, false
+
+	for _, v := range c.Views {
+		if ok, _ := path.Match(v.Instrument, inst.Name); !ok {
+			continue
+		}
+		matched = true
+		if v.Name != "" && !renamed {
+			stream.Name, renamed = v.Name, true
+		}
+		if len(v.Buckets) > 0 && !bucketed && inst.Kind == sdkmetric.InstrumentKindHistogram {
+			stream.Aggregation, bucketed = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets}, true
+		}
+		drop = append(drop, v.DropAttributes...)
+	}
+	if !matched {
+		return sdkmetric.Stream{}, false
+	}
+
+	if len(drop) > 0 {
+		keys := make([]attribute.Key, len(drop))
+		for i, k := range drop {
+			keys[i] = attribute.Key(k)
+		}
+		stream.AttributeFilter = attribute.NewDenyKeysFilter(keys...)
+	}
+	return stream, true
+}
@@ -0,0 +1,104 @@
+package metricview
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collect(t *testing.T, cfg Config, record func(metric.Meter)) []metricdata.Metrics {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(append(cfg.Options(), sdkmetric.WithReader(reader))...)
+	record(provider.Meter("test"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	return rm.ScopeMetrics[0].Metrics
+}
+
+func TestViews(t *testing.T) {
+	cfg := Config{
+		Views: []View{
+			{Instrument: "lookups", Name: "lookups.total", DropAttributes: []string{"city"}},
+			{Instrument: "wait*", Buckets: []float64{1, 10}},
+		},
+		DropAttributes: []string{"cep"},
+	}
+
+	metrics := collect(t, cfg, func(m metric.Meter) {
+		lookups, _ := m.Int64Counter("lookups")
+		lookups.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("cep", "01001000"), attribute.String("city", "São Paulo"), attribute.String("state", "ok"),
+		))
+		wait, _ := m.Float64Histogram("wait.duration")
+		wait.Record(context.Background(), 5)
+	})
+
+	byName := map[string]metricdata.Metrics{}
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+
+	lookups, ok := byName["lookups.total"]
+	if !ok {
+		t.Fatalf("lookups was not renamed, got %v", byName)
+	}
+	attrs := lookups.Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+	if attrs.Len() != 1 || !attrs.HasValue("state") {
+		t.Errorf("lookups attributes = %v, want only state", attrs.ToSlice())
+	}
+
+	wait := byName["wait.duration"].Data.(metricdata.Histogram[float64]).DataPoints[0]
+	if len(wait.Bounds) != 2 {
+		t.Errorf("wait.duration bounds = %v, want [1 10]", wait.Bounds)
+	}
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	metrics := collect(t, Config{CardinalityLimit: 3}, func(m metric.Meter) {
+		lookups, _ := m.Int64Counter("lookups")
+		for _, city := range []string{"a", "b", "c", "d", "e"} {
+			lookups.Add(context.Background(), 1, metric.WithAttributes(attribute.String("city", city)))
+		}
+	})
+
+	if points := metrics[0].Data.(metricdata.Sum[int64]).DataPoints; len(points) != 3 {
+		t.Errorf("got %d data points, want 3", len(points))
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `[{"instrument":"workpool.*","buckets":[1,5,10]}]`, false},
+		{"missing instrument", `[{"name":"x"}]`, true},
+		{"bad pattern", `[{"instrument":"["}]`, true},
+		{"unsorted buckets", `[{"instrument":"x","buckets":[5,1]}]`, true},
+		{"not json", `instrument: x`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "views.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// FieldNaming selects how Temperature field names are serialized.
+type FieldNaming string
+
+const (
+	// NamingLegacy keeps the original mixed-case keys (temp_C, temp_F, temp_K).
+	NamingLegacy FieldNaming = "legacy"
+	// NamingSnakeCase uses lower snake_case keys (temp_c, temp_f, temp_k).
+	NamingSnakeCase FieldNaming = "snake_case"
+)
+
+func ParseFieldNaming(value string) (FieldNaming, error) {
+	switch FieldNaming(strings.ToLower(strings.TrimSpace(value))) {
+	case "", NamingLegacy:
+		return NamingLegacy, nil
+	case NamingSnakeCase:
+		return NamingSnakeCase, nil
+	}
+	return "", fmt.Errorf("invalid field naming %q: expected legacy or snake_case", value)
+}
+
+// TemperatureFields returns the keys n gives the temperature fields.
+func (n FieldNaming) TemperatureFields() []string {
+	if n == NamingSnakeCase {
+		return []string{"temp_c", "temp_f", "temp_k"}
+	}
+	return []string{"temp_C", "temp_F", "temp_K"}
+}
+
+// NamingFromAccept returns the naming requested through the profile parameter
+// of an Accept header (e.g. `application/json; profile=snake_case`), or
+// fallback when none of the listed media types asks for one.
+func NamingFromAccept(accept string, fallback FieldNaming) FieldNaming {
+	for _, part := range strings.Split(accept, ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if naming, err := ParseFieldNaming(params["profile"]); err == nil && params["profile"] != "" {
+			return naming
+		}
+	}
+	return fallback
+}
+
+type legacyTemperature struct {
+	City  string  `json:"city"`
+	TempC float64 `json:"temp_C"`
+	TempF float64 `json:"temp_F"`
+	TempK float64 `json:"temp_K"`
+
+	DataQuality string              `json:"data_quality,omitempty"`
+	Display     *DisplayTemperature `json:"display,omitempty"`
+	Location    *Location           `json:"location,omitempty"`
+	Alerts      []WeatherAlert      `json:"alerts,omitempty"`
+	Meta        *ResponseMeta       `json:"meta,omitempty"`
+}
+
+type snakeCaseTemperature struct {
+	City  string  `json:"city"`
+	TempC float64 `json:"temp_c"`
+	TempF float64 `json:"temp_f"`
+	TempK float64 `json:"temp_k"`
+
+	DataQuality string              `json:"data_quality,omitempty"`
+	Display     *DisplayTemperature `json:"display,omitempty"`
+	Location    *Location           `json:"location,omitempty"`
+	Alerts      []WeatherAlert      `json:"alerts,omitempty"`
+	Meta        *ResponseMeta       `json:"meta,omitempty"`
+}
+
+func (t Temperature) MarshalJSON() ([]byte, error) {
+	if t.Naming == NamingSnakeCase {
+		return json.Marshal(snakeCaseTemperature(t.fields()))
+	}
+	return json.Marshal(t.fields())
+}
+
+// UnmarshalJSON accepts either naming scheme: encoding/json matches keys
+// case-insensitively, so temp_c decodes into the temp_C field as well.
+func (t *Temperature) UnmarshalJSON(data []byte) error {
+	var fields legacyTemperature
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*t = Temperature{
+		City:        fields.City,
+		TempC:       fields.TempC,
+		TempF:       fields.TempF,
+		TempK:       fields.TempK,
+		DataQuality: fields.DataQuality,
+		Display:     fields.Display,
+		Location:    fields.Location,
+		Alerts:      fields.Alerts,
+		Meta:        fields.Meta,
+	}
+	return nil
+}
+
+func (t Temperature) fields() legacyTemperature {
+	return legacyTemperature{
+		City:        t.City,
+		TempC:       t.TempC,
+		TempF:       t.TempF,
+		TempK:       t.TempK,
+		DataQuality: t.DataQuality,
+		Display:     t.Display,
+		Location:    t.Location,
+		Alerts:      t.Alerts,
+		Meta:        t.Meta,
+	}
+}
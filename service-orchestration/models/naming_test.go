@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemperatureMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		naming FieldNaming
+		want   string
+	}{
+		{"zero value is legacy", "", `{"city":"Recife","temp_C":30,"temp_F":86,"temp_K":303}`},
+		{"legacy", NamingLegacy, `{"city":"Recife","temp_C":30,"temp_F":86,"temp_K":303}`},
+		{"snake case", NamingSnakeCase, `{"city":"Recife","temp_c":30,"temp_f":86,"temp_k":303}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(Temperature{City: "Recife", TempC: 30, TempF: 86, TempK: 303, Naming: tt.naming})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemperatureUnmarshalJSONAcceptsBothSchemes(t *testing.T) {
+	for _, body := range []string{
+		`{"city":"Recife","temp_C":30,"temp_F":86,"temp_K":303}`,
+		`{"city":"Recife","temp_c":30,"temp_f":86,"temp_k":303}`,
+	} {
+		var got Temperature
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", body, err)
+		}
+		if got.City != "Recife" || got.TempC != 30 || got.TempF != 86 || got.TempK != 303 {
+			t.Errorf("Unmarshal(%s) = %+v", body, got)
+		}
+	}
+}
+
+func TestNamingFromAccept(t *testing.T) {
+	tests := []struct {
+		accept   string
+		fallback FieldNaming
+		want     FieldNaming
+	}{
+		{"", NamingLegacy, NamingLegacy},
+		{"", NamingSnakeCase, NamingSnakeCase},
+		{"application/json", NamingLegacy, NamingLegacy},
+		{"application/json; profile=snake_case", NamingLegacy, NamingSnakeCase},
+		{`application/json; profile="legacy"`, NamingSnakeCase, NamingLegacy},
+		{"text/html, application/json;profile=snake_case", NamingLegacy, NamingSnakeCase},
+		{"application/json; profile=camelCase", NamingLegacy, NamingLegacy},
+	}
+
+	for _, tt := range tests {
+		if got := NamingFromAccept(tt.accept, tt.fallback); got != tt.want {
+			t.Errorf("NamingFromAccept(%q, %q) = %q, want %q", tt.accept, tt.fallback, got, tt.want)
+		}
+	}
+}
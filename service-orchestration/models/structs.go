@@ -1,19 +1,110 @@
 package models
 
+import "time"
+
+// Temperature is serialized through MarshalJSON, which picks the key names
+// from Naming; the zero value keeps the legacy temp_C/temp_F/temp_K keys.
 type Temperature struct {
-	City  string  `json:"city"`
-	TempC float64 `json:"temp_C"`
-	TempF float64 `json:"temp_F"`
-	TempK float64 `json:"temp_K"`
+	City  string
+	TempC float64
+	TempF float64
+	TempK float64
+
+	DataQuality string
+	Display     *DisplayTemperature
+	Location    *Location
+	Alerts      []WeatherAlert
+	Meta        *ResponseMeta
+
+	Naming FieldNaming
 }
 
+// DisplayTemperature holds the temperatures formatted for a locale (e.g.
+// "24,6 °C" for pt-BR), for clients that render them without formatting.
+type DisplayTemperature struct {
+	Locale string `json:"locale"`
+	TempC  string `json:"temp_c"`
+	TempF  string `json:"temp_f"`
+	TempK  string `json:"temp_k"`
+}
+
+type ResponseMeta struct {
+	Degraded              bool     `json:"degraded"`
+	Degradations          []string `json:"degradations,omitempty"`
+	TemperatureAgeSeconds int64    `json:"temperature_age_seconds,omitempty"`
+	Cost                  *Cost    `json:"cost,omitempty"`
+}
+
+// ViaCEP is the address of a postal code. Latitude and Longitude are only
+// set by providers that return them, such as Zippopotam.
 type ViaCEP struct {
-	Localidade string `json:"localidade"`
-	Erro       bool   `json:"erro,omitempty"`
+	Localidade string  `json:"localidade"`
+	UF         string  `json:"uf"`
+	IBGE       string  `json:"ibge"`
+	Erro       bool    `json:"erro,omitempty"`
+	Latitude   float64 `json:"lat,omitempty"`
+	Longitude  float64 `json:"lon,omitempty"`
+}
+
+type Location struct {
+	IBGE      string  `json:"ibge"`
+	UF        string  `json:"uf"`
+	Region    string  `json:"region"`
+	Latitude  float64 `json:"lat,omitempty"`
+	Longitude float64 `json:"lon,omitempty"`
+}
+
+// HasCoordinates reports whether l was located, by the municipios dataset, a
+// geocoder or the postal code provider.
+func (l Location) HasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
+
+// WeatherAlert is an active weather alert for the resolved location, as
+// reported by the weather provider.
+type WeatherAlert struct {
+	Event    string    `json:"event"`
+	Severity string    `json:"severity"`
+	Expires  time.Time `json:"expires,omitzero"`
 }
 
 type WeatherAPI struct {
-	Current struct {
-		TempC float64 `json:"temp_c"`
+	Current *struct {
+		TempC *float64 `json:"temp_c"`
+		TempF *float64 `json:"temp_f"`
 	} `json:"current"`
 }
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+type ProviderHealth struct {
+	Healthy     bool      `json:"healthy"`
+	LatencyMs   float64   `json:"latency_ms"`
+	LastChecked time.Time `json:"last_checked"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+type JobStatus struct {
+	Schedule       string     `json:"schedule"`
+	Enabled        bool       `json:"enabled"`
+	Running        bool       `json:"running"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	Skipped        int        `json:"skipped"`
+	LastRun        *time.Time `json:"last_run,omitempty"`
+	LastDurationMs float64    `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRun        *time.Time `json:"next_run,omitempty"`
+}
+
+// Cost is the upstream work done for one request; Estimated uses the
+// configured per-call provider prices.
+type Cost struct {
+	UpstreamCalls int     `json:"upstream_calls"`
+	CacheHits     int     `json:"cache_hits"`
+	Bytes         int64   `json:"bytes"`
+	Estimated     float64 `json:"estimated"`
+}
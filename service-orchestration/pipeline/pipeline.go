@@ -0,0 +1,143 @@
+// Package pipeline runs a request through a sequence of named stages, each in
+// its own span, context and latency budget, so steps such as caches or
+// fallbacks can be inserted between the existing ones without touching them.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/budget"
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stage is one step of a Pipeline. Stages share the request state S, usually a
+// pointer to a struct each stage reads from and fills in.
+type Stage[S any] struct {
+	// Name is the span name of the stage and how other stages refer to it.
+	Name string
+	// Budget is the budget.Stage* whose latency budget the stage is checked
+	// against; empty skips the check.
+	Budget string
+	// Timeout bounds the stage's context; zero keeps the caller's deadline.
+	Timeout time.Duration
+	Run     func(ctx context.Context, span trace.Span, state S) error
+}
+
+// Error is returned by Pipeline.Run when a stage fails, so callers can map the
+// failure by the stage it happened in.
+type Error struct {
+	Stage string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.Stage, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type Pipeline[S any] struct {
+	tracer trace.Tracer
+	stages []Stage[S]
+}
+
+func New[S any](tracer trace.Tracer, stages ...Stage[S]) *Pipeline[S] {
+	return &Pipeline[S]{tracer: tracer, stages: stages}
+}
+
+// Stages returns the names of the stages, in the order they run.
+func (p *Pipeline[S]) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// InsertBefore adds stages before the stage called name.
+func (p *Pipeline[S]) InsertBefore(name string, stages ...Stage[S]) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("pipeline has no stage %q", name)
+	}
+	p.stages = slices.Insert(p.stages, i, stages...)
+	return nil
+}
+
+// InsertAfter adds stages after the stage called name.
+func (p *Pipeline[S]) InsertAfter(name string, stages ...Stage[S]) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("pipeline has no stage %q", name)
+	}
+	p.stages = slices.Insert(p.stages, i+1, stages...)
+	return nil
+}
+
+// Replace swaps the stage called name for stage.
+func (p *Pipeline[S]) Replace(name string, stage Stage[S]) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("pipeline has no stage %q", name)
+	}
+	p.stages[i] = stage
+	return nil
+}
+
+func (p *Pipeline[S]) index(name string) int {
+	return slices.IndexFunc(p.stages, func(s Stage[S]) bool { return s.Name == name })
+}
+
+// Run runs the stages in order and stops at the first one that fails,
+// returning an *Error naming it. Every stage runs in its own span and is
+// recorded on the lookup timeline.
+func (p *Pipeline[S]) Run(ctx context.Context, state S) error {
+	for _, stage := range p.stages {
+		if err := p.runStage(ctx, stage, state); err != nil {
+			return &Error{Stage: stage.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline[S]) runStage(ctx context.Context, stage Stage[S], state S) error {
+	return tracing.WithSpan(ctx, p.tracer, stage.Name, func(ctx context.Context, span trace.Span) error {
+		if stage.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, stage.Timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		err := stage.Run(ctx, span, state)
+		elapsed := time.Since(start)
+		if stage.Budget != "" {
+			budget.Observe(ctx, span, stage.Budget, elapsed)
+		}
+		history.AddEvent(ctx, "stage "+stageLabel(stage), outcome(err), start, elapsed)
+		return err
+	})
+}
+
+// stageLabel names the stage on the lookup timeline by its budget, as the
+// timeline did before stages had their own names.
+func stageLabel[S any](stage Stage[S]) string {
+	if stage.Budget != "" {
+		return stage.Budget
+	}
+	return stage.Name
+}
+
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type state struct {
+	ran []string
+}
+
+func record(name string, err error) Stage[*state] {
+	return Stage[*state]{Name: name, Run: func(ctx context.Context, span trace.Span, s *state) error {
+		s.ran = append(s.ran, name)
+		return err
+	}}
+}
+
+func TestRun(t *testing.T) {
+	errFetch := errors.New("provider unavailable")
+	tests := []struct {
+		name   string
+		stages []Stage[*state]
+		ran    []string
+		stage  string
+	}{
+		{"all stages", []Stage[*state]{record("validate", nil), record("fetch", nil), record("respond", nil)}, []string{"validate", "fetch", "respond"}, ""},
+		{"stops at failure", []Stage[*state]{record("validate", nil), record("fetch", errFetch), record("respond", nil)}, []string{"validate", "fetch"}, "fetch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &state{}
+			err := New(noop.NewTracerProvider().Tracer("test"), tt.stages...).Run(context.Background(), s)
+
+			if !slices.Equal(s.ran, tt.ran) {
+				t.Errorf("ran %v, want %v", s.ran, tt.ran)
+			}
+			var stageErr *Error
+			if tt.stage == "" {
+				if err != nil {
+					t.Errorf("Run() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &stageErr) || stageErr.Stage != tt.stage || !errors.Is(err, errFetch) {
+				t.Errorf("Run() = %v, want a failure of stage %q", err, tt.stage)
+			}
+		})
+	}
+}
+
+func TestInsertAndReplace(t *testing.T) {
+	p := New(noop.NewTracerProvider().Tracer("test"), record("validate", nil), record("fetch", nil))
+	if err := p.InsertBefore("fetch", record("cache", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.InsertAfter("fetch", record("fallback", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.InsertAfter("missing", record("x", nil)); err == nil {
+		t.Error("InsertAfter an unknown stage succeeded")
+	}
+	if err := p.Replace("validate", record("lint", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"lint", "cache", "fetch", "fallback"}
+	if got := p.Stages(); !slices.Equal(got, want) {
+		t.Errorf("Stages() = %v, want %v", got, want)
+	}
+	s := &state{}
+	p.Run(context.Background(), s)
+	if !slices.Equal(s.ran, want) {
+		t.Errorf("ran %v, want %v", s.ran, want)
+	}
+}
+
+func TestStageTimeout(t *testing.T) {
+	slow := Stage[*state]{Name: "fetch", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context, span trace.Span, s *state) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	err := New(noop.NewTracerProvider().Tracer("test"), slow).Run(context.Background(), &state{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() = %v, want the stage deadline", err)
+	}
+}
@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fhsmendes/open-telemetry/pkg/middleware"
+	"github.com/fhsmendes/open-telemetry/pkg/serve"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	// HeaderAPIKey identifies the caller, whose key maps to a tier.
+	HeaderAPIKey = "X-API-Key"
+	// TierAnonymous is the tier of requests without a known API key.
+	TierAnonymous = "anonymous"
+	// RuleDefault is the rule ID of decisions no rule matched.
+	RuleDefault = "default"
+)
+
+// AccessPolicy decides which requests are served from rules kept in a file,
+// so access can change without a release. The first rule matching a request
+// decides it; requests no rule matches get Default.
+//
+//	default: allow
+//	rules:
+//	  - id: free-small-batches
+//	    effect: deny
+//	    tiers: [free, anonymous]
+//	    routes: [/temperature/batch]
+//	    max_batch_size: 10
+//	  - id: roraima-partners-only
+//	    effect: deny
+//	    tiers: [free, anonymous]
+//	    ufs: [RR]
+type AccessPolicy struct {
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule matches requests meeting every condition it sets; a condition left
+// empty matches any request.
+type Rule struct {
+	ID     string   `yaml:"id"`
+	Effect string   `yaml:"effect"`
+	Tiers  []string `yaml:"tiers"`
+	Routes []string `yaml:"routes"`
+	UFs    []string `yaml:"ufs"`
+	// MaxBatchSize matches batches of more items than it.
+	MaxBatchSize int `yaml:"max_batch_size"`
+}
+
+// Input is what a request is decided on.
+type Input struct {
+	Tier  string
+	Route string
+	// UF is the state of the CEP once Resolved is set, which may still leave
+	// it empty when the state is not known.
+	UF       string
+	Resolved bool
+	// BatchSize is the number of items of the batch the lookup is part of,
+	// zero for single lookups.
+	BatchSize int
+}
+
+type Decision struct {
+	Allowed bool
+	RuleID  string
+}
+
+// LoadAccessPolicy reads the policy at path.
+func LoadAccessPolicy(path string) (*AccessPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy: %w", err)
+	}
+	return ParseAccessPolicy(data)
+}
+
+// ParseAccessPolicy decodes a YAML policy, rejecting unknown fields so a
+// misspelt condition cannot silently match every request.
+func ParseAccessPolicy(data []byte) (*AccessPolicy, error) {
+	var p AccessPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid access policy: %w", err)
+	}
+
+	if p.Default == "" {
+		p.Default = EffectAllow
+	}
+	if p.Default != EffectAllow && p.Default != EffectDeny {
+		return nil, fmt.Errorf("invalid access policy default %q: expected allow or deny", p.Default)
+	}
+	seen := make(map[string]bool)
+	for i, rule := range p.Rules {
+		if rule.ID == "" || rule.ID == RuleDefault || seen[rule.ID] {
+			return nil, fmt.Errorf("invalid access policy rule #%d: expected a unique id other than %q", i+1, RuleDefault)
+		}
+		seen[rule.ID] = true
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return nil, fmt.Errorf("invalid effect %q of access policy rule %s: expected allow or deny", rule.Effect, rule.ID)
+		}
+		for j, uf := range rule.UFs {
+			if rule.UFs[j] = strings.ToUpper(uf); !validUFs[rule.UFs[j]] {
+				return nil, fmt.Errorf("invalid access policy rule %s: unknown UF %q", rule.ID, uf)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// Evaluate decides in. A rule on the UF may or may not match before the city
+// is resolved, so reaching one leaves the request undecided: decided is false
+// and the caller evaluates again once in.Resolved is set.
+func (p *AccessPolicy) Evaluate(in Input) (d Decision, decided bool) {
+	for _, rule := range p.Rules {
+		if !rule.matchesKnown(in) {
+			continue
+		}
+		if len(rule.UFs) > 0 {
+			if !in.Resolved {
+				return Decision{}, false
+			}
+			if !contains(rule.UFs, strings.ToUpper(in.UF)) {
+				continue
+			}
+		}
+		return Decision{Allowed: rule.Effect == EffectAllow, RuleID: rule.ID}, true
+	}
+	return Decision{Allowed: p.Default == EffectAllow, RuleID: RuleDefault}, true
+}
+
+// matchesKnown reports whether every condition but the UF holds for in.
+func (r Rule) matchesKnown(in Input) bool {
+	if len(r.Tiers) > 0 && !contains(r.Tiers, in.Tier) {
+		return false
+	}
+	if len(r.Routes) > 0 && !contains(r.Routes, in.Route) {
+		return false
+	}
+	if r.MaxBatchSize > 0 && in.BatchSize <= r.MaxBatchSize {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAPIKeyTiers parses comma separated key:tier pairs, e.g.
+// "k1:premium,k2:free".
+func ParseAPIKeyTiers(value string) (map[string]string, error) {
+	tiers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, tier, ok := strings.Cut(pair, ":")
+		key, tier = strings.TrimSpace(key), strings.TrimSpace(tier)
+		if !ok || key == "" || tier == "" {
+			return nil, fmt.Errorf("invalid API key tier %q: expected key:tier", pair)
+		}
+		tiers[key] = tier
+	}
+	return tiers, nil
+}
+
+type inputKey struct{}
+
+// Middleware attaches the Input of each request, with the tier of its
+// X-API-Key and the route it was made to, for the handlers to evaluate. Keys
+// are compared in constant time, like the admin keys.
+func Middleware(tiers map[string]string) func(http.Handler) http.Handler {
+	keys := middleware.APIKeys(tiers)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tier, ok := keys.Lookup(r.Header.Get(HeaderAPIKey))
+			if !ok {
+				tier = TierAnonymous
+			}
+			in := Input{Tier: tier, Route: serve.RoutePath(r.Context(), r.URL.Path)}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inputKey{}, in)))
+		})
+	}
+}
+
+// InputFromContext returns the Input Middleware attached to ctx.
+func InputFromContext(ctx context.Context) (Input, bool) {
+	in, ok := ctx.Value(inputKey{}).(Input)
+	return in, ok
+}
+
+// WithInput returns ctx with its Input changed by update, such as a batch
+// recording its size for the lookups of its items. Contexts without an Input
+// are returned as they are.
+func WithInput(ctx context.Context, update func(*Input)) context.Context {
+	in, ok := InputFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	update(&in)
+	return context.WithValue(ctx, inputKey{}, in)
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - id: free-small-batches
+    effect: deny
+    tiers: [free, anonymous]
+    routes: [/temperature/batch]
+    max_batch_size: 10
+  - id: roraima-partners-only
+    effect: deny
+    tiers: [free, anonymous]
+    ufs: [rr]
+  - id: everyone
+    effect: allow
+`
+
+func TestParseAccessPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"unknown field", "rules:\n  - id: a\n    effect: deny\n    tier: [free]\n"},
+		{"invalid default", "default: maybe\n"},
+		{"invalid effect", "rules:\n  - id: a\n    effect: block\n"},
+		{"missing id", "rules:\n  - effect: deny\n"},
+		{"duplicate id", "rules:\n  - id: a\n    effect: deny\n  - id: a\n    effect: allow\n"},
+		{"unknown UF", "rules:\n  - id: a\n    effect: deny\n    ufs: [XX]\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAccessPolicy([]byte(tt.policy)); err == nil {
+			t.Errorf("%s: ParseAccessPolicy() should fail", tt.name)
+		}
+	}
+
+	if p, err := ParseAccessPolicy([]byte("rules: []\n")); err != nil || p.Default != EffectAllow {
+		t.Errorf("ParseAccessPolicy(no default) = %+v, %v, want allow by default", p, err)
+	}
+}
+
+func TestAccessPolicyEvaluate(t *testing.T) {
+	p, err := ParseAccessPolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		in      Input
+		decided bool
+		allowed bool
+		rule    string
+	}{
+		{"large free batch", Input{Tier: "free", Route: "/temperature/batch", BatchSize: 20}, true, false, "free-small-batches"},
+		{"small free batch, state unknown", Input{Tier: "free", Route: "/temperature/batch", BatchSize: 5}, false, false, ""},
+		{"small free batch in SP", Input{Tier: "free", Route: "/temperature/batch", BatchSize: 5, UF: "SP", Resolved: true}, true, true, "everyone"},
+		{"free lookup in RR", Input{Tier: "free", Route: "/temperature", UF: "RR", Resolved: true}, true, false, "roraima-partners-only"},
+		{"premium lookup in RR", Input{Tier: "premium", Route: "/temperature"}, true, true, "everyone"},
+		{"free lookup without state", Input{Tier: "free", Route: "/temperature", Resolved: true}, true, true, "everyone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, decided := p.Evaluate(tt.in)
+			if decided != tt.decided || d.Allowed != tt.allowed || d.RuleID != tt.rule {
+				t.Errorf("Evaluate() = %+v, %v, want allowed %v by %q, decided %v", d, decided, tt.allowed, tt.rule, tt.decided)
+			}
+		})
+	}
+
+	p.Rules = nil
+	if d, _ := p.Evaluate(Input{Tier: "premium"}); d.Allowed || d.RuleID != RuleDefault {
+		t.Errorf("Evaluate() without rules = %+v, want denied by default", d)
+	}
+}
+
+func TestParseAPIKeyTiers(t *testing.T) {
+	tiers, err := ParseAPIKeyTiers(" k1:premium, k2:free ,")
+	if err != nil || len(tiers) != 2 || tiers["k1"] != "premium" || tiers["k2"] != "free" {
+		t.Errorf("ParseAPIKeyTiers() = %v, %v", tiers, err)
+	}
+	for _, value := range []string{"k1", "k1:", ":free"} {
+		if _, err := ParseAPIKeyTiers(value); err == nil {
+			t.Errorf("ParseAPIKeyTiers(%q) should fail", value)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Input
+	h := Middleware(map[string]string{"k1": "premium"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithInput(r.Context(), func(in *Input) { in.BatchSize = 3 })
+		got, _ = InputFromContext(ctx)
+	}))
+
+	tests := []struct {
+		key  string
+		tier string
+	}{
+		{"k1", "premium"},
+		{"unknown", TierAnonymous},
+		{"", TierAnonymous},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/temperature/batch", nil)
+		req.Header.Set(HeaderAPIKey, tt.key)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if want := (Input{Tier: tt.tier, Route: "/temperature/batch", BatchSize: 3}); got != want {
+			t.Errorf("key %q: input = %+v, want %+v", tt.key, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+var validUFs = map[string]bool{
+	"AC": true, "AL": true, "AM": true, "AP": true, "BA": true, "CE": true, "DF": true,
+	"ES": true, "GO": true, "MA": true, "MG": true, "MS": true, "MT": true, "PA": true,
+	"PB": true, "PE": true, "PI": true, "PR": true, "RJ": true, "RN": true, "RO": true,
+	"RR": true, "RS": true, "SC": true, "SE": true, "SP": true, "TO": true,
+}
+
+// UFPolicy restricts lookups by state. An empty allow list allows every state
+// not in the deny list.
+type UFPolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// ParseUFPolicy builds a policy from comma separated state lists, e.g. "SP,RJ".
+func ParseUFPolicy(allow, deny string) (UFPolicy, error) {
+	allowSet, err := parseUFs(allow)
+	if err != nil {
+		return UFPolicy{}, fmt.Errorf("invalid UF allow list: %w", err)
+	}
+	denySet, err := parseUFs(deny)
+	if err != nil {
+		return UFPolicy{}, fmt.Errorf("invalid UF deny list: %w", err)
+	}
+	return UFPolicy{allow: allowSet, deny: denySet}, nil
+}
+
+func parseUFs(value string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, uf := range strings.Split(value, ",") {
+		uf = strings.ToUpper(strings.TrimSpace(uf))
+		if uf == "" {
+			continue
+		}
+		if !validUFs[uf] {
+			return nil, fmt.Errorf("unknown UF %q", uf)
+		}
+		set[uf] = true
+	}
+	return set, nil
+}
+
+// Allowed reports whether lookups for uf are permitted. An unknown (empty) UF
+// is only allowed when there is no allow list.
+func (p UFPolicy) Allowed(uf string) bool {
+	uf = strings.ToUpper(uf)
+	if p.deny[uf] {
+		return false
+	}
+	if len(p.allow) > 0 {
+		return p.allow[uf]
+	}
+	return true
+}
+
+func (p UFPolicy) Enabled() bool {
+	return len(p.allow) > 0 || len(p.deny) > 0
+}
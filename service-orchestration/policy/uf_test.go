@@ -0,0 +1,47 @@
+package policy
+
+import "testing"
+
+func TestParseUFPolicy(t *testing.T) {
+	if _, err := ParseUFPolicy("SP,XX", ""); err == nil {
+		t.Error("ParseUFPolicy should reject unknown UFs in the allow list")
+	}
+	if _, err := ParseUFPolicy("", "ZZ"); err == nil {
+		t.Error("ParseUFPolicy should reject unknown UFs in the deny list")
+	}
+	if p, err := ParseUFPolicy("", ""); err != nil || p.Enabled() {
+		t.Errorf("ParseUFPolicy empty = %v, %v, want disabled policy", p, err)
+	}
+}
+
+func TestUFPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    string
+		deny     string
+		uf       string
+		expected bool
+	}{
+		{"no policy", "", "", "BA", true},
+		{"no policy unknown UF", "", "", "", true},
+		{"in allow list", "SP, rj", "", "RJ", true},
+		{"outside allow list", "SP,RJ", "", "MG", false},
+		{"lowercase UF", "SP", "", "sp", true},
+		{"unknown UF with allow list", "SP", "", "", false},
+		{"in deny list", "", "AM", "AM", false},
+		{"outside deny list", "", "AM", "PA", true},
+		{"deny wins over allow", "SP,RJ", "RJ", "RJ", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseUFPolicy(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("ParseUFPolicy(%q, %q) unexpected error: %v", tt.allow, tt.deny, err)
+			}
+			if got := p.Allowed(tt.uf); got != tt.expected {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.uf, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package postal abstracts the postal codes of the countries the service
+// answers for: how a code is validated and normalized, and which provider
+// resolves it to an address. Brazil, with its CEPs looked up on ViaCEP, is
+// the default country.
+package postal
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	Brazil       = "BR"
+	Portugal     = "PT"
+	UnitedStates = "US"
+
+	DefaultCountry = Brazil
+)
+
+var ErrUnsupportedCountry = errors.New("unsupported country")
+
+// Validator reports whether code is a postal code of its country and returns
+// it in the normalized form providers and caches use.
+type Validator func(code string) (normalized string, ok bool)
+
+// Provider resolves a normalized postal code to an address. Addresses keep the
+// shape of ViaCEP's, with the state or region of other countries as UF, and a
+// code that does not exist is domain.ErrCEPNotFound.
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, code string, span trace.Span) (models.ViaCEP, error)
+}
+
+// Country is how the postal codes of a country are handled.
+type Country struct {
+	// Code is the ISO 3166-1 alpha-2 code of the country.
+	Code string
+	// Name disambiguates its cities in weather queries.
+	Name     string
+	Validate Validator
+	Provider Provider
+	// Coordinates is set when Provider locates every code it resolves, so
+	// weather providers that only take coordinates can serve the country.
+	Coordinates bool
+}
+
+var (
+	mu        sync.RWMutex
+	countries = map[string]Country{
+		Brazil: {Code: Brazil, Name: "Brazil", Validate: validateBR, Provider: ViaCEPProvider(utils.ViaCEP{})},
+		Portugal: {Code: Portugal, Name: "Portugal", Validate: validatePT,
+			Provider: zippopotam{country: "pt"}, Coordinates: true},
+		UnitedStates: {Code: UnitedStates, Name: "United States", Validate: validateUS,
+			Provider: zippopotam{country: "us"}, Coordinates: true},
+	}
+)
+
+// Register adds c, or replaces the country of the same code, so a country
+// can be supported, or served by another provider, without touching the
+// handlers.
+func Register(c Country) {
+	mu.Lock()
+	defer mu.Unlock()
+	c.Code = strings.ToUpper(c.Code)
+	countries[c.Code] = c
+}
+
+// Lookup returns the country of code, case insensitive; an empty code is the
+// default country.
+func Lookup(code string) (Country, error) {
+	if code == "" {
+		code = DefaultCountry
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := countries[strings.ToUpper(code)]
+	if !ok {
+		return Country{}, ErrUnsupportedCountry
+	}
+	return c, nil
+}
+
+// Codes returns the codes of the supported countries, sorted.
+func Codes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Key returns the key a postal code of country is cached under. CEPs keep
+// their bare code, so entries cached before other countries were supported
+// stay valid.
+func Key(country, code string) string {
+	if country == "" || country == DefaultCountry {
+		return code
+	}
+	return country + ":" + code
+}
+
+// SplitKey returns the country and postal code of a key built by Key.
+func SplitKey(key string) (country, code string) {
+	if country, code, ok := strings.Cut(key, ":"); ok {
+		return country, code
+	}
+	return DefaultCountry, key
+}
+
+var (
+	ptCode = regexp.MustCompile(`^(\d{4})-?(\d{3})$`)
+	usCode = regexp.MustCompile(`^(\d{5})(-\d{4})?$`)
+)
+
+func validateBR(code string) (string, bool) {
+	return code, utils.IsValidCEP(code)
+}
+
+// validatePT accepts NNNN-NNN with or without the hyphen.
+func validatePT(code string) (string, bool) {
+	m := ptCode.FindStringSubmatch(code)
+	if m == nil {
+		return "", false
+	}
+	return m[1] + "-" + m[2], true
+}
+
+// validateUS accepts ZIP and ZIP+4 codes, keeping the 5-digit ZIP that
+// addresses are resolved from.
+func validateUS(code string) (string, bool) {
+	m := usCode.FindStringSubmatch(code)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// ViaCEPProvider returns the Provider of CEPs that asks client, e.g. a mock
+// registered for Brazil in tests.
+func ViaCEPProvider(client utils.ViaCEPClient) Provider {
+	return viaCEP{client: client}
+}
+
+type viaCEP struct {
+	client utils.ViaCEPClient
+}
+
+func (viaCEP) Name() string { return "viacep" }
+
+func (v viaCEP) Lookup(ctx context.Context, code string, span trace.Span) (models.ViaCEP, error) {
+	return v.client.GetAddressFromCEP(ctx, code, span)
+}
+
+type zippopotam struct {
+	country string
+}
+
+func (zippopotam) Name() string { return "zippopotam" }
+
+func (z zippopotam) Lookup(ctx context.Context, code string, span trace.Span) (models.ViaCEP, error) {
+	return utils.GetAddressFromZippopotam(ctx, z.country, code, span)
+}
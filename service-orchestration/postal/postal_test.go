@@ -0,0 +1,61 @@
+package postal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		country string
+		code    string
+		want    string
+		valid   bool
+	}{
+		{Brazil, "01001000", "01001000", true},
+		{Brazil, "01001-000", "", false},
+		{Portugal, "1000-001", "1000-001", true},
+		{Portugal, "1000001", "1000-001", true},
+		{Portugal, "1000", "", false},
+		{UnitedStates, "90210", "90210", true},
+		{UnitedStates, "90210-1234", "90210", true},
+		{UnitedStates, "9021", "", false},
+	}
+	for _, tt := range tests {
+		c, err := Lookup(tt.country)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, valid := c.Validate(tt.code)
+		if valid != tt.valid || (valid && got != tt.want) {
+			t.Errorf("%s.Validate(%q) = %q, %v, want %q, %v", tt.country, tt.code, got, valid, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if c, err := Lookup(""); err != nil || c.Code != Brazil || c.Provider.Name() != "viacep" {
+		t.Errorf("Lookup(\"\") = %+v, %v, want Brazil on ViaCEP", c, err)
+	}
+	if c, err := Lookup("pt"); err != nil || c.Code != Portugal {
+		t.Errorf("Lookup(pt) = %+v, %v, want Portugal", c, err)
+	}
+	if _, err := Lookup("AR"); !errors.Is(err, ErrUnsupportedCountry) {
+		t.Errorf("Lookup(AR) error = %v, want ErrUnsupportedCountry", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if got := Key(Brazil, "01001000"); got != "01001000" {
+		t.Errorf("Key(BR) = %q, want the bare CEP", got)
+	}
+	if got := Key(Portugal, "1000-001"); got != "PT:1000-001" {
+		t.Errorf("Key(PT) = %q, want PT:1000-001", got)
+	}
+
+	for _, tt := range []struct{ country, code string }{{Brazil, "01001000"}, {Portugal, "1000-001"}} {
+		if country, code := SplitKey(Key(tt.country, tt.code)); country != tt.country || code != tt.code {
+			t.Errorf("SplitKey(Key(%s, %s)) = %s, %s", tt.country, tt.code, country, code)
+		}
+	}
+}
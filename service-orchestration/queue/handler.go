@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+	"github.com/go-chi/chi/v5"
+)
+
+// EnqueueHandler queues the lookup in the query string and answers 202 with the
+// message and its status URL in Location.
+func (q *Queue) EnqueueHandler(w http.ResponseWriter, r *http.Request) {
+	p := validation.NewParams(r.URL.Query())
+	p.String("cep", true)
+	if errs := p.Errors(); len(errs) > 0 {
+		validation.WriteProblem(w, p.Status(), "invalid request parameters", errs)
+		return
+	}
+
+	m, err := q.Enqueue(r.Context(), r.URL.Query())
+	if err != nil {
+		validation.WriteProblem(w, http.StatusServiceUnavailable, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+m.ID)
+	writeJSON(w, http.StatusAccepted, m)
+}
+
+// StatusHandler answers with the message {id}, including its result once done.
+func (q *Queue) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := q.Get(chi.URLParam(r, "id"))
+	if !ok {
+		validation.WriteProblem(w, http.StatusNotFound, ErrNotFound.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (q *Queue) DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	dead := q.DeadLetters()
+	if dead == nil {
+		dead = []Message{}
+	}
+	writeJSON(w, http.StatusOK, dead)
+}
+
+// DeadLetterHandler answers with the dead letter {id}: its original query, the
+// failure reason, the attempt count and the trace of its attempts.
+func (q *Queue) DeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := q.Get(chi.URLParam(r, "id"))
+	if !ok || m.Status != StatusDead {
+		validation.WriteProblem(w, http.StatusNotFound, ErrNotFound.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (q *Queue) RequeueHandler(w http.ResponseWriter, r *http.Request) {
+	m, err := q.Requeue(chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		validation.WriteProblem(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, ErrNotDead):
+		validation.WriteProblem(w, http.StatusConflict, err.Error(), nil)
+	case err != nil:
+		validation.WriteProblem(w, http.StatusServiceUnavailable, err.Error(), nil)
+	default:
+		writeJSON(w, http.StatusAccepted, m)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,358 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/carrier"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	StatusQueued = "queued"
+	StatusDone   = "done"
+	StatusDead   = "dead"
+)
+
+const (
+	DefaultWorkers     = 2
+	DefaultCapacity    = 100
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = time.Second
+	// DefaultRetention is how long finished messages stay readable through
+	// their status URL. Dead letters are kept until they are requeued.
+	DefaultRetention = time.Hour
+)
+
+var (
+	ErrFull     = errors.New("async lookup queue is full")
+	ErrNotFound = errors.New("message not found")
+	ErrNotDead  = errors.New("message is not dead-lettered")
+)
+
+var lookupAttempts, _ = otel.Meter("service-orchestration").Int64Counter(
+	"async.lookup.attempts",
+	metric.WithDescription("Async lookup attempts by outcome (done, retried, dead_lettered)"),
+)
+
+// ProcessFunc runs the lookup of query and returns the status and body of its
+// response, the same way a /temperature request would answer.
+type ProcessFunc func(ctx context.Context, query url.Values, header http.Header) (status int, body []byte)
+
+// Message is one async lookup. Once it runs out of attempts, or fails in a way
+// a retry cannot fix, it stays in the queue as a dead letter so operators can
+// inspect and requeue it.
+type Message struct {
+	ID             string          `json:"id"`
+	Query          string          `json:"query"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	Requeues       int             `json:"requeues,omitempty"`
+	ResultStatus   int             `json:"result_status,omitempty"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	TraceID        string          `json:"trace_id,omitempty"`
+	EnqueuedAt     time.Time       `json:"enqueued_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeadLetteredAt *time.Time      `json:"dead_lettered_at,omitempty"`
+
+	metadata carrier.Metadata
+}
+
+type Config struct {
+	Workers     int
+	Capacity    int
+	MaxAttempts int
+	// Backoff is the delay before the first redelivery; each later attempt
+	// waits one more Backoff.
+	Backoff time.Duration
+}
+
+// LoadConfig reads ASYNC_WORKERS, ASYNC_QUEUE_SIZE, ASYNC_MAX_ATTEMPTS and
+// ASYNC_RETRY_BACKOFF (e.g. "2s") from the environment.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		Workers:     DefaultWorkers,
+		Capacity:    DefaultCapacity,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+	}
+
+	for name, dst := range map[string]*int{
+		"ASYNC_WORKERS":      &cfg.Workers,
+		"ASYNC_QUEUE_SIZE":   &cfg.Capacity,
+		"ASYNC_MAX_ATTEMPTS": &cfg.MaxAttempts,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid %s: must be a positive integer", name)
+		}
+		*dst = n
+	}
+
+	if v := os.Getenv("ASYNC_RETRY_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid ASYNC_RETRY_BACKOFF: %w", err)
+		}
+		cfg.Backoff = d
+	}
+
+	return cfg, nil
+}
+
+// Queue runs async lookups on a fixed pool of workers. A failed attempt is
+// nacked and redelivered after a backoff until MaxAttempts is reached; a panic
+// in the lookup counts as a failed attempt instead of killing the worker.
+type Queue struct {
+	cfg     Config
+	process ProcessFunc
+	pending chan string
+
+	mu       sync.Mutex
+	messages map[string]*Message
+}
+
+func New(cfg Config, process ProcessFunc) *Queue {
+	return &Queue{
+		cfg:      cfg,
+		process:  process,
+		pending:  make(chan string, cfg.Capacity),
+		messages: make(map[string]*Message),
+	}
+}
+
+// Enqueue queues a lookup of query. The trace context and baggage of ctx travel
+// with the message, so every attempt continues the caller's trace.
+func (q *Queue) Enqueue(ctx context.Context, query url.Values) (Message, error) {
+	now := time.Now().UTC()
+	m := &Message{
+		ID:         newID(),
+		Query:      query.Encode(),
+		Status:     StatusQueued,
+		EnqueuedAt: now,
+		UpdatedAt:  now,
+		metadata:   carrier.Capture(ctx),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		m.TraceID = sc.TraceID().String()
+	}
+
+	q.mu.Lock()
+	q.prune(now)
+	select {
+	case q.pending <- m.ID:
+	default:
+		q.mu.Unlock()
+		return Message{}, ErrFull
+	}
+	q.messages[m.ID] = m
+	snapshot := *m
+	q.mu.Unlock()
+
+	return snapshot, nil
+}
+
+func (q *Queue) Get(id string) (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m, ok := q.messages[id]
+	if !ok {
+		return Message{}, false
+	}
+	return *m, true
+}
+
+// DeadLetters returns the dead-lettered messages, oldest first.
+func (q *Queue) DeadLetters() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var dead []Message
+	for _, m := range q.messages {
+		if m.Status == StatusDead {
+			dead = append(dead, *m)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].DeadLetteredAt.Before(*dead[j].DeadLetteredAt) })
+	return dead
+}
+
+// Requeue puts a dead letter back on the queue with a fresh set of attempts.
+func (q *Queue) Requeue(id string) (Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m, ok := q.messages[id]
+	if !ok {
+		return Message{}, ErrNotFound
+	}
+	if m.Status != StatusDead {
+		return Message{}, ErrNotDead
+	}
+
+	select {
+	case q.pending <- m.ID:
+	default:
+		return Message{}, ErrFull
+	}
+	m.Status = StatusQueued
+	m.Attempts = 0
+	m.Requeues++
+	m.DeadLetteredAt = nil
+	m.UpdatedAt = time.Now().UTC()
+	return *m, nil
+}
+
+// Run is one worker: it handles queued messages until ctx is done. It blocks,
+// so callers start one goroutine per worker. Messages still queued when the
+// last worker stops are dropped with the process.
+func (q *Queue) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case id := <-q.pending:
+			q.handle(ctx, id)
+		}
+	}
+}
+
+func (q *Queue) handle(ctx context.Context, id string) {
+	q.mu.Lock()
+	m, ok := q.messages[id]
+	if !ok || m.Status != StatusQueued {
+		q.mu.Unlock()
+		return
+	}
+	m.Attempts++
+	attempt := m.Attempts
+	query, _ := url.ParseQuery(m.Query)
+	metadata := m.metadata
+	q.mu.Unlock()
+
+	ctx = carrier.Extract(ctx, metadata)
+	ctx, span := otel.Tracer("service-orchestration").Start(ctx, "async-lookup",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.message.id", id),
+			attribute.Int("async.attempt", attempt),
+		))
+	defer span.End()
+
+	status, body, err := q.attempt(ctx, query)
+	retry := err != nil || retryable(status)
+	if err == nil && status >= http.StatusBadRequest {
+		err = fmt.Errorf("lookup answered %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now().UTC()
+	m.UpdatedAt = now
+	if m.TraceID == "" {
+		m.TraceID = span.SpanContext().TraceID().String()
+	}
+
+	var outcome string
+	switch {
+	case err == nil:
+		outcome = StatusDone
+		m.Status = StatusDone
+		m.ResultStatus = status
+		m.Result = json.RawMessage(body)
+		m.LastError = ""
+	case retry && attempt < q.cfg.MaxAttempts:
+		outcome = "retried"
+		m.LastError = err.Error()
+		go q.redeliver(ctx, id, time.Duration(attempt)*q.cfg.Backoff)
+	default:
+		outcome = "dead_lettered"
+		m.Status = StatusDead
+		m.ResultStatus = status
+		m.LastError = err.Error()
+		m.DeadLetteredAt = &now
+		span.AddEvent("dead-lettered")
+	}
+	span.SetAttributes(attribute.String("async.outcome", outcome))
+	lookupAttempts.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+}
+
+func (q *Queue) attempt(ctx context.Context, query url.Values) (status int, body []byte, err error) {
+	defer supervisor.RecoverPanic(ctx, "async lookup", &err)
+
+	status, body = q.process(ctx, query, nil)
+	return status, body, nil
+}
+
+// redeliver nacks a message back onto the queue after delay.
+func (q *Queue) redeliver(ctx context.Context, id string, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	select {
+	case <-ctx.Done():
+	case q.pending <- id:
+	}
+}
+
+// prune drops finished messages older than DefaultRetention. Callers must hold
+// q.mu.
+func (q *Queue) prune(now time.Time) {
+	for id, m := range q.messages {
+		if m.Status == StatusDone && now.Sub(m.UpdatedAt) > DefaultRetention {
+			delete(q.messages, id)
+		}
+	}
+}
+
+// retryable reports whether a lookup that answered status may succeed when
+// tried again. Client errors such as an invalid or unknown CEP never will.
+func retryable(status int) bool {
+	switch {
+	case status < http.StatusBadRequest:
+		return false
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
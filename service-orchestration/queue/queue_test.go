@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func startQueue(t *testing.T, process ProcessFunc) *Queue {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	q := New(Config{Workers: 1, Capacity: 10, MaxAttempts: 3, Backoff: time.Millisecond}, process)
+	go q.Run(ctx)
+	return q
+}
+
+func waitStatus(t *testing.T, q *Queue, id, status string) Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if m, ok := q.Get(id); ok && m.Status == status {
+			return m
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m, _ := q.Get(id)
+	t.Fatalf("message %s status = %q, want %q", id, m.Status, status)
+	return Message{}
+}
+
+func cepQuery(cep string) url.Values {
+	return url.Values{"cep": {cep}}
+}
+
+func TestQueue_Done(t *testing.T) {
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		return http.StatusOK, []byte(`{"city":"São Paulo","cep":"` + query.Get("cep") + `"}`)
+	})
+
+	m, err := q.Enqueue(context.Background(), cepQuery("01001000"))
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	m = waitStatus(t, q, m.ID, StatusDone)
+	if m.Attempts != 1 || m.ResultStatus != http.StatusOK {
+		t.Errorf("done message = %+v, want one attempt answered 200", m)
+	}
+	if string(m.Result) != `{"city":"São Paulo","cep":"01001000"}` {
+		t.Errorf("result = %s", m.Result)
+	}
+}
+
+func TestQueue_RetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		if calls.Add(1) < 3 {
+			return http.StatusBadGateway, []byte("upstream unavailable")
+		}
+		return http.StatusOK, []byte(`{}`)
+	})
+
+	m, _ := q.Enqueue(context.Background(), cepQuery("01001000"))
+	m = waitStatus(t, q, m.ID, StatusDone)
+	if m.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", m.Attempts)
+	}
+}
+
+func TestQueue_DeadLettersPoisonMessage(t *testing.T) {
+	prev, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		panic("decoder exploded")
+	})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "enqueue")
+	m, _ := q.Enqueue(ctx, cepQuery("01001000"))
+	span.End()
+
+	m = waitStatus(t, q, m.ID, StatusDead)
+	if m.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", m.Attempts)
+	}
+	if m.LastError != "panic in async lookup: decoder exploded" {
+		t.Errorf("last error = %q", m.LastError)
+	}
+	if m.TraceID != span.SpanContext().TraceID().String() {
+		t.Errorf("trace id = %q, want the enqueuing trace %s", m.TraceID, span.SpanContext().TraceID())
+	}
+	if m.DeadLetteredAt == nil {
+		t.Error("dead letter has no dead_lettered_at")
+	}
+	var attemptSpans int
+	for _, s := range spans.Ended() {
+		if s.Name() != "async-lookup" {
+			continue
+		}
+		attemptSpans++
+		if s.Parent().SpanID() != span.SpanContext().SpanID() {
+			t.Errorf("attempt span parent = %s, want the enqueuing span %s", s.Parent().SpanID(), span.SpanContext().SpanID())
+		}
+	}
+	if attemptSpans == 0 {
+		t.Error("no async-lookup spans were recorded")
+	}
+
+	dead := q.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != m.ID {
+		t.Errorf("DeadLetters = %+v, want [%s]", dead, m.ID)
+	}
+}
+
+func TestQueue_ClientErrorsAreNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		calls.Add(1)
+		return http.StatusUnprocessableEntity, []byte("invalid zipcode\n")
+	})
+
+	m, _ := q.Enqueue(context.Background(), cepQuery("123"))
+	m = waitStatus(t, q, m.ID, StatusDead)
+	if m.Attempts != 1 || calls.Load() != 1 {
+		t.Errorf("attempts = %d, calls = %d, want a single attempt", m.Attempts, calls.Load())
+	}
+	if m.LastError != "lookup answered 422: invalid zipcode" {
+		t.Errorf("last error = %q", m.LastError)
+	}
+}
+
+func TestQueue_Requeue(t *testing.T) {
+	var healthy atomic.Bool
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		if !healthy.Load() {
+			return http.StatusServiceUnavailable, nil
+		}
+		return http.StatusOK, []byte(`{}`)
+	})
+
+	m, _ := q.Enqueue(context.Background(), cepQuery("01001000"))
+	waitStatus(t, q, m.ID, StatusDead)
+
+	healthy.Store(true)
+	if _, err := q.Requeue(m.ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	m = waitStatus(t, q, m.ID, StatusDone)
+	if m.Requeues != 1 || m.Attempts != 1 {
+		t.Errorf("requeued message = %+v, want one requeue and one new attempt", m)
+	}
+
+	if _, err := q.Requeue(m.ID); err != ErrNotDead {
+		t.Errorf("Requeue of a done message = %v, want ErrNotDead", err)
+	}
+	if _, err := q.Requeue("missing"); err != ErrNotFound {
+		t.Errorf("Requeue of an unknown message = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	q := startQueue(t, func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		return http.StatusInternalServerError, []byte("boom")
+	})
+
+	r := chi.NewRouter()
+	r.Post("/temperature/async", q.EnqueueHandler)
+	r.Get("/temperature/async/{id}", q.StatusHandler)
+	r.Get("/admin/dead-letters", q.DeadLettersHandler)
+	r.Get("/admin/dead-letters/{id}", q.DeadLetterHandler)
+	r.Post("/admin/dead-letters/{id}/requeue", q.RequeueHandler)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/temperature/async"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("enqueue without cep = %d, want 422", w.Code)
+	}
+
+	w := serve(http.MethodPost, "/temperature/async?cep=01001000")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("enqueue = %d, want 202", w.Code)
+	}
+	var m Message
+	json.NewDecoder(w.Body).Decode(&m)
+	if loc := w.Header().Get("Location"); loc != "/temperature/async/"+m.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	if w := serve(http.MethodGet, "/admin/dead-letters/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("inspect of an unknown message = %d, want 404", w.Code)
+	}
+	if w := serve(http.MethodPost, "/admin/dead-letters/missing/requeue"); w.Code != http.StatusNotFound {
+		t.Errorf("requeue of an unknown message = %d, want 404", w.Code)
+	}
+
+	waitStatus(t, q, m.ID, StatusDead)
+
+	w = serve(http.MethodGet, "/admin/dead-letters")
+	var dead []Message
+	json.NewDecoder(w.Body).Decode(&dead)
+	if len(dead) != 1 || dead[0].ID != m.ID || dead[0].Query != "cep=01001000" {
+		t.Errorf("dead letters = %+v", dead)
+	}
+
+	if w := serve(http.MethodGet, "/admin/dead-letters/"+m.ID); w.Code != http.StatusOK {
+		t.Errorf("inspect = %d, want 200", w.Code)
+	}
+	if w := serve(http.MethodPost, "/admin/dead-letters/"+m.ID+"/requeue"); w.Code != http.StatusAccepted {
+		t.Errorf("requeue = %d, want 202", w.Code)
+	}
+	if w := serve(http.MethodGet, "/temperature/async/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("status of an unknown message = %d, want 404", w.Code)
+	}
+}
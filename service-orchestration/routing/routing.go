@@ -0,0 +1,138 @@
+// Package routing orders interchangeable providers by their recent latency and
+// success rate, so lookups go to the provider that is currently answering
+// best.
+package routing
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+const (
+	DefaultAlpha          = 0.2
+	DefaultMinSamples     = 20
+	DefaultExplorePercent = 5.0
+
+	// z95 turns a standard deviation into the distance from the mean to the
+	// 95th percentile of a normal distribution.
+	z95 = 1.645
+)
+
+const (
+	DecisionStatic  = "static"
+	DecisionLatency = "latency"
+	DecisionExplore = "explore"
+)
+
+type stats struct {
+	samples  int
+	mean     float64 // EWMA of latency, in ms
+	variance float64 // EWMA of the squared deviation from mean
+	success  float64 // EWMA of 1 for success and 0 for failure
+}
+
+// p95 estimates the 95th percentile latency from the EWMA mean and variance.
+func (s *stats) p95() float64 {
+	return s.mean + z95*math.Sqrt(s.variance)
+}
+
+// score is lower for better providers: the p95 latency inflated by the share
+// of failed calls, which would have to be retried elsewhere.
+func (s *stats) score() float64 {
+	return s.p95() / math.Max(s.success, 0.01)
+}
+
+// Router ranks a set of providers. Until every candidate has MinSamples
+// observations it keeps the static order it was given; ExplorePercent of
+// the time it moves a random other candidate to the front, so providers that
+// are not chosen keep getting the samples they need to be compared. Rand
+// decides when and where to explore.
+type Router struct {
+	Alpha          float64
+	MinSamples     int
+	ExplorePercent float64
+	Rand           clock.Rand
+
+	order []string
+
+	mu    sync.Mutex
+	stats map[string]*stats
+}
+
+// New returns a Router for providers, listed in their static order.
+func New(providers ...string) *Router {
+	return &Router{
+		Alpha:          DefaultAlpha,
+		MinSamples:     DefaultMinSamples,
+		ExplorePercent: DefaultExplorePercent,
+		Rand:           clock.DefaultRand,
+		order:          providers,
+		stats:          make(map[string]*stats),
+	}
+}
+
+// Providers returns the providers of r in their static order.
+func (r *Router) Providers() []string {
+	return append([]string(nil), r.order...)
+}
+
+// Observe records the outcome of a call to provider.
+func (r *Router) Observe(provider string, latency time.Duration, err error) {
+	ms := float64(latency.Microseconds()) / 1000
+	ok := 0.0
+	if err == nil {
+		ok = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, found := r.stats[provider]
+	if !found {
+		r.stats[provider] = &stats{samples: 1, mean: ms, success: ok}
+		return
+	}
+	s.samples++
+	diff := ms - s.mean
+	s.mean += r.Alpha * diff
+	s.variance = (1 - r.Alpha) * (s.variance + r.Alpha*diff*diff)
+	s.success += r.Alpha * (ok - s.success)
+}
+
+// Rank returns the providers for which eligible is true, best first, and the
+// decision that ordered them.
+func (r *Router) Rank(eligible func(provider string) bool) ([]string, string) {
+	var candidates []string
+	for _, p := range r.order {
+		if eligible == nil || eligible(p) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) < 2 {
+		return candidates, DecisionStatic
+	}
+
+	if r.ExplorePercent > 0 && r.Rand()*100 < r.ExplorePercent {
+		i := 1 + int(r.Rand()*float64(len(candidates)-1))
+		explored := append([]string{candidates[i]}, candidates[:i]...)
+		return append(explored, candidates[i+1:]...), DecisionExplore
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scores := make(map[string]float64, len(candidates))
+	for _, p := range candidates {
+		s, ok := r.stats[p]
+		if !ok || s.samples < r.MinSamples {
+			return candidates, DecisionStatic
+		}
+		scores[p] = s.score()
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return scores[candidates[i]] < scores[candidates[j]] })
+	return candidates, DecisionLatency
+}
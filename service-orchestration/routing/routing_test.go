@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func observe(r *Router, provider string, n int, latency time.Duration, err error) {
+	for range n {
+		r.Observe(provider, latency, err)
+	}
+}
+
+func TestRank(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	tests := []struct {
+		name         string
+		setup        func(r *Router)
+		eligible     func(string) bool
+		wantOrder    []string
+		wantDecision string
+	}{
+		{
+			"static until every provider has enough samples",
+			func(r *Router) { observe(r, "weatherapi", 10, time.Second, nil) },
+			nil,
+			[]string{"weatherapi", "open-meteo"},
+			DecisionStatic,
+		},
+		{
+			"faster provider first",
+			func(r *Router) {
+				observe(r, "weatherapi", 5, 800*time.Millisecond, nil)
+				observe(r, "open-meteo", 5, 100*time.Millisecond, nil)
+			},
+			nil,
+			[]string{"open-meteo", "weatherapi"},
+			DecisionLatency,
+		},
+		{
+			"failing provider last",
+			func(r *Router) {
+				observe(r, "weatherapi", 5, 100*time.Millisecond, nil)
+				observe(r, "open-meteo", 5, 50*time.Millisecond, errTimeout)
+			},
+			nil,
+			[]string{"weatherapi", "open-meteo"},
+			DecisionLatency,
+		},
+		{
+			"ineligible providers are left out",
+			func(r *Router) {
+				observe(r, "weatherapi", 5, 800*time.Millisecond, nil)
+				observe(r, "open-meteo", 5, 100*time.Millisecond, nil)
+			},
+			func(p string) bool { return p != "open-meteo" },
+			[]string{"weatherapi"},
+			DecisionStatic,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New("weatherapi", "open-meteo")
+			r.MinSamples = 5
+			r.ExplorePercent = 0
+			tt.setup(r)
+
+			order, decision := r.Rank(tt.eligible)
+			if !reflect.DeepEqual(order, tt.wantOrder) || decision != tt.wantDecision {
+				t.Errorf("Rank() = %v, %s; want %v, %s", order, decision, tt.wantOrder, tt.wantDecision)
+			}
+		})
+	}
+}
+
+func TestRankRecoversAfterLatencySpike(t *testing.T) {
+	r := New("weatherapi", "open-meteo")
+	r.MinSamples = 1
+	r.ExplorePercent = 0
+	observe(r, "weatherapi", 20, 100*time.Millisecond, nil)
+	observe(r, "open-meteo", 20, 300*time.Millisecond, nil)
+
+	observe(r, "weatherapi", 5, 3*time.Second, nil)
+	if order, _ := r.Rank(nil); order[0] != "open-meteo" {
+		t.Fatalf("order during spike = %v, want open-meteo first", order)
+	}
+
+	observe(r, "weatherapi", 40, 100*time.Millisecond, nil)
+	if order, _ := r.Rank(nil); order[0] != "weatherapi" {
+		t.Errorf("order after spike = %v, want weatherapi first", order)
+	}
+}
+
+func TestRankExplores(t *testing.T) {
+	r := New("weatherapi", "open-meteo")
+	r.ExplorePercent = 100
+
+	order, decision := r.Rank(nil)
+	if !reflect.DeepEqual(order, []string{"open-meteo", "weatherapi"}) || decision != DecisionExplore {
+		t.Errorf("Rank() = %v, %s; want the other provider first", order, decision)
+	}
+}
+
+func TestRankExploresWithRand(t *testing.T) {
+	r := New("weatherapi", "open-meteo", "nws")
+	r.ExplorePercent = 10
+
+	r.Rand = clock.Fixed(0.5)
+	if order, decision := r.Rank(nil); decision != DecisionStatic {
+		t.Errorf("Rank() = %v, %s; want no exploration above the percentage", order, decision)
+	}
+
+	r.Rand = clock.Fixed(0.05)
+	order, decision := r.Rank(nil)
+	if !reflect.DeepEqual(order, []string{"open-meteo", "weatherapi", "nws"}) || decision != DecisionExplore {
+		t.Errorf("Rank() = %v, %s; want open-meteo explored", order, decision)
+	}
+}
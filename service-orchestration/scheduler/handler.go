@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type jobUpdate struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ListHandler reports the state of every job.
+func (s *Scheduler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+// UpdateHandler enables or disables the job named by {name} with a
+// {"enabled": bool} body and reports its new state.
+func (s *Scheduler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var update jobUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Enabled == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": `expected {"enabled": true|false}`})
+		return
+	}
+	if err := s.SetEnabled(name, *update.Enabled); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Status()[name])
+}
+
+// TriggerHandler runs the job named by {name} now, outside its schedule.
+func (s *Scheduler) TriggerHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := s.Trigger(r.Context(), name); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.Status()[name])
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, ErrUnknownJob) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"message": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
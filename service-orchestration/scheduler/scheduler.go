@@ -0,0 +1,273 @@
+// Package scheduler runs the service's periodic jobs (probes, dataset updates)
+// on cron schedules, with a span per run and the outcome of the last run of
+// each job kept for /statusz and the admin API.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/open-telemetry/pkg/carrier"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrUnknownJob = errors.New("unknown job")
+
+var jobRuns, _ = otel.Meter("service-orchestration").Int64Counter(
+	"scheduler.job.runs",
+	metric.WithDescription("Scheduled job runs by job and result"),
+)
+
+var jobDuration, _ = otel.Meter("service-orchestration").Float64Histogram(
+	"scheduler.job.duration",
+	metric.WithDescription("Duration of scheduled job runs"),
+	metric.WithUnit("ms"),
+)
+
+// Job is a unit of periodic work. Spec is a standard five-field cron
+// expression or a descriptor such as "@hourly" or "@every 5m".
+type Job struct {
+	Name string
+	Spec string
+	Run  func(ctx context.Context) error
+
+	// RunOnStart runs the job as soon as the scheduler starts instead of
+	// waiting for the first scheduled time.
+	RunOnStart bool
+}
+
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+	status   models.JobStatus
+	next     time.Time
+}
+
+// Scheduler starts each enabled job when it is due. A job that is still running
+// when it comes due again is skipped rather than run twice at once.
+type Scheduler struct {
+	tracer trace.Tracer
+
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	started bool
+	ctx     context.Context
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		tracer: otel.Tracer("service-orchestration"),
+		jobs:   make(map[string]*entry),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add registers job. It fails on an invalid schedule or a duplicate name.
+func (s *Scheduler) Add(job Job) error {
+	schedule, err := cron.ParseStandard(job.Spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", job.Spec, job.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %s already registered", job.Name)
+	}
+	s.jobs[job.Name] = &entry{
+		job:      job,
+		schedule: schedule,
+		status:   models.JobStatus{Schedule: job.Spec, Enabled: true},
+		next:     schedule.Next(time.Now()),
+	}
+	s.notify()
+	return nil
+}
+
+// Run starts due jobs until ctx is done, then waits for the running ones to
+// return. It blocks, so callers run it in its own goroutine.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.started = true
+	for _, e := range s.jobs {
+		if e.job.RunOnStart && e.status.Enabled {
+			s.start(e, nil)
+		}
+	}
+	s.mu.Unlock()
+
+	defer s.wg.Wait()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		wait := s.startDue(time.Now())
+
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// startDue starts every enabled job whose time has come and returns how long to
+// sleep until the next one.
+func (s *Scheduler) startDue(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := time.Hour
+	for _, e := range s.jobs {
+		if !e.status.Enabled {
+			continue
+		}
+		if !e.next.After(now) {
+			s.start(e, nil)
+			e.next = e.schedule.Next(now)
+		}
+		wait = min(wait, e.next.Sub(now))
+	}
+	return wait
+}
+
+// start runs e in its own goroutine, continuing the trace in meta if any;
+// s.mu must be held.
+func (s *Scheduler) start(e *entry, meta carrier.Metadata) {
+	if e.status.Running {
+		e.status.Skipped++
+		jobRuns.Add(s.ctx, 1, metric.WithAttributes(attribute.String("job", e.job.Name), attribute.String("result", "skipped")))
+		return
+	}
+	e.status.Running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(e, meta)
+	}()
+}
+
+func (s *Scheduler) run(e *entry, meta carrier.Metadata) {
+	start := time.Now()
+	err := s.runJob(e.job, meta)
+	elapsed := time.Since(start)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+		slog.ErrorContext(s.ctx, "Scheduled job failed", "job", e.job.Name, "error", err)
+	}
+	attrs := metric.WithAttributes(attribute.String("job", e.job.Name), attribute.String("result", result))
+	jobRuns.Add(s.ctx, 1, attrs)
+	jobDuration.Record(s.ctx, float64(elapsed.Microseconds())/1000, attrs)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastRun = &start
+	e.status.LastDurationMs = float64(elapsed.Microseconds()) / 1000
+	e.status.LastError = ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	}
+}
+
+// runJob runs job under a span of its own. s.ctx carries no span, so each
+// scheduled run is its own trace; a triggered run is a child of the span that
+// triggered it, read from meta.
+func (s *Scheduler) runJob(job Job, meta carrier.Metadata) error {
+	ctx := s.ctx
+	if meta != nil {
+		ctx = carrier.Extract(ctx, meta)
+	}
+	return tracing.WithSpan(ctx, s.tracer, "job "+job.Name, func(ctx context.Context, span trace.Span) (err error) {
+		defer supervisor.RecoverPanic(ctx, "job "+job.Name, &err)
+		span.SetAttributes(
+			attribute.String("job.name", job.Name),
+			attribute.String("job.schedule", job.Spec),
+		)
+		return job.Run(ctx)
+	})
+}
+
+// Trigger runs the named job now, outside its schedule, as part of the trace
+// of ctx. The scheduler must be running.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if !s.started || s.ctx.Err() != nil {
+		return errors.New("scheduler is not running")
+	}
+	s.start(e, carrier.Capture(ctx))
+	return nil
+}
+
+// SetEnabled pauses or resumes the named job. A run in progress is not
+// interrupted; a resumed job next runs at its following scheduled time.
+func (s *Scheduler) SetEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if enabled && !e.status.Enabled {
+		e.next = e.schedule.Next(time.Now())
+	}
+	e.status.Enabled = enabled
+	s.notify()
+	return nil
+}
+
+// Status reports the state of every job for /statusz.
+func (s *Scheduler) Status() map[string]models.JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make(map[string]models.JobStatus, len(s.jobs))
+	for name, e := range s.jobs {
+		status[name] = s.statusOf(e)
+	}
+	return status
+}
+
+// statusOf copies the status of e; s.mu must be held.
+func (s *Scheduler) statusOf(e *entry) models.JobStatus {
+	status := e.status
+	if status.Enabled {
+		next := e.next
+		status.NextRun = &next
+	}
+	return status
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
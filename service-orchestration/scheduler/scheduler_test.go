@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAddRejectsInvalidSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"five fields", "*/5 * * * *", false},
+		{"descriptor", "@daily", false},
+		{"interval", "@every 90s", false},
+		{"six fields", "0 */5 * * * *", true},
+		{"garbage", "often", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Add(Job{Name: "job", Spec: tt.spec, Run: func(context.Context) error { return nil }})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Add(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunOnStartAndStatus(t *testing.T) {
+	s := New()
+	s.Add(Job{Name: "ok", Spec: "@hourly", RunOnStart: true, Run: func(context.Context) error { return nil }})
+	s.Add(Job{Name: "failing", Spec: "@hourly", RunOnStart: true, Run: func(context.Context) error { return errors.New("provider down") }})
+	s.Add(Job{Name: "panicking", Spec: "@hourly", RunOnStart: true, Run: func(context.Context) error { panic("nil map") }})
+	s.Add(Job{Name: "later", Spec: "@hourly", Run: func(context.Context) error { return nil }})
+	startScheduler(t, s)
+
+	waitFor(t, func() bool {
+		status := s.Status()
+		return status["ok"].Runs == 1 && status["failing"].Runs == 1 && status["panicking"].Runs == 1
+	})
+
+	status := s.Status()
+	if status["ok"].LastError != "" || status["ok"].LastRun == nil || status["ok"].NextRun == nil {
+		t.Errorf("ok job status = %+v", status["ok"])
+	}
+	if status["failing"].Failures != 1 || status["failing"].LastError != "provider down" {
+		t.Errorf("failing job status = %+v", status["failing"])
+	}
+	if status["panicking"].Failures != 1 {
+		t.Errorf("panicking job status = %+v", status["panicking"])
+	}
+	if status["later"].Runs != 0 || status["later"].LastRun != nil {
+		t.Errorf("later job ran before its schedule: %+v", status["later"])
+	}
+}
+
+func TestTriggerSkipsRunningJob(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	s.Add(Job{Name: "slow", Spec: "@hourly", Run: func(context.Context) error {
+		<-release
+		return nil
+	}})
+	startScheduler(t, s)
+
+	// Trigger fails until Run has started
+	waitFor(t, func() bool { return s.Trigger(context.Background(), "slow") == nil })
+	waitFor(t, func() bool { return s.Status()["slow"].Running })
+	s.Trigger(context.Background(), "slow")
+	close(release)
+	waitFor(t, func() bool { return !s.Status()["slow"].Running })
+
+	if status := s.Status()["slow"]; status.Runs != 1 || status.Skipped != 1 {
+		t.Errorf("status = %+v, want 1 run and 1 skipped", status)
+	}
+	if err := s.Trigger(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Trigger(missing) = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestTriggerContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	s := New()
+	s.Add(Job{Name: "probe", Spec: "@hourly", Run: func(context.Context) error { return nil }})
+	startScheduler(t, s)
+
+	ctx, trigger := provider.Tracer("test").Start(context.Background(), "POST /admin/jobs/probe/run")
+	defer trigger.End()
+	waitFor(t, func() bool { return s.Trigger(ctx, "probe") == nil })
+
+	waitFor(t, func() bool { return len(recorder.Ended()) == 1 })
+
+	parent := recorder.Ended()[0].Parent()
+	if !parent.IsRemote() || parent.SpanID() != trigger.SpanContext().SpanID() || parent.TraceID() != trigger.SpanContext().TraceID() {
+		t.Errorf("job span parent = %s/%s (remote %t), want the triggering span %s/%s",
+			parent.TraceID(), parent.SpanID(), parent.IsRemote(), trigger.SpanContext().TraceID(), trigger.SpanContext().SpanID())
+	}
+}
+
+func TestUpdateHandler(t *testing.T) {
+	s := New()
+	s.Add(Job{Name: "prober", Spec: "@hourly", Run: func(context.Context) error { return nil }})
+
+	r := chi.NewRouter()
+	r.Post("/admin/jobs/{name}", s.UpdateHandler)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		wantStatus  int
+		wantEnabled bool
+	}{
+		{"disable", "/admin/jobs/prober", `{"enabled": false}`, http.StatusOK, false},
+		{"enable", "/admin/jobs/prober", `{"enabled": true}`, http.StatusOK, true},
+		{"missing field", "/admin/jobs/prober", `{}`, http.StatusBadRequest, true},
+		{"unknown job", "/admin/jobs/warmer", `{"enabled": false}`, http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			status := s.Status()["prober"]
+			if status.Enabled != tt.wantEnabled {
+				t.Errorf("enabled = %v, want %v", status.Enabled, tt.wantEnabled)
+			}
+			if !status.Enabled && status.NextRun != nil {
+				t.Errorf("disabled job reports next run %v", status.NextRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package sla
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+)
+
+// defaultRoute is assumed for lookups recorded before the route was stored.
+const defaultRoute = "/temperature"
+
+type EndpointStats struct {
+	Endpoint     string  `json:"endpoint"`
+	Requests     int     `json:"requests"`
+	Failures     int     `json:"failures"`
+	Availability float64 `json:"availability"`
+	P50Ms        float64 `json:"p50_ms"`
+	P95Ms        float64 `json:"p95_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+}
+
+type ProviderStats struct {
+	Provider     string  `json:"provider"`
+	Calls        int     `json:"calls"`
+	Failures     int     `json:"failures"`
+	Availability float64 `json:"availability"`
+}
+
+type Report struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Endpoints []EndpointStats `json:"endpoints"`
+	Providers []ProviderStats `json:"providers"`
+}
+
+type endpoint struct {
+	failures  int
+	latencies []float64
+}
+
+type provider struct {
+	calls    int
+	failures int
+}
+
+// Collector aggregates the lookups recorded in [From, To). A request counts
+// against availability when it was answered with a 5xx status; client errors
+// such as an invalid or unknown CEP are the expected answer.
+type Collector struct {
+	From, To time.Time
+
+	endpoints map[string]*endpoint
+	providers map[string]*provider
+}
+
+func NewCollector(from, to time.Time) *Collector {
+	return &Collector{
+		From:      from,
+		To:        to,
+		endpoints: make(map[string]*endpoint),
+		providers: make(map[string]*provider),
+	}
+}
+
+func (c *Collector) Add(l history.Lookup) error {
+	if l.Time.Before(c.From) || !l.Time.Before(c.To) {
+		return nil
+	}
+
+	route := l.Route
+	if route == "" {
+		route = defaultRoute
+	}
+	e, ok := c.endpoints[route]
+	if !ok {
+		e = &endpoint{}
+		c.endpoints[route] = e
+	}
+	e.latencies = append(e.latencies, l.DurationMs)
+	if l.Status >= 500 {
+		e.failures++
+	}
+
+	for name, healthy := range l.Providers {
+		p, ok := c.providers[name]
+		if !ok {
+			p = &provider{}
+			c.providers[name] = p
+		}
+		p.calls++
+		if !healthy {
+			p.failures++
+		}
+	}
+	return nil
+}
+
+func (c *Collector) Report() Report {
+	r := Report{From: c.From, To: c.To, Endpoints: []EndpointStats{}, Providers: []ProviderStats{}}
+
+	for name, e := range c.endpoints {
+		sort.Float64s(e.latencies)
+		r.Endpoints = append(r.Endpoints, EndpointStats{
+			Endpoint:     name,
+			Requests:     len(e.latencies),
+			Failures:     e.failures,
+			Availability: availability(len(e.latencies), e.failures),
+			P50Ms:        percentile(e.latencies, 50),
+			P95Ms:        percentile(e.latencies, 95),
+			P99Ms:        percentile(e.latencies, 99),
+		})
+	}
+	sort.Slice(r.Endpoints, func(i, j int) bool { return r.Endpoints[i].Endpoint < r.Endpoints[j].Endpoint })
+
+	for name, p := range c.providers {
+		r.Providers = append(r.Providers, ProviderStats{
+			Provider:     name,
+			Calls:        p.calls,
+			Failures:     p.failures,
+			Availability: availability(p.calls, p.failures),
+		})
+	}
+	sort.Slice(r.Providers, func(i, j int) bool { return r.Providers[i].Provider < r.Providers[j].Provider })
+
+	return r
+}
+
+// availability is the percentage of successful requests; a period without
+// requests is reported as fully available.
+func availability(total, failures int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(total-failures)/float64(total)*100_000) / 1000
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, rank)]
+}
+
+// WriteCSV writes the report as one row per endpoint and provider. Latency
+// columns are empty for providers.
+func WriteCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "name", "requests", "failures", "availability", "p50_ms", "p95_ms", "p99_ms"})
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, e := range r.Endpoints {
+		cw.Write([]string{"endpoint", e.Endpoint, strconv.Itoa(e.Requests), strconv.Itoa(e.Failures), f(e.Availability), f(e.P50Ms), f(e.P95Ms), f(e.P99Ms)})
+	}
+	for _, p := range r.Providers {
+		cw.Write([]string{"provider", p.Provider, strconv.Itoa(p.Calls), strconv.Itoa(p.Failures), f(p.Availability), "", "", ""})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
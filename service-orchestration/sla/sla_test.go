@@ -0,0 +1,68 @@
+package sla
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+)
+
+func TestCollectorReport(t *testing.T) {
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCollector(from, to)
+
+	lookups := []history.Lookup{
+		{Time: from, Route: "/temperature", Status: 200, DurationMs: 100, Providers: map[string]bool{"viacep": true, "weatherapi": true}},
+		{Time: from.Add(time.Hour), Route: "/temperature", Status: 404, DurationMs: 50, Providers: map[string]bool{"viacep": true}},
+		{Time: from.Add(2 * time.Hour), Status: 502, DurationMs: 300, Providers: map[string]bool{"viacep": true, "weatherapi": false}},
+		{Time: from.Add(3 * time.Hour), Route: "/temperature", Status: 200, DurationMs: 200},
+		// Outside the range
+		{Time: to, Route: "/temperature", Status: 500, DurationMs: 9000},
+		{Time: from.Add(-time.Second), Route: "/temperature", Status: 500, DurationMs: 9000},
+	}
+	for _, l := range lookups {
+		c.Add(l)
+	}
+
+	r := c.Report()
+	if len(r.Endpoints) != 1 {
+		t.Fatalf("endpoints = %+v, want only /temperature", r.Endpoints)
+	}
+	e := r.Endpoints[0]
+	if e.Requests != 4 || e.Failures != 1 || e.Availability != 75 {
+		t.Errorf("endpoint stats = %+v, want 4 requests, 1 failure, 75%% available", e)
+	}
+	if e.P50Ms != 100 || e.P99Ms != 300 {
+		t.Errorf("latency p50 = %v, p99 = %v, want 100 and 300", e.P50Ms, e.P99Ms)
+	}
+
+	if len(r.Providers) != 2 {
+		t.Fatalf("providers = %+v, want viacep and weatherapi", r.Providers)
+	}
+	if p := r.Providers[0]; p.Provider != "viacep" || p.Calls != 3 || p.Availability != 100 {
+		t.Errorf("viacep stats = %+v", p)
+	}
+	if p := r.Providers[1]; p.Provider != "weatherapi" || p.Calls != 2 || p.Failures != 1 || p.Availability != 50 {
+		t.Errorf("weatherapi stats = %+v", p)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := Report{
+		Endpoints: []EndpointStats{{Endpoint: "/temperature", Requests: 3, Failures: 1, Availability: 66.667, P50Ms: 10, P95Ms: 20, P99Ms: 30}},
+		Providers: []ProviderStats{{Provider: "viacep", Calls: 3, Availability: 100}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	want := "kind,name,requests,failures,availability,p50_ms,p95_ms,p99_ms\n" +
+		"endpoint,/temperature,3,1,66.667,10,20,30\n" +
+		"provider,viacep,3,0,100,,,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,17 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/open-telemetry/pkg/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithSpan runs fn inside a new span named name with telemetry.WithSpan,
+// deriving the status from the returned error with domain.SpanStatus: Ok on
+// success, Unset for client errors, Error otherwise.
+func WithSpan(ctx context.Context, tracer trace.Tracer, name string, fn func(ctx context.Context, span trace.Span) error) error {
+	return telemetry.WithSpan(ctx, tracer, name, domain.SpanStatus, fn)
+}
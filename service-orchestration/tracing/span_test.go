@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return provider.Tracer("test"), recorder
+}
+
+// TestWithSpan checks the status mapping; the span handling itself is
+// covered in pkg/telemetry.
+func TestWithSpan(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(ctx context.Context, span trace.Span) error
+		wantCode codes.Code
+		wantDesc string
+	}{
+		{
+			"success",
+			func(ctx context.Context, span trace.Span) error { return nil },
+			codes.Ok, "",
+		},
+		{
+			"returned error",
+			func(ctx context.Context, span trace.Span) error { return errors.New("can not find zipcode") },
+			codes.Error, "can not find zipcode",
+		},
+		{
+			"client error leaves status unset",
+			func(ctx context.Context, span trace.Span) error { return domain.ErrCEPNotFound },
+			codes.Unset, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := newTracer()
+			WithSpan(context.Background(), tracer, "step", tt.fn)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("ended spans = %d, want exactly 1", len(spans))
+			}
+			status := spans[0].Status()
+			if status.Code != tt.wantCode || status.Description != tt.wantDesc {
+				t.Errorf("status = %v %q, want %v %q", status.Code, status.Description, tt.wantCode, tt.wantDesc)
+			}
+		})
+	}
+}
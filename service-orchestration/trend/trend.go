@@ -0,0 +1,174 @@
+// Package trend compares the current temperature of a CEP with the one
+// recorded in the lookup history a given window ago.
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/deploy-cloud-run/validation"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultWindow = 6 * time.Hour
+	MinWindow     = time.Minute
+	MaxWindow     = 7 * 24 * time.Hour
+
+	// StableThreshold is the smallest change, in °C, reported as rising or
+	// falling; smaller ones are within the provider's rounding noise.
+	StableThreshold = 0.5
+)
+
+type Direction string
+
+const (
+	Rising  Direction = "rising"
+	Falling Direction = "falling"
+	Stable  Direction = "stable"
+)
+
+type Reading struct {
+	TempC float64   `json:"temp_c"`
+	Time  time.Time `json:"time"`
+}
+
+type Response struct {
+	CEP       string    `json:"cep"`
+	City      string    `json:"city"`
+	Window    string    `json:"window"`
+	Current   Reading   `json:"current"`
+	Previous  Reading   `json:"previous"`
+	DeltaC    float64   `json:"delta_c"`
+	Direction Direction `json:"direction"`
+}
+
+// Compare returns the change from previous to current, rounded to a tenth of
+// a degree, and its direction.
+func Compare(previous, current Reading) (float64, Direction) {
+	delta := math.Round((current.TempC-previous.TempC)*10) / 10
+	switch {
+	case delta >= StableThreshold:
+		return delta, Rising
+	case delta <= -StableThreshold:
+		return delta, Falling
+	}
+	return delta, Stable
+}
+
+// Handler serves GET /temperature/trend?cep=...&window=6h. The current
+// temperature comes from lookup, so it is validated, cached and recorded like
+// any other lookup; the previous one is the latest successful lookup of the
+// CEP made at least window ago, but no more than half a window earlier than
+// that. Without such a lookup the response is 404 trend_unavailable.
+func Handler(store history.Store, lookup history.LookupFunc, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := validation.NewParams(r.URL.Query())
+		window := p.Duration("window", DefaultWindow, MinWindow, MaxWindow)
+		if errs := p.Errors(); len(errs) > 0 {
+			validation.WriteProblem(w, p.Status(), "invalid request parameters", errs)
+			return
+		}
+
+		cep := r.URL.Query().Get("cep")
+		tracing.WithSpan(r.Context(), otel.Tracer("service-orchestration"), "temperature-trend", func(ctx context.Context, span trace.Span) error {
+			span.SetAttributes(attribute.String("cep", cep), attribute.String("trend.window", window.String()))
+
+			now := clk.Now()
+			current, status, body := lookupCurrent(ctx, lookup, r, cep)
+			if status != http.StatusOK {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				w.Write(body)
+				return nil
+			}
+
+			previous, ok, err := previousReading(store, cep, now.Add(-window), window/2)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "failed to read history"})
+				return err
+			}
+			if !ok {
+				span.SetAttributes(attribute.Bool("trend.available", false))
+				writeJSON(w, http.StatusNotFound, models.ErrorResponse{
+					Message: fmt.Sprintf("no lookup of this zipcode around %s ago", window),
+					Code:    "trend_unavailable",
+				})
+				return nil
+			}
+
+			resp := Response{
+				CEP:      cep,
+				City:     current.City,
+				Window:   window.String(),
+				Current:  Reading{TempC: current.TempC, Time: now.UTC()},
+				Previous: previous,
+			}
+			resp.DeltaC, resp.Direction = Compare(resp.Previous, resp.Current)
+			span.SetAttributes(
+				attribute.Bool("trend.available", true),
+				attribute.Float64("trend.delta_c", resp.DeltaC),
+				attribute.String("trend.direction", string(resp.Direction)),
+			)
+			writeJSON(w, http.StatusOK, resp)
+			return nil
+		})
+	}
+}
+
+func lookupCurrent(ctx context.Context, lookup history.LookupFunc, r *http.Request, cep string) (models.Temperature, int, []byte) {
+	status, body := lookup(ctx, url.Values{"cep": {cep}}, r.Header.Clone())
+
+	var temp models.Temperature
+	if status == http.StatusOK {
+		if err := json.Unmarshal(body, &temp); err != nil {
+			body, _ := json.Marshal(models.ErrorResponse{Message: "invalid lookup response"})
+			return temp, http.StatusBadGateway, body
+		}
+	}
+	return temp, status, body
+}
+
+// previousReading returns the latest successful temperature lookup of cep made
+// at or before target and no earlier than target-tolerance.
+func previousReading(store history.Store, cep string, target time.Time, tolerance time.Duration) (Reading, bool, error) {
+	lookups, err := store.List(history.Filter{CEP: cep})
+	if err != nil {
+		return Reading{}, false, err
+	}
+
+	for _, l := range lookups {
+		if l.Time.After(target) {
+			continue
+		}
+		if l.Time.Before(target.Add(-tolerance)) {
+			break
+		}
+		if l.Status != http.StatusOK || (l.Route != "" && l.Route != "/temperature") {
+			continue
+		}
+		var temp models.Temperature
+		if err := json.Unmarshal([]byte(l.Body), &temp); err != nil {
+			continue
+		}
+		return Reading{TempC: temp.TempC, Time: l.Time}, true, nil
+	}
+	return Reading{}, false, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,86 @@
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/history"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		previous, current float64
+		delta             float64
+		direction         Direction
+	}{
+		{20, 25, 5, Rising},
+		{25, 20, -5, Falling},
+		{20, 20.3, 0.3, Stable},
+		{20, 19.6, -0.4, Stable},
+		{20, 20.5, 0.5, Rising},
+	}
+
+	for _, tt := range tests {
+		delta, direction := Compare(Reading{TempC: tt.previous}, Reading{TempC: tt.current})
+		if delta != tt.delta || direction != tt.direction {
+			t.Errorf("Compare(%v, %v) = %v, %s, want %v, %s", tt.previous, tt.current, delta, direction, tt.delta, tt.direction)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Date(2024, 2, 10, 18, 0, 0, 0, time.UTC)
+	store := history.NewMemoryStore(10)
+	store.Add(history.Lookup{CEP: "01001000", Time: now.Add(-8 * time.Hour), Route: "/temperature", Status: 200, Body: `{"city":"São Paulo","temp_C":18}`})
+	store.Add(history.Lookup{CEP: "01001000", Time: now.Add(-6*time.Hour - 10*time.Minute), Route: "/temperature", Status: 200, Body: `{"city":"São Paulo","temp_C":21}`})
+	store.Add(history.Lookup{CEP: "01001000", Time: now.Add(-6*time.Hour - 5*time.Minute), Route: "/temperature", Status: 502, Body: `{"message":"upstream provider unavailable"}`})
+	store.Add(history.Lookup{CEP: "01001000", Time: now.Add(-time.Hour), Route: "/temperature", Status: 200, Body: `{"city":"São Paulo","temp_C":30}`})
+
+	lookup := func(ctx context.Context, query url.Values, header http.Header) (int, []byte) {
+		if query.Get("cep") != "01001000" {
+			return http.StatusNotFound, []byte("can not find zipcode")
+		}
+		return http.StatusOK, []byte(`{"city":"São Paulo","temp_C":27.5,"temp_F":81.5,"temp_K":300.65}`)
+	}
+	h := Handler(store, lookup, clock.NewFake(now))
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantDelta  float64
+	}{
+		{"rising over 6h", "cep=01001000", http.StatusOK, 6.5},
+		{"no lookup around window", "cep=01001000&window=2h", http.StatusNotFound, 0},
+		{"invalid window", "cep=01001000&window=forever", http.StatusBadRequest, 0},
+		{"window too wide", "cep=01001000&window=720h", http.StatusUnprocessableEntity, 0},
+		{"lookup error passes through", "cep=99999999", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature/trend?"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.DeltaC != tt.wantDelta || resp.Direction != Rising || resp.Previous.TempC != 21 {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
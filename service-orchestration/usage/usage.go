@@ -0,0 +1,176 @@
+package usage
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	meter = otel.Meter("service-orchestration")
+
+	upstreamCalls, _ = meter.Int64Counter(
+		"orchestration.upstream.calls",
+		metric.WithDescription("Number of calls made to upstream providers, by provider"),
+	)
+	upstreamBytes, _ = meter.Int64Counter(
+		"orchestration.upstream.bytes",
+		metric.WithDescription("Response bytes read from upstream providers, by provider"),
+		metric.WithUnit("By"),
+	)
+	cacheHits, _ = meter.Int64Counter(
+		"orchestration.cache.hits",
+		metric.WithDescription("Number of provider calls avoided by the caches"),
+	)
+	estimatedCost, _ = meter.Float64Counter(
+		"orchestration.provider.estimated_cost",
+		metric.WithDescription("Estimated provider cost from the configured per-call prices, by provider"),
+	)
+)
+
+var prices map[string]float64
+
+// SetPrices sets the price of one call to each provider, used to estimate the
+// cost of a request. Providers without a price are free.
+func SetPrices(p map[string]float64) {
+	prices = p
+}
+
+type providerUsage struct {
+	calls int
+	bytes int64
+}
+
+// Usage accumulates the upstream work done on behalf of one request. A nil
+// *Usage ignores every call, so code paths outside a request need no checks.
+type Usage struct {
+	mu        sync.Mutex
+	providers map[string]*providerUsage
+	cacheHits int
+}
+
+type ctxKey struct{}
+
+// NewContext returns a context carrying a new, empty Usage.
+func NewContext(ctx context.Context) (context.Context, *Usage) {
+	u := &Usage{providers: make(map[string]*providerUsage)}
+	return context.WithValue(ctx, ctxKey{}, u), u
+}
+
+// WithoutUsage returns a context that carries no Usage, for work started on
+// behalf of a request that must not be billed to it.
+func WithoutUsage(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, (*Usage)(nil))
+}
+
+// FromContext returns the Usage of the current request, or nil.
+func FromContext(ctx context.Context) *Usage {
+	u, _ := ctx.Value(ctxKey{}).(*Usage)
+	return u
+}
+
+func (u *Usage) provider(name string) *providerUsage {
+	p, ok := u.providers[name]
+	if !ok {
+		p = &providerUsage{}
+		u.providers[name] = p
+	}
+	return p
+}
+
+// AddCall counts one call to provider. It is called once the provider
+// answered: error statuses count, since providers bill them as well, but
+// calls that never reached it (DNS, refused connections, an open breaker)
+// do not.
+func (u *Usage) AddCall(provider string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.provider(provider).calls++
+}
+
+func (u *Usage) AddCacheHit() {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cacheHits++
+}
+
+// CountBytes wraps a provider response body so the bytes read from it are
+// added to the request's usage.
+func CountBytes(ctx context.Context, provider string, body io.ReadCloser) io.ReadCloser {
+	u := FromContext(ctx)
+	if u == nil {
+		return body
+	}
+	return &countingBody{ReadCloser: body, usage: u, provider: provider}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	usage    *Usage
+	provider string
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.usage.mu.Lock()
+	b.usage.provider(b.provider).bytes += int64(n)
+	b.usage.mu.Unlock()
+	return n, err
+}
+
+// Cost summarizes the usage, pricing each call with the prices from SetPrices.
+func (u *Usage) Cost() models.Cost {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	cost := models.Cost{CacheHits: u.cacheHits}
+	for name, p := range u.providers {
+		cost.UpstreamCalls += p.calls
+		cost.Bytes += p.bytes
+		cost.Estimated += float64(p.calls) * prices[name]
+	}
+	return cost
+}
+
+// Record sets the usage on span and adds it to the aggregate metrics.
+func (u *Usage) Record(ctx context.Context, span trace.Span) {
+	cost := u.Cost()
+	span.SetAttributes(
+		attribute.Int("cost.upstream_calls", cost.UpstreamCalls),
+		attribute.Int("cost.cache_hits", cost.CacheHits),
+		attribute.Int64("cost.bytes", cost.Bytes),
+		attribute.Float64("cost.estimated", cost.Estimated),
+	)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	names := make([]string, 0, len(u.providers))
+	for name := range u.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := u.providers[name]
+		attrs := metric.WithAttributes(attribute.String("provider", name))
+		upstreamCalls.Add(ctx, int64(p.calls), attrs)
+		upstreamBytes.Add(ctx, p.bytes, attrs)
+		estimatedCost.Add(ctx, float64(p.calls)*prices[name], attrs)
+	}
+	if u.cacheHits > 0 {
+		cacheHits.Add(ctx, int64(u.cacheHits))
+	}
+}
@@ -0,0 +1,44 @@
+package usage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUsageCost(t *testing.T) {
+	SetPrices(map[string]float64{"weatherapi": 0.002})
+	defer SetPrices(nil)
+
+	ctx, u := NewContext(context.Background())
+	FromContext(ctx).AddCall("viacep")
+	FromContext(ctx).AddCall("weatherapi")
+	FromContext(ctx).AddCall("weatherapi")
+	FromContext(ctx).AddCacheHit()
+
+	body := CountBytes(ctx, "weatherapi", io.NopCloser(strings.NewReader(`{"current":{"temp_c":20}}`)))
+	io.ReadAll(body)
+
+	cost := u.Cost()
+	if cost.UpstreamCalls != 3 || cost.CacheHits != 1 {
+		t.Errorf("calls = %d, cache hits = %d, want 3 and 1", cost.UpstreamCalls, cost.CacheHits)
+	}
+	if cost.Bytes != 25 {
+		t.Errorf("bytes = %d, want 25", cost.Bytes)
+	}
+	if cost.Estimated != 0.004 {
+		t.Errorf("estimated cost = %v, want 0.004", cost.Estimated)
+	}
+}
+
+func TestUsageOutsideRequest(t *testing.T) {
+	ctx := context.Background()
+	FromContext(ctx).AddCall("weatherapi")
+	FromContext(ctx).AddCacheHit()
+
+	body := io.NopCloser(strings.NewReader("{}"))
+	if CountBytes(ctx, "weatherapi", body) != body {
+		t.Error("CountBytes should return the body unchanged without a Usage")
+	}
+}
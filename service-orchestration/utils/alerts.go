@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UrlWeatherAlertsAPI is the forecast endpoint, the only one that returns
+// alerts; a single day keeps the payload small.
+const UrlWeatherAlertsAPI = "%s/forecast.json?key=%s&q=%s&days=1&aqi=no&alerts=yes"
+
+type weatherAlertsAPI struct {
+	Alerts struct {
+		Alert []struct {
+			Event    string `json:"event"`
+			Severity string `json:"severity"`
+			Expires  string `json:"expires"`
+		} `json:"alert"`
+	} `json:"alerts"`
+}
+
+// GetWeatherAlerts returns the alerts active at now for query, a city name or
+// "lat,lon" pair.
+func GetWeatherAlerts(ctx context.Context, query string, now time.Time, span trace.Span) ([]models.WeatherAlert, error) {
+	var alerts []models.WeatherAlert
+	err := withWeatherKey(ctx, span, func(apiKey string) error {
+		var err error
+		alerts, err = getWeatherAlerts(ctx, query, apiKey, now, span)
+		return err
+	})
+	return alerts, err
+}
+
+func getWeatherAlerts(ctx context.Context, query, apiKey string, now time.Time, span trace.Span) ([]models.WeatherAlert, error) {
+	if apiKey == "" {
+		span.RecordError(fmt.Errorf("API key is not set"))
+		span.SetStatus(codes.Error, "API key is not set")
+		return nil, fmt.Errorf("API key is not set")
+	}
+
+	ctx, done := withUpstreamTimeout(ctx, "weatherapi", span)
+	defer done()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(UrlWeatherAlertsAPI, upstreams.URL("weatherapi", ""), apiKey, url.QueryEscape(query)), nil)
+	if err != nil {
+		span.RecordError(fmt.Errorf("failed to create request: %w", err))
+		span.SetStatus(codes.Error, "failed to create request")
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+
+	resp, err := do("weatherapi", req)
+	if err != nil {
+		span.RecordError(fmt.Errorf("failed to get weather alerts: %w", err))
+		span.SetStatus(codes.Error, "failed to get weather alerts")
+		return nil, domain.NewProviderError("weatherapi", 0, err)
+	}
+	defer resp.Body.Close()
+	usage.FromContext(ctx).AddCall("weatherapi")
+	resp.Body = usage.CountBytes(ctx, "weatherapi", resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		err := domain.NewProviderError("weatherapi", resp.StatusCode, nil)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "weather API returned error status")
+		return nil, err
+	}
+
+	alerts, err := DecodeWeatherAlerts(resp.Body, now)
+	if err != nil {
+		span.RecordError(fmt.Errorf("failed to decode alerts: %w", err))
+		span.SetStatus(codes.Error, decodeFailure(err, "failed to decode alerts"))
+		return nil, domain.NewProviderError("weatherapi", 0, err)
+	}
+	span.SetAttributes(attribute.Int("weather.alerts", len(alerts)))
+	return alerts, nil
+}
+
+// DecodeWeatherAlerts reads the alerts of a forecast response, skipping the
+// ones that expired before now. Alerts without a parseable expiry are kept,
+// with a zero Expires.
+func DecodeWeatherAlerts(r io.Reader, now time.Time) ([]models.WeatherAlert, error) {
+	var body weatherAlertsAPI
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, &WeatherSchemaError{Field: "body", Reason: err.Error(), Err: err}
+	}
+
+	alerts := []models.WeatherAlert{}
+	for _, a := range body.Alerts.Alert {
+		alert := models.WeatherAlert{Event: a.Event, Severity: a.Severity}
+		if expires, err := time.Parse(time.RFC3339, a.Expires); err == nil {
+			if expires.Before(now) {
+				continue
+			}
+			alert.Expires = expires
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeWeatherAlerts(t *testing.T) {
+	now := time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC)
+	body := `{"current":{"temp_c":30},"alerts":{"alert":[
+		{"event":"Heat Advisory","severity":"Moderate","expires":"2024-02-10T18:00:00-03:00"},
+		{"event":"Storm Warning","severity":"Severe","expires":"2024-02-10T08:00:00-03:00"},
+		{"event":"Flood Watch","severity":"Minor","expires":""}
+	]}}`
+
+	alerts, err := DecodeWeatherAlerts(strings.NewReader(body), now)
+	if err != nil {
+		t.Fatalf("DecodeWeatherAlerts() error = %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2 (the expired one skipped): %+v", len(alerts), alerts)
+	}
+	if alerts[0].Event != "Heat Advisory" || alerts[0].Severity != "Moderate" || alerts[0].Expires.IsZero() {
+		t.Errorf("alerts[0] = %+v", alerts[0])
+	}
+	if alerts[1].Event != "Flood Watch" || !alerts[1].Expires.IsZero() {
+		t.Errorf("alerts[1] = %+v, want Flood Watch without expiry", alerts[1])
+	}
+}
+
+func TestDecodeWeatherAlertsWithoutAlerts(t *testing.T) {
+	alerts, err := DecodeWeatherAlerts(strings.NewReader(`{"alerts":{"alert":[]}}`), time.Now())
+	if err != nil || alerts == nil || len(alerts) != 0 {
+		t.Errorf("DecodeWeatherAlerts() = %v, %v, want an empty list", alerts, err)
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/adaptive"
+	"github.com/fhsmendes/open-telemetry/pkg/cassette"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/geo"
+	"github.com/fhsmendes/open-telemetry/pkg/redact"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPClient's transport carries every upstream provider call, below the
+// retries, breaker and credentials of each provider's target.
+var HTTPClient = &http.Client{}
+
+// baseTransport is the network transport under HTTPClient and the cassette.
+var baseTransport = http.DefaultTransport
+
+// DefaultUpstreams are the provider targets used unless UPSTREAMS_FILE
+// replaces them. APIKeyWeather is the legacy weather API key variable. The
+// response limits leave ample room over the largest answers seen: an address
+// from ViaCEP is under 1 KiB and a forecast with alerts a few dozen KiB.
+var DefaultUpstreams = []upstream.Target{
+	{Name: "viacep", URL: "https://viacep.com.br", MaxResponseBytes: 64 << 10},
+	{Name: "zippopotam", URL: "https://api.zippopotam.us", MaxResponseBytes: 64 << 10},
+	{Name: "weatherapi", URL: "https://api.weatherapi.com/v1", Auth: upstream.Auth{QueryParam: "key", ValueEnv: "APIKeyWeather"}, MaxResponseBytes: 1 << 20},
+	{Name: "open-meteo", URL: geo.DefaultOpenMeteoURL},
+	{Name: "nominatim", URL: geo.DefaultNominatimURL},
+	{Name: "open-meteo-forecast", URL: "https://api.open-meteo.com/v1"},
+}
+
+var upstreams = newUpstreams(nil)
+
+// SetUpstreams makes provider calls use the targets of cfg, with clk timing
+// their breaker cooldowns.
+func SetUpstreams(cfg upstream.Config, clk clock.Clock) {
+	upstreams = newUpstreams(cfg)
+	upstreams.SetClock(clk)
+}
+
+// UpstreamClient returns the client and base URL of the target named name,
+// for providers built outside this package.
+func UpstreamClient(name string) (*http.Client, string) {
+	return upstreams.Client(name), upstreams.URL(name, "")
+}
+
+func newUpstreams(cfg upstream.Config) *upstream.Registry {
+	if cfg == nil {
+		cfg = make(upstream.Config)
+		for _, t := range DefaultUpstreams {
+			cfg[t.Name] = t
+		}
+	}
+	return upstream.NewRegistry(cfg, sharedTransport{})
+}
+
+// sharedTransport sends requests through HTTPClient's transport as it is at
+// call time, so SetCassette applies to clients built before it.
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if HTTPClient.Transport == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return HTTPClient.Transport.RoundTrip(req)
+}
+
+// do sends req with the client of provider's target, masking credentials in
+// the request URL that *url.Error embeds in its message so they never reach
+// spans, logs or error responses.
+func do(provider string, req *http.Request) (*http.Response, error) {
+	resp, err := upstreams.Client(provider).Do(req)
+	return resp, redact.Error(err)
+}
+
+// SetResolver makes provider calls resolve hosts through r. Call it before
+// SetCassette, which records through the same transport.
+func SetResolver(r *upstream.Resolver) {
+	baseTransport = r.Transport()
+	HTTPClient.Transport = baseTransport
+}
+
+// SetCassette makes provider calls record to, or replay from, dir.
+func SetCassette(mode cassette.Mode, dir string) {
+	HTTPClient.Transport = cassette.Wrap(baseTransport, mode, dir)
+}
+
+var upstreamTimeouts map[string]*adaptive.Controller
+
+// SetAdaptiveTimeouts gives each provider its own adaptive.Controller, built
+// by newController, that sets the deadline of its calls.
+func SetAdaptiveTimeouts(newController func() *adaptive.Controller) {
+	upstreamTimeouts = map[string]*adaptive.Controller{
+		"viacep":     newController(),
+		"weatherapi": newController(),
+	}
+}
+
+// withUpstreamTimeout applies the adaptive deadline of provider to ctx and
+// records it on span. The returned done func feeds the call latency back to
+// the controller and releases the context.
+func withUpstreamTimeout(ctx context.Context, provider string, span trace.Span) (context.Context, func()) {
+	c, ok := upstreamTimeouts[provider]
+	if !ok {
+		return ctx, func() {}
+	}
+
+	timeout := c.Timeout()
+	span.SetAttributes(attribute.Int64("upstream.timeout_ms", timeout.Milliseconds()))
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		c.Observe(time.Since(start))
+		cancel()
+	}
+}
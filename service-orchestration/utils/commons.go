@@ -11,6 +11,17 @@ func IsValidCEP(cep string) bool {
 	return matched
 }
 
+// Plausible range for surface air temperatures; values outside it are treated
+// as corrupt provider data.
+const (
+	MinPlausibleTempC = -90.0
+	MaxPlausibleTempC = 60.0
+)
+
+func IsPlausibleTemperature(celsius float64) bool {
+	return celsius >= MinPlausibleTempC && celsius <= MaxPlausibleTempC
+}
+
 func ConvertTemperatures(celsius float64) models.Temperature {
 	fahrenheit := celsius*1.8 + 32
 	kelvin := celsius + 273
@@ -21,3 +32,30 @@ func ConvertTemperatures(celsius float64) models.Temperature {
 		TempK: kelvin,
 	}
 }
+
+// Reading is a temperature as a provider reported it. Fahrenheit is set when
+// the provider supplies it, so it is not derived from a Celsius value the
+// provider already rounded.
+type Reading struct {
+	Celsius    float64
+	Fahrenheit *float64
+}
+
+// Conversion paths reported by ConvertReading.
+const (
+	ConversionProvider  = "provider"
+	ConversionConverted = "converted"
+)
+
+// ConvertReading returns the temperatures of r, preferring the provider's own
+// Fahrenheit value over ConvertTemperatures, and which of the two was used:
+// converting a rounded Celsius value can be off by a tenth of a degree from
+// what the provider shows.
+func ConvertReading(r Reading) (models.Temperature, string) {
+	temps := ConvertTemperatures(r.Celsius)
+	if r.Fahrenheit == nil {
+		return temps, ConversionConverted
+	}
+	temps.TempF = *r.Fahrenheit
+	return temps, ConversionProvider
+}
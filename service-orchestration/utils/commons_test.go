@@ -227,3 +227,53 @@ func TestConvertTemperatures_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPlausibleTemperature(t *testing.T) {
+	tests := []struct {
+		name     string
+		celsius  float64
+		expected bool
+	}{
+		{"room temperature", 25, true},
+		{"freezing point", 0, true},
+		{"lower bound", -90, true},
+		{"upper bound", 60, true},
+		{"below lower bound", -90.1, false},
+		{"above upper bound", 60.1, false},
+		{"absolute zero", -273, false},
+		{"corrupt value", 9999, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPlausibleTemperature(tt.celsius); got != tt.expected {
+				t.Errorf("IsPlausibleTemperature(%f) = %v, want %v", tt.celsius, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertReading(t *testing.T) {
+	native := 71.1
+	tests := []struct {
+		name     string
+		reading  Reading
+		wantF    float64
+		wantPath string
+	}{
+		{"provider fahrenheit", Reading{Celsius: 21.7, Fahrenheit: &native}, 71.1, ConversionProvider},
+		{"converted", Reading{Celsius: 21.7}, 21.7*1.8 + 32, ConversionConverted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			temps, path := ConvertReading(tt.reading)
+			if path != tt.wantPath {
+				t.Errorf("path = %q, want %q", path, tt.wantPath)
+			}
+			if temps.TempC != 21.7 || !almostEqual(temps.TempF, tt.wantF, 1e-9) || !almostEqual(temps.TempK, 294.7, 1e-9) {
+				t.Errorf("ConvertReading(%+v) = %+v", tt.reading, temps)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// DefaultDisplayLocale formats temperatures when the caller asks for the
+// display representation without a locale.
+var DefaultDisplayLocale = language.BrazilianPortuguese
+
+// FormatTemperatures returns t formatted with one decimal place and the
+// decimal and grouping separators of locale, such as "24,6 °C" for pt-BR and
+// "24.6 °C" for en-US.
+func FormatTemperatures(t models.Temperature, locale language.Tag) *models.DisplayTemperature {
+	p := message.NewPrinter(locale)
+	format := func(v float64, unit string) string {
+		return p.Sprintf("%v %s", number.Decimal(v, number.Scale(1)), unit)
+	}
+	return &models.DisplayTemperature{
+		Locale: locale.String(),
+		TempC:  format(t.TempC, "°C"),
+		TempF:  format(t.TempF, "°F"),
+		TempK:  format(t.TempK, "K"),
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"golang.org/x/text/language"
+)
+
+func TestFormatTemperatures(t *testing.T) {
+	temps := models.Temperature{TempC: 24.6, TempF: 76.28, TempK: 297.6}
+	tests := []struct {
+		locale string
+		want   models.DisplayTemperature
+	}{
+		{"pt-BR", models.DisplayTemperature{Locale: "pt-BR", TempC: "24,6 °C", TempF: "76,3 °F", TempK: "297,6 K"}},
+		{"en-US", models.DisplayTemperature{Locale: "en-US", TempC: "24.6 °C", TempF: "76.3 °F", TempK: "297.6 K"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got := FormatTemperatures(temps, language.MustParse(tt.locale))
+			if *got != tt.want {
+				t.Errorf("FormatTemperatures() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
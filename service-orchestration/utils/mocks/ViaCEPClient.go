@@ -2,34 +2,41 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	models "github.com/fhsmendes/deploy-cloud-run/models"
+	mock "github.com/stretchr/testify/mock"
+
+	trace "go.opentelemetry.io/otel/trace"
+)
 
 // ViaCEPClient is an autogenerated mock type for the ViaCEPClient type
 type ViaCEPClient struct {
 	mock.Mock
 }
 
-// GetCityFromCEP provides a mock function with given fields: cep
-func (_m *ViaCEPClient) GetCityFromCEP(cep string) (string, error) {
-	ret := _m.Called(cep)
+// GetAddressFromCEP provides a mock function with given fields: ctx, cep, span
+func (_m *ViaCEPClient) GetAddressFromCEP(ctx context.Context, cep string, span trace.Span) (models.ViaCEP, error) {
+	ret := _m.Called(ctx, cep, span)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetCityFromCEP")
+		panic("no return value specified for GetAddressFromCEP")
 	}
 
-	var r0 string
+	var r0 models.ViaCEP
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (string, error)); ok {
-		return rf(cep)
+	if rf, ok := ret.Get(0).(func(context.Context, string, trace.Span) (models.ViaCEP, error)); ok {
+		return rf(ctx, cep, span)
 	}
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(cep)
+	if rf, ok := ret.Get(0).(func(context.Context, string, trace.Span) models.ViaCEP); ok {
+		r0 = rf(ctx, cep, span)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.ViaCEP)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(cep)
+	if rf, ok := ret.Get(1).(func(context.Context, string, trace.Span) error); ok {
+		r1 = rf(ctx, cep, span)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -2,34 +2,42 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	trace "go.opentelemetry.io/otel/trace"
+
+	utils "github.com/fhsmendes/deploy-cloud-run/utils"
+)
 
 // WeatherAPIClient is an autogenerated mock type for the WeatherAPIClient type
 type WeatherAPIClient struct {
 	mock.Mock
 }
 
-// GetTemperature provides a mock function with given fields: city
-func (_m *WeatherAPIClient) GetTemperature(city string) (float64, error) {
-	ret := _m.Called(city)
+// GetReading provides a mock function with given fields: ctx, query, span
+func (_m *WeatherAPIClient) GetReading(ctx context.Context, query string, span trace.Span) (utils.Reading, error) {
+	ret := _m.Called(ctx, query, span)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetTemperature")
+		panic("no return value specified for GetReading")
 	}
 
-	var r0 float64
+	var r0 utils.Reading
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (float64, error)); ok {
-		return rf(city)
+	if rf, ok := ret.Get(0).(func(context.Context, string, trace.Span) (utils.Reading, error)); ok {
+		return rf(ctx, query, span)
 	}
-	if rf, ok := ret.Get(0).(func(string) float64); ok {
-		r0 = rf(city)
+	if rf, ok := ret.Get(0).(func(context.Context, string, trace.Span) utils.Reading); ok {
+		r0 = rf(ctx, query, span)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(utils.Reading)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(city)
+	if rf, ok := ret.Get(1).(func(context.Context, string, trace.Span) error); ok {
+		r1 = rf(ctx, query, span)
 	} else {
 		r1 = ret.Error(1)
 	}
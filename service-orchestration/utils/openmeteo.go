@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UrlOpenMeteoForecast is the current temperature path under the
+// open-meteo-forecast target URL. Open-Meteo only takes coordinates.
+const UrlOpenMeteoForecast = "%s/forecast?latitude=%s&longitude=%s&current=temperature_2m"
+
+// GetOpenMeteoTemperature returns the current temperature in Celsius at the
+// given coordinates from Open-Meteo, the second provider used to cross-check
+// the weather API.
+func GetOpenMeteoTemperature(ctx context.Context, lat, lon float64, span trace.Span) (float64, error) {
+	apiUrl := fmt.Sprintf(UrlOpenMeteoForecast, upstreams.URL("open-meteo-forecast", ""),
+		url.QueryEscape(strconv.FormatFloat(lat, 'f', 4, 64)), url.QueryEscape(strconv.FormatFloat(lon, 'f', 4, 64)))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		span.RecordError(fmt.Errorf("failed to create request: %w", err))
+		span.SetStatus(codes.Error, "failed to create request")
+		return 0, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+
+	resp, err := do("open-meteo-forecast", req)
+	if err != nil {
+		span.RecordError(fmt.Errorf("failed to get temperature: %w", err))
+		span.SetStatus(codes.Error, "failed to get temperature")
+		return 0, domain.NewProviderError("open-meteo", 0, err)
+	}
+	defer resp.Body.Close()
+	usage.FromContext(ctx).AddCall("open-meteo")
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		err := domain.NewProviderError("open-meteo", resp.StatusCode, nil)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Open-Meteo returned error status")
+		return 0, err
+	}
+
+	var body struct {
+		Current *struct {
+			Temperature *float64 `json:"temperature_2m"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Current == nil || body.Current.Temperature == nil {
+		err := fmt.Errorf("invalid Open-Meteo response: missing current.temperature_2m")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to decode response")
+		return 0, domain.NewProviderError("open-meteo", 0, err)
+	}
+	return *body.Current.Temperature, nil
+}
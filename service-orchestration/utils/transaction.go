@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const (
+	TransactionIDHeader     = "X-Transaction-ID"
+	TransactionIDBaggageKey = "transaction.id"
+
+	maxTransactionIDLength = 128
+)
+
+// TransactionID returns the caller's business transaction ID, preferring the
+// value propagated through baggage and falling back to the X-Transaction-ID
+// header for callers that reach this service directly.
+func TransactionID(ctx context.Context, header http.Header) string {
+	id := baggage.FromContext(ctx).Member(TransactionIDBaggageKey).Value()
+	if id == "" {
+		id = header.Get(TransactionIDHeader)
+	}
+	if len(id) > maxTransactionIDLength {
+		// Cut on a rune boundary so the ID stays valid UTF-8
+		n := maxTransactionIDLength
+		for n > 0 && !utf8.RuneStart(id[n]) {
+			n--
+		}
+		id = id[:n]
+	}
+	return id
+}
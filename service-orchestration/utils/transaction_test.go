@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTransactionIDTruncatesOnRuneBoundary(t *testing.T) {
+	header := http.Header{}
+	// 127 ASCII bytes followed by a two-byte rune that crosses the limit
+	header.Set(TransactionIDHeader, strings.Repeat("a", maxTransactionIDLength-1)+"ção")
+
+	id := TransactionID(context.Background(), header)
+	if !utf8.ValidString(id) || id != strings.Repeat("a", maxTransactionIDLength-1) {
+		t.Errorf("TransactionID() = %q (%d bytes), want the ASCII prefix only", id, len(id))
+	}
+}
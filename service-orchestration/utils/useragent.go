@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fhsmendes/open-telemetry/pkg/buildinfo"
+)
+
+const ServiceName = "service-orchestration"
+
+func UserAgent() string {
+	ua := fmt.Sprintf("%s/%s", ServiceName, buildinfo.Get().Version)
+	if contact := os.Getenv("UPSTREAM_CONTACT_URL"); contact != "" {
+		ua = fmt.Sprintf("%s (+%s)", ua, contact)
+	}
+	return ua
+}
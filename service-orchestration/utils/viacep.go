@@ -6,42 +6,71 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/fhsmendes/deploy-cloud-run/domain"
 	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
-const UrlViaCEP = "https://viacep.com.br/ws/%s/json/"
+// UrlViaCEP is the path of a CEP lookup under the viacep target URL.
+const UrlViaCEP = "%s/ws/%s/json/"
 
+// ErrCEPNotFound is kept for existing callers; it is domain.ErrCEPNotFound.
+var ErrCEPNotFound = domain.ErrCEPNotFound
+
+// ViaCEPClient resolves the address of a CEP; ViaCEP asks the ViaCEP API.
 type ViaCEPClient interface {
-	GetCityFromCEP(ctx context.Context, cep string, span trace.Span) (string, error)
+	GetAddressFromCEP(ctx context.Context, cep string, span trace.Span) (models.ViaCEP, error)
+}
+
+// ViaCEP is the ViaCEPClient of the ViaCEP API.
+type ViaCEP struct{}
+
+func (ViaCEP) GetAddressFromCEP(ctx context.Context, cep string, span trace.Span) (models.ViaCEP, error) {
+	return GetAddressFromCEP(ctx, cep, span)
 }
 
 func GetCityFromCEP(ctx context.Context, cep string, span trace.Span) (string, error) {
-	url := fmt.Sprintf(UrlViaCEP, cep)
+	address, err := GetAddressFromCEP(ctx, cep, span)
+	if err != nil {
+		return "", err
+	}
+	return address.Localidade, nil
+}
 
+func GetAddressFromCEP(ctx context.Context, cep string, span trace.Span) (models.ViaCEP, error) {
+	url := fmt.Sprintf(UrlViaCEP, upstreams.URL("viacep", ""), cep)
+
+	userAgent := UserAgent()
 	span.SetAttributes(
 		attribute.String("viacep.url", url),
 		attribute.String("viacep.cep", cep),
 		attribute.String("http.method", "GET"),
+		attribute.String("http.user_agent", userAgent),
 	)
 
+	ctx, done := withUpstreamTimeout(ctx, "viacep", span)
+	defer done()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to create HTTP request")
-		return "", err
+		return models.ViaCEP{}, err
 	}
+	req.Header.Set("User-Agent", userAgent)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := do("viacep", req)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "HTTP request failed")
-		return "", err
+		return models.ViaCEP{}, domain.NewProviderError("viacep", 0, err)
 	}
 	defer resp.Body.Close()
+	usage.FromContext(ctx).AddCall("viacep")
+	resp.Body = usage.CountBytes(ctx, "viacep", resp.Body)
 
 	span.SetAttributes(
 		attribute.Int("http.status_code", resp.StatusCode),
@@ -49,31 +78,33 @@ func GetCityFromCEP(ctx context.Context, cep string, span trace.Span) (string, e
 	)
 
 	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("ViaCEP API returned status: %d", resp.StatusCode)
+		err := domain.NewProviderError("viacep", resp.StatusCode, nil)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "unexpected HTTP status code")
-		return "", err
+		return models.ViaCEP{}, err
 	}
 
 	var viaCEP models.ViaCEP
 	if err := json.NewDecoder(resp.Body).Decode(&viaCEP); err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to decode JSON response")
-		return "", err
+		span.SetStatus(codes.Error, decodeFailure(err, "failed to decode JSON response"))
+		return models.ViaCEP{}, domain.NewProviderError("viacep", 0, err)
 	}
 
 	span.SetAttributes(
 		attribute.String("viacep.localidade", viaCEP.Localidade),
+		attribute.String("viacep.uf", viaCEP.UF),
+		attribute.String("viacep.ibge", viaCEP.IBGE),
 		attribute.Bool("viacep.erro", viaCEP.Erro),
 	)
 
 	if viaCEP.Erro || viaCEP.Localidade == "" {
-		err := fmt.Errorf("can not find zipcode")
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "can not find zipcodeP")
-		return "", err
+		// A CEP that does not exist is a client error; the span status is left
+		// to the caller
+		span.AddEvent("zipcode not found")
+		return models.ViaCEP{}, domain.ErrCEPNotFound
 	}
 
 	span.SetStatus(codes.Ok, "city successfully retrieved from ViaCEP")
-	return viaCEP.Localidade, nil
+	return viaCEP, nil
 }
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"github.com/fhsmendes/open-telemetry/pkg/attrfilter"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// viaCEPTransport answers every request with the address of a CEP in São
+// Paulo.
+type viaCEPTransport struct{}
+
+func (viaCEPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"localidade":"São Paulo","uf":"SP","ibge":"3550308"}`)),
+		Request:    req,
+	}, nil
+}
+
+// exportedSpan runs call under a span of a tracer provider that filters
+// attributes the way the services do by default, and returns the span as
+// exported.
+func exportedSpan(t *testing.T, call func(ctx context.Context)) tracetest.SpanStub {
+	t.Helper()
+	filter, err := attrfilter.Load(func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(attrfilter.NewProcessor(sdktrace.NewSimpleSpanProcessor(exporter), filter)),
+	)
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "provider call")
+	call(ctx)
+	span.End()
+	return exporter.GetSpans()[0]
+}
+
+func TestGetAddressFromCEPSpanDropsURL(t *testing.T) {
+	transport := HTTPClient.Transport
+	HTTPClient.Transport = viaCEPTransport{}
+	defer func() { HTTPClient.Transport = transport }()
+
+	got := exportedSpan(t, func(ctx context.Context) {
+		if _, err := GetCityFromCEP(ctx, "01001000", trace.SpanFromContext(ctx)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, kv := range got.Attributes {
+		if kv.Key == "viacep.url" || strings.Contains(kv.Value.Emit(), "/ws/01001000/") {
+			t.Errorf("exported attribute %s = %q, want the request URL dropped", kv.Key, kv.Value.Emit())
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestGetAddressFromCEPCountsAnsweredCalls(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		want      int
+	}{
+		{"answered", viaCEPTransport{}, 1},
+		{"error status", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}), 1},
+		{"unreachable", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := HTTPClient.Transport
+			HTTPClient.Transport = tt.transport
+			defer func() { HTTPClient.Transport = transport }()
+
+			ctx, u := usage.NewContext(context.Background())
+			GetAddressFromCEP(ctx, "01001000", trace.SpanFromContext(ctx))
+			if got := u.Cost().UpstreamCalls; got != tt.want {
+				t.Errorf("UpstreamCalls = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,63 +3,213 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/keypool"
 	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
-const UrlWeatherAPI = "https://api.weatherapi.com/v1/current.json?key=%s&q=%s"
+// UrlWeatherAPI is the current weather path under the weatherapi target URL.
+const UrlWeatherAPI = "%s/current.json?key=%s&q=%s"
 
+// WeatherAPIClient reads the current temperature of a query; WeatherAPI asks
+// the weather API.
 type WeatherAPIClient interface {
-	GetTemperature(ctx context.Context, city string, span trace.Span) (float64, error)
+	GetReading(ctx context.Context, query string, span trace.Span) (Reading, error)
+}
+
+// WeatherAPI is the WeatherAPIClient of the weather API.
+type WeatherAPI struct{}
+
+func (WeatherAPI) GetReading(ctx context.Context, query string, span trace.Span) (Reading, error) {
+	return GetReading(ctx, query, span)
+}
+
+var weatherKeys *keypool.Pool
+
+// SetWeatherAPIKeys makes GetTemperature take its key from p instead of the
+// weatherapi target's auth.
+func SetWeatherAPIKeys(p *keypool.Pool) {
+	weatherKeys = p
 }
 
 func GetTemperature(ctx context.Context, city string, span trace.Span) (float64, error) {
-	apiKey := os.Getenv("APIKeyWeather")
+	reading, err := GetReading(ctx, city, span)
+	return reading.Celsius, err
+}
+
+// GetReading returns the current temperature of city in both the units the
+// weather API reports.
+func GetReading(ctx context.Context, city string, span trace.Span) (Reading, error) {
+	var reading Reading
+	err := withWeatherKey(ctx, span, func(apiKey string) error {
+		var err error
+		reading, err = getTemperature(ctx, city, apiKey, span)
+		return err
+	})
+	return reading, err
+}
 
+// withWeatherKey calls fn with a key from the weather key pool, reporting the
+// outcome back to the pool, or with the weatherapi target's key when no pool
+// is set.
+func withWeatherKey(ctx context.Context, span trace.Span, fn func(apiKey string) error) error {
+	if weatherKeys == nil {
+		target, _ := upstreams.Target("weatherapi")
+		return fn(target.Auth.Value)
+	}
+
+	key, err := weatherKeys.Pick()
+	if err != nil {
+		span.RecordError(fmt.Errorf("every weather API key is quarantined: %w", err))
+		span.SetStatus(codes.Error, "every weather API key is quarantined")
+		return err
+	}
+	span.SetAttributes(attribute.String("weather.api_key", key.ID))
+
+	err = fn(key.Value)
+	weatherKeys.Report(ctx, key, err)
+	return err
+}
+
+func getTemperature(ctx context.Context, city, apiKey string, span trace.Span) (Reading, error) {
 	if apiKey == "" {
 		span.RecordError(fmt.Errorf("API key is not set"))
 		span.SetStatus(codes.Error, "API key is not set")
-		return 0, fmt.Errorf("API key is not set")
+		return Reading{}, fmt.Errorf("API key is not set")
 	}
 
 	encodedCity := url.QueryEscape(city)
-	fmt.Println("Encoded city:", encodedCity)
 
-	apiUrl := fmt.Sprintf(UrlWeatherAPI, apiKey, encodedCity)
+	ctx, done := withUpstreamTimeout(ctx, "weatherapi", span)
+	defer done()
+
+	apiUrl := fmt.Sprintf(UrlWeatherAPI, upstreams.URL("weatherapi", ""), apiKey, encodedCity)
 	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
 	if err != nil {
 		span.RecordError(fmt.Errorf("failed to create request: %w", err))
 		span.SetStatus(codes.Error, "failed to create request")
-		return 0, err
+		return Reading{}, err
 	}
+	userAgent := UserAgent()
+	req.Header.Set("User-Agent", userAgent)
+	span.SetAttributes(attribute.String("http.user_agent", userAgent))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := do("weatherapi", req)
 	if err != nil {
 		span.RecordError(fmt.Errorf("failed to get temperature: %w", err))
 		span.SetStatus(codes.Error, "failed to get temperature")
-		return 0, err
+		// A canceled request or a deadline that ran out says nothing of the
+		// provider, and is answered as such
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return Reading{}, err
+		}
+		return Reading{}, domain.NewProviderError("weatherapi", 0, err)
 	}
 	defer resp.Body.Close()
+	usage.FromContext(ctx).AddCall("weatherapi")
+	resp.Body = usage.CountBytes(ctx, "weatherapi", resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		span.RecordError(fmt.Errorf("weather API returned status: %d", resp.StatusCode))
+		if isNoMatchingLocation(resp) {
+			span.AddEvent("city not found")
+			return Reading{}, domain.ErrCityNotFound
+		}
+		err := domain.NewProviderError("weatherapi", resp.StatusCode, nil)
+		span.RecordError(err)
 		span.SetStatus(codes.Error, "weather API returned error status")
-		return 0, fmt.Errorf("weather API returned status: %d", resp.StatusCode)
+		return Reading{}, err
 	}
 
-	var weather models.WeatherAPI
-	if err := json.NewDecoder(resp.Body).Decode(&weather); err != nil {
+	reading, err := DecodeWeatherReading(resp.Body, os.Getenv("WEATHER_STRICT_DECODING") == "true")
+	if err != nil {
 		span.RecordError(fmt.Errorf("failed to decode response: %w", err))
-		span.SetStatus(codes.Error, "failed to decode response")
-		return 0, err
+		span.SetStatus(codes.Error, decodeFailure(err, "failed to decode response"))
+		return Reading{}, domain.NewProviderError("weatherapi", 0, err)
+	}
+
+	return reading, nil
+}
+
+// weatherAPINoMatchingLocation is the code of the 400 WeatherAPI answers
+// when it knows no location by the query.
+const weatherAPINoMatchingLocation = 1006
+
+// isNoMatchingLocation reports whether resp is WeatherAPI's answer to a query
+// naming no known location, a client error rather than a provider failure.
+func isNoMatchingLocation(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	var body struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	return json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error.Code == weatherAPINoMatchingLocation
+}
+
+type WeatherSchemaError struct {
+	Field  string
+	Reason string
+	// Err is the error that made the body unreadable, if any.
+	Err error
+}
+
+func (e *WeatherSchemaError) Error() string {
+	return fmt.Sprintf("invalid weather API response: %s %s", e.Field, e.Reason)
+}
+
+func (e *WeatherSchemaError) Unwrap() error {
+	return e.Err
+}
+
+// decodeFailure is the span status of a response that could not be decoded,
+// telling a body cut off at the target's size limit apart from a malformed one.
+func decodeFailure(err error, status string) string {
+	var tooLarge *upstream.ResponseTooLargeError
+	if errors.As(err, &tooLarge) {
+		return "response body too large"
+	}
+	return status
+}
+
+func DecodeWeatherResponse(r io.Reader, strict bool) (float64, error) {
+	reading, err := DecodeWeatherReading(r, strict)
+	return reading.Celsius, err
+}
+
+// DecodeWeatherReading reads the current temperature of a weather API
+// response. temp_c is required; temp_f is kept when present so it need not be
+// converted.
+func DecodeWeatherReading(r io.Reader, strict bool) (Reading, error) {
+	decoder := json.NewDecoder(r)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	var weather models.WeatherAPI
+	if err := decoder.Decode(&weather); err != nil {
+		return Reading{}, &WeatherSchemaError{Field: "body", Reason: err.Error(), Err: err}
+	}
+
+	if weather.Current == nil {
+		return Reading{}, &WeatherSchemaError{Field: "current", Reason: "is missing"}
+	}
+	if weather.Current.TempC == nil {
+		return Reading{}, &WeatherSchemaError{Field: "current.temp_c", Reason: "is missing"}
 	}
 
-	return weather.Current.TempC, nil
+	return Reading{Celsius: *weather.Current.TempC, Fahrenheit: weather.Current.TempF}, nil
 }
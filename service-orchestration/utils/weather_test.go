@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/open-telemetry/pkg/upstream"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDecodeWeatherResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		strict    bool
+		expected  float64
+		wantField string
+	}{
+		{"valid response", `{"current":{"temp_c":28.5}}`, false, 28.5, ""},
+		{"valid zero temperature", `{"current":{"temp_c":0}}`, false, 0, ""},
+		{"extra fields allowed", `{"location":{"name":"Sao Paulo"},"current":{"temp_c":20,"temp_f":68}}`, false, 20, ""},
+		{"temp_f known in strict mode", `{"current":{"temp_c":20,"temp_f":68}}`, true, 20, ""},
+		{"extra fields rejected in strict mode", `{"current":{"temp_c":20,"feelslike_c":18}}`, true, 0, "body"},
+		{"missing current", `{"location":{}}`, false, 0, "current"},
+		{"missing temp_c", `{"current":{"temp_f":68}}`, false, 0, "current.temp_c"},
+		{"null temp_c", `{"current":{"temp_c":null}}`, false, 0, "current.temp_c"},
+		{"malformed JSON", `{"current":`, false, 0, "body"},
+		{"wrong type", `{"current":{"temp_c":"hot"}}`, false, 0, "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DecodeWeatherResponse(strings.NewReader(tt.body), tt.strict)
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("DecodeWeatherResponse(%q) unexpected error: %v", tt.body, err)
+				}
+				if result != tt.expected {
+					t.Errorf("DecodeWeatherResponse(%q) = %f, want %f", tt.body, result, tt.expected)
+				}
+				return
+			}
+
+			var schemaErr *WeatherSchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("DecodeWeatherResponse(%q) error = %v, want *WeatherSchemaError", tt.body, err)
+			}
+			if schemaErr.Field != tt.wantField {
+				t.Errorf("DecodeWeatherResponse(%q) field = %q, want %q", tt.body, schemaErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestDecodeWeatherReading(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantF   float64
+		wantHas bool
+	}{
+		{"both units", `{"current":{"temp_c":21.7,"temp_f":71.1}}`, 71.1, true},
+		{"celsius only", `{"current":{"temp_c":21.7}}`, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading, err := DecodeWeatherReading(strings.NewReader(tt.body), false)
+			if err != nil {
+				t.Fatalf("DecodeWeatherReading(%q) unexpected error: %v", tt.body, err)
+			}
+			if reading.Celsius != 21.7 {
+				t.Errorf("Celsius = %f, want 21.7", reading.Celsius)
+			}
+			if (reading.Fahrenheit != nil) != tt.wantHas || (tt.wantHas && *reading.Fahrenheit != tt.wantF) {
+				t.Errorf("Fahrenheit = %v, want %f (set: %t)", reading.Fahrenheit, tt.wantF, tt.wantHas)
+			}
+		})
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestGetTemperatureMasksAPIKey(t *testing.T) {
+	transport := HTTPClient.Transport
+	HTTPClient.Transport = failingTransport{}
+	defer func() { HTTPClient.Transport = transport }()
+
+	_, err := getTemperature(context.Background(), "Recife", "secret-api-key", trace.SpanFromContext(context.Background()))
+	if err == nil {
+		t.Fatal("getTemperature() error = nil, want a transport error")
+	}
+	if strings.Contains(err.Error(), "secret-api-key") {
+		t.Errorf("getTemperature() error = %q, leaks the API key", err)
+	}
+}
+
+// oversizedTransport streams a body larger than the weatherapi target allows,
+// without declaring its length.
+type oversizedTransport struct{}
+
+func (oversizedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := `{"current":{"temp_c":20,"condition":"` + strings.Repeat("x", 2<<20) + `"}}`
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func TestGetTemperatureRejectsOversizedResponse(t *testing.T) {
+	transport := HTTPClient.Transport
+	HTTPClient.Transport = oversizedTransport{}
+	defer func() { HTTPClient.Transport = transport }()
+
+	_, err := getTemperature(context.Background(), "Recife", "key", trace.SpanFromContext(context.Background()))
+	var tooLarge *upstream.ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Target != "weatherapi" {
+		t.Fatalf("getTemperature() error = %v, want a weatherapi ResponseTooLargeError", err)
+	}
+}
+
+func TestGetTemperatureSpanHidesAPIKey(t *testing.T) {
+	transport := HTTPClient.Transport
+	HTTPClient.Transport = failingTransport{}
+	defer func() { HTTPClient.Transport = transport }()
+
+	got := exportedSpan(t, func(ctx context.Context) {
+		getTemperature(ctx, "Recife", "secret-api-key", trace.SpanFromContext(ctx))
+	})
+	values := []string{got.Status.Description}
+	for _, kv := range got.Attributes {
+		values = append(values, kv.Value.Emit())
+	}
+	for _, e := range got.Events {
+		for _, kv := range e.Attributes {
+			values = append(values, kv.Value.Emit())
+		}
+	}
+	for _, v := range values {
+		if strings.Contains(v, "secret-api-key") {
+			t.Errorf("exported span leaks the API key in %q", v)
+		}
+	}
+}
+
+// statusTransport answers every request with status and body.
+type statusTransport struct {
+	status int
+	body   string
+}
+
+func (t statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: t.status,
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestGetTemperatureErrors(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		transport  http.RoundTripper
+		wantStatus int
+	}{
+		{"unknown city", context.Background(), statusTransport{http.StatusBadRequest, `{"error":{"code":1006,"message":"No matching location found."}}`}, http.StatusNotFound},
+		{"other bad request", context.Background(), statusTransport{http.StatusBadRequest, `{"error":{"code":1003,"message":"Parameter q is missing."}}`}, http.StatusBadGateway},
+		{"provider error", context.Background(), statusTransport{http.StatusInternalServerError, `{}`}, http.StatusBadGateway},
+		{"deadline", expired, HTTPClient.Transport, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := HTTPClient.Transport
+			HTTPClient.Transport = tt.transport
+			defer func() { HTTPClient.Transport = transport }()
+
+			_, err := getTemperature(tt.ctx, "Nowhere", "key", trace.SpanFromContext(context.Background()))
+			if got := domain.HTTPStatus(err); got != tt.wantStatus {
+				t.Errorf("getTemperature() error = %v, answered with %d, want %d", err, got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fhsmendes/deploy-cloud-run/domain"
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UrlZippopotam is the path of a postal code lookup under the zippopotam
+// target URL, by country and code.
+const UrlZippopotam = "%s/%s/%s"
+
+type zippopotamResponse struct {
+	Places []struct {
+		PlaceName         string `json:"place name"`
+		State             string `json:"state"`
+		StateAbbreviation string `json:"state abbreviation"`
+		// Coordinates come as strings, e.g. "38.7167"
+		Latitude  string `json:"latitude"`
+		Longitude string `json:"longitude"`
+	} `json:"places"`
+}
+
+// GetAddressFromZippopotam looks up the postal code of country on Zippopotam,
+// which covers the countries ViaCEP does not. The address comes back in the
+// shape of ViaCEP's, with the state abbreviation, or the state when there is
+// none, as UF, and the coordinates of the place; a code it does not know is
+// domain.ErrCEPNotFound.
+func GetAddressFromZippopotam(ctx context.Context, country, code string, span trace.Span) (models.ViaCEP, error) {
+	url := fmt.Sprintf(UrlZippopotam, upstreams.URL("zippopotam", ""), country, code)
+
+	userAgent := UserAgent()
+	span.SetAttributes(
+		attribute.String("zippopotam.url", url),
+		attribute.String("zippopotam.country", country),
+		attribute.String("zippopotam.code", code),
+		attribute.String("http.method", "GET"),
+		attribute.String("http.user_agent", userAgent),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create HTTP request")
+		return models.ViaCEP{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := do("zippopotam", req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "HTTP request failed")
+		return models.ViaCEP{}, domain.NewProviderError("zippopotam", 0, err)
+	}
+	defer resp.Body.Close()
+	usage.FromContext(ctx).AddCall("zippopotam")
+	resp.Body = usage.CountBytes(ctx, "zippopotam", resp.Body)
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode == http.StatusNotFound {
+		span.AddEvent("zipcode not found")
+		return models.ViaCEP{}, domain.ErrCEPNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := domain.NewProviderError("zippopotam", resp.StatusCode, nil)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unexpected HTTP status code")
+		return models.ViaCEP{}, err
+	}
+
+	var body zippopotamResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, decodeFailure(err, "failed to decode JSON response"))
+		return models.ViaCEP{}, domain.NewProviderError("zippopotam", 0, err)
+	}
+	if len(body.Places) == 0 || body.Places[0].PlaceName == "" {
+		span.AddEvent("zipcode not found")
+		return models.ViaCEP{}, domain.ErrCEPNotFound
+	}
+
+	place := body.Places[0]
+	address := models.ViaCEP{Localidade: place.PlaceName, UF: place.StateAbbreviation}
+	if address.UF == "" {
+		address.UF = place.State
+	}
+	lat, latErr := strconv.ParseFloat(place.Latitude, 64)
+	lon, lonErr := strconv.ParseFloat(place.Longitude, 64)
+	if latErr == nil && lonErr == nil {
+		address.Latitude, address.Longitude = lat, lon
+	}
+	span.SetAttributes(
+		attribute.String("zippopotam.place", address.Localidade),
+		attribute.String("zippopotam.state", address.UF),
+	)
+	span.SetStatus(codes.Ok, "city successfully retrieved from Zippopotam")
+	return address, nil
+}
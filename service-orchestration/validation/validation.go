@@ -0,0 +1,194 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+const (
+	CodeRequired      = "required"
+	CodeInvalidType   = "invalid_type"
+	CodeOutOfRange    = "out_of_range"
+	CodeNotAllowed    = "not_allowed"
+	CodeInvalidFormat = "invalid_format"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Problem struct {
+	Status  int          `json:"status"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// Params parses typed query parameters and collects per-field errors instead of
+// failing on the first one, so clients get every problem in a single response.
+type Params struct {
+	values url.Values
+	errs   []FieldError
+}
+
+func NewParams(values url.Values) *Params {
+	return &Params{values: values}
+}
+
+func (p *Params) addError(field, code, format string, args ...any) {
+	p.errs = append(p.errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *Params) String(name string, required bool) string {
+	v := strings.TrimSpace(p.values.Get(name))
+	if v == "" && required {
+		p.addError(name, CodeRequired, "%s is required", name)
+	}
+	return v
+}
+
+func (p *Params) Int(name string, def, min, max int) int {
+	raw := strings.TrimSpace(p.values.Get(name))
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		p.addError(name, CodeInvalidType, "%s must be an integer", name)
+		return def
+	}
+	if v < min || v > max {
+		p.addError(name, CodeOutOfRange, "%s must be between %d and %d", name, min, max)
+		return def
+	}
+	return v
+}
+
+func (p *Params) Date(name string, required bool) time.Time {
+	raw := strings.TrimSpace(p.values.Get(name))
+	if raw == "" {
+		if required {
+			p.addError(name, CodeRequired, "%s is required", name)
+		}
+		return time.Time{}
+	}
+
+	v, err := time.Parse(DateLayout, raw)
+	if err != nil {
+		p.addError(name, CodeInvalidType, "%s must be a date in the format YYYY-MM-DD", name)
+		return time.Time{}
+	}
+	return v
+}
+
+// Duration parses a Go duration such as "6h"; values outside [min, max] are
+// out of range.
+func (p *Params) Duration(name string, def, min, max time.Duration) time.Duration {
+	raw := strings.TrimSpace(p.values.Get(name))
+	if raw == "" {
+		return def
+	}
+
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		p.addError(name, CodeInvalidType, "%s must be a duration such as 30m or 6h", name)
+		return def
+	}
+	if v < min || v > max {
+		p.addError(name, CodeOutOfRange, "%s must be between %s and %s", name, min, max)
+		return def
+	}
+	return v
+}
+
+func (p *Params) Enum(name, def string, allowed ...string) string {
+	raw := strings.TrimSpace(p.values.Get(name))
+	if raw == "" {
+		return def
+	}
+	if !slices.Contains(allowed, raw) {
+		p.addError(name, CodeNotAllowed, "%s must be one of: %s", name, strings.Join(allowed, ", "))
+		return def
+	}
+	return raw
+}
+
+// Check records a custom error for name when ok is false.
+func (p *Params) Check(ok bool, name, code, message string) {
+	if !ok {
+		p.addError(name, code, "%s", message)
+	}
+}
+
+func (p *Params) Errors() []FieldError {
+	return p.errs
+}
+
+// Status is 400 when any value could not be parsed and 422 when every value is
+// well-formed but some are semantically invalid.
+func (p *Params) Status() int {
+	for _, e := range p.errs {
+		if e.Code == CodeInvalidType {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func WriteProblem(w http.ResponseWriter, status int, message string, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{Status: status, Message: message, Errors: errs})
+}
+
+// DecodeJSON decodes a request body into v, rejecting unknown fields and
+// trailing data. The returned error is safe to show to clients.
+func DecodeJSON(body io.Reader, v any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid request body: unexpected data after JSON value")
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// Middleware parses the request query with parse and stores the result in the
+// request context, answering with a problem response when validation fails.
+// Handlers retrieve the parsed value with FromContext.
+func Middleware[T any](parse func(p *Params) T) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewParams(r.URL.Query())
+			value := parse(p)
+			if errs := p.Errors(); len(errs) > 0 {
+				WriteProblem(w, p.Status(), "invalid request parameters", errs)
+				return
+			}
+			ctx := context.WithValue(r.Context(), contextKey{}, value)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func FromContext[T any](ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(contextKey{}).(T)
+	return v, ok
+}
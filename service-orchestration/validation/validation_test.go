@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantCodes  map[string]string
+		wantStatus int
+	}{
+		{"all valid", "days=3&units=metric&date=2025-08-04&window=6h", map[string]string{}, 0},
+		{"defaults when missing", "", map[string]string{}, 0},
+		{"int not a number", "days=abc", map[string]string{"days": CodeInvalidType}, http.StatusBadRequest},
+		{"int out of range", "days=30", map[string]string{"days": CodeOutOfRange}, http.StatusUnprocessableEntity},
+		{"enum not allowed", "units=kelvin", map[string]string{"units": CodeNotAllowed}, http.StatusUnprocessableEntity},
+		{"invalid date", "date=04/08/2025", map[string]string{"date": CodeInvalidType}, http.StatusBadRequest},
+		{"invalid duration", "window=6", map[string]string{"window": CodeInvalidType}, http.StatusBadRequest},
+		{"duration out of range", "window=30s", map[string]string{"window": CodeOutOfRange}, http.StatusUnprocessableEntity},
+		{
+			"multiple errors",
+			"days=0&units=x",
+			map[string]string{"days": CodeOutOfRange, "units": CodeNotAllowed},
+			http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			p := NewParams(values)
+			p.Int("days", 1, 1, 14)
+			p.Enum("units", "metric", "metric", "imperial")
+			p.Date("date", false)
+			p.Duration("window", time.Hour, time.Minute, 24*time.Hour)
+
+			errs := p.Errors()
+			if len(errs) != len(tt.wantCodes) {
+				t.Fatalf("Errors() = %+v, want codes %v", errs, tt.wantCodes)
+			}
+			for _, e := range errs {
+				if tt.wantCodes[e.Field] != e.Code {
+					t.Errorf("field %q code = %q, want %q", e.Field, e.Code, tt.wantCodes[e.Field])
+				}
+			}
+			if len(errs) > 0 && p.Status() != tt.wantStatus {
+				t.Errorf("Status() = %d, want %d", p.Status(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	type query struct{ Days int }
+
+	h := Middleware(func(p *Params) query {
+		return query{Days: p.Int("days", 1, 1, 14)}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, ok := FromContext[query](r.Context())
+		if !ok || q.Days != 7 {
+			t.Errorf("FromContext() = %+v, %v, want Days 7", q, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast?days=7", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("valid request status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast?days=99", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid request status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "days" {
+		t.Errorf("problem errors = %+v, want one error for days", problem.Errors)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var v struct {
+		CEP string `json:"cep"`
+	}
+
+	if err := DecodeJSON(strings.NewReader(`{"cep":"01001000"}`), &v); err != nil || v.CEP != "01001000" {
+		t.Errorf("DecodeJSON valid body = %v, %q", err, v.CEP)
+	}
+	if err := DecodeJSON(strings.NewReader(`{"cep":"01001000","x":1}`), &v); err == nil {
+		t.Error("DecodeJSON should reject unknown fields")
+	}
+	if err := DecodeJSON(strings.NewReader(`{"cep":"01001000"}{}`), &v); err == nil {
+		t.Error("DecodeJSON should reject trailing data")
+	}
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileStore persists deliveries to a JSON lines file, so pending ones are
+// resumed after a restart, and answers lookups from memory. Every change
+// appends a line; the file is compacted to the deliveries kept each time it
+// is opened.
+type FileStore struct {
+	*MemoryStore
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileStore opens (or creates) the webhook deliveries file at path.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore()}
+
+	if err := s.load(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+	if err := s.compact(path); err != nil {
+		return nil, fmt.Errorf("failed to compact webhook deliveries: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook deliveries: %w", err)
+	}
+	s.file = f
+	return s, nil
+}
+
+// load reads every delivery in path; later lines replace earlier ones for the
+// same ID, and lines that cannot be decoded are skipped.
+func (s *FileStore) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var d Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil || d.ID == "" {
+			continue
+		}
+		s.MemoryStore.Put(d)
+	}
+	return scanner.Err()
+}
+
+// compact rewrites path with one line per loaded delivery.
+func (s *FileStore) compact(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, d := range s.deliveries {
+		if err := enc.Encode(d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Put(d Delivery) error {
+	s.MemoryStore.Put(d)
+
+	line, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
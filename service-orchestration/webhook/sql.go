@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SQLStore keeps deliveries in the webhook_deliveries table of a database
+// opened with database.Open.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const deliveryColumns = `id, event, url, payload, status, created, next_attempt, attempts, trace`
+
+func (s *SQLStore) Get(id string) (Delivery, bool, error) {
+	d, err := scanDelivery(s.db.QueryRow(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return Delivery{}, false, nil
+	}
+	if err != nil {
+		return Delivery{}, false, err
+	}
+	return d, true, nil
+}
+
+func (s *SQLStore) Put(d Delivery) error {
+	attempts, err := json.Marshal(d.Attempts)
+	if err != nil {
+		return err
+	}
+	trace, err := json.Marshal(d.Trace)
+	if err != nil {
+		return err
+	}
+	var next sql.NullInt64
+	if d.NextAttempt != nil {
+		next = sql.NullInt64{Int64: d.NextAttempt.UnixNano(), Valid: true}
+	}
+
+	_, err = s.db.Exec(`INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	status = excluded.status,
+	next_attempt = excluded.next_attempt,
+	attempts = excluded.attempts`,
+		d.ID, d.Event, d.URL, string(d.Payload), d.Status, d.Created.UnixNano(), next, string(attempts), string(trace))
+	if err != nil {
+		return fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) List(status string) ([]Delivery, error) {
+	rows, err := s.db.Query(`SELECT `+deliveryColumns+` FROM webhook_deliveries
+WHERE ? = '' OR status = ? ORDER BY created DESC`, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (Delivery, error) {
+	var d Delivery
+	var payload, attempts, trace string
+	var created int64
+	var next sql.NullInt64
+	if err := row.Scan(&d.ID, &d.Event, &d.URL, &payload, &d.Status, &created, &next, &attempts, &trace); err != nil {
+		return Delivery{}, err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	d.Created = time.Unix(0, created).UTC()
+	if next.Valid {
+		t := time.Unix(0, next.Int64).UTC()
+		d.NextAttempt = &t
+	}
+	if err := json.Unmarshal([]byte(attempts), &d.Attempts); err != nil {
+		return Delivery{}, fmt.Errorf("invalid attempts of webhook delivery %s: %w", d.ID, err)
+	}
+	json.Unmarshal([]byte(trace), &d.Trace)
+	return d, nil
+}
@@ -0,0 +1,386 @@
+// Package webhook delivers event notifications to HTTP endpoints. Every
+// delivery, and every attempt at it, is kept in a Store, so a notification
+// survives restarts, is retried with backoff until it is accepted or runs
+// out of attempts, and can be audited afterwards.
+//
+// Each delivery is sent with a stable Webhook-Id header. A delivery that was
+// accepted but not yet recorded when the process stopped is sent again on
+// the next start, so receivers drop the IDs they already processed to handle
+// each event exactly once.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/models"
+	"github.com/fhsmendes/deploy-cloud-run/tracing"
+	"github.com/fhsmendes/open-telemetry/pkg/carrier"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+	"github.com/fhsmendes/open-telemetry/pkg/supervisor"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderID    = "Webhook-Id"
+	HeaderEvent = "Webhook-Event"
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+
+	DefaultMaxAttempts = 5
+	// DefaultBackoff is the wait after the first failed attempt; it doubles
+	// after each one, up to maxBackoff.
+	DefaultBackoff = 30 * time.Second
+
+	maxBackoff = time.Hour
+	// maxDeliveries is how many finished deliveries MemoryStore keeps.
+	maxDeliveries = 1000
+)
+
+var deliveryAttempts, _ = otel.Meter("service-orchestration").Int64Counter(
+	"webhook.delivery.attempts",
+	metric.WithDescription("Number of webhook delivery attempts, by event and result (delivered, retry or failed)"),
+)
+
+// Attempt is one try at sending a delivery.
+type Attempt struct {
+	Time time.Time `json:"time"`
+	// Status is the HTTP status the receiver answered, if it answered.
+	Status     int     `json:"status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Delivery is an event to POST to URL, and the attempts made so far.
+type Delivery struct {
+	ID      string          `json:"id"`
+	Event   string          `json:"event"`
+	URL     string          `json:"url"`
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+	Created time.Time       `json:"created"`
+	// NextAttempt is when a pending delivery is tried next.
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+	Attempts    []Attempt  `json:"attempts"`
+	// Trace is the trace context of whatever enqueued the delivery, so
+	// attempts made after a restart stay in its trace.
+	Trace carrier.Metadata `json:"trace,omitempty"`
+}
+
+type Store interface {
+	Get(id string) (Delivery, bool, error)
+	Put(d Delivery) error
+	// List returns the deliveries with status, or every one for "", most
+	// recent first.
+	List(status string) ([]Delivery, error)
+}
+
+// MemoryStore keeps deliveries in memory, dropping the oldest finished ones
+// past maxDeliveries.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	deliveries map[string]Delivery
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: make(map[string]Delivery)}
+}
+
+func (s *MemoryStore) Get(id string) (Delivery, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.deliveries[id]
+	return d, ok, nil
+}
+
+func (s *MemoryStore) Put(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	s.prune()
+	return nil
+}
+
+// prune must be called with s.mu held.
+func (s *MemoryStore) prune() {
+	if len(s.deliveries) <= maxDeliveries {
+		return
+	}
+	var finished []Delivery
+	for _, d := range s.deliveries {
+		if d.Status != StatusPending {
+			finished = append(finished, d)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Created.Before(finished[j].Created) })
+	for _, d := range finished[:min(len(finished), len(s.deliveries)-maxDeliveries)] {
+		delete(s.deliveries, d.ID)
+	}
+}
+
+func (s *MemoryStore) List(status string) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []Delivery
+	for _, d := range s.deliveries {
+		if status == "" || d.Status == status {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Dispatcher enqueues deliveries in a Store and makes the attempts, one at a
+// time, from Run. Only one Dispatcher should run per Store.
+type Dispatcher struct {
+	MaxAttempts int
+	Backoff     time.Duration
+
+	store  Store
+	client *http.Client
+	clock  clock.Clock
+
+	// mu makes looking up and storing a new delivery in Enqueue atomic
+	mu   sync.Mutex
+	wake chan struct{}
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		clock:       clock.Real{},
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetClock replaces the clock used to schedule attempts.
+func (d *Dispatcher) SetClock(clk clock.Clock) {
+	d.clock = clk
+}
+
+// Enqueue stores a delivery of payload, as JSON, to url under id and wakes
+// Run to make its first attempt. An id already stored returns that delivery
+// instead, so an event enqueued twice is still delivered once.
+func (d *Dispatcher) Enqueue(ctx context.Context, id, event, url string, payload any) (Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, ok, err := d.store.Get(id)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("failed to read webhook delivery: %w", err)
+	}
+	if ok {
+		return existing, nil
+	}
+
+	now := d.clock.Now().UTC()
+	delivery := Delivery{
+		ID:          id,
+		Event:       event,
+		URL:         url,
+		Payload:     body,
+		Status:      StatusPending,
+		Created:     now,
+		NextAttempt: &now,
+		Attempts:    []Attempt{},
+		Trace:       carrier.Capture(ctx),
+	}
+	if err := d.store.Put(delivery); err != nil {
+		return Delivery{}, fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return delivery, nil
+}
+
+// Run makes the attempts of pending deliveries as they come due, starting
+// with those a previous run left pending, until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		wait, err := d.deliverDue(ctx)
+		if err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-d.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverDue attempts the pending deliveries that are due and returns how
+// long until the next one is.
+func (d *Dispatcher) deliverDue(ctx context.Context) (time.Duration, error) {
+	pending, err := d.store.List(StatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	wait := maxBackoff
+	for _, delivery := range pending {
+		if ctx.Err() != nil {
+			return 0, nil
+		}
+		if delivery.NextAttempt != nil {
+			if until := delivery.NextAttempt.Sub(d.clock.Now()); until > 0 {
+				wait = min(wait, until)
+				continue
+			}
+		}
+		if err := d.attempt(ctx, delivery); err != nil {
+			return 0, err
+		}
+		if delivery, ok, _ := d.store.Get(delivery.ID); ok && delivery.NextAttempt != nil {
+			wait = min(wait, delivery.NextAttempt.Sub(d.clock.Now()))
+		}
+	}
+	return max(wait, 0), nil
+}
+
+// attempt sends delivery once and records the outcome. An attempt cut short
+// by ctx is not recorded, so it is made again by the next run.
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) error {
+	ctx = carrier.Extract(ctx, delivery.Trace)
+	start := d.clock.Now()
+	var status int
+	err := tracing.WithSpan(ctx, otel.Tracer("service-orchestration"), "webhook-delivery", func(ctx context.Context, span trace.Span) (err error) {
+		// A panic while sending is a failed attempt, so a delivery that keeps
+		// panicking runs out of attempts instead of restarting Run forever.
+		defer supervisor.RecoverPanic(ctx, "webhook delivery "+delivery.ID, &err)
+
+		span.SetAttributes(
+			attribute.String("webhook.id", delivery.ID),
+			attribute.String("webhook.event", delivery.Event),
+			attribute.Int("webhook.attempt", len(delivery.Attempts)+1),
+		)
+		status, err = d.send(ctx, delivery)
+		if status != 0 {
+			span.SetAttributes(attribute.Int("http.status_code", status))
+		}
+		return err
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	now := d.clock.Now()
+	attempt := Attempt{Time: now.UTC(), Status: status, DurationMs: float64(now.Sub(start).Microseconds()) / 1000}
+	result := StatusDelivered
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.NextAttempt = nil
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+	case len(delivery.Attempts) >= d.MaxAttempts:
+		delivery.Attempts[len(delivery.Attempts)-1].Error = err.Error()
+		delivery.Status, result = StatusFailed, StatusFailed
+	default:
+		delivery.Attempts[len(delivery.Attempts)-1].Error = err.Error()
+		next := now.Add(d.backoff(len(delivery.Attempts))).UTC()
+		delivery.NextAttempt, result = &next, "retry"
+	}
+	deliveryAttempts.Add(ctx, 1, metric.WithAttributes(attribute.String("event", delivery.Event), attribute.String("result", result)))
+
+	if err := d.store.Put(delivery); err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// backoff is the wait after the nth failed attempt.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < n && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	carrier.Inject(ctx, carrier.Headers(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// ListHandler reports the deliveries, filtered by ?status=.
+func ListHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := store.List(r.URL.Query().Get("status"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "failed to list webhook deliveries"})
+			return
+		}
+		if list == nil {
+			list = []Delivery{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// Handler reports the delivery {id} with every attempt made at it.
+func Handler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		delivery, ok, err := store.Get(chi.URLParam(r, "id"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "failed to read webhook delivery"})
+			return
+		}
+		if !ok {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "webhook delivery not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, delivery)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fhsmendes/deploy-cloud-run/database"
+	"github.com/fhsmendes/open-telemetry/pkg/clock"
+)
+
+// receiver answers each delivery with the next status in statuses, repeating
+// the last one, and counts the requests by Webhook-Id.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderID) == "" || r.Header.Get(HeaderEvent) != "test.event" {
+			t.Errorf("delivery headers = %v, want Webhook-Id and Webhook-Event", r.Header)
+		}
+		n := int(calls.Add(1))
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	srv, calls := receiver(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusNoContent)
+	clk := clock.NewFake(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+	d := NewDispatcher(NewMemoryStore())
+	d.SetClock(clk)
+	ctx := context.Background()
+
+	if _, err := d.Enqueue(ctx, "1", "test.event", srv.URL, map[string]int{"rows": 3}); err != nil {
+		t.Fatal(err)
+	}
+	wait, err := d.deliverDue(ctx)
+	if err != nil || wait != DefaultBackoff {
+		t.Fatalf("deliverDue() = %s, %v; want the first backoff", wait, err)
+	}
+	// Not due yet
+	d.deliverDue(ctx)
+	if calls.Load() != 1 {
+		t.Fatalf("%d attempts before the backoff elapsed, want 1", calls.Load())
+	}
+
+	clk.Advance(DefaultBackoff)
+	if wait, _ := d.deliverDue(ctx); wait != 2*DefaultBackoff {
+		t.Errorf("wait after the second failure = %s, want %s", wait, 2*DefaultBackoff)
+	}
+	clk.Advance(2 * DefaultBackoff)
+	d.deliverDue(ctx)
+
+	delivery, _, _ := d.store.Get("1")
+	if delivery.Status != StatusDelivered || len(delivery.Attempts) != 3 || delivery.NextAttempt != nil {
+		t.Fatalf("delivery = %+v, want delivered on the third attempt", delivery)
+	}
+	if a := delivery.Attempts[0]; a.Status != http.StatusServiceUnavailable || a.Error == "" {
+		t.Errorf("first attempt = %+v, want the 503 recorded", a)
+	}
+	if a := delivery.Attempts[2]; a.Status != http.StatusNoContent || a.Error != "" {
+		t.Errorf("last attempt = %+v, want the 204 recorded", a)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	srv, calls := receiver(t, http.StatusInternalServerError)
+	clk := clock.NewFake(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+	d := NewDispatcher(NewMemoryStore())
+	d.SetClock(clk)
+	d.MaxAttempts = 2
+
+	d.Enqueue(context.Background(), "1", "test.event", srv.URL, nil)
+	for range 3 {
+		d.deliverDue(context.Background())
+		clk.Advance(time.Hour)
+	}
+	delivery, _, _ := d.store.Get("1")
+	if delivery.Status != StatusFailed || calls.Load() != 2 {
+		t.Errorf("delivery %s after %d calls, want failed after 2", delivery.Status, calls.Load())
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestDispatcherCountsPanicsAsFailedAttempts(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+	d := NewDispatcher(NewMemoryStore())
+	d.SetClock(clk)
+	d.MaxAttempts = 2
+	var calls atomic.Int32
+	d.client = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls.Add(1)
+		panic("receiver exploded")
+	})}
+
+	d.Enqueue(context.Background(), "1", "test.event", "http://receiver.invalid/hook", nil)
+	for range 3 {
+		if _, err := d.deliverDue(context.Background()); err != nil {
+			t.Fatalf("deliverDue() = %v", err)
+		}
+		clk.Advance(time.Hour)
+	}
+
+	delivery, _, _ := d.store.Get("1")
+	if delivery.Status != StatusFailed || calls.Load() != 2 {
+		t.Fatalf("delivery %s after %d calls, want failed after 2", delivery.Status, calls.Load())
+	}
+	if a := delivery.Attempts[0]; a.Error != "panic in webhook delivery 1: receiver exploded" {
+		t.Errorf("first attempt error = %q, want the recovered panic", a.Error)
+	}
+}
+
+func TestEnqueueIsIdempotent(t *testing.T) {
+	srv, calls := receiver(t, http.StatusOK)
+	d := NewDispatcher(NewMemoryStore())
+
+	first, _ := d.Enqueue(context.Background(), "export-1", "test.event", srv.URL, "a")
+	d.deliverDue(context.Background())
+	again, err := d.Enqueue(context.Background(), "export-1", "test.event", srv.URL, "b")
+	if err != nil || again.Created != first.Created || again.Status != StatusDelivered {
+		t.Fatalf("second Enqueue() = %+v, %v; want the delivered original", again, err)
+	}
+	d.deliverDue(context.Background())
+	if calls.Load() != 1 {
+		t.Errorf("event delivered %d times, want once", calls.Load())
+	}
+}
+
+func TestDispatcherResumesPendingDeliveries(t *testing.T) {
+	srv, calls := receiver(t, http.StatusOK)
+	db, err := database.Open(filepath.Join(t.TempDir(), "orchestration.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stores := map[string]func(t *testing.T) Store{
+		"file": func(t *testing.T) Store {
+			path := filepath.Join(t.TempDir(), "webhooks.jsonl")
+			s, err := NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Enqueued before a restart, delivered after it
+			s.Put(Delivery{ID: "1", Event: "test.event", URL: srv.URL, Payload: []byte(`{}`), Status: StatusPending, Attempts: []Attempt{}})
+			s.Close()
+			reopened, err := NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { reopened.Close() })
+			return reopened
+		},
+		"sqlite": func(t *testing.T) Store {
+			s := NewSQLStore(db)
+			if err := s.Put(Delivery{ID: "1", Event: "test.event", URL: srv.URL, Payload: []byte(`{}`), Status: StatusPending, Attempts: []Attempt{}}); err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			calls.Store(0)
+			store := open(t)
+			d := NewDispatcher(store)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- d.Run(ctx) }()
+			deadline := time.After(5 * time.Second)
+			for calls.Load() == 0 {
+				select {
+				case <-deadline:
+					t.Fatal("pending delivery not resumed")
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			cancel()
+			<-done
+
+			delivery, ok, err := store.Get("1")
+			if err != nil || !ok || delivery.Status != StatusDelivered || len(delivery.Attempts) != 1 {
+				t.Errorf("Get() = %+v, %v, %v; want delivered once", delivery, ok, err)
+			}
+			if list, _ := store.List(StatusPending); len(list) != 0 {
+				t.Errorf("List(pending) = %+v, want none left", list)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put(Delivery{ID: "1", Status: StatusFailed})
+
+	rec := httptest.NewRecorder()
+	ListHandler(store).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/webhooks?status=pending", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("pending list = %d %s, want an empty list", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(store).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/webhooks/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown delivery status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package workpool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const DefaultBatchWorkers = 4
+
+// ErrBusy is returned when the context ends before a worker is free.
+var ErrBusy = errors.New("worker pool busy")
+
+var waitDuration, _ = otel.Meter("service-orchestration").Float64Histogram(
+	"workpool.wait.duration",
+	metric.WithDescription("Time spent waiting for a free worker, by pool"),
+	metric.WithUnit("ms"),
+)
+
+// Pool bounds how many tasks of one kind run at the same time, so a burst of
+// them (e.g. a large batch) cannot take every upstream connection away from
+// the rest of the traffic.
+type Pool struct {
+	name  string
+	slots chan struct{}
+	busy  atomic.Int64
+}
+
+func New(name string, workers int) *Pool {
+	return &Pool{name: name, slots: make(chan struct{}, workers)}
+}
+
+// Do runs fn once a worker is free, or returns ErrBusy if ctx ends first.
+func (p *Pool) Do(ctx context.Context, fn func()) error {
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ErrBusy
+	}
+	waitDuration.Record(ctx, float64(time.Since(start).Microseconds())/1000,
+		metric.WithAttributes(attribute.String("pool", p.name)))
+
+	p.busy.Add(1)
+	defer func() {
+		p.busy.Add(-1)
+		<-p.slots
+	}()
+	fn()
+	return nil
+}
+
+// Middleware runs each request on a worker, answering 503 when none frees up
+// before the request is done.
+func (p *Pool) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := p.Do(r.Context(), func() {
+			next.ServeHTTP(w, r)
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		}
+	})
+}
+
+func (p *Pool) Busy() int {
+	return int(p.busy.Load())
+}
+
+func (p *Pool) Capacity() int {
+	return cap(p.slots)
+}
+
+// RegisterMetrics exposes the busy and total workers of pools as gauges.
+func RegisterMetrics(pools ...*Pool) error {
+	meter := otel.Meter("service-orchestration")
+
+	busy, err := meter.Int64ObservableGauge("workpool.workers.busy",
+		metric.WithDescription("Number of workers running a task, by pool"))
+	if err != nil {
+		return err
+	}
+	capacity, err := meter.Int64ObservableGauge("workpool.workers.capacity",
+		metric.WithDescription("Number of workers in the pool"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, p := range pools {
+			attrs := metric.WithAttributes(attribute.String("pool", p.name))
+			o.ObserveInt64(busy, int64(p.Busy()), attrs)
+			o.ObserveInt64(capacity, int64(p.Capacity()), attrs)
+		}
+		return nil
+	}, busy, capacity)
+	return err
+}